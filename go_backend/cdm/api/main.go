@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
@@ -10,55 +11,112 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/GavinHemsada/go-backend/internal/audit"
 	"github.com/GavinHemsada/go-backend/internal/config"
 	"github.com/GavinHemsada/go-backend/internal/database"
+	"github.com/GavinHemsada/go-backend/internal/directory"
+	"github.com/GavinHemsada/go-backend/internal/filter"
 	"github.com/GavinHemsada/go-backend/internal/handlers"
+	"github.com/GavinHemsada/go-backend/internal/loginguard"
+	"github.com/GavinHemsada/go-backend/internal/mailer"
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/GavinHemsada/go-backend/internal/password"
 	repository "github.com/GavinHemsada/go-backend/internal/repositories"
 	"github.com/GavinHemsada/go-backend/internal/router"
 	"github.com/GavinHemsada/go-backend/internal/services"
 	"github.com/GavinHemsada/go-backend/internal/websocket"
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
 
 func main() {
+	makeAdmin := flag.String("make-admin", "", "grant the admin role to the user with this username or email, then exit")
+	flag.Parse()
+
 	// Load config
 	cfg := config.Load()
 
-	dsn := fmt.Sprintf(
-		"postgres://%s:%s@%s:%s/%s?sslmode=disable",
-		cfg.DBUser,
-		cfg.DBPassword,
-		cfg.DBHost,
-		cfg.DBPort,
-		cfg.DBName,
-	)
-
-	// Run migrations
-	log.Println("Running database migrations...")
-	database.RunMigrations(dsn)
-
-	// Connect to database using sqlx
-	log.Println("Connecting to database...")
-	db, err := database.ConnectDB(cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName)
-	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
-	}
-	defer db.Close()
+	// Initialize repositories for the configured storage backend
+	var repos *repository.Repositories
+	switch cfg.Storage {
+	case "memory":
+		log.Println("Using in-memory storage, data will be lost on shutdown")
+		repos = repository.NewMemoryRepositories(repository.NewMemoryStore())
+	case "postgres":
+		dsn := fmt.Sprintf(
+			"postgres://%s:%s@%s:%s/%s?sslmode=disable",
+			cfg.DBUser,
+			cfg.DBPassword,
+			cfg.DBHost,
+			cfg.DBPort,
+			cfg.DBName,
+		)
 
-	// Initialize repositories
-	userRepo := repository.NewUserRepository(db)
-	roomRepo := repository.NewRoomRepository(db)
-	messageRepo := repository.NewMessageRepository(db)
+		// Run migrations
+		log.Println("Running database migrations...")
+		database.RunMigrations(dsn)
 
-	// Initialize services
-	userService := services.NewUserService(userRepo, cfg.JWTSecret)
-	roomService := services.NewRoomService(roomRepo)
-	messageService := services.NewMessageService(messageRepo, roomRepo)
+		// Connect to database using sqlx
+		log.Println("Connecting to database...")
+		db, err := database.ConnectDB(cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName)
+		if err != nil {
+			log.Fatalf("Failed to connect to database: %v", err)
+		}
+		defer db.Close()
 
-	// Initialize handlers
-	userHandler := handlers.NewUserHandler(userService)
-	roomHandler := handlers.NewRoomHandler(roomService)
-	messageHandler := handlers.NewMessageHandler(messageService)
+		repos = repository.NewPostgresRepositories(db)
+	case "sqlite":
+		// Run migrations
+		log.Println("Running SQLite migrations...")
+		database.RunSQLiteMigrations(cfg.SQLitePath)
+
+		db, err := database.ConnectSQLite(cfg.SQLitePath)
+		if err != nil {
+			log.Fatalf("Failed to open SQLite database: %v", err)
+		}
+		defer db.Close()
+
+		repos = repository.NewSQLiteRepositories(db)
+	default:
+		log.Fatalf("Unknown STORAGE %q, expected \"postgres\", \"sqlite\" or \"memory\"", cfg.Storage)
+	}
+
+	// Bootstrap an admin from the command line; the first one cannot be granted through the API
+	if *makeAdmin != "" {
+		if cfg.Storage == "memory" {
+			log.Fatal("-make-admin needs persistent storage, set STORAGE to postgres or sqlite")
+		}
+		user, err := repos.Users.GetByIdentifier(context.Background(), *makeAdmin)
+		if err != nil {
+			log.Fatalf("Failed to find user %q: %v", *makeAdmin, err)
+		}
+		if err := repos.Users.SetRole(context.Background(), user.ID, models.RoleAdmin); err != nil {
+			log.Fatalf("Failed to grant admin role: %v", err)
+		}
+		audit.NewLogger(repos.Audit).Record(context.Background(), models.AuditEvent{
+			Action:     models.AuditAdminSetRole,
+			TargetType: models.AuditTargetUser,
+			TargetID:   user.ID.String(),
+			Details:    "role admin, granted from the command line",
+		})
+		log.Printf("User %s is now an admin", user.Username)
+		return
+	}
+
+	// Build the content filter chain; filters with a zero limit are left out
+	fc := cfg.Filter
+	wordFilter := filter.NewWordFilter(fc.BlockWords, fc.MaskWords, fc.FlagWords, repos.Filters)
+	filters := []filter.Filter{filter.NewLinkFilter(fc.LinkAllow, fc.LinkDeny)}
+	if fc.NewAccountAge > 0 {
+		filters = append(filters, filter.NewAccountAgeFilter(fc.NewAccountAge, repos.Users))
+	}
+	if fc.MaxMentions > 0 {
+		filters = append(filters, filter.NewMentionFilter(fc.MaxMentions))
+	}
+	if fc.MaxDuplicates > 0 {
+		filters = append(filters, filter.NewFloodFilter(fc.MaxDuplicates, fc.DuplicateWindow))
+	}
+	filters = append(filters, wordFilter)
 
 	// Initialize Redis for WebSocket (optional - can work without Redis)
 	var redisClient *redis.Client
@@ -72,14 +130,210 @@ func main() {
 		}
 	}
 
+	// Failed logins and email cooldowns are tracked in Redis when it is
+	// available, so every instance shares the limits
+	var loginStore loginguard.Store = loginguard.NewMemoryStore()
+	if redisClient != nil {
+		loginStore = loginguard.NewFallbackStore(loginguard.NewRedisStore(redisClient), loginStore)
+	}
+	lc := cfg.Login
+	loginGuard := loginguard.NewGuard(loginguard.Config{
+		MaxFailures:   lc.MaxFailures,
+		Lockout:       lc.Lockout,
+		IPMaxFailures: lc.IPMaxFailures,
+		IPLockout:     lc.IPLockout,
+		Window:        lc.Window,
+		Delay:         lc.Delay,
+		MaxDelay:      lc.MaxDelay,
+	}, loginStore)
+
+	// Initialize services
+	auditLog := audit.NewLogger(repos.Audit)
+
+	// New passwords are checked against the policy and hashed with Argon2id
+	pc := cfg.Password
+	hasher, err := password.NewHasher(password.Params{
+		Memory:      pc.Argon2Memory,
+		Iterations:  pc.Argon2Iterations,
+		Parallelism: pc.Argon2Parallelism,
+	})
+	if err != nil {
+		log.Fatalf("Invalid password hashing configuration: %v", err)
+	}
+	passwordPolicy, err := password.NewPolicy(pc.MinLength, pc.DenylistFile)
+	if err != nil {
+		log.Fatalf("Invalid password policy: %v", err)
+	}
+
+	// Verification, password reset and login link emails go through the configured driver
+	var mail mailer.Mailer
+	switch mc := cfg.Mail; mc.Driver {
+	case "log":
+		mail = mailer.NewLogMailer(mc.From)
+	case "file":
+		fileMailer, err := mailer.NewFileMailer(mc.From, mc.Dir)
+		if err != nil {
+			log.Fatalf("Failed to create mail directory: %v", err)
+		}
+		mail = fileMailer
+		log.Printf("Writing mail to %s", mc.Dir)
+	case "smtp":
+		smtpMailer, err := mailer.NewSMTPMailer(mailer.SMTPConfig{
+			Host:     mc.SMTPHost,
+			Port:     mc.SMTPPort,
+			Username: mc.SMTPUsername,
+			Password: mc.SMTPPassword,
+			From:     mc.From,
+		})
+		if err != nil {
+			log.Fatalf("Invalid SMTP configuration: %v", err)
+		}
+		mail = smtpMailer
+		log.Printf("Sending mail through %s", mc.SMTPHost)
+	default:
+		log.Fatalf("Unknown MAIL_DRIVER %q, expected \"log\", \"file\" or \"smtp\"", mc.Driver)
+	}
+	emailService := services.NewEmailService(repos.Users, repos.Settings, mail, loginGuard, hasher, passwordPolicy, cfg.JWTSecret, cfg.Mail.AppURL, cfg.Mail.VerifyTTL, cfg.Mail.ResetTTL, auditLog, loginStore)
+	emailHandler := handlers.NewEmailHandler(emailService)
+
+	roomService := services.NewRoomService(repos.Rooms, repos.Moderation, repos.TxManager, emailService, auditLog)
+	messageService := services.NewMessageService(repos.Messages, repos.Rooms, repos.Users, repos.Moderation, repos.Reports, repos.TxManager, filter.NewChain(filters...), auditLog)
+
+	// Initialize handlers
+	roomHandler := handlers.NewRoomHandler(roomService)
+
 	// Initialize WebSocket handler
 	wsHandler := websocket.NewHandler(messageService, roomService, redisClient)
 	
 	// Start WebSocket hub
 	go wsHandler.GetHub().Run()
 
+	// Messages posted over REST reach live connections through the hub
+	messageHandler := handlers.NewMessageHandler(messageService, wsHandler.GetHub())
+
+	// Password logins go through the configured backends in order
+	var authenticators []services.Authenticator
+	var ldapAuthenticator *services.LDAPAuthenticator
+	for _, backend := range cfg.AuthBackends {
+		switch backend {
+		case "local":
+			authenticators = append(authenticators, services.NewLocalAuthenticator(repos.Users, repos.Identities, hasher))
+		case "ldap":
+			dc := cfg.LDAP
+			dir, err := directory.New(directory.Config{
+				URL:                  dc.URL,
+				StartTLS:             dc.StartTLS,
+				CAFile:               dc.CAFile,
+				InsecureSkipVerify:   dc.InsecureSkipVerify,
+				BindDN:               dc.BindDN,
+				BindPassword:         dc.BindPassword,
+				BaseDN:               dc.BaseDN,
+				UserFilter:           dc.UserFilter,
+				IDAttribute:          dc.IDAttribute,
+				UsernameAttribute:    dc.UsernameAttribute,
+				EmailAttribute:       dc.EmailAttribute,
+				DisplayNameAttribute: dc.DisplayNameAttribute,
+				GroupBaseDN:          dc.GroupBaseDN,
+				GroupFilter:          dc.GroupFilter,
+				GroupNameAttribute:   dc.GroupNameAttribute,
+			})
+			if err != nil {
+				log.Fatalf("Invalid LDAP configuration: %v", err)
+			}
+			ldapAuthenticator = services.NewLDAPAuthenticator(dir, repos.Users, repos.Identities, repos.TxManager, services.LDAPConfig{
+				RequiredGroups: dc.RequiredGroups,
+				AdminGroups:    dc.AdminGroups,
+			}, wsHandler.GetHub(), auditLog)
+			authenticators = append(authenticators, ldapAuthenticator)
+			log.Printf("LDAP login enabled with %s", dc.URL)
+		default:
+			log.Fatalf("Unknown AUTH_BACKENDS entry %q, expected \"local\" or \"ldap\"", backend)
+		}
+	}
+	authenticator := services.NewAuthChain(authenticators...)
+
+	// Linked directory accounts are synced in the background until shutdown
+	syncCtx, stopSync := context.WithCancel(context.Background())
+	defer stopSync()
+	if ldapAuthenticator != nil && cfg.LDAP.SyncInterval > 0 {
+		go ldapAuthenticator.RunSync(syncCtx, cfg.LDAP.SyncInterval)
+	}
+
+	// Suspending or deactivating an account closes its live connections through the hub
+	twoFactorService := services.NewTwoFactorService(repos.Users, authenticator, repos.TwoFactor, repos.Settings, wsHandler.GetHub(), loginGuard, cfg.JWTSecret, cfg.TOTPIssuer, auditLog)
+	twoFactorHandler := handlers.NewTwoFactorHandler(twoFactorService)
+	userService := services.NewUserService(repos.Users, authenticator, hasher, passwordPolicy, cfg.JWTSecret, wsHandler.GetHub(), loginGuard, twoFactorService, emailService, auditLog)
+	wsHandler.GetHub().OnActive(func(userID uuid.UUID) {
+		userService.Seen(context.Background(), userID)
+	})
+	privacyService := services.NewPrivacyService(repos.Users, repos.Rooms, repos.Privacy, auditLog)
+	userHandler := handlers.NewUserHandler(userService, privacyService)
+	magicLinkService := services.NewMagicLinkService(repos.Users, repos.LoginLinks, twoFactorService, mail, cfg.JWTSecret, cfg.Mail.AppURL, cfg.Mail.MagicLinkTTL, auditLog)
+	magicLinkHandler := handlers.NewMagicLinkHandler(magicLinkService)
+	profileService := services.NewProfileService(repos.Users, repos.Rooms, repos.Avatars, wsHandler.GetHub(), auditLog)
+	profileHandler := handlers.NewProfileHandler(profileService, userService)
+	wa := cfg.WebAuthn
+	passkeyService, err := services.NewPasskeyService(repos.Users, repos.Passkeys, wa.RPID, wa.RPDisplayName, wa.Origins, cfg.JWTSecret, auditLog)
+	if err != nil {
+		log.Fatalf("Invalid passkey configuration: %v", err)
+	}
+	passkeyHandler := handlers.NewPasskeyHandler(passkeyService)
+
+	accessTokenService := services.NewAccessTokenService(repos.Users, repos.AccessTokens, auditLog)
+	accessTokenHandler := handlers.NewAccessTokenHandler(accessTokenService)
+	oauthService := services.NewOAuthService(repos.Users, repos.OAuth, repos.TxManager, auditLog)
+	oauthHandler := handlers.NewOAuthHandler(oauthService)
+
+	// Single sign-on stays off until an identity provider is configured
+	var oidcHandler *handlers.OIDCHandler
+	if oc := cfg.OIDC; oc.Issuer != "" {
+		oidcService := services.NewOIDCService(repos.Users, repos.Identities, repos.TxManager, services.OIDCConfig{
+			Issuer:         oc.Issuer,
+			ClientID:       oc.ClientID,
+			ClientSecret:   oc.ClientSecret,
+			RedirectURL:    oc.RedirectURL,
+			Scopes:         oc.Scopes,
+			AllowedDomains: oc.AllowedDomains,
+			GroupsClaim:    oc.GroupsClaim,
+			AdminGroups:    oc.AdminGroups,
+		}, cfg.JWTSecret, auditLog)
+		oidcHandler = handlers.NewOIDCHandler(oidcService)
+		log.Printf("Single sign-on enabled with %s", oc.Issuer)
+	}
+	var samlHandler *handlers.SAMLHandler
+	if sc := cfg.SAML; sc.IDPMetadata != "" {
+		samlService, err := services.NewSAMLService(repos.Users, repos.Identities, repos.TxManager, services.SAMLConfig{
+			IDPMetadata:       sc.IDPMetadata,
+			EntityID:          sc.EntityID,
+			MetadataURL:       sc.RootURL + "/api/v1/users/login/saml/metadata",
+			ACSURL:            sc.RootURL + "/api/v1/users/login/saml/acs",
+			CertFile:          sc.CertFile,
+			KeyFile:           sc.KeyFile,
+			UsernameAttribute: sc.UsernameAttribute,
+			EmailAttribute:    sc.EmailAttribute,
+		}, cfg.JWTSecret, auditLog)
+		if err != nil {
+			log.Fatalf("Invalid SAML configuration: %v", err)
+		}
+		samlHandler = handlers.NewSAMLHandler(samlService)
+		log.Printf("SAML login enabled with %s", sc.IDPMetadata)
+	}
+
+	// Moderation enforces kicks, bans and mutes on live connections through the hub
+	moderationService := services.NewModerationService(repos.Rooms, repos.Moderation, repos.Users, repos.TxManager, wsHandler.GetHub(), auditLog)
+	moderationHandler := handlers.NewModerationHandler(moderationService)
+	botService := services.NewBotService(repos.Users, repos.Rooms, repos.Moderation, repos.TxManager, moderationService, accessTokenService, wsHandler.GetHub(), auditLog)
+	botHandler := handlers.NewBotHandler(botService)
+	reportService := services.NewReportService(repos.Reports, repos.Messages, repos.Rooms, moderationService, repos.TxManager)
+	reportHandler := handlers.NewReportHandler(reportService)
+	filterService := services.NewFilterService(repos.Filters, moderationService, wordFilter)
+	filterHandler := handlers.NewFilterHandler(filterService)
+	adminService := services.NewAdminService(repos.Users, repos.Rooms, repos.Stats, wsHandler.GetHub(), loginGuard, twoFactorService, emailService, auditLog)
+	auditService := services.NewAuditService(repos.Audit)
+	adminHandler := handlers.NewAdminHandler(adminService, auditService)
+
 	// Setup router
-	r := router.NewRouter(userHandler, roomHandler, messageHandler, wsHandler, cfg.JWTSecret)
+	r := router.NewRouter(userHandler, roomHandler, messageHandler, moderationHandler, reportHandler, filterHandler, adminHandler, twoFactorHandler, passkeyHandler, oidcHandler, samlHandler, accessTokenHandler, botHandler, oauthHandler, emailHandler, magicLinkHandler, profileHandler, wsHandler, cfg.JWTSecret)
 
 	// Setup HTTP server
 	port := cfg.ServerPort
@@ -109,6 +363,7 @@ func main() {
 	<-quit
 
 	log.Println("Shutting down server...")
+	stopSync()
 
 	// Graceful shutdown with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -118,5 +373,8 @@ func main() {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
+	// Flush messages still waiting to be saved
+	wsHandler.GetHub().Stop()
+
 	log.Println("Server exited")
 }
\ No newline at end of file
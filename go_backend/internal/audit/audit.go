@@ -0,0 +1,95 @@
+// Package audit records security and administrative events in the
+// append-only audit log.
+package audit
+
+import (
+	"context"
+	"log"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+)
+
+// Client describes where a request came from
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
+type clientKey struct{}
+
+// WithClient returns a copy of ctx carrying the request's client
+func WithClient(ctx context.Context, client Client) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+// ClientFromContext returns the client stored by WithClient, or the zero Client
+func ClientFromContext(ctx context.Context) Client {
+	client, _ := ctx.Value(clientKey{}).(Client)
+	return client
+}
+
+// Logger writes events to the audit log. A nil Logger records nothing.
+type Logger struct {
+	repo repository.AuditRepository
+}
+
+func NewLogger(repo repository.AuditRepository) *Logger {
+	return &Logger{repo: repo}
+}
+
+// Record appends event, taking the IP and user agent from ctx and defaulting
+// Result to success. Failures to write are logged, not returned, so auditing
+// never fails the action being audited. Called inside a transaction, it
+// writes the event on its own once the transaction has ended, so the event
+// outlives a rollback; use Write to record an action together with its
+// transaction.
+func (l *Logger) Record(ctx context.Context, event models.AuditEvent) {
+	if l == nil {
+		return
+	}
+
+	// The event is written even if the client has gone away meanwhile
+	recordCtx := repository.WithoutTx(context.WithoutCancel(ctx))
+	repository.AfterTx(ctx, func() {
+		if err := l.append(recordCtx, event); err != nil {
+			log.Printf("Error writing audit event %s: %v", event.Action, err)
+		}
+	})
+}
+
+// Write is Record for use inside the transaction of the audited action: the
+// event commits or rolls back with it, and a failure to write is returned so
+// the action fails rather than going unrecorded.
+func (l *Logger) Write(ctx context.Context, event models.AuditEvent) error {
+	if l == nil {
+		return nil
+	}
+	return l.append(ctx, event)
+}
+
+func (l *Logger) append(ctx context.Context, event models.AuditEvent) error {
+	client := ClientFromContext(ctx)
+	event.IP = client.IP
+	event.UserAgent = client.UserAgent
+	if event.Result == "" {
+		event.Result = models.AuditSuccess
+	}
+	return l.repo.Append(ctx, &event)
+}
+
+// Outcome marks event as failed when the action it describes returned an
+// error, adding the error to Details
+func Outcome(event models.AuditEvent, err error) models.AuditEvent {
+	if err == nil {
+		return event
+	}
+
+	event.Result = models.AuditFailure
+	if event.Details != "" {
+		event.Details += ": " + err.Error()
+	} else {
+		event.Details = err.Error()
+	}
+	return event
+}
@@ -6,12 +6,17 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
     ServerPort     string
+    Storage        string // "postgres" (default), "sqlite" or "memory"
+    SQLitePath     string
     DBHost         string
     DBPort         string
     DBUser         string
@@ -20,6 +25,125 @@ type Config struct {
     RedisAddr      string
     RedisPassword  string
     JWTSecret      string
+    TOTPIssuer     string // shown next to the account in authenticator apps
+    Filter         FilterConfig
+    Login          LoginConfig
+    WebAuthn       WebAuthnConfig
+    OIDC           OIDCConfig
+    SAML           SAMLConfig
+    AuthBackends   []string // AUTH_BACKENDS: password login backends, asked in order: "local" and "ldap"
+    LDAP           LDAPConfig
+    Mail           MailConfig
+    Password       PasswordConfig
+}
+
+// FilterConfig holds the server-wide settings of the content filter.
+// Lists are comma-separated in the environment.
+type FilterConfig struct {
+    BlockWords        []string      // FILTER_BLOCK_WORDS
+    MaskWords         []string      // FILTER_MASK_WORDS
+    FlagWords         []string      // FILTER_FLAG_WORDS
+    LinkAllow         []string      // FILTER_LINK_ALLOW: when set, only links to these domains pass
+    LinkDeny          []string      // FILTER_LINK_DENY
+    MaxDuplicates     int           // FILTER_MAX_DUPLICATES: identical messages allowed per window, 0 disables
+    DuplicateWindow   time.Duration // FILTER_DUPLICATE_WINDOW
+    MaxMentions       int           // FILTER_MAX_MENTIONS: mentions allowed per message, 0 disables
+    NewAccountAge     time.Duration // FILTER_NEW_ACCOUNT_AGE: younger accounts cannot post links or mentions, 0 disables
+}
+
+// LoginConfig holds the limits on failed logins. A zero limit disables that check.
+type LoginConfig struct {
+    MaxFailures   int           // LOGIN_MAX_FAILURES: failures that lock an account
+    Lockout       time.Duration // LOGIN_LOCKOUT
+    IPMaxFailures int           // LOGIN_IP_MAX_FAILURES: failures that lock a client IP, over all accounts
+    IPLockout     time.Duration // LOGIN_IP_LOCKOUT
+    Window        time.Duration // LOGIN_FAILURE_WINDOW: failures are forgotten this long after the last one
+    Delay         time.Duration // LOGIN_DELAY: wait after the first failure, doubling with each further one
+    MaxDelay      time.Duration // LOGIN_MAX_DELAY
+}
+
+// WebAuthnConfig identifies this server to passkey authenticators. Passkeys
+// are bound to RPID, so changing it invalidates every registered passkey.
+type WebAuthnConfig struct {
+    RPID          string   // WEBAUTHN_RP_ID: the site's domain, without scheme or port
+    RPDisplayName string   // WEBAUTHN_RP_NAME
+    Origins       []string // WEBAUTHN_ORIGINS: origins of the web clients, like https://chat.example.com
+}
+
+// OIDCConfig sets up single sign-on through an OpenID Connect provider.
+// Single sign-on is off while Issuer is empty.
+type OIDCConfig struct {
+    Issuer         string   // OIDC_ISSUER: the provider's URL, as in its discovery document
+    ClientID       string   // OIDC_CLIENT_ID
+    ClientSecret   string   // OIDC_CLIENT_SECRET: empty for public clients, which rely on PKCE alone
+    RedirectURL    string   // OIDC_REDIRECT_URL: this server's /api/v1/users/login/oidc/callback
+    Scopes         []string // OIDC_SCOPES
+    AllowedDomains []string // OIDC_ALLOWED_DOMAINS: when set, only emails at these domains may log in
+    GroupsClaim    string   // OIDC_GROUPS_CLAIM: the ID token claim listing the user's groups
+    AdminGroups    []string // OIDC_ADMIN_GROUPS: when set, members get the admin role and everyone else loses it
+}
+
+// SAMLConfig sets up single sign-on through a SAML 2.0 identity provider.
+// SAML login is off while IDPMetadata is empty.
+type SAMLConfig struct {
+    IDPMetadata       string // SAML_IDP_METADATA: URL or file path of the provider's metadata
+    RootURL           string // SAML_ROOT_URL: this server's public URL, like https://chat.example.com
+    EntityID          string // SAML_ENTITY_ID: defaults to the metadata URL
+    CertFile          string // SAML_CERT_FILE: PEM certificate the provider checks requests against
+    KeyFile           string // SAML_KEY_FILE: PEM private key that signs requests
+    UsernameAttribute string // SAML_USERNAME_ATTRIBUTE: name or friendly name of the username attribute
+    EmailAttribute    string // SAML_EMAIL_ATTRIBUTE
+}
+
+// LDAPConfig sets up password logins against an LDAP directory or Active
+// Directory, used when AUTH_BACKENDS lists "ldap". Filters may hold
+// {username}, and LDAP_GROUP_FILTER also {dn}.
+type LDAPConfig struct {
+    URL                  string        // LDAP_URL: ldap://host:389 or ldaps://host:636
+    StartTLS             bool          // LDAP_START_TLS: upgrade ldap:// connections to TLS before binding
+    CAFile               string        // LDAP_CA_FILE: PEM certificates to trust besides the system ones
+    InsecureSkipVerify   bool          // LDAP_INSECURE_SKIP_VERIFY: for testing only
+    BindDN               string        // LDAP_BIND_DN: service account that searches the directory
+    BindPassword         string        // LDAP_BIND_PASSWORD
+    BaseDN               string        // LDAP_BASE_DN
+    UserFilter           string        // LDAP_USER_FILTER
+    IDAttribute          string        // LDAP_ID_ATTRIBUTE: stable ID of an entry; objectGUID for Active Directory
+    UsernameAttribute    string        // LDAP_USERNAME_ATTRIBUTE
+    EmailAttribute       string        // LDAP_EMAIL_ATTRIBUTE
+    DisplayNameAttribute string        // LDAP_DISPLAY_NAME_ATTRIBUTE
+    GroupBaseDN          string        // LDAP_GROUP_BASE_DN: defaults to LDAP_BASE_DN
+    GroupFilter          string        // LDAP_GROUP_FILTER
+    GroupNameAttribute   string        // LDAP_GROUP_NAME_ATTRIBUTE
+    RequiredGroups       []string      // LDAP_REQUIRED_GROUPS: when set, only members may log in
+    AdminGroups          []string      // LDAP_ADMIN_GROUPS: when set, members get the admin role and everyone else loses it
+    SyncInterval         time.Duration // LDAP_SYNC_INTERVAL: how often linked accounts are synced, 0 disables
+}
+
+// MailConfig sets up outgoing email for address verification, password
+// resets and login links. The log driver prints messages, links included, instead of sending them.
+type MailConfig struct {
+    Driver       string        // MAIL_DRIVER: "log" (default), "file" or "smtp"
+    From         string        // MAIL_FROM: sender, like Chat App <no-reply@chat.example.com>
+    Dir          string        // MAIL_DIR: where the file driver writes .eml files
+    SMTPHost     string        // SMTP_HOST
+    SMTPPort     int           // SMTP_PORT: 465 uses TLS from the start, other ports STARTTLS
+    SMTPUsername string        // SMTP_USERNAME: empty sends without authenticating
+    SMTPPassword string        // SMTP_PASSWORD
+    AppURL       string        // APP_URL: the frontend's URL; links go to its /verify-email, /reset-password and /magic-link pages
+    VerifyTTL    time.Duration // EMAIL_VERIFY_TTL: how long verification links work
+    ResetTTL     time.Duration // PASSWORD_RESET_TTL: how long password reset links work
+    MagicLinkTTL time.Duration // MAGIC_LINK_TTL: how long login links work
+}
+
+// PasswordConfig holds the rules for new passwords and the cost of hashing
+// them with Argon2id. Stored hashes made otherwise are upgraded at login.
+// The default cost is OWASP's minimum recommendation.
+type PasswordConfig struct {
+    MinLength         int    // PASSWORD_MIN_LENGTH
+    DenylistFile      string // PASSWORD_DENYLIST_FILE: more passwords to refuse, one per line, on top of the built-in list
+    Argon2Memory      int    // ARGON2_MEMORY: KiB
+    Argon2Iterations  int    // ARGON2_ITERATIONS
+    Argon2Parallelism int    // ARGON2_PARALLELISM
 }
 
 func Load() *Config {
@@ -94,8 +218,99 @@ func Load() *Config {
 	if !found {
 		log.Printf("No .env file found or could not be loaded. Tried: %v. Using system environment variables.", pathsToTry)
 	}
+    storage := os.Getenv("STORAGE")
+    if storage == "" {
+        storage = "postgres"
+    }
+
+    sqlitePath := os.Getenv("SQLITE_PATH")
+    if sqlitePath == "" {
+        sqlitePath = "chat.db"
+    }
+
+    totpIssuer := os.Getenv("TOTP_ISSUER")
+    if totpIssuer == "" {
+        totpIssuer = "Chat App"
+    }
+
+    webAuthn := WebAuthnConfig{
+        RPID:          os.Getenv("WEBAUTHN_RP_ID"),
+        RPDisplayName: os.Getenv("WEBAUTHN_RP_NAME"),
+        Origins:       getEnvList("WEBAUTHN_ORIGINS"),
+    }
+    if webAuthn.RPID == "" {
+        webAuthn.RPID = "localhost"
+    }
+    if webAuthn.RPDisplayName == "" {
+        webAuthn.RPDisplayName = "Chat App"
+    }
+    if len(webAuthn.Origins) == 0 {
+        webAuthn.Origins = []string{"http://localhost:8080"}
+    }
+
+    oidc := OIDCConfig{
+        Issuer:         os.Getenv("OIDC_ISSUER"),
+        ClientID:       os.Getenv("OIDC_CLIENT_ID"),
+        ClientSecret:   os.Getenv("OIDC_CLIENT_SECRET"),
+        RedirectURL:    os.Getenv("OIDC_REDIRECT_URL"),
+        Scopes:         getEnvList("OIDC_SCOPES"),
+        AllowedDomains: getEnvList("OIDC_ALLOWED_DOMAINS"),
+        GroupsClaim:    os.Getenv("OIDC_GROUPS_CLAIM"),
+        AdminGroups:    getEnvList("OIDC_ADMIN_GROUPS"),
+    }
+    if len(oidc.Scopes) == 0 {
+        oidc.Scopes = []string{"openid", "email", "profile"}
+    }
+    if oidc.GroupsClaim == "" {
+        oidc.GroupsClaim = "groups"
+    }
+
+    saml := SAMLConfig{
+        IDPMetadata:       os.Getenv("SAML_IDP_METADATA"),
+        RootURL:           strings.TrimSuffix(os.Getenv("SAML_ROOT_URL"), "/"),
+        EntityID:          os.Getenv("SAML_ENTITY_ID"),
+        CertFile:          os.Getenv("SAML_CERT_FILE"),
+        KeyFile:           os.Getenv("SAML_KEY_FILE"),
+        UsernameAttribute: os.Getenv("SAML_USERNAME_ATTRIBUTE"),
+        EmailAttribute:    os.Getenv("SAML_EMAIL_ATTRIBUTE"),
+    }
+    if saml.UsernameAttribute == "" {
+        saml.UsernameAttribute = "uid"
+    }
+    if saml.EmailAttribute == "" {
+        saml.EmailAttribute = "mail"
+    }
+
+    authBackends := getEnvList("AUTH_BACKENDS")
+    if len(authBackends) == 0 {
+        authBackends = []string{"local"}
+    }
+
+    ldapConfig := LDAPConfig{
+        URL:                  os.Getenv("LDAP_URL"),
+        StartTLS:             getEnvBool("LDAP_START_TLS", false),
+        CAFile:               os.Getenv("LDAP_CA_FILE"),
+        InsecureSkipVerify:   getEnvBool("LDAP_INSECURE_SKIP_VERIFY", false),
+        BindDN:               os.Getenv("LDAP_BIND_DN"),
+        BindPassword:         os.Getenv("LDAP_BIND_PASSWORD"),
+        BaseDN:               os.Getenv("LDAP_BASE_DN"),
+        UserFilter:           getEnv("LDAP_USER_FILTER", "(uid={username})"),
+        IDAttribute:          getEnv("LDAP_ID_ATTRIBUTE", "entryUUID"),
+        UsernameAttribute:    getEnv("LDAP_USERNAME_ATTRIBUTE", "uid"),
+        EmailAttribute:       getEnv("LDAP_EMAIL_ATTRIBUTE", "mail"),
+        DisplayNameAttribute: getEnv("LDAP_DISPLAY_NAME_ATTRIBUTE", "displayName"),
+        GroupBaseDN:          os.Getenv("LDAP_GROUP_BASE_DN"),
+        GroupFilter:          getEnv("LDAP_GROUP_FILTER", "(member={dn})"),
+        GroupNameAttribute:   getEnv("LDAP_GROUP_NAME_ATTRIBUTE", "cn"),
+        RequiredGroups:       getEnvList("LDAP_REQUIRED_GROUPS"),
+        AdminGroups:          getEnvList("LDAP_ADMIN_GROUPS"),
+        SyncInterval:         getEnvDuration("LDAP_SYNC_INTERVAL", time.Hour),
+    }
+
     return &Config{
         ServerPort:    os.Getenv("SERVER_PORT"),
+        Storage:       storage,
+        SQLitePath:    sqlitePath,
         DBHost:        os.Getenv("DB_HOST"),
         DBPort:        os.Getenv("DB_PORT"),
         DBUser:        os.Getenv("DB_USER"),
@@ -104,5 +319,108 @@ func Load() *Config {
         RedisAddr:     os.Getenv("REDIS_ADDR"),
         RedisPassword: os.Getenv("REDIS_PASSWORD"),
         JWTSecret:     os.Getenv("JWT_SECRET"),
+        TOTPIssuer:    totpIssuer,
+        Filter: FilterConfig{
+            BlockWords:      getEnvList("FILTER_BLOCK_WORDS"),
+            MaskWords:       getEnvList("FILTER_MASK_WORDS"),
+            FlagWords:       getEnvList("FILTER_FLAG_WORDS"),
+            LinkAllow:       getEnvList("FILTER_LINK_ALLOW"),
+            LinkDeny:        getEnvList("FILTER_LINK_DENY"),
+            MaxDuplicates:   getEnvInt("FILTER_MAX_DUPLICATES", 3),
+            DuplicateWindow: getEnvDuration("FILTER_DUPLICATE_WINDOW", 30*time.Second),
+            MaxMentions:     getEnvInt("FILTER_MAX_MENTIONS", 5),
+            NewAccountAge:   getEnvDuration("FILTER_NEW_ACCOUNT_AGE", 0),
+        },
+        Login: LoginConfig{
+            MaxFailures:   getEnvInt("LOGIN_MAX_FAILURES", 5),
+            Lockout:       getEnvDuration("LOGIN_LOCKOUT", 15*time.Minute),
+            IPMaxFailures: getEnvInt("LOGIN_IP_MAX_FAILURES", 50),
+            IPLockout:     getEnvDuration("LOGIN_IP_LOCKOUT", 15*time.Minute),
+            Window:        getEnvDuration("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+            Delay:         getEnvDuration("LOGIN_DELAY", time.Second),
+            MaxDelay:      getEnvDuration("LOGIN_MAX_DELAY", 30*time.Second),
+        },
+        WebAuthn:     webAuthn,
+        OIDC:         oidc,
+        SAML:         saml,
+        AuthBackends: authBackends,
+        LDAP:         ldapConfig,
+        Mail: MailConfig{
+            Driver:       getEnv("MAIL_DRIVER", "log"),
+            From:         getEnv("MAIL_FROM", "Chat App <no-reply@localhost>"),
+            Dir:          getEnv("MAIL_DIR", "mail"),
+            SMTPHost:     os.Getenv("SMTP_HOST"),
+            SMTPPort:     getEnvInt("SMTP_PORT", 587),
+            SMTPUsername: os.Getenv("SMTP_USERNAME"),
+            SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+            AppURL:       strings.TrimSuffix(getEnv("APP_URL", "http://localhost:8080"), "/"),
+            VerifyTTL:    getEnvDuration("EMAIL_VERIFY_TTL", 48*time.Hour),
+            ResetTTL:     getEnvDuration("PASSWORD_RESET_TTL", time.Hour),
+            MagicLinkTTL: getEnvDuration("MAGIC_LINK_TTL", 15*time.Minute),
+        },
+        Password: PasswordConfig{
+            MinLength:         getEnvInt("PASSWORD_MIN_LENGTH", 8),
+            DenylistFile:      os.Getenv("PASSWORD_DENYLIST_FILE"),
+            Argon2Memory:      getEnvInt("ARGON2_MEMORY", 19*1024),
+            Argon2Iterations:  getEnvInt("ARGON2_ITERATIONS", 2),
+            Argon2Parallelism: getEnvInt("ARGON2_PARALLELISM", 1),
+        },
+    }
+}
+
+func getEnv(key, fallback string) string {
+    if value := os.Getenv(key); value != "" {
+        return value
+    }
+    return fallback
+}
+
+func getEnvBool(key string, fallback bool) bool {
+    value := os.Getenv(key)
+    if value == "" {
+        return fallback
+    }
+    b, err := strconv.ParseBool(value)
+    if err != nil {
+        log.Printf("Invalid %s %q, using %t", key, value, fallback)
+        return fallback
+    }
+    return b
+}
+
+// getEnvList splits a comma-separated variable, dropping empty entries
+func getEnvList(key string) []string {
+    var list []string
+    for _, item := range strings.Split(os.Getenv(key), ",") {
+        if item = strings.TrimSpace(item); item != "" {
+            list = append(list, item)
+        }
+    }
+    return list
+}
+
+func getEnvInt(key string, fallback int) int {
+    value := os.Getenv(key)
+    if value == "" {
+        return fallback
+    }
+    n, err := strconv.Atoi(value)
+    if err != nil {
+        log.Printf("Invalid %s %q, using %d", key, value, fallback)
+        return fallback
+    }
+    return n
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+    value := os.Getenv(key)
+    if value == "" {
+        return fallback
+    }
+    d, err := time.ParseDuration(value)
+    if err != nil {
+        log.Printf("Invalid %s %q, using %s", key, value, fallback)
+        return fallback
     }
+    return d
 }
\ No newline at end of file
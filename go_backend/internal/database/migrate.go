@@ -7,7 +7,9 @@ import (
 	"path/filepath"
 
 	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/database/sqlite"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 	_ "github.com/jackc/pgx/v5/stdlib"
 )
@@ -24,13 +26,33 @@ func RunMigrations(dsn string) {
 		log.Fatal(err)
 	}
 
-	// Dynamic migration path finding
-	migrationsPath := "migrations" // default
+	runMigrations(driver, "postgres", findMigrationsPath("migrations"))
+}
+
+// RunSQLiteMigrations applies the SQLite flavoured migrations from migrations/sqlite
+func RunSQLiteMigrations(path string) {
+	db, err := sql.Open("sqlite", SQLiteDSN(path))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	driver, err := sqlite.WithInstance(db, &sqlite.Config{})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	runMigrations(driver, "sqlite", findMigrationsPath(filepath.Join("migrations", "sqlite")))
+}
+
+// findMigrationsPath looks for dir in the working directory and up to three levels above it
+func findMigrationsPath(dir string) string {
+	migrationsPath := dir // default
 	searchPaths := []string{
-		"migrations",
-		"../migrations",
-		"../../migrations",
-		"../../../migrations",
+		dir,
+		filepath.Join("..", dir),
+		filepath.Join("..", "..", dir),
+		filepath.Join("..", "..", "..", dir),
 	}
 
 	for _, path := range searchPaths {
@@ -41,12 +63,15 @@ func RunMigrations(dsn string) {
 	}
 
 	log.Printf("Using migrations from: %s", migrationsPath)
+	return migrationsPath
+}
 
+func runMigrations(driver database.Driver, databaseName, migrationsPath string) {
 	pathToUse := filepath.ToSlash(migrationsPath)
-	
+
 	m, err := migrate.NewWithDatabaseInstance(
 		"file://" + pathToUse,
-		"postgres",
+		databaseName,
 		driver,
 	)
 	if err != nil {
@@ -0,0 +1,43 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+
+	"github.com/jmoiron/sqlx"
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteDSN builds a modernc.org/sqlite DSN for the database file at path.
+// Every connection enables foreign keys (needed for the cascade deletes), WAL
+// journaling so readers don't block the writer, and a busy timeout so
+// concurrent writers wait for the lock instead of failing with SQLITE_BUSY.
+// Transactions begin IMMEDIATE so a read-then-write transaction takes the
+// write lock up front rather than failing when it tries to upgrade.
+// Timestamps are stored in SQLite's own text format so they sort correctly.
+func SQLiteDSN(path string) string {
+	params := url.Values{}
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Add("_pragma", "synchronous(NORMAL)")
+	params.Set("_txlock", "immediate")
+	params.Set("_time_format", "sqlite")
+	return "file:" + path + "?" + params.Encode()
+}
+
+// ConnectSQLite opens the SQLite database file at path using sqlx and returns *sqlx.DB
+func ConnectSQLite(path string) (*sqlx.DB, error) {
+	db, err := sqlx.Connect("sqlite", SQLiteDSN(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+
+	// SQLite allows a single writer; a small pool keeps readers concurrent
+	// under WAL without piling up goroutines waiting on the write lock
+	db.SetMaxOpenConns(4)
+
+	log.Printf("SQLite database opened at %s", path)
+	return db, nil
+}
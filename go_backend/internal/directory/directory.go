@@ -0,0 +1,307 @@
+// Package directory authenticates and looks up users in an LDAP directory,
+// such as OpenLDAP or Active Directory.
+package directory
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// ErrUnknownUser is returned when no directory entry matches a username
+var ErrUnknownUser = errors.New("user not found in directory")
+
+// errUnavailable stands in for directory errors, which are logged instead
+// of being shown to users
+var errUnavailable = errors.New("directory is unavailable")
+
+// Active Directory's userAccountControl flag for disabled accounts
+const accountDisable = 0x2
+
+// Entries read per page when listing users
+const pageSize = 500
+
+// Config describes how to reach the directory and read its entries.
+// Filters may hold {username}, and GroupFilter also {dn}; both are escaped.
+type Config struct {
+	URL                  string // ldap://host:389 or ldaps://host:636
+	StartTLS             bool   // upgrade ldap:// connections before binding
+	CAFile               string // PEM certificates trusted besides the system ones
+	InsecureSkipVerify   bool
+	BindDN               string // the service account that searches the directory
+	BindPassword         string
+	BaseDN               string
+	UserFilter           string // like (uid={username})
+	IDAttribute          string // stable ID of an entry, like entryUUID or objectGUID
+	UsernameAttribute    string
+	EmailAttribute       string
+	DisplayNameAttribute string
+	GroupBaseDN          string // defaults to BaseDN
+	GroupFilter          string // like (member={dn})
+	GroupNameAttribute   string // like cn
+	Timeout              time.Duration
+}
+
+// Entry is a user as the directory describes them
+type Entry struct {
+	DN          string
+	ID          string // binary IDs such as objectGUID are hex-encoded
+	Username    string
+	Email       string
+	DisplayName string
+	Groups      []string
+	Disabled    bool // disabled in Active Directory
+}
+
+type Directory struct {
+	cfg       Config
+	tlsConfig *tls.Config
+}
+
+// New checks the configuration and loads the trusted certificates.
+// Nothing is dialled until the first call.
+func New(cfg Config) (*Directory, error) {
+	if cfg.URL == "" || cfg.BaseDN == "" {
+		return nil, errors.New("directory URL and base DN are required")
+	}
+	if !strings.Contains(cfg.UserFilter, "{username}") {
+		return nil, errors.New("user filter must contain {username}")
+	}
+	if cfg.GroupBaseDN == "" {
+		cfg.GroupBaseDN = cfg.BaseDN
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+
+	u, err := url.Parse(cfg.URL)
+	if err != nil || u.Hostname() == "" {
+		return nil, errors.New("directory URL is invalid")
+	}
+
+	tlsConfig := &tls.Config{ServerName: u.Hostname(), InsecureSkipVerify: cfg.InsecureSkipVerify}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificates found in " + cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return &Directory{cfg: cfg, tlsConfig: tlsConfig}, nil
+}
+
+// Issuer names the directory in linked identities
+func (d *Directory) Issuer() string {
+	return "ldap:" + strings.ToLower(d.cfg.BaseDN)
+}
+
+// Authenticate finds the user's entry with the service account, then binds
+// as the user to check the password. Groups are read with the service
+// account again, since users may not be allowed to search them.
+func (d *Directory) Authenticate(ctx context.Context, username, password string) (*Entry, error) {
+	// An empty password would make an unauthenticated bind, which succeeds
+	if username == "" || password == "" {
+		return nil, errors.New("invalid credentials")
+	}
+
+	conn, err := d.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	entry, err := d.findUser(conn, username)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, errors.New("invalid credentials")
+		}
+		return nil, unavailable(err)
+	}
+	if err := d.bindService(conn); err != nil {
+		return nil, unavailable(err)
+	}
+
+	if entry.Groups, err = d.groups(conn, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// Users returns every entry the user filter matches, with their groups
+func (d *Directory) Users(ctx context.Context) ([]Entry, error) {
+	conn, err := d.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// The filter is built by hand, as escaping would turn the wildcard into a literal
+	filter := strings.ReplaceAll(d.cfg.UserFilter, "{username}", "*")
+	result, err := conn.SearchWithPaging(d.userSearch(filter, 0), pageSize)
+	if err != nil {
+		return nil, unavailable(err)
+	}
+
+	entries := make([]Entry, 0, len(result.Entries))
+	for _, e := range result.Entries {
+		entry := d.entry(e)
+		if entry.ID == "" {
+			continue
+		}
+		if entry.Groups, err = d.groups(conn, &entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// connect dials the directory, upgrades to TLS when asked and binds as the service account
+func (d *Directory) connect(ctx context.Context) (*ldap.Conn, error) {
+	timeout := d.cfg.Timeout
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < timeout {
+		timeout = time.Until(deadline)
+	}
+
+	conn, err := ldap.DialURL(d.cfg.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: timeout}),
+		ldap.DialWithTLSConfig(d.tlsConfig),
+	)
+	if err != nil {
+		return nil, unavailable(err)
+	}
+	conn.SetTimeout(timeout)
+
+	if d.cfg.StartTLS {
+		if err := conn.StartTLS(d.tlsConfig); err != nil {
+			conn.Close()
+			return nil, unavailable(err)
+		}
+	}
+	if err := d.bindService(conn); err != nil {
+		conn.Close()
+		return nil, unavailable(err)
+	}
+	return conn, nil
+}
+
+// bindService binds as the service account, or stays anonymous without one
+func (d *Directory) bindService(conn *ldap.Conn) error {
+	if d.cfg.BindDN == "" {
+		return conn.UnauthenticatedBind("")
+	}
+	return conn.Bind(d.cfg.BindDN, d.cfg.BindPassword)
+}
+
+func (d *Directory) findUser(conn *ldap.Conn, username string) (*Entry, error) {
+	filter := strings.ReplaceAll(d.cfg.UserFilter, "{username}", ldap.EscapeFilter(username))
+	// Two entries are enough to tell that the username is ambiguous
+	result, err := conn.Search(d.userSearch(filter, 2))
+	if ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) || (err == nil && len(result.Entries) > 1) {
+		return nil, errors.New("username matches several directory entries")
+	}
+	if err != nil {
+		return nil, unavailable(err)
+	}
+	if len(result.Entries) == 0 {
+		return nil, ErrUnknownUser
+	}
+
+	entry := d.entry(result.Entries[0])
+	if entry.ID == "" {
+		return nil, errors.New("directory entry has no " + d.cfg.IDAttribute)
+	}
+	return &entry, nil
+}
+
+func (d *Directory) userSearch(filter string, sizeLimit int) *ldap.SearchRequest {
+	return ldap.NewSearchRequest(
+		d.cfg.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, sizeLimit, 0, false,
+		filter,
+		[]string{d.cfg.IDAttribute, d.cfg.UsernameAttribute, d.cfg.EmailAttribute, d.cfg.DisplayNameAttribute, "userAccountControl"},
+		nil,
+	)
+}
+
+func (d *Directory) entry(e *ldap.Entry) Entry {
+	uac, _ := strconv.Atoi(e.GetAttributeValue("userAccountControl"))
+	return Entry{
+		DN:          e.DN,
+		ID:          attributeID(e.GetRawAttributeValue(d.cfg.IDAttribute)),
+		Username:    e.GetAttributeValue(d.cfg.UsernameAttribute),
+		Email:       e.GetAttributeValue(d.cfg.EmailAttribute),
+		DisplayName: e.GetAttributeValue(d.cfg.DisplayNameAttribute),
+		Disabled:    uac&accountDisable != 0,
+	}
+}
+
+// groups returns the names of the groups the entry is a member of
+func (d *Directory) groups(conn *ldap.Conn, entry *Entry) ([]string, error) {
+	if d.cfg.GroupFilter == "" {
+		return nil, nil
+	}
+
+	filter := strings.NewReplacer(
+		"{dn}", ldap.EscapeFilter(entry.DN),
+		"{username}", ldap.EscapeFilter(entry.Username),
+	).Replace(d.cfg.GroupFilter)
+	result, err := conn.Search(ldap.NewSearchRequest(
+		d.cfg.GroupBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		filter, []string{d.cfg.GroupNameAttribute}, nil,
+	))
+	if err != nil {
+		return nil, unavailable(err)
+	}
+
+	var groups []string
+	for _, e := range result.Entries {
+		if name := e.GetAttributeValue(d.cfg.GroupNameAttribute); name != "" {
+			groups = append(groups, name)
+		}
+	}
+	return groups, nil
+}
+
+// unavailable logs a directory error and returns one fit to show users
+func unavailable(err error) error {
+	log.Printf("Directory error: %v", err)
+	return errUnavailable
+}
+
+// attributeID returns a text ID as is and hex-encodes a binary one
+func attributeID(raw []byte) string {
+	if !utf8.Valid(raw) {
+		return hex.EncodeToString(raw)
+	}
+	for _, r := range string(raw) {
+		if !unicode.IsPrint(r) {
+			return hex.EncodeToString(raw)
+		}
+	}
+	return string(raw)
+}
@@ -0,0 +1,21 @@
+package dtos
+
+import (
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+)
+
+// CreateAccessTokenDto asks for a personal access token. Without ExpiresAt
+// the token works until it is revoked.
+type CreateAccessTokenDto struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// AccessTokenResponse returns a new token. Token is only shown this once.
+type AccessTokenResponse struct {
+	*models.AccessToken
+	Token string `json:"token"`
+}
@@ -1,8 +1,23 @@
 package dtos
 
-import "github.com/GavinHemsada/go-backend/internal/models"
+import (
+	"time"
 
+	"github.com/GavinHemsada/go-backend/internal/models"
+)
+
+// AuthResponse carries a session token. When a login needs a second factor,
+// only TwoFactor is set.
 type AuthResponse struct {
-	User  *models.User `json:"user"`
-	Token string       `json:"token"`
-}
\ No newline at end of file
+	User          *models.User        `json:"user,omitempty"`
+	Token         string              `json:"token,omitempty"`
+	TwoFactor     *TwoFactorChallenge `json:"two_factor,omitempty"`
+	RecoveryCodes []string            `json:"recovery_codes,omitempty"` // shown once, when 2FA is enabled at login
+}
+
+// TwoFactorChallenge asks the client to finish logging in with a second factor
+type TwoFactorChallenge struct {
+	Method         string    `json:"method"` // "totp", or "totp_setup" when policy requires enrolling first
+	ChallengeToken string    `json:"challenge_token"`
+	ExpiresAt      time.Time `json:"expires_at"`
+}
@@ -0,0 +1,13 @@
+package dtos
+
+import "github.com/google/uuid"
+
+type CreateBotDto struct {
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+}
+
+// AddRoomBotDto names the bot a room moderator adds to their room
+type AddRoomBotDto struct {
+	BotID uuid.UUID `json:"bot_id"`
+}
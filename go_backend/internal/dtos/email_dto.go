@@ -0,0 +1,20 @@
+package dtos
+
+// VerifyEmailDto carries the token from a verification link
+type VerifyEmailDto struct {
+	Token string `json:"token"`
+}
+
+type ForgotPasswordDto struct {
+	Email string `json:"email"`
+}
+
+// ResetPasswordDto carries the token from a password reset link and the new password
+type ResetPasswordDto struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type EmailVerificationPolicyDto struct {
+	Required bool `json:"required"` // users must verify their email before joining rooms
+}
@@ -0,0 +1,22 @@
+package dtos
+
+import "time"
+
+type MagicLinkRequestDto struct {
+	Email string `json:"email"`
+}
+
+// MagicLinkResponse is returned whether or not the email belongs to an
+// account. The client keeps DeviceToken and sends it with the link's token,
+// so the link only works where it was asked for.
+type MagicLinkResponse struct {
+	DeviceToken string    `json:"device_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// MagicLinkLoginDto carries the token from a login link and the device token
+// of the request that sent it
+type MagicLinkLoginDto struct {
+	Token       string `json:"token"`
+	DeviceToken string `json:"device_token"`
+}
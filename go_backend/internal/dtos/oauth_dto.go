@@ -0,0 +1,81 @@
+package dtos
+
+import (
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+)
+
+// CreateOAuthAppDto registers an OAuth2 app. Confidential apps get a client
+// secret; public apps, such as single-page and mobile apps, do not.
+type CreateOAuthAppDto struct {
+	Name         string   `json:"name"`
+	RedirectURIs []string `json:"redirect_uris"`
+	Confidential bool     `json:"confidential"`
+}
+
+// OAuthAppResponse returns an app. ClientSecret is only shown when a
+// confidential app is registered.
+type OAuthAppResponse struct {
+	*models.OAuthApp
+	Confidential bool   `json:"confidential"`
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+// OAuthAuthorizeDto is an authorization request, as the app sent it to the
+// consent screen. Approve is the user's decision and only matters when it
+// is submitted.
+type OAuthAuthorizeDto struct {
+	ResponseType        string `json:"response_type"`
+	ClientID            string `json:"client_id"`
+	RedirectURI         string `json:"redirect_uri"`
+	Scope               string `json:"scope"`
+	State               string `json:"state"`
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+	Approve             bool   `json:"approve"`
+}
+
+// OAuthConsentResponse is what the consent screen shows. Granted tells
+// whether the user already granted every requested scope.
+type OAuthConsentResponse struct {
+	ClientID    uuid.UUID `json:"client_id"`
+	Name        string    `json:"name"`
+	RedirectURI string    `json:"redirect_uri"`
+	Scopes      []string  `json:"scopes"`
+	Granted     bool      `json:"granted"`
+}
+
+// OAuthRedirectResponse is where the consent screen sends the browser once
+// the user decided
+type OAuthRedirectResponse struct {
+	RedirectTo string `json:"redirect_to"`
+}
+
+// OAuthTokenResponse is the token endpoint's response (RFC 6749 section 5.1)
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+}
+
+// OAuthIntrospectionResponse describes a token (RFC 7662). Inactive tokens
+// only carry Active.
+type OAuthIntrospectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+}
+
+// OAuthErrorResponse is an error from the token, introspection and
+// revocation endpoints (RFC 6749 section 5.2)
+type OAuthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
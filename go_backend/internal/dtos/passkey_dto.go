@@ -0,0 +1,29 @@
+package dtos
+
+import (
+	"encoding/json"
+
+	"github.com/google/uuid"
+)
+
+// PasskeyCeremonyResponse starts a passkey ceremony. Options go to
+// navigator.credentials.create() or .get(); send SessionID back to finish.
+type PasskeyCeremonyResponse struct {
+	SessionID uuid.UUID   `json:"session_id"`
+	Options   interface{} `json:"options"`
+}
+
+// FinishPasskeyRegistrationDto carries the PublicKeyCredential returned by
+// navigator.credentials.create(), serialized with toJSON()
+type FinishPasskeyRegistrationDto struct {
+	SessionID  uuid.UUID       `json:"session_id"`
+	Name       string          `json:"name"`
+	Credential json.RawMessage `json:"credential"`
+}
+
+// FinishPasskeyLoginDto carries the PublicKeyCredential returned by
+// navigator.credentials.get(), serialized with toJSON()
+type FinishPasskeyLoginDto struct {
+	SessionID  uuid.UUID       `json:"session_id"`
+	Credential json.RawMessage `json:"credential"`
+}
@@ -0,0 +1,20 @@
+package dtos
+
+import "github.com/GavinHemsada/go-backend/internal/models"
+
+// UpdatePrivacyDto changes the settings that are present and keeps the rest.
+// Each is "everyone", "room_members" or "nobody".
+type UpdatePrivacyDto struct {
+	Email          *string `json:"email"`
+	Presence       *string `json:"presence"`
+	LastSeen       *string `json:"last_seen"`
+	DirectMessages *string `json:"direct_messages"`
+}
+
+// UserDirectoryResponse is a page of the user directory
+type UserDirectoryResponse struct {
+	Users  []models.PublicUser `json:"users"`
+	Total  int                 `json:"total"` // users matching the search in all
+	Limit  int                 `json:"limit"`
+	Offset int                 `json:"offset"`
+}
@@ -0,0 +1,22 @@
+package dtos
+
+import "time"
+
+// UpdateProfileDto changes the fields that are present and keeps the rest.
+// An empty string clears a field.
+type UpdateProfileDto struct {
+	Username    *string          `json:"username"`
+	DisplayName *string          `json:"display_name"`
+	Bio         *string          `json:"bio"`
+	TimeZone    *string          `json:"time_zone"` // IANA name, like "Europe/Berlin"
+	Pronouns    *string          `json:"pronouns"`
+	Status      *CustomStatusDto `json:"custom_status"`
+}
+
+// CustomStatusDto replaces the custom status. An empty one clears it; without
+// ExpiresAt it stays until changed.
+type CustomStatusDto struct {
+	Text      string     `json:"text"`
+	Emoji     string     `json:"emoji"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
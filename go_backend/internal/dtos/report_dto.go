@@ -0,0 +1,9 @@
+package dtos
+
+import "github.com/GavinHemsada/go-backend/internal/models"
+
+// ReportDetails is a report together with its audit trail
+type ReportDetails struct {
+	models.Report
+	Actions []models.ReportAction `json:"actions"`
+}
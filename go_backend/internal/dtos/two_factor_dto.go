@@ -0,0 +1,29 @@
+package dtos
+
+// TwoFactorLoginDto finishes a login. ChallengeToken comes from the login
+// response; send either Code or RecoveryCode.
+type TwoFactorLoginDto struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recovery_code"`
+}
+
+// TwoFactorCodeDto proves the second factor: a TOTP code or a recovery code
+type TwoFactorCodeDto struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type DisableTwoFactorDto struct {
+	Password     string `json:"password"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type TwoFactorPolicyDto struct {
+	RequiredFor string `json:"required_for"` // "none", "admins" or "everyone"
+}
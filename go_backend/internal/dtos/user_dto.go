@@ -10,4 +10,12 @@ type RegisterUserDto struct {
 type LoginUserDto struct {
 	Identifier string `json:"identifier"` // Can be email or username
 	Password   string `json:"password"`
-}
\ No newline at end of file
+}
+type DeactivateAccountDto struct {
+	Password string `json:"password"`
+}
+
+type ChangePasswordDto struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
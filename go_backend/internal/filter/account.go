@@ -0,0 +1,97 @@
+package filter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+	"github.com/google/uuid"
+)
+
+// How long an account's creation time stays cached after it was last needed
+const createdCacheTTL = 10 * time.Minute
+
+// AccountAgeFilter keeps accounts younger than minAge from posting links or
+// mentions, the usual payload of spam accounts
+type AccountAgeFilter struct {
+	minAge   time.Duration
+	userRepo repository.UserRepository
+
+	mu        sync.Mutex
+	created   map[uuid.UUID]cachedCreation // userID -> account creation time
+	lastSweep time.Time
+}
+
+type cachedCreation struct {
+	createdAt time.Time
+	usedAt    time.Time
+}
+
+func NewAccountAgeFilter(minAge time.Duration, userRepo repository.UserRepository) *AccountAgeFilter {
+	return &AccountAgeFilter{
+		minAge:    minAge,
+		userRepo:  userRepo,
+		created:   make(map[uuid.UUID]cachedCreation),
+		lastSweep: time.Now(),
+	}
+}
+
+func (f *AccountAgeFilter) Check(ctx context.Context, msg Message) (Result, error) {
+	if len(links(msg.Content)) == 0 && len(mentions(msg.Content)) == 0 {
+		return Result{Action: Allow}, nil
+	}
+
+	createdAt, err := f.createdAt(ctx, msg.UserID)
+	if err != nil {
+		return Result{}, err
+	}
+
+	if time.Since(createdAt) < f.minAge {
+		return Result{Action: Reject, Reason: "new accounts cannot post links or mentions yet"}, nil
+	}
+	return Result{Action: Allow}, nil
+}
+
+// createdAt returns when the user signed up, cached since it never changes.
+// Users who have not needed it for createdCacheTTL drop out of the cache.
+func (f *AccountAgeFilter) createdAt(ctx context.Context, userID uuid.UUID) (time.Time, error) {
+	now := time.Now()
+
+	f.mu.Lock()
+	f.sweep(now)
+	cached, ok := f.created[userID]
+	if ok {
+		cached.usedAt = now
+		f.created[userID] = cached
+	}
+	f.mu.Unlock()
+	if ok {
+		return cached.createdAt, nil
+	}
+
+	user, err := f.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	f.mu.Lock()
+	f.created[userID] = cachedCreation{createdAt: user.CreatedAt, usedAt: now}
+	f.mu.Unlock()
+	return user.CreatedAt, nil
+}
+
+// sweep forgets users who did not need their creation time within
+// createdCacheTTL, at most once per createdCacheTTL. Callers must hold mu.
+func (f *AccountAgeFilter) sweep(now time.Time) {
+	if now.Sub(f.lastSweep) < createdCacheTTL {
+		return
+	}
+	f.lastSweep = now
+
+	for userID, cached := range f.created {
+		if now.Sub(cached.usedAt) >= createdCacheTTL {
+			delete(f.created, userID)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+// Package filter checks chat messages before they are stored and broadcast.
+//
+// A Chain runs its filters in order. Each filter allows a message, modifies
+// its content for the filters after it, or rejects it, which stops the chain.
+// Filters can also flag a message; flagged messages are delivered but
+// reported to the room's moderators.
+package filter
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Action is a filter's decision on a message
+type Action int
+
+const (
+	Allow Action = iota
+	Modify
+	Reject
+)
+
+// Message is the part of a chat message the filters look at
+type Message struct {
+	RoomID  uuid.UUID
+	UserID  uuid.UUID
+	Content string
+}
+
+// Result is the outcome of one filter. Content is the new content when Action
+// is Modify, Reason explains a Reject, and Flags lists why a message was flagged.
+type Result struct {
+	Action  Action
+	Content string
+	Reason  string
+	Flags   []string
+}
+
+// Filter checks one aspect of a message
+type Filter interface {
+	Check(ctx context.Context, msg Message) (Result, error)
+}
+
+// Counter is a filter that counts the messages it allows, such as
+// FloodFilter. Uncount takes back a message it allowed that was not stored
+// after all, because a later filter rejected it or saving it failed.
+type Counter interface {
+	Filter
+	Uncount(msg Message)
+}
+
+// RejectedError is returned by Chain.Run when a filter rejects a message
+type RejectedError struct {
+	Reason string
+}
+
+func (e *RejectedError) Error() string {
+	return "message rejected: " + e.Reason
+}
+
+// Outcome is the result of running a message through a Chain
+type Outcome struct {
+	Content string   // Content after every modification
+	Flags   []string // Flags raised by any filter
+
+	counted []counted
+}
+
+// counted is a message as a Counter in the chain saw it
+type counted struct {
+	filter Counter
+	msg    Message
+}
+
+// Undo takes the message back from the filters that counted it. Call it when
+// a message that passed the chain is not stored.
+func (o *Outcome) Undo() {
+	if o == nil {
+		return
+	}
+	for _, c := range o.counted {
+		c.filter.Uncount(c.msg)
+	}
+	o.counted = nil
+}
+
+// Chain runs filters in order
+type Chain struct {
+	filters []Filter
+}
+
+func NewChain(filters ...Filter) *Chain {
+	return &Chain{filters: filters}
+}
+
+// Run passes msg through every filter. It returns a *RejectedError as soon as a
+// filter rejects the message, or any error a filter fails with; either way the
+// filters before it do not count the message.
+func (c *Chain) Run(ctx context.Context, msg Message) (*Outcome, error) {
+	outcome := &Outcome{Content: msg.Content}
+	if c == nil {
+		return outcome, nil
+	}
+
+	for _, f := range c.filters {
+		msg.Content = outcome.Content
+		result, err := f.Check(ctx, msg)
+		if err != nil {
+			outcome.Undo()
+			return nil, err
+		}
+
+		outcome.Flags = append(outcome.Flags, result.Flags...)
+		switch result.Action {
+		case Modify:
+			outcome.Content = result.Content
+		case Reject:
+			outcome.Undo()
+			return nil, &RejectedError{Reason: result.Reason}
+		}
+		if counter, ok := f.(Counter); ok {
+			outcome.counted = append(outcome.counted, counted{filter: counter, msg: msg})
+		}
+	}
+	return outcome, nil
+}
+
+var (
+	linkPattern    = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"']+`)
+	mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@(\w+)`)
+)
+
+// links returns the lower-case host names of the links in content
+func links(content string) []string {
+	var hosts []string
+	for _, link := range linkPattern.FindAllString(content, -1) {
+		host := strings.ToLower(link)
+		host = strings.TrimPrefix(host, "http://")
+		host = strings.TrimPrefix(host, "https://")
+		if i := strings.IndexAny(host, "/?#:"); i >= 0 {
+			host = host[:i]
+		}
+		if host = strings.TrimRight(host, ".,;!)"); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// mentions returns the distinct lower-case usernames mentioned in content
+func mentions(content string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, m := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		name := strings.ToLower(m[1])
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// matchesDomain reports whether host is domain or one of its subdomains
+func matchesDomain(host, domain string) bool {
+	domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
@@ -0,0 +1,110 @@
+package filter
+
+import (
+	"context"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FloodFilter rejects a message when its sender already sent the same text
+// max times within window, in any room. Counts are kept per server instance,
+// and messages that end up not being stored are taken back (see Counter).
+type FloodFilter struct {
+	max    int
+	window time.Duration
+
+	mu        sync.Mutex
+	sent      map[uuid.UUID][]sentMessage // userID -> recent messages, oldest first
+	lastSweep time.Time
+}
+
+type sentMessage struct {
+	hash uint64
+	at   time.Time
+}
+
+func NewFloodFilter(max int, window time.Duration) *FloodFilter {
+	return &FloodFilter{
+		max:       max,
+		window:    window,
+		sent:      make(map[uuid.UUID][]sentMessage),
+		lastSweep: time.Now(),
+	}
+}
+
+func (f *FloodFilter) Check(ctx context.Context, msg Message) (Result, error) {
+	hash := contentHash(msg.Content)
+	now := time.Now()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sweep(now)
+
+	recent := f.recent(msg.UserID, now)
+	duplicates := 0
+	for _, m := range recent {
+		if m.hash == hash {
+			duplicates++
+		}
+	}
+
+	if duplicates >= f.max {
+		f.sent[msg.UserID] = recent
+		return Result{Action: Reject, Reason: "you already sent this message, please wait before repeating it"}, nil
+	}
+
+	f.sent[msg.UserID] = append(recent, sentMessage{hash: hash, at: now})
+	return Result{Action: Allow}, nil
+}
+
+// Uncount forgets the user's latest copy of msg, which was not sent after all
+func (f *FloodFilter) Uncount(msg Message) {
+	hash := contentHash(msg.Content)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	messages := f.sent[msg.UserID]
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].hash == hash {
+			f.sent[msg.UserID] = append(messages[:i], messages[i+1:]...)
+			return
+		}
+	}
+}
+
+// recent returns the user's messages sent within the window. Callers must hold mu.
+func (f *FloodFilter) recent(userID uuid.UUID, now time.Time) []sentMessage {
+	messages := f.sent[userID]
+	i := 0
+	for i < len(messages) && now.Sub(messages[i].at) > f.window {
+		i++
+	}
+	return messages[i:]
+}
+
+// sweep forgets users who sent nothing within the window, at most once per window. Callers must hold mu.
+func (f *FloodFilter) sweep(now time.Time) {
+	if now.Sub(f.lastSweep) < f.window {
+		return
+	}
+	f.lastSweep = now
+
+	for userID, messages := range f.sent {
+		if len(messages) == 0 || now.Sub(messages[len(messages)-1].at) > f.window {
+			delete(f.sent, userID)
+		}
+	}
+}
+
+// contentHash hashes content ignoring case and whitespace differences
+func contentHash(content string) uint64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(strings.ToLower(strings.Join(strings.Fields(content), " "))))
+	return hash.Sum64()
+}
@@ -0,0 +1,41 @@
+package filter
+
+import "context"
+
+// LinkFilter rejects links to denied domains and, when an allow list is
+// set, links to any domain not on it. Subdomains match their parent domain.
+type LinkFilter struct {
+	allow []string
+	deny  []string
+}
+
+func NewLinkFilter(allow, deny []string) *LinkFilter {
+	return &LinkFilter{allow: allow, deny: deny}
+}
+
+func (f *LinkFilter) Check(ctx context.Context, msg Message) (Result, error) {
+	for _, host := range links(msg.Content) {
+		if !f.allowed(host) {
+			return Result{Action: Reject, Reason: "links to " + host + " are not allowed"}, nil
+		}
+	}
+	return Result{Action: Allow}, nil
+}
+
+func (f *LinkFilter) allowed(host string) bool {
+	for _, domain := range f.deny {
+		if matchesDomain(host, domain) {
+			return false
+		}
+	}
+
+	if len(f.allow) == 0 {
+		return true
+	}
+	for _, domain := range f.allow {
+		if matchesDomain(host, domain) {
+			return true
+		}
+	}
+	return false
+}
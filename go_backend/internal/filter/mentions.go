@@ -0,0 +1,22 @@
+package filter
+
+import (
+	"context"
+	"strconv"
+)
+
+// MentionFilter rejects messages that mention more than max distinct users
+type MentionFilter struct {
+	max int
+}
+
+func NewMentionFilter(max int) *MentionFilter {
+	return &MentionFilter{max: max}
+}
+
+func (f *MentionFilter) Check(ctx context.Context, msg Message) (Result, error) {
+	if len(mentions(msg.Content)) > f.max {
+		return Result{Action: Reject, Reason: "too many mentions, at most " + strconv.Itoa(f.max) + " per message"}, nil
+	}
+	return Result{Action: Allow}, nil
+}
@@ -0,0 +1,162 @@
+package filter
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+	"github.com/google/uuid"
+)
+
+// How long a room's word list is cached. Changes made through Invalidate
+// apply at once on this instance and within this time on the others.
+const roomWordsTTL = 30 * time.Second
+
+// WordFilter blocks, masks or flags listed words and phrases. Words are
+// matched whole and case-insensitively. The server-wide list applies to every
+// room and each room adds its own list; when both list a word, the stricter
+// action wins (block, then mask, then flag).
+type WordFilter struct {
+	global     map[string]string // word -> action
+	filterRepo repository.FilterRepository
+
+	mu    sync.Mutex
+	rooms map[uuid.UUID]*roomWords
+}
+
+type roomWords struct {
+	rules    *wordRules
+	loadedAt time.Time
+}
+
+// wordRules is a compiled word list, one pattern per action
+type wordRules struct {
+	block *regexp.Regexp
+	mask  *regexp.Regexp
+	flag  *regexp.Regexp
+}
+
+func NewWordFilter(block, mask, flag []string, filterRepo repository.FilterRepository) *WordFilter {
+	global := make(map[string]string)
+	addWords(global, flag, models.WordActionFlag)
+	addWords(global, mask, models.WordActionMask)
+	addWords(global, block, models.WordActionBlock)
+
+	return &WordFilter{
+		global:     global,
+		filterRepo: filterRepo,
+		rooms:      make(map[uuid.UUID]*roomWords),
+	}
+}
+
+// addWords adds words to list with action, keeping a stricter action already there
+func addWords(list map[string]string, words []string, action string) {
+	for _, word := range words {
+		word = strings.ToLower(strings.TrimSpace(word))
+		if word != "" && actionRank(action) >= actionRank(list[word]) {
+			list[word] = action
+		}
+	}
+}
+
+func actionRank(action string) int {
+	switch action {
+	case models.WordActionBlock:
+		return 3
+	case models.WordActionMask:
+		return 2
+	case models.WordActionFlag:
+		return 1
+	}
+	return 0
+}
+
+func (f *WordFilter) Check(ctx context.Context, msg Message) (Result, error) {
+	rules, err := f.roomRules(ctx, msg.RoomID)
+	if err != nil {
+		return Result{}, err
+	}
+
+	if rules.block != nil && rules.block.MatchString(msg.Content) {
+		return Result{Action: Reject, Reason: "message contains a blocked word"}, nil
+	}
+
+	result := Result{Action: Allow}
+	if rules.flag != nil {
+		for _, word := range rules.flag.FindAllString(msg.Content, -1) {
+			result.Flags = append(result.Flags, "word: "+strings.ToLower(word))
+		}
+	}
+
+	if rules.mask != nil && rules.mask.MatchString(msg.Content) {
+		result.Action = Modify
+		result.Content = rules.mask.ReplaceAllStringFunc(msg.Content, func(word string) string {
+			return strings.Repeat("*", utf8.RuneCountInString(word))
+		})
+	}
+	return result, nil
+}
+
+// Invalidate drops the cached word list of a room after it was changed
+func (f *WordFilter) Invalidate(roomID uuid.UUID) {
+	f.mu.Lock()
+	delete(f.rooms, roomID)
+	f.mu.Unlock()
+}
+
+// roomRules returns the compiled global and room word lists of a room
+func (f *WordFilter) roomRules(ctx context.Context, roomID uuid.UUID) (*wordRules, error) {
+	f.mu.Lock()
+	cached, ok := f.rooms[roomID]
+	f.mu.Unlock()
+	if ok && time.Since(cached.loadedAt) < roomWordsTTL {
+		return cached.rules, nil
+	}
+
+	words, err := f.filterRepo.GetWords(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make(map[string]string, len(f.global)+len(words))
+	for word, action := range f.global {
+		list[word] = action
+	}
+	for _, w := range words {
+		addWords(list, []string{w.Word}, w.Action)
+	}
+
+	rules := compileRules(list)
+	f.mu.Lock()
+	f.rooms[roomID] = &roomWords{rules: rules, loadedAt: time.Now()}
+	f.mu.Unlock()
+	return rules, nil
+}
+
+func compileRules(list map[string]string) *wordRules {
+	byAction := make(map[string][]string)
+	for word, action := range list {
+		byAction[action] = append(byAction[action], regexp.QuoteMeta(word))
+	}
+
+	compile := func(words []string) *regexp.Regexp {
+		if len(words) == 0 {
+			return nil
+		}
+		// Longest first so phrases win over the words they contain
+		sort.Slice(words, func(i, j int) bool { return len(words[i]) > len(words[j]) })
+		return regexp.MustCompile(`(?i)\b(?:` + strings.Join(words, "|") + `)\b`)
+	}
+
+	return &wordRules{
+		block: compile(byAction[models.WordActionBlock]),
+		mask:  compile(byAction[models.WordActionMask]),
+		flag:  compile(byAction[models.WordActionFlag]),
+	}
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/GavinHemsada/go-backend/internal/dtos"
+	"github.com/GavinHemsada/go-backend/internal/middleware"
+	"github.com/GavinHemsada/go-backend/internal/services"
+	"github.com/GavinHemsada/go-backend/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type AccessTokenHandler struct {
+	accessTokenService *services.AccessTokenService
+}
+
+func NewAccessTokenHandler(accessTokenService *services.AccessTokenService) *AccessTokenHandler {
+	return &AccessTokenHandler{
+		accessTokenService: accessTokenService,
+	}
+}
+
+// CreateToken handles issuing a personal access token
+func (h *AccessTokenHandler) CreateToken(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req dtos.CreateAccessTokenDto
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	token, err := h.accessTokenService.CreateToken(r.Context(), claims.UserID, req.Name, req.Scopes, req.ExpiresAt)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, token)
+}
+
+// GetTokens handles listing the user's personal access tokens
+func (h *AccessTokenHandler) GetTokens(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	tokens, err := h.accessTokenService.GetTokens(r.Context(), claims.UserID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve tokens")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, tokens)
+}
+
+// RevokeToken handles revoking one of the user's personal access tokens
+func (h *AccessTokenHandler) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	tokenID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid token ID")
+		return
+	}
+
+	if err := h.accessTokenService.RevokeToken(r.Context(), claims.UserID, tokenID); err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Token revoked successfully"})
+}
+
+// CheckToken validates a personal access token, for middleware.JWTMiddleware
+func (h *AccessTokenHandler) CheckToken(ctx context.Context, token string) (*utils.Claims, error) {
+	return h.accessTokenService.Authenticate(ctx, token)
+}
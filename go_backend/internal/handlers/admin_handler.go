@@ -0,0 +1,390 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/dtos"
+	"github.com/GavinHemsada/go-backend/internal/middleware"
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/GavinHemsada/go-backend/internal/services"
+	"github.com/GavinHemsada/go-backend/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type AdminHandler struct {
+	adminService *services.AdminService
+	auditService *services.AuditService
+}
+
+func NewAdminHandler(adminService *services.AdminService, auditService *services.AuditService) *AdminHandler {
+	return &AdminHandler{
+		adminService: adminService,
+		auditService: auditService,
+	}
+}
+
+type RoleRequest struct {
+	Role string `json:"role"` // "user" or "admin"
+}
+
+// IsAdmin reports whether a user is a global admin, for middleware.RequireAdmin
+func (h *AdminHandler) IsAdmin(ctx context.Context, userID uuid.UUID) bool {
+	return h.adminService.IsAdmin(ctx, userID)
+}
+
+// GetUsers handles listing every user
+func (h *AdminHandler) GetUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := h.adminService.GetUsers(r.Context())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve users")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, users)
+}
+
+// SuspendUser handles suspending a user account
+func (h *AdminHandler) SuspendUser(w http.ResponseWriter, r *http.Request) {
+	actorID, targetID, ok := parseAdminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := h.adminService.SuspendUser(r.Context(), actorID, targetID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, user)
+}
+
+// DeactivateUser handles closing a user account while keeping its messages
+func (h *AdminHandler) DeactivateUser(w http.ResponseWriter, r *http.Request) {
+	actorID, targetID, ok := parseAdminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := h.adminService.DeactivateUser(r.Context(), actorID, targetID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, user)
+}
+
+// ReactivateUser handles lifting a suspension or deactivation
+func (h *AdminHandler) ReactivateUser(w http.ResponseWriter, r *http.Request) {
+	actorID, targetID, ok := parseAdminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := h.adminService.ReactivateUser(r.Context(), actorID, targetID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, user)
+}
+
+// DeleteUser handles deleting a user account
+func (h *AdminHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	actorID, targetID, ok := parseAdminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.adminService.DeleteUser(r.Context(), actorID, targetID); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "User deleted successfully"})
+}
+
+// SetRole handles granting or revoking the admin role
+func (h *AdminHandler) SetRole(w http.ResponseWriter, r *http.Request) {
+	actorID, targetID, ok := parseAdminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	var req RoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	user, err := h.adminService.SetRole(r.Context(), actorID, targetID, req.Role)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, user)
+}
+
+// DeleteRoom handles force-deleting any room
+func (h *AdminHandler) DeleteRoom(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	roomID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid room ID")
+		return
+	}
+
+	if err := h.adminService.DeleteRoom(r.Context(), claims.UserID, roomID); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Room deleted successfully"})
+}
+
+// UnlockUser handles lifting a failed-login lock from a user account
+func (h *AdminHandler) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	actorID, targetID, ok := parseAdminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.adminService.UnlockUser(r.Context(), actorID, targetID); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "User unlocked successfully"})
+}
+
+// UnlockIP handles lifting a failed-login lock from a client IP
+func (h *AdminHandler) UnlockIP(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := h.adminService.UnlockIP(r.Context(), claims.UserID, mux.Vars(r)["ip"]); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "IP address unlocked successfully"})
+}
+
+// ResetTwoFactor handles removing a user's second factor
+func (h *AdminHandler) ResetTwoFactor(w http.ResponseWriter, r *http.Request) {
+	actorID, targetID, ok := parseAdminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.adminService.ResetTwoFactor(r.Context(), actorID, targetID); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Two-factor authentication reset successfully"})
+}
+
+// GetTwoFactorPolicy handles reporting who must use two-factor authentication
+func (h *AdminHandler) GetTwoFactorPolicy(w http.ResponseWriter, r *http.Request) {
+	requiredFor, err := h.adminService.GetTwoFactorPolicy(r.Context())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve two-factor policy")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, dtos.TwoFactorPolicyDto{RequiredFor: requiredFor})
+}
+
+// SetTwoFactorPolicy handles changing who must use two-factor authentication
+func (h *AdminHandler) SetTwoFactorPolicy(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req dtos.TwoFactorPolicyDto
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := h.adminService.SetTwoFactorPolicy(r.Context(), claims.UserID, req.RequiredFor); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, req)
+}
+
+// GetEmailVerificationPolicy handles reporting whether users must verify their email before joining rooms
+func (h *AdminHandler) GetEmailVerificationPolicy(w http.ResponseWriter, r *http.Request) {
+	required, err := h.adminService.GetEmailVerificationPolicy(r.Context())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve email verification policy")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, dtos.EmailVerificationPolicyDto{Required: required})
+}
+
+// SetEmailVerificationPolicy handles changing whether users must verify their email before joining rooms
+func (h *AdminHandler) SetEmailVerificationPolicy(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req dtos.EmailVerificationPolicyDto
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := h.adminService.SetEmailVerificationPolicy(r.Context(), claims.UserID, req.Required); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to change email verification policy")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, req)
+}
+
+// GetStats handles retrieving server statistics
+func (h *AdminHandler) GetStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.adminService.GetStats(r.Context())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve stats")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, stats)
+}
+
+// GetClients handles listing the WebSocket clients connected to this server
+func (h *AdminHandler) GetClients(w http.ResponseWriter, r *http.Request) {
+	utils.RespondWithJSON(w, http.StatusOK, h.adminService.GetClients())
+}
+
+// GetAuditLog handles searching the audit log, newest first
+func (h *AdminHandler) GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := models.AuditFilter{
+		Action:   query.Get("action"),
+		TargetID: query.Get("target_id"),
+		Result:   query.Get("result"),
+		Limit:    50, // default
+	}
+
+	if actor := query.Get("actor_id"); actor != "" {
+		actorID, err := uuid.Parse(actor)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid actor ID")
+			return
+		}
+		filter.ActorID = &actorID
+	}
+
+	for name, dest := range map[string]**time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value := query.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				utils.RespondWithError(w, http.StatusBadRequest, "Invalid "+name+", expected an RFC 3339 time")
+				return
+			}
+			*dest = &t
+		}
+	}
+
+	if limitStr := query.Get("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil {
+			filter.Limit = parsedLimit
+		}
+	}
+
+	if offsetStr := query.Get("offset"); offsetStr != "" {
+		if parsedOffset, err := strconv.Atoi(offsetStr); err == nil {
+			filter.Offset = parsedOffset
+		}
+	}
+
+	events, err := h.auditService.GetEvents(r.Context(), filter)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, events)
+}
+
+// ExportAuditLog handles streaming the audit log as JSON lines, oldest first
+func (h *AdminHandler) ExportAuditLog(w http.ResponseWriter, r *http.Request) {
+	var afterSeq int64
+	if afterStr := r.URL.Query().Get("after_seq"); afterStr != "" {
+		parsed, err := strconv.ParseInt(afterStr, 10, 64)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid after_seq")
+			return
+		}
+		afterSeq = parsed
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit-log.jsonl"`)
+	encoder := json.NewEncoder(w)
+
+	// Headers are gone once the first line is written, so a failure can only cut the export short
+	err := h.auditService.Export(r.Context(), afterSeq, func(event models.AuditEvent) error {
+		return encoder.Encode(event)
+	})
+	if err != nil {
+		log.Printf("Audit log export stopped: %v", err)
+	}
+}
+
+// VerifyAuditLog handles checking the audit log's hash chain
+func (h *AdminHandler) VerifyAuditLog(w http.ResponseWriter, r *http.Request) {
+	result, err := h.auditService.Verify(r.Context())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to verify audit log")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, result)
+}
+
+// parseAdminTarget reads the acting admin from the JWT claims and the target
+// user from the URL. It writes the error response itself when ok is false.
+func parseAdminTarget(w http.ResponseWriter, r *http.Request) (actorID, targetID uuid.UUID, ok bool) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	targetID, err = uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return claims.UserID, targetID, true
+}
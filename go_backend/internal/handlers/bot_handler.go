@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/GavinHemsada/go-backend/internal/dtos"
+	"github.com/GavinHemsada/go-backend/internal/middleware"
+	"github.com/GavinHemsada/go-backend/internal/services"
+	"github.com/GavinHemsada/go-backend/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type BotHandler struct {
+	botService *services.BotService
+}
+
+func NewBotHandler(botService *services.BotService) *BotHandler {
+	return &BotHandler{
+		botService: botService,
+	}
+}
+
+// CreateBot handles creating a bot owned by the user
+func (h *BotHandler) CreateBot(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req dtos.CreateBotDto
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	bot, err := h.botService.CreateBot(r.Context(), claims.UserID, req.Username, req.DisplayName)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, bot)
+}
+
+// GetBots handles listing the user's bots
+func (h *BotHandler) GetBots(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	bots, err := h.botService.GetBots(r.Context(), claims.UserID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve bots")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, bots)
+}
+
+// DeleteBot handles deleting one of the user's bots
+func (h *BotHandler) DeleteBot(w http.ResponseWriter, r *http.Request) {
+	claims, botID, ok := parseBotRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.botService.DeleteBot(r.Context(), claims.UserID, botID); err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Bot deleted successfully"})
+}
+
+// CreateToken handles issuing a token for one of the user's bots
+func (h *BotHandler) CreateToken(w http.ResponseWriter, r *http.Request) {
+	claims, botID, ok := parseBotRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req dtos.CreateAccessTokenDto
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	token, err := h.botService.CreateToken(r.Context(), claims.UserID, botID, req.Name, req.Scopes, req.ExpiresAt)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, token)
+}
+
+// GetTokens handles listing the tokens of one of the user's bots
+func (h *BotHandler) GetTokens(w http.ResponseWriter, r *http.Request) {
+	claims, botID, ok := parseBotRequest(w, r)
+	if !ok {
+		return
+	}
+
+	tokens, err := h.botService.GetTokens(r.Context(), claims.UserID, botID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, tokens)
+}
+
+// RevokeToken handles revoking a token of one of the user's bots
+func (h *BotHandler) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	claims, botID, ok := parseBotRequest(w, r)
+	if !ok {
+		return
+	}
+
+	tokenID, err := uuid.Parse(mux.Vars(r)["token_id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid token ID")
+		return
+	}
+
+	if err := h.botService.RevokeToken(r.Context(), claims.UserID, botID, tokenID); err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Token revoked successfully"})
+}
+
+// AddToRoom handles a room moderator adding a bot to the room
+func (h *BotHandler) AddToRoom(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	roomID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid room ID")
+		return
+	}
+
+	var req dtos.AddRoomBotDto
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := h.botService.AddToRoom(r.Context(), roomID, claims.UserID, req.BotID); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Bot added to room successfully"})
+}
+
+// RemoveFromRoom handles a room moderator removing a bot from the room
+func (h *BotHandler) RemoveFromRoom(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	vars := mux.Vars(r)
+	roomID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid room ID")
+		return
+	}
+
+	botID, err := uuid.Parse(vars["bot_id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid bot ID")
+		return
+	}
+
+	if err := h.botService.RemoveFromRoom(r.Context(), roomID, claims.UserID, botID); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Bot removed from room successfully"})
+}
+
+// parseBotRequest reads the caller's claims and the bot ID from the path
+func parseBotRequest(w http.ResponseWriter, r *http.Request) (*utils.Claims, uuid.UUID, bool) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return nil, uuid.Nil, false
+	}
+
+	botID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid bot ID")
+		return nil, uuid.Nil, false
+	}
+
+	return claims, botID, true
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/GavinHemsada/go-backend/internal/dtos"
+	"github.com/GavinHemsada/go-backend/internal/middleware"
+	"github.com/GavinHemsada/go-backend/internal/services"
+	"github.com/GavinHemsada/go-backend/pkg/utils"
+)
+
+type EmailHandler struct {
+	emailService *services.EmailService
+}
+
+func NewEmailHandler(emailService *services.EmailService) *EmailHandler {
+	return &EmailHandler{
+		emailService: emailService,
+	}
+}
+
+// SendVerification handles the user asking for another verification email
+func (h *EmailHandler) SendVerification(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := h.emailService.SendVerification(r.Context(), claims.UserID); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Verification email sent"})
+}
+
+// VerifyEmail handles a verification link being opened
+func (h *EmailHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req dtos.VerifyEmailDto
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	user, err := h.emailService.VerifyEmail(r.Context(), req.Token)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, user)
+}
+
+// ForgotPassword handles a request for a password reset email. The response
+// is the same whether or not the email belongs to an account.
+func (h *EmailHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req dtos.ForgotPasswordDto
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := h.emailService.RequestPasswordReset(r.Context(), req.Email); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "If the email belongs to an account, a reset link is on its way"})
+}
+
+// ResetPassword handles a new password being set through a reset link
+func (h *EmailHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req dtos.ResetPasswordDto
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := h.emailService.ResetPassword(r.Context(), req.Token, req.Password); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Password reset successfully"})
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/GavinHemsada/go-backend/internal/middleware"
+	"github.com/GavinHemsada/go-backend/internal/services"
+	"github.com/GavinHemsada/go-backend/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type FilterHandler struct {
+	filterService *services.FilterService
+}
+
+func NewFilterHandler(filterService *services.FilterService) *FilterHandler {
+	return &FilterHandler{
+		filterService: filterService,
+	}
+}
+
+type WordFilterRequest struct {
+	Word   string `json:"word"`
+	Action string `json:"action"` // "block", "mask" or "flag"
+}
+
+// AddWord handles adding a word to a room's filter
+func (h *FilterHandler) AddWord(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	roomID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid room ID")
+		return
+	}
+
+	var req WordFilterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	word, err := h.filterService.AddWord(r.Context(), roomID, claims.UserID, req.Word, req.Action)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, word)
+}
+
+// RemoveWord handles removing a word from a room's filter
+func (h *FilterHandler) RemoveWord(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	vars := mux.Vars(r)
+	roomID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid room ID")
+		return
+	}
+
+	if err := h.filterService.RemoveWord(r.Context(), roomID, claims.UserID, vars["word"]); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Word removed successfully"})
+}
+
+// GetWords handles listing a room's filtered words
+func (h *FilterHandler) GetWords(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	roomID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid room ID")
+		return
+	}
+
+	words, err := h.filterService.GetWords(r.Context(), roomID, claims.UserID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, words)
+}
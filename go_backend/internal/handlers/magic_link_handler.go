@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/GavinHemsada/go-backend/internal/dtos"
+	"github.com/GavinHemsada/go-backend/internal/services"
+	"github.com/GavinHemsada/go-backend/pkg/utils"
+)
+
+type MagicLinkHandler struct {
+	magicLinkService *services.MagicLinkService
+}
+
+func NewMagicLinkHandler(magicLinkService *services.MagicLinkService) *MagicLinkHandler {
+	return &MagicLinkHandler{
+		magicLinkService: magicLinkService,
+	}
+}
+
+// Request handles a request for a login link. The response is the same
+// whether or not the email belongs to an account.
+func (h *MagicLinkHandler) Request(w http.ResponseWriter, r *http.Request) {
+	var req dtos.MagicLinkRequestDto
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	resp, err := h.magicLinkService.Request(r.Context(), req.Email)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, resp)
+}
+
+// Login handles a login link being opened on the device that asked for it
+func (h *MagicLinkHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var req dtos.MagicLinkLoginDto
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	authResp, err := h.magicLinkService.Login(r.Context(), req.Token, req.DeviceToken)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, authResp)
+}
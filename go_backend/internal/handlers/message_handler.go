@@ -6,6 +6,7 @@ import (
 	"strconv"
 
 	"github.com/GavinHemsada/go-backend/internal/middleware"
+	"github.com/GavinHemsada/go-backend/internal/models"
 	"github.com/GavinHemsada/go-backend/internal/services"
 	"github.com/GavinHemsada/go-backend/pkg/utils"
 	"github.com/google/uuid"
@@ -14,11 +15,15 @@ import (
 
 type MessageHandler struct {
 	messageService *services.MessageService
+	notifier       services.RoomNotifier
 }
 
-func NewMessageHandler(messageService *services.MessageService) *MessageHandler {
+// NewMessageHandler creates a MessageHandler. Messages posted over REST are
+// sent to the room's live connections through notifier, when set.
+func NewMessageHandler(messageService *services.MessageService, notifier services.RoomNotifier) *MessageHandler {
 	return &MessageHandler{
 		messageService: messageService,
+		notifier:       notifier,
 	}
 }
 
@@ -55,6 +60,16 @@ func (h *MessageHandler) CreateMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Same event as for messages sent over the WebSocket
+	if h.notifier != nil {
+		h.notifier.NotifyRoom(roomID, models.WSMessageResponse{
+			Type:    "message",
+			Message: message,
+			UserID:  message.UserID.String(),
+			RoomID:  message.RoomID.String(),
+		})
+	}
+
 	utils.RespondWithJSON(w, http.StatusCreated, message)
 }
 
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/middleware"
+	"github.com/GavinHemsada/go-backend/internal/services"
+	"github.com/GavinHemsada/go-backend/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type ModerationHandler struct {
+	moderationService *services.ModerationService
+}
+
+func NewModerationHandler(moderationService *services.ModerationService) *ModerationHandler {
+	return &ModerationHandler{
+		moderationService: moderationService,
+	}
+}
+
+type ModerationRequest struct {
+	UserID          uuid.UUID `json:"user_id"`
+	DurationMinutes int       `json:"duration_minutes"` // Bans: 0 means permanent
+	Reason          string    `json:"reason"`
+}
+
+// KickUser handles removing a user from a room
+func (h *ModerationHandler) KickUser(w http.ResponseWriter, r *http.Request) {
+	claims, roomID, req, ok := parseModerationRequest(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.moderationService.Kick(r.Context(), roomID, claims.UserID, req.UserID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "User kicked successfully"})
+}
+
+// BanUser handles banning a user from a room
+func (h *ModerationHandler) BanUser(w http.ResponseWriter, r *http.Request) {
+	claims, roomID, req, ok := parseModerationRequest(w, r)
+	if !ok {
+		return
+	}
+
+	duration := time.Duration(req.DurationMinutes) * time.Minute
+	ban, err := h.moderationService.Ban(r.Context(), roomID, claims.UserID, req.UserID, duration, req.Reason)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, ban)
+}
+
+// UnbanUser handles lifting a user's ban
+func (h *ModerationHandler) UnbanUser(w http.ResponseWriter, r *http.Request) {
+	claims, roomID, userID, ok := parseModerationTarget(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.moderationService.Unban(r.Context(), roomID, claims.UserID, userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "User unbanned successfully"})
+}
+
+// GetBans handles listing the active bans of a room
+func (h *ModerationHandler) GetBans(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	roomID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid room ID")
+		return
+	}
+
+	bans, err := h.moderationService.GetBans(r.Context(), roomID, claims.UserID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, bans)
+}
+
+// MuteUser handles muting a user in a room
+func (h *ModerationHandler) MuteUser(w http.ResponseWriter, r *http.Request) {
+	claims, roomID, req, ok := parseModerationRequest(w, r)
+	if !ok {
+		return
+	}
+
+	duration := time.Duration(req.DurationMinutes) * time.Minute
+	mute, err := h.moderationService.Mute(r.Context(), roomID, claims.UserID, req.UserID, duration, req.Reason)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, mute)
+}
+
+// UnmuteUser handles lifting a user's mute
+func (h *ModerationHandler) UnmuteUser(w http.ResponseWriter, r *http.Request) {
+	claims, roomID, userID, ok := parseModerationTarget(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.moderationService.Unmute(r.Context(), roomID, claims.UserID, userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "User unmuted successfully"})
+}
+
+// GetMutes handles listing the active mutes of a room
+func (h *ModerationHandler) GetMutes(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	roomID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid room ID")
+		return
+	}
+
+	mutes, err := h.moderationService.GetMutes(r.Context(), roomID, claims.UserID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, mutes)
+}
+
+// parseModerationRequest reads the caller, the room ID from the URL and the
+// request body, writing an error response and returning false on failure
+func parseModerationRequest(w http.ResponseWriter, r *http.Request) (*utils.Claims, uuid.UUID, ModerationRequest, bool) {
+	var req ModerationRequest
+
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return nil, uuid.Nil, req, false
+	}
+
+	roomID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid room ID")
+		return nil, uuid.Nil, req, false
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID == uuid.Nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return nil, uuid.Nil, req, false
+	}
+
+	return claims, roomID, req, true
+}
+
+// parseModerationTarget reads the caller and the room and user IDs from the URL,
+// writing an error response and returning false on failure
+func parseModerationTarget(w http.ResponseWriter, r *http.Request) (*utils.Claims, uuid.UUID, uuid.UUID, bool) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return nil, uuid.Nil, uuid.Nil, false
+	}
+
+	vars := mux.Vars(r)
+	roomID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid room ID")
+		return nil, uuid.Nil, uuid.Nil, false
+	}
+
+	userID, err := uuid.Parse(vars["user_id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return nil, uuid.Nil, uuid.Nil, false
+	}
+
+	return claims, roomID, userID, true
+}
@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/GavinHemsada/go-backend/internal/dtos"
+	"github.com/GavinHemsada/go-backend/internal/middleware"
+	"github.com/GavinHemsada/go-backend/internal/services"
+	"github.com/GavinHemsada/go-backend/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type OAuthHandler struct {
+	oauthService *services.OAuthService
+}
+
+func NewOAuthHandler(oauthService *services.OAuthService) *OAuthHandler {
+	return &OAuthHandler{
+		oauthService: oauthService,
+	}
+}
+
+// CreateApp handles registering an OAuth2 app
+func (h *OAuthHandler) CreateApp(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req dtos.CreateOAuthAppDto
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	app, err := h.oauthService.CreateApp(r.Context(), claims.UserID, req.Name, req.RedirectURIs, req.Confidential)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, app)
+}
+
+// GetApps handles listing the user's OAuth2 apps
+func (h *OAuthHandler) GetApps(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	apps, err := h.oauthService.GetApps(r.Context(), claims.UserID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve apps")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, apps)
+}
+
+// DeleteApp handles deleting one of the user's OAuth2 apps
+func (h *OAuthHandler) DeleteApp(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	appID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid app ID")
+		return
+	}
+
+	if err := h.oauthService.DeleteApp(r.Context(), claims.UserID, appID); err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "App deleted successfully"})
+}
+
+// GetAuthorization handles the consent screen loading an authorization request
+func (h *OAuthHandler) GetAuthorization(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	query := r.URL.Query()
+	req := dtos.OAuthAuthorizeDto{
+		ResponseType:        query.Get("response_type"),
+		ClientID:            query.Get("client_id"),
+		RedirectURI:         query.Get("redirect_uri"),
+		Scope:               query.Get("scope"),
+		State:               query.Get("state"),
+		CodeChallenge:       query.Get("code_challenge"),
+		CodeChallengeMethod: query.Get("code_challenge_method"),
+	}
+
+	consent, err := h.oauthService.GetAuthorization(r.Context(), claims.UserID, req)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, consent)
+}
+
+// Authorize handles the user approving or denying an authorization request
+func (h *OAuthHandler) Authorize(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req dtos.OAuthAuthorizeDto
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	redirect, err := h.oauthService.Authorize(r.Context(), claims.UserID, req)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, redirect)
+}
+
+// Token handles apps exchanging an authorization code or a refresh token
+func (h *OAuthHandler) Token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		respondWithOAuthError(w, &services.OAuthError{Code: services.OAuthInvalidRequest, Description: "Invalid request payload"})
+		return
+	}
+	clientID, clientSecret := clientCredentials(r)
+
+	var token *dtos.OAuthTokenResponse
+	var err error
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		token, err = h.oauthService.ExchangeCode(r.Context(), clientID, clientSecret,
+			r.PostForm.Get("code"), r.PostForm.Get("redirect_uri"), r.PostForm.Get("code_verifier"))
+	case "refresh_token":
+		token, err = h.oauthService.Refresh(r.Context(), clientID, clientSecret,
+			r.PostForm.Get("refresh_token"), r.PostForm.Get("scope"))
+	default:
+		err = &services.OAuthError{Code: services.OAuthUnsupportedGrant, Description: "grant_type must be authorization_code or refresh_token"}
+	}
+	if err != nil {
+		respondWithOAuthError(w, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	utils.RespondWithJSON(w, http.StatusOK, token)
+}
+
+// Introspect handles apps asking whether one of their tokens is active
+func (h *OAuthHandler) Introspect(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		respondWithOAuthError(w, &services.OAuthError{Code: services.OAuthInvalidRequest, Description: "Invalid request payload"})
+		return
+	}
+	clientID, clientSecret := clientCredentials(r)
+
+	introspection, err := h.oauthService.Introspect(r.Context(), clientID, clientSecret, r.PostForm.Get("token"))
+	if err != nil {
+		respondWithOAuthError(w, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	utils.RespondWithJSON(w, http.StatusOK, introspection)
+}
+
+// Revoke handles apps revoking one of their tokens
+func (h *OAuthHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		respondWithOAuthError(w, &services.OAuthError{Code: services.OAuthInvalidRequest, Description: "Invalid request payload"})
+		return
+	}
+	clientID, clientSecret := clientCredentials(r)
+
+	if err := h.oauthService.Revoke(r.Context(), clientID, clientSecret, r.PostForm.Get("token")); err != nil {
+		respondWithOAuthError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetAuthorizations handles listing the apps the user authorized
+func (h *OAuthHandler) GetAuthorizations(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	consents, err := h.oauthService.GetAuthorizations(r.Context(), claims.UserID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve authorizations")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, consents)
+}
+
+// RevokeAuthorization handles the user withdrawing an app's access
+func (h *OAuthHandler) RevokeAuthorization(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	appID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid app ID")
+		return
+	}
+
+	if err := h.oauthService.RevokeAuthorization(r.Context(), claims.UserID, appID); err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Authorization revoked successfully"})
+}
+
+// CheckToken validates an OAuth2 access token, for middleware.JWTMiddleware
+func (h *OAuthHandler) CheckToken(ctx context.Context, token string) (*utils.Claims, error) {
+	return h.oauthService.Authenticate(ctx, token)
+}
+
+// clientCredentials reads the app's credentials from HTTP Basic
+// authentication, or from the form body
+func clientCredentials(r *http.Request) (string, string) {
+	if id, secret, ok := r.BasicAuth(); ok {
+		// RFC 6749 form-encodes both before Basic encoding
+		if unescaped, err := url.QueryUnescape(id); err == nil {
+			id = unescaped
+		}
+		if unescaped, err := url.QueryUnescape(secret); err == nil {
+			secret = unescaped
+		}
+		return id, secret
+	}
+	return r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+}
+
+// respondWithOAuthError writes an error in the RFC 6749 format
+func respondWithOAuthError(w http.ResponseWriter, err error) {
+	var oauthErr *services.OAuthError
+	if !errors.As(err, &oauthErr) {
+		utils.RespondWithJSON(w, http.StatusInternalServerError, dtos.OAuthErrorResponse{Error: "server_error"})
+		return
+	}
+
+	status := http.StatusBadRequest
+	if oauthErr.Code == services.OAuthInvalidClient {
+		w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+		status = http.StatusUnauthorized
+	}
+	utils.RespondWithJSON(w, status, dtos.OAuthErrorResponse{Error: oauthErr.Code, ErrorDescription: oauthErr.Description})
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/GavinHemsada/go-backend/internal/services"
+	"github.com/GavinHemsada/go-backend/pkg/utils"
+)
+
+type OIDCHandler struct {
+	oidcService *services.OIDCService
+}
+
+func NewOIDCHandler(oidcService *services.OIDCService) *OIDCHandler {
+	return &OIDCHandler{
+		oidcService: oidcService,
+	}
+}
+
+// BeginLogin handles sending the user to the identity provider
+func (h *OIDCHandler) BeginLogin(w http.ResponseWriter, r *http.Request) {
+	authURL, err := h.oidcService.BeginLogin(r.Context())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// Callback handles the user coming back from the identity provider
+func (h *OIDCHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	authResp, err := h.oidcService.FinishLogin(r.Context(), query.Get("state"), query.Get("code"), query.Get("error"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, authResp)
+}
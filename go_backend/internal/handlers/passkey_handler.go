@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/GavinHemsada/go-backend/internal/dtos"
+	"github.com/GavinHemsada/go-backend/internal/middleware"
+	"github.com/GavinHemsada/go-backend/internal/services"
+	"github.com/GavinHemsada/go-backend/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type PasskeyHandler struct {
+	passkeyService *services.PasskeyService
+}
+
+func NewPasskeyHandler(passkeyService *services.PasskeyService) *PasskeyHandler {
+	return &PasskeyHandler{
+		passkeyService: passkeyService,
+	}
+}
+
+// BeginLogin handles starting a passwordless login
+func (h *PasskeyHandler) BeginLogin(w http.ResponseWriter, r *http.Request) {
+	ceremony, err := h.passkeyService.BeginLogin(r.Context())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to start passkey login")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, ceremony)
+}
+
+// FinishLogin handles logging in with the authenticator's response
+func (h *PasskeyHandler) FinishLogin(w http.ResponseWriter, r *http.Request) {
+	var req dtos.FinishPasskeyLoginDto
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	authResp, err := h.passkeyService.FinishLogin(r.Context(), req.SessionID, req.Credential)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, authResp)
+}
+
+// BeginRegistration handles starting to register a passkey
+func (h *PasskeyHandler) BeginRegistration(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	ceremony, err := h.passkeyService.BeginRegistration(r.Context(), claims.UserID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, ceremony)
+}
+
+// FinishRegistration handles storing a passkey from the authenticator's response
+func (h *PasskeyHandler) FinishRegistration(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req dtos.FinishPasskeyRegistrationDto
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	passkey, err := h.passkeyService.FinishRegistration(r.Context(), claims.UserID, req.SessionID, req.Name, req.Credential)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, passkey)
+}
+
+// GetPasskeys handles listing the user's passkeys
+func (h *PasskeyHandler) GetPasskeys(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	passkeys, err := h.passkeyService.GetPasskeys(r.Context(), claims.UserID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve passkeys")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, passkeys)
+}
+
+// DeletePasskey handles removing one of the user's passkeys
+func (h *PasskeyHandler) DeletePasskey(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	passkeyID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid passkey ID")
+		return
+	}
+
+	if err := h.passkeyService.DeletePasskey(r.Context(), claims.UserID, passkeyID); err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Passkey deleted successfully"})
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/GavinHemsada/go-backend/internal/dtos"
+	"github.com/GavinHemsada/go-backend/internal/middleware"
+	"github.com/GavinHemsada/go-backend/internal/services"
+	"github.com/GavinHemsada/go-backend/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// Room for the multipart headers around an avatar upload
+const avatarFormOverhead = 64 << 10
+
+type ProfileHandler struct {
+	profileService *services.ProfileService
+	userService    *services.UserService
+}
+
+func NewProfileHandler(profileService *services.ProfileService, userService *services.UserService) *ProfileHandler {
+	return &ProfileHandler{
+		profileService: profileService,
+		userService:    userService,
+	}
+}
+
+// GetProfile handles getting the current user's own account
+func (h *ProfileHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	user, err := h.userService.GetByID(r.Context(), claims.UserID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, user)
+}
+
+// UpdateProfile handles the current user editing their profile
+func (h *ProfileHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req dtos.UpdateProfileDto
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	user, err := h.profileService.UpdateProfile(r.Context(), claims.UserID, req)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, user)
+}
+
+// UploadAvatar handles the current user uploading an avatar as the "avatar"
+// field of a multipart form
+func (h *ProfileHandler) UploadAvatar(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, services.MaxAvatarSize+avatarFormOverhead)
+	form, err := r.MultipartReader()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Expected a multipart form with an avatar field")
+		return
+	}
+
+	var data []byte
+	for {
+		part, err := form.NextPart()
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Expected a multipart form with an avatar field")
+			return
+		}
+		if part.FormName() != "avatar" {
+			continue
+		}
+		// One byte over the limit is enough to refuse the upload
+		data, err = io.ReadAll(io.LimitReader(part, services.MaxAvatarSize+1))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusRequestEntityTooLarge, "avatar must be at most 1 MiB")
+			return
+		}
+		break
+	}
+
+	user, err := h.profileService.SetAvatar(r.Context(), claims.UserID, data)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, user)
+}
+
+// DeleteAvatar handles the current user removing their avatar
+func (h *ProfileHandler) DeleteAvatar(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	user, err := h.profileService.DeleteAvatar(r.Context(), claims.UserID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, user)
+}
+
+// GetAvatar serves an uploaded avatar. A new upload gets a new ID, so the
+// image can be cached for good.
+func (h *ProfileHandler) GetAvatar(w http.ResponseWriter, r *http.Request) {
+	avatarID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid avatar ID")
+		return
+	}
+
+	avatar, err := h.profileService.GetAvatar(r.Context(), avatarID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", avatar.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(avatar.Data)))
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'")
+	w.WriteHeader(http.StatusOK)
+	w.Write(avatar.Data)
+}
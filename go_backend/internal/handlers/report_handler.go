@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/middleware"
+	"github.com/GavinHemsada/go-backend/internal/services"
+	"github.com/GavinHemsada/go-backend/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type ReportHandler struct {
+	reportService *services.ReportService
+}
+
+func NewReportHandler(reportService *services.ReportService) *ReportHandler {
+	return &ReportHandler{
+		reportService: reportService,
+	}
+}
+
+type CreateReportRequest struct {
+	RoomID   uuid.UUID `json:"room_id"` // User reports only
+	Category string    `json:"category"`
+	Details  string    `json:"details"`
+}
+
+type ResolveReportRequest struct {
+	Action          string `json:"action"`
+	DurationMinutes int    `json:"duration_minutes"` // Ban and mute only; a 0 minute ban is permanent
+	Note            string `json:"note"`
+}
+
+type DismissReportRequest struct {
+	Note string `json:"note"`
+}
+
+// ReportMessage handles reporting a message
+func (h *ReportHandler) ReportMessage(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	vars := mux.Vars(r)
+	roomID, err := uuid.Parse(vars["room_id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid room ID")
+		return
+	}
+
+	messageID, err := uuid.Parse(vars["message_id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid message ID")
+		return
+	}
+
+	var req CreateReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	report, err := h.reportService.ReportMessage(r.Context(), roomID, messageID, claims.UserID, req.Category, req.Details)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, report)
+}
+
+// ReportUser handles reporting a user in a room
+func (h *ReportHandler) ReportUser(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	userID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var req CreateReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RoomID == uuid.Nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	report, err := h.reportService.ReportUser(r.Context(), req.RoomID, userID, claims.UserID, req.Category, req.Details)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, report)
+}
+
+// GetReports handles listing a room's moderation queue
+func (h *ReportHandler) GetReports(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	roomID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid room ID")
+		return
+	}
+
+	// Get filter and pagination parameters
+	query := r.URL.Query()
+	status := query.Get("status")
+	limit := 50 // default
+	offset := 0 // default
+
+	if limitStr := query.Get("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil {
+			limit = parsedLimit
+		}
+	}
+
+	if offsetStr := query.Get("offset"); offsetStr != "" {
+		if parsedOffset, err := strconv.Atoi(offsetStr); err == nil {
+			offset = parsedOffset
+		}
+	}
+
+	reports, err := h.reportService.GetQueue(r.Context(), roomID, claims.UserID, status, limit, offset)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, reports)
+}
+
+// GetAllReports handles listing the reports of every room for global admins
+func (h *ReportHandler) GetAllReports(w http.ResponseWriter, r *http.Request) {
+	// Get filter and pagination parameters
+	query := r.URL.Query()
+	status := query.Get("status")
+	limit := 50 // default
+	offset := 0 // default
+
+	if limitStr := query.Get("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil {
+			limit = parsedLimit
+		}
+	}
+
+	if offsetStr := query.Get("offset"); offsetStr != "" {
+		if parsedOffset, err := strconv.Atoi(offsetStr); err == nil {
+			offset = parsedOffset
+		}
+	}
+
+	reports, err := h.reportService.GetAllQueues(r.Context(), status, limit, offset)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, reports)
+}
+
+// GetReport handles getting a report with its audit trail
+func (h *ReportHandler) GetReport(w http.ResponseWriter, r *http.Request) {
+	claims, roomID, reportID, ok := parseReportTarget(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := h.reportService.GetReport(r.Context(), roomID, reportID, claims.UserID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, report)
+}
+
+// ClaimReport handles a moderator taking a report from the queue
+func (h *ReportHandler) ClaimReport(w http.ResponseWriter, r *http.Request) {
+	claims, roomID, reportID, ok := parseReportTarget(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := h.reportService.Claim(r.Context(), roomID, reportID, claims.UserID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, report)
+}
+
+// ResolveReport handles acting on a report and closing it
+func (h *ReportHandler) ResolveReport(w http.ResponseWriter, r *http.Request) {
+	claims, roomID, reportID, ok := parseReportTarget(w, r)
+	if !ok {
+		return
+	}
+
+	var req ResolveReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	duration := time.Duration(req.DurationMinutes) * time.Minute
+	report, err := h.reportService.Resolve(r.Context(), roomID, reportID, claims.UserID, req.Action, duration, req.Note)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, report)
+}
+
+// DismissReport handles closing a report without action
+func (h *ReportHandler) DismissReport(w http.ResponseWriter, r *http.Request) {
+	claims, roomID, reportID, ok := parseReportTarget(w, r)
+	if !ok {
+		return
+	}
+
+	// The note is optional, so an empty body is accepted
+	var req DismissReportRequest
+	json.NewDecoder(r.Body).Decode(&req)
+
+	report, err := h.reportService.Dismiss(r.Context(), roomID, reportID, claims.UserID, req.Note)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, report)
+}
+
+// parseReportTarget reads the caller and the room and report IDs from the URL,
+// writing an error response and returning false on failure
+func parseReportTarget(w http.ResponseWriter, r *http.Request) (*utils.Claims, uuid.UUID, uuid.UUID, bool) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return nil, uuid.Nil, uuid.Nil, false
+	}
+
+	vars := mux.Vars(r)
+	roomID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid room ID")
+		return nil, uuid.Nil, uuid.Nil, false
+	}
+
+	reportID, err := uuid.Parse(vars["report_id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid report ID")
+		return nil, uuid.Nil, uuid.Nil, false
+	}
+
+	return claims, roomID, reportID, true
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/GavinHemsada/go-backend/internal/services"
+	"github.com/GavinHemsada/go-backend/pkg/utils"
+)
+
+type SAMLHandler struct {
+	samlService *services.SAMLService
+}
+
+func NewSAMLHandler(samlService *services.SAMLService) *SAMLHandler {
+	return &SAMLHandler{
+		samlService: samlService,
+	}
+}
+
+// Metadata handles serving the service provider metadata
+func (h *SAMLHandler) Metadata(w http.ResponseWriter, r *http.Request) {
+	metadata, err := h.samlService.Metadata()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to build SAML metadata")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/samlmetadata+xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(metadata)
+}
+
+// BeginLogin handles sending the user to the identity provider
+func (h *SAMLHandler) BeginLogin(w http.ResponseWriter, r *http.Request) {
+	authURL, err := h.samlService.BeginLogin(r.Context())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// ACS handles the identity provider posting its response back
+func (h *SAMLHandler) ACS(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	authResp, err := h.samlService.FinishLogin(r.Context(), r.PostForm.Get("SAMLResponse"), r.PostForm.Get("RelayState"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, authResp)
+}
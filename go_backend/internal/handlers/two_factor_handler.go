@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/GavinHemsada/go-backend/internal/dtos"
+	"github.com/GavinHemsada/go-backend/internal/middleware"
+	"github.com/GavinHemsada/go-backend/internal/services"
+	"github.com/GavinHemsada/go-backend/pkg/utils"
+)
+
+type TwoFactorHandler struct {
+	twoFactorService *services.TwoFactorService
+}
+
+func NewTwoFactorHandler(twoFactorService *services.TwoFactorService) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		twoFactorService: twoFactorService,
+	}
+}
+
+// LoginVerify handles finishing a login with a TOTP or recovery code
+func (h *TwoFactorHandler) LoginVerify(w http.ResponseWriter, r *http.Request) {
+	var req dtos.TwoFactorLoginDto
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	authResp, err := h.twoFactorService.CompleteLogin(r.Context(), req.ChallengeToken, req.Code, req.RecoveryCode)
+	if respondLocked(w, err) {
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, authResp)
+}
+
+// LoginSetup handles starting enrollment for a login the policy holds back
+// until the account has a second factor
+func (h *TwoFactorHandler) LoginSetup(w http.ResponseWriter, r *http.Request) {
+	var req dtos.TwoFactorLoginDto
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	setup, err := h.twoFactorService.StartLoginSetup(r.Context(), req.ChallengeToken)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, setup)
+}
+
+// LoginEnable handles confirming enrollment at login, which finishes the login
+func (h *TwoFactorHandler) LoginEnable(w http.ResponseWriter, r *http.Request) {
+	var req dtos.TwoFactorLoginDto
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	authResp, err := h.twoFactorService.CompleteLoginSetup(r.Context(), req.ChallengeToken, req.Code)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, authResp)
+}
+
+// GetStatus handles reporting the user's two-factor status
+func (h *TwoFactorHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	status, err := h.twoFactorService.GetStatus(r.Context(), claims.UserID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve two-factor status")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, status)
+}
+
+// Start handles generating a new TOTP secret for the user to enroll
+func (h *TwoFactorHandler) Start(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	setup, err := h.twoFactorService.Start(r.Context(), claims.UserID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, setup)
+}
+
+// Enable handles confirming enrollment with a first code
+func (h *TwoFactorHandler) Enable(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req dtos.TwoFactorCodeDto
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	codes, err := h.twoFactorService.Enable(r.Context(), claims.UserID, req.Code)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, dtos.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// Disable handles turning two-factor authentication off
+func (h *TwoFactorHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req dtos.DisableTwoFactorDto
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	err = h.twoFactorService.Disable(r.Context(), claims.UserID, req.Password, req.Code, req.RecoveryCode)
+	if respondLocked(w, err) {
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Two-factor authentication disabled successfully"})
+}
+
+// RegenerateRecoveryCodes handles replacing the user's recovery codes
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req dtos.TwoFactorCodeDto
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	codes, err := h.twoFactorService.RegenerateRecoveryCodes(r.Context(), claims.UserID, req.Code, req.RecoveryCode)
+	if respondLocked(w, err) {
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, dtos.RecoveryCodesResponse{RecoveryCodes: codes})
+}
@@ -1,9 +1,15 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 
+	"github.com/GavinHemsada/go-backend/internal/loginguard"
+	"github.com/GavinHemsada/go-backend/internal/middleware"
 	"github.com/GavinHemsada/go-backend/internal/services"
 	"github.com/GavinHemsada/go-backend/pkg/utils"
 	"github.com/GavinHemsada/go-backend/internal/dtos"
@@ -12,12 +18,14 @@ import (
 )
 
 type UserHandler struct {
-	userService *services.UserService
+	userService    *services.UserService
+	privacyService *services.PrivacyService
 }
 
-func NewUserHandler(userService *services.UserService) *UserHandler {
+func NewUserHandler(userService *services.UserService, privacyService *services.PrivacyService) *UserHandler {
 	return &UserHandler{
-		userService: userService,
+		userService:    userService,
+		privacyService: privacyService,
 	}
 }
 
@@ -47,6 +55,9 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 	}
 
 	authResp, err := h.userService.Login(r.Context(), req.Identifier, req.Password)
+	if respondLocked(w, err) {
+		return
+	}
 	if err != nil {
 		utils.RespondWithError(w, http.StatusUnauthorized, err.Error())
 		return
@@ -55,8 +66,28 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 	utils.RespondWithJSON(w, http.StatusOK, authResp)
 }
 
-// GetUserByID handles getting a user by ID
+// respondLocked answers 429 with a Retry-After header when err is a
+// *loginguard.LockedError, reporting whether it did
+func respondLocked(w http.ResponseWriter, err error) bool {
+	var locked *loginguard.LockedError
+	if !errors.As(err, &locked) {
+		return false
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+	utils.RespondWithError(w, http.StatusTooManyRequests, err.Error())
+	return true
+}
+
+// GetUserByID handles getting a user by ID. The user themselves and admins
+// get the full record, everyone else the public view.
 func (h *UserHandler) GetUserByID(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
 	vars := mux.Vars(r)
 	userID, err := uuid.Parse(vars["id"])
 	if err != nil {
@@ -64,7 +95,22 @@ func (h *UserHandler) GetUserByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := h.userService.GetByID(r.Context(), userID)
+	full, err := h.privacyService.FullAccess(r.Context(), claims.UserID, userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if full {
+		user, err := h.userService.GetByID(r.Context(), userID)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		utils.RespondWithJSON(w, http.StatusOK, user)
+		return
+	}
+
+	user, err := h.privacyService.GetUser(r.Context(), claims.UserID, userID)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusNotFound, err.Error())
 		return
@@ -73,14 +119,134 @@ func (h *UserHandler) GetUserByID(w http.ResponseWriter, r *http.Request) {
 	utils.RespondWithJSON(w, http.StatusOK, user)
 }
 
-// GetAllUsers handles getting all users
+// GetAllUsers handles searching the user directory
 func (h *UserHandler) GetAllUsers(w http.ResponseWriter, r *http.Request) {
-	users, err := h.userService.GetAll(r.Context())
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get pagination parameters
+	query := r.URL.Query()
+	limit := 50 // default
+	offset := 0 // default
+
+	if limitStr := query.Get("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil {
+			limit = parsedLimit
+		}
+	}
+
+	if offsetStr := query.Get("offset"); offsetStr != "" {
+		if parsedOffset, err := strconv.Atoi(offsetStr); err == nil {
+			offset = parsedOffset
+		}
+	}
+
+	page, err := h.privacyService.Directory(r.Context(), claims.UserID, query.Get("q"), limit, offset)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, page)
+}
+
+// GetPrivacy handles getting the current user's privacy settings
+func (h *UserHandler) GetPrivacy(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	settings, err := h.privacyService.GetSettings(r.Context(), claims.UserID)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	utils.RespondWithJSON(w, http.StatusOK, users)
+	utils.RespondWithJSON(w, http.StatusOK, settings)
+}
+
+// UpdatePrivacy handles the current user changing their privacy settings
+func (h *UserHandler) UpdatePrivacy(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req dtos.UpdatePrivacyDto
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	settings, err := h.privacyService.UpdateSettings(r.Context(), claims.UserID, req)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, settings)
+}
+
+// Deactivate handles a user closing their own account
+func (h *UserHandler) Deactivate(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req dtos.DeactivateAccountDto
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := h.userService.Deactivate(r.Context(), claims, req.Password); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Account deactivated successfully"})
+}
+
+// ChangePassword handles the user changing their password
+func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req dtos.ChangePasswordDto
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	err = h.userService.ChangePassword(r.Context(), claims.UserID, req.CurrentPassword, req.NewPassword)
+	if respondLocked(w, err) {
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Password changed successfully"})
 }
 
+// CheckAccount reports why a user may no longer use their token, for
+// middleware.JWTMiddleware. Users who may are recorded as seen.
+func (h *UserHandler) CheckAccount(ctx context.Context, claims *utils.Claims) error {
+	if err := h.userService.CheckAccount(ctx, claims); err != nil {
+		return err
+	}
+	h.userService.Seen(ctx, claims.UserID)
+	return nil
+}
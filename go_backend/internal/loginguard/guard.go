@@ -0,0 +1,195 @@
+// Package loginguard slows down and locks out repeated failed logins, per
+// account and per client IP.
+package loginguard
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Config sets the limits of a Guard. A zero limit disables that check.
+type Config struct {
+	MaxFailures   int           // failures that lock an account
+	Lockout       time.Duration // how long an account stays locked
+	IPMaxFailures int           // failures that lock a client IP, over all accounts
+	IPLockout     time.Duration // how long a client IP stays locked
+	Window        time.Duration // failures are forgotten this long after the last one
+	Delay         time.Duration // wait after the first failure, doubling with each further one
+	MaxDelay      time.Duration // cap on the wait between attempts
+}
+
+// Lockout kinds
+const (
+	LockoutAccount = "account"
+	LockoutIP      = "ip"
+)
+
+// Lockout describes a lock placed by a failed attempt
+type Lockout struct {
+	Kind  string // LockoutAccount or LockoutIP
+	Until time.Time
+}
+
+// LockedError refuses an attempt before its password is checked. It reads
+// the same for every account, existing or not.
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return "too many failed login attempts, try again later"
+}
+
+// UserKey names an existing account. Every identifier of the account shares it.
+func UserKey(id uuid.UUID) string {
+	return "user:" + id.String()
+}
+
+// SecondFactorKey names the second login step of an existing account. Its
+// failures are kept apart from password failures, which a correct password clears.
+func SecondFactorKey(id uuid.UUID) string {
+	return "2fa:" + id.String()
+}
+
+// IdentifierKey names the account an unknown identifier would belong to, so
+// unknown identifiers are throttled the same way as existing accounts
+func IdentifierKey(identifier string) string {
+	return "name:" + strings.ToLower(strings.TrimSpace(identifier))
+}
+
+// Guard admits or refuses login attempts. Store errors are logged and the
+// attempt is let through, so an outage cannot lock everyone out.
+type Guard struct {
+	cfg   Config
+	store Store
+}
+
+func NewGuard(cfg Config, store Store) *Guard {
+	return &Guard{cfg: cfg, store: store}
+}
+
+// Attempt is a login attempt admitted by Begin. Report its outcome with
+// Succeeded or Failed.
+type Attempt struct {
+	guard    *Guard
+	account  string
+	ip       string // empty when the client IP is unknown
+	failures int    // account failures, counting this attempt
+}
+
+// Begin admits an attempt on the account named by accountKey from ip, or
+// returns a *LockedError when either is locked or still has to wait after
+// its last failure. The attempt counts as a failure of the account until
+// Succeeded is called, so concurrent guesses cannot slip past the limit.
+func (g *Guard) Begin(ctx context.Context, accountKey, ip string) (*Attempt, error) {
+	now := time.Now()
+	attempt := &Attempt{guard: g, account: LockoutAccount + ":" + accountKey}
+	if ip != "" {
+		attempt.ip = LockoutIP + ":" + ip
+	}
+
+	if attempt.ip != "" && g.cfg.IPMaxFailures > 0 {
+		record, err := g.store.Get(ctx, attempt.ip)
+		if err != nil {
+			log.Printf("Error reading login attempts of %s: %v", ip, err)
+		} else if record.LockedUntil.After(now) {
+			return nil, &LockedError{RetryAfter: record.LockedUntil.Sub(now)}
+		}
+	}
+
+	record, err := g.store.Get(ctx, attempt.account)
+	if err != nil {
+		log.Printf("Error reading login attempts: %v", err)
+	} else {
+		if record.LockedUntil.After(now) {
+			return nil, &LockedError{RetryAfter: record.LockedUntil.Sub(now)}
+		}
+		if next := record.LastFailure.Add(g.delay(record.Failures)); next.After(now) {
+			return nil, &LockedError{RetryAfter: next.Sub(now)}
+		}
+	}
+
+	record, err = g.store.Fail(ctx, attempt.account, now, g.cfg.Window)
+	if err != nil {
+		log.Printf("Error counting login attempt: %v", err)
+		return attempt, nil
+	}
+	attempt.failures = record.Failures
+
+	// Concurrent attempts beyond the limit wait for the lock the last admitted one places
+	if g.cfg.MaxFailures > 0 && record.Failures > g.cfg.MaxFailures {
+		return nil, &LockedError{RetryAfter: g.cfg.Lockout}
+	}
+	return attempt, nil
+}
+
+// Succeeded clears the account's failures. The client IP's failures stay,
+// so logging into one account does not reset guesses at others.
+func (a *Attempt) Succeeded(ctx context.Context) {
+	if err := a.guard.store.Reset(ctx, a.account); err != nil {
+		log.Printf("Error clearing login attempts: %v", err)
+	}
+}
+
+// Failed counts the failure against the client IP and locks the account or
+// IP once it reaches its limit, returning the locks placed
+func (a *Attempt) Failed(ctx context.Context) []Lockout {
+	g := a.guard
+	now := time.Now()
+	var lockouts []Lockout
+
+	if g.cfg.MaxFailures > 0 && a.failures >= g.cfg.MaxFailures {
+		until := now.Add(g.cfg.Lockout)
+		if err := g.store.Lock(ctx, a.account, until); err != nil {
+			log.Printf("Error locking account after failed logins: %v", err)
+		} else {
+			lockouts = append(lockouts, Lockout{Kind: LockoutAccount, Until: until})
+		}
+	}
+
+	if a.ip != "" && g.cfg.IPMaxFailures > 0 {
+		record, err := g.store.Fail(ctx, a.ip, now, g.cfg.Window)
+		if err != nil {
+			log.Printf("Error counting failed login: %v", err)
+		} else if record.Failures >= g.cfg.IPMaxFailures {
+			until := now.Add(g.cfg.IPLockout)
+			if err := g.store.Lock(ctx, a.ip, until); err != nil {
+				log.Printf("Error locking client IP after failed logins: %v", err)
+			} else {
+				lockouts = append(lockouts, Lockout{Kind: LockoutIP, Until: until})
+			}
+		}
+	}
+
+	return lockouts
+}
+
+// UnlockAccount clears the lock and failures of the account named by accountKey
+func (g *Guard) UnlockAccount(ctx context.Context, accountKey string) error {
+	return g.store.Reset(ctx, LockoutAccount+":"+accountKey)
+}
+
+// UnlockIP clears the lock and failures of a client IP
+func (g *Guard) UnlockIP(ctx context.Context, ip string) error {
+	return g.store.Reset(ctx, LockoutIP+":"+ip)
+}
+
+// Doublings of Config.Delay after which the wait stops growing.
+const maxDelayDoublings = 20
+
+// delay returns how long to wait after the last of failures before trying again
+func (g *Guard) delay(failures int) time.Duration {
+	if failures == 0 || g.cfg.Delay <= 0 {
+		return 0
+	}
+
+	delay := g.cfg.Delay << min(failures-1, maxDelayDoublings)
+	if g.cfg.MaxDelay > 0 && delay > g.cfg.MaxDelay {
+		return g.cfg.MaxDelay
+	}
+	return delay
+}
@@ -0,0 +1,201 @@
+package loginguard
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"testing"
+	"time"
+)
+
+func quietLogs(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+}
+
+// fail makes a failed attempt on the account from ip, failing the test if
+// the attempt is refused
+func fail(t *testing.T, g *Guard, account, ip string) []Lockout {
+	t.Helper()
+	ctx := context.Background()
+	attempt, err := g.Begin(ctx, account, ip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return attempt.Failed(ctx)
+}
+
+// locked returns how long the guard makes the account wait, or 0 if it admits an attempt
+func locked(t *testing.T, g *Guard, account, ip string) time.Duration {
+	t.Helper()
+	attempt, err := g.Begin(context.Background(), account, ip)
+	var lockedErr *LockedError
+	if errors.As(err, &lockedErr) {
+		return lockedErr.RetryAfter
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	attempt.Succeeded(context.Background())
+	return 0
+}
+
+func TestGuardLocksAccountAtThreshold(t *testing.T) {
+	g := NewGuard(Config{MaxFailures: 3, Lockout: time.Hour, Window: time.Hour}, NewMemoryStore())
+
+	for i := 1; i < 3; i++ {
+		if lockouts := fail(t, g, "alice", ""); len(lockouts) != 0 {
+			t.Fatalf("failure %d placed %+v", i, lockouts)
+		}
+	}
+	lockouts := fail(t, g, "alice", "")
+	if len(lockouts) != 1 || lockouts[0].Kind != LockoutAccount {
+		t.Fatalf("got %+v, want the account locked on the third failure", lockouts)
+	}
+
+	if wait := locked(t, g, "alice", ""); wait <= 59*time.Minute || wait > time.Hour {
+		t.Errorf("got a wait of %v, want about an hour", wait)
+	}
+	if wait := locked(t, g, "bob", ""); wait != 0 {
+		t.Errorf("another account has to wait %v", wait)
+	}
+}
+
+// A success before the threshold starts the count over
+func TestGuardSuccessClearsFailures(t *testing.T) {
+	g := NewGuard(Config{MaxFailures: 2, Lockout: time.Hour, Window: time.Hour}, NewMemoryStore())
+
+	fail(t, g, "alice", "")
+	if wait := locked(t, g, "alice", ""); wait != 0 {
+		t.Fatalf("got a wait of %v after one failure", wait)
+	}
+	if lockouts := fail(t, g, "alice", ""); len(lockouts) != 0 {
+		t.Errorf("got %+v, want the count started over", lockouts)
+	}
+}
+
+func TestGuardDelayDoubles(t *testing.T) {
+	store := NewMemoryStore()
+	g := NewGuard(Config{Window: time.Hour, Delay: time.Minute, MaxDelay: 5 * time.Minute}, store)
+	ctx := context.Background()
+
+	if wait := locked(t, g, "alice", ""); wait != 0 {
+		t.Fatalf("got a wait of %v before any failure", wait)
+	}
+
+	// Failures made long enough ago for their delay to be over
+	past := time.Now().Add(-10 * time.Minute)
+	for failures, want := range map[int]time.Duration{1: 2 * time.Minute, 2: 4 * time.Minute, 3: 5 * time.Minute, 10: 5 * time.Minute} {
+		store.Reset(ctx, LockoutAccount+":alice")
+		for i := 0; i < failures; i++ {
+			store.Fail(ctx, LockoutAccount+":alice", past, time.Hour)
+		}
+
+		// The attempt is admitted, and the one after it waits twice as long as the last
+		attempt, err := g.Begin(ctx, "alice", "")
+		if err != nil {
+			t.Fatalf("after %d failures: %v", failures, err)
+		}
+		attempt.Failed(ctx)
+
+		wait := locked(t, g, "alice", "")
+		if wait <= want-time.Second || wait > want {
+			t.Errorf("after %d failures: got a wait of %v, want %v", failures+1, wait, want)
+		}
+	}
+}
+
+func TestGuardLocksIPOverAccounts(t *testing.T) {
+	g := NewGuard(Config{IPMaxFailures: 3, IPLockout: time.Hour, Window: time.Hour}, NewMemoryStore())
+
+	fail(t, g, "alice", "192.0.2.1")
+	fail(t, g, "bob", "192.0.2.1")
+	lockouts := fail(t, g, "carol", "192.0.2.1")
+	if len(lockouts) != 1 || lockouts[0].Kind != LockoutIP {
+		t.Fatalf("got %+v, want the IP locked on the third failure", lockouts)
+	}
+
+	if wait := locked(t, g, "dave", "192.0.2.1"); wait == 0 {
+		t.Error("the locked IP tried another account")
+	}
+	if wait := locked(t, g, "dave", "192.0.2.2"); wait != 0 {
+		t.Errorf("another IP has to wait %v", wait)
+	}
+
+	// A success does not clear the IP's failures
+	if err := g.UnlockIP(context.Background(), "192.0.2.1"); err != nil {
+		t.Fatal(err)
+	}
+	fail(t, g, "alice", "192.0.2.1")
+	locked(t, g, "bob", "192.0.2.1")
+	fail(t, g, "carol", "192.0.2.1")
+	if lockouts := fail(t, g, "dave", "192.0.2.1"); len(lockouts) != 1 {
+		t.Errorf("got %+v, want the IP locked again despite the success", lockouts)
+	}
+}
+
+func TestGuardUnlock(t *testing.T) {
+	g := NewGuard(Config{MaxFailures: 1, Lockout: time.Hour, IPMaxFailures: 1, IPLockout: time.Hour, Window: time.Hour}, NewMemoryStore())
+	ctx := context.Background()
+
+	fail(t, g, "alice", "192.0.2.1")
+	if err := g.UnlockAccount(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if wait := locked(t, g, "alice", ""); wait != 0 {
+		t.Errorf("got a wait of %v after the account was unlocked", wait)
+	}
+	if wait := locked(t, g, "alice", "192.0.2.1"); wait == 0 {
+		t.Error("unlocking the account unlocked the IP")
+	}
+
+	if err := g.UnlockIP(ctx, "192.0.2.1"); err != nil {
+		t.Fatal(err)
+	}
+	if wait := locked(t, g, "alice", "192.0.2.1"); wait != 0 {
+		t.Errorf("got a wait of %v after the IP was unlocked", wait)
+	}
+}
+
+func TestGuardLockExpires(t *testing.T) {
+	g := NewGuard(Config{MaxFailures: 1, Lockout: 50 * time.Millisecond, Window: time.Hour}, NewMemoryStore())
+
+	fail(t, g, "alice", "")
+	if wait := locked(t, g, "alice", ""); wait == 0 {
+		t.Fatal("the account was not locked")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if wait := locked(t, g, "alice", ""); wait != 0 {
+		t.Errorf("got a wait of %v after the lock expired", wait)
+	}
+}
+
+// Begin counts the attempt before the password is checked, so guesses made
+// at once cannot all get in before the first failure is reported
+func TestGuardCountsConcurrentAttempts(t *testing.T) {
+	g := NewGuard(Config{MaxFailures: 2, Lockout: time.Hour, Window: time.Hour}, NewMemoryStore())
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := g.Begin(ctx, "alice", ""); err != nil {
+			t.Fatalf("attempt %d: %v", i+1, err)
+		}
+	}
+	if _, err := g.Begin(ctx, "alice", ""); err == nil {
+		t.Error("a third attempt was admitted while two were in progress")
+	}
+}
+
+// With a broken store, attempts are let through rather than refused
+func TestGuardFailsOpen(t *testing.T) {
+	quietLogs(t)
+	g := NewGuard(Config{MaxFailures: 1, Lockout: time.Hour, IPMaxFailures: 1, IPLockout: time.Hour, Window: time.Hour}, brokenStore{})
+
+	for i := 0; i < 3; i++ {
+		if lockouts := fail(t, g, "alice", "192.0.2.1"); len(lockouts) != 0 {
+			t.Fatalf("got %+v from a broken store", lockouts)
+		}
+	}
+}
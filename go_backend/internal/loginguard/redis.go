@@ -0,0 +1,75 @@
+package loginguard
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Prefix of the Redis keys holding records.
+const redisKeyPrefix = "login:"
+
+// RedisStore keeps records in Redis hashes shared by every server instance
+type RedisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (s *RedisStore) Get(ctx context.Context, key string) (Record, error) {
+	fields, err := s.client.HGetAll(ctx, redisKeyPrefix+key).Result()
+	if err != nil {
+		return Record{}, err
+	}
+	return parseRedisRecord(fields), nil
+}
+
+func (s *RedisStore) Fail(ctx context.Context, key string, now time.Time, ttl time.Duration) (Record, error) {
+	key = redisKeyPrefix + key
+
+	var fields *redis.MapStringStringCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HIncrBy(ctx, key, "failures", 1)
+		pipe.HSet(ctx, key, "last_failure", now.UnixMilli())
+		pipe.PExpire(ctx, key, ttl)
+		fields = pipe.HGetAll(ctx, key)
+		return nil
+	})
+	if err != nil {
+		return Record{}, err
+	}
+	return parseRedisRecord(fields.Val()), nil
+}
+
+func (s *RedisStore) Lock(ctx context.Context, key string, until time.Time) error {
+	key = redisKeyPrefix + key
+
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		pipe.HSet(ctx, key, "locked_until", until.UnixMilli())
+		pipe.PExpireAt(ctx, key, until)
+		return nil
+	})
+	return err
+}
+
+func (s *RedisStore) Reset(ctx context.Context, key string) error {
+	return s.client.Del(ctx, redisKeyPrefix+key).Err()
+}
+
+// parseRedisRecord reads a record hash, treating missing or malformed fields as zero
+func parseRedisRecord(fields map[string]string) Record {
+	var record Record
+	record.Failures, _ = strconv.Atoi(fields["failures"])
+	if ms, err := strconv.ParseInt(fields["last_failure"], 10, 64); err == nil {
+		record.LastFailure = time.UnixMilli(ms)
+	}
+	if ms, err := strconv.ParseInt(fields["locked_until"], 10, 64); err == nil {
+		record.LockedUntil = time.UnixMilli(ms)
+	}
+	return record
+}
@@ -0,0 +1,163 @@
+package loginguard
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Record tracks the failed logins of one account or client IP
+type Record struct {
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+// Store keeps records shared by the server instances using it. Records
+// expire on their own; an unknown key has the zero Record.
+type Store interface {
+	Get(ctx context.Context, key string) (Record, error)
+	// Fail counts a failure at now and returns the updated record, which
+	// expires ttl after its last failure
+	Fail(ctx context.Context, key string, now time.Time, ttl time.Duration) (Record, error)
+	// Lock replaces the record with a lock that expires at until
+	Lock(ctx context.Context, key string, until time.Time) error
+	// Reset forgets the record
+	Reset(ctx context.Context, key string) error
+}
+
+// How often MemoryStore drops expired records.
+const memorySweepInterval = time.Minute
+
+// MemoryStore keeps records in this server instance only
+type MemoryStore struct {
+	mu        sync.Mutex
+	records   map[string]memoryRecord
+	lastSweep time.Time
+}
+
+type memoryRecord struct {
+	record    Record
+	expiresAt time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		records:   make(map[string]memoryRecord),
+		lastSweep: time.Now(),
+	}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.get(key, time.Now()), nil
+}
+
+func (s *MemoryStore) Fail(ctx context.Context, key string, now time.Time, ttl time.Duration) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+
+	record := s.get(key, now)
+	record.Failures++
+	record.LastFailure = now
+	s.records[key] = memoryRecord{record: record, expiresAt: now.Add(ttl)}
+	return record, nil
+}
+
+func (s *MemoryStore) Lock(ctx context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records[key] = memoryRecord{record: Record{LockedUntil: until}, expiresAt: until}
+	return nil
+}
+
+func (s *MemoryStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+	return nil
+}
+
+// get returns the key's record unless it has expired. Callers must hold mu.
+func (s *MemoryStore) get(key string, now time.Time) Record {
+	r, ok := s.records[key]
+	if !ok || !now.Before(r.expiresAt) {
+		return Record{}
+	}
+	return r.record
+}
+
+// sweep drops expired records, at most once per memorySweepInterval. Callers must hold mu.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < memorySweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, r := range s.records {
+		if !now.Before(r.expiresAt) {
+			delete(s.records, key)
+		}
+	}
+}
+
+// FallbackStore uses primary and switches to fallback for any call primary
+// fails, so logins stay protected, per instance, while Redis is unreachable
+type FallbackStore struct {
+	primary  Store
+	fallback Store
+}
+
+func NewFallbackStore(primary, fallback Store) *FallbackStore {
+	return &FallbackStore{primary: primary, fallback: fallback}
+}
+
+func (s *FallbackStore) Get(ctx context.Context, key string) (Record, error) {
+	record, err := s.primary.Get(ctx, key)
+	if err != nil {
+		logFallback(err)
+		return s.fallback.Get(ctx, key)
+	}
+	return record, nil
+}
+
+func (s *FallbackStore) Fail(ctx context.Context, key string, now time.Time, ttl time.Duration) (Record, error) {
+	record, err := s.primary.Fail(ctx, key, now, ttl)
+	if err != nil {
+		logFallback(err)
+		return s.fallback.Fail(ctx, key, now, ttl)
+	}
+	return record, nil
+}
+
+func (s *FallbackStore) Lock(ctx context.Context, key string, until time.Time) error {
+	if err := s.primary.Lock(ctx, key, until); err != nil {
+		logFallback(err)
+		return s.fallback.Lock(ctx, key, until)
+	}
+	return nil
+}
+
+// Reset clears the key in both stores, so an unlock also covers records
+// kept while primary was failing
+func (s *FallbackStore) Reset(ctx context.Context, key string) error {
+	err := s.primary.Reset(ctx, key)
+	if fallbackErr := s.fallback.Reset(ctx, key); fallbackErr != nil {
+		return fallbackErr
+	}
+	if err != nil {
+		logFallback(err)
+	}
+	return nil
+}
+
+func logFallback(err error) {
+	log.Printf("Login attempt store failed, using in-memory fallback: %v", err)
+}
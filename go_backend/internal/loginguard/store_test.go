@@ -0,0 +1,111 @@
+package loginguard
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var errStoreDown = errors.New("store unavailable")
+
+// brokenStore fails every call, like Redis while it is unreachable
+type brokenStore struct{}
+
+func (brokenStore) Get(ctx context.Context, key string) (Record, error) {
+	return Record{}, errStoreDown
+}
+
+func (brokenStore) Fail(ctx context.Context, key string, now time.Time, ttl time.Duration) (Record, error) {
+	return Record{}, errStoreDown
+}
+
+func (brokenStore) Lock(ctx context.Context, key string, until time.Time) error {
+	return errStoreDown
+}
+
+func (brokenStore) Reset(ctx context.Context, key string) error {
+	return errStoreDown
+}
+
+func TestMemoryStoreExpiry(t *testing.T) {
+	s := NewMemoryStore()
+	ctx := context.Background()
+	now := time.Now()
+
+	s.Fail(ctx, "old", now.Add(-2*time.Hour), time.Hour)
+	if record, _ := s.Get(ctx, "old"); record.Failures != 0 {
+		t.Errorf("got %+v, want failures past their window forgotten", record)
+	}
+
+	s.Fail(ctx, "recent", now.Add(-time.Minute), time.Hour)
+	record, _ := s.Fail(ctx, "recent", now, time.Hour)
+	if record.Failures != 2 || !record.LastFailure.Equal(now) {
+		t.Errorf("got %+v, want 2 failures, the last one now", record)
+	}
+
+	s.Lock(ctx, "recent", now.Add(-time.Second))
+	if record, _ := s.Get(ctx, "recent"); record != (Record{}) {
+		t.Errorf("got %+v, want an expired lock forgotten along with the failures", record)
+	}
+}
+
+// Expired records are dropped from memory, not just hidden
+func TestMemoryStoreSweeps(t *testing.T) {
+	s := NewMemoryStore()
+	ctx := context.Background()
+	now := time.Now()
+
+	s.Fail(ctx, "old", now, time.Minute)
+	s.Fail(ctx, "new", now.Add(2*memorySweepInterval), time.Minute)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.records["old"]; ok || len(s.records) != 1 {
+		t.Errorf("got %d records, want only the one still current", len(s.records))
+	}
+}
+
+// While Redis is unreachable, failures are counted and locks placed in the
+// fallback, so logins stay throttled
+func TestFallbackStoreLocksWhileRedisIsDown(t *testing.T) {
+	quietLogs(t)
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", DialTimeout: 100 * time.Millisecond, MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	g := NewGuard(Config{MaxFailures: 2, Lockout: time.Hour, Window: time.Hour}, NewFallbackStore(NewRedisStore(client), NewMemoryStore()))
+
+	fail(t, g, "alice", "")
+	if lockouts := fail(t, g, "alice", ""); len(lockouts) != 1 {
+		t.Fatalf("got %+v, want the account locked", lockouts)
+	}
+	if wait := locked(t, g, "alice", ""); wait == 0 {
+		t.Fatal("the account was not locked")
+	}
+
+	if err := g.UnlockAccount(context.Background(), "alice"); err != nil {
+		t.Fatalf("unlock failed with the primary down: %v", err)
+	}
+	if wait := locked(t, g, "alice", ""); wait != 0 {
+		t.Errorf("got a wait of %v after the unlock", wait)
+	}
+}
+
+// Reset clears records kept in either store
+func TestFallbackStoreResetsBoth(t *testing.T) {
+	ctx := context.Background()
+	primary, fallback := NewMemoryStore(), NewMemoryStore()
+	s := NewFallbackStore(primary, fallback)
+
+	primary.Lock(ctx, "key", time.Now().Add(time.Hour))
+	fallback.Lock(ctx, "key", time.Now().Add(time.Hour))
+	if err := s.Reset(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	for name, store := range map[string]Store{"primary": primary, "fallback": fallback} {
+		if record, _ := store.Get(ctx, "key"); record != (Record{}) {
+			t.Errorf("the %s store still has %+v", name, record)
+		}
+	}
+}
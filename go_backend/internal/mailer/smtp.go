@@ -0,0 +1,141 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// Time allowed for a whole SMTP conversation when ctx has no deadline
+const smtpTimeout = 30 * time.Second
+
+// SMTPConfig describes how to reach the mail server
+type SMTPConfig struct {
+	Host     string
+	Port     int    // 465 uses TLS from the start, other ports upgrade with STARTTLS
+	Username string // empty sends without authenticating
+	Password string
+	From     string // like Chat App <no-reply@chat.example.com>
+}
+
+// SMTPMailer sends email through a mail server. Connections are only
+// upgraded with STARTTLS when the server offers it, but credentials are
+// never sent unencrypted except to localhost.
+type SMTPMailer struct {
+	cfg    SMTPConfig
+	sender string // the bare address from cfg.From
+}
+
+// NewSMTPMailer checks the configuration. Nothing is dialled until the first message.
+func NewSMTPMailer(cfg SMTPConfig) (*SMTPMailer, error) {
+	if cfg.Host == "" {
+		return nil, errors.New("SMTP host is required")
+	}
+	if cfg.Port == 0 {
+		cfg.Port = 587
+	}
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, errors.New("invalid sender address")
+	}
+	return &SMTPMailer{cfg: cfg, sender: from.Address}, nil
+}
+
+// Send delivers the message to the mail server
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	data, err := build(m.cfg.From, msg)
+	if err != nil {
+		return err
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return errors.New("invalid recipient address")
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, smtpTimeout)
+		defer cancel()
+	}
+
+	c, err := m.dial(ctx)
+	if err != nil {
+		log.Printf("SMTP: %v", err)
+		return errors.New("mail server is unavailable")
+	}
+	defer c.Close()
+
+	if err := m.deliver(c, to.Address, data); err != nil {
+		log.Printf("SMTP: sending to %s: %v", to.Address, err)
+		return errors.New("failed to send email")
+	}
+	return nil
+}
+
+// dial connects and says hello, with TLS from the start on port 465
+func (m *SMTPMailer) dial(ctx context.Context) (*smtp.Client, error) {
+	addr := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
+	tlsConfig := &tls.Config{ServerName: m.cfg.Host}
+
+	var conn net.Conn
+	var err error
+	if m.cfg.Port == 465 {
+		dialer := &tls.Dialer{Config: tlsConfig}
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	} else {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, m.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if ok, _ := c.Extension("STARTTLS"); ok && m.cfg.Port != 465 {
+		if err := c.StartTLS(tlsConfig); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// deliver runs the SMTP transaction for one message
+func (m *SMTPMailer) deliver(c *smtp.Client, to string, data []byte) error {
+	if m.cfg.Username != "" {
+		// PlainAuth itself refuses to send credentials without TLS, except to localhost
+		if err := c.Auth(smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(m.sender); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
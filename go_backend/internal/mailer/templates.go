@@ -0,0 +1,57 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	htmltemplate "html/template"
+	"strings"
+	"text/template"
+)
+
+// Email templates. Each has a plain text version, name.txt, which also
+// defines "subject", and an HTML version, name.html.
+const (
+	TemplateVerifyEmail   = "verify_email"
+	TemplatePasswordReset = "password_reset"
+	TemplateMagicLink     = "magic_link"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+// The text templates are parsed one by one, as they all define "subject"
+var textTemplates = func() map[string]*template.Template {
+	templates := make(map[string]*template.Template)
+	for _, name := range []string{TemplateVerifyEmail, TemplatePasswordReset, TemplateMagicLink} {
+		templates[name] = template.Must(template.ParseFS(templateFS, "templates/"+name+".txt"))
+	}
+	return templates
+}()
+
+var htmlTemplates = htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/*.html"))
+
+// Render fills in the named template. The message has no recipient yet.
+func Render(name string, data any) (Message, error) {
+	text, ok := textTemplates[name]
+	if !ok {
+		return Message{}, errors.New("unknown email template " + name)
+	}
+
+	var subject, body, html bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, err
+	}
+	if err := text.ExecuteTemplate(&body, name+".txt", data); err != nil {
+		return Message{}, err
+	}
+	if err := htmlTemplates.ExecuteTemplate(&html, name+".html", data); err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    body.String(),
+		HTML:    html.String(),
+	}, nil
+}
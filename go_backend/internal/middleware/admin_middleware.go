@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/GavinHemsada/go-backend/pkg/utils"
+	"github.com/google/uuid"
+)
+
+// RequireAdmin creates a middleware that only lets global admins through.
+// It must run after JWTMiddleware. The role is looked up on every request
+// so a revoked admin loses access right away.
+func RequireAdmin(isAdmin func(ctx context.Context, userID uuid.UUID) bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, err := GetUserClaims(r)
+			if err != nil {
+				utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+				return
+			}
+
+			if !isAdmin(r.Context(), claims.UserID) {
+				utils.RespondWithError(w, http.StatusForbidden, "Admin access required")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
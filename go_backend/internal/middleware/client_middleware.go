@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/GavinHemsada/go-backend/internal/audit"
+)
+
+// ClientInfo stores the caller's IP address and user agent in the request
+// context for the audit log
+func ClientInfo(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+
+		ctx := audit.WithClient(r.Context(), audit.Client{IP: ip, UserAgent: r.UserAgent()})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -3,19 +3,31 @@ package middleware
 import (
 	"context"
 	"errors"
+	"log"
 	"net/http"
 	"strings"
 
 	"github.com/GavinHemsada/go-backend/pkg/utils"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
 )
 
 type contextKey string
 
 const UserClaimsKey contextKey = "userClaims"
 
-// JWTMiddleware creates a middleware that validates JWT tokens
-func JWTMiddleware(jwtSecret string) func(http.Handler) http.Handler {
+// TokenChecker validates a token that is not a JWT and returns its claims
+type TokenChecker func(ctx context.Context, token string) (*utils.Claims, error)
+
+// JWTMiddleware creates a middleware that validates JWT tokens. Personal
+// access tokens and OAuth2 access tokens are accepted too: the checker
+// registered for the token's prefix validates them, and they only reach
+// routes wrapped with Scoped whose scopes they grant. checkAccount runs on
+// every request with a valid token, so tokens of suspended, deactivated or
+// deleted accounts, and sessions revoked by a password reset, stop working
+// right away. It returns a *utils.AccountError to refuse the token; other
+// errors are answered with 500.
+func JWTMiddleware(jwtSecret string, checkers map[string]TokenChecker, checkAccount func(ctx context.Context, claims *utils.Claims) error) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Extract token from Authorization header
@@ -34,6 +46,20 @@ func JWTMiddleware(jwtSecret string) func(http.Handler) http.Handler {
 
 			tokenString := parts[1]
 
+			if checkToken := tokenChecker(checkers, tokenString); checkToken != nil {
+				claims, err := checkToken(r.Context(), tokenString)
+				if err != nil {
+					utils.RespondWithError(w, http.StatusUnauthorized, "Invalid or expired token")
+					return
+				}
+				if !tokenAllowed(r, claims) {
+					utils.RespondWithError(w, http.StatusForbidden, "Token does not grant access to this route")
+					return
+				}
+				serveWithClaims(w, r, next, claims, checkAccount)
+				return
+			}
+
 			// Validate token
 			claims := &utils.Claims{}
 			token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
@@ -49,18 +75,71 @@ func JWTMiddleware(jwtSecret string) func(http.Handler) http.Handler {
 				return
 			}
 
-			if !token.Valid {
+			// Challenge tokens from the login steps are not sessions
+			if !token.Valid || claims.Purpose != "" {
 				utils.RespondWithError(w, http.StatusUnauthorized, "Invalid token")
 				return
 			}
 
-			// Add claims to request context
-			ctx := context.WithValue(r.Context(), UserClaimsKey, claims)
-			next.ServeHTTP(w, r.WithContext(ctx))
+			serveWithClaims(w, r, next, claims, checkAccount)
 		})
 	}
 }
 
+// tokenChecker returns the checker registered for the token's prefix, or nil for JWTs
+func tokenChecker(checkers map[string]TokenChecker, token string) TokenChecker {
+	for prefix, check := range checkers {
+		if strings.HasPrefix(token, prefix) {
+			return check
+		}
+	}
+	return nil
+}
+
+// serveWithClaims checks the account, then adds the claims to the request context
+func serveWithClaims(w http.ResponseWriter, r *http.Request, next http.Handler, claims *utils.Claims, checkAccount func(ctx context.Context, claims *utils.Claims) error) {
+	if err := checkAccount(r.Context(), claims); err != nil {
+		var refused *utils.AccountError
+		if errors.As(err, &refused) {
+			utils.RespondWithError(w, http.StatusUnauthorized, refused.Reason)
+			return
+		}
+		log.Printf("Failed to check account %s: %v", claims.UserID, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check account")
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), UserClaimsKey, claims)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// ScopedHandler is a route that personal and OAuth2 access tokens may call
+// when they grant every one of Scopes
+type ScopedHandler struct {
+	Handler http.HandlerFunc
+	Scopes  []string
+}
+
+func (h *ScopedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.Handler(w, r)
+}
+
+// Scoped opens a route to access tokens with the given scopes.
+// Routes that are not wrapped only accept session tokens.
+func Scoped(handler http.HandlerFunc, scopes ...string) *ScopedHandler {
+	return &ScopedHandler{Handler: handler, Scopes: scopes}
+}
+
+// tokenAllowed tells whether the matched route accepts the token's scopes
+func tokenAllowed(r *http.Request, claims *utils.Claims) bool {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return false
+	}
+	h, ok := route.GetHandler().(*ScopedHandler)
+	return ok && claims.Scopes.Has(h.Scopes...)
+}
+
 // GetUserClaims extracts user claims from the request context
 func GetUserClaims(r *http.Request) (*utils.Claims, error) {
 	claims, ok := r.Context().Value(UserClaimsKey).(*utils.Claims)
@@ -0,0 +1,212 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/audit"
+	"github.com/GavinHemsada/go-backend/internal/models"
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+	"github.com/GavinHemsada/go-backend/internal/services"
+	"github.com/GavinHemsada/go-backend/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+const testJWTSecret = "test-secret"
+
+type tokenTest struct {
+	router *mux.Router
+	tokens *services.AccessTokenService
+	repos  *repository.Repositories
+	user   *models.User
+}
+
+// newTokenTest returns a router with a route for each scope and one route
+// left unscoped, behind JWTMiddleware with personal access tokens enabled
+func newTokenTest(t *testing.T) *tokenTest {
+	t.Helper()
+	repos := repository.NewMemoryRepositories(repository.NewMemoryStore())
+	user, err := repos.Users.Register(context.Background(), "alice", "alice@example.com", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := services.NewAccessTokenService(repos.Users, repos.AccessTokens, audit.NewLogger(repos.Audit))
+	users := services.NewUserService(repos.Users, nil, nil, nil, testJWTSecret, nil, nil, nil, nil, audit.NewLogger(repos.Audit))
+
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	router := mux.NewRouter()
+	router.Use(JWTMiddleware(testJWTSecret, map[string]TokenChecker{utils.AccessTokenPrefix: tokens.Authenticate}, users.CheckAccount))
+	router.Handle("/read", Scoped(ok, models.ScopeMessagesRead))
+	router.Handle("/write", Scoped(ok, models.ScopeMessagesWrite))
+	router.Handle("/read-write", Scoped(ok, models.ScopeMessagesRead, models.ScopeMessagesWrite))
+	router.HandleFunc("/unscoped", ok)
+
+	return &tokenTest{router: router, tokens: tokens, repos: repos, user: user}
+}
+
+// get returns the status of a request to path with token
+func (tt *tokenTest) get(path, token string) int {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	tt.router.ServeHTTP(w, r)
+	return w.Code
+}
+
+// create issues a token for the test's user
+func (tt *tokenTest) create(t *testing.T, scopes []string, expiresAt *time.Time) (string, uuid.UUID) {
+	t.Helper()
+	resp, err := tt.tokens.CreateToken(context.Background(), tt.user.ID, "script", scopes, expiresAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.Token, resp.AccessToken.ID
+}
+
+func TestAccessTokenScopes(t *testing.T) {
+	tt := newTokenTest(t)
+	token, _ := tt.create(t, []string{models.ScopeMessagesRead}, nil)
+
+	for path, want := range map[string]int{
+		"/read":       http.StatusOK,
+		"/write":      http.StatusForbidden,
+		"/read-write": http.StatusForbidden,
+		"/unscoped":   http.StatusForbidden,
+	} {
+		if got := tt.get(path, token); got != want {
+			t.Errorf("%s: got %d, want %d", path, got, want)
+		}
+	}
+
+	both, _ := tt.create(t, []string{models.ScopeMessagesRead, models.ScopeMessagesWrite}, nil)
+	if got := tt.get("/read-write", both); got != http.StatusOK {
+		t.Errorf("got %d with both scopes, want %d", got, http.StatusOK)
+	}
+}
+
+// Session tokens reach every route, scoped or not
+func TestSessionTokenReachesEveryRoute(t *testing.T) {
+	tt := newTokenTest(t)
+	session, err := utils.GenerateToken(tt.user, testJWTSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"/read", "/write", "/read-write", "/unscoped"} {
+		if got := tt.get(path, session); got != http.StatusOK {
+			t.Errorf("%s: got %d, want %d", path, got, http.StatusOK)
+		}
+	}
+}
+
+func TestAccessTokenRevocation(t *testing.T) {
+	tt := newTokenTest(t)
+	token, id := tt.create(t, []string{models.ScopeMessagesRead}, nil)
+	if got := tt.get("/read", token); got != http.StatusOK {
+		t.Fatalf("got %d before revocation, want %d", got, http.StatusOK)
+	}
+
+	if err := tt.tokens.RevokeToken(context.Background(), tt.user.ID, id); err != nil {
+		t.Fatal(err)
+	}
+	if got := tt.get("/read", token); got != http.StatusUnauthorized {
+		t.Errorf("got %d right after revocation, want %d", got, http.StatusUnauthorized)
+	}
+
+	// Only the owner can revoke a token
+	other, otherID := tt.create(t, []string{models.ScopeMessagesRead}, nil)
+	if err := tt.tokens.RevokeToken(context.Background(), uuid.New(), otherID); err == nil {
+		t.Error("another user revoked the token")
+	}
+	if got := tt.get("/read", other); got != http.StatusOK {
+		t.Errorf("got %d, want the token still working", got)
+	}
+}
+
+func TestAccessTokenExpiry(t *testing.T) {
+	tt := newTokenTest(t)
+
+	past := time.Now().Add(-time.Minute)
+	if _, err := tt.tokens.CreateToken(context.Background(), tt.user.ID, "script", []string{models.ScopeMessagesRead}, &past); err == nil {
+		t.Error("a token was created already expired")
+	}
+
+	soon := time.Now().Add(100 * time.Millisecond)
+	token, _ := tt.create(t, []string{models.ScopeMessagesRead}, &soon)
+	if got := tt.get("/read", token); got != http.StatusOK {
+		t.Fatalf("got %d before expiry, want %d", got, http.StatusOK)
+	}
+	time.Sleep(time.Until(soon) + 10*time.Millisecond)
+	if got := tt.get("/read", token); got != http.StatusUnauthorized {
+		t.Errorf("got %d after expiry, want %d", got, http.StatusUnauthorized)
+	}
+}
+
+// Tokens stop working while their user is suspended
+func TestAccessTokenOfSuspendedUser(t *testing.T) {
+	tt := newTokenTest(t)
+	token, _ := tt.create(t, []string{models.ScopeMessagesRead}, nil)
+
+	if err := tt.repos.Users.SetStatus(context.Background(), tt.user.ID, models.UserStatusSuspended); err != nil {
+		t.Fatal(err)
+	}
+	if got := tt.get("/read", token); got != http.StatusUnauthorized {
+		t.Errorf("got %d, want %d", got, http.StatusUnauthorized)
+	}
+}
+
+func TestAccessTokenRejectsUnknownScopes(t *testing.T) {
+	tt := newTokenTest(t)
+	for _, scopes := range [][]string{nil, {"admin"}, {models.ScopeMessagesRead, "messages:delete"}} {
+		if _, err := tt.tokens.CreateToken(context.Background(), tt.user.ID, "script", scopes, nil); err == nil {
+			t.Errorf("a token was created with scopes %v", scopes)
+		}
+	}
+}
+
+// Accounts refused by the check get 401, failures to check them get 500
+func TestAccountCheckFailures(t *testing.T) {
+	tt := newTokenTest(t)
+	session, err := utils.GenerateToken(tt.user, testJWTSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	for _, c := range []struct {
+		err  error
+		want int
+	}{
+		{&utils.AccountError{Reason: "account is suspended"}, http.StatusUnauthorized},
+		{fmt.Errorf("checking account: %w", &utils.AccountError{Reason: "account is suspended"}), http.StatusUnauthorized},
+		{errors.New("database is down"), http.StatusInternalServerError},
+	} {
+		router := mux.NewRouter()
+		router.Use(JWTMiddleware(testJWTSecret, nil, func(ctx context.Context, claims *utils.Claims) error {
+			return c.err
+		}))
+		router.HandleFunc("/unscoped", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+
+		r := httptest.NewRequest(http.MethodGet, "/unscoped", nil)
+		r.Header.Set("Authorization", "Bearer "+session)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != c.want {
+			t.Errorf("%v: got %d, want %d", c.err, w.Code, c.want)
+		}
+		if c.want == http.StatusInternalServerError && strings.Contains(w.Body.String(), "database") {
+			t.Errorf("the error was sent to the client: %s", w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Personal access token scopes
+const (
+	ScopeMessagesRead  = "messages:read"  // list rooms and read their messages
+	ScopeMessagesWrite = "messages:write" // post messages
+	ScopeRoomsManage   = "rooms:manage"   // create, delete, join and leave rooms
+)
+
+// AccessTokenScopes lists every scope a token can be given
+var AccessTokenScopes = []string{ScopeMessagesRead, ScopeMessagesWrite, ScopeRoomsManage}
+
+// Scopes is a list of token scopes, stored comma-separated
+type Scopes []string
+
+// Has tells whether every one of scopes is in the list
+func (s Scopes) Has(scopes ...string) bool {
+	for _, want := range scopes {
+		found := false
+		for _, scope := range s {
+			if scope == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (s Scopes) Value() (driver.Value, error) {
+	return strings.Join(s, ","), nil
+}
+
+func (s *Scopes) Scan(src interface{}) error {
+	var joined string
+	switch v := src.(type) {
+	case string:
+		joined = v
+	case []byte:
+		joined = string(v)
+	default:
+		return errors.New("scopes must be text")
+	}
+
+	*s = nil
+	for _, scope := range strings.Split(joined, ",") {
+		if scope != "" {
+			*s = append(*s, scope)
+		}
+	}
+	return nil
+}
+
+// AccessToken is a personal access token. Only the token's SHA-256 hash is
+// stored; Prefix tells tokens apart in listings.
+type AccessToken struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	UserID     uuid.UUID  `json:"-" db:"user_id"`
+	Name       string     `json:"name" db:"name"`
+	TokenHash  string     `json:"-" db:"token_hash"`
+	Prefix     string     `json:"prefix" db:"prefix"`
+	Scopes     Scopes     `json:"scopes" db:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" db:"expires_at"` // nil for tokens that never expire
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+}
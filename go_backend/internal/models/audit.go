@@ -0,0 +1,157 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Audit event results
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// Audit actions
+const (
+	AuditUserRegister   = "user.register"
+	AuditUserLogin      = "user.login"
+	AuditUserDeactivate = "user.deactivate"
+	AuditUserLockout    = "user.lockout"
+
+	AuditEmailVerify          = "user.email.verify"
+	AuditPasswordResetRequest = "user.password.reset_request"
+	AuditPasswordReset        = "user.password.reset"
+	AuditPasswordChange       = "user.password.change"
+	AuditMagicLinkRequest     = "user.magic_link.request"
+	AuditProfileUpdate        = "user.profile.update"
+	AuditPrivacyUpdate        = "user.privacy.update"
+
+	AuditTwoFactorEnable        = "user.2fa.enable"
+	AuditTwoFactorDisable       = "user.2fa.disable"
+	AuditTwoFactorVerify        = "user.2fa.verify"
+	AuditTwoFactorRecoveryCodes = "user.2fa.recovery_codes"
+
+	AuditPasskeyRegister = "user.passkey.register"
+	AuditPasskeyDelete   = "user.passkey.delete"
+
+	AuditIdentityLink = "user.identity.link"
+
+	AuditAccessTokenCreate = "user.token.create"
+	AuditAccessTokenRevoke = "user.token.revoke"
+
+	AuditBotCreate = "user.bot.create"
+	AuditBotDelete = "user.bot.delete"
+
+	AuditIPLockout = "ip.lockout"
+
+	AuditOAuthAppCreate     = "oauth.app.create"
+	AuditOAuthAppDelete     = "oauth.app.delete"
+	AuditOAuthConsent       = "oauth.consent"
+	AuditOAuthConsentRevoke = "oauth.consent.revoke"
+
+	AuditRoomCreate = "room.create"
+	AuditRoomDelete = "room.delete"
+	AuditRoomJoin   = "room.join"
+	AuditRoomLeave  = "room.leave"
+
+	AuditRoomBotAdd    = "room.bot.add"
+	AuditRoomBotRemove = "room.bot.remove"
+
+	AuditMessageDenied  = "message.denied"
+	AuditMessageFlagged = "message.flagged"
+
+	AuditModerationKick   = "moderation.kick"
+	AuditModerationBan    = "moderation.ban"
+	AuditModerationUnban  = "moderation.unban"
+	AuditModerationMute   = "moderation.mute"
+	AuditModerationUnmute = "moderation.unmute"
+
+	AuditAdminSuspend    = "admin.user.suspend"
+	AuditAdminDeactivate = "admin.user.deactivate"
+	AuditAdminReactivate = "admin.user.reactivate"
+	AuditAdminDeleteUser = "admin.user.delete"
+	AuditAdminSetRole    = "admin.user.role"
+	AuditAdminUnlockUser = "admin.user.unlock"
+	AuditAdminUnlockIP   = "admin.ip.unlock"
+	AuditAdminReset2FA   = "admin.user.2fa_reset"
+	AuditAdminDeleteRoom = "admin.room.delete"
+	AuditAdminSetting    = "admin.setting"
+)
+
+// Audit target types
+const (
+	AuditTargetUser    = "user"
+	AuditTargetRoom    = "room"
+	AuditTargetMessage = "message"
+	AuditTargetIP      = "ip"
+	AuditTargetSetting = "setting"
+	AuditTargetApp     = "oauth_app"
+)
+
+// AuditEvent is one entry of the append-only audit log. Hash covers every
+// other field, including PrevHash, so changing or removing an entry breaks
+// the chain from that point on.
+type AuditEvent struct {
+	Seq        int64      `json:"seq" db:"seq"`
+	ActorID    *uuid.UUID `json:"actor_id,omitempty" db:"actor_id"` // nil for anonymous or system events
+	Action     string     `json:"action" db:"action"`
+	TargetType string     `json:"target_type,omitempty" db:"target_type"`
+	TargetID   string     `json:"target_id,omitempty" db:"target_id"`
+	IP         string     `json:"ip,omitempty" db:"ip"`
+	UserAgent  string     `json:"user_agent,omitempty" db:"user_agent"`
+	Result     string     `json:"result" db:"result"`
+	Details    string     `json:"details,omitempty" db:"details"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	PrevHash   string     `json:"prev_hash" db:"prev_hash"`
+	Hash       string     `json:"hash" db:"hash"`
+}
+
+// ComputeHash returns the SHA-256 of the event's fields and PrevHash, hex encoded
+func (e *AuditEvent) ComputeHash() string {
+	actor := ""
+	if e.ActorID != nil {
+		actor = e.ActorID.String()
+	}
+
+	// A JSON array keeps field boundaries unambiguous
+	fields, _ := json.Marshal([]string{
+		strconv.FormatInt(e.Seq, 10),
+		e.PrevHash,
+		actor,
+		e.Action,
+		e.TargetType,
+		e.TargetID,
+		e.IP,
+		e.UserAgent,
+		e.Result,
+		e.Details,
+		e.CreatedAt.UTC().Format(time.RFC3339Nano),
+	})
+	sum := sha256.Sum256(fields)
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditFilter selects audit events. Empty fields match everything.
+type AuditFilter struct {
+	ActorID  *uuid.UUID
+	Action   string
+	TargetID string
+	Result   string
+	Since    *time.Time
+	Until    *time.Time
+	Limit    int
+	Offset   int
+}
+
+// AuditVerification is the outcome of checking the audit log's hash chain
+type AuditVerification struct {
+	Valid     bool   `json:"valid"`
+	Checked   int    `json:"checked"`
+	BrokenSeq int64  `json:"broken_seq,omitempty"` // first event that does not match the chain
+	Error     string `json:"error,omitempty"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Avatar is an image a user uploaded as their picture
+type Avatar struct {
+	ID          uuid.UUID `db:"id"`
+	UserID      uuid.UUID `db:"user_id"`
+	ContentType string    `db:"content_type"`
+	Data        []byte    `db:"data"`
+	CreatedAt   time.Time `db:"created_at"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+    "time"
+    "github.com/google/uuid"
+)
+
+// Word filter actions
+const (
+    WordActionBlock = "block" // Reject the message
+    WordActionMask  = "mask"  // Replace the word with asterisks
+    WordActionFlag  = "flag"  // Let the message through and report it to the moderators
+)
+
+// WordFilter is a word or phrase that a room's moderators want blocked, masked or flagged
+type WordFilter struct {
+    RoomID    uuid.UUID `json:"room_id" db:"room_id"`
+    Word      string    `json:"word" db:"word"`
+    Action    string    `json:"action" db:"action"`
+    CreatedBy uuid.UUID `json:"created_by" db:"created_by"`
+    CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
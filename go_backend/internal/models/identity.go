@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Identity links an account at an OpenID Connect provider to a local user
+type Identity struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	UserID      uuid.UUID  `json:"user_id" db:"user_id"`
+	Issuer      string     `json:"issuer" db:"issuer"`
+	Subject     string     `json:"subject" db:"subject"`
+	Email       string     `json:"email" db:"email"` // as last reported by the provider
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty" db:"last_login_at"`
+}
+
+// OIDCLogin is a login redirected to the provider and not back yet. State
+// comes back with the provider's response, the nonce inside its ID token.
+type OIDCLogin struct {
+	State        string    `db:"state"`
+	Nonce        string    `db:"nonce"`
+	CodeVerifier string    `db:"code_verifier"` // PKCE
+	ExpiresAt    time.Time `db:"expires_at"`
+}
+
+// SAMLRequest is an authentication request sent to the SAML identity
+// provider and not answered yet. RelayState comes back with the response,
+// which must be InResponseTo RequestID.
+type SAMLRequest struct {
+	RelayState string    `db:"relay_state"`
+	RequestID  string    `db:"request_id"`
+	ExpiresAt  time.Time `db:"expires_at"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LoginLink is a one-time link that logs a user in from their email. It
+// only works with the device token handed to whoever asked for it.
+type LoginLink struct {
+	TokenHash  string     `db:"token_hash"`
+	UserID     uuid.UUID  `db:"user_id"`
+	DeviceHash string     `db:"device_hash"`
+	ExpiresAt  time.Time  `db:"expires_at"`
+	UsedAt     *time.Time `db:"used_at"`
+	CreatedAt  time.Time  `db:"created_at"`
+}
@@ -13,4 +13,5 @@ type Message struct {
     MessageType string    `json:"message_type" db:"message_type"`
     CreatedAt   time.Time `json:"created_at" db:"created_at"`
     Username    string    `json:"username,omitempty"` // For display
+    IsBot       bool      `json:"is_bot" db:"is_bot"` // Author is a bot account
 }
\ No newline at end of file
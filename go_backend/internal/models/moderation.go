@@ -0,0 +1,26 @@
+package models
+
+import (
+    "time"
+    "github.com/google/uuid"
+)
+
+// RoomBan keeps a user out of a room until ExpiresAt, or forever when ExpiresAt is nil
+type RoomBan struct {
+    RoomID    uuid.UUID  `json:"room_id" db:"room_id"`
+    UserID    uuid.UUID  `json:"user_id" db:"user_id"`
+    BannedBy  uuid.UUID  `json:"banned_by" db:"banned_by"`
+    Reason    string     `json:"reason" db:"reason"`
+    ExpiresAt *time.Time `json:"expires_at" db:"expires_at"`
+    CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+// RoomMute stops a user from posting in a room until ExpiresAt
+type RoomMute struct {
+    RoomID    uuid.UUID `json:"room_id" db:"room_id"`
+    UserID    uuid.UUID `json:"user_id" db:"user_id"`
+    MutedBy   uuid.UUID `json:"muted_by" db:"muted_by"`
+    Reason    string    `json:"reason" db:"reason"`
+    ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+    CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
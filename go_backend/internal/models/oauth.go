@@ -0,0 +1,99 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// URIs is a list of redirect URIs, stored newline-separated
+type URIs []string
+
+// Has tells whether uri is in the list
+func (u URIs) Has(uri string) bool {
+	for _, candidate := range u {
+		if candidate == uri {
+			return true
+		}
+	}
+	return false
+}
+
+func (u URIs) Value() (driver.Value, error) {
+	return strings.Join(u, "\n"), nil
+}
+
+func (u *URIs) Scan(src interface{}) error {
+	var joined string
+	switch v := src.(type) {
+	case string:
+		joined = v
+	case []byte:
+		joined = string(v)
+	default:
+		return errors.New("redirect URIs must be text")
+	}
+
+	*u = nil
+	for _, uri := range strings.Split(joined, "\n") {
+		if uri != "" {
+			*u = append(*u, uri)
+		}
+	}
+	return nil
+}
+
+// OAuthApp is a third-party app that acts for users through OAuth2. Its ID
+// is the client ID. Confidential apps authenticate with a secret, of which
+// only the SHA-256 hash is kept; public apps have none and rely on PKCE.
+type OAuthApp struct {
+	ID           uuid.UUID `json:"client_id" db:"id"`
+	OwnerID      uuid.UUID `json:"-" db:"owner_id"`
+	Name         string    `json:"name" db:"name"`
+	SecretHash   *string   `json:"-" db:"secret_hash"`
+	RedirectURIs URIs      `json:"redirect_uris" db:"redirect_uris"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
+
+// Confidential tells whether the app authenticates with a secret
+func (a *OAuthApp) Confidential() bool {
+	return a.SecretHash != nil
+}
+
+// OAuthCode is an authorization code waiting to be exchanged for tokens.
+// CodeChallenge is the PKCE S256 challenge the code verifier must match.
+type OAuthCode struct {
+	CodeHash      string    `db:"code_hash"`
+	AppID         uuid.UUID `db:"app_id"`
+	UserID        uuid.UUID `db:"user_id"`
+	RedirectURI   string    `db:"redirect_uri"`
+	Scopes        Scopes    `db:"scopes"`
+	CodeChallenge string    `db:"code_challenge"`
+	ExpiresAt     time.Time `db:"expires_at"`
+}
+
+// OAuthConsent is the scopes a user granted an app
+type OAuthConsent struct {
+	UserID    uuid.UUID `json:"-" db:"user_id"`
+	AppID     uuid.UUID `json:"client_id" db:"app_id"`
+	AppName   string    `json:"name" db:"app_name"`
+	Scopes    Scopes    `json:"scopes" db:"scopes"`
+	GrantedAt time.Time `json:"granted_at" db:"granted_at"`
+}
+
+// OAuthToken is an access token issued to an app for a user, with the
+// refresh token that renews it. Only the SHA-256 of each is stored.
+type OAuthToken struct {
+	ID               uuid.UUID `db:"id"`
+	AppID            uuid.UUID `db:"app_id"`
+	UserID           uuid.UUID `db:"user_id"`
+	AccessHash       string    `db:"access_hash"`
+	RefreshHash      string    `db:"refresh_hash"`
+	Scopes           Scopes    `db:"scopes"`
+	AccessExpiresAt  time.Time `db:"access_expires_at"`
+	RefreshExpiresAt time.Time `db:"refresh_expires_at"`
+	CreatedAt        time.Time `db:"created_at"`
+}
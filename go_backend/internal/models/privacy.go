@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Audiences of a privacy setting. Users always see their own details, and
+// admins everyone's.
+const (
+	VisibilityEveryone    = "everyone"
+	VisibilityRoomMembers = "room_members" // users sharing a room with them
+	VisibilityNobody      = "nobody"
+)
+
+// Presence states
+const (
+	PresenceOnline  = "online"
+	PresenceOffline = "offline"
+)
+
+// PrivacySettings says who may see a user's details and message them
+type PrivacySettings struct {
+	UserID         uuid.UUID `json:"-" db:"user_id"`
+	Email          string    `json:"email" db:"email_visibility"`
+	Presence       string    `json:"presence" db:"presence_visibility"`
+	LastSeen       string    `json:"last_seen" db:"last_seen_visibility"`
+	DirectMessages string    `json:"direct_messages" db:"direct_messages"`
+	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// DefaultPrivacySettings are the settings of users who never changed them
+func DefaultPrivacySettings(userID uuid.UUID) PrivacySettings {
+	return PrivacySettings{
+		UserID:         userID,
+		Email:          VisibilityNobody,
+		Presence:       VisibilityEveryone,
+		LastSeen:       VisibilityRoomMembers,
+		DirectMessages: VisibilityEveryone,
+	}
+}
+
+// PublicUser is what other users see of someone, within their privacy settings
+type PublicUser struct {
+	Profile
+	Email      string     `json:"email,omitempty"`
+	Presence   string     `json:"presence,omitempty"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+	CanMessage bool       `json:"can_message"` // whether the viewer may send them direct messages
+}
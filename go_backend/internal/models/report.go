@@ -0,0 +1,56 @@
+package models
+
+import (
+    "time"
+    "github.com/google/uuid"
+)
+
+// Report target types
+const (
+    ReportTargetMessage = "message"
+    ReportTargetUser    = "user"
+)
+
+// Report statuses. Open reports wait in the room's moderation queue, claimed
+// reports are being handled by one moderator, resolved and dismissed are final.
+const (
+    ReportStatusOpen      = "open"
+    ReportStatusClaimed   = "claimed"
+    ReportStatusResolved  = "resolved"
+    ReportStatusDismissed = "dismissed"
+)
+
+// Report flags a message or a user in a room for the room's moderators.
+// For message reports TargetUserID is the author and MessageContent keeps
+// a copy of the message, so the evidence survives the message being deleted.
+// ReporterID is nil for reports filed by the content filter.
+type Report struct {
+    ID             uuid.UUID  `json:"id" db:"id"`
+    RoomID         uuid.UUID  `json:"room_id" db:"room_id"`
+    ReporterID     *uuid.UUID `json:"reporter_id" db:"reporter_id"`
+    TargetType     string     `json:"target_type" db:"target_type"`
+    MessageID      *uuid.UUID `json:"message_id,omitempty" db:"message_id"`
+    TargetUserID   uuid.UUID  `json:"target_user_id" db:"target_user_id"`
+    MessageContent string     `json:"message_content,omitempty" db:"message_content"`
+    Category       string     `json:"category" db:"category"`
+    Details        string     `json:"details" db:"details"`
+    Status         string     `json:"status" db:"status"`
+    ClaimedBy      *uuid.UUID `json:"claimed_by" db:"claimed_by"`
+    ClaimedAt      *time.Time `json:"claimed_at" db:"claimed_at"`
+    ResolvedBy     *uuid.UUID `json:"resolved_by" db:"resolved_by"`
+    ResolvedAt     *time.Time `json:"resolved_at" db:"resolved_at"`
+    Resolution     string     `json:"resolution" db:"resolution"`
+    ResolutionNote string     `json:"resolution_note" db:"resolution_note"`
+    CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+}
+
+// ReportAction is one entry in a report's audit trail: who did what to it and when.
+// ActorID is nil for actions taken by the server itself.
+type ReportAction struct {
+    ID        uuid.UUID  `json:"id" db:"id"`
+    ReportID  uuid.UUID  `json:"report_id" db:"report_id"`
+    ActorID   *uuid.UUID `json:"actor_id" db:"actor_id"`
+    Action    string     `json:"action" db:"action"`
+    Note      string     `json:"note" db:"note"`
+    CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,14 @@
+package models
+
+// Server setting keys
+const (
+	SettingTwoFactorRequired         = "two_factor_required"
+	SettingEmailVerificationRequired = "email_verification_required" // "true" or "false"
+)
+
+// Values of SettingTwoFactorRequired
+const (
+	TwoFactorRequiredNone     = "none"
+	TwoFactorRequiredAdmins   = "admins"
+	TwoFactorRequiredEveryone = "everyone"
+)
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ServerStats is a point-in-time overview of the server for administrators
+type ServerStats struct {
+	Users       int      `json:"users" db:"users"`
+	Rooms       int      `json:"rooms" db:"rooms"`
+	Messages    int      `json:"messages" db:"messages"`
+	OpenReports int      `json:"open_reports" db:"open_reports"`
+	Hub         HubStats `json:"hub" db:"-"`
+}
+
+// HubStats counts the live connections held by one server instance
+type HubStats struct {
+	HubID       string `json:"hub_id"`
+	ActiveRooms int    `json:"active_rooms"`
+	Clients     int    `json:"clients"`
+}
+
+// ConnectedClient is one live WebSocket connection
+type ConnectedClient struct {
+	UserID      uuid.UUID `json:"user_id"`
+	RoomID      uuid.UUID `json:"room_id"`
+	RemoteAddr  string    `json:"remote_addr"`
+	ConnectedAt time.Time `json:"connected_at"`
+}
+
+// HubClients lists the live connections held by one server instance
+type HubClients struct {
+	HubID   string            `json:"hub_id"`
+	Clients []ConnectedClient `json:"clients"`
+}
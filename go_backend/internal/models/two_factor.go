@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TOTP is a user's authenticator app enrollment. It is enabled once the
+// user confirms a first code.
+type TOTP struct {
+	UserID    uuid.UUID  `json:"-" db:"user_id"`
+	Secret    string     `json:"-" db:"secret"`
+	Enabled   bool       `json:"enabled" db:"enabled"`
+	LastStep  int64      `json:"-" db:"last_step"` // time step of the last accepted code
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	EnabledAt *time.Time `json:"enabled_at,omitempty" db:"enabled_at"`
+}
+
+// TOTPSetup is what an authenticator app needs to enroll. URI is usually
+// shown as a QR code.
+type TOTPSetup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// TwoFactorStatus describes a user's two-factor authentication
+type TwoFactorStatus struct {
+	Enabled       bool       `json:"enabled"`
+	EnabledAt     *time.Time `json:"enabled_at,omitempty"`
+	RecoveryCodes int        `json:"recovery_codes"` // unused recovery codes left
+	Required      bool       `json:"required"`       // the server policy requires it for this user
+}
@@ -1,14 +1,113 @@
 package models
 
 import (
+    "encoding/json"
     "time"
     "github.com/google/uuid"
 )
 
+// Global roles
+const (
+    RoleUser  = "user"
+    RoleAdmin = "admin"
+)
+
+// Account statuses
+const (
+    UserStatusActive      = "active"
+    UserStatusSuspended   = "suspended"
+    UserStatusDeactivated = "deactivated"
+)
+
+// DeactivatedUsername is shown instead of a deactivated user's name next to their messages
+const DeactivatedUsername = "deactivated"
+
+// BotEmailDomain holds the placeholder emails of bot accounts, which never receive mail
+const BotEmailDomain = "bots.invalid"
+
 type User struct {
-    ID           uuid.UUID `json:"id" db:"id"`
-    Username     string    `json:"username" db:"username"`
-    Email        string    `json:"email" db:"email"`
-    PasswordHash string    `json:"-" db:"password_hash"`
-    CreatedAt    time.Time `json:"created_at" db:"created_at"`
-}
\ No newline at end of file
+    ID            uuid.UUID  `json:"id" db:"id"`
+    Username      string     `json:"username" db:"username"`
+    Email         string     `json:"email" db:"email"`
+    EmailVerified bool       `json:"email_verified" db:"email_verified"`
+    PasswordHash  string     `json:"-" db:"password_hash"`
+    SessionStamp  string     `json:"-" db:"session_stamp"` // carried by session tokens; changed to revoke them
+    DisplayName   string     `json:"display_name,omitempty" db:"display_name"`
+    Role          string     `json:"role" db:"role"`
+    Status        string     `json:"status" db:"status"`
+    IsBot         bool       `json:"is_bot" db:"is_bot"`
+    OwnerID       *uuid.UUID `json:"owner_id,omitempty" db:"owner_id"` // the human who manages the bot
+    Bio           string     `json:"bio,omitempty" db:"bio"`
+    TimeZone      string     `json:"time_zone,omitempty" db:"time_zone"` // IANA name, like "Europe/Berlin"
+    Pronouns      string     `json:"pronouns,omitempty" db:"pronouns"`
+    StatusText    string     `json:"-" db:"status_text"`
+    StatusEmoji   string     `json:"-" db:"status_emoji"`
+    StatusExpires *time.Time `json:"-" db:"status_expires_at"`
+    AvatarID      *uuid.UUID `json:"-" db:"avatar_id"`
+    LastSeenAt    *time.Time `json:"last_seen_at,omitempty" db:"last_seen_at"`
+    CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+}
+
+// CustomStatus is a short text and emoji shown next to a user's name
+type CustomStatus struct {
+    Text      string     `json:"text,omitempty"`
+    Emoji     string     `json:"emoji,omitempty"`
+    ExpiresAt *time.Time `json:"expires_at,omitempty"` // cleared when nil
+}
+
+// Profile is what other users see of someone in rooms
+type Profile struct {
+    ID          uuid.UUID     `json:"id"`
+    Username    string        `json:"username"`
+    DisplayName string        `json:"display_name,omitempty"`
+    AvatarURL   string        `json:"avatar_url,omitempty"`
+    Bio         string        `json:"bio,omitempty"`
+    TimeZone    string        `json:"time_zone,omitempty"`
+    Pronouns    string        `json:"pronouns,omitempty"`
+    Status      *CustomStatus `json:"custom_status,omitempty"`
+    IsBot       bool          `json:"is_bot"`
+}
+
+// CustomStatus returns the user's custom status, or nil if they have none or it expired
+func (u User) CustomStatus() *CustomStatus {
+    if u.StatusText == "" && u.StatusEmoji == "" {
+        return nil
+    }
+    if u.StatusExpires != nil && !u.StatusExpires.After(time.Now()) {
+        return nil
+    }
+    return &CustomStatus{Text: u.StatusText, Emoji: u.StatusEmoji, ExpiresAt: u.StatusExpires}
+}
+
+// AvatarURL returns the path the user's avatar is served at, or "" if they have none
+func (u User) AvatarURL() string {
+    if u.AvatarID == nil {
+        return ""
+    }
+    return "/api/v1/avatars/" + u.AvatarID.String()
+}
+
+// Profile returns the user's public profile
+func (u User) Profile() Profile {
+    return Profile{
+        ID:          u.ID,
+        Username:    u.Username,
+        DisplayName: u.DisplayName,
+        AvatarURL:   u.AvatarURL(),
+        Bio:         u.Bio,
+        TimeZone:    u.TimeZone,
+        Pronouns:    u.Pronouns,
+        Status:      u.CustomStatus(),
+        IsBot:       u.IsBot,
+    }
+}
+
+// MarshalJSON adds the avatar URL and the custom status while it lasts
+func (u User) MarshalJSON() ([]byte, error) {
+    type user User // without this method
+    return json.Marshal(struct {
+        user
+        AvatarURL string        `json:"avatar_url,omitempty"`
+        Status    *CustomStatus `json:"custom_status,omitempty"`
+    }{user(u), u.AvatarURL(), u.CustomStatus()})
+}
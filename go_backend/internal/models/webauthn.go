@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Passkey ceremonies
+const (
+	PasskeyCeremonyRegistration = "registration"
+	PasskeyCeremonyLogin        = "login"
+)
+
+// Passkey is a WebAuthn credential registered to a user. Its private key
+// never leaves the authenticator.
+type Passkey struct {
+	ID              uuid.UUID  `json:"id" db:"id"`
+	UserID          uuid.UUID  `json:"-" db:"user_id"`
+	CredentialID    []byte     `json:"-" db:"credential_id"`
+	Name            string     `json:"name" db:"name"`
+	PublicKey       []byte     `json:"-" db:"public_key"` // COSE encoded
+	AttestationType string     `json:"-" db:"attestation_type"`
+	Transports      string     `json:"-" db:"transports"` // comma-separated
+	AAGUID          []byte     `json:"-" db:"aaguid"`
+	Flags           uint8      `json:"-" db:"flags"`
+	SignCount       uint32     `json:"sign_count" db:"sign_count"`
+	CloneWarning    bool       `json:"clone_warning" db:"clone_warning"` // the sign count went backwards once
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	LastUsedAt      *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+}
+
+// PasskeySession is a registration or login ceremony in progress. Data is
+// the relying party's ceremony state, which must not reach the client.
+type PasskeySession struct {
+	ID        uuid.UUID  `db:"id"`
+	Ceremony  string     `db:"ceremony"`
+	UserID    *uuid.UUID `db:"user_id"` // registering user, nil for logins
+	Data      string     `db:"data"`
+	ExpiresAt time.Time  `db:"expires_at"`
+}
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type WSMessage struct {
 	Type    string      `json:"type"`    // "message", "join", "leave", "typing"
 	RoomID  string      `json:"room_id"`
@@ -9,10 +11,14 @@ type WSMessage struct {
 }
 
 type WSMessageResponse struct {
-	Type      string    `json:"type"`
-	Message   *Message  `json:"message,omitempty"`
-	UserID    string    `json:"user_id,omitempty"`
-	Username  string    `json:"username,omitempty"`
-	RoomID    string    `json:"room_id,omitempty"`
-	Timestamp string    `json:"timestamp,omitempty"`
+	Type      string     `json:"type"`
+	Message   *Message   `json:"message,omitempty"`
+	UserID    string     `json:"user_id,omitempty"`
+	Username  string     `json:"username,omitempty"`
+	RoomID    string     `json:"room_id,omitempty"`
+	Timestamp string     `json:"timestamp,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	Reason    string     `json:"reason,omitempty"`     // For moderation events
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // For moderation events
+	Profile   *Profile   `json:"profile,omitempty"`    // For profile events
 }
@@ -0,0 +1,138 @@
+// Package password hashes passwords with Argon2id, checks hashes made by
+// older algorithms or parameters, and decides which new passwords to accept.
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Params sets the cost of Argon2id hashes
+type Params struct {
+	Memory      int // KiB
+	Iterations  int
+	Parallelism int // threads, at most 255
+}
+
+// valid reports whether argon2 accepts the parameters
+func (p Params) valid() bool {
+	return p.Iterations >= 1 && p.Parallelism >= 1 && p.Parallelism <= 255 &&
+		p.Memory >= 8*p.Parallelism && int64(p.Memory) <= math.MaxUint32 && int64(p.Iterations) <= math.MaxUint32
+}
+
+// key derives the Argon2id key of password
+func (p Params) key(password string, salt []byte, length int) []byte {
+	return argon2.IDKey([]byte(password), salt, uint32(p.Iterations), uint32(p.Memory), uint8(p.Parallelism), uint32(length))
+}
+
+const (
+	saltLength = 16
+	keyLength  = 32
+)
+
+// Hasher hashes new passwords with Argon2id and checks them against stored
+// hashes, which may also be bcrypt hashes from before Argon2id was used
+type Hasher struct {
+	params Params
+	dummy  func() string
+}
+
+func NewHasher(params Params) (*Hasher, error) {
+	if !params.valid() {
+		return nil, errors.New("invalid Argon2id parameters")
+	}
+	h := &Hasher{params: params}
+	h.dummy = sync.OnceValue(func() string {
+		hash, _ := h.Hash("not a real password")
+		return hash
+	})
+	return h, nil
+}
+
+// Hash returns the Argon2id hash of password in the PHC string format, like
+// $argon2id$v=19$m=19456,t=2,p=1$salt$key
+func (h *Hasher) Hash(password string) (string, error) {
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := h.params.key(password, salt, keyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.params.Memory, h.params.Iterations, h.params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Verify reports whether password matches hash. An empty or unrecognised
+// hash matches no password.
+func (h *Hasher) Verify(hash, password string) bool {
+	if isBcrypt(hash) {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	}
+
+	params, salt, key, err := parseArgon2id(hash)
+	if err != nil {
+		return false
+	}
+	computed := params.key(password, salt, len(key))
+	return subtle.ConstantTimeCompare(computed, key) == 1
+}
+
+// VerifyDummy spends as long as checking a password against a real hash, so
+// that logins to accounts that do not exist cannot be told apart by timing
+func (h *Hasher) VerifyDummy(password string) {
+	h.Verify(h.dummy(), password)
+}
+
+// NeedsRehash reports whether hash was made by another algorithm or with
+// other parameters than new hashes are. Empty hashes never need one.
+func (h *Hasher) NeedsRehash(hash string) bool {
+	if hash == "" {
+		return false
+	}
+	params, _, key, err := parseArgon2id(hash)
+	return err != nil || params != h.params || len(key) != keyLength
+}
+
+func isBcrypt(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// parseArgon2id reads a hash made by Hash
+func parseArgon2id(hash string) (Params, []byte, []byte, error) {
+	var params Params
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" {
+		return params, nil, nil, errors.New("not an Argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, errors.New("unsupported Argon2 version")
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, errors.New("invalid Argon2id parameters")
+	}
+	if !params.valid() {
+		return params, nil, nil, errors.New("invalid Argon2id parameters")
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, errors.New("invalid Argon2id salt")
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, errors.New("invalid Argon2id key")
+	}
+	return params, salt, key, nil
+}
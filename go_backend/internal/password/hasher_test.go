@@ -0,0 +1,124 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// cheap are the lowest parameters Argon2id allows, to keep tests fast
+var cheap = Params{Memory: 8, Iterations: 1, Parallelism: 1}
+
+func newTestHasher(t *testing.T, params Params) *Hasher {
+	t.Helper()
+	h, err := NewHasher(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestHashRoundTrip(t *testing.T) {
+	h := newTestHasher(t, cheap)
+
+	hash, err := h.Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=8,t=1,p=1$") {
+		t.Errorf("got %q, want an Argon2id PHC string with the hasher's parameters", hash)
+	}
+	if !h.Verify(hash, "correct horse") {
+		t.Error("the password does not match its own hash")
+	}
+	if h.Verify(hash, "correct horse ") || h.Verify(hash, "") {
+		t.Error("another password matches the hash")
+	}
+	if h.NeedsRehash(hash) {
+		t.Error("a fresh hash needs a rehash")
+	}
+
+	again, err := h.Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == hash {
+		t.Error("two hashes of the same password are equal; the salt is not random")
+	}
+}
+
+func TestVerifyBcrypt(t *testing.T) {
+	h := newTestHasher(t, cheap)
+	legacy, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !h.Verify(string(legacy), "correct horse") {
+		t.Error("the password does not match its bcrypt hash")
+	}
+	if h.Verify(string(legacy), "wrong") {
+		t.Error("a wrong password matches the bcrypt hash")
+	}
+	if !h.NeedsRehash(string(legacy)) {
+		t.Error("a bcrypt hash does not need a rehash")
+	}
+}
+
+// Hashes made with other parameters still verify, and are upgraded
+func TestNeedsRehashOnParameterChange(t *testing.T) {
+	old := newTestHasher(t, cheap)
+	hash, err := old.Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := newTestHasher(t, Params{Memory: 16, Iterations: 2, Parallelism: 1})
+	if !h.Verify(hash, "correct horse") {
+		t.Error("a hash made with other parameters no longer verifies")
+	}
+	if !h.NeedsRehash(hash) {
+		t.Error("a hash made with other parameters does not need a rehash")
+	}
+	if h.NeedsRehash("") {
+		t.Error("an empty hash needs a rehash")
+	}
+}
+
+func TestVerifyRejectsMalformedHashes(t *testing.T) {
+	h := newTestHasher(t, cheap)
+	hash, err := h.Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(hash, "$")
+
+	malformed := map[string]string{
+		"empty":          "",
+		"plain text":     "correct horse",
+		"other version":  strings.Replace(hash, "v=19", "v=16", 1),
+		"bad parameters": strings.Replace(hash, "m=8,t=1,p=1", "m=8,t=0,p=1", 1),
+		"bad salt":       strings.Join([]string{"", parts[1], parts[2], parts[3], "!!", parts[5]}, "$"),
+		"no key":         strings.Join([]string{"", parts[1], parts[2], parts[3], parts[4], ""}, "$"),
+		"argon2i":        strings.Replace(hash, "$argon2id$", "$argon2i$", 1),
+	}
+	for name, hash := range malformed {
+		if h.Verify(hash, "correct horse") {
+			t.Errorf("%s: the password matches", name)
+		}
+	}
+}
+
+func TestNewHasherRejectsInvalidParams(t *testing.T) {
+	for _, params := range []Params{
+		{Memory: 8, Iterations: 0, Parallelism: 1},
+		{Memory: 8, Iterations: 1, Parallelism: 0},
+		{Memory: 8, Iterations: 1, Parallelism: 256},
+		{Memory: 7, Iterations: 1, Parallelism: 1},
+	} {
+		if _, err := NewHasher(params); err == nil {
+			t.Errorf("%+v: got a hasher, want an error", params)
+		}
+	}
+}
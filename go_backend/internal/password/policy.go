@@ -0,0 +1,97 @@
+package password
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Longest password accepted, in characters
+const maxLength = 256
+
+// Usernames and email local parts shorter than this may appear in passwords
+const minIdentifierLength = 3
+
+//go:embed common.txt
+var commonPasswords string
+
+// Policy decides which new passwords are accepted. Passwords already set
+// keep working when the policy gets stricter.
+type Policy struct {
+	minLength int
+	denylist  map[string]struct{}
+}
+
+// NewPolicy refuses passwords shorter than minLength characters and the
+// common passwords built in, plus those listed in denylistFile when given,
+// one per line
+func NewPolicy(minLength int, denylistFile string) (*Policy, error) {
+	if minLength < 1 || minLength > maxLength {
+		return nil, errors.New("minimum password length must be between 1 and " + strconv.Itoa(maxLength))
+	}
+
+	p := &Policy{minLength: minLength, denylist: make(map[string]struct{})}
+	if err := p.deny(strings.NewReader(commonPasswords)); err != nil {
+		return nil, err
+	}
+	if denylistFile != "" {
+		f, err := os.Open(denylistFile)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		if err := p.deny(f); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// MinLength is the length new passwords must have at least
+func (p *Policy) MinLength() int {
+	return p.minLength
+}
+
+// Check returns why password cannot be set for the account with the given
+// username and email, or nil if it can
+func (p *Policy) Check(password, username, email string) error {
+	length := utf8.RuneCountInString(password)
+	if length < p.minLength {
+		return errors.New("password must be at least " + strconv.Itoa(p.minLength) + " characters")
+	}
+	if length > maxLength {
+		return errors.New("password must be at most " + strconv.Itoa(maxLength) + " characters")
+	}
+
+	lower := strings.ToLower(password)
+	if _, ok := p.denylist[lower]; ok {
+		return errors.New("password is too common")
+	}
+
+	localPart, _, _ := strings.Cut(email, "@")
+	for _, identifier := range []string{username, email, localPart} {
+		identifier = strings.ToLower(identifier)
+		if utf8.RuneCountInString(identifier) >= minIdentifierLength && strings.Contains(lower, identifier) {
+			return errors.New("password must not contain your username or email")
+		}
+	}
+	return nil
+}
+
+// deny adds the passwords listed in r, skipping blank lines and # comments
+func (p *Policy) deny(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p.denylist[strings.ToLower(line)] = struct{}{}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,83 @@
+package password
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	p, err := NewPolicy(10, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Check("correct horse battery", "alice", "alice@example.com"); err != nil {
+		t.Errorf("a good password was refused: %v", err)
+	}
+
+	refused := map[string]string{
+		"too short":        "short",
+		"too long":         strings.Repeat("x", maxLength+1),
+		"common":           "1234567890",
+		"common, any case": "QWERTYUIOP",
+		"username":         "my name is alice!",
+		"email":            "alice@example.com1",
+		"email local part": "ALICE-and-more",
+	}
+	for name, password := range refused {
+		if err := p.Check(password, "alice", "alice@example.com"); err == nil {
+			t.Errorf("%s: %q was accepted", name, password)
+		}
+	}
+
+	// Short identifiers are too likely to appear by chance to be refused
+	if err := p.Check("bobsleigh championship", "bo", "bo@example.com"); err != nil {
+		t.Errorf("a password containing a short username was refused: %v", err)
+	}
+}
+
+// Length counts characters, not bytes
+func TestPolicyCountsCharacters(t *testing.T) {
+	p, err := NewPolicy(4, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Check("ééé", "alice", "alice@example.com"); err == nil {
+		t.Error("a three character password was accepted")
+	}
+	if err := p.Check("éééé", "alice", "alice@example.com"); err != nil {
+		t.Errorf("a four character password was refused: %v", err)
+	}
+}
+
+func TestPolicyDenylistFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "denylist.txt")
+	if err := os.WriteFile(path, []byte("# company words\n\nAcmeRocks2024\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := NewPolicy(8, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Check("acmerocks2024", "alice", "alice@example.com"); err == nil {
+		t.Error("a password from the denylist file was accepted")
+	}
+	if err := p.Check("# company words", "alice", "alice@example.com"); err != nil {
+		t.Errorf("a comment line was treated as a password: %v", err)
+	}
+
+	if _, err := NewPolicy(8, filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("a missing denylist file was ignored")
+	}
+}
+
+func TestNewPolicyRejectsInvalidLength(t *testing.T) {
+	for _, minLength := range []int{0, maxLength + 1} {
+		if _, err := NewPolicy(minLength, ""); err == nil {
+			t.Errorf("minimum length %d was accepted", minLength)
+		}
+	}
+}
@@ -0,0 +1,814 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/database"
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// The conformance suite checks that every storage backend behaves the same.
+// Each test gets its own repositories, but the PostgreSQL backend shares one
+// database across tests, so tests only look at rows they created themselves.
+
+func TestMemoryRepositories(t *testing.T) {
+	testRepositories(t, func(t *testing.T) *Repositories {
+		return NewMemoryRepositories(NewMemoryStore())
+	})
+}
+
+// TestPostgresRepositories runs the suite against the database at
+// TEST_DATABASE_URL, migrating it first. Use a database you can throw away.
+func TestPostgresRepositories(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	database.RunMigrations(dsn)
+
+	db, err := sqlx.Connect("pgx", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	testRepositories(t, func(t *testing.T) *Repositories {
+		return NewPostgresRepositories(db)
+	})
+}
+
+// testRepositories runs the conformance suite on the repositories returned by newRepos
+func testRepositories(t *testing.T, newRepos func(t *testing.T) *Repositories) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, repos *Repositories)
+	}{
+		{"UserUniqueness", testUserUniqueness},
+		{"UserUpdates", testUserUpdates},
+		{"UserDeleteCascades", testUserDeleteCascades},
+		{"UserSearch", testUserSearch},
+		{"RoomCreateAddsCreator", testRoomCreateAddsCreator},
+		{"RoomOrdering", testRoomOrdering},
+		{"RoomMembers", testRoomMembers},
+		{"RoomDeleteCascades", testRoomDeleteCascades},
+		{"MessageOrderingAndPaging", testMessageOrderingAndPaging},
+		{"MessageSoftDelete", testMessageSoftDelete},
+		{"MessageBatch", testMessageBatch},
+		{"MessageDeactivatedAuthor", testMessageDeactivatedAuthor},
+		{"Moderation", testModeration},
+		{"Privacy", testPrivacy},
+		{"Identities", testIdentities},
+		{"TxCommit", testTxCommit},
+		{"TxRollback", testTxRollback},
+		{"TxRollbackDeletes", testTxRollbackDeletes},
+		{"TxNested", testTxNested},
+		{"TxPanic", testTxPanic},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newRepos(t))
+		})
+	}
+}
+
+// unique returns name with a random suffix, so tests sharing a database don't collide
+func unique(name string) string {
+	return name + "_" + strings.ReplaceAll(uuid.NewString()[:13], "-", "")
+}
+
+func mustRegister(t *testing.T, repos *Repositories, name string) *models.User {
+	t.Helper()
+
+	username := unique(name)
+	user, err := repos.Users.Register(context.Background(), username, username+"@example.com", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func mustCreateRoom(t *testing.T, repos *Repositories, creator *models.User) *models.Room {
+	t.Helper()
+
+	room := &models.Room{Name: unique("room"), RoomType: "public", CreatedBy: creator.ID}
+	if err := repos.Rooms.Create(context.Background(), room); err != nil {
+		t.Fatal(err)
+	}
+	return room
+}
+
+func mustPost(t *testing.T, repos *Repositories, room *models.Room, author *models.User, content string) *models.Message {
+	t.Helper()
+
+	msg := &models.Message{RoomID: room.ID, UserID: author.ID, Content: content, MessageType: "text"}
+	if err := repos.Messages.Create(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+// contents returns the contents of messages in order
+func contents(messages []models.Message) string {
+	names := make([]string, len(messages))
+	for i, m := range messages {
+		names[i] = m.Content
+	}
+	return strings.Join(names, ",")
+}
+
+// roomIndex returns the position of the room with id in rooms, or -1
+func roomIndex(rooms []models.Room, id uuid.UUID) int {
+	for i, room := range rooms {
+		if room.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func testUserUniqueness(t *testing.T, repos *Repositories) {
+	ctx := context.Background()
+	alice := mustRegister(t, repos, "alice")
+
+	if _, err := repos.Users.Register(ctx, alice.Username, unique("other")+"@example.com", "hash"); err == nil {
+		t.Error("registered a second user with the same username")
+	}
+	if _, err := repos.Users.Register(ctx, unique("other"), alice.Email, "hash"); err == nil {
+		t.Error("registered a second user with the same email")
+	}
+
+	for _, identifier := range []string{alice.Username, alice.Email} {
+		user, err := repos.Users.GetByIdentifier(ctx, identifier)
+		if err != nil {
+			t.Fatalf("GetByIdentifier(%q): %v", identifier, err)
+		}
+		if user.ID != alice.ID || user.Role != models.RoleUser || user.Status != models.UserStatusActive {
+			t.Errorf("GetByIdentifier(%q) = %+v, want alice as an active user", identifier, user)
+		}
+	}
+
+	if _, err := repos.Users.GetByID(ctx, uuid.New()); err == nil {
+		t.Error("GetByID found an unknown user")
+	}
+}
+
+func testUserUpdates(t *testing.T, repos *Repositories) {
+	ctx := context.Background()
+	alice := mustRegister(t, repos, "alice")
+
+	if err := repos.Users.SetRole(ctx, alice.ID, models.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if err := repos.Users.SetStatus(ctx, alice.ID, models.UserStatusSuspended); err != nil {
+		t.Fatal(err)
+	}
+	if err := repos.Users.SetDisplayName(ctx, alice.ID, "Alice A."); err != nil {
+		t.Fatal(err)
+	}
+	seen := time.Now().UTC().Truncate(time.Millisecond)
+	if err := repos.Users.SetLastSeen(ctx, alice.ID, seen); err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := repos.Users.GetByID(ctx, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Role != models.RoleAdmin || user.Status != models.UserStatusSuspended || user.DisplayName != "Alice A." {
+		t.Errorf("got %+v, want an admin named Alice A. who is suspended", user)
+	}
+	if user.LastSeenAt == nil || !user.LastSeenAt.Equal(seen) {
+		t.Errorf("got last seen %v, want %v", user.LastSeenAt, seen)
+	}
+
+	if err := repos.Users.SetRole(ctx, uuid.New(), models.RoleAdmin); err == nil {
+		t.Error("SetRole changed an unknown user")
+	}
+}
+
+func testUserDeleteCascades(t *testing.T, repos *Repositories) {
+	ctx := context.Background()
+	alice := mustRegister(t, repos, "alice")
+	bob := mustRegister(t, repos, "bob")
+	room := mustCreateRoom(t, repos, alice)
+	if err := repos.Rooms.AddMember(ctx, room.ID, bob.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repos.Users.Delete(ctx, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repos.Users.GetByID(ctx, alice.ID); err == nil {
+		t.Error("deleted user is still there")
+	}
+
+	// The room stays without its creator, and without their membership
+	if _, err := repos.Rooms.GetByID(ctx, room.ID); err != nil {
+		t.Fatalf("room of a deleted user is gone: %v", err)
+	}
+	members, err := repos.Rooms.GetMembers(ctx, room.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 1 || members[0].UserID != bob.ID {
+		t.Errorf("got members %+v, want only bob", members)
+	}
+
+	if err := repos.Users.Delete(ctx, alice.ID); err == nil {
+		t.Error("deleted a user twice")
+	}
+}
+
+func testUserSearch(t *testing.T, repos *Repositories) {
+	ctx := context.Background()
+	tag := unique("zq")
+	names := []string{tag + "_carol", tag + "_Bob", tag + "_alice"}
+	var users []*models.User
+	for _, name := range names {
+		user, err := repos.Users.Register(ctx, name, name+"@example.com", "hash")
+		if err != nil {
+			t.Fatal(err)
+		}
+		users = append(users, user)
+	}
+	if err := repos.Users.SetStatus(ctx, users[0].ID, models.UserStatusDeactivated); err != nil {
+		t.Fatal(err)
+	}
+
+	found, total, err := repos.Users.Search(ctx, strings.ToUpper(tag), 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(found) != 1 || found[0].ID != users[1].ID {
+		t.Errorf("got %d of %d users, want Bob, the second of 2", len(found), total)
+	}
+
+	// Wildcards in the query match only themselves
+	if _, total, err := repos.Users.Search(ctx, tag[:3]+"%", 10, 0); err != nil || total != 0 {
+		t.Errorf("wildcard search matched %d users (%v)", total, err)
+	}
+
+	// Emails are only searchable when shown to everyone
+	email := users[2].Email
+	if _, total, _ := repos.Users.Search(ctx, email, 10, 0); total != 0 {
+		t.Error("found a user by an email they hide")
+	}
+	settings := models.DefaultPrivacySettings(users[2].ID)
+	settings.Email = models.VisibilityEveryone
+	if err := repos.Privacy.Set(ctx, &settings); err != nil {
+		t.Fatal(err)
+	}
+	if found, total, _ := repos.Users.Search(ctx, email, 10, 0); total != 1 || found[0].ID != users[2].ID {
+		t.Error("did not find a user by an email they show to everyone")
+	}
+}
+
+func testRoomCreateAddsCreator(t *testing.T, repos *Repositories) {
+	ctx := context.Background()
+	alice := mustRegister(t, repos, "alice")
+	room := mustCreateRoom(t, repos, alice)
+
+	if room.ID == uuid.Nil || room.CreatedAt.IsZero() {
+		t.Errorf("Create did not fill in the ID and CreatedAt: %+v", room)
+	}
+	isMember, err := repos.Rooms.IsMember(ctx, room.ID, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isMember {
+		t.Error("the creator is not a member of the new room")
+	}
+
+	if err := repos.Rooms.Create(ctx, &models.Room{Name: unique("room"), RoomType: "public", CreatedBy: uuid.New()}); err == nil {
+		t.Error("created a room for an unknown user")
+	}
+}
+
+func testRoomOrdering(t *testing.T, repos *Repositories) {
+	ctx := context.Background()
+	alice := mustRegister(t, repos, "alice")
+	first := mustCreateRoom(t, repos, alice)
+	second := mustCreateRoom(t, repos, alice)
+
+	rooms, err := repos.Rooms.GetUserRooms(ctx, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rooms) != 2 || rooms[0].ID != second.ID || rooms[1].ID != first.ID {
+		t.Errorf("got rooms %+v, want the second room first", rooms)
+	}
+
+	all, err := repos.Rooms.GetAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i, j := roomIndex(all, second.ID), roomIndex(all, first.ID); i < 0 || j < 0 || i > j {
+		t.Errorf("GetAll lists the rooms at %d and %d, want the second room first", i, j)
+	}
+}
+
+func testRoomMembers(t *testing.T, repos *Repositories) {
+	ctx := context.Background()
+	alice := mustRegister(t, repos, "alice")
+	bob := mustRegister(t, repos, "bob")
+	carol := mustRegister(t, repos, "carol")
+	room := mustCreateRoom(t, repos, alice)
+
+	for i := 0; i < 2; i++ {
+		// Adding an existing member is a no-op
+		if err := repos.Rooms.AddMember(ctx, room.ID, bob.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repos.Rooms.AddMember(ctx, room.ID, uuid.New()); err == nil {
+		t.Error("added an unknown user")
+	}
+	if err := repos.Rooms.AddMember(ctx, uuid.New(), bob.ID); err == nil {
+		t.Error("added a user to an unknown room")
+	}
+
+	members, err := repos.Rooms.GetMembers(ctx, room.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 2 || members[0].UserID != alice.ID || members[1].UserID != bob.ID {
+		t.Errorf("got members %+v, want alice then bob", members)
+	}
+
+	roommates, err := repos.Rooms.GetRoommates(ctx, alice.ID, []uuid.UUID{bob.ID, carol.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roommates) != 1 || roommates[0] != bob.ID {
+		t.Errorf("got roommates %v, want only bob", roommates)
+	}
+
+	if err := repos.Rooms.RemoveMember(ctx, room.ID, bob.ID); err != nil {
+		t.Fatal(err)
+	}
+	if isMember, _ := repos.Rooms.IsMember(ctx, room.ID, bob.ID); isMember {
+		t.Error("removed member is still a member")
+	}
+	if err := repos.Rooms.RemoveMember(ctx, room.ID, bob.ID); err == nil {
+		t.Error("removed a member twice")
+	}
+}
+
+func testRoomDeleteCascades(t *testing.T, repos *Repositories) {
+	ctx := context.Background()
+	alice := mustRegister(t, repos, "alice")
+	bob := mustRegister(t, repos, "bob")
+	room := mustCreateRoom(t, repos, alice)
+	if err := repos.Rooms.AddMember(ctx, room.ID, bob.ID); err != nil {
+		t.Fatal(err)
+	}
+	msg := mustPost(t, repos, room, bob, "hello")
+	if err := repos.Moderation.Mute(ctx, &models.RoomMute{RoomID: room.ID, UserID: bob.ID, MutedBy: alice.ID}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repos.Rooms.Delete(ctx, room.ID, bob.ID); err == nil {
+		t.Fatal("a member who did not create the room deleted it")
+	}
+	if err := repos.Rooms.Delete(ctx, room.ID, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repos.Rooms.GetByID(ctx, room.ID); err == nil {
+		t.Error("deleted room is still there")
+	}
+	if members, _ := repos.Rooms.GetMembers(ctx, room.ID); len(members) != 0 {
+		t.Errorf("deleted room still has %d members", len(members))
+	}
+	if _, err := repos.Messages.GetByID(ctx, msg.ID); err == nil {
+		t.Error("message of a deleted room is still there")
+	}
+	if mute, _ := repos.Moderation.GetMute(ctx, room.ID, bob.ID); mute != nil {
+		t.Error("mute in a deleted room is still there")
+	}
+	if err := repos.Rooms.ForceDelete(ctx, room.ID); err == nil {
+		t.Error("deleted a room twice")
+	}
+}
+
+func testMessageOrderingAndPaging(t *testing.T, repos *Repositories) {
+	ctx := context.Background()
+	alice := mustRegister(t, repos, "alice")
+	room := mustCreateRoom(t, repos, alice)
+	for _, content := range []string{"1", "2", "3", "4"} {
+		msg := mustPost(t, repos, room, alice, content)
+		if msg.ID == uuid.Nil || msg.CreatedAt.IsZero() {
+			t.Fatalf("Create did not fill in the ID and CreatedAt: %+v", msg)
+		}
+	}
+
+	messages, err := repos.Messages.GetByRoom(ctx, room.ID, 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := contents(messages); got != "3,2" {
+		t.Errorf("got messages %s, want 3,2", got)
+	}
+	if messages[0].Username != alice.Username {
+		t.Errorf("got author %q, want %q", messages[0].Username, alice.Username)
+	}
+
+	if err := repos.Messages.Create(ctx, &models.Message{RoomID: uuid.New(), UserID: alice.ID, Content: "x", MessageType: "text"}); err == nil {
+		t.Error("stored a message in an unknown room")
+	}
+}
+
+func testMessageSoftDelete(t *testing.T, repos *Repositories) {
+	ctx := context.Background()
+	alice := mustRegister(t, repos, "alice")
+	room := mustCreateRoom(t, repos, alice)
+	mustPost(t, repos, room, alice, "kept")
+	deleted := mustPost(t, repos, room, alice, "deleted")
+
+	if err := repos.Messages.Delete(ctx, deleted.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repos.Messages.GetByID(ctx, deleted.ID); err == nil {
+		t.Error("GetByID returned a deleted message")
+	}
+	messages, err := repos.Messages.GetByRoom(ctx, room.ID, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := contents(messages); got != "kept" {
+		t.Errorf("got messages %s, want kept", got)
+	}
+	if err := repos.Messages.Delete(ctx, deleted.ID); err == nil {
+		t.Error("deleted a message twice")
+	}
+}
+
+func testMessageBatch(t *testing.T, repos *Repositories) {
+	ctx := context.Background()
+	alice := mustRegister(t, repos, "alice")
+	room := mustCreateRoom(t, repos, alice)
+
+	var batch []*models.Message
+	for _, content := range []string{"1", "2", "3"} {
+		batch = append(batch, &models.Message{RoomID: room.ID, UserID: alice.ID, Content: content, MessageType: "text"})
+	}
+	if err := repos.Messages.CreateBatch(ctx, batch); err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range batch {
+		if msg.ID == uuid.Nil || msg.CreatedAt.IsZero() {
+			t.Fatalf("CreateBatch did not fill in the ID and CreatedAt: %+v", msg)
+		}
+	}
+
+	// A batch with one bad message stores nothing
+	bad := []*models.Message{
+		{RoomID: room.ID, UserID: alice.ID, Content: "4", MessageType: "text"},
+		{RoomID: uuid.New(), UserID: alice.ID, Content: "5", MessageType: "text"},
+	}
+	if err := repos.Messages.CreateBatch(ctx, bad); err == nil {
+		t.Error("stored a batch with a message in an unknown room")
+	}
+
+	messages, err := repos.Messages.GetByRoom(ctx, room.ID, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := contents(messages); got != "3,2,1" {
+		t.Errorf("got messages %s, want 3,2,1", got)
+	}
+}
+
+func testMessageDeactivatedAuthor(t *testing.T, repos *Repositories) {
+	ctx := context.Background()
+	alice := mustRegister(t, repos, "alice")
+	room := mustCreateRoom(t, repos, alice)
+	msg := mustPost(t, repos, room, alice, "hello")
+
+	if err := repos.Users.SetStatus(ctx, alice.ID, models.UserStatusDeactivated); err != nil {
+		t.Fatal(err)
+	}
+	got, err := repos.Messages.GetByID(ctx, msg.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Username != models.DeactivatedUsername {
+		t.Errorf("got author %q, want %q", got.Username, models.DeactivatedUsername)
+	}
+}
+
+func testModeration(t *testing.T, repos *Repositories) {
+	ctx := context.Background()
+	alice := mustRegister(t, repos, "alice")
+	bob := mustRegister(t, repos, "bob")
+	room := mustCreateRoom(t, repos, alice)
+
+	ban := &models.RoomBan{RoomID: room.ID, UserID: bob.ID, BannedBy: alice.ID, Reason: "spam"}
+	if err := repos.Moderation.Ban(ctx, ban, 0); err != nil {
+		t.Fatal(err)
+	}
+	if ban.ExpiresAt != nil {
+		t.Errorf("permanent ban expires at %v", ban.ExpiresAt)
+	}
+	got, err := repos.Moderation.GetBan(ctx, room.ID, bob.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.Reason != "spam" {
+		t.Fatalf("got ban %+v, want the permanent ban", got)
+	}
+
+	if err := repos.Moderation.Unban(ctx, room.ID, bob.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := repos.Moderation.GetBan(ctx, room.ID, bob.ID); got != nil {
+		t.Error("ban is still active after Unban")
+	}
+	if err := repos.Moderation.Unban(ctx, room.ID, bob.ID); err == nil {
+		t.Error("unbanned a user who is not banned")
+	}
+
+	// A new mute replaces the old one
+	for _, d := range []time.Duration{time.Minute, time.Hour} {
+		if err := repos.Moderation.Mute(ctx, &models.RoomMute{RoomID: room.ID, UserID: bob.ID, MutedBy: alice.ID}, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mutes, err := repos.Moderation.GetMutes(ctx, room.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mutes) != 1 || time.Until(mutes[0].ExpiresAt) < 59*time.Minute {
+		t.Errorf("got mutes %+v, want one ending in an hour", mutes)
+	}
+}
+
+func testPrivacy(t *testing.T, repos *Repositories) {
+	ctx := context.Background()
+	alice := mustRegister(t, repos, "alice")
+	bob := mustRegister(t, repos, "bob")
+
+	settings, err := repos.Privacy.Get(ctx, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *settings != models.DefaultPrivacySettings(alice.ID) {
+		t.Errorf("got %+v, want the defaults", settings)
+	}
+
+	settings.Presence = models.VisibilityNobody
+	for i := 0; i < 2; i++ {
+		// Setting twice updates the stored row
+		if err := repos.Privacy.Set(ctx, settings); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all, err := repos.Privacy.GetMany(ctx, []uuid.UUID{alice.ID, bob.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all[alice.ID].Presence != models.VisibilityNobody || all[bob.ID].Presence != models.VisibilityEveryone {
+		t.Errorf("got presence %q for alice and %q for bob, want nobody and everyone", all[alice.ID].Presence, all[bob.ID].Presence)
+	}
+}
+
+func testIdentities(t *testing.T, repos *Repositories) {
+	ctx := context.Background()
+	alice := mustRegister(t, repos, "alice")
+	bob := mustRegister(t, repos, "bob")
+	issuer := unique("ldap")
+
+	for _, identity := range []*models.Identity{
+		{UserID: alice.ID, Issuer: issuer, Subject: "a"},
+		{UserID: bob.ID, Issuer: issuer, Subject: "b"},
+		{UserID: alice.ID, Issuer: unique("oidc"), Subject: "a"},
+	} {
+		if err := repos.Identities.Create(ctx, identity); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repos.Identities.Create(ctx, &models.Identity{UserID: bob.ID, Issuer: issuer, Subject: "a"}); err == nil {
+		t.Error("linked a subject twice")
+	}
+
+	identity, err := repos.Identities.GetBySubject(ctx, issuer, "b")
+	if err != nil || identity.UserID != bob.ID {
+		t.Errorf("got %+v, %v, want bob's identity", identity, err)
+	}
+	if byIssuer, err := repos.Identities.GetByIssuer(ctx, issuer); err != nil || len(byIssuer) != 2 {
+		t.Errorf("got %+v, %v, want both of the issuer's identities", byIssuer, err)
+	}
+
+	byUser, err := repos.Identities.GetByUser(ctx, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byUser) != 2 || byUser[0].Issuer != issuer {
+		t.Errorf("got %+v, want alice's two identities, oldest first", byUser)
+	}
+	if none, err := repos.Identities.GetByUser(ctx, uuid.New()); err != nil || len(none) != 0 {
+		t.Errorf("got %+v, %v for an unknown user, want none", none, err)
+	}
+}
+
+func testTxCommit(t *testing.T, repos *Repositories) {
+	ctx := context.Background()
+	alice := mustRegister(t, repos, "alice")
+
+	var room *models.Room
+	err := repos.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		room = mustCreateRoomCtx(t, ctx, repos, alice)
+		return repos.Messages.Create(ctx, &models.Message{RoomID: room.ID, UserID: alice.ID, Content: "hello", MessageType: "text"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages, err := repos.Messages.GetByRoom(ctx, room.ID, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := contents(messages); got != "hello" {
+		t.Errorf("got messages %q, want hello", got)
+	}
+}
+
+func testTxRollback(t *testing.T, repos *Repositories) {
+	ctx := context.Background()
+	alice := mustRegister(t, repos, "alice")
+	room := mustCreateRoom(t, repos, alice)
+	kept := mustPost(t, repos, room, alice, "kept")
+
+	failure := errors.New("failure")
+	var bob *models.User
+	var newRoom *models.Room
+	err := repos.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		if bob, err = repos.Users.Register(ctx, unique("bob"), unique("bob")+"@example.com", "hash"); err != nil {
+			return err
+		}
+		newRoom = mustCreateRoomCtx(t, ctx, repos, bob)
+		if err := repos.Rooms.AddMember(ctx, room.ID, bob.ID); err != nil {
+			return err
+		}
+		if err := repos.Users.SetRole(ctx, alice.ID, models.RoleAdmin); err != nil {
+			return err
+		}
+		if err := repos.Messages.Delete(ctx, kept.ID); err != nil {
+			return err
+		}
+		if err := repos.Messages.Create(ctx, &models.Message{RoomID: room.ID, UserID: bob.ID, Content: "gone", MessageType: "text"}); err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("got error %v, want %v", err, failure)
+	}
+
+	if _, err := repos.Users.GetByID(ctx, bob.ID); err == nil {
+		t.Error("user created in a failed transaction is there")
+	}
+	if _, err := repos.Rooms.GetByID(ctx, newRoom.ID); err == nil {
+		t.Error("room created in a failed transaction is there")
+	}
+	if user, _ := repos.Users.GetByID(ctx, alice.ID); user == nil || user.Role != models.RoleUser {
+		t.Error("role change of a failed transaction was kept")
+	}
+	messages, err := repos.Messages.GetByRoom(ctx, room.ID, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := contents(messages); got != "kept" {
+		t.Errorf("got messages %q, want only kept", got)
+	}
+	members, err := repos.Rooms.GetMembers(ctx, room.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 1 {
+		t.Errorf("got %d members, want only the creator", len(members))
+	}
+}
+
+// Deletes cascade to other rows, and a failed transaction puts all of them back
+func testTxRollbackDeletes(t *testing.T, repos *Repositories) {
+	ctx := context.Background()
+	alice := mustRegister(t, repos, "alice")
+	bob := mustRegister(t, repos, "bob")
+	carol := mustRegister(t, repos, "carol")
+	room := mustCreateRoom(t, repos, alice)
+	if err := repos.Rooms.AddMember(ctx, room.ID, bob.ID); err != nil {
+		t.Fatal(err)
+	}
+	mustPost(t, repos, room, bob, "hi")
+	if err := repos.Moderation.Ban(ctx, &models.RoomBan{RoomID: room.ID, UserID: carol.ID, BannedBy: alice.ID}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	failure := errors.New("failure")
+	err := repos.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		if err := repos.Users.Delete(ctx, bob.ID); err != nil {
+			return err
+		}
+		if err := repos.Rooms.ForceDelete(ctx, room.ID); err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("got error %v, want %v", err, failure)
+	}
+
+	if _, err := repos.Users.GetByID(ctx, bob.ID); err != nil {
+		t.Errorf("user deleted in a failed transaction is gone: %v", err)
+	}
+	if _, err := repos.Rooms.GetByID(ctx, room.ID); err != nil {
+		t.Errorf("room deleted in a failed transaction is gone: %v", err)
+	}
+	members, err := repos.Rooms.GetMembers(ctx, room.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 2 {
+		t.Errorf("got %d members, want 2", len(members))
+	}
+	messages, err := repos.Messages.GetByRoom(ctx, room.ID, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 || messages[0].UserID != bob.ID {
+		t.Errorf("got messages %+v, want bob's message", messages)
+	}
+	if ban, err := repos.Moderation.GetBan(ctx, room.ID, carol.ID); err != nil || ban == nil || ban.BannedBy != alice.ID {
+		t.Errorf("got ban %+v, %v, want carol's ban by alice", ban, err)
+	}
+}
+
+func testTxNested(t *testing.T, repos *Repositories) {
+	ctx := context.Background()
+	alice := mustRegister(t, repos, "alice")
+
+	failure := errors.New("failure")
+	var room *models.Room
+	err := repos.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		err := repos.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+			room = mustCreateRoomCtx(t, ctx, repos, alice)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("got error %v, want %v", err, failure)
+	}
+
+	if _, err := repos.Rooms.GetByID(ctx, room.ID); err == nil {
+		t.Error("the inner call committed on its own")
+	}
+}
+
+func testTxPanic(t *testing.T, repos *Repositories) {
+	ctx := context.Background()
+	alice := mustRegister(t, repos, "alice")
+
+	var room *models.Room
+	func() {
+		defer func() {
+			if p := recover(); p != "boom" {
+				t.Errorf("got panic %v, want boom", p)
+			}
+		}()
+
+		repos.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+			room = mustCreateRoomCtx(t, ctx, repos, alice)
+			panic("boom")
+		})
+	}()
+
+	if _, err := repos.Rooms.GetByID(ctx, room.ID); err == nil {
+		t.Error("room created before the panic is there")
+	}
+	// The store is usable after the panic
+	mustCreateRoom(t, repos, alice)
+}
+
+func mustCreateRoomCtx(t *testing.T, ctx context.Context, repos *Repositories, creator *models.User) *models.Room {
+	t.Helper()
+
+	room := &models.Room{Name: unique("room"), RoomType: "public", CreatedBy: creator.ID}
+	if err := repos.Rooms.Create(ctx, room); err != nil {
+		t.Fatal(err)
+	}
+	return room
+}
@@ -0,0 +1,321 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+)
+
+// MemoryStore is a thread-safe in-memory storage backend for development and
+// tests. It mirrors the PostgreSQL schema: unique usernames and emails,
+// cascade deletes from rooms, newest-first listings and soft-deleted messages.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	seq      int64
+	users    map[uuid.UUID]*memUser
+	rooms    map[uuid.UUID]*memRoom
+	members  map[uuid.UUID]map[uuid.UUID]*memMember // roomID -> userID -> member
+	messages map[uuid.UUID]*memMessage
+	bans     map[memRoomUser]*models.RoomBan
+	mutes    map[memRoomUser]*models.RoomMute
+	reports  map[uuid.UUID]*memReport
+	words    map[uuid.UUID]map[string]*models.WordFilter // roomID -> word -> filter
+	// reportActions holds each report's audit trail in insertion order
+	reportActions map[uuid.UUID][]models.ReportAction
+	// auditLog holds the audit log in Seq order
+	auditLog []models.AuditEvent
+	totp     map[uuid.UUID]*models.TOTP
+	// recoveryCodes holds each user's recovery code hashes
+	recoveryCodes map[uuid.UUID]map[string]*memRecoveryCode
+	settings      map[string]string
+	passkeys      map[uuid.UUID]*memPasskey
+	// passkeySessions holds the passkey ceremonies in progress
+	passkeySessions map[uuid.UUID]*models.PasskeySession
+	identities      map[uuid.UUID]*models.Identity
+	// oidcLogins holds the OpenID Connect logins in progress by state
+	oidcLogins map[string]*models.OIDCLogin
+	// samlRequests holds the SAML requests awaiting a response by relay state
+	samlRequests map[string]*models.SAMLRequest
+	accessTokens map[uuid.UUID]*memAccessToken
+	oauthApps    map[uuid.UUID]*memOAuthApp
+	// oauthCodes holds the authorization codes waiting to be exchanged by hash
+	oauthCodes    map[string]*models.OAuthCode
+	oauthConsents map[memUserApp]*memOAuthConsent
+	oauthTokens   map[uuid.UUID]*models.OAuthToken
+	// loginLinks holds the login links emailed to users by token hash
+	loginLinks map[string]*models.LoginLink
+	// avatars holds the users' uploaded avatars by ID
+	avatars map[uuid.UUID]*models.Avatar
+	// privacy holds the privacy settings users changed, by user ID
+	privacy map[uuid.UUID]*models.PrivacySettings
+
+	// logging is set while a transaction runs, and undo then holds the steps
+	// that revert its changes in the order they were made
+	logging bool
+	undo    []func()
+}
+
+type memRoomUser struct {
+	roomID uuid.UUID
+	userID uuid.UUID
+}
+
+type memUserApp struct {
+	userID uuid.UUID
+	appID  uuid.UUID
+}
+
+// Every row carries an insertion sequence number so rows created within the
+// same clock tick still sort deterministically.
+type memUser struct {
+	user models.User
+	seq  int64
+}
+
+type memRoom struct {
+	room models.Room
+	seq  int64
+}
+
+type memMember struct {
+	member models.RoomMember
+	seq    int64
+}
+
+type memMessage struct {
+	message   models.Message
+	isDeleted bool
+	seq       int64
+}
+
+type memReport struct {
+	report models.Report
+	seq    int64
+}
+
+type memRecoveryCode struct {
+	usedAt *time.Time
+}
+
+type memPasskey struct {
+	passkey models.Passkey
+	seq     int64
+}
+
+type memAccessToken struct {
+	token models.AccessToken
+	seq   int64
+}
+
+type memOAuthApp struct {
+	app models.OAuthApp
+	seq int64
+}
+
+type memOAuthConsent struct {
+	consent models.OAuthConsent
+	seq     int64
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users:    make(map[uuid.UUID]*memUser),
+		rooms:    make(map[uuid.UUID]*memRoom),
+		members:  make(map[uuid.UUID]map[uuid.UUID]*memMember),
+		messages: make(map[uuid.UUID]*memMessage),
+		bans:     make(map[memRoomUser]*models.RoomBan),
+		mutes:    make(map[memRoomUser]*models.RoomMute),
+		reports:  make(map[uuid.UUID]*memReport),
+		words:    make(map[uuid.UUID]map[string]*models.WordFilter),
+
+		reportActions: make(map[uuid.UUID][]models.ReportAction),
+		totp:          make(map[uuid.UUID]*models.TOTP),
+		recoveryCodes: make(map[uuid.UUID]map[string]*memRecoveryCode),
+		settings:      make(map[string]string),
+		passkeys:      make(map[uuid.UUID]*memPasskey),
+
+		passkeySessions: make(map[uuid.UUID]*models.PasskeySession),
+		identities:      make(map[uuid.UUID]*models.Identity),
+		oidcLogins:      make(map[string]*models.OIDCLogin),
+		samlRequests:    make(map[string]*models.SAMLRequest),
+		accessTokens:    make(map[uuid.UUID]*memAccessToken),
+
+		oauthApps:     make(map[uuid.UUID]*memOAuthApp),
+		oauthCodes:    make(map[string]*models.OAuthCode),
+		oauthConsents: make(map[memUserApp]*memOAuthConsent),
+		oauthTokens:   make(map[uuid.UUID]*models.OAuthToken),
+		loginLinks:    make(map[string]*models.LoginLink),
+		avatars:       make(map[uuid.UUID]*models.Avatar),
+		privacy:       make(map[uuid.UUID]*models.PrivacySettings),
+	}
+}
+
+// NewMemoryRepositories wires in-memory repositories sharing a single store
+func NewMemoryRepositories(store *MemoryStore) *Repositories {
+	return &Repositories{
+		Users:        &MemoryUserRepository{store: store},
+		Rooms:        &MemoryRoomRepository{store: store},
+		Messages:     &MemoryMessageRepository{store: store},
+		Moderation:   &MemoryModerationRepository{store: store},
+		Reports:      &MemoryReportRepository{store: store},
+		Filters:      &MemoryFilterRepository{store: store},
+		Stats:        &MemoryStatsRepository{store: store},
+		Audit:        &MemoryAuditRepository{store: store},
+		TwoFactor:    &MemoryTwoFactorRepository{store: store},
+		Settings:     &MemorySettingsRepository{store: store},
+		Passkeys:     &MemoryPasskeyRepository{store: store},
+		Identities:   &MemoryIdentityRepository{store: store},
+		AccessTokens: &MemoryAccessTokenRepository{store: store},
+		OAuth:        &MemoryOAuthRepository{store: store},
+		LoginLinks:   &MemoryLoginLinkRepository{store: store},
+		Avatars:      &MemoryAvatarRepository{store: store},
+		Privacy:      &MemoryPrivacyRepository{store: store},
+		TxManager:    &MemoryTxManager{store: store},
+	}
+}
+
+type memTxKey struct{}
+
+// inTx reports whether ctx belongs to a transaction on this store, in which
+// case the store lock is already held by the caller
+func (s *MemoryStore) inTx(ctx context.Context) bool {
+	owner, _ := ctx.Value(memTxKey{}).(*MemoryStore)
+	return owner == s
+}
+
+// lock takes the write lock unless ctx already holds it through a transaction
+func (s *MemoryStore) lock(ctx context.Context) func() {
+	if s.inTx(ctx) {
+		return func() {}
+	}
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+// rlock takes the read lock unless ctx already holds the write lock through a transaction
+func (s *MemoryStore) rlock(ctx context.Context) func() {
+	if s.inTx(ctx) {
+		return func() {}
+	}
+	s.mu.RLock()
+	return s.mu.RUnlock
+}
+
+// next returns the next insertion sequence number and timestamp. Callers must hold the write lock.
+func (s *MemoryStore) next() (int64, time.Time) {
+	s.seq++
+	return s.seq, time.Now().UTC()
+}
+
+// saveRow records how to put back the row under key in m, or remove it if
+// there is none, should the running transaction fail. Call it before adding,
+// changing or deleting the row. Callers must hold the write lock.
+func saveRow[K comparable, V any](s *MemoryStore, m map[K]*V, key K) {
+	if !s.logging {
+		return
+	}
+	if old, ok := m[key]; ok {
+		saved := *old
+		s.undo = append(s.undo, func() {
+			*old = saved
+			m[key] = old
+		})
+	} else {
+		s.undo = append(s.undo, func() { delete(m, key) })
+	}
+}
+
+// saveEntry is saveRow for maps holding values rather than rows, such as the
+// per-room member maps. The value itself must not be changed in place.
+func saveEntry[K comparable, V any](s *MemoryStore, m map[K]V, key K) {
+	if !s.logging {
+		return
+	}
+	if old, ok := m[key]; ok {
+		s.undo = append(s.undo, func() { m[key] = old })
+	} else {
+		s.undo = append(s.undo, func() { delete(m, key) })
+	}
+}
+
+// deleteRow deletes the row under key in m, recording it for the running transaction
+func deleteRow[K comparable, V any](s *MemoryStore, m map[K]*V, key K) {
+	saveRow(s, m, key)
+	delete(m, key)
+}
+
+// rollback reverts the changes of the running transaction, newest first.
+// Callers must hold the write lock.
+func (s *MemoryStore) rollback() {
+	for i := len(s.undo) - 1; i >= 0; i-- {
+		s.undo[i]()
+	}
+	s.undo = nil
+}
+
+// MemoryTxManager runs groups of repository calls atomically against a MemoryStore.
+// A transaction holds the store's write lock for its whole duration. The rows
+// it changes are recorded as it goes and put back if it fails, so its cost
+// depends on what it changes rather than on the size of the store.
+type MemoryTxManager struct {
+	store *MemoryStore
+}
+
+func (m *MemoryTxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if m.store.inTx(ctx) {
+		return fn(ctx)
+	}
+
+	ctx, hooks := beginHooks(ctx)
+	err := m.run(ctx, fn)
+	hooks.run(err == nil)
+	return err
+}
+
+// run runs fn in a transaction holding the store's write lock
+func (m *MemoryTxManager) run(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	s := m.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.logging = true
+	defer func() {
+		s.logging = false
+		s.undo = nil
+	}()
+	defer func() {
+		if p := recover(); p != nil {
+			s.rollback()
+			panic(p)
+		}
+	}()
+
+	if err = fn(context.WithValue(ctx, memTxKey{}, s)); err != nil {
+		s.rollback()
+		return err
+	}
+	return nil
+}
+
+var (
+	_ UserRepository       = (*MemoryUserRepository)(nil)
+	_ RoomRepository       = (*MemoryRoomRepository)(nil)
+	_ MessageRepository    = (*MemoryMessageRepository)(nil)
+	_ ModerationRepository = (*MemoryModerationRepository)(nil)
+	_ ReportRepository     = (*MemoryReportRepository)(nil)
+	_ FilterRepository     = (*MemoryFilterRepository)(nil)
+	_ StatsRepository      = (*MemoryStatsRepository)(nil)
+	_ AuditRepository      = (*MemoryAuditRepository)(nil)
+	_ TwoFactorRepository  = (*MemoryTwoFactorRepository)(nil)
+	_ SettingsRepository   = (*MemorySettingsRepository)(nil)
+	_ PasskeyRepository    = (*MemoryPasskeyRepository)(nil)
+	_ IdentityRepository   = (*MemoryIdentityRepository)(nil)
+	_ OAuthRepository      = (*MemoryOAuthRepository)(nil)
+	_ LoginLinkRepository  = (*MemoryLoginLinkRepository)(nil)
+	_ AvatarRepository     = (*MemoryAvatarRepository)(nil)
+	_ PrivacyRepository    = (*MemoryPrivacyRepository)(nil)
+	_ TxManager            = (*MemoryTxManager)(nil)
+)
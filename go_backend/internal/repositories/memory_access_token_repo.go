@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+)
+
+type MemoryAccessTokenRepository struct {
+	store *MemoryStore
+}
+
+// Create stores a new token
+func (r *MemoryAccessTokenRepository) Create(ctx context.Context, token *models.AccessToken) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	if _, ok := s.users[token.UserID]; !ok {
+		return errors.New("user not found")
+	}
+
+	seq, now := s.next()
+	token.ID = uuid.New()
+	token.CreatedAt = now
+	saveRow(s, s.accessTokens, token.ID)
+	s.accessTokens[token.ID] = &memAccessToken{token: *token, seq: seq}
+	return nil
+}
+
+// GetByUser returns the user's tokens, oldest first
+func (r *MemoryAccessTokenRepository) GetByUser(ctx context.Context, userID uuid.UUID) ([]models.AccessToken, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	var found []*memAccessToken
+	for _, t := range s.accessTokens {
+		if t.token.UserID == userID {
+			found = append(found, t)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].seq < found[j].seq })
+
+	tokens := make([]models.AccessToken, len(found))
+	for i, t := range found {
+		tokens[i] = t.token
+	}
+	return tokens, nil
+}
+
+// GetByHash returns the token with the given hash
+func (r *MemoryAccessTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*models.AccessToken, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	for _, t := range s.accessTokens {
+		if t.token.TokenHash == tokenHash {
+			token := t.token
+			return &token, nil
+		}
+	}
+	return nil, errors.New("token not found")
+}
+
+// RecordUse stores the time the token was last used
+func (r *MemoryAccessTokenRepository) RecordUse(ctx context.Context, id uuid.UUID) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	t, ok := s.accessTokens[id]
+	if !ok {
+		return errors.New("token not found")
+	}
+
+	saveRow(s, s.accessTokens, id)
+	_, now := s.next()
+	t.token.LastUsedAt = &now
+	return nil
+}
+
+// Delete revokes one of the user's tokens
+func (r *MemoryAccessTokenRepository) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	t, ok := s.accessTokens[id]
+	if !ok || t.token.UserID != userID {
+		return errors.New("token not found")
+	}
+
+	deleteRow(s, s.accessTokens, id)
+	return nil
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+)
+
+type MemoryAuditRepository struct {
+	store *MemoryStore
+}
+
+// Append adds an event to the end of the log
+func (r *MemoryAuditRepository) Append(ctx context.Context, event *models.AuditEvent) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	var last models.AuditEvent
+	if n := len(s.auditLog); n > 0 {
+		last = s.auditLog[n-1]
+	}
+	chainAuditEvent(event, &last)
+	if s.logging {
+		n := len(s.auditLog)
+		s.undo = append(s.undo, func() { s.auditLog = s.auditLog[:n] })
+	}
+	s.auditLog = append(s.auditLog, *event)
+	return nil
+}
+
+// List returns the events matching filter, newest first
+func (r *MemoryAuditRepository) List(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	var events []models.AuditEvent
+	skipped := 0
+	for i := len(s.auditLog) - 1; i >= 0 && len(events) < filter.Limit; i-- {
+		e := s.auditLog[i]
+		if filter.ActorID != nil && (e.ActorID == nil || *e.ActorID != *filter.ActorID) {
+			continue
+		}
+		if (filter.Action != "" && e.Action != filter.Action) ||
+			(filter.TargetID != "" && e.TargetID != filter.TargetID) ||
+			(filter.Result != "" && e.Result != filter.Result) {
+			continue
+		}
+		if (filter.Since != nil && e.CreatedAt.Before(*filter.Since)) ||
+			(filter.Until != nil && !e.CreatedAt.Before(*filter.Until)) {
+			continue
+		}
+		if skipped < filter.Offset {
+			skipped++
+			continue
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// Range returns up to limit events after afterSeq, oldest first
+func (r *MemoryAuditRepository) Range(ctx context.Context, afterSeq int64, limit int) ([]models.AuditEvent, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	// Seq starts at 1 and has no gaps, so it doubles as an index
+	start := int(afterSeq)
+	if start < 0 {
+		start = 0
+	}
+	if start >= len(s.auditLog) {
+		return nil, nil
+	}
+	end := len(s.auditLog)
+	if limit >= 0 && start+limit < end {
+		end = start + limit
+	}
+	return append([]models.AuditEvent(nil), s.auditLog[start:end]...), nil
+}
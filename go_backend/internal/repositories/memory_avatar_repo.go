@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+)
+
+type MemoryAvatarRepository struct {
+	store *MemoryStore
+}
+
+// Set replaces the user's avatar and points their avatar_id at it
+func (r *MemoryAvatarRepository) Set(ctx context.Context, avatar *models.Avatar) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	u, ok := s.users[avatar.UserID]
+	if !ok {
+		return errors.New("user not found")
+	}
+	for id, a := range s.avatars {
+		if a.UserID == avatar.UserID {
+			deleteRow(s, s.avatars, id)
+		}
+	}
+
+	_, avatar.CreatedAt = s.next()
+	cp := *avatar
+	saveRow(s, s.avatars, avatar.ID)
+	s.avatars[avatar.ID] = &cp
+	id := avatar.ID
+	saveRow(s, s.users, avatar.UserID)
+	u.user.AvatarID = &id
+	return nil
+}
+
+// Get retrieves an avatar by its ID
+func (r *MemoryAvatarRepository) Get(ctx context.Context, id uuid.UUID) (*models.Avatar, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	a, ok := s.avatars[id]
+	if !ok {
+		return nil, errors.New("avatar not found")
+	}
+	cp := *a
+	return &cp, nil
+}
+
+// Delete removes the user's avatar and clears their avatar_id
+func (r *MemoryAvatarRepository) Delete(ctx context.Context, userID uuid.UUID) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	u, ok := s.users[userID]
+	if !ok || u.user.AvatarID == nil {
+		return errors.New("avatar not found")
+	}
+	deleteRow(s, s.avatars, *u.user.AvatarID)
+	saveRow(s, s.users, userID)
+	u.user.AvatarID = nil
+	return nil
+}
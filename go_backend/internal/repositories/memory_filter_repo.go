@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+)
+
+type MemoryFilterRepository struct {
+	store *MemoryStore
+}
+
+// AddWord adds a word to a room's list or updates its action
+func (r *MemoryFilterRepository) AddWord(ctx context.Context, word *models.WordFilter) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	if err := s.checkRoomUser(word.RoomID, word.CreatedBy); err != nil {
+		return err
+	}
+
+	_, now := s.next()
+	word.CreatedAt = now
+
+	if s.words[word.RoomID] == nil {
+		saveEntry(s, s.words, word.RoomID)
+		s.words[word.RoomID] = make(map[string]*models.WordFilter)
+	}
+	saveRow(s, s.words[word.RoomID], word.Word)
+	stored := *word
+	s.words[word.RoomID][word.Word] = &stored
+	return nil
+}
+
+// RemoveWord removes a word from a room's list
+func (r *MemoryFilterRepository) RemoveWord(ctx context.Context, roomID uuid.UUID, word string) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	if _, ok := s.words[roomID][word]; !ok {
+		return errors.New("word is not filtered in this room")
+	}
+
+	deleteRow(s, s.words[roomID], word)
+	if len(s.words[roomID]) == 0 {
+		saveEntry(s, s.words, roomID)
+		delete(s.words, roomID)
+	}
+	return nil
+}
+
+// GetWords returns a room's word list
+func (r *MemoryFilterRepository) GetWords(ctx context.Context, roomID uuid.UUID) ([]models.WordFilter, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	var words []models.WordFilter
+	for _, w := range s.words[roomID] {
+		words = append(words, *w)
+	}
+	sort.Slice(words, func(i, j int) bool { return words[i].Word < words[j].Word })
+	return words, nil
+}
@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+)
+
+type MemoryIdentityRepository struct {
+	store *MemoryStore
+}
+
+// Create links an external identity to a user
+func (r *MemoryIdentityRepository) Create(ctx context.Context, identity *models.Identity) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	if _, ok := s.users[identity.UserID]; !ok {
+		return errors.New("user not found")
+	}
+	for _, i := range s.identities {
+		if i.Issuer == identity.Issuer && i.Subject == identity.Subject {
+			return errors.New("identity is already linked")
+		}
+	}
+
+	_, now := s.next()
+	identity.ID = uuid.New()
+	identity.CreatedAt = now
+	cp := *identity
+	saveRow(s, s.identities, identity.ID)
+	s.identities[identity.ID] = &cp
+	return nil
+}
+
+// GetBySubject returns the identity the issuer knows by subject
+func (r *MemoryIdentityRepository) GetBySubject(ctx context.Context, issuer, subject string) (*models.Identity, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	for _, i := range s.identities {
+		if i.Issuer == issuer && i.Subject == subject {
+			cp := *i
+			return &cp, nil
+		}
+	}
+	return nil, errors.New("identity not found")
+}
+
+// GetByIssuer returns every identity the issuer vouches for, oldest first
+func (r *MemoryIdentityRepository) GetByIssuer(ctx context.Context, issuer string) ([]models.Identity, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	identities := []models.Identity{}
+	for _, i := range s.identities {
+		if i.Issuer == issuer {
+			identities = append(identities, *i)
+		}
+	}
+	sort.Slice(identities, func(i, j int) bool { return identities[i].CreatedAt.Before(identities[j].CreatedAt) })
+	return identities, nil
+}
+
+// GetByUser returns every identity linked to the user, oldest first
+func (r *MemoryIdentityRepository) GetByUser(ctx context.Context, userID uuid.UUID) ([]models.Identity, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	identities := []models.Identity{}
+	for _, i := range s.identities {
+		if i.UserID == userID {
+			identities = append(identities, *i)
+		}
+	}
+	sort.Slice(identities, func(i, j int) bool { return identities[i].CreatedAt.Before(identities[j].CreatedAt) })
+	return identities, nil
+}
+
+// RecordLogin stores the time of a login and the email the provider reported
+func (r *MemoryIdentityRepository) RecordLogin(ctx context.Context, id uuid.UUID, email string) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	identity, ok := s.identities[id]
+	if !ok {
+		return errors.New("identity not found")
+	}
+
+	saveRow(s, s.identities, id)
+	_, now := s.next()
+	identity.Email = email
+	identity.LastLoginAt = &now
+	return nil
+}
+
+// CreateLogin stores a login in progress, dropping expired ones
+func (r *MemoryIdentityRepository) CreateLogin(ctx context.Context, login *models.OIDCLogin) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	now := time.Now().UTC()
+	for state, l := range s.oidcLogins {
+		if l.ExpiresAt.Before(now) {
+			deleteRow(s, s.oidcLogins, state)
+		}
+	}
+
+	cp := *login
+	saveRow(s, s.oidcLogins, login.State)
+	s.oidcLogins[login.State] = &cp
+	return nil
+}
+
+// TakeLogin removes and returns an unexpired login
+func (r *MemoryIdentityRepository) TakeLogin(ctx context.Context, state string) (*models.OIDCLogin, error) {
+	s := r.store
+	defer s.lock(ctx)()
+
+	login, ok := s.oidcLogins[state]
+	if !ok || !login.ExpiresAt.After(time.Now()) {
+		return nil, errors.New("login not found or expired")
+	}
+
+	deleteRow(s, s.oidcLogins, state)
+	return login, nil
+}
+
+// CreateSAMLRequest stores a SAML request awaiting a response, dropping expired ones
+func (r *MemoryIdentityRepository) CreateSAMLRequest(ctx context.Context, request *models.SAMLRequest) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	now := time.Now().UTC()
+	for relayState, req := range s.samlRequests {
+		if req.ExpiresAt.Before(now) {
+			deleteRow(s, s.samlRequests, relayState)
+		}
+	}
+
+	cp := *request
+	saveRow(s, s.samlRequests, request.RelayState)
+	s.samlRequests[request.RelayState] = &cp
+	return nil
+}
+
+// TakeSAMLRequest removes and returns an unexpired SAML request
+func (r *MemoryIdentityRepository) TakeSAMLRequest(ctx context.Context, relayState string) (*models.SAMLRequest, error) {
+	s := r.store
+	defer s.lock(ctx)()
+
+	request, ok := s.samlRequests[relayState]
+	if !ok || !request.ExpiresAt.After(time.Now()) {
+		return nil, errors.New("login not found or expired")
+	}
+
+	deleteRow(s, s.samlRequests, relayState)
+	return request, nil
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+)
+
+type MemoryLoginLinkRepository struct {
+	store *MemoryStore
+}
+
+// Create stores a link, dropping links that expired over a day ago
+func (r *MemoryLoginLinkRepository) Create(ctx context.Context, link *models.LoginLink) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	_, now := s.next()
+	for hash, l := range s.loginLinks {
+		if l.ExpiresAt.Before(now.Add(-loginLinkRetention)) {
+			deleteRow(s, s.loginLinks, hash)
+		}
+	}
+
+	if _, ok := s.users[link.UserID]; !ok {
+		return errors.New("user not found")
+	}
+
+	link.CreatedAt = now
+	cp := *link
+	saveRow(s, s.loginLinks, link.TokenHash)
+	s.loginLinks[link.TokenHash] = &cp
+	return nil
+}
+
+// CountSince counts the links created for the user since the given time, used or not
+func (r *MemoryLoginLinkRepository) CountSince(ctx context.Context, userID uuid.UUID, since time.Time) (int, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	count := 0
+	for _, l := range s.loginLinks {
+		if l.UserID == userID && !l.CreatedAt.Before(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Use marks an unused, unexpired link as used and returns it
+func (r *MemoryLoginLinkRepository) Use(ctx context.Context, tokenHash string) (*models.LoginLink, error) {
+	s := r.store
+	defer s.lock(ctx)()
+
+	now := time.Now().UTC()
+	l, ok := s.loginLinks[tokenHash]
+	if !ok || l.UsedAt != nil || !l.ExpiresAt.After(now) {
+		return nil, errors.New("link not found or expired")
+	}
+
+	saveRow(s, s.loginLinks, tokenHash)
+	l.UsedAt = &now
+	cp := *l
+	return &cp, nil
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+)
+
+type MemoryMessageRepository struct {
+	store *MemoryStore
+}
+
+func (r *MemoryMessageRepository) Create(ctx context.Context, msg *models.Message) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	if _, ok := s.rooms[msg.RoomID]; !ok {
+		return errors.New("room not found")
+	}
+	if _, ok := s.users[msg.UserID]; !ok {
+		return errors.New("user not found")
+	}
+
+	seq, now := s.next()
+	msg.ID = uuid.New()
+	msg.CreatedAt = now
+
+	stored := *msg
+	stored.Username, stored.IsBot = "", false
+	saveRow(s, s.messages, msg.ID)
+	s.messages[msg.ID] = &memMessage{message: stored, seq: seq}
+	return nil
+}
+
+// CreateBatch stores msgs atomically: either all are stored or none are
+func (r *MemoryMessageRepository) CreateBatch(ctx context.Context, msgs []*models.Message) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	for _, msg := range msgs {
+		if _, ok := s.rooms[msg.RoomID]; !ok {
+			return errors.New("room not found")
+		}
+		if _, ok := s.users[msg.UserID]; !ok {
+			return errors.New("user not found")
+		}
+	}
+
+	for _, msg := range msgs {
+		seq, now := s.next()
+		msg.ID = uuid.New()
+		msg.CreatedAt = now
+
+		stored := *msg
+		stored.Username, stored.IsBot = "", false
+		saveRow(s, s.messages, msg.ID)
+		s.messages[msg.ID] = &memMessage{message: stored, seq: seq}
+	}
+	return nil
+}
+
+// GetByID returns a non-deleted message with its author's username
+func (r *MemoryMessageRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Message, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	m, ok := s.messages[id]
+	if !ok || m.isDeleted {
+		return nil, errors.New("message not found")
+	}
+
+	message := m.message
+	if u, ok := s.users[message.UserID]; ok {
+		message.Username = displayName(u.user)
+		message.IsBot = u.user.IsBot
+	}
+	return &message, nil
+}
+
+func (r *MemoryMessageRepository) GetByRoom(ctx context.Context, roomID uuid.UUID, limit, offset int) ([]models.Message, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	rows := make([]*memMessage, 0)
+	for _, m := range s.messages {
+		if m.message.RoomID != roomID || m.isDeleted {
+			continue
+		}
+		// Messages whose author is gone drop out, as with the SQL join on users
+		if _, ok := s.users[m.message.UserID]; !ok {
+			continue
+		}
+		rows = append(rows, m)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].seq > rows[j].seq })
+
+	if offset >= len(rows) {
+		return nil, nil
+	}
+	rows = rows[offset:]
+	if limit >= 0 && limit < len(rows) {
+		rows = rows[:limit]
+	}
+
+	messages := make([]models.Message, len(rows))
+	for i, m := range rows {
+		messages[i] = m.message
+		author := s.users[m.message.UserID].user
+		messages[i].Username = displayName(author)
+		messages[i].IsBot = author.IsBot
+	}
+	return messages, nil
+}
+
+// Delete soft-deletes a message
+func (r *MemoryMessageRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	m, ok := s.messages[id]
+	if !ok || m.isDeleted {
+		return errors.New("message not found")
+	}
+	saveRow(s, s.messages, id)
+	m.isDeleted = true
+	return nil
+}
+
+// displayName is the name shown next to a user's messages
+func displayName(user models.User) string {
+	if user.Status == models.UserStatusDeactivated {
+		return models.DeactivatedUsername
+	}
+	return user.Username
+}
@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+)
+
+type MemoryModerationRepository struct {
+	store *MemoryStore
+}
+
+// Ban bans a user from a room
+func (r *MemoryModerationRepository) Ban(ctx context.Context, ban *models.RoomBan, duration time.Duration) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	if err := s.checkRoomUser(ban.RoomID, ban.UserID); err != nil {
+		return err
+	}
+
+	_, now := s.next()
+	ban.CreatedAt = now
+	ban.ExpiresAt = nil
+	if duration > 0 {
+		expiresAt := now.Add(duration)
+		ban.ExpiresAt = &expiresAt
+	}
+
+	key := memRoomUser{ban.RoomID, ban.UserID}
+	stored := *ban
+	saveRow(s, s.bans, key)
+	s.bans[key] = &stored
+	return nil
+}
+
+// Unban lifts a user's ban from a room
+func (r *MemoryModerationRepository) Unban(ctx context.Context, roomID, userID uuid.UUID) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	key := memRoomUser{roomID, userID}
+	if ban, ok := s.bans[key]; !ok || !banActive(ban) {
+		return errors.New("user is not banned from this room")
+	}
+	deleteRow(s, s.bans, key)
+	return nil
+}
+
+// GetBan returns the user's active ban in a room, or nil if there is none
+func (r *MemoryModerationRepository) GetBan(ctx context.Context, roomID, userID uuid.UUID) (*models.RoomBan, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	ban, ok := s.bans[memRoomUser{roomID, userID}]
+	if !ok || !banActive(ban) {
+		return nil, nil
+	}
+	cp := *ban
+	return &cp, nil
+}
+
+// GetBans returns the active bans of a room
+func (r *MemoryModerationRepository) GetBans(ctx context.Context, roomID uuid.UUID) ([]models.RoomBan, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	var bans []models.RoomBan
+	for key, ban := range s.bans {
+		if key.roomID == roomID && banActive(ban) {
+			bans = append(bans, *ban)
+		}
+	}
+	sort.Slice(bans, func(i, j int) bool { return bans[i].CreatedAt.After(bans[j].CreatedAt) })
+	return bans, nil
+}
+
+// Mute mutes a user in a room
+func (r *MemoryModerationRepository) Mute(ctx context.Context, mute *models.RoomMute, duration time.Duration) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	if err := s.checkRoomUser(mute.RoomID, mute.UserID); err != nil {
+		return err
+	}
+
+	_, now := s.next()
+	mute.CreatedAt = now
+	mute.ExpiresAt = now.Add(duration)
+
+	key := memRoomUser{mute.RoomID, mute.UserID}
+	stored := *mute
+	saveRow(s, s.mutes, key)
+	s.mutes[key] = &stored
+	return nil
+}
+
+// Unmute lifts a user's mute in a room
+func (r *MemoryModerationRepository) Unmute(ctx context.Context, roomID, userID uuid.UUID) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	key := memRoomUser{roomID, userID}
+	if mute, ok := s.mutes[key]; !ok || !time.Now().Before(mute.ExpiresAt) {
+		return errors.New("user is not muted in this room")
+	}
+	deleteRow(s, s.mutes, key)
+	return nil
+}
+
+// GetMute returns the user's active mute in a room, or nil if there is none
+func (r *MemoryModerationRepository) GetMute(ctx context.Context, roomID, userID uuid.UUID) (*models.RoomMute, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	mute, ok := s.mutes[memRoomUser{roomID, userID}]
+	if !ok || !time.Now().Before(mute.ExpiresAt) {
+		return nil, nil
+	}
+	cp := *mute
+	return &cp, nil
+}
+
+// GetMutes returns the active mutes of a room
+func (r *MemoryModerationRepository) GetMutes(ctx context.Context, roomID uuid.UUID) ([]models.RoomMute, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	var mutes []models.RoomMute
+	now := time.Now()
+	for key, mute := range s.mutes {
+		if key.roomID == roomID && now.Before(mute.ExpiresAt) {
+			mutes = append(mutes, *mute)
+		}
+	}
+	sort.Slice(mutes, func(i, j int) bool { return mutes[i].CreatedAt.After(mutes[j].CreatedAt) })
+	return mutes, nil
+}
+
+func banActive(ban *models.RoomBan) bool {
+	return ban.ExpiresAt == nil || time.Now().Before(*ban.ExpiresAt)
+}
+
+// checkRoomUser enforces the room and user foreign keys. Callers must hold a lock.
+func (s *MemoryStore) checkRoomUser(roomID, userID uuid.UUID) error {
+	if _, ok := s.rooms[roomID]; !ok {
+		return errors.New("room not found")
+	}
+	if _, ok := s.users[userID]; !ok {
+		return errors.New("user not found")
+	}
+	return nil
+}
@@ -0,0 +1,291 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+)
+
+type MemoryOAuthRepository struct {
+	store *MemoryStore
+}
+
+// CreateApp stores a new app
+func (r *MemoryOAuthRepository) CreateApp(ctx context.Context, app *models.OAuthApp) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	if _, ok := s.users[app.OwnerID]; !ok {
+		return errors.New("user not found")
+	}
+
+	seq, now := s.next()
+	app.ID = uuid.New()
+	app.CreatedAt = now
+	cp := *app
+	cp.RedirectURIs = append(models.URIs(nil), app.RedirectURIs...)
+	saveRow(s, s.oauthApps, app.ID)
+	s.oauthApps[app.ID] = &memOAuthApp{app: cp, seq: seq}
+	return nil
+}
+
+// GetApp returns the app with the given ID
+func (r *MemoryOAuthRepository) GetApp(ctx context.Context, id uuid.UUID) (*models.OAuthApp, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	a, ok := s.oauthApps[id]
+	if !ok {
+		return nil, errors.New("app not found")
+	}
+	app := a.app
+	return &app, nil
+}
+
+// GetAppsByOwner returns the apps the user registered, oldest first
+func (r *MemoryOAuthRepository) GetAppsByOwner(ctx context.Context, ownerID uuid.UUID) ([]models.OAuthApp, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	var found []*memOAuthApp
+	for _, a := range s.oauthApps {
+		if a.app.OwnerID == ownerID {
+			found = append(found, a)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].seq < found[j].seq })
+
+	apps := make([]models.OAuthApp, len(found))
+	for i, a := range found {
+		apps[i] = a.app
+	}
+	return apps, nil
+}
+
+// DeleteApp removes one of the owner's apps with everything issued to it
+func (r *MemoryOAuthRepository) DeleteApp(ctx context.Context, ownerID, id uuid.UUID) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	a, ok := s.oauthApps[id]
+	if !ok || a.app.OwnerID != ownerID {
+		return errors.New("app not found")
+	}
+
+	s.deleteOAuthApp(id)
+	return nil
+}
+
+// deleteOAuthApp cascades to the app's codes, consents and tokens. The caller holds the lock.
+func (s *MemoryStore) deleteOAuthApp(id uuid.UUID) {
+	deleteRow(s, s.oauthApps, id)
+	for hash, code := range s.oauthCodes {
+		if code.AppID == id {
+			deleteRow(s, s.oauthCodes, hash)
+		}
+	}
+	for key := range s.oauthConsents {
+		if key.appID == id {
+			deleteRow(s, s.oauthConsents, key)
+		}
+	}
+	for tokenID, token := range s.oauthTokens {
+		if token.AppID == id {
+			deleteRow(s, s.oauthTokens, tokenID)
+		}
+	}
+}
+
+// CreateCode stores an authorization code, dropping expired ones
+func (r *MemoryOAuthRepository) CreateCode(ctx context.Context, code *models.OAuthCode) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	now := time.Now().UTC()
+	for hash, c := range s.oauthCodes {
+		if c.ExpiresAt.Before(now) {
+			deleteRow(s, s.oauthCodes, hash)
+		}
+	}
+
+	if _, ok := s.oauthApps[code.AppID]; !ok {
+		return errors.New("app not found")
+	}
+	if _, ok := s.users[code.UserID]; !ok {
+		return errors.New("user not found")
+	}
+
+	cp := *code
+	saveRow(s, s.oauthCodes, code.CodeHash)
+	s.oauthCodes[code.CodeHash] = &cp
+	return nil
+}
+
+// TakeCode removes and returns an unexpired code
+func (r *MemoryOAuthRepository) TakeCode(ctx context.Context, codeHash string) (*models.OAuthCode, error) {
+	s := r.store
+	defer s.lock(ctx)()
+
+	code, ok := s.oauthCodes[codeHash]
+	if !ok || !code.ExpiresAt.After(time.Now()) {
+		return nil, errors.New("code not found or expired")
+	}
+
+	deleteRow(s, s.oauthCodes, codeHash)
+	return code, nil
+}
+
+// GetConsent returns the scopes the user granted the app, or nil if there is no consent
+func (r *MemoryOAuthRepository) GetConsent(ctx context.Context, userID, appID uuid.UUID) (*models.OAuthConsent, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	c, ok := s.oauthConsents[memUserApp{userID: userID, appID: appID}]
+	if !ok {
+		return nil, nil
+	}
+	consent := s.withAppName(c.consent)
+	return &consent, nil
+}
+
+// SaveConsent stores the scopes the user granted the app, replacing earlier ones
+func (r *MemoryOAuthRepository) SaveConsent(ctx context.Context, consent *models.OAuthConsent) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	if _, ok := s.oauthApps[consent.AppID]; !ok {
+		return errors.New("app not found")
+	}
+	if _, ok := s.users[consent.UserID]; !ok {
+		return errors.New("user not found")
+	}
+
+	seq, now := s.next()
+	consent.GrantedAt = now
+	cp := *consent
+	cp.Scopes = append(models.Scopes(nil), consent.Scopes...)
+	key := memUserApp{userID: consent.UserID, appID: consent.AppID}
+	saveRow(s, s.oauthConsents, key)
+	s.oauthConsents[key] = &memOAuthConsent{consent: cp, seq: seq}
+	return nil
+}
+
+// GetConsents returns the apps the user authorized, oldest first
+func (r *MemoryOAuthRepository) GetConsents(ctx context.Context, userID uuid.UUID) ([]models.OAuthConsent, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	var found []*memOAuthConsent
+	for key, c := range s.oauthConsents {
+		if key.userID == userID {
+			found = append(found, c)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].seq < found[j].seq })
+
+	consents := make([]models.OAuthConsent, len(found))
+	for i, c := range found {
+		consents[i] = s.withAppName(c.consent)
+	}
+	return consents, nil
+}
+
+// withAppName fills in the consent's app name, as the SQL join does. The caller holds the lock.
+func (s *MemoryStore) withAppName(consent models.OAuthConsent) models.OAuthConsent {
+	if a, ok := s.oauthApps[consent.AppID]; ok {
+		consent.AppName = a.app.Name
+	}
+	return consent
+}
+
+// DeleteConsent removes the user's consent to the app and the app's codes and tokens for the user
+func (r *MemoryOAuthRepository) DeleteConsent(ctx context.Context, userID, appID uuid.UUID) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	key := memUserApp{userID: userID, appID: appID}
+	if _, ok := s.oauthConsents[key]; !ok {
+		return errors.New("authorization not found")
+	}
+
+	deleteRow(s, s.oauthConsents, key)
+	for hash, code := range s.oauthCodes {
+		if code.UserID == userID && code.AppID == appID {
+			deleteRow(s, s.oauthCodes, hash)
+		}
+	}
+	for tokenID, token := range s.oauthTokens {
+		if token.UserID == userID && token.AppID == appID {
+			deleteRow(s, s.oauthTokens, tokenID)
+		}
+	}
+	return nil
+}
+
+// CreateToken stores a new token, dropping those whose refresh token expired
+func (r *MemoryOAuthRepository) CreateToken(ctx context.Context, token *models.OAuthToken) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	_, now := s.next()
+	for tokenID, t := range s.oauthTokens {
+		if t.RefreshExpiresAt.Before(now) {
+			deleteRow(s, s.oauthTokens, tokenID)
+		}
+	}
+
+	if _, ok := s.oauthApps[token.AppID]; !ok {
+		return errors.New("app not found")
+	}
+	if _, ok := s.users[token.UserID]; !ok {
+		return errors.New("user not found")
+	}
+
+	token.ID = uuid.New()
+	token.CreatedAt = now
+	cp := *token
+	cp.Scopes = append(models.Scopes(nil), token.Scopes...)
+	saveRow(s, s.oauthTokens, token.ID)
+	s.oauthTokens[token.ID] = &cp
+	return nil
+}
+
+// GetTokenByAccessHash returns the token with the given access token hash
+func (r *MemoryOAuthRepository) GetTokenByAccessHash(ctx context.Context, accessHash string) (*models.OAuthToken, error) {
+	return r.getToken(ctx, func(t *models.OAuthToken) bool { return t.AccessHash == accessHash })
+}
+
+// GetTokenByRefreshHash returns the token with the given refresh token hash
+func (r *MemoryOAuthRepository) GetTokenByRefreshHash(ctx context.Context, refreshHash string) (*models.OAuthToken, error) {
+	return r.getToken(ctx, func(t *models.OAuthToken) bool { return t.RefreshHash == refreshHash })
+}
+
+func (r *MemoryOAuthRepository) getToken(ctx context.Context, match func(t *models.OAuthToken) bool) (*models.OAuthToken, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	for _, t := range s.oauthTokens {
+		if match(t) {
+			token := *t
+			return &token, nil
+		}
+	}
+	return nil, errors.New("token not found")
+}
+
+// DeleteToken revokes a token
+func (r *MemoryOAuthRepository) DeleteToken(ctx context.Context, id uuid.UUID) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	if _, ok := s.oauthTokens[id]; !ok {
+		return errors.New("token not found")
+	}
+
+	deleteRow(s, s.oauthTokens, id)
+	return nil
+}
@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+)
+
+type MemoryPasskeyRepository struct {
+	store *MemoryStore
+}
+
+// Create stores a new passkey
+func (r *MemoryPasskeyRepository) Create(ctx context.Context, passkey *models.Passkey) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	if _, ok := s.users[passkey.UserID]; !ok {
+		return errors.New("user not found")
+	}
+	for _, p := range s.passkeys {
+		if bytes.Equal(p.passkey.CredentialID, passkey.CredentialID) {
+			return errors.New("passkey is already registered")
+		}
+	}
+
+	seq, now := s.next()
+	passkey.ID = uuid.New()
+	passkey.CreatedAt = now
+	saveRow(s, s.passkeys, passkey.ID)
+	s.passkeys[passkey.ID] = &memPasskey{passkey: *passkey, seq: seq}
+	return nil
+}
+
+// GetByUser returns the user's passkeys, oldest first
+func (r *MemoryPasskeyRepository) GetByUser(ctx context.Context, userID uuid.UUID) ([]models.Passkey, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	var found []*memPasskey
+	for _, p := range s.passkeys {
+		if p.passkey.UserID == userID {
+			found = append(found, p)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].seq < found[j].seq })
+
+	passkeys := make([]models.Passkey, len(found))
+	for i, p := range found {
+		passkeys[i] = p.passkey
+	}
+	return passkeys, nil
+}
+
+// RecordUse stores the sign count and flags of a successful login
+func (r *MemoryPasskeyRepository) RecordUse(ctx context.Context, passkey *models.Passkey) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	p, ok := s.passkeys[passkey.ID]
+	if !ok {
+		return errors.New("passkey not found")
+	}
+
+	saveRow(s, s.passkeys, passkey.ID)
+	_, now := s.next()
+	p.passkey.SignCount = passkey.SignCount
+	p.passkey.CloneWarning = passkey.CloneWarning
+	p.passkey.Flags = passkey.Flags
+	p.passkey.LastUsedAt = &now
+	return nil
+}
+
+// Delete removes one of the user's passkeys
+func (r *MemoryPasskeyRepository) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	p, ok := s.passkeys[id]
+	if !ok || p.passkey.UserID != userID {
+		return errors.New("passkey not found")
+	}
+
+	deleteRow(s, s.passkeys, id)
+	return nil
+}
+
+// CreateSession stores a ceremony in progress, dropping expired ones
+func (r *MemoryPasskeyRepository) CreateSession(ctx context.Context, session *models.PasskeySession) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	now := time.Now().UTC()
+	for id, sess := range s.passkeySessions {
+		if sess.ExpiresAt.Before(now) {
+			deleteRow(s, s.passkeySessions, id)
+		}
+	}
+
+	session.ID = uuid.New()
+	cp := *session
+	saveRow(s, s.passkeySessions, session.ID)
+	s.passkeySessions[session.ID] = &cp
+	return nil
+}
+
+// TakeSession removes and returns an unexpired ceremony
+func (r *MemoryPasskeyRepository) TakeSession(ctx context.Context, id uuid.UUID, ceremony string) (*models.PasskeySession, error) {
+	s := r.store
+	defer s.lock(ctx)()
+
+	session, ok := s.passkeySessions[id]
+	if !ok || session.Ceremony != ceremony || !session.ExpiresAt.After(time.Now()) {
+		return nil, errors.New("passkey session not found or expired")
+	}
+
+	deleteRow(s, s.passkeySessions, id)
+	return session, nil
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+)
+
+type MemoryPrivacyRepository struct {
+	store *MemoryStore
+}
+
+// Get returns the user's settings, or the defaults if they never changed them
+func (r *MemoryPrivacyRepository) Get(ctx context.Context, userID uuid.UUID) (*models.PrivacySettings, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	return s.privacySettings(userID), nil
+}
+
+// GetMany returns the settings of each of the users, like Get
+func (r *MemoryPrivacyRepository) GetMany(ctx context.Context, userIDs []uuid.UUID) (map[uuid.UUID]*models.PrivacySettings, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	result := make(map[uuid.UUID]*models.PrivacySettings, len(userIDs))
+	for _, id := range userIDs {
+		result[id] = s.privacySettings(id)
+	}
+	return result, nil
+}
+
+// Set saves the user's settings
+func (r *MemoryPrivacyRepository) Set(ctx context.Context, settings *models.PrivacySettings) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	if _, ok := s.users[settings.UserID]; !ok {
+		return errors.New("user not found")
+	}
+
+	_, settings.UpdatedAt = s.next()
+	cp := *settings
+	saveRow(s, s.privacy, settings.UserID)
+	s.privacy[settings.UserID] = &cp
+	return nil
+}
+
+// privacySettings returns a copy of the user's settings. Callers must hold the lock.
+func (s *MemoryStore) privacySettings(userID uuid.UUID) *models.PrivacySettings {
+	if p, ok := s.privacy[userID]; ok {
+		cp := *p
+		return &cp
+	}
+	settings := models.DefaultPrivacySettings(userID)
+	return &settings
+}
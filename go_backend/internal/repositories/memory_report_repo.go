@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+)
+
+type MemoryReportRepository struct {
+	store *MemoryStore
+}
+
+// Create stores a new open report
+func (r *MemoryReportRepository) Create(ctx context.Context, report *models.Report) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	if _, ok := s.rooms[report.RoomID]; !ok {
+		return errors.New("room not found")
+	}
+	if report.ReporterID != nil {
+		if _, ok := s.users[*report.ReporterID]; !ok {
+			return errors.New("user not found")
+		}
+	}
+	if report.MessageID != nil {
+		if _, ok := s.messages[*report.MessageID]; !ok {
+			return errors.New("message not found")
+		}
+	}
+
+	seq, now := s.next()
+	report.ID = uuid.New()
+	report.Status = models.ReportStatusOpen
+	report.CreatedAt = now
+
+	saveRow(s, s.reports, report.ID)
+	s.reports[report.ID] = &memReport{report: *report, seq: seq}
+	return nil
+}
+
+// GetByID retrieves a report by its ID
+func (r *MemoryReportRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Report, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	rp, ok := s.reports[id]
+	if !ok {
+		return nil, errors.New("report not found")
+	}
+	report := rp.report
+	return &report, nil
+}
+
+// GetByRoom returns the reports of a room, oldest first, optionally filtered by status
+func (r *MemoryReportRepository) GetByRoom(ctx context.Context, roomID uuid.UUID, status string, limit, offset int) ([]models.Report, error) {
+	return r.list(ctx, func(report *models.Report) bool { return report.RoomID == roomID }, status, limit, offset)
+}
+
+// List returns the reports of every room, oldest first, optionally filtered by status
+func (r *MemoryReportRepository) List(ctx context.Context, status string, limit, offset int) ([]models.Report, error) {
+	return r.list(ctx, func(*models.Report) bool { return true }, status, limit, offset)
+}
+
+// list returns a page of the reports that match and have status, oldest first
+func (r *MemoryReportRepository) list(ctx context.Context, match func(report *models.Report) bool, status string, limit, offset int) ([]models.Report, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	rows := make([]*memReport, 0)
+	for _, rp := range s.reports {
+		if match(&rp.report) && (status == "" || rp.report.Status == status) {
+			rows = append(rows, rp)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].seq < rows[j].seq })
+
+	if offset >= len(rows) {
+		return nil, nil
+	}
+	rows = rows[offset:]
+	if limit >= 0 && limit < len(rows) {
+		rows = rows[:limit]
+	}
+
+	reports := make([]models.Report, len(rows))
+	for i, rp := range rows {
+		reports[i] = rp.report
+	}
+	return reports, nil
+}
+
+// HasPending reports whether the reporter already has an unhandled report on the same target
+func (r *MemoryReportRepository) HasPending(ctx context.Context, report *models.Report) (bool, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	for _, rp := range s.reports {
+		p := &rp.report
+		// Reports without a reporter never match, as NULL never equals in SQL
+		if p.ReporterID == nil || report.ReporterID == nil || *p.ReporterID != *report.ReporterID {
+			continue
+		}
+		if p.RoomID != report.RoomID || p.TargetType != report.TargetType || p.TargetUserID != report.TargetUserID {
+			continue
+		}
+		if (p.MessageID == nil) != (report.MessageID == nil) ||
+			(p.MessageID != nil && *p.MessageID != *report.MessageID) {
+			continue
+		}
+		if p.Status == models.ReportStatusOpen || p.Status == models.ReportStatusClaimed {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Claim assigns an open report to a moderator
+func (r *MemoryReportRepository) Claim(ctx context.Context, id, moderatorID uuid.UUID) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	rp, ok := s.reports[id]
+	if !ok || rp.report.Status != models.ReportStatusOpen {
+		return errors.New("report is not open")
+	}
+
+	saveRow(s, s.reports, id)
+	_, now := s.next()
+	rp.report.Status = models.ReportStatusClaimed
+	rp.report.ClaimedBy = &moderatorID
+	rp.report.ClaimedAt = &now
+	return nil
+}
+
+// Close resolves or dismisses a report that is open or claimed by the moderator
+func (r *MemoryReportRepository) Close(ctx context.Context, id, moderatorID uuid.UUID, status, resolution, note string) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	rp, ok := s.reports[id]
+	if !ok {
+		return errors.New("report is closed or claimed by another moderator")
+	}
+	open := rp.report.Status == models.ReportStatusOpen
+	claimed := rp.report.Status == models.ReportStatusClaimed &&
+		rp.report.ClaimedBy != nil && *rp.report.ClaimedBy == moderatorID
+	if !open && !claimed {
+		return errors.New("report is closed or claimed by another moderator")
+	}
+
+	saveRow(s, s.reports, id)
+	_, now := s.next()
+	rp.report.Status = status
+	rp.report.ResolvedBy = &moderatorID
+	rp.report.ResolvedAt = &now
+	rp.report.Resolution = resolution
+	rp.report.ResolutionNote = note
+	return nil
+}
+
+// AddAction appends an entry to a report's audit trail
+func (r *MemoryReportRepository) AddAction(ctx context.Context, action *models.ReportAction) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	if _, ok := s.reports[action.ReportID]; !ok {
+		return errors.New("report not found")
+	}
+
+	_, now := s.next()
+	action.ID = uuid.New()
+	action.CreatedAt = now
+	saveEntry(s, s.reportActions, action.ReportID)
+	s.reportActions[action.ReportID] = append(s.reportActions[action.ReportID], *action)
+	return nil
+}
+
+// GetActions returns a report's audit trail, oldest first
+func (r *MemoryReportRepository) GetActions(ctx context.Context, reportID uuid.UUID) ([]models.ReportAction, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	return append([]models.ReportAction(nil), s.reportActions[reportID]...), nil
+}
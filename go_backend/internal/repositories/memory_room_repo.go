@@ -0,0 +1,242 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+)
+
+type MemoryRoomRepository struct {
+	store *MemoryStore
+}
+
+// Create creates a new room and adds its creator as a member
+func (r *MemoryRoomRepository) Create(ctx context.Context, room *models.Room) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	if _, ok := s.users[room.CreatedBy]; !ok {
+		return errors.New("user not found")
+	}
+
+	seq, now := s.next()
+	room.ID = uuid.New()
+	room.CreatedAt = now
+	saveRow(s, s.rooms, room.ID)
+	s.rooms[room.ID] = &memRoom{room: *room, seq: seq}
+
+	// Automatically add creator as a member
+	s.addMember(room.ID, room.CreatedBy)
+	return nil
+}
+
+// GetByID retrieves a room by its ID
+func (r *MemoryRoomRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Room, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	rm, ok := s.rooms[id]
+	if !ok {
+		return nil, errors.New("room not found")
+	}
+	room := rm.room
+	return &room, nil
+}
+
+// GetAll retrieves all rooms
+func (r *MemoryRoomRepository) GetAll(ctx context.Context) ([]models.Room, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	return s.sortedRooms(func(uuid.UUID) bool { return true }), nil
+}
+
+// GetUserRooms retrieves all rooms a user is a member of
+func (r *MemoryRoomRepository) GetUserRooms(ctx context.Context, userID uuid.UUID) ([]models.Room, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	return s.sortedRooms(func(roomID uuid.UUID) bool {
+		_, ok := s.members[roomID][userID]
+		return ok
+	}), nil
+}
+
+// Delete deletes a room (only if user is the creator)
+func (r *MemoryRoomRepository) Delete(ctx context.Context, roomID, userID uuid.UUID) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	rm, ok := s.rooms[roomID]
+	if !ok {
+		return errors.New("room not found")
+	}
+
+	if rm.room.CreatedBy != userID {
+		return errors.New("only room creator can delete the room")
+	}
+
+	s.deleteRoom(roomID)
+	return nil
+}
+
+// ForceDelete deletes a room whoever created it
+func (r *MemoryRoomRepository) ForceDelete(ctx context.Context, roomID uuid.UUID) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	if _, ok := s.rooms[roomID]; !ok {
+		return errors.New("room not found")
+	}
+
+	s.deleteRoom(roomID)
+	return nil
+}
+
+// deleteRoom removes a room. Callers must hold the write lock.
+func (s *MemoryStore) deleteRoom(roomID uuid.UUID) {
+	// Cascade to members, messages, bans, mutes, reports and word filters like the foreign keys do
+	deleteRow(s, s.rooms, roomID)
+	saveEntry(s, s.members, roomID)
+	delete(s.members, roomID)
+	saveEntry(s, s.words, roomID)
+	delete(s.words, roomID)
+	for id, m := range s.messages {
+		if m.message.RoomID == roomID {
+			deleteRow(s, s.messages, id)
+		}
+	}
+	for key := range s.bans {
+		if key.roomID == roomID {
+			deleteRow(s, s.bans, key)
+		}
+	}
+	for key := range s.mutes {
+		if key.roomID == roomID {
+			deleteRow(s, s.mutes, key)
+		}
+	}
+	for id, rp := range s.reports {
+		if rp.report.RoomID == roomID {
+			deleteRow(s, s.reports, id)
+			saveEntry(s, s.reportActions, id)
+			delete(s.reportActions, id)
+		}
+	}
+}
+
+// AddMember adds a user to a room
+func (r *MemoryRoomRepository) AddMember(ctx context.Context, roomID, userID uuid.UUID) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	if _, ok := s.rooms[roomID]; !ok {
+		return errors.New("room not found")
+	}
+	if _, ok := s.users[userID]; !ok {
+		return errors.New("user not found")
+	}
+
+	s.addMember(roomID, userID)
+	return nil
+}
+
+// RemoveMember removes a user from a room
+func (r *MemoryRoomRepository) RemoveMember(ctx context.Context, roomID, userID uuid.UUID) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	if _, ok := s.members[roomID][userID]; !ok {
+		return errors.New("user is not a member of this room")
+	}
+
+	deleteRow(s, s.members[roomID], userID)
+	if len(s.members[roomID]) == 0 {
+		saveEntry(s, s.members, roomID)
+		delete(s.members, roomID)
+	}
+	return nil
+}
+
+// GetMembers retrieves all members of a room
+func (r *MemoryRoomRepository) GetMembers(ctx context.Context, roomID uuid.UUID) ([]models.RoomMember, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	rows := make([]*memMember, 0, len(s.members[roomID]))
+	for _, m := range s.members[roomID] {
+		rows = append(rows, m)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].seq < rows[j].seq })
+
+	members := make([]models.RoomMember, len(rows))
+	for i, m := range rows {
+		members[i] = m.member
+	}
+	return members, nil
+}
+
+// IsMember checks if a user is a member of a room
+func (r *MemoryRoomRepository) IsMember(ctx context.Context, roomID, userID uuid.UUID) (bool, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	_, ok := s.members[roomID][userID]
+	return ok, nil
+}
+
+// GetRoommates returns those of candidates who share a room with the user
+func (r *MemoryRoomRepository) GetRoommates(ctx context.Context, userID uuid.UUID, candidates []uuid.UUID) ([]uuid.UUID, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	roommates := []uuid.UUID{}
+	for _, candidate := range candidates {
+		for _, members := range s.members {
+			_, own := members[userID]
+			_, other := members[candidate]
+			if own && other {
+				roommates = append(roommates, candidate)
+				break
+			}
+		}
+	}
+	return roommates, nil
+}
+
+// addMember inserts a membership unless it already exists. Callers must hold the write lock.
+func (s *MemoryStore) addMember(roomID, userID uuid.UUID) {
+	if _, ok := s.members[roomID][userID]; ok {
+		return
+	}
+	if s.members[roomID] == nil {
+		saveEntry(s, s.members, roomID)
+		s.members[roomID] = make(map[uuid.UUID]*memMember)
+	}
+	saveRow(s, s.members[roomID], userID)
+	seq, now := s.next()
+	s.members[roomID][userID] = &memMember{
+		member: models.RoomMember{RoomID: roomID, UserID: userID, JoinedAt: now},
+		seq:    seq,
+	}
+}
+
+// sortedRooms returns the rooms accepted by keep, newest first. Callers must hold a lock.
+func (s *MemoryStore) sortedRooms(keep func(roomID uuid.UUID) bool) []models.Room {
+	rows := make([]*memRoom, 0, len(s.rooms))
+	for id, rm := range s.rooms {
+		if keep(id) {
+			rows = append(rows, rm)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].seq > rows[j].seq })
+
+	rooms := make([]models.Room, len(rows))
+	for i, rm := range rows {
+		rooms[i] = rm.room
+	}
+	return rooms
+}
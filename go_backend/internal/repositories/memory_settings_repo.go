@@ -0,0 +1,25 @@
+package repository
+
+import "context"
+
+type MemorySettingsRepository struct {
+	store *MemoryStore
+}
+
+// Get returns a setting's value, or "" if it was never set
+func (r *MemorySettingsRepository) Get(ctx context.Context, key string) (string, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	return s.settings[key], nil
+}
+
+// Set stores a setting's value
+func (r *MemorySettingsRepository) Set(ctx context.Context, key, value string) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	saveEntry(s, s.settings, key)
+	s.settings[key] = value
+	return nil
+}
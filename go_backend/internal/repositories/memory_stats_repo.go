@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+)
+
+type MemoryStatsRepository struct {
+	store *MemoryStore
+}
+
+// GetStats counts users, rooms, messages and pending reports
+func (r *MemoryStatsRepository) GetStats(ctx context.Context) (*models.ServerStats, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	stats := models.ServerStats{
+		Users: len(s.users),
+		Rooms: len(s.rooms),
+	}
+	for _, m := range s.messages {
+		if !m.isDeleted {
+			stats.Messages++
+		}
+	}
+	for _, rp := range s.reports {
+		if rp.report.Status == models.ReportStatusOpen || rp.report.Status == models.ReportStatusClaimed {
+			stats.OpenReports++
+		}
+	}
+	return &stats, nil
+}
@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+)
+
+type MemoryTwoFactorRepository struct {
+	store *MemoryStore
+}
+
+// GetTOTP returns the user's TOTP enrollment, or nil if there is none
+func (r *MemoryTwoFactorRepository) GetTOTP(ctx context.Context, userID uuid.UUID) (*models.TOTP, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	t, ok := s.totp[userID]
+	if !ok {
+		return nil, nil
+	}
+	cp := *t
+	return &cp, nil
+}
+
+// StartTOTP stores a secret waiting for confirmation
+func (r *MemoryTwoFactorRepository) StartTOTP(ctx context.Context, userID uuid.UUID, secret string) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	if _, ok := s.users[userID]; !ok {
+		return errors.New("user not found")
+	}
+	if t, ok := s.totp[userID]; ok && t.Enabled {
+		return errors.New("two-factor authentication is already enabled")
+	}
+
+	saveRow(s, s.totp, userID)
+	_, now := s.next()
+	s.totp[userID] = &models.TOTP{UserID: userID, Secret: secret, CreatedAt: now}
+	return nil
+}
+
+// EnableTOTP confirms the enrollment and replaces the recovery codes
+func (r *MemoryTwoFactorRepository) EnableTOTP(ctx context.Context, userID uuid.UUID, step int64, codeHashes []string) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	t, ok := s.totp[userID]
+	if !ok || t.Enabled {
+		return errors.New("no two-factor setup in progress")
+	}
+
+	saveRow(s, s.totp, userID)
+	_, now := s.next()
+	t.Enabled = true
+	t.EnabledAt = &now
+	t.LastStep = step
+	s.replaceRecoveryCodes(userID, codeHashes)
+	return nil
+}
+
+// UseTOTPStep accepts the code of step unless a code of that step or a later one was accepted before
+func (r *MemoryTwoFactorRepository) UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	t, ok := s.totp[userID]
+	if !ok || !t.Enabled || t.LastStep >= step {
+		return errors.New("code was already used")
+	}
+
+	saveRow(s, s.totp, userID)
+	t.LastStep = step
+	return nil
+}
+
+// DeleteTOTP removes the enrollment and the recovery codes
+func (r *MemoryTwoFactorRepository) DeleteTOTP(ctx context.Context, userID uuid.UUID) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	if _, ok := s.totp[userID]; !ok {
+		return errors.New("two-factor authentication is not set up")
+	}
+
+	deleteRow(s, s.totp, userID)
+	saveEntry(s, s.recoveryCodes, userID)
+	delete(s.recoveryCodes, userID)
+	return nil
+}
+
+// ReplaceRecoveryCodes replaces the user's recovery codes
+func (r *MemoryTwoFactorRepository) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	if _, ok := s.users[userID]; !ok {
+		return errors.New("user not found")
+	}
+
+	s.replaceRecoveryCodes(userID, codeHashes)
+	return nil
+}
+
+// UseRecoveryCode marks an unused recovery code as used
+func (r *MemoryTwoFactorRepository) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	code, ok := s.recoveryCodes[userID][codeHash]
+	if !ok || code.usedAt != nil {
+		return errors.New("invalid recovery code")
+	}
+
+	saveRow(s, s.recoveryCodes[userID], codeHash)
+	_, now := s.next()
+	code.usedAt = &now
+	return nil
+}
+
+// CountRecoveryCodes counts the user's unused recovery codes
+func (r *MemoryTwoFactorRepository) CountRecoveryCodes(ctx context.Context, userID uuid.UUID) (int, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	count := 0
+	for _, code := range s.recoveryCodes[userID] {
+		if code.usedAt == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// replaceRecoveryCodes replaces a user's recovery codes. Callers must hold the write lock.
+func (s *MemoryStore) replaceRecoveryCodes(userID uuid.UUID, codeHashes []string) {
+	codes := make(map[string]*memRecoveryCode, len(codeHashes))
+	for _, hash := range codeHashes {
+		codes[hash] = &memRecoveryCode{}
+	}
+	saveEntry(s, s.recoveryCodes, userID)
+	s.recoveryCodes[userID] = codes
+}
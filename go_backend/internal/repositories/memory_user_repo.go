@@ -0,0 +1,452 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+)
+
+type MemoryUserRepository struct {
+	store *MemoryStore
+}
+
+// Register creates a new user with an already hashed password
+func (r *MemoryUserRepository) Register(ctx context.Context, username, email, passwordHash string) (*models.User, error) {
+	s := r.store
+	defer s.lock(ctx)()
+
+	for _, u := range s.users {
+		if u.user.Username == username {
+			return nil, errors.New("username already exists")
+		}
+		if u.user.Email == email {
+			return nil, errors.New("email already exists")
+		}
+	}
+
+	seq, now := s.next()
+	user := models.User{
+		ID:           uuid.New(),
+		Username:     username,
+		Email:        email,
+		PasswordHash: passwordHash,
+		Role:         models.RoleUser,
+		Status:       models.UserStatusActive,
+		CreatedAt:    now,
+	}
+	saveRow(s, s.users, user.ID)
+	s.users[user.ID] = &memUser{user: user, seq: seq}
+
+	return &user, nil
+}
+
+// CreateBot creates a bot account owned by ownerID
+func (r *MemoryUserRepository) CreateBot(ctx context.Context, ownerID uuid.UUID, username, displayName string) (*models.User, error) {
+	s := r.store
+	defer s.lock(ctx)()
+
+	if _, ok := s.users[ownerID]; !ok {
+		return nil, errors.New("user not found")
+	}
+	for _, u := range s.users {
+		if u.user.Username == username {
+			return nil, errors.New("username already exists")
+		}
+	}
+
+	seq, now := s.next()
+	user := models.User{
+		ID:          uuid.New(),
+		Username:    username,
+		DisplayName: displayName,
+		Role:        models.RoleUser,
+		Status:      models.UserStatusActive,
+		IsBot:       true,
+		OwnerID:     &ownerID,
+		CreatedAt:   now,
+	}
+	user.Email = user.ID.String() + "@" + models.BotEmailDomain
+	saveRow(s, s.users, user.ID)
+	s.users[user.ID] = &memUser{user: user, seq: seq}
+
+	return &user, nil
+}
+
+// GetByID retrieves a user by their ID
+func (r *MemoryUserRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	u, ok := s.users[id]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	user := u.user
+	return &user, nil
+}
+
+// GetAll retrieves all users
+func (r *MemoryUserRepository) GetAll(ctx context.Context) ([]models.User, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	rows := make([]*memUser, 0, len(s.users))
+	for _, u := range s.users {
+		rows = append(rows, u)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].seq > rows[j].seq })
+
+	users := make([]models.User, len(rows))
+	for i, u := range rows {
+		users[i] = u.user
+	}
+	return users, nil
+}
+
+// GetByOwner retrieves the bots owned by a user
+func (r *MemoryUserRepository) GetByOwner(ctx context.Context, ownerID uuid.UUID) ([]models.User, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	var rows []*memUser
+	for _, u := range s.users {
+		if u.user.OwnerID != nil && *u.user.OwnerID == ownerID {
+			rows = append(rows, u)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].seq < rows[j].seq })
+
+	users := make([]models.User, len(rows))
+	for i, u := range rows {
+		users[i] = u.user
+	}
+	return users, nil
+}
+
+// Search lists the users matching query, a page at a time
+func (r *MemoryUserRepository) Search(ctx context.Context, query string, limit, offset int) ([]models.User, int, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	lower := strings.ToLower(query)
+	var matches []models.User
+	for _, u := range s.users {
+		user := u.user
+		if user.Status == models.UserStatusDeactivated {
+			continue
+		}
+		emailShown := false
+		if p, ok := s.privacy[user.ID]; ok {
+			emailShown = p.Email == models.VisibilityEveryone
+		}
+		if strings.Contains(strings.ToLower(user.Username), lower) || strings.Contains(strings.ToLower(user.DisplayName), lower) ||
+			(emailShown && user.Email == query) {
+			matches = append(matches, user)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := strings.ToLower(matches[i].Username), strings.ToLower(matches[j].Username)
+		if a != b {
+			return a < b
+		}
+		return matches[i].ID.String() < matches[j].ID.String()
+	})
+
+	users := []models.User{}
+	if offset < len(matches) {
+		users = append(users, matches[offset:min(offset+limit, len(matches))]...)
+	}
+	return users, len(matches), nil
+}
+
+// GetByIdentifier retrieves a user by email or username
+func (r *MemoryUserRepository) GetByIdentifier(ctx context.Context, identifier string) (*models.User, error) {
+	s := r.store
+	defer s.rlock(ctx)()
+
+	for _, u := range s.users {
+		if u.user.Email == identifier || u.user.Username == identifier {
+			user := u.user
+			return &user, nil
+		}
+	}
+	return nil, errors.New("user not found")
+}
+
+// SetRole changes a user's global role
+func (r *MemoryUserRepository) SetRole(ctx context.Context, id uuid.UUID, role string) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	u, ok := s.users[id]
+	if !ok {
+		return errors.New("user not found")
+	}
+	saveRow(s, s.users, id)
+	u.user.Role = role
+	return nil
+}
+
+// SetStatus changes a user's account status
+func (r *MemoryUserRepository) SetStatus(ctx context.Context, id uuid.UUID, status string) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	u, ok := s.users[id]
+	if !ok {
+		return errors.New("user not found")
+	}
+	saveRow(s, s.users, id)
+	u.user.Status = status
+	return nil
+}
+
+// SetDisplayName changes the name shown instead of the user's username
+func (r *MemoryUserRepository) SetDisplayName(ctx context.Context, id uuid.UUID, displayName string) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	u, ok := s.users[id]
+	if !ok {
+		return errors.New("user not found")
+	}
+	saveRow(s, s.users, id)
+	u.user.DisplayName = displayName
+	return nil
+}
+
+// SetPasswordHash replaces a user's password hash
+func (r *MemoryUserRepository) SetPasswordHash(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	u, ok := s.users[id]
+	if !ok {
+		return errors.New("user not found")
+	}
+	saveRow(s, s.users, id)
+	u.user.PasswordHash = passwordHash
+	return nil
+}
+
+// SetSessionStamp replaces the stamp session tokens must carry to be accepted
+func (r *MemoryUserRepository) SetSessionStamp(ctx context.Context, id uuid.UUID, stamp string) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	u, ok := s.users[id]
+	if !ok {
+		return errors.New("user not found")
+	}
+	saveRow(s, s.users, id)
+	u.user.SessionStamp = stamp
+	return nil
+}
+
+// SetEmailVerified records whether the user proved they own their email
+func (r *MemoryUserRepository) SetEmailVerified(ctx context.Context, id uuid.UUID, verified bool) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	u, ok := s.users[id]
+	if !ok {
+		return errors.New("user not found")
+	}
+	saveRow(s, s.users, id)
+	u.user.EmailVerified = verified
+	return nil
+}
+
+// UpdateProfile saves the fields users edit on their profile
+func (r *MemoryUserRepository) UpdateProfile(ctx context.Context, user *models.User) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	u, ok := s.users[user.ID]
+	if !ok {
+		return errors.New("user not found")
+	}
+	for id, other := range s.users {
+		if id != user.ID && other.user.Username == user.Username {
+			return errors.New("username already exists")
+		}
+	}
+
+	saveRow(s, s.users, user.ID)
+	u.user.Username = user.Username
+	u.user.DisplayName = user.DisplayName
+	u.user.Bio = user.Bio
+	u.user.TimeZone = user.TimeZone
+	u.user.Pronouns = user.Pronouns
+	u.user.StatusText = user.StatusText
+	u.user.StatusEmoji = user.StatusEmoji
+	u.user.StatusExpires = user.StatusExpires
+	return nil
+}
+
+// SetLastSeen records when the user was last active
+func (r *MemoryUserRepository) SetLastSeen(ctx context.Context, id uuid.UUID, seenAt time.Time) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	u, ok := s.users[id]
+	if !ok {
+		return errors.New("user not found")
+	}
+	saveRow(s, s.users, id)
+	seenAt = seenAt.UTC()
+	u.user.LastSeenAt = &seenAt
+	return nil
+}
+
+// Delete deletes a user and their bots. Rooms they created are kept without a creator.
+func (r *MemoryUserRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	s := r.store
+	defer s.lock(ctx)()
+
+	if _, ok := s.users[id]; !ok {
+		return errors.New("user not found")
+	}
+
+	s.deleteUser(id)
+	return nil
+}
+
+// deleteUser cascades to bots, memberships, bans and mutes and clears every
+// other reference, like the foreign keys do. The caller holds the lock.
+func (s *MemoryStore) deleteUser(id uuid.UUID) {
+	deleteRow(s, s.users, id)
+	for botID, u := range s.users {
+		if u.user.OwnerID != nil && *u.user.OwnerID == id {
+			s.deleteUser(botID)
+		}
+	}
+	for roomID, rm := range s.rooms {
+		if rm.room.CreatedBy == id {
+			saveRow(s, s.rooms, roomID)
+			rm.room.CreatedBy = uuid.Nil
+		}
+	}
+	for _, members := range s.members {
+		deleteRow(s, members, id)
+	}
+	for messageID, m := range s.messages {
+		if m.message.UserID == id {
+			saveRow(s, s.messages, messageID)
+			m.message.UserID = uuid.Nil
+		}
+	}
+	for key, b := range s.bans {
+		if key.userID == id {
+			deleteRow(s, s.bans, key)
+		} else if b.BannedBy == id {
+			saveRow(s, s.bans, key)
+			b.BannedBy = uuid.Nil
+		}
+	}
+	for key, m := range s.mutes {
+		if key.userID == id {
+			deleteRow(s, s.mutes, key)
+		} else if m.MutedBy == id {
+			saveRow(s, s.mutes, key)
+			m.MutedBy = uuid.Nil
+		}
+	}
+	for reportID, rp := range s.reports {
+		saveRow(s, s.reports, reportID)
+		report := &rp.report
+		if report.ReporterID != nil && *report.ReporterID == id {
+			report.ReporterID = nil
+		}
+		if report.TargetUserID == id {
+			report.TargetUserID = uuid.Nil
+		}
+		if report.ClaimedBy != nil && *report.ClaimedBy == id {
+			report.ClaimedBy = nil
+		}
+		if report.ResolvedBy != nil && *report.ResolvedBy == id {
+			report.ResolvedBy = nil
+		}
+	}
+	for reportID, actions := range s.reportActions {
+		for i := range actions {
+			if actions[i].ActorID != nil && *actions[i].ActorID == id {
+				if s.logging {
+					// the saved trail must keep its actor
+					saveEntry(s, s.reportActions, reportID)
+					actions = slices.Clone(actions)
+					s.reportActions[reportID] = actions
+				}
+				actions[i].ActorID = nil
+			}
+		}
+	}
+	for _, words := range s.words {
+		for word, w := range words {
+			if w.CreatedBy == id {
+				saveRow(s, words, word)
+				w.CreatedBy = uuid.Nil
+			}
+		}
+	}
+	deleteRow(s, s.totp, id)
+	saveEntry(s, s.recoveryCodes, id)
+	delete(s.recoveryCodes, id)
+	for passkeyID, p := range s.passkeys {
+		if p.passkey.UserID == id {
+			deleteRow(s, s.passkeys, passkeyID)
+		}
+	}
+	for sessionID, session := range s.passkeySessions {
+		if session.UserID != nil && *session.UserID == id {
+			deleteRow(s, s.passkeySessions, sessionID)
+		}
+	}
+	for identityID, identity := range s.identities {
+		if identity.UserID == id {
+			deleteRow(s, s.identities, identityID)
+		}
+	}
+	for tokenID, t := range s.accessTokens {
+		if t.token.UserID == id {
+			deleteRow(s, s.accessTokens, tokenID)
+		}
+	}
+	for appID, a := range s.oauthApps {
+		if a.app.OwnerID == id {
+			s.deleteOAuthApp(appID)
+		}
+	}
+	for hash, code := range s.oauthCodes {
+		if code.UserID == id {
+			deleteRow(s, s.oauthCodes, hash)
+		}
+	}
+	for key := range s.oauthConsents {
+		if key.userID == id {
+			deleteRow(s, s.oauthConsents, key)
+		}
+	}
+	for tokenID, token := range s.oauthTokens {
+		if token.UserID == id {
+			deleteRow(s, s.oauthTokens, tokenID)
+		}
+	}
+	for hash, link := range s.loginLinks {
+		if link.UserID == id {
+			deleteRow(s, s.loginLinks, hash)
+		}
+	}
+	for avatarID, avatar := range s.avatars {
+		if avatar.UserID == id {
+			deleteRow(s, s.avatars, avatarID)
+		}
+	}
+	deleteRow(s, s.privacy, id)
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// PostgresTxManager runs groups of repository calls atomically in a sqlx.Tx
+type PostgresTxManager struct {
+	db *sqlx.DB
+}
+
+func NewPostgresTxManager(db *sqlx.DB) *PostgresTxManager {
+	return &PostgresTxManager{db: db}
+}
+
+// WithinTx runs fn inside a transaction carried by the context passed to fn.
+// If ctx already carries a transaction, fn joins it instead of starting a new one.
+// The transaction is rolled back if fn returns an error or panics.
+func (m *PostgresTxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return withinTx(ctx, m.db, fn)
+}
+
+// NewPostgresRepositories wires the PostgreSQL-backed repositories to db
+func NewPostgresRepositories(db *sqlx.DB) *Repositories {
+	return &Repositories{
+		Users:        NewPostgresUserRepository(db),
+		Rooms:        NewPostgresRoomRepository(db),
+		Messages:     NewPostgresMessageRepository(db),
+		Moderation:   NewPostgresModerationRepository(db),
+		Reports:      NewPostgresReportRepository(db),
+		Filters:      NewPostgresFilterRepository(db),
+		Stats:        NewPostgresStatsRepository(db),
+		Audit:        NewPostgresAuditRepository(db),
+		TwoFactor:    NewPostgresTwoFactorRepository(db),
+		Settings:     NewPostgresSettingsRepository(db),
+		Passkeys:     NewPostgresPasskeyRepository(db),
+		Identities:   NewPostgresIdentityRepository(db),
+		AccessTokens: NewPostgresAccessTokenRepository(db),
+		OAuth:        NewPostgresOAuthRepository(db),
+		LoginLinks:   NewPostgresLoginLinkRepository(db),
+		Avatars:      NewPostgresAvatarRepository(db),
+		Privacy:      NewPostgresPrivacyRepository(db),
+		TxManager:    NewPostgresTxManager(db),
+	}
+}
+
+var (
+	_ UserRepository        = (*PostgresUserRepository)(nil)
+	_ RoomRepository        = (*PostgresRoomRepository)(nil)
+	_ MessageRepository     = (*PostgresMessageRepository)(nil)
+	_ ModerationRepository  = (*PostgresModerationRepository)(nil)
+	_ ReportRepository      = (*PostgresReportRepository)(nil)
+	_ FilterRepository      = (*PostgresFilterRepository)(nil)
+	_ StatsRepository       = (*PostgresStatsRepository)(nil)
+	_ AuditRepository       = (*PostgresAuditRepository)(nil)
+	_ TwoFactorRepository   = (*PostgresTwoFactorRepository)(nil)
+	_ SettingsRepository    = (*PostgresSettingsRepository)(nil)
+	_ PasskeyRepository     = (*PostgresPasskeyRepository)(nil)
+	_ IdentityRepository    = (*PostgresIdentityRepository)(nil)
+	_ AccessTokenRepository = (*PostgresAccessTokenRepository)(nil)
+	_ OAuthRepository       = (*PostgresOAuthRepository)(nil)
+	_ LoginLinkRepository   = (*PostgresLoginLinkRepository)(nil)
+	_ AvatarRepository      = (*PostgresAvatarRepository)(nil)
+	_ PrivacyRepository     = (*PostgresPrivacyRepository)(nil)
+	_ TxManager             = (*PostgresTxManager)(nil)
+)
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+const accessTokenColumns = `id, user_id, name, token_hash, prefix, scopes, expires_at, created_at, last_used_at`
+
+type PostgresAccessTokenRepository struct {
+	db *sqlx.DB
+}
+
+func NewPostgresAccessTokenRepository(db *sqlx.DB) *PostgresAccessTokenRepository {
+	return &PostgresAccessTokenRepository{db: db}
+}
+
+// Create stores a new token
+func (r *PostgresAccessTokenRepository) Create(ctx context.Context, token *models.AccessToken) error {
+	query := `
+		INSERT INTO access_tokens (user_id, name, token_hash, prefix, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+	return conn(ctx, r.db).QueryRowContext(
+		ctx, query, token.UserID, token.Name, token.TokenHash, token.Prefix, token.Scopes, token.ExpiresAt,
+	).Scan(&token.ID, &token.CreatedAt)
+}
+
+// GetByUser returns the user's tokens, oldest first
+func (r *PostgresAccessTokenRepository) GetByUser(ctx context.Context, userID uuid.UUID) ([]models.AccessToken, error) {
+	tokens := []models.AccessToken{}
+	query := `SELECT ` + accessTokenColumns + ` FROM access_tokens WHERE user_id = $1 ORDER BY created_at`
+	if err := conn(ctx, r.db).SelectContext(ctx, &tokens, query, userID); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// GetByHash returns the token with the given hash
+func (r *PostgresAccessTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*models.AccessToken, error) {
+	var token models.AccessToken
+	query := `SELECT ` + accessTokenColumns + ` FROM access_tokens WHERE token_hash = $1`
+	err := conn(ctx, r.db).GetContext(ctx, &token, query, tokenHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("token not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// RecordUse stores the time the token was last used
+func (r *PostgresAccessTokenRepository) RecordUse(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE access_tokens SET last_used_at = NOW() WHERE id = $1`
+	return execOne(ctx, r.db, "token not found", query, id)
+}
+
+// Delete revokes one of the user's tokens
+func (r *PostgresAccessTokenRepository) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	query := `DELETE FROM access_tokens WHERE id = $1 AND user_id = $2`
+	return execOne(ctx, r.db, "token not found", query, id, userID)
+}
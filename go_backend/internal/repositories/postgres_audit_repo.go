@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+const auditColumns = `
+	seq, actor_id, action, target_type, target_id, ip, user_agent, result,
+	details, created_at, prev_hash, hash
+`
+
+type PostgresAuditRepository struct {
+	db *sqlx.DB
+}
+
+func NewPostgresAuditRepository(db *sqlx.DB) *PostgresAuditRepository {
+	return &PostgresAuditRepository{db: db}
+}
+
+// Append adds an event to the end of the log
+func (r *PostgresAuditRepository) Append(ctx context.Context, event *models.AuditEvent) error {
+	return withinTx(ctx, r.db, func(ctx context.Context) error {
+		// Block other appends, but not readers, until this one commits
+		if _, err := conn(ctx, r.db).ExecContext(ctx, `LOCK TABLE audit_log IN EXCLUSIVE MODE`); err != nil {
+			return err
+		}
+
+		var last models.AuditEvent
+		query := `SELECT seq, hash FROM audit_log ORDER BY seq DESC LIMIT 1`
+		err := conn(ctx, r.db).GetContext(ctx, &last, query)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		chainAuditEvent(event, &last)
+
+		// created_at is hashed, so it is set here rather than by NOW()
+		query = `
+			INSERT INTO audit_log (` + auditColumns + `)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		`
+		_, err = conn(ctx, r.db).ExecContext(
+			ctx, query,
+			event.Seq, event.ActorID, event.Action, event.TargetType, event.TargetID, event.IP, event.UserAgent,
+			event.Result, event.Details, event.CreatedAt, event.PrevHash, event.Hash,
+		)
+		return err
+	})
+}
+
+// List returns the events matching filter, newest first
+func (r *PostgresAuditRepository) List(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error) {
+	var events []models.AuditEvent
+	query := `
+		SELECT ` + auditColumns + `
+		FROM audit_log
+		WHERE ($1::uuid IS NULL OR actor_id = $1)
+		  AND ($2 = '' OR action = $2)
+		  AND ($3 = '' OR target_id = $3)
+		  AND ($4 = '' OR result = $4)
+		  AND ($5::timestamp IS NULL OR created_at >= $5)
+		  AND ($6::timestamp IS NULL OR created_at < $6)
+		ORDER BY seq DESC
+		LIMIT $7 OFFSET $8
+	`
+	err := conn(ctx, r.db).SelectContext(
+		ctx, &events, query,
+		filter.ActorID, filter.Action, filter.TargetID, filter.Result,
+		utcOrNil(filter.Since), utcOrNil(filter.Until), filter.Limit, filter.Offset,
+	)
+	return events, err
+}
+
+// Range returns up to limit events after afterSeq, oldest first
+func (r *PostgresAuditRepository) Range(ctx context.Context, afterSeq int64, limit int) ([]models.AuditEvent, error) {
+	var events []models.AuditEvent
+	query := `
+		SELECT ` + auditColumns + `
+		FROM audit_log
+		WHERE seq > $1
+		ORDER BY seq ASC
+		LIMIT $2
+	`
+	err := conn(ctx, r.db).SelectContext(ctx, &events, query, afterSeq, limit)
+	return events, err
+}
+
+// chainAuditEvent makes event the successor of last (zero for an empty log)
+// and seals it with its hash
+func chainAuditEvent(event *models.AuditEvent, last *models.AuditEvent) {
+	event.Seq = last.Seq + 1
+	event.PrevHash = last.Hash
+	// Microseconds survive every backend's timestamp type, so the hash can be recomputed
+	event.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	event.Hash = event.ComputeHash()
+}
+
+// utcOrNil converts t to UTC, keeping nil as NULL
+func utcOrNil(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type PostgresFilterRepository struct {
+	db *sqlx.DB
+}
+
+func NewPostgresFilterRepository(db *sqlx.DB) *PostgresFilterRepository {
+	return &PostgresFilterRepository{db: db}
+}
+
+// AddWord adds a word to a room's list or updates its action
+func (r *PostgresFilterRepository) AddWord(ctx context.Context, word *models.WordFilter) error {
+	query := `
+		INSERT INTO room_word_filters (room_id, word, action, created_by, created_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (room_id, word) DO UPDATE
+		SET action = EXCLUDED.action, created_by = EXCLUDED.created_by, created_at = EXCLUDED.created_at
+		RETURNING created_at
+	`
+	return conn(ctx, r.db).QueryRowContext(
+		ctx, query,
+		word.RoomID, word.Word, word.Action, word.CreatedBy,
+	).Scan(&word.CreatedAt)
+}
+
+// RemoveWord removes a word from a room's list
+func (r *PostgresFilterRepository) RemoveWord(ctx context.Context, roomID uuid.UUID, word string) error {
+	query := `DELETE FROM room_word_filters WHERE room_id = $1 AND word = $2`
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, roomID, word)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("word is not filtered in this room")
+	}
+
+	return nil
+}
+
+// GetWords returns a room's word list
+func (r *PostgresFilterRepository) GetWords(ctx context.Context, roomID uuid.UUID) ([]models.WordFilter, error) {
+	var words []models.WordFilter
+	query := `
+		SELECT room_id, word, action, created_by, created_at
+		FROM room_word_filters
+		WHERE room_id = $1
+		ORDER BY word
+	`
+	err := conn(ctx, r.db).SelectContext(ctx, &words, query, roomID)
+	return words, err
+}
@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type PostgresIdentityRepository struct {
+	db *sqlx.DB
+}
+
+func NewPostgresIdentityRepository(db *sqlx.DB) *PostgresIdentityRepository {
+	return &PostgresIdentityRepository{db: db}
+}
+
+// Create links an external identity to a user
+func (r *PostgresIdentityRepository) Create(ctx context.Context, identity *models.Identity) error {
+	query := `
+		INSERT INTO user_identities (user_id, issuer, subject, email)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (issuer, subject) DO NOTHING
+		RETURNING id, created_at
+	`
+	err := conn(ctx, r.db).QueryRowContext(
+		ctx, query, identity.UserID, identity.Issuer, identity.Subject, identity.Email,
+	).Scan(&identity.ID, &identity.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("identity is already linked")
+	}
+	return err
+}
+
+// GetBySubject returns the identity the issuer knows by subject
+func (r *PostgresIdentityRepository) GetBySubject(ctx context.Context, issuer, subject string) (*models.Identity, error) {
+	var identity models.Identity
+	query := `
+		SELECT id, user_id, issuer, subject, email, created_at, last_login_at
+		FROM user_identities
+		WHERE issuer = $1 AND subject = $2
+	`
+	err := conn(ctx, r.db).GetContext(ctx, &identity, query, issuer, subject)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("identity not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// GetByIssuer returns every identity the issuer vouches for, oldest first
+func (r *PostgresIdentityRepository) GetByIssuer(ctx context.Context, issuer string) ([]models.Identity, error) {
+	identities := []models.Identity{}
+	query := `
+		SELECT id, user_id, issuer, subject, email, created_at, last_login_at
+		FROM user_identities
+		WHERE issuer = $1
+		ORDER BY created_at
+	`
+	if err := conn(ctx, r.db).SelectContext(ctx, &identities, query, issuer); err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+
+// GetByUser returns every identity linked to the user, oldest first
+func (r *PostgresIdentityRepository) GetByUser(ctx context.Context, userID uuid.UUID) ([]models.Identity, error) {
+	identities := []models.Identity{}
+	query := `
+		SELECT id, user_id, issuer, subject, email, created_at, last_login_at
+		FROM user_identities
+		WHERE user_id = $1
+		ORDER BY created_at
+	`
+	if err := conn(ctx, r.db).SelectContext(ctx, &identities, query, userID); err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+
+// RecordLogin stores the time of a login and the email the provider reported
+func (r *PostgresIdentityRepository) RecordLogin(ctx context.Context, id uuid.UUID, email string) error {
+	query := `UPDATE user_identities SET email = $2, last_login_at = NOW() WHERE id = $1`
+	return execOne(ctx, r.db, "identity not found", query, id, email)
+}
+
+// CreateLogin stores a login in progress, dropping expired ones
+func (r *PostgresIdentityRepository) CreateLogin(ctx context.Context, login *models.OIDCLogin) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM oidc_logins WHERE expires_at < NOW()`); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO oidc_logins (state, nonce, code_verifier, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, login.State, login.Nonce, login.CodeVerifier, login.ExpiresAt)
+	return err
+}
+
+// TakeLogin removes and returns an unexpired login
+func (r *PostgresIdentityRepository) TakeLogin(ctx context.Context, state string) (*models.OIDCLogin, error) {
+	var login models.OIDCLogin
+	query := `
+		DELETE FROM oidc_logins
+		WHERE state = $1 AND expires_at > NOW()
+		RETURNING state, nonce, code_verifier, expires_at
+	`
+	err := conn(ctx, r.db).GetContext(ctx, &login, query, state)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("login not found or expired")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &login, nil
+}
+
+// CreateSAMLRequest stores a SAML request awaiting a response, dropping expired ones
+func (r *PostgresIdentityRepository) CreateSAMLRequest(ctx context.Context, request *models.SAMLRequest) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM saml_requests WHERE expires_at < NOW()`); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO saml_requests (relay_state, request_id, expires_at)
+		VALUES ($1, $2, $3)
+	`
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, request.RelayState, request.RequestID, request.ExpiresAt)
+	return err
+}
+
+// TakeSAMLRequest removes and returns an unexpired SAML request
+func (r *PostgresIdentityRepository) TakeSAMLRequest(ctx context.Context, relayState string) (*models.SAMLRequest, error) {
+	var request models.SAMLRequest
+	query := `
+		DELETE FROM saml_requests
+		WHERE relay_state = $1 AND expires_at > NOW()
+		RETURNING relay_state, request_id, expires_at
+	`
+	err := conn(ctx, r.db).GetContext(ctx, &request, query, relayState)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("login not found or expired")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type PostgresLoginLinkRepository struct {
+	db *sqlx.DB
+}
+
+func NewPostgresLoginLinkRepository(db *sqlx.DB) *PostgresLoginLinkRepository {
+	return &PostgresLoginLinkRepository{db: db}
+}
+
+// Create stores a link, dropping links that expired over a day ago
+func (r *PostgresLoginLinkRepository) Create(ctx context.Context, link *models.LoginLink) error {
+	now := time.Now().UTC()
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM login_links WHERE expires_at < $1`, now.Add(-loginLinkRetention)); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO login_links (token_hash, user_id, device_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, link.TokenHash, link.UserID, link.DeviceHash, link.ExpiresAt.UTC(), now)
+	if err != nil {
+		return err
+	}
+
+	link.CreatedAt = now
+	return nil
+}
+
+// CountSince counts the links created for the user since the given time, used or not
+func (r *PostgresLoginLinkRepository) CountSince(ctx context.Context, userID uuid.UUID, since time.Time) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM login_links WHERE user_id = $1 AND created_at >= $2`
+	err := conn(ctx, r.db).GetContext(ctx, &count, query, userID, since.UTC())
+	return count, err
+}
+
+// Use marks an unused, unexpired link as used and returns it
+func (r *PostgresLoginLinkRepository) Use(ctx context.Context, tokenHash string) (*models.LoginLink, error) {
+	var link models.LoginLink
+	now := time.Now().UTC()
+	query := `
+		UPDATE login_links SET used_at = $2
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2
+		RETURNING token_hash, user_id, device_hash, expires_at, used_at, created_at
+	`
+	err := conn(ctx, r.db).GetContext(ctx, &link, query, tokenHash, now)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("link not found or expired")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
@@ -0,0 +1,143 @@
+package repository
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "strings"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/jmoiron/sqlx"
+    "github.com/GavinHemsada/go-backend/internal/models"
+)
+
+type PostgresMessageRepository struct {
+    db *sqlx.DB
+}
+
+func NewPostgresMessageRepository(db *sqlx.DB) *PostgresMessageRepository {
+    return &PostgresMessageRepository{db: db}
+}
+
+func (r *PostgresMessageRepository) Create(ctx context.Context, msg *models.Message) error {
+    query := `
+        INSERT INTO messages (room_id, user_id, content, message_type)
+        VALUES ($1, $2, $3, $4)
+        RETURNING id, created_at
+    `
+    return conn(ctx, r.db).QueryRowContext(
+        ctx, query,
+        msg.RoomID, msg.UserID, msg.Content, msg.MessageType,
+    ).Scan(&msg.ID, &msg.CreatedAt)
+}
+
+// CreateBatch stores msgs with multi-row inserts in one transaction.
+// Each row is stamped NOW() plus its position in microseconds so the batch
+// keeps its order when listed by created_at.
+func (r *PostgresMessageRepository) CreateBatch(ctx context.Context, msgs []*models.Message) error {
+    return withinTx(ctx, r.db, func(ctx context.Context) error {
+        for start := 0; start < len(msgs); start += maxBatchRows {
+            end := start + maxBatchRows
+            if end > len(msgs) {
+                end = len(msgs)
+            }
+            if err := r.insertRows(ctx, msgs[start:end], start); err != nil {
+                return err
+            }
+        }
+        return nil
+    })
+}
+
+func (r *PostgresMessageRepository) insertRows(ctx context.Context, msgs []*models.Message, offset int) error {
+    values := make([]string, len(msgs))
+    args := make([]interface{}, 0, len(msgs)*6)
+    byID := make(map[uuid.UUID]*models.Message, len(msgs))
+    for i, msg := range msgs {
+        msg.ID = uuid.New()
+        byID[msg.ID] = msg
+        n := len(args)
+        values[i] = fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, NOW() + $%d * INTERVAL '1 microsecond')", n+1, n+2, n+3, n+4, n+5, n+6)
+        args = append(args, msg.ID, msg.RoomID, msg.UserID, msg.Content, msg.MessageType, offset+i)
+    }
+
+    query := `
+        INSERT INTO messages (id, room_id, user_id, content, message_type, created_at)
+        VALUES ` + strings.Join(values, ", ") + `
+        RETURNING id, created_at
+    `
+    var rows []struct {
+        ID        uuid.UUID `db:"id"`
+        CreatedAt time.Time `db:"created_at"`
+    }
+    if err := conn(ctx, r.db).SelectContext(ctx, &rows, query, args...); err != nil {
+        return err
+    }
+    for _, row := range rows {
+        byID[row.ID].CreatedAt = row.CreatedAt
+    }
+    return nil
+}
+
+// GetByID returns a non-deleted message with its author's username
+func (r *PostgresMessageRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Message, error) {
+    var message models.Message
+    query := `
+        SELECT m.id, m.room_id, m.user_id, m.content, m.message_type, m.created_at,
+            CASE WHEN u.status = 'deactivated' THEN 'deactivated' ELSE COALESCE(u.username, '') END AS username,
+            COALESCE(u.is_bot, FALSE) AS is_bot
+        FROM messages m
+        LEFT JOIN users u ON m.user_id = u.id
+        WHERE m.id = $1 AND m.is_deleted = false
+    `
+    err := conn(ctx, r.db).GetContext(ctx, &message, query, id)
+    if err != nil {
+        if errors.Is(err, sql.ErrNoRows) {
+            return nil, errors.New("message not found")
+        }
+        return nil, err
+    }
+    return &message, nil
+}
+
+func (r *PostgresMessageRepository) GetByRoom(ctx context.Context, roomID uuid.UUID, limit, offset int) ([]models.Message, error) {
+    query := `
+        SELECT m.id, m.room_id, m.user_id, m.content, m.message_type, m.created_at,
+            CASE WHEN u.status = 'deactivated' THEN 'deactivated' ELSE u.username END AS username,
+            u.is_bot
+        FROM messages m
+        JOIN users u ON m.user_id = u.id
+        WHERE m.room_id = $1 AND m.is_deleted = false
+        ORDER BY m.created_at DESC
+        LIMIT $2 OFFSET $3
+    `
+    
+    var messages []models.Message
+    err := conn(ctx, r.db).SelectContext(ctx, &messages, query, roomID, limit, offset)
+    return messages, err
+}
+
+// Delete soft-deletes a message
+func (r *PostgresMessageRepository) Delete(ctx context.Context, id uuid.UUID) error {
+    query := `
+        UPDATE messages SET is_deleted = true, updated_at = NOW()
+        WHERE id = $1 AND is_deleted = false
+    `
+    result, err := conn(ctx, r.db).ExecContext(ctx, query, id)
+    if err != nil {
+        return err
+    }
+
+    rowsAffected, err := result.RowsAffected()
+    if err != nil {
+        return err
+    }
+
+    if rowsAffected == 0 {
+        return errors.New("message not found")
+    }
+
+    return nil
+}
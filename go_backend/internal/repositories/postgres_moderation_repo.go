@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type PostgresModerationRepository struct {
+	db *sqlx.DB
+}
+
+func NewPostgresModerationRepository(db *sqlx.DB) *PostgresModerationRepository {
+	return &PostgresModerationRepository{db: db}
+}
+
+// Ban bans a user from a room. Expiry is computed from NOW() so it compares
+// correctly with the database clock used by the reads.
+func (r *PostgresModerationRepository) Ban(ctx context.Context, ban *models.RoomBan, duration time.Duration) error {
+	var seconds *float64
+	if duration > 0 {
+		s := duration.Seconds()
+		seconds = &s
+	}
+
+	query := `
+		INSERT INTO room_bans (room_id, user_id, banned_by, reason, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, NOW() + $5::float8 * INTERVAL '1 second', NOW())
+		ON CONFLICT (room_id, user_id) DO UPDATE
+		SET banned_by = EXCLUDED.banned_by, reason = EXCLUDED.reason,
+		    expires_at = EXCLUDED.expires_at, created_at = EXCLUDED.created_at
+		RETURNING expires_at, created_at
+	`
+	return conn(ctx, r.db).QueryRowContext(
+		ctx, query,
+		ban.RoomID, ban.UserID, ban.BannedBy, ban.Reason, seconds,
+	).Scan(&ban.ExpiresAt, &ban.CreatedAt)
+}
+
+// Unban lifts a user's ban from a room
+func (r *PostgresModerationRepository) Unban(ctx context.Context, roomID, userID uuid.UUID) error {
+	query := `
+		DELETE FROM room_bans
+		WHERE room_id = $1 AND user_id = $2 AND (expires_at IS NULL OR expires_at > NOW())
+	`
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, roomID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("user is not banned from this room")
+	}
+
+	return nil
+}
+
+// GetBan returns the user's active ban in a room, or nil if there is none
+func (r *PostgresModerationRepository) GetBan(ctx context.Context, roomID, userID uuid.UUID) (*models.RoomBan, error) {
+	var ban models.RoomBan
+	query := `
+		SELECT room_id, user_id, banned_by, reason, expires_at, created_at
+		FROM room_bans
+		WHERE room_id = $1 AND user_id = $2 AND (expires_at IS NULL OR expires_at > NOW())
+	`
+	err := conn(ctx, r.db).GetContext(ctx, &ban, query, roomID, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &ban, nil
+}
+
+// GetBans returns the active bans of a room
+func (r *PostgresModerationRepository) GetBans(ctx context.Context, roomID uuid.UUID) ([]models.RoomBan, error) {
+	var bans []models.RoomBan
+	query := `
+		SELECT room_id, user_id, banned_by, reason, expires_at, created_at
+		FROM room_bans
+		WHERE room_id = $1 AND (expires_at IS NULL OR expires_at > NOW())
+		ORDER BY created_at DESC
+	`
+	err := conn(ctx, r.db).SelectContext(ctx, &bans, query, roomID)
+	return bans, err
+}
+
+// Mute mutes a user in a room
+func (r *PostgresModerationRepository) Mute(ctx context.Context, mute *models.RoomMute, duration time.Duration) error {
+	query := `
+		INSERT INTO room_mutes (room_id, user_id, muted_by, reason, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, NOW() + $5::float8 * INTERVAL '1 second', NOW())
+		ON CONFLICT (room_id, user_id) DO UPDATE
+		SET muted_by = EXCLUDED.muted_by, reason = EXCLUDED.reason,
+		    expires_at = EXCLUDED.expires_at, created_at = EXCLUDED.created_at
+		RETURNING expires_at, created_at
+	`
+	return conn(ctx, r.db).QueryRowContext(
+		ctx, query,
+		mute.RoomID, mute.UserID, mute.MutedBy, mute.Reason, duration.Seconds(),
+	).Scan(&mute.ExpiresAt, &mute.CreatedAt)
+}
+
+// Unmute lifts a user's mute in a room
+func (r *PostgresModerationRepository) Unmute(ctx context.Context, roomID, userID uuid.UUID) error {
+	query := `
+		DELETE FROM room_mutes
+		WHERE room_id = $1 AND user_id = $2 AND expires_at > NOW()
+	`
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, roomID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("user is not muted in this room")
+	}
+
+	return nil
+}
+
+// GetMute returns the user's active mute in a room, or nil if there is none
+func (r *PostgresModerationRepository) GetMute(ctx context.Context, roomID, userID uuid.UUID) (*models.RoomMute, error) {
+	var mute models.RoomMute
+	query := `
+		SELECT room_id, user_id, muted_by, reason, expires_at, created_at
+		FROM room_mutes
+		WHERE room_id = $1 AND user_id = $2 AND expires_at > NOW()
+	`
+	err := conn(ctx, r.db).GetContext(ctx, &mute, query, roomID, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &mute, nil
+}
+
+// GetMutes returns the active mutes of a room
+func (r *PostgresModerationRepository) GetMutes(ctx context.Context, roomID uuid.UUID) ([]models.RoomMute, error) {
+	var mutes []models.RoomMute
+	query := `
+		SELECT room_id, user_id, muted_by, reason, expires_at, created_at
+		FROM room_mutes
+		WHERE room_id = $1 AND expires_at > NOW()
+		ORDER BY created_at DESC
+	`
+	err := conn(ctx, r.db).SelectContext(ctx, &mutes, query, roomID)
+	return mutes, err
+}
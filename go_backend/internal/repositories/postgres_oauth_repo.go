@@ -0,0 +1,209 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	oauthAppColumns   = `id, owner_id, name, secret_hash, redirect_uris, created_at`
+	oauthTokenColumns = `id, app_id, user_id, access_hash, refresh_hash, scopes, access_expires_at, refresh_expires_at, created_at`
+)
+
+type PostgresOAuthRepository struct {
+	db *sqlx.DB
+}
+
+func NewPostgresOAuthRepository(db *sqlx.DB) *PostgresOAuthRepository {
+	return &PostgresOAuthRepository{db: db}
+}
+
+// CreateApp stores a new app
+func (r *PostgresOAuthRepository) CreateApp(ctx context.Context, app *models.OAuthApp) error {
+	query := `
+		INSERT INTO oauth_apps (owner_id, name, secret_hash, redirect_uris)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+	return conn(ctx, r.db).QueryRowContext(
+		ctx, query, app.OwnerID, app.Name, app.SecretHash, app.RedirectURIs,
+	).Scan(&app.ID, &app.CreatedAt)
+}
+
+// GetApp returns the app with the given ID
+func (r *PostgresOAuthRepository) GetApp(ctx context.Context, id uuid.UUID) (*models.OAuthApp, error) {
+	var app models.OAuthApp
+	query := `SELECT ` + oauthAppColumns + ` FROM oauth_apps WHERE id = $1`
+	err := conn(ctx, r.db).GetContext(ctx, &app, query, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("app not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &app, nil
+}
+
+// GetAppsByOwner returns the apps the user registered, oldest first
+func (r *PostgresOAuthRepository) GetAppsByOwner(ctx context.Context, ownerID uuid.UUID) ([]models.OAuthApp, error) {
+	apps := []models.OAuthApp{}
+	query := `SELECT ` + oauthAppColumns + ` FROM oauth_apps WHERE owner_id = $1 ORDER BY created_at`
+	if err := conn(ctx, r.db).SelectContext(ctx, &apps, query, ownerID); err != nil {
+		return nil, err
+	}
+	return apps, nil
+}
+
+// DeleteApp removes one of the owner's apps; codes, consents and tokens cascade
+func (r *PostgresOAuthRepository) DeleteApp(ctx context.Context, ownerID, id uuid.UUID) error {
+	query := `DELETE FROM oauth_apps WHERE id = $1 AND owner_id = $2`
+	return execOne(ctx, r.db, "app not found", query, id, ownerID)
+}
+
+// CreateCode stores an authorization code, dropping expired ones
+func (r *PostgresOAuthRepository) CreateCode(ctx context.Context, code *models.OAuthCode) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM oauth_codes WHERE expires_at < NOW()`); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO oauth_codes (code_hash, app_id, user_id, redirect_uri, scopes, code_challenge, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx, query, code.CodeHash, code.AppID, code.UserID, code.RedirectURI, code.Scopes, code.CodeChallenge, code.ExpiresAt,
+	)
+	return err
+}
+
+// TakeCode removes and returns an unexpired code
+func (r *PostgresOAuthRepository) TakeCode(ctx context.Context, codeHash string) (*models.OAuthCode, error) {
+	var code models.OAuthCode
+	query := `
+		DELETE FROM oauth_codes
+		WHERE code_hash = $1 AND expires_at > NOW()
+		RETURNING code_hash, app_id, user_id, redirect_uri, scopes, code_challenge, expires_at
+	`
+	err := conn(ctx, r.db).GetContext(ctx, &code, query, codeHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("code not found or expired")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+// GetConsent returns the scopes the user granted the app, or nil if there is no consent
+func (r *PostgresOAuthRepository) GetConsent(ctx context.Context, userID, appID uuid.UUID) (*models.OAuthConsent, error) {
+	var consent models.OAuthConsent
+	query := `
+		SELECT c.user_id, c.app_id, a.name AS app_name, c.scopes, c.granted_at
+		FROM oauth_consents c
+		INNER JOIN oauth_apps a ON c.app_id = a.id
+		WHERE c.user_id = $1 AND c.app_id = $2
+	`
+	err := conn(ctx, r.db).GetContext(ctx, &consent, query, userID, appID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &consent, nil
+}
+
+// SaveConsent stores the scopes the user granted the app, replacing earlier ones
+func (r *PostgresOAuthRepository) SaveConsent(ctx context.Context, consent *models.OAuthConsent) error {
+	query := `
+		INSERT INTO oauth_consents (user_id, app_id, scopes)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, app_id) DO UPDATE SET scopes = EXCLUDED.scopes, granted_at = NOW()
+		RETURNING granted_at
+	`
+	return conn(ctx, r.db).QueryRowContext(
+		ctx, query, consent.UserID, consent.AppID, consent.Scopes,
+	).Scan(&consent.GrantedAt)
+}
+
+// GetConsents returns the apps the user authorized, oldest first
+func (r *PostgresOAuthRepository) GetConsents(ctx context.Context, userID uuid.UUID) ([]models.OAuthConsent, error) {
+	consents := []models.OAuthConsent{}
+	query := `
+		SELECT c.user_id, c.app_id, a.name AS app_name, c.scopes, c.granted_at
+		FROM oauth_consents c
+		INNER JOIN oauth_apps a ON c.app_id = a.id
+		WHERE c.user_id = $1
+		ORDER BY c.granted_at
+	`
+	if err := conn(ctx, r.db).SelectContext(ctx, &consents, query, userID); err != nil {
+		return nil, err
+	}
+	return consents, nil
+}
+
+// DeleteConsent removes the user's consent to the app and the app's codes and tokens for the user
+func (r *PostgresOAuthRepository) DeleteConsent(ctx context.Context, userID, appID uuid.UUID) error {
+	return withinTx(ctx, r.db, func(ctx context.Context) error {
+		for _, query := range []string{
+			`DELETE FROM oauth_codes WHERE user_id = $1 AND app_id = $2`,
+			`DELETE FROM oauth_tokens WHERE user_id = $1 AND app_id = $2`,
+		} {
+			if _, err := conn(ctx, r.db).ExecContext(ctx, query, userID, appID); err != nil {
+				return err
+			}
+		}
+		query := `DELETE FROM oauth_consents WHERE user_id = $1 AND app_id = $2`
+		return execOne(ctx, r.db, "authorization not found", query, userID, appID)
+	})
+}
+
+// CreateToken stores a new token, dropping those whose refresh token expired
+func (r *PostgresOAuthRepository) CreateToken(ctx context.Context, token *models.OAuthToken) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM oauth_tokens WHERE refresh_expires_at < NOW()`); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO oauth_tokens (app_id, user_id, access_hash, refresh_hash, scopes, access_expires_at, refresh_expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`
+	return conn(ctx, r.db).QueryRowContext(
+		ctx, query, token.AppID, token.UserID, token.AccessHash, token.RefreshHash, token.Scopes, token.AccessExpiresAt, token.RefreshExpiresAt,
+	).Scan(&token.ID, &token.CreatedAt)
+}
+
+// GetTokenByAccessHash returns the token with the given access token hash
+func (r *PostgresOAuthRepository) GetTokenByAccessHash(ctx context.Context, accessHash string) (*models.OAuthToken, error) {
+	return r.getToken(ctx, `access_hash = $1`, accessHash)
+}
+
+// GetTokenByRefreshHash returns the token with the given refresh token hash
+func (r *PostgresOAuthRepository) GetTokenByRefreshHash(ctx context.Context, refreshHash string) (*models.OAuthToken, error) {
+	return r.getToken(ctx, `refresh_hash = $1`, refreshHash)
+}
+
+func (r *PostgresOAuthRepository) getToken(ctx context.Context, where string, hash string) (*models.OAuthToken, error) {
+	var token models.OAuthToken
+	query := `SELECT ` + oauthTokenColumns + ` FROM oauth_tokens WHERE ` + where
+	err := conn(ctx, r.db).GetContext(ctx, &token, query, hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("token not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// DeleteToken revokes a token
+func (r *PostgresOAuthRepository) DeleteToken(ctx context.Context, id uuid.UUID) error {
+	query := `DELETE FROM oauth_tokens WHERE id = $1`
+	return execOne(ctx, r.db, "token not found", query, id)
+}
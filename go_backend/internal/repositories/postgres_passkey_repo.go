@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+const passkeyColumns = `id, user_id, credential_id, name, public_key, attestation_type, transports, aaguid, flags, sign_count, clone_warning, created_at, last_used_at`
+
+type PostgresPasskeyRepository struct {
+	db *sqlx.DB
+}
+
+func NewPostgresPasskeyRepository(db *sqlx.DB) *PostgresPasskeyRepository {
+	return &PostgresPasskeyRepository{db: db}
+}
+
+// Create stores a new passkey
+func (r *PostgresPasskeyRepository) Create(ctx context.Context, passkey *models.Passkey) error {
+	query := `
+		INSERT INTO webauthn_credentials (user_id, credential_id, name, public_key, attestation_type, transports, aaguid, flags, sign_count, clone_warning)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (credential_id) DO NOTHING
+		RETURNING id, created_at
+	`
+	err := conn(ctx, r.db).QueryRowContext(
+		ctx, query,
+		passkey.UserID, passkey.CredentialID, passkey.Name, passkey.PublicKey, passkey.AttestationType,
+		passkey.Transports, passkey.AAGUID, passkey.Flags, passkey.SignCount, passkey.CloneWarning,
+	).Scan(&passkey.ID, &passkey.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("passkey is already registered")
+	}
+	return err
+}
+
+// GetByUser returns the user's passkeys, oldest first
+func (r *PostgresPasskeyRepository) GetByUser(ctx context.Context, userID uuid.UUID) ([]models.Passkey, error) {
+	var passkeys []models.Passkey
+	query := `SELECT ` + passkeyColumns + ` FROM webauthn_credentials WHERE user_id = $1 ORDER BY created_at`
+	err := conn(ctx, r.db).SelectContext(ctx, &passkeys, query, userID)
+	return passkeys, err
+}
+
+// RecordUse stores the sign count and flags of a successful login
+func (r *PostgresPasskeyRepository) RecordUse(ctx context.Context, passkey *models.Passkey) error {
+	query := `
+		UPDATE webauthn_credentials
+		SET sign_count = $2, clone_warning = $3, flags = $4, last_used_at = NOW()
+		WHERE id = $1
+	`
+	return execOne(ctx, r.db, "passkey not found", query, passkey.ID, passkey.SignCount, passkey.CloneWarning, passkey.Flags)
+}
+
+// Delete removes one of the user's passkeys
+func (r *PostgresPasskeyRepository) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	query := `DELETE FROM webauthn_credentials WHERE id = $1 AND user_id = $2`
+	return execOne(ctx, r.db, "passkey not found", query, id, userID)
+}
+
+// CreateSession stores a ceremony in progress, dropping expired ones
+func (r *PostgresPasskeyRepository) CreateSession(ctx context.Context, session *models.PasskeySession) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM webauthn_sessions WHERE expires_at < NOW()`); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO webauthn_sessions (ceremony, user_id, data, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`
+	return conn(ctx, r.db).QueryRowContext(
+		ctx, query, session.Ceremony, session.UserID, session.Data, session.ExpiresAt,
+	).Scan(&session.ID)
+}
+
+// TakeSession removes and returns an unexpired ceremony
+func (r *PostgresPasskeyRepository) TakeSession(ctx context.Context, id uuid.UUID, ceremony string) (*models.PasskeySession, error) {
+	var session models.PasskeySession
+	query := `
+		DELETE FROM webauthn_sessions
+		WHERE id = $1 AND ceremony = $2 AND expires_at > NOW()
+		RETURNING id, ceremony, user_id, data, expires_at
+	`
+	err := conn(ctx, r.db).GetContext(ctx, &session, query, id, ceremony)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("passkey session not found or expired")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
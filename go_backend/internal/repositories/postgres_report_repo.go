@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+const reportColumns = `
+	id, room_id, reporter_id, target_type, message_id, target_user_id, message_content,
+	category, details, status, claimed_by, claimed_at, resolved_by, resolved_at,
+	resolution, resolution_note, created_at
+`
+
+type PostgresReportRepository struct {
+	db *sqlx.DB
+}
+
+func NewPostgresReportRepository(db *sqlx.DB) *PostgresReportRepository {
+	return &PostgresReportRepository{db: db}
+}
+
+// Create stores a new open report
+func (r *PostgresReportRepository) Create(ctx context.Context, report *models.Report) error {
+	report.Status = models.ReportStatusOpen
+	query := `
+		INSERT INTO reports (room_id, reporter_id, target_type, message_id, target_user_id, message_content, category, details, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at
+	`
+	return conn(ctx, r.db).QueryRowContext(
+		ctx, query,
+		report.RoomID, report.ReporterID, report.TargetType, report.MessageID, report.TargetUserID,
+		report.MessageContent, report.Category, report.Details, report.Status,
+	).Scan(&report.ID, &report.CreatedAt)
+}
+
+// GetByID retrieves a report by its ID
+func (r *PostgresReportRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Report, error) {
+	var report models.Report
+	query := `SELECT ` + reportColumns + ` FROM reports WHERE id = $1`
+	err := conn(ctx, r.db).GetContext(ctx, &report, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("report not found")
+		}
+		return nil, err
+	}
+	return &report, nil
+}
+
+// GetByRoom returns the reports of a room, oldest first, optionally filtered by status
+func (r *PostgresReportRepository) GetByRoom(ctx context.Context, roomID uuid.UUID, status string, limit, offset int) ([]models.Report, error) {
+	var reports []models.Report
+	query := `
+		SELECT ` + reportColumns + `
+		FROM reports
+		WHERE room_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY created_at ASC
+		LIMIT $3 OFFSET $4
+	`
+	err := conn(ctx, r.db).SelectContext(ctx, &reports, query, roomID, status, limit, offset)
+	return reports, err
+}
+
+// List returns the reports of every room, oldest first, optionally filtered by status
+func (r *PostgresReportRepository) List(ctx context.Context, status string, limit, offset int) ([]models.Report, error) {
+	var reports []models.Report
+	query := `
+		SELECT ` + reportColumns + `
+		FROM reports
+		WHERE $1 = '' OR status = $1
+		ORDER BY created_at ASC
+		LIMIT $2 OFFSET $3
+	`
+	err := conn(ctx, r.db).SelectContext(ctx, &reports, query, status, limit, offset)
+	return reports, err
+}
+
+// HasPending reports whether the reporter already has an unhandled report on the same target
+func (r *PostgresReportRepository) HasPending(ctx context.Context, report *models.Report) (bool, error) {
+	var exists bool
+	query := `
+		SELECT EXISTS(
+			SELECT 1 FROM reports
+			WHERE reporter_id = $1 AND room_id = $2 AND target_type = $3
+			  AND target_user_id = $4 AND message_id IS NOT DISTINCT FROM $5
+			  AND status IN ('open', 'claimed')
+		)
+	`
+	err := conn(ctx, r.db).GetContext(
+		ctx, &exists, query,
+		report.ReporterID, report.RoomID, report.TargetType, report.TargetUserID, report.MessageID,
+	)
+	return exists, err
+}
+
+// Claim assigns an open report to a moderator
+func (r *PostgresReportRepository) Claim(ctx context.Context, id, moderatorID uuid.UUID) error {
+	query := `
+		UPDATE reports SET status = 'claimed', claimed_by = $2, claimed_at = NOW()
+		WHERE id = $1 AND status = 'open'
+	`
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, id, moderatorID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("report is not open")
+	}
+
+	return nil
+}
+
+// Close resolves or dismisses a report that is open or claimed by the moderator
+func (r *PostgresReportRepository) Close(ctx context.Context, id, moderatorID uuid.UUID, status, resolution, note string) error {
+	query := `
+		UPDATE reports
+		SET status = $3, resolved_by = $2, resolved_at = NOW(), resolution = $4, resolution_note = $5
+		WHERE id = $1 AND (status = 'open' OR (status = 'claimed' AND claimed_by = $2))
+	`
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, id, moderatorID, status, resolution, note)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("report is closed or claimed by another moderator")
+	}
+
+	return nil
+}
+
+// AddAction appends an entry to a report's audit trail
+func (r *PostgresReportRepository) AddAction(ctx context.Context, action *models.ReportAction) error {
+	query := `
+		INSERT INTO report_actions (report_id, actor_id, action, note)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+	return conn(ctx, r.db).QueryRowContext(
+		ctx, query,
+		action.ReportID, action.ActorID, action.Action, action.Note,
+	).Scan(&action.ID, &action.CreatedAt)
+}
+
+// GetActions returns a report's audit trail, oldest first
+func (r *PostgresReportRepository) GetActions(ctx context.Context, reportID uuid.UUID) ([]models.ReportAction, error) {
+	var actions []models.ReportAction
+	query := `
+		SELECT id, report_id, actor_id, action, note, created_at
+		FROM report_actions
+		WHERE report_id = $1
+		ORDER BY created_at ASC
+	`
+	err := conn(ctx, r.db).SelectContext(ctx, &actions, query, reportID)
+	return actions, err
+}
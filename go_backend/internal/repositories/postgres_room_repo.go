@@ -0,0 +1,217 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type PostgresRoomRepository struct {
+	db *sqlx.DB
+}
+
+func NewPostgresRoomRepository(db *sqlx.DB) *PostgresRoomRepository {
+	return &PostgresRoomRepository{db: db}
+}
+
+// Create creates a new room and adds its creator as a member in one transaction
+func (r *PostgresRoomRepository) Create(ctx context.Context, room *models.Room) error {
+	room.ID = uuid.New()
+	return withinTx(ctx, r.db, func(ctx context.Context) error {
+		query := `
+			INSERT INTO rooms (id, name, room_type, created_by)
+			VALUES ($1, $2, $3, $4)
+			RETURNING created_at
+		`
+		err := conn(ctx, r.db).QueryRowContext(
+			ctx, query,
+			room.ID, room.Name, room.RoomType, room.CreatedBy,
+		).Scan(&room.CreatedAt)
+
+		if err != nil {
+			return err
+		}
+
+		// Automatically add creator as a member
+		return r.AddMember(ctx, room.ID, room.CreatedBy)
+	})
+}
+
+// GetByID retrieves a room by its ID
+func (r *PostgresRoomRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Room, error) {
+	var room models.Room
+	query := `
+		SELECT id, name, room_type, created_by, created_at
+		FROM rooms
+		WHERE id = $1
+	`
+	err := conn(ctx, r.db).GetContext(ctx, &room, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("room not found")
+		}
+		return nil, err
+	}
+	return &room, nil
+}
+
+// GetAll retrieves all rooms
+func (r *PostgresRoomRepository) GetAll(ctx context.Context) ([]models.Room, error) {
+	var rooms []models.Room
+	query := `
+		SELECT id, name, room_type, created_by, created_at
+		FROM rooms
+		ORDER BY created_at DESC
+	`
+	err := conn(ctx, r.db).SelectContext(ctx, &rooms, query)
+	return rooms, err
+}
+
+// GetUserRooms retrieves all rooms a user is a member of
+func (r *PostgresRoomRepository) GetUserRooms(ctx context.Context, userID uuid.UUID) ([]models.Room, error) {
+	var rooms []models.Room
+	query := `
+		SELECT r.id, r.name, r.room_type, r.created_by, r.created_at
+		FROM rooms r
+		INNER JOIN room_members rm ON r.id = rm.room_id
+		WHERE rm.user_id = $1
+		ORDER BY r.created_at DESC
+	`
+	err := conn(ctx, r.db).SelectContext(ctx, &rooms, query, userID)
+	return rooms, err
+}
+
+// Delete deletes a room (only if user is the creator)
+func (r *PostgresRoomRepository) Delete(ctx context.Context, roomID, userID uuid.UUID) error {
+	// Check ownership and delete in a single statement so the room cannot
+	// change hands between the check and the delete
+	query := `DELETE FROM rooms WHERE id = $1 AND created_by = $2`
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, roomID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		// Nothing deleted: tell apart a missing room from a non-creator
+		if _, err := r.GetByID(ctx, roomID); err != nil {
+			return err
+		}
+		return errors.New("only room creator can delete the room")
+	}
+
+	return nil
+}
+
+// ForceDelete deletes a room with its members and messages, whoever created it
+func (r *PostgresRoomRepository) ForceDelete(ctx context.Context, roomID uuid.UUID) error {
+	query := `DELETE FROM rooms WHERE id = $1`
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, roomID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("room not found")
+	}
+
+	return nil
+}
+
+// AddMember adds a user to a room
+func (r *PostgresRoomRepository) AddMember(ctx context.Context, roomID, userID uuid.UUID) error {
+	query := `
+		INSERT INTO room_members (room_id, user_id)
+		VALUES ($1, $2)
+		ON CONFLICT (room_id, user_id) DO NOTHING
+	`
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, roomID, userID)
+	return err
+}
+
+// RemoveMember removes a user from a room
+func (r *PostgresRoomRepository) RemoveMember(ctx context.Context, roomID, userID uuid.UUID) error {
+	query := `DELETE FROM room_members WHERE room_id = $1 AND user_id = $2`
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, roomID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("user is not a member of this room")
+	}
+
+	return nil
+}
+
+// GetMembers retrieves all members of a room
+func (r *PostgresRoomRepository) GetMembers(ctx context.Context, roomID uuid.UUID) ([]models.RoomMember, error) {
+	var members []models.RoomMember
+	query := `
+		SELECT room_id, user_id, joined_at
+		FROM room_members
+		WHERE room_id = $1
+		ORDER BY joined_at ASC
+	`
+	err := conn(ctx, r.db).SelectContext(ctx, &members, query, roomID)
+	return members, err
+}
+
+// IsMember checks if a user is a member of a room. Inside a transaction the
+// membership row is locked FOR SHARE, so removing the member waits until the
+// transaction ends.
+func (r *PostgresRoomRepository) IsMember(ctx context.Context, roomID, userID uuid.UUID) (bool, error) {
+	var found int
+	query := `
+		SELECT 1
+		FROM room_members
+		WHERE room_id = $1 AND user_id = $2
+	`
+	if _, ok := TxFromContext(ctx); ok {
+		query += "FOR SHARE"
+	}
+	err := conn(ctx, r.db).GetContext(ctx, &found, query, roomID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// GetRoommates returns those of candidates who share a room with the user
+func (r *PostgresRoomRepository) GetRoommates(ctx context.Context, userID uuid.UUID, candidates []uuid.UUID) ([]uuid.UUID, error) {
+	roommates := []uuid.UUID{}
+	if len(candidates) == 0 {
+		return roommates, nil
+	}
+
+	query, args, err := sqlx.In(`
+		SELECT DISTINCT other.user_id
+		FROM room_members own
+		JOIN room_members other ON other.room_id = own.room_id
+		WHERE own.user_id = ? AND other.user_id IN (?)
+	`, userID, candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	err = conn(ctx, r.db).SelectContext(ctx, &roommates, r.db.Rebind(query), args...)
+	return roommates, err
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type PostgresSettingsRepository struct {
+	db *sqlx.DB
+}
+
+func NewPostgresSettingsRepository(db *sqlx.DB) *PostgresSettingsRepository {
+	return &PostgresSettingsRepository{db: db}
+}
+
+// Get returns a setting's value, or "" if it was never set
+func (r *PostgresSettingsRepository) Get(ctx context.Context, key string) (string, error) {
+	var value string
+	err := conn(ctx, r.db).GetContext(ctx, &value, `SELECT value FROM settings WHERE key = $1`, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return value, err
+}
+
+// Set stores a setting's value
+func (r *PostgresSettingsRepository) Set(ctx context.Context, key, value string) error {
+	query := `
+		INSERT INTO settings (key, value, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (key) DO UPDATE
+		SET value = EXCLUDED.value, updated_at = EXCLUDED.updated_at
+	`
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, key, value)
+	return err
+}
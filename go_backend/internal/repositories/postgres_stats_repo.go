@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+type PostgresStatsRepository struct {
+	db *sqlx.DB
+}
+
+func NewPostgresStatsRepository(db *sqlx.DB) *PostgresStatsRepository {
+	return &PostgresStatsRepository{db: db}
+}
+
+// GetStats counts users, rooms, messages and pending reports
+func (r *PostgresStatsRepository) GetStats(ctx context.Context) (*models.ServerStats, error) {
+	var stats models.ServerStats
+
+	query := `
+		SELECT
+			(SELECT COUNT(*) FROM users) AS users,
+			(SELECT COUNT(*) FROM rooms) AS rooms,
+			(SELECT COUNT(*) FROM messages WHERE is_deleted = FALSE) AS messages,
+			(SELECT COUNT(*) FROM reports WHERE status IN ('open', 'claimed')) AS open_reports
+	`
+	if err := conn(ctx, r.db).GetContext(ctx, &stats, query); err != nil {
+		return nil, err
+	}
+
+	return &stats, nil
+}
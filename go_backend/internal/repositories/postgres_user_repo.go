@@ -0,0 +1,281 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type PostgresUserRepository struct {
+    db *sqlx.DB
+}
+
+func NewPostgresUserRepository(db *sqlx.DB) *PostgresUserRepository {
+    return &PostgresUserRepository{db: db}
+}
+
+// Register creates a new user with an already hashed password
+func (r *PostgresUserRepository) Register(ctx context.Context, username, email, passwordHash string) (*models.User, error) {
+    user := &models.User{
+        ID:           uuid.New(),
+        Username:     username,
+        Email:        email,
+        PasswordHash: passwordHash,
+        Role:         models.RoleUser,
+        Status:       models.UserStatusActive,
+    }
+
+    query := `
+        INSERT INTO users (id, username, email, password_hash)
+        VALUES ($1, $2, $3, $4)
+        RETURNING created_at
+    `
+    
+    err := conn(ctx, r.db).QueryRowContext(
+        ctx, query,
+        user.ID, user.Username, user.Email, user.PasswordHash,
+    ).Scan(&user.CreatedAt)
+    
+    if err != nil {
+        return nil, err
+    }
+
+    return user, nil
+}
+
+// CreateBot creates a bot account owned by ownerID
+func (r *PostgresUserRepository) CreateBot(ctx context.Context, ownerID uuid.UUID, username, displayName string) (*models.User, error) {
+    user := &models.User{
+        ID:          uuid.New(),
+        Username:    username,
+        DisplayName: displayName,
+        Role:        models.RoleUser,
+        Status:      models.UserStatusActive,
+        IsBot:       true,
+        OwnerID:     &ownerID,
+    }
+    user.Email = user.ID.String() + "@" + models.BotEmailDomain
+
+    // An empty hash matches no password
+    query := `
+        INSERT INTO users (id, username, email, password_hash, display_name, is_bot, owner_id)
+        VALUES ($1, $2, $3, '', $4, TRUE, $5)
+        RETURNING created_at
+    `
+
+    err := conn(ctx, r.db).QueryRowContext(
+        ctx, query,
+        user.ID, user.Username, user.Email, user.DisplayName, ownerID,
+    ).Scan(&user.CreatedAt)
+    if err != nil {
+        return nil, err
+    }
+
+    return user, nil
+}
+
+// GetByID retrieves a user by their ID
+func (r *PostgresUserRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+    var user models.User
+    
+    query := `
+        SELECT id, username, email, email_verified, password_hash, session_stamp, display_name, role, status, is_bot, owner_id,
+               bio, time_zone, pronouns, status_text, status_emoji, status_expires_at, avatar_id, last_seen_at, created_at
+        FROM users
+        WHERE id = $1
+    `
+    
+    err := conn(ctx, r.db).GetContext(ctx, &user, query, id)
+    if err != nil {
+        if errors.Is(err, sql.ErrNoRows) {
+            return nil, ErrUserNotFound
+        }
+        return nil, err
+    }
+
+    return &user, nil
+}
+
+// GetAll retrieves all users
+func (r *PostgresUserRepository) GetAll(ctx context.Context) ([]models.User, error) {
+    var users []models.User
+    
+    query := `
+        SELECT id, username, email, email_verified, password_hash, session_stamp, display_name, role, status, is_bot, owner_id,
+               bio, time_zone, pronouns, status_text, status_emoji, status_expires_at, avatar_id, last_seen_at, created_at
+        FROM users
+        ORDER BY created_at DESC
+    `
+    
+    err := conn(ctx, r.db).SelectContext(ctx, &users, query)
+    if err != nil {
+        return nil, err
+    }
+
+    return users, nil
+}
+
+// GetByOwner retrieves the bots owned by a user
+func (r *PostgresUserRepository) GetByOwner(ctx context.Context, ownerID uuid.UUID) ([]models.User, error) {
+    users := []models.User{}
+
+    query := `
+        SELECT id, username, email, email_verified, password_hash, session_stamp, display_name, role, status, is_bot, owner_id,
+               bio, time_zone, pronouns, status_text, status_emoji, status_expires_at, avatar_id, last_seen_at, created_at
+        FROM users
+        WHERE owner_id = $1
+        ORDER BY created_at
+    `
+
+    err := conn(ctx, r.db).SelectContext(ctx, &users, query, ownerID)
+    if err != nil {
+        return nil, err
+    }
+
+    return users, nil
+}
+
+// Search lists the users matching query, a page at a time
+func (r *PostgresUserRepository) Search(ctx context.Context, query string, limit, offset int) ([]models.User, int, error) {
+    users := []models.User{}
+    where := `
+        FROM users u
+        LEFT JOIN privacy_settings p ON p.user_id = u.id
+        WHERE u.status <> $1
+          AND (u.username ILIKE $2 ESCAPE '\' OR u.display_name ILIKE $2 ESCAPE '\'
+               OR (u.email = $3 AND p.email_visibility = $4))
+    `
+    args := []interface{}{models.UserStatusDeactivated, containsPattern(query), query, models.VisibilityEveryone}
+
+    var total int
+    if err := conn(ctx, r.db).GetContext(ctx, &total, `SELECT COUNT(*) `+where, args...); err != nil {
+        return nil, 0, err
+    }
+
+    page := `
+        SELECT u.id, u.username, u.email, u.email_verified, u.password_hash, u.session_stamp, u.display_name, u.role, u.status, u.is_bot, u.owner_id,
+               u.bio, u.time_zone, u.pronouns, u.status_text, u.status_emoji, u.status_expires_at, u.avatar_id, u.last_seen_at, u.created_at
+    ` + where + `
+        ORDER BY LOWER(u.username), u.id
+        LIMIT $5 OFFSET $6
+    `
+    err := conn(ctx, r.db).SelectContext(ctx, &users, page, append(args, limit, offset)...)
+    if err != nil {
+        return nil, 0, err
+    }
+
+    return users, total, nil
+}
+
+// GetByIdentifier retrieves a user by email or username
+func (r *PostgresUserRepository) GetByIdentifier(ctx context.Context, identifier string) (*models.User, error) {
+    var user models.User
+
+    query := `
+        SELECT id, username, email, email_verified, password_hash, session_stamp, display_name, role, status, is_bot, owner_id,
+               bio, time_zone, pronouns, status_text, status_emoji, status_expires_at, avatar_id, last_seen_at, created_at
+        FROM users
+        WHERE email = $1 OR username = $1
+    `
+
+    err := conn(ctx, r.db).GetContext(ctx, &user, query, identifier)
+    if err != nil {
+        if errors.Is(err, sql.ErrNoRows) {
+            return nil, errors.New("user not found")
+        }
+        return nil, err
+    }
+
+    return &user, nil
+}
+
+// SetRole changes a user's global role
+func (r *PostgresUserRepository) SetRole(ctx context.Context, id uuid.UUID, role string) error {
+    query := `UPDATE users SET role = $2, updated_at = NOW() WHERE id = $1`
+    return r.update(ctx, query, id, role)
+}
+
+// SetStatus changes a user's account status
+func (r *PostgresUserRepository) SetStatus(ctx context.Context, id uuid.UUID, status string) error {
+    query := `UPDATE users SET status = $2, updated_at = NOW() WHERE id = $1`
+    return r.update(ctx, query, id, status)
+}
+
+// SetDisplayName changes the name shown instead of the user's username
+func (r *PostgresUserRepository) SetDisplayName(ctx context.Context, id uuid.UUID, displayName string) error {
+    query := `UPDATE users SET display_name = $2, updated_at = NOW() WHERE id = $1`
+    return r.update(ctx, query, id, displayName)
+}
+
+// SetPasswordHash replaces a user's password hash
+func (r *PostgresUserRepository) SetPasswordHash(ctx context.Context, id uuid.UUID, passwordHash string) error {
+    query := `UPDATE users SET password_hash = $2, updated_at = NOW() WHERE id = $1`
+    return r.update(ctx, query, id, passwordHash)
+}
+
+// SetSessionStamp replaces the stamp session tokens must carry to be accepted
+func (r *PostgresUserRepository) SetSessionStamp(ctx context.Context, id uuid.UUID, stamp string) error {
+    query := `UPDATE users SET session_stamp = $2, updated_at = NOW() WHERE id = $1`
+    return r.update(ctx, query, id, stamp)
+}
+
+// SetEmailVerified records whether the user proved they own their email
+func (r *PostgresUserRepository) SetEmailVerified(ctx context.Context, id uuid.UUID, verified bool) error {
+    query := `UPDATE users SET email_verified = $2, updated_at = NOW() WHERE id = $1`
+    return r.update(ctx, query, id, verified)
+}
+
+// UpdateProfile saves the fields users edit on their profile
+func (r *PostgresUserRepository) UpdateProfile(ctx context.Context, user *models.User) error {
+    query := `
+        UPDATE users
+        SET username = $2, display_name = $3, bio = $4, time_zone = $5, pronouns = $6,
+            status_text = $7, status_emoji = $8, status_expires_at = $9, updated_at = NOW()
+        WHERE id = $1
+    `
+    return r.update(ctx, query,
+        user.ID, user.Username, user.DisplayName, user.Bio, user.TimeZone, user.Pronouns,
+        user.StatusText, user.StatusEmoji, user.StatusExpires,
+    )
+}
+
+// SetLastSeen records when the user was last active
+func (r *PostgresUserRepository) SetLastSeen(ctx context.Context, id uuid.UUID, seenAt time.Time) error {
+    query := `UPDATE users SET last_seen_at = $2 WHERE id = $1`
+    return r.update(ctx, query, id, seenAt.UTC())
+}
+
+func (r *PostgresUserRepository) update(ctx context.Context, query string, args ...interface{}) error {
+    result, err := conn(ctx, r.db).ExecContext(ctx, query, args...)
+    if err != nil {
+        return err
+    }
+
+    rowsAffected, err := result.RowsAffected()
+    if err != nil {
+        return err
+    }
+
+    if rowsAffected == 0 {
+        return errors.New("user not found")
+    }
+
+    return nil
+}
+
+// Delete deletes a user and, through the foreign key, their bots. Rooms they
+// created are kept without a creator.
+func (r *PostgresUserRepository) Delete(ctx context.Context, id uuid.UUID) error {
+    return withinTx(ctx, r.db, func(ctx context.Context) error {
+        // rooms.created_by has no ON DELETE action, so release it first
+        if _, err := conn(ctx, r.db).ExecContext(ctx, `UPDATE rooms SET created_by = NULL WHERE created_by = $1`, id); err != nil {
+            return err
+        }
+        return r.update(ctx, `DELETE FROM users WHERE id = $1`, id)
+    })
+}
@@ -0,0 +1,354 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+)
+
+// maxBatchRows caps the rows in a single multi-row INSERT so the statement
+// stays well below the bind parameter limits of PostgreSQL and SQLite
+const maxBatchRows = 500
+
+// How long login links are kept after they expire, so they still count
+// towards the rate limit
+const loginLinkRetention = 24 * time.Hour
+
+// ErrUserNotFound is returned by UserRepository.GetByID for unknown users
+var ErrUserNotFound = errors.New("user not found")
+
+// UserRepository stores user accounts
+type UserRepository interface {
+	// Register creates a new user with an already hashed password
+	Register(ctx context.Context, username, email, passwordHash string) (*models.User, error)
+	// CreateBot creates a bot account owned by ownerID. Bots have no password,
+	// so they can only use access tokens.
+	CreateBot(ctx context.Context, ownerID uuid.UUID, username, displayName string) (*models.User, error)
+	// GetByID returns the user with the given ID, or ErrUserNotFound
+	GetByID(ctx context.Context, id uuid.UUID) (*models.User, error)
+	// GetByIdentifier returns the user with the given email or username
+	GetByIdentifier(ctx context.Context, identifier string) (*models.User, error)
+	// GetAll returns all users, newest first
+	GetAll(ctx context.Context) ([]models.User, error)
+	// GetByOwner returns the bots owned by a user, oldest first
+	GetByOwner(ctx context.Context, ownerID uuid.UUID) ([]models.User, error)
+	// Search returns a page of the users who are not deactivated and whose
+	// username or display name contains query, ignoring case, or whose email
+	// is query and visible to everyone, by username. It also returns how many
+	// users match in all.
+	Search(ctx context.Context, query string, limit, offset int) ([]models.User, int, error)
+	// SetRole changes a user's global role
+	SetRole(ctx context.Context, id uuid.UUID, role string) error
+	// SetStatus changes a user's account status
+	SetStatus(ctx context.Context, id uuid.UUID, status string) error
+	// SetDisplayName changes the name shown instead of the user's username
+	SetDisplayName(ctx context.Context, id uuid.UUID, displayName string) error
+	// SetPasswordHash replaces a user's password hash. An empty hash matches no password.
+	SetPasswordHash(ctx context.Context, id uuid.UUID, passwordHash string) error
+	// SetSessionStamp replaces the stamp session tokens must carry to be accepted
+	SetSessionStamp(ctx context.Context, id uuid.UUID, stamp string) error
+	// SetEmailVerified records whether the user proved they own their email
+	SetEmailVerified(ctx context.Context, id uuid.UUID, verified bool) error
+	// UpdateProfile saves the user's username, display name, bio, time zone,
+	// pronouns and custom status
+	UpdateProfile(ctx context.Context, user *models.User) error
+	// SetLastSeen records when the user was last active
+	SetLastSeen(ctx context.Context, id uuid.UUID, seenAt time.Time) error
+	// Delete deletes a user with their bots, memberships, bans and mutes. Their
+	// messages and reports stay without an author and their rooms without a creator.
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+// RoomRepository stores rooms and their memberships
+type RoomRepository interface {
+	// Create creates a new room and adds its creator as a member
+	Create(ctx context.Context, room *models.Room) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Room, error)
+	// GetAll returns all rooms, newest first
+	GetAll(ctx context.Context) ([]models.Room, error)
+	// GetUserRooms returns the rooms a user is a member of, newest first
+	GetUserRooms(ctx context.Context, userID uuid.UUID) ([]models.Room, error)
+	// Delete deletes a room with its members and messages (only if user is the creator)
+	Delete(ctx context.Context, roomID, userID uuid.UUID) error
+	// ForceDelete deletes a room like Delete, whoever created it
+	ForceDelete(ctx context.Context, roomID uuid.UUID) error
+	// AddMember adds a user to a room; adding an existing member is a no-op
+	AddMember(ctx context.Context, roomID, userID uuid.UUID) error
+	// RemoveMember removes a user from a room
+	RemoveMember(ctx context.Context, roomID, userID uuid.UUID) error
+	// GetMembers returns the members of a room, oldest first
+	GetMembers(ctx context.Context, roomID uuid.UUID) ([]models.RoomMember, error)
+	// IsMember reports whether the user is a member of the room. Inside a
+	// transaction the membership cannot be removed until the transaction ends.
+	IsMember(ctx context.Context, roomID, userID uuid.UUID) (bool, error)
+	// GetRoommates returns those of candidates who share a room with the user
+	GetRoommates(ctx context.Context, userID uuid.UUID, candidates []uuid.UUID) ([]uuid.UUID, error)
+}
+
+// MessageRepository stores chat messages
+type MessageRepository interface {
+	// Create stores a message and fills in its ID and CreatedAt
+	Create(ctx context.Context, msg *models.Message) error
+	// CreateBatch stores msgs atomically with as few statements as possible,
+	// filling in IDs and CreatedAt so that the slice order is the listing order
+	CreateBatch(ctx context.Context, msgs []*models.Message) error
+	// GetByID returns a non-deleted message with its author's username.
+	// Deactivated authors are named models.DeactivatedUsername here and in GetByRoom.
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Message, error)
+	// GetByRoom returns non-deleted messages of a room, newest first
+	GetByRoom(ctx context.Context, roomID uuid.UUID, limit, offset int) ([]models.Message, error)
+	// Delete soft-deletes a message so it no longer appears in listings
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+// ModerationRepository stores room bans and mutes. Expired entries are
+// ignored by every read, so they need no cleanup to stop taking effect.
+type ModerationRepository interface {
+	// Ban bans a user from a room for duration, or permanently when duration is 0.
+	// Banning an already banned user replaces the ban. ExpiresAt and CreatedAt are filled in.
+	Ban(ctx context.Context, ban *models.RoomBan, duration time.Duration) error
+	// Unban lifts a user's ban from a room
+	Unban(ctx context.Context, roomID, userID uuid.UUID) error
+	// GetBan returns the user's active ban in a room, or nil if there is none
+	GetBan(ctx context.Context, roomID, userID uuid.UUID) (*models.RoomBan, error)
+	// GetBans returns the active bans of a room, newest first
+	GetBans(ctx context.Context, roomID uuid.UUID) ([]models.RoomBan, error)
+	// Mute mutes a user in a room for duration, replacing any existing mute.
+	// ExpiresAt and CreatedAt are filled in.
+	Mute(ctx context.Context, mute *models.RoomMute, duration time.Duration) error
+	// Unmute lifts a user's mute in a room
+	Unmute(ctx context.Context, roomID, userID uuid.UUID) error
+	// GetMute returns the user's active mute in a room, or nil if there is none
+	GetMute(ctx context.Context, roomID, userID uuid.UUID) (*models.RoomMute, error)
+	// GetMutes returns the active mutes of a room, newest first
+	GetMutes(ctx context.Context, roomID uuid.UUID) ([]models.RoomMute, error)
+}
+
+// ReportRepository stores reports of messages and users and their audit trail
+type ReportRepository interface {
+	// Create stores an open report and fills in its ID, Status and CreatedAt
+	Create(ctx context.Context, report *models.Report) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Report, error)
+	// GetByRoom returns the reports of a room, oldest first. An empty status returns every status.
+	GetByRoom(ctx context.Context, roomID uuid.UUID, status string, limit, offset int) ([]models.Report, error)
+	// List returns the reports of every room, oldest first. An empty status returns every status.
+	List(ctx context.Context, status string, limit, offset int) ([]models.Report, error)
+	// HasPending reports whether the reporter already has an open or claimed
+	// report on the same target in the same room
+	HasPending(ctx context.Context, report *models.Report) (bool, error)
+	// Claim assigns an open report to a moderator
+	Claim(ctx context.Context, id, moderatorID uuid.UUID) error
+	// Close moves an open report, or one claimed by moderatorID, to status
+	// (resolved or dismissed) and records the resolution
+	Close(ctx context.Context, id, moderatorID uuid.UUID, status, resolution, note string) error
+	// AddAction appends an entry to a report's audit trail and fills in its ID and CreatedAt
+	AddAction(ctx context.Context, action *models.ReportAction) error
+	// GetActions returns a report's audit trail, oldest first
+	GetActions(ctx context.Context, reportID uuid.UUID) ([]models.ReportAction, error)
+}
+
+// FilterRepository stores the per-room word lists of the content filter
+type FilterRepository interface {
+	// AddWord adds a word to a room's list, replacing its action if it is already there
+	AddWord(ctx context.Context, word *models.WordFilter) error
+	// RemoveWord removes a word from a room's list
+	RemoveWord(ctx context.Context, roomID uuid.UUID, word string) error
+	// GetWords returns a room's word list in alphabetical order
+	GetWords(ctx context.Context, roomID uuid.UUID) ([]models.WordFilter, error)
+}
+
+// AuditRepository stores the append-only audit log. Entries are never changed or removed.
+type AuditRepository interface {
+	// Append adds an event to the end of the log, filling in Seq, CreatedAt,
+	// PrevHash and Hash. Appends are serialized so the hash chain has no forks.
+	Append(ctx context.Context, event *models.AuditEvent) error
+	// List returns the events matching filter, newest first
+	List(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error)
+	// Range returns up to limit events with Seq above afterSeq, oldest first
+	Range(ctx context.Context, afterSeq int64, limit int) ([]models.AuditEvent, error)
+}
+
+// TwoFactorRepository stores users' TOTP enrollments and recovery codes
+type TwoFactorRepository interface {
+	// GetTOTP returns the user's TOTP enrollment, enabled or not, or nil if there is none
+	GetTOTP(ctx context.Context, userID uuid.UUID) (*models.TOTP, error)
+	// StartTOTP stores a new secret waiting for confirmation, replacing any
+	// earlier one. It fails once TOTP is enabled.
+	StartTOTP(ctx context.Context, userID uuid.UUID, secret string) error
+	// EnableTOTP confirms the enrollment with the code of step, and replaces
+	// the user's recovery codes with codeHashes
+	EnableTOTP(ctx context.Context, userID uuid.UUID, step int64, codeHashes []string) error
+	// UseTOTPStep accepts the code of step. It fails for steps up to the last
+	// accepted one, so every code works once.
+	UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) error
+	// DeleteTOTP removes the enrollment and the recovery codes
+	DeleteTOTP(ctx context.Context, userID uuid.UUID) error
+	// ReplaceRecoveryCodes replaces the user's recovery codes with codeHashes
+	ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error
+	// UseRecoveryCode marks the unused recovery code with codeHash as used
+	UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) error
+	// CountRecoveryCodes counts the user's unused recovery codes
+	CountRecoveryCodes(ctx context.Context, userID uuid.UUID) (int, error)
+}
+
+// PasskeyRepository stores users' WebAuthn credentials and the ceremonies in progress
+type PasskeyRepository interface {
+	// Create stores a new passkey. It fails if the credential is already registered.
+	Create(ctx context.Context, passkey *models.Passkey) error
+	// GetByUser returns the user's passkeys, oldest first
+	GetByUser(ctx context.Context, userID uuid.UUID) ([]models.Passkey, error)
+	// RecordUse stores the sign count and flags of a successful login
+	RecordUse(ctx context.Context, passkey *models.Passkey) error
+	// Delete removes one of the user's passkeys
+	Delete(ctx context.Context, userID, id uuid.UUID) error
+	// CreateSession stores a ceremony in progress, dropping expired ones
+	CreateSession(ctx context.Context, session *models.PasskeySession) error
+	// TakeSession removes and returns an unexpired ceremony, so each works once
+	TakeSession(ctx context.Context, id uuid.UUID, ceremony string) (*models.PasskeySession, error)
+}
+
+// AccessTokenRepository stores personal access tokens
+type AccessTokenRepository interface {
+	// Create stores a new token
+	Create(ctx context.Context, token *models.AccessToken) error
+	// GetByUser returns the user's tokens, oldest first
+	GetByUser(ctx context.Context, userID uuid.UUID) ([]models.AccessToken, error)
+	// GetByHash returns the token with the given hash, expired or not
+	GetByHash(ctx context.Context, tokenHash string) (*models.AccessToken, error)
+	// RecordUse stores the time the token was last used
+	RecordUse(ctx context.Context, id uuid.UUID) error
+	// Delete revokes one of the user's tokens
+	Delete(ctx context.Context, userID, id uuid.UUID) error
+}
+
+// LoginLinkRepository stores the one-time login links emailed to users
+type LoginLinkRepository interface {
+	// Create stores a link, dropping links that expired over a day ago
+	Create(ctx context.Context, link *models.LoginLink) error
+	// CountSince counts the links created for the user since the given time, used or not
+	CountSince(ctx context.Context, userID uuid.UUID, since time.Time) (int, error)
+	// Use marks an unused, unexpired link as used and returns it, so each works once
+	Use(ctx context.Context, tokenHash string) (*models.LoginLink, error)
+}
+
+// AvatarRepository stores the images users upload as their avatar
+type AvatarRepository interface {
+	// Set replaces the user's avatar and points their avatar_id at it
+	Set(ctx context.Context, avatar *models.Avatar) error
+	Get(ctx context.Context, id uuid.UUID) (*models.Avatar, error)
+	// Delete removes the user's avatar and clears their avatar_id
+	Delete(ctx context.Context, userID uuid.UUID) error
+}
+
+// PrivacyRepository stores who may see users' details and message them
+type PrivacyRepository interface {
+	// Get returns the user's settings, or the defaults if they never changed them
+	Get(ctx context.Context, userID uuid.UUID) (*models.PrivacySettings, error)
+	// GetMany returns the settings of each of the users, like Get
+	GetMany(ctx context.Context, userIDs []uuid.UUID) (map[uuid.UUID]*models.PrivacySettings, error)
+	// Set saves the user's settings
+	Set(ctx context.Context, settings *models.PrivacySettings) error
+}
+
+// OAuthRepository stores OAuth2 apps and the codes, consents and tokens
+// issued to them
+type OAuthRepository interface {
+	// CreateApp stores a new app
+	CreateApp(ctx context.Context, app *models.OAuthApp) error
+	// GetApp returns the app with the given ID, which is its client ID
+	GetApp(ctx context.Context, id uuid.UUID) (*models.OAuthApp, error)
+	// GetAppsByOwner returns the apps the user registered, oldest first
+	GetAppsByOwner(ctx context.Context, ownerID uuid.UUID) ([]models.OAuthApp, error)
+	// DeleteApp removes one of the owner's apps with everything issued to it
+	DeleteApp(ctx context.Context, ownerID, id uuid.UUID) error
+	// CreateCode stores an authorization code, dropping expired ones
+	CreateCode(ctx context.Context, code *models.OAuthCode) error
+	// TakeCode removes and returns an unexpired code, so each works once
+	TakeCode(ctx context.Context, codeHash string) (*models.OAuthCode, error)
+	// GetConsent returns the scopes the user granted the app, or nil if there is no consent
+	GetConsent(ctx context.Context, userID, appID uuid.UUID) (*models.OAuthConsent, error)
+	// SaveConsent stores the scopes the user granted the app, replacing earlier ones
+	SaveConsent(ctx context.Context, consent *models.OAuthConsent) error
+	// GetConsents returns the apps the user authorized, oldest first
+	GetConsents(ctx context.Context, userID uuid.UUID) ([]models.OAuthConsent, error)
+	// DeleteConsent removes the user's consent to the app and the app's codes and tokens for the user
+	DeleteConsent(ctx context.Context, userID, appID uuid.UUID) error
+	// CreateToken stores a new token, dropping those whose refresh token expired
+	CreateToken(ctx context.Context, token *models.OAuthToken) error
+	// GetTokenByAccessHash returns the token with the given access token hash, expired or not
+	GetTokenByAccessHash(ctx context.Context, accessHash string) (*models.OAuthToken, error)
+	// GetTokenByRefreshHash returns the token with the given refresh token hash, expired or not
+	GetTokenByRefreshHash(ctx context.Context, refreshHash string) (*models.OAuthToken, error)
+	// DeleteToken revokes a token. It fails if the token is already gone, so
+	// each refresh token is exchanged once.
+	DeleteToken(ctx context.Context, id uuid.UUID) error
+}
+
+// SettingsRepository stores server-wide settings that admins change at runtime
+type SettingsRepository interface {
+	// Get returns a setting's value, or "" if it was never set
+	Get(ctx context.Context, key string) (string, error)
+	// Set stores a setting's value
+	Set(ctx context.Context, key, value string) error
+}
+
+// StatsRepository reports storage-wide counters
+type StatsRepository interface {
+	// GetStats counts users, rooms, non-deleted messages and open or claimed reports
+	GetStats(ctx context.Context) (*models.ServerStats, error)
+}
+
+// IdentityRepository stores the external identities linked to users and the
+// OpenID Connect and SAML logins in progress
+type IdentityRepository interface {
+	// Create links an external identity to a user. It fails if the identity is already linked.
+	Create(ctx context.Context, identity *models.Identity) error
+	// GetBySubject returns the identity the issuer knows by subject
+	GetBySubject(ctx context.Context, issuer, subject string) (*models.Identity, error)
+	// GetByIssuer returns every identity the issuer vouches for, oldest first
+	GetByIssuer(ctx context.Context, issuer string) ([]models.Identity, error)
+	// GetByUser returns every identity linked to the user, oldest first
+	GetByUser(ctx context.Context, userID uuid.UUID) ([]models.Identity, error)
+	// RecordLogin stores the time of a login and the email the provider reported
+	RecordLogin(ctx context.Context, id uuid.UUID, email string) error
+	// CreateLogin stores a login in progress, dropping expired ones
+	CreateLogin(ctx context.Context, login *models.OIDCLogin) error
+	// TakeLogin removes and returns an unexpired login, so each state works once
+	TakeLogin(ctx context.Context, state string) (*models.OIDCLogin, error)
+	// CreateSAMLRequest stores a SAML request awaiting a response, dropping expired ones
+	CreateSAMLRequest(ctx context.Context, request *models.SAMLRequest) error
+	// TakeSAMLRequest removes and returns an unexpired SAML request, so each is answered once
+	TakeSAMLRequest(ctx context.Context, relayState string) (*models.SAMLRequest, error)
+}
+
+// TxManager runs groups of repository calls atomically
+type TxManager interface {
+	// WithinTx runs fn in a transaction carried by the context passed to fn.
+	// Repository calls made with that context commit or roll back together.
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Repositories bundles one storage backend's repositories
+type Repositories struct {
+	Users        UserRepository
+	Rooms        RoomRepository
+	Messages     MessageRepository
+	Moderation   ModerationRepository
+	Reports      ReportRepository
+	Filters      FilterRepository
+	Stats        StatsRepository
+	Audit        AuditRepository
+	TwoFactor    TwoFactorRepository
+	Settings     SettingsRepository
+	Passkeys     PasskeyRepository
+	Identities   IdentityRepository
+	AccessTokens AccessTokenRepository
+	OAuth        OAuthRepository
+	LoginLinks   LoginLinkRepository
+	Avatars      AvatarRepository
+	Privacy      PrivacyRepository
+	TxManager    TxManager
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// SQLiteTxManager runs groups of repository calls atomically in a sqlx.Tx
+type SQLiteTxManager struct {
+	db *sqlx.DB
+}
+
+func NewSQLiteTxManager(db *sqlx.DB) *SQLiteTxManager {
+	return &SQLiteTxManager{db: db}
+}
+
+// WithinTx runs fn inside a transaction carried by the context passed to fn.
+// If ctx already carries a transaction, fn joins it instead of starting a new one.
+// The transaction is rolled back if fn returns an error or panics.
+func (m *SQLiteTxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return withinTx(ctx, m.db, fn)
+}
+
+// NewSQLiteRepositories wires the SQLite-backed repositories to db
+func NewSQLiteRepositories(db *sqlx.DB) *Repositories {
+	return &Repositories{
+		Users:        NewSQLiteUserRepository(db),
+		Rooms:        NewSQLiteRoomRepository(db),
+		Messages:     NewSQLiteMessageRepository(db),
+		Moderation:   NewSQLiteModerationRepository(db),
+		Reports:      NewSQLiteReportRepository(db),
+		Filters:      NewSQLiteFilterRepository(db),
+		Stats:        NewSQLiteStatsRepository(db),
+		Audit:        NewSQLiteAuditRepository(db),
+		TwoFactor:    NewSQLiteTwoFactorRepository(db),
+		Settings:     NewSQLiteSettingsRepository(db),
+		Passkeys:     NewSQLitePasskeyRepository(db),
+		Identities:   NewSQLiteIdentityRepository(db),
+		AccessTokens: NewSQLiteAccessTokenRepository(db),
+		OAuth:        NewSQLiteOAuthRepository(db),
+		LoginLinks:   NewSQLiteLoginLinkRepository(db),
+		Avatars:      NewSQLiteAvatarRepository(db),
+		Privacy:      NewSQLitePrivacyRepository(db),
+		TxManager:    NewSQLiteTxManager(db),
+	}
+}
+
+var (
+	_ UserRepository        = (*SQLiteUserRepository)(nil)
+	_ RoomRepository        = (*SQLiteRoomRepository)(nil)
+	_ MessageRepository     = (*SQLiteMessageRepository)(nil)
+	_ ModerationRepository  = (*SQLiteModerationRepository)(nil)
+	_ ReportRepository      = (*SQLiteReportRepository)(nil)
+	_ FilterRepository      = (*SQLiteFilterRepository)(nil)
+	_ StatsRepository       = (*SQLiteStatsRepository)(nil)
+	_ AuditRepository       = (*SQLiteAuditRepository)(nil)
+	_ TwoFactorRepository   = (*SQLiteTwoFactorRepository)(nil)
+	_ SettingsRepository    = (*SQLiteSettingsRepository)(nil)
+	_ PasskeyRepository     = (*SQLitePasskeyRepository)(nil)
+	_ IdentityRepository    = (*SQLiteIdentityRepository)(nil)
+	_ AccessTokenRepository = (*SQLiteAccessTokenRepository)(nil)
+	_ OAuthRepository       = (*SQLiteOAuthRepository)(nil)
+	_ LoginLinkRepository   = (*SQLiteLoginLinkRepository)(nil)
+	_ AvatarRepository      = (*SQLiteAvatarRepository)(nil)
+	_ PrivacyRepository     = (*SQLitePrivacyRepository)(nil)
+	_ TxManager             = (*SQLiteTxManager)(nil)
+)
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type SQLiteAccessTokenRepository struct {
+	db *sqlx.DB
+}
+
+func NewSQLiteAccessTokenRepository(db *sqlx.DB) *SQLiteAccessTokenRepository {
+	return &SQLiteAccessTokenRepository{db: db}
+}
+
+// Create stores a new token
+func (r *SQLiteAccessTokenRepository) Create(ctx context.Context, token *models.AccessToken) error {
+	id, createdAt := uuid.New(), time.Now().UTC()
+	var expiresAt *time.Time
+	if token.ExpiresAt != nil {
+		utc := token.ExpiresAt.UTC()
+		expiresAt = &utc
+	}
+
+	query := `
+		INSERT INTO access_tokens (id, user_id, name, token_hash, prefix, scopes, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx, query, id, token.UserID, token.Name, token.TokenHash, token.Prefix, token.Scopes, expiresAt, createdAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	token.ID, token.CreatedAt = id, createdAt
+	return nil
+}
+
+// GetByUser returns the user's tokens, oldest first
+func (r *SQLiteAccessTokenRepository) GetByUser(ctx context.Context, userID uuid.UUID) ([]models.AccessToken, error) {
+	tokens := []models.AccessToken{}
+	query := `SELECT ` + accessTokenColumns + ` FROM access_tokens WHERE user_id = ? ORDER BY created_at, rowid`
+	if err := conn(ctx, r.db).SelectContext(ctx, &tokens, query, userID); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// GetByHash returns the token with the given hash
+func (r *SQLiteAccessTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*models.AccessToken, error) {
+	var token models.AccessToken
+	query := `SELECT ` + accessTokenColumns + ` FROM access_tokens WHERE token_hash = ?`
+	err := conn(ctx, r.db).GetContext(ctx, &token, query, tokenHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("token not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// RecordUse stores the time the token was last used
+func (r *SQLiteAccessTokenRepository) RecordUse(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE access_tokens SET last_used_at = ? WHERE id = ?`
+	return execOne(ctx, r.db, "token not found", query, time.Now().UTC(), id)
+}
+
+// Delete revokes one of the user's tokens
+func (r *SQLiteAccessTokenRepository) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	query := `DELETE FROM access_tokens WHERE id = ? AND user_id = ?`
+	return execOne(ctx, r.db, "token not found", query, id, userID)
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+type SQLiteAuditRepository struct {
+	db *sqlx.DB
+}
+
+func NewSQLiteAuditRepository(db *sqlx.DB) *SQLiteAuditRepository {
+	return &SQLiteAuditRepository{db: db}
+}
+
+// Append adds an event to the end of the log
+func (r *SQLiteAuditRepository) Append(ctx context.Context, event *models.AuditEvent) error {
+	// Transactions begin IMMEDIATE, so holding the write lock serializes appends
+	return withinTx(ctx, r.db, func(ctx context.Context) error {
+		var last models.AuditEvent
+		query := `SELECT seq, hash FROM audit_log ORDER BY seq DESC LIMIT 1`
+		err := conn(ctx, r.db).GetContext(ctx, &last, query)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		chainAuditEvent(event, &last)
+
+		query = `
+			INSERT INTO audit_log (` + auditColumns + `)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`
+		_, err = conn(ctx, r.db).ExecContext(
+			ctx, query,
+			event.Seq, event.ActorID, event.Action, event.TargetType, event.TargetID, event.IP, event.UserAgent,
+			event.Result, event.Details, event.CreatedAt, event.PrevHash, event.Hash,
+		)
+		return err
+	})
+}
+
+// List returns the events matching filter, newest first
+func (r *SQLiteAuditRepository) List(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error) {
+	var events []models.AuditEvent
+	since, until := utcOrNil(filter.Since), utcOrNil(filter.Until)
+	query := `
+		SELECT ` + auditColumns + `
+		FROM audit_log
+		WHERE (? IS NULL OR actor_id = ?)
+		  AND (? = '' OR action = ?)
+		  AND (? = '' OR target_id = ?)
+		  AND (? = '' OR result = ?)
+		  AND (? IS NULL OR created_at >= ?)
+		  AND (? IS NULL OR created_at < ?)
+		ORDER BY seq DESC
+		LIMIT ? OFFSET ?
+	`
+	err := conn(ctx, r.db).SelectContext(
+		ctx, &events, query,
+		filter.ActorID, filter.ActorID, filter.Action, filter.Action, filter.TargetID, filter.TargetID,
+		filter.Result, filter.Result, since, since, until, until, filter.Limit, filter.Offset,
+	)
+	return events, err
+}
+
+// Range returns up to limit events after afterSeq, oldest first
+func (r *SQLiteAuditRepository) Range(ctx context.Context, afterSeq int64, limit int) ([]models.AuditEvent, error) {
+	var events []models.AuditEvent
+	query := `
+		SELECT ` + auditColumns + `
+		FROM audit_log
+		WHERE seq > ?
+		ORDER BY seq ASC
+		LIMIT ?
+	`
+	err := conn(ctx, r.db).SelectContext(ctx, &events, query, afterSeq, limit)
+	return events, err
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type SQLiteAvatarRepository struct {
+	db *sqlx.DB
+}
+
+func NewSQLiteAvatarRepository(db *sqlx.DB) *SQLiteAvatarRepository {
+	return &SQLiteAvatarRepository{db: db}
+}
+
+// Set replaces the user's avatar and points their avatar_id at it
+func (r *SQLiteAvatarRepository) Set(ctx context.Context, avatar *models.Avatar) error {
+	now := time.Now().UTC()
+	return withinTx(ctx, r.db, func(ctx context.Context) error {
+		if _, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM avatars WHERE user_id = ?`, avatar.UserID); err != nil {
+			return err
+		}
+
+		query := `
+			INSERT INTO avatars (id, user_id, content_type, data, created_at)
+			VALUES (?, ?, ?, ?, ?)
+		`
+		if _, err := conn(ctx, r.db).ExecContext(ctx, query, avatar.ID, avatar.UserID, avatar.ContentType, avatar.Data, now); err != nil {
+			return err
+		}
+
+		if err := execOne(ctx, r.db, "user not found", `UPDATE users SET avatar_id = ? WHERE id = ?`, avatar.ID, avatar.UserID); err != nil {
+			return err
+		}
+		avatar.CreatedAt = now
+		return nil
+	})
+}
+
+// Get retrieves an avatar by its ID
+func (r *SQLiteAvatarRepository) Get(ctx context.Context, id uuid.UUID) (*models.Avatar, error) {
+	var avatar models.Avatar
+	query := `SELECT id, user_id, content_type, data, created_at FROM avatars WHERE id = ?`
+	err := conn(ctx, r.db).GetContext(ctx, &avatar, query, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("avatar not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &avatar, nil
+}
+
+// Delete removes the user's avatar and clears their avatar_id
+func (r *SQLiteAvatarRepository) Delete(ctx context.Context, userID uuid.UUID) error {
+	return withinTx(ctx, r.db, func(ctx context.Context) error {
+		if err := execOne(ctx, r.db, "avatar not found", `DELETE FROM avatars WHERE user_id = ?`, userID); err != nil {
+			return err
+		}
+		_, err := conn(ctx, r.db).ExecContext(ctx, `UPDATE users SET avatar_id = NULL WHERE id = ?`, userID)
+		return err
+	})
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type SQLiteFilterRepository struct {
+	db *sqlx.DB
+}
+
+func NewSQLiteFilterRepository(db *sqlx.DB) *SQLiteFilterRepository {
+	return &SQLiteFilterRepository{db: db}
+}
+
+// AddWord adds a word to a room's list or updates its action
+func (r *SQLiteFilterRepository) AddWord(ctx context.Context, word *models.WordFilter) error {
+	word.CreatedAt = time.Now().UTC()
+	query := `
+		INSERT INTO room_word_filters (room_id, word, action, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (room_id, word) DO UPDATE
+		SET action = excluded.action, created_by = excluded.created_by, created_at = excluded.created_at
+	`
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx, query,
+		word.RoomID, word.Word, word.Action, word.CreatedBy, word.CreatedAt,
+	)
+	return err
+}
+
+// RemoveWord removes a word from a room's list
+func (r *SQLiteFilterRepository) RemoveWord(ctx context.Context, roomID uuid.UUID, word string) error {
+	query := `DELETE FROM room_word_filters WHERE room_id = ? AND word = ?`
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, roomID, word)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("word is not filtered in this room")
+	}
+
+	return nil
+}
+
+// GetWords returns a room's word list
+func (r *SQLiteFilterRepository) GetWords(ctx context.Context, roomID uuid.UUID) ([]models.WordFilter, error) {
+	var words []models.WordFilter
+	query := `
+		SELECT room_id, word, action, created_by, created_at
+		FROM room_word_filters
+		WHERE room_id = ?
+		ORDER BY word
+	`
+	err := conn(ctx, r.db).SelectContext(ctx, &words, query, roomID)
+	return words, err
+}
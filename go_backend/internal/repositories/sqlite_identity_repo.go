@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type SQLiteIdentityRepository struct {
+	db *sqlx.DB
+}
+
+func NewSQLiteIdentityRepository(db *sqlx.DB) *SQLiteIdentityRepository {
+	return &SQLiteIdentityRepository{db: db}
+}
+
+// Create links an external identity to a user
+func (r *SQLiteIdentityRepository) Create(ctx context.Context, identity *models.Identity) error {
+	id, createdAt := uuid.New(), time.Now().UTC()
+	query := `
+		INSERT INTO user_identities (id, user_id, issuer, subject, email, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (issuer, subject) DO NOTHING
+	`
+	err := execOne(ctx, r.db, "identity is already linked", query, id, identity.UserID, identity.Issuer, identity.Subject, identity.Email, createdAt)
+	if err != nil {
+		return err
+	}
+
+	identity.ID, identity.CreatedAt = id, createdAt
+	return nil
+}
+
+// GetBySubject returns the identity the issuer knows by subject
+func (r *SQLiteIdentityRepository) GetBySubject(ctx context.Context, issuer, subject string) (*models.Identity, error) {
+	var identity models.Identity
+	query := `
+		SELECT id, user_id, issuer, subject, email, created_at, last_login_at
+		FROM user_identities
+		WHERE issuer = ? AND subject = ?
+	`
+	err := conn(ctx, r.db).GetContext(ctx, &identity, query, issuer, subject)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("identity not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// GetByIssuer returns every identity the issuer vouches for, oldest first
+func (r *SQLiteIdentityRepository) GetByIssuer(ctx context.Context, issuer string) ([]models.Identity, error) {
+	identities := []models.Identity{}
+	query := `
+		SELECT id, user_id, issuer, subject, email, created_at, last_login_at
+		FROM user_identities
+		WHERE issuer = ?
+		ORDER BY created_at, rowid
+	`
+	if err := conn(ctx, r.db).SelectContext(ctx, &identities, query, issuer); err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+
+// GetByUser returns every identity linked to the user, oldest first
+func (r *SQLiteIdentityRepository) GetByUser(ctx context.Context, userID uuid.UUID) ([]models.Identity, error) {
+	identities := []models.Identity{}
+	query := `
+		SELECT id, user_id, issuer, subject, email, created_at, last_login_at
+		FROM user_identities
+		WHERE user_id = ?
+		ORDER BY created_at, rowid
+	`
+	if err := conn(ctx, r.db).SelectContext(ctx, &identities, query, userID); err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+
+// RecordLogin stores the time of a login and the email the provider reported
+func (r *SQLiteIdentityRepository) RecordLogin(ctx context.Context, id uuid.UUID, email string) error {
+	query := `UPDATE user_identities SET email = ?, last_login_at = ? WHERE id = ?`
+	return execOne(ctx, r.db, "identity not found", query, email, time.Now().UTC(), id)
+}
+
+// CreateLogin stores a login in progress, dropping expired ones
+func (r *SQLiteIdentityRepository) CreateLogin(ctx context.Context, login *models.OIDCLogin) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM oidc_logins WHERE expires_at < ?`, time.Now().UTC()); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO oidc_logins (state, nonce, code_verifier, expires_at)
+		VALUES (?, ?, ?, ?)
+	`
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, login.State, login.Nonce, login.CodeVerifier, login.ExpiresAt.UTC())
+	return err
+}
+
+// TakeLogin removes and returns an unexpired login
+func (r *SQLiteIdentityRepository) TakeLogin(ctx context.Context, state string) (*models.OIDCLogin, error) {
+	var login models.OIDCLogin
+	err := withinTx(ctx, r.db, func(ctx context.Context) error {
+		query := `
+			SELECT state, nonce, code_verifier, expires_at
+			FROM oidc_logins
+			WHERE state = ? AND expires_at > ?
+		`
+		if err := conn(ctx, r.db).GetContext(ctx, &login, query, state, time.Now().UTC()); err != nil {
+			return err
+		}
+		return execOne(ctx, r.db, "login not found or expired", `DELETE FROM oidc_logins WHERE state = ?`, state)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("login not found or expired")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &login, nil
+}
+
+// CreateSAMLRequest stores a SAML request awaiting a response, dropping expired ones
+func (r *SQLiteIdentityRepository) CreateSAMLRequest(ctx context.Context, request *models.SAMLRequest) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM saml_requests WHERE expires_at < ?`, time.Now().UTC()); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO saml_requests (relay_state, request_id, expires_at)
+		VALUES (?, ?, ?)
+	`
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, request.RelayState, request.RequestID, request.ExpiresAt.UTC())
+	return err
+}
+
+// TakeSAMLRequest removes and returns an unexpired SAML request
+func (r *SQLiteIdentityRepository) TakeSAMLRequest(ctx context.Context, relayState string) (*models.SAMLRequest, error) {
+	var request models.SAMLRequest
+	err := withinTx(ctx, r.db, func(ctx context.Context) error {
+		query := `
+			SELECT relay_state, request_id, expires_at
+			FROM saml_requests
+			WHERE relay_state = ? AND expires_at > ?
+		`
+		if err := conn(ctx, r.db).GetContext(ctx, &request, query, relayState, time.Now().UTC()); err != nil {
+			return err
+		}
+		return execOne(ctx, r.db, "login not found or expired", `DELETE FROM saml_requests WHERE relay_state = ?`, relayState)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("login not found or expired")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type SQLiteLoginLinkRepository struct {
+	db *sqlx.DB
+}
+
+func NewSQLiteLoginLinkRepository(db *sqlx.DB) *SQLiteLoginLinkRepository {
+	return &SQLiteLoginLinkRepository{db: db}
+}
+
+// Create stores a link, dropping links that expired over a day ago
+func (r *SQLiteLoginLinkRepository) Create(ctx context.Context, link *models.LoginLink) error {
+	now := time.Now().UTC()
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM login_links WHERE expires_at < ?`, now.Add(-loginLinkRetention)); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO login_links (token_hash, user_id, device_hash, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, link.TokenHash, link.UserID, link.DeviceHash, link.ExpiresAt.UTC(), now)
+	if err != nil {
+		return err
+	}
+
+	link.CreatedAt = now
+	return nil
+}
+
+// CountSince counts the links created for the user since the given time, used or not
+func (r *SQLiteLoginLinkRepository) CountSince(ctx context.Context, userID uuid.UUID, since time.Time) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM login_links WHERE user_id = ? AND created_at >= ?`
+	err := conn(ctx, r.db).GetContext(ctx, &count, query, userID, since.UTC())
+	return count, err
+}
+
+// Use marks an unused, unexpired link as used and returns it
+func (r *SQLiteLoginLinkRepository) Use(ctx context.Context, tokenHash string) (*models.LoginLink, error) {
+	var link models.LoginLink
+	now := time.Now().UTC()
+	err := withinTx(ctx, r.db, func(ctx context.Context) error {
+		query := `
+			SELECT token_hash, user_id, device_hash, expires_at, used_at, created_at
+			FROM login_links
+			WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?
+		`
+		if err := conn(ctx, r.db).GetContext(ctx, &link, query, tokenHash, now); err != nil {
+			return err
+		}
+		query = `UPDATE login_links SET used_at = ? WHERE token_hash = ? AND used_at IS NULL`
+		return execOne(ctx, r.db, "link not found or expired", query, now, tokenHash)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("link not found or expired")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	link.UsedAt = &now
+	return &link, nil
+}
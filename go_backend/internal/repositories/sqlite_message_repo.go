@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type SQLiteMessageRepository struct {
+	db *sqlx.DB
+}
+
+func NewSQLiteMessageRepository(db *sqlx.DB) *SQLiteMessageRepository {
+	return &SQLiteMessageRepository{db: db}
+}
+
+func (r *SQLiteMessageRepository) Create(ctx context.Context, msg *models.Message) error {
+	// SQLite has no gen_random_uuid(), so the ID is generated here
+	msg.ID = uuid.New()
+	msg.CreatedAt = time.Now().UTC()
+	query := `
+		INSERT INTO messages (id, room_id, user_id, content, message_type, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx, query,
+		msg.ID, msg.RoomID, msg.UserID, msg.Content, msg.MessageType, msg.CreatedAt,
+	)
+	return err
+}
+
+// CreateBatch stores msgs with multi-row inserts in one transaction.
+// Timestamps step by a microsecond per row so the batch keeps its order.
+func (r *SQLiteMessageRepository) CreateBatch(ctx context.Context, msgs []*models.Message) error {
+	now := time.Now().UTC()
+	return withinTx(ctx, r.db, func(ctx context.Context) error {
+		for start := 0; start < len(msgs); start += maxBatchRows {
+			end := start + maxBatchRows
+			if end > len(msgs) {
+				end = len(msgs)
+			}
+
+			chunk := msgs[start:end]
+			values := make([]string, len(chunk))
+			args := make([]interface{}, 0, len(chunk)*6)
+			for i, msg := range chunk {
+				msg.ID = uuid.New()
+				msg.CreatedAt = now.Add(time.Duration(start+i) * time.Microsecond)
+				values[i] = "(?, ?, ?, ?, ?, ?)"
+				args = append(args, msg.ID, msg.RoomID, msg.UserID, msg.Content, msg.MessageType, msg.CreatedAt)
+			}
+
+			query := `
+				INSERT INTO messages (id, room_id, user_id, content, message_type, created_at)
+				VALUES ` + strings.Join(values, ", ")
+			if _, err := conn(ctx, r.db).ExecContext(ctx, query, args...); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetByID returns a non-deleted message with its author's username
+func (r *SQLiteMessageRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Message, error) {
+	var message models.Message
+	query := `
+		SELECT m.id, m.room_id, m.user_id, m.content, m.message_type, m.created_at,
+			CASE WHEN u.status = 'deactivated' THEN 'deactivated' ELSE COALESCE(u.username, '') END AS username,
+			COALESCE(u.is_bot, FALSE) AS is_bot
+		FROM messages m
+		LEFT JOIN users u ON m.user_id = u.id
+		WHERE m.id = ? AND m.is_deleted = FALSE
+	`
+	err := conn(ctx, r.db).GetContext(ctx, &message, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("message not found")
+		}
+		return nil, err
+	}
+	return &message, nil
+}
+
+func (r *SQLiteMessageRepository) GetByRoom(ctx context.Context, roomID uuid.UUID, limit, offset int) ([]models.Message, error) {
+	query := `
+		SELECT m.id, m.room_id, m.user_id, m.content, m.message_type, m.created_at,
+			CASE WHEN u.status = 'deactivated' THEN 'deactivated' ELSE u.username END AS username,
+			u.is_bot
+		FROM messages m
+		JOIN users u ON m.user_id = u.id
+		WHERE m.room_id = ? AND m.is_deleted = FALSE
+		ORDER BY m.created_at DESC, m.rowid DESC
+		LIMIT ? OFFSET ?
+	`
+
+	var messages []models.Message
+	err := conn(ctx, r.db).SelectContext(ctx, &messages, query, roomID, limit, offset)
+	return messages, err
+}
+
+// Delete soft-deletes a message
+func (r *SQLiteMessageRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE messages SET is_deleted = TRUE, updated_at = ?
+		WHERE id = ? AND is_deleted = FALSE
+	`
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("message not found")
+	}
+
+	return nil
+}
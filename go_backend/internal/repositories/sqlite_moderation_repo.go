@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type SQLiteModerationRepository struct {
+	db *sqlx.DB
+}
+
+func NewSQLiteModerationRepository(db *sqlx.DB) *SQLiteModerationRepository {
+	return &SQLiteModerationRepository{db: db}
+}
+
+// Ban bans a user from a room
+func (r *SQLiteModerationRepository) Ban(ctx context.Context, ban *models.RoomBan, duration time.Duration) error {
+	ban.CreatedAt = time.Now().UTC()
+	ban.ExpiresAt = nil
+	if duration > 0 {
+		expiresAt := ban.CreatedAt.Add(duration)
+		ban.ExpiresAt = &expiresAt
+	}
+
+	query := `
+		INSERT INTO room_bans (room_id, user_id, banned_by, reason, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (room_id, user_id) DO UPDATE
+		SET banned_by = excluded.banned_by, reason = excluded.reason,
+		    expires_at = excluded.expires_at, created_at = excluded.created_at
+	`
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx, query,
+		ban.RoomID, ban.UserID, ban.BannedBy, ban.Reason, ban.ExpiresAt, ban.CreatedAt,
+	)
+	return err
+}
+
+// Unban lifts a user's ban from a room
+func (r *SQLiteModerationRepository) Unban(ctx context.Context, roomID, userID uuid.UUID) error {
+	query := `
+		DELETE FROM room_bans
+		WHERE room_id = ? AND user_id = ? AND (expires_at IS NULL OR expires_at > ?)
+	`
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, roomID, userID, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("user is not banned from this room")
+	}
+
+	return nil
+}
+
+// GetBan returns the user's active ban in a room, or nil if there is none
+func (r *SQLiteModerationRepository) GetBan(ctx context.Context, roomID, userID uuid.UUID) (*models.RoomBan, error) {
+	var ban models.RoomBan
+	query := `
+		SELECT room_id, user_id, banned_by, reason, expires_at, created_at
+		FROM room_bans
+		WHERE room_id = ? AND user_id = ? AND (expires_at IS NULL OR expires_at > ?)
+	`
+	err := conn(ctx, r.db).GetContext(ctx, &ban, query, roomID, userID, time.Now().UTC())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &ban, nil
+}
+
+// GetBans returns the active bans of a room
+func (r *SQLiteModerationRepository) GetBans(ctx context.Context, roomID uuid.UUID) ([]models.RoomBan, error) {
+	var bans []models.RoomBan
+	query := `
+		SELECT room_id, user_id, banned_by, reason, expires_at, created_at
+		FROM room_bans
+		WHERE room_id = ? AND (expires_at IS NULL OR expires_at > ?)
+		ORDER BY created_at DESC, rowid DESC
+	`
+	err := conn(ctx, r.db).SelectContext(ctx, &bans, query, roomID, time.Now().UTC())
+	return bans, err
+}
+
+// Mute mutes a user in a room
+func (r *SQLiteModerationRepository) Mute(ctx context.Context, mute *models.RoomMute, duration time.Duration) error {
+	mute.CreatedAt = time.Now().UTC()
+	mute.ExpiresAt = mute.CreatedAt.Add(duration)
+
+	query := `
+		INSERT INTO room_mutes (room_id, user_id, muted_by, reason, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (room_id, user_id) DO UPDATE
+		SET muted_by = excluded.muted_by, reason = excluded.reason,
+		    expires_at = excluded.expires_at, created_at = excluded.created_at
+	`
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx, query,
+		mute.RoomID, mute.UserID, mute.MutedBy, mute.Reason, mute.ExpiresAt, mute.CreatedAt,
+	)
+	return err
+}
+
+// Unmute lifts a user's mute in a room
+func (r *SQLiteModerationRepository) Unmute(ctx context.Context, roomID, userID uuid.UUID) error {
+	query := `
+		DELETE FROM room_mutes
+		WHERE room_id = ? AND user_id = ? AND expires_at > ?
+	`
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, roomID, userID, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("user is not muted in this room")
+	}
+
+	return nil
+}
+
+// GetMute returns the user's active mute in a room, or nil if there is none
+func (r *SQLiteModerationRepository) GetMute(ctx context.Context, roomID, userID uuid.UUID) (*models.RoomMute, error) {
+	var mute models.RoomMute
+	query := `
+		SELECT room_id, user_id, muted_by, reason, expires_at, created_at
+		FROM room_mutes
+		WHERE room_id = ? AND user_id = ? AND expires_at > ?
+	`
+	err := conn(ctx, r.db).GetContext(ctx, &mute, query, roomID, userID, time.Now().UTC())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &mute, nil
+}
+
+// GetMutes returns the active mutes of a room
+func (r *SQLiteModerationRepository) GetMutes(ctx context.Context, roomID uuid.UUID) ([]models.RoomMute, error) {
+	var mutes []models.RoomMute
+	query := `
+		SELECT room_id, user_id, muted_by, reason, expires_at, created_at
+		FROM room_mutes
+		WHERE room_id = ? AND expires_at > ?
+		ORDER BY created_at DESC, rowid DESC
+	`
+	err := conn(ctx, r.db).SelectContext(ctx, &mutes, query, roomID, time.Now().UTC())
+	return mutes, err
+}
@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type SQLiteOAuthRepository struct {
+	db *sqlx.DB
+}
+
+func NewSQLiteOAuthRepository(db *sqlx.DB) *SQLiteOAuthRepository {
+	return &SQLiteOAuthRepository{db: db}
+}
+
+// CreateApp stores a new app
+func (r *SQLiteOAuthRepository) CreateApp(ctx context.Context, app *models.OAuthApp) error {
+	id, createdAt := uuid.New(), time.Now().UTC()
+	query := `
+		INSERT INTO oauth_apps (id, owner_id, name, secret_hash, redirect_uris, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, id, app.OwnerID, app.Name, app.SecretHash, app.RedirectURIs, createdAt)
+	if err != nil {
+		return err
+	}
+
+	app.ID, app.CreatedAt = id, createdAt
+	return nil
+}
+
+// GetApp returns the app with the given ID
+func (r *SQLiteOAuthRepository) GetApp(ctx context.Context, id uuid.UUID) (*models.OAuthApp, error) {
+	var app models.OAuthApp
+	query := `SELECT ` + oauthAppColumns + ` FROM oauth_apps WHERE id = ?`
+	err := conn(ctx, r.db).GetContext(ctx, &app, query, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("app not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &app, nil
+}
+
+// GetAppsByOwner returns the apps the user registered, oldest first
+func (r *SQLiteOAuthRepository) GetAppsByOwner(ctx context.Context, ownerID uuid.UUID) ([]models.OAuthApp, error) {
+	apps := []models.OAuthApp{}
+	query := `SELECT ` + oauthAppColumns + ` FROM oauth_apps WHERE owner_id = ? ORDER BY created_at, rowid`
+	if err := conn(ctx, r.db).SelectContext(ctx, &apps, query, ownerID); err != nil {
+		return nil, err
+	}
+	return apps, nil
+}
+
+// DeleteApp removes one of the owner's apps; codes, consents and tokens cascade
+func (r *SQLiteOAuthRepository) DeleteApp(ctx context.Context, ownerID, id uuid.UUID) error {
+	query := `DELETE FROM oauth_apps WHERE id = ? AND owner_id = ?`
+	return execOne(ctx, r.db, "app not found", query, id, ownerID)
+}
+
+// CreateCode stores an authorization code, dropping expired ones
+func (r *SQLiteOAuthRepository) CreateCode(ctx context.Context, code *models.OAuthCode) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM oauth_codes WHERE expires_at < ?`, time.Now().UTC()); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO oauth_codes (code_hash, app_id, user_id, redirect_uri, scopes, code_challenge, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx, query, code.CodeHash, code.AppID, code.UserID, code.RedirectURI, code.Scopes, code.CodeChallenge, code.ExpiresAt.UTC(),
+	)
+	return err
+}
+
+// TakeCode removes and returns an unexpired code
+func (r *SQLiteOAuthRepository) TakeCode(ctx context.Context, codeHash string) (*models.OAuthCode, error) {
+	var code models.OAuthCode
+	err := withinTx(ctx, r.db, func(ctx context.Context) error {
+		query := `
+			SELECT code_hash, app_id, user_id, redirect_uri, scopes, code_challenge, expires_at
+			FROM oauth_codes
+			WHERE code_hash = ? AND expires_at > ?
+		`
+		if err := conn(ctx, r.db).GetContext(ctx, &code, query, codeHash, time.Now().UTC()); err != nil {
+			return err
+		}
+		return execOne(ctx, r.db, "code not found or expired", `DELETE FROM oauth_codes WHERE code_hash = ?`, codeHash)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("code not found or expired")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+// GetConsent returns the scopes the user granted the app, or nil if there is no consent
+func (r *SQLiteOAuthRepository) GetConsent(ctx context.Context, userID, appID uuid.UUID) (*models.OAuthConsent, error) {
+	var consent models.OAuthConsent
+	query := `
+		SELECT c.user_id, c.app_id, a.name AS app_name, c.scopes, c.granted_at
+		FROM oauth_consents c
+		INNER JOIN oauth_apps a ON c.app_id = a.id
+		WHERE c.user_id = ? AND c.app_id = ?
+	`
+	err := conn(ctx, r.db).GetContext(ctx, &consent, query, userID, appID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &consent, nil
+}
+
+// SaveConsent stores the scopes the user granted the app, replacing earlier ones
+func (r *SQLiteOAuthRepository) SaveConsent(ctx context.Context, consent *models.OAuthConsent) error {
+	grantedAt := time.Now().UTC()
+	query := `
+		INSERT INTO oauth_consents (user_id, app_id, scopes, granted_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (user_id, app_id) DO UPDATE SET scopes = excluded.scopes, granted_at = excluded.granted_at
+	`
+	if _, err := conn(ctx, r.db).ExecContext(ctx, query, consent.UserID, consent.AppID, consent.Scopes, grantedAt); err != nil {
+		return err
+	}
+
+	consent.GrantedAt = grantedAt
+	return nil
+}
+
+// GetConsents returns the apps the user authorized, oldest first
+func (r *SQLiteOAuthRepository) GetConsents(ctx context.Context, userID uuid.UUID) ([]models.OAuthConsent, error) {
+	consents := []models.OAuthConsent{}
+	query := `
+		SELECT c.user_id, c.app_id, a.name AS app_name, c.scopes, c.granted_at
+		FROM oauth_consents c
+		INNER JOIN oauth_apps a ON c.app_id = a.id
+		WHERE c.user_id = ?
+		ORDER BY c.granted_at, c.rowid
+	`
+	if err := conn(ctx, r.db).SelectContext(ctx, &consents, query, userID); err != nil {
+		return nil, err
+	}
+	return consents, nil
+}
+
+// DeleteConsent removes the user's consent to the app and the app's codes and tokens for the user
+func (r *SQLiteOAuthRepository) DeleteConsent(ctx context.Context, userID, appID uuid.UUID) error {
+	return withinTx(ctx, r.db, func(ctx context.Context) error {
+		for _, query := range []string{
+			`DELETE FROM oauth_codes WHERE user_id = ? AND app_id = ?`,
+			`DELETE FROM oauth_tokens WHERE user_id = ? AND app_id = ?`,
+		} {
+			if _, err := conn(ctx, r.db).ExecContext(ctx, query, userID, appID); err != nil {
+				return err
+			}
+		}
+		query := `DELETE FROM oauth_consents WHERE user_id = ? AND app_id = ?`
+		return execOne(ctx, r.db, "authorization not found", query, userID, appID)
+	})
+}
+
+// CreateToken stores a new token, dropping those whose refresh token expired
+func (r *SQLiteOAuthRepository) CreateToken(ctx context.Context, token *models.OAuthToken) error {
+	id, createdAt := uuid.New(), time.Now().UTC()
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM oauth_tokens WHERE refresh_expires_at < ?`, createdAt); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO oauth_tokens (id, app_id, user_id, access_hash, refresh_hash, scopes, access_expires_at, refresh_expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx, query, id, token.AppID, token.UserID, token.AccessHash, token.RefreshHash, token.Scopes,
+		token.AccessExpiresAt.UTC(), token.RefreshExpiresAt.UTC(), createdAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	token.ID, token.CreatedAt = id, createdAt
+	return nil
+}
+
+// GetTokenByAccessHash returns the token with the given access token hash
+func (r *SQLiteOAuthRepository) GetTokenByAccessHash(ctx context.Context, accessHash string) (*models.OAuthToken, error) {
+	return r.getToken(ctx, `access_hash = ?`, accessHash)
+}
+
+// GetTokenByRefreshHash returns the token with the given refresh token hash
+func (r *SQLiteOAuthRepository) GetTokenByRefreshHash(ctx context.Context, refreshHash string) (*models.OAuthToken, error) {
+	return r.getToken(ctx, `refresh_hash = ?`, refreshHash)
+}
+
+func (r *SQLiteOAuthRepository) getToken(ctx context.Context, where string, hash string) (*models.OAuthToken, error) {
+	var token models.OAuthToken
+	query := `SELECT ` + oauthTokenColumns + ` FROM oauth_tokens WHERE ` + where
+	err := conn(ctx, r.db).GetContext(ctx, &token, query, hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("token not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// DeleteToken revokes a token
+func (r *SQLiteOAuthRepository) DeleteToken(ctx context.Context, id uuid.UUID) error {
+	query := `DELETE FROM oauth_tokens WHERE id = ?`
+	return execOne(ctx, r.db, "token not found", query, id)
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type SQLitePasskeyRepository struct {
+	db *sqlx.DB
+}
+
+func NewSQLitePasskeyRepository(db *sqlx.DB) *SQLitePasskeyRepository {
+	return &SQLitePasskeyRepository{db: db}
+}
+
+// Create stores a new passkey
+func (r *SQLitePasskeyRepository) Create(ctx context.Context, passkey *models.Passkey) error {
+	id, createdAt := uuid.New(), time.Now().UTC()
+	query := `
+		INSERT INTO webauthn_credentials (id, user_id, credential_id, name, public_key, attestation_type, transports, aaguid, flags, sign_count, clone_warning, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (credential_id) DO NOTHING
+	`
+	err := execOne(
+		ctx, r.db, "passkey is already registered", query,
+		id, passkey.UserID, passkey.CredentialID, passkey.Name, passkey.PublicKey, passkey.AttestationType,
+		passkey.Transports, passkey.AAGUID, passkey.Flags, passkey.SignCount, passkey.CloneWarning, createdAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	passkey.ID, passkey.CreatedAt = id, createdAt
+	return nil
+}
+
+// GetByUser returns the user's passkeys, oldest first
+func (r *SQLitePasskeyRepository) GetByUser(ctx context.Context, userID uuid.UUID) ([]models.Passkey, error) {
+	var passkeys []models.Passkey
+	query := `SELECT ` + passkeyColumns + ` FROM webauthn_credentials WHERE user_id = ? ORDER BY created_at`
+	err := conn(ctx, r.db).SelectContext(ctx, &passkeys, query, userID)
+	return passkeys, err
+}
+
+// RecordUse stores the sign count and flags of a successful login
+func (r *SQLitePasskeyRepository) RecordUse(ctx context.Context, passkey *models.Passkey) error {
+	query := `
+		UPDATE webauthn_credentials
+		SET sign_count = ?, clone_warning = ?, flags = ?, last_used_at = ?
+		WHERE id = ?
+	`
+	return execOne(ctx, r.db, "passkey not found", query, passkey.SignCount, passkey.CloneWarning, passkey.Flags, time.Now().UTC(), passkey.ID)
+}
+
+// Delete removes one of the user's passkeys
+func (r *SQLitePasskeyRepository) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	query := `DELETE FROM webauthn_credentials WHERE id = ? AND user_id = ?`
+	return execOne(ctx, r.db, "passkey not found", query, id, userID)
+}
+
+// CreateSession stores a ceremony in progress, dropping expired ones
+func (r *SQLitePasskeyRepository) CreateSession(ctx context.Context, session *models.PasskeySession) error {
+	now := time.Now().UTC()
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM webauthn_sessions WHERE expires_at < ?`, now); err != nil {
+		return err
+	}
+
+	session.ID = uuid.New()
+	query := `
+		INSERT INTO webauthn_sessions (id, ceremony, user_id, data, expires_at)
+		VALUES (?, ?, ?, ?, ?)
+	`
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, session.ID, session.Ceremony, session.UserID, session.Data, session.ExpiresAt.UTC())
+	return err
+}
+
+// TakeSession removes and returns an unexpired ceremony
+func (r *SQLitePasskeyRepository) TakeSession(ctx context.Context, id uuid.UUID, ceremony string) (*models.PasskeySession, error) {
+	var session models.PasskeySession
+	err := withinTx(ctx, r.db, func(ctx context.Context) error {
+		query := `
+			SELECT id, ceremony, user_id, data, expires_at
+			FROM webauthn_sessions
+			WHERE id = ? AND ceremony = ? AND expires_at > ?
+		`
+		if err := conn(ctx, r.db).GetContext(ctx, &session, query, id, ceremony, time.Now().UTC()); err != nil {
+			return err
+		}
+		return execOne(ctx, r.db, "passkey session not found or expired", `DELETE FROM webauthn_sessions WHERE id = ?`, id)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("passkey session not found or expired")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type SQLitePrivacyRepository struct {
+	db *sqlx.DB
+}
+
+func NewSQLitePrivacyRepository(db *sqlx.DB) *SQLitePrivacyRepository {
+	return &SQLitePrivacyRepository{db: db}
+}
+
+// Get returns the user's settings, or the defaults if they never changed them
+func (r *SQLitePrivacyRepository) Get(ctx context.Context, userID uuid.UUID) (*models.PrivacySettings, error) {
+	var settings models.PrivacySettings
+	query := `
+		SELECT user_id, email_visibility, presence_visibility, last_seen_visibility, direct_messages, updated_at
+		FROM privacy_settings
+		WHERE user_id = ?
+	`
+	err := conn(ctx, r.db).GetContext(ctx, &settings, query, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		settings = models.DefaultPrivacySettings(userID)
+		return &settings, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// GetMany returns the settings of each of the users, like Get
+func (r *SQLitePrivacyRepository) GetMany(ctx context.Context, userIDs []uuid.UUID) (map[uuid.UUID]*models.PrivacySettings, error) {
+	result := make(map[uuid.UUID]*models.PrivacySettings, len(userIDs))
+	for _, id := range userIDs {
+		settings := models.DefaultPrivacySettings(id)
+		result[id] = &settings
+	}
+	if len(userIDs) == 0 {
+		return result, nil
+	}
+
+	query, args, err := sqlx.In(`
+		SELECT user_id, email_visibility, presence_visibility, last_seen_visibility, direct_messages, updated_at
+		FROM privacy_settings
+		WHERE user_id IN (?)
+	`, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []models.PrivacySettings
+	if err := conn(ctx, r.db).SelectContext(ctx, &rows, r.db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	for i := range rows {
+		result[rows[i].UserID] = &rows[i]
+	}
+	return result, nil
+}
+
+// Set saves the user's settings
+func (r *SQLitePrivacyRepository) Set(ctx context.Context, settings *models.PrivacySettings) error {
+	now := time.Now().UTC()
+	query := `
+		INSERT INTO privacy_settings (user_id, email_visibility, presence_visibility, last_seen_visibility, direct_messages, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET
+			email_visibility = excluded.email_visibility,
+			presence_visibility = excluded.presence_visibility,
+			last_seen_visibility = excluded.last_seen_visibility,
+			direct_messages = excluded.direct_messages,
+			updated_at = excluded.updated_at
+	`
+	_, err := conn(ctx, r.db).ExecContext(ctx, query,
+		settings.UserID, settings.Email, settings.Presence, settings.LastSeen, settings.DirectMessages, now,
+	)
+	if err != nil {
+		return err
+	}
+
+	settings.UpdatedAt = now
+	return nil
+}
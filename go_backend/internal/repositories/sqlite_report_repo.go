@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type SQLiteReportRepository struct {
+	db *sqlx.DB
+}
+
+func NewSQLiteReportRepository(db *sqlx.DB) *SQLiteReportRepository {
+	return &SQLiteReportRepository{db: db}
+}
+
+// Create stores a new open report
+func (r *SQLiteReportRepository) Create(ctx context.Context, report *models.Report) error {
+	report.ID = uuid.New()
+	report.Status = models.ReportStatusOpen
+	report.CreatedAt = time.Now().UTC()
+	query := `
+		INSERT INTO reports (id, room_id, reporter_id, target_type, message_id, target_user_id, message_content, category, details, status, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx, query,
+		report.ID, report.RoomID, report.ReporterID, report.TargetType, report.MessageID, report.TargetUserID,
+		report.MessageContent, report.Category, report.Details, report.Status, report.CreatedAt,
+	)
+	return err
+}
+
+// GetByID retrieves a report by its ID
+func (r *SQLiteReportRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Report, error) {
+	var report models.Report
+	query := `SELECT ` + reportColumns + ` FROM reports WHERE id = ?`
+	err := conn(ctx, r.db).GetContext(ctx, &report, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("report not found")
+		}
+		return nil, err
+	}
+	return &report, nil
+}
+
+// GetByRoom returns the reports of a room, oldest first, optionally filtered by status
+func (r *SQLiteReportRepository) GetByRoom(ctx context.Context, roomID uuid.UUID, status string, limit, offset int) ([]models.Report, error) {
+	var reports []models.Report
+	query := `
+		SELECT ` + reportColumns + `
+		FROM reports
+		WHERE room_id = ? AND (? = '' OR status = ?)
+		ORDER BY created_at ASC, rowid ASC
+		LIMIT ? OFFSET ?
+	`
+	err := conn(ctx, r.db).SelectContext(ctx, &reports, query, roomID, status, status, limit, offset)
+	return reports, err
+}
+
+// List returns the reports of every room, oldest first, optionally filtered by status
+func (r *SQLiteReportRepository) List(ctx context.Context, status string, limit, offset int) ([]models.Report, error) {
+	var reports []models.Report
+	query := `
+		SELECT ` + reportColumns + `
+		FROM reports
+		WHERE ? = '' OR status = ?
+		ORDER BY created_at ASC, rowid ASC
+		LIMIT ? OFFSET ?
+	`
+	err := conn(ctx, r.db).SelectContext(ctx, &reports, query, status, status, limit, offset)
+	return reports, err
+}
+
+// HasPending reports whether the reporter already has an unhandled report on the same target
+func (r *SQLiteReportRepository) HasPending(ctx context.Context, report *models.Report) (bool, error) {
+	var exists bool
+	query := `
+		SELECT EXISTS(
+			SELECT 1 FROM reports
+			WHERE reporter_id = ? AND room_id = ? AND target_type = ?
+			  AND target_user_id = ? AND message_id IS ?
+			  AND status IN ('open', 'claimed')
+		)
+	`
+	err := conn(ctx, r.db).GetContext(
+		ctx, &exists, query,
+		report.ReporterID, report.RoomID, report.TargetType, report.TargetUserID, report.MessageID,
+	)
+	return exists, err
+}
+
+// Claim assigns an open report to a moderator
+func (r *SQLiteReportRepository) Claim(ctx context.Context, id, moderatorID uuid.UUID) error {
+	query := `
+		UPDATE reports SET status = 'claimed', claimed_by = ?, claimed_at = ?
+		WHERE id = ? AND status = 'open'
+	`
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, moderatorID, time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("report is not open")
+	}
+
+	return nil
+}
+
+// Close resolves or dismisses a report that is open or claimed by the moderator
+func (r *SQLiteReportRepository) Close(ctx context.Context, id, moderatorID uuid.UUID, status, resolution, note string) error {
+	query := `
+		UPDATE reports
+		SET status = ?, resolved_by = ?, resolved_at = ?, resolution = ?, resolution_note = ?
+		WHERE id = ? AND (status = 'open' OR (status = 'claimed' AND claimed_by = ?))
+	`
+	result, err := conn(ctx, r.db).ExecContext(
+		ctx, query,
+		status, moderatorID, time.Now().UTC(), resolution, note, id, moderatorID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("report is closed or claimed by another moderator")
+	}
+
+	return nil
+}
+
+// AddAction appends an entry to a report's audit trail
+func (r *SQLiteReportRepository) AddAction(ctx context.Context, action *models.ReportAction) error {
+	action.ID = uuid.New()
+	action.CreatedAt = time.Now().UTC()
+	query := `
+		INSERT INTO report_actions (id, report_id, actor_id, action, note, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx, query,
+		action.ID, action.ReportID, action.ActorID, action.Action, action.Note, action.CreatedAt,
+	)
+	return err
+}
+
+// GetActions returns a report's audit trail, oldest first
+func (r *SQLiteReportRepository) GetActions(ctx context.Context, reportID uuid.UUID) ([]models.ReportAction, error) {
+	var actions []models.ReportAction
+	query := `
+		SELECT id, report_id, actor_id, action, note, created_at
+		FROM report_actions
+		WHERE report_id = ?
+		ORDER BY created_at ASC, rowid ASC
+	`
+	err := conn(ctx, r.db).SelectContext(ctx, &actions, query, reportID)
+	return actions, err
+}
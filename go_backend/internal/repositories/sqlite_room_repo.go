@@ -0,0 +1,209 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type SQLiteRoomRepository struct {
+	db *sqlx.DB
+}
+
+func NewSQLiteRoomRepository(db *sqlx.DB) *SQLiteRoomRepository {
+	return &SQLiteRoomRepository{db: db}
+}
+
+// Create creates a new room and adds its creator as a member in one transaction
+func (r *SQLiteRoomRepository) Create(ctx context.Context, room *models.Room) error {
+	room.ID = uuid.New()
+	room.CreatedAt = time.Now().UTC()
+	return withinTx(ctx, r.db, func(ctx context.Context) error {
+		query := `
+			INSERT INTO rooms (id, name, room_type, created_by, created_at)
+			VALUES (?, ?, ?, ?, ?)
+		`
+		_, err := conn(ctx, r.db).ExecContext(
+			ctx, query,
+			room.ID, room.Name, room.RoomType, room.CreatedBy, room.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
+
+		// Automatically add creator as a member
+		return r.AddMember(ctx, room.ID, room.CreatedBy)
+	})
+}
+
+// GetByID retrieves a room by its ID
+func (r *SQLiteRoomRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Room, error) {
+	var room models.Room
+	query := `
+		SELECT id, name, room_type, created_by, created_at
+		FROM rooms
+		WHERE id = ?
+	`
+	err := conn(ctx, r.db).GetContext(ctx, &room, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("room not found")
+		}
+		return nil, err
+	}
+	return &room, nil
+}
+
+// GetAll retrieves all rooms
+func (r *SQLiteRoomRepository) GetAll(ctx context.Context) ([]models.Room, error) {
+	var rooms []models.Room
+	query := `
+		SELECT id, name, room_type, created_by, created_at
+		FROM rooms
+		ORDER BY created_at DESC, rowid DESC
+	`
+	err := conn(ctx, r.db).SelectContext(ctx, &rooms, query)
+	return rooms, err
+}
+
+// GetUserRooms retrieves all rooms a user is a member of
+func (r *SQLiteRoomRepository) GetUserRooms(ctx context.Context, userID uuid.UUID) ([]models.Room, error) {
+	var rooms []models.Room
+	query := `
+		SELECT r.id, r.name, r.room_type, r.created_by, r.created_at
+		FROM rooms r
+		INNER JOIN room_members rm ON r.id = rm.room_id
+		WHERE rm.user_id = ?
+		ORDER BY r.created_at DESC, r.rowid DESC
+	`
+	err := conn(ctx, r.db).SelectContext(ctx, &rooms, query, userID)
+	return rooms, err
+}
+
+// Delete deletes a room (only if user is the creator)
+func (r *SQLiteRoomRepository) Delete(ctx context.Context, roomID, userID uuid.UUID) error {
+	// Check ownership and delete in a single statement so the room cannot
+	// change hands between the check and the delete
+	query := `DELETE FROM rooms WHERE id = ? AND created_by = ?`
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, roomID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		// Nothing deleted: tell apart a missing room from a non-creator
+		if _, err := r.GetByID(ctx, roomID); err != nil {
+			return err
+		}
+		return errors.New("only room creator can delete the room")
+	}
+
+	return nil
+}
+
+// ForceDelete deletes a room with its members and messages, whoever created it
+func (r *SQLiteRoomRepository) ForceDelete(ctx context.Context, roomID uuid.UUID) error {
+	query := `DELETE FROM rooms WHERE id = ?`
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, roomID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("room not found")
+	}
+
+	return nil
+}
+
+// AddMember adds a user to a room
+func (r *SQLiteRoomRepository) AddMember(ctx context.Context, roomID, userID uuid.UUID) error {
+	query := `
+		INSERT INTO room_members (room_id, user_id, joined_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT (room_id, user_id) DO NOTHING
+	`
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, roomID, userID, time.Now().UTC())
+	return err
+}
+
+// RemoveMember removes a user from a room
+func (r *SQLiteRoomRepository) RemoveMember(ctx context.Context, roomID, userID uuid.UUID) error {
+	query := `DELETE FROM room_members WHERE room_id = ? AND user_id = ?`
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, roomID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("user is not a member of this room")
+	}
+
+	return nil
+}
+
+// GetMembers retrieves all members of a room
+func (r *SQLiteRoomRepository) GetMembers(ctx context.Context, roomID uuid.UUID) ([]models.RoomMember, error) {
+	var members []models.RoomMember
+	query := `
+		SELECT room_id, user_id, joined_at
+		FROM room_members
+		WHERE room_id = ?
+		ORDER BY joined_at ASC, rowid ASC
+	`
+	err := conn(ctx, r.db).SelectContext(ctx, &members, query, roomID)
+	return members, err
+}
+
+// IsMember checks if a user is a member of a room
+func (r *SQLiteRoomRepository) IsMember(ctx context.Context, roomID, userID uuid.UUID) (bool, error) {
+	var count int
+	query := `
+		SELECT COUNT(*)
+		FROM room_members
+		WHERE room_id = ? AND user_id = ?
+	`
+	err := conn(ctx, r.db).GetContext(ctx, &count, query, roomID, userID)
+	return count > 0, err
+}
+
+// GetRoommates returns those of candidates who share a room with the user
+func (r *SQLiteRoomRepository) GetRoommates(ctx context.Context, userID uuid.UUID, candidates []uuid.UUID) ([]uuid.UUID, error) {
+	roommates := []uuid.UUID{}
+	if len(candidates) == 0 {
+		return roommates, nil
+	}
+
+	query, args, err := sqlx.In(`
+		SELECT DISTINCT other.user_id
+		FROM room_members own
+		JOIN room_members other ON other.room_id = own.room_id
+		WHERE own.user_id = ? AND other.user_id IN (?)
+	`, userID, candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	err = conn(ctx, r.db).SelectContext(ctx, &roommates, r.db.Rebind(query), args...)
+	return roommates, err
+}
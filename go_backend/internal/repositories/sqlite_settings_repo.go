@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type SQLiteSettingsRepository struct {
+	db *sqlx.DB
+}
+
+func NewSQLiteSettingsRepository(db *sqlx.DB) *SQLiteSettingsRepository {
+	return &SQLiteSettingsRepository{db: db}
+}
+
+// Get returns a setting's value, or "" if it was never set
+func (r *SQLiteSettingsRepository) Get(ctx context.Context, key string) (string, error) {
+	var value string
+	err := conn(ctx, r.db).GetContext(ctx, &value, `SELECT value FROM settings WHERE key = ?`, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return value, err
+}
+
+// Set stores a setting's value
+func (r *SQLiteSettingsRepository) Set(ctx context.Context, key, value string) error {
+	query := `
+		INSERT INTO settings (key, value, updated_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT (key) DO UPDATE
+		SET value = excluded.value, updated_at = excluded.updated_at
+	`
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, key, value, time.Now().UTC())
+	return err
+}
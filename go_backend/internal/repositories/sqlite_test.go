@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+)
+
+func TestSQLiteRepositories(t *testing.T) {
+	testRepositories(t, func(t *testing.T) *Repositories {
+		return NewSQLiteRepositories(newTestSQLite(t))
+	})
+}
+
+func TestSQLiteUsesWAL(t *testing.T) {
+	db := newTestSQLite(t)
+
+	var mode string
+	if err := db.Get(&mode, "PRAGMA journal_mode"); err != nil {
+		t.Fatal(err)
+	}
+	if mode != "wal" {
+		t.Fatalf("got journal mode %q, want wal", mode)
+	}
+}
+
+// Concurrent writers wait for the write lock instead of failing with SQLITE_BUSY
+func TestSQLiteConcurrentWriters(t *testing.T) {
+	ctx := context.Background()
+	repos := NewSQLiteRepositories(newTestSQLite(t))
+	alice := mustRegister(t, repos, "alice")
+	room := mustCreateRoom(t, repos, alice)
+
+	const writers, perWriter = 8, 20
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				err := repos.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+					if _, err := repos.Rooms.IsMember(ctx, room.ID, alice.ID); err != nil {
+						return err
+					}
+					return repos.Messages.Create(ctx, &models.Message{
+						RoomID: room.ID, UserID: alice.ID, Content: fmt.Sprintf("%d-%d", w, i), MessageType: "text",
+					})
+				})
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+	messages, err := repos.Messages.GetByRoom(ctx, room.ID, -1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != writers*perWriter {
+		t.Fatalf("got %d messages, want %d", len(messages), writers*perWriter)
+	}
+}
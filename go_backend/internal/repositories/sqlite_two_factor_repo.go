@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type SQLiteTwoFactorRepository struct {
+	db *sqlx.DB
+}
+
+func NewSQLiteTwoFactorRepository(db *sqlx.DB) *SQLiteTwoFactorRepository {
+	return &SQLiteTwoFactorRepository{db: db}
+}
+
+// GetTOTP returns the user's TOTP enrollment, or nil if there is none
+func (r *SQLiteTwoFactorRepository) GetTOTP(ctx context.Context, userID uuid.UUID) (*models.TOTP, error) {
+	var t models.TOTP
+	query := `
+		SELECT user_id, secret, enabled, last_step, created_at, enabled_at
+		FROM user_totp
+		WHERE user_id = ?
+	`
+	err := conn(ctx, r.db).GetContext(ctx, &t, query, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// StartTOTP stores a secret waiting for confirmation
+func (r *SQLiteTwoFactorRepository) StartTOTP(ctx context.Context, userID uuid.UUID, secret string) error {
+	query := `
+		INSERT INTO user_totp (user_id, secret, enabled, last_step, created_at)
+		VALUES (?, ?, FALSE, 0, ?)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = excluded.secret, last_step = 0, created_at = excluded.created_at
+		WHERE user_totp.enabled = FALSE
+	`
+	return execOne(ctx, r.db, "two-factor authentication is already enabled", query, userID, secret, time.Now().UTC())
+}
+
+// EnableTOTP confirms the enrollment and replaces the recovery codes
+func (r *SQLiteTwoFactorRepository) EnableTOTP(ctx context.Context, userID uuid.UUID, step int64, codeHashes []string) error {
+	return withinTx(ctx, r.db, func(ctx context.Context) error {
+		query := `
+			UPDATE user_totp
+			SET enabled = TRUE, enabled_at = ?, last_step = ?
+			WHERE user_id = ? AND enabled = FALSE
+		`
+		if err := execOne(ctx, r.db, "no two-factor setup in progress", query, time.Now().UTC(), step, userID); err != nil {
+			return err
+		}
+		return r.ReplaceRecoveryCodes(ctx, userID, codeHashes)
+	})
+}
+
+// UseTOTPStep accepts the code of step unless a code of that step or a later one was accepted before
+func (r *SQLiteTwoFactorRepository) UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) error {
+	query := `
+		UPDATE user_totp
+		SET last_step = ?
+		WHERE user_id = ? AND enabled = TRUE AND last_step < ?
+	`
+	return execOne(ctx, r.db, "code was already used", query, step, userID, step)
+}
+
+// DeleteTOTP removes the enrollment and the recovery codes
+func (r *SQLiteTwoFactorRepository) DeleteTOTP(ctx context.Context, userID uuid.UUID) error {
+	return withinTx(ctx, r.db, func(ctx context.Context) error {
+		if _, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM user_recovery_codes WHERE user_id = ?`, userID); err != nil {
+			return err
+		}
+		return execOne(ctx, r.db, "two-factor authentication is not set up", `DELETE FROM user_totp WHERE user_id = ?`, userID)
+	})
+}
+
+// ReplaceRecoveryCodes replaces the user's recovery codes
+func (r *SQLiteTwoFactorRepository) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error {
+	return withinTx(ctx, r.db, func(ctx context.Context) error {
+		if _, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM user_recovery_codes WHERE user_id = ?`, userID); err != nil {
+			return err
+		}
+		if len(codeHashes) == 0 {
+			return nil
+		}
+
+		values := make([]string, len(codeHashes))
+		args := make([]interface{}, 0, len(codeHashes)*2)
+		for i, hash := range codeHashes {
+			values[i] = "(?, ?)"
+			args = append(args, userID, hash)
+		}
+		query := `INSERT INTO user_recovery_codes (user_id, code_hash) VALUES ` + strings.Join(values, ", ")
+		_, err := conn(ctx, r.db).ExecContext(ctx, query, args...)
+		return err
+	})
+}
+
+// UseRecoveryCode marks an unused recovery code as used
+func (r *SQLiteTwoFactorRepository) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) error {
+	query := `
+		UPDATE user_recovery_codes
+		SET used_at = ?
+		WHERE user_id = ? AND code_hash = ? AND used_at IS NULL
+	`
+	return execOne(ctx, r.db, "invalid recovery code", query, time.Now().UTC(), userID, codeHash)
+}
+
+// CountRecoveryCodes counts the user's unused recovery codes
+func (r *SQLiteTwoFactorRepository) CountRecoveryCodes(ctx context.Context, userID uuid.UUID) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM user_recovery_codes WHERE user_id = ? AND used_at IS NULL`
+	err := conn(ctx, r.db).GetContext(ctx, &count, query, userID)
+	return count, err
+}
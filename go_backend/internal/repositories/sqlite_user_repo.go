@@ -0,0 +1,280 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type SQLiteUserRepository struct {
+	db *sqlx.DB
+}
+
+func NewSQLiteUserRepository(db *sqlx.DB) *SQLiteUserRepository {
+	return &SQLiteUserRepository{db: db}
+}
+
+// Register creates a new user with an already hashed password
+func (r *SQLiteUserRepository) Register(ctx context.Context, username, email, passwordHash string) (*models.User, error) {
+	user := &models.User{
+		ID:           uuid.New(),
+		Username:     username,
+		Email:        email,
+		PasswordHash: passwordHash,
+		Role:         models.RoleUser,
+		Status:       models.UserStatusActive,
+		CreatedAt:    time.Now().UTC(),
+	}
+
+	query := `
+		INSERT INTO users (id, username, email, password_hash, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx, query,
+		user.ID, user.Username, user.Email, user.PasswordHash, user.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// CreateBot creates a bot account owned by ownerID
+func (r *SQLiteUserRepository) CreateBot(ctx context.Context, ownerID uuid.UUID, username, displayName string) (*models.User, error) {
+	user := &models.User{
+		ID:          uuid.New(),
+		Username:    username,
+		DisplayName: displayName,
+		Role:        models.RoleUser,
+		Status:      models.UserStatusActive,
+		IsBot:       true,
+		OwnerID:     &ownerID,
+		CreatedAt:   time.Now().UTC(),
+	}
+	user.Email = user.ID.String() + "@" + models.BotEmailDomain
+
+	// An empty hash matches no password
+	query := `
+		INSERT INTO users (id, username, email, password_hash, display_name, is_bot, owner_id, created_at)
+		VALUES (?, ?, ?, '', ?, TRUE, ?, ?)
+	`
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx, query,
+		user.ID, user.Username, user.Email, user.DisplayName, ownerID, user.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// GetByID retrieves a user by their ID
+func (r *SQLiteUserRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	var user models.User
+
+	query := `
+		SELECT id, username, email, email_verified, password_hash, session_stamp, display_name, role, status, is_bot, owner_id,
+		       bio, time_zone, pronouns, status_text, status_emoji, status_expires_at, avatar_id, last_seen_at, created_at
+		FROM users
+		WHERE id = ?
+	`
+	err := conn(ctx, r.db).GetContext(ctx, &user, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// GetAll retrieves all users
+func (r *SQLiteUserRepository) GetAll(ctx context.Context) ([]models.User, error) {
+	var users []models.User
+
+	query := `
+		SELECT id, username, email, email_verified, password_hash, session_stamp, display_name, role, status, is_bot, owner_id,
+		       bio, time_zone, pronouns, status_text, status_emoji, status_expires_at, avatar_id, last_seen_at, created_at
+		FROM users
+		ORDER BY created_at DESC, rowid DESC
+	`
+	err := conn(ctx, r.db).SelectContext(ctx, &users, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// GetByOwner retrieves the bots owned by a user
+func (r *SQLiteUserRepository) GetByOwner(ctx context.Context, ownerID uuid.UUID) ([]models.User, error) {
+	users := []models.User{}
+
+	query := `
+		SELECT id, username, email, email_verified, password_hash, session_stamp, display_name, role, status, is_bot, owner_id,
+		       bio, time_zone, pronouns, status_text, status_emoji, status_expires_at, avatar_id, last_seen_at, created_at
+		FROM users
+		WHERE owner_id = ?
+		ORDER BY created_at, rowid
+	`
+	err := conn(ctx, r.db).SelectContext(ctx, &users, query, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// Search lists the users matching query, a page at a time
+func (r *SQLiteUserRepository) Search(ctx context.Context, query string, limit, offset int) ([]models.User, int, error) {
+	users := []models.User{}
+	pattern := containsPattern(query)
+	where := `
+		FROM users u
+		LEFT JOIN privacy_settings p ON p.user_id = u.id
+		WHERE u.status <> ?
+		  AND (u.username LIKE ? ESCAPE '\' OR u.display_name LIKE ? ESCAPE '\'
+		       OR (u.email = ? AND p.email_visibility = ?))
+	`
+	args := []interface{}{models.UserStatusDeactivated, pattern, pattern, query, models.VisibilityEveryone}
+
+	var total int
+	if err := conn(ctx, r.db).GetContext(ctx, &total, `SELECT COUNT(*) `+where, args...); err != nil {
+		return nil, 0, err
+	}
+
+	page := `
+		SELECT u.id, u.username, u.email, u.email_verified, u.password_hash, u.session_stamp, u.display_name, u.role, u.status, u.is_bot, u.owner_id,
+		       u.bio, u.time_zone, u.pronouns, u.status_text, u.status_emoji, u.status_expires_at, u.avatar_id, u.last_seen_at, u.created_at
+	` + where + `
+		ORDER BY LOWER(u.username), u.id
+		LIMIT ? OFFSET ?
+	`
+	err := conn(ctx, r.db).SelectContext(ctx, &users, page, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+// GetByIdentifier retrieves a user by email or username
+func (r *SQLiteUserRepository) GetByIdentifier(ctx context.Context, identifier string) (*models.User, error) {
+	var user models.User
+
+	query := `
+		SELECT id, username, email, email_verified, password_hash, session_stamp, display_name, role, status, is_bot, owner_id,
+		       bio, time_zone, pronouns, status_text, status_emoji, status_expires_at, avatar_id, last_seen_at, created_at
+		FROM users
+		WHERE email = ? OR username = ?
+	`
+	err := conn(ctx, r.db).GetContext(ctx, &user, query, identifier, identifier)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("user not found")
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// SetRole changes a user's global role
+func (r *SQLiteUserRepository) SetRole(ctx context.Context, id uuid.UUID, role string) error {
+	query := `UPDATE users SET role = ?, updated_at = ? WHERE id = ?`
+	return r.update(ctx, query, role, time.Now().UTC(), id)
+}
+
+// SetStatus changes a user's account status
+func (r *SQLiteUserRepository) SetStatus(ctx context.Context, id uuid.UUID, status string) error {
+	query := `UPDATE users SET status = ?, updated_at = ? WHERE id = ?`
+	return r.update(ctx, query, status, time.Now().UTC(), id)
+}
+
+// SetDisplayName changes the name shown instead of the user's username
+func (r *SQLiteUserRepository) SetDisplayName(ctx context.Context, id uuid.UUID, displayName string) error {
+	query := `UPDATE users SET display_name = ?, updated_at = ? WHERE id = ?`
+	return r.update(ctx, query, displayName, time.Now().UTC(), id)
+}
+
+// SetPasswordHash replaces a user's password hash
+func (r *SQLiteUserRepository) SetPasswordHash(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	query := `UPDATE users SET password_hash = ?, updated_at = ? WHERE id = ?`
+	return r.update(ctx, query, passwordHash, time.Now().UTC(), id)
+}
+
+// SetSessionStamp replaces the stamp session tokens must carry to be accepted
+func (r *SQLiteUserRepository) SetSessionStamp(ctx context.Context, id uuid.UUID, stamp string) error {
+	query := `UPDATE users SET session_stamp = ?, updated_at = ? WHERE id = ?`
+	return r.update(ctx, query, stamp, time.Now().UTC(), id)
+}
+
+// SetEmailVerified records whether the user proved they own their email
+func (r *SQLiteUserRepository) SetEmailVerified(ctx context.Context, id uuid.UUID, verified bool) error {
+	query := `UPDATE users SET email_verified = ?, updated_at = ? WHERE id = ?`
+	return r.update(ctx, query, verified, time.Now().UTC(), id)
+}
+
+// UpdateProfile saves the fields users edit on their profile
+func (r *SQLiteUserRepository) UpdateProfile(ctx context.Context, user *models.User) error {
+	query := `
+		UPDATE users
+		SET username = ?, display_name = ?, bio = ?, time_zone = ?, pronouns = ?,
+		    status_text = ?, status_emoji = ?, status_expires_at = ?, updated_at = ?
+		WHERE id = ?
+	`
+	var expires *time.Time
+	if user.StatusExpires != nil {
+		utc := user.StatusExpires.UTC()
+		expires = &utc
+	}
+	return r.update(ctx, query,
+		user.Username, user.DisplayName, user.Bio, user.TimeZone, user.Pronouns,
+		user.StatusText, user.StatusEmoji, expires, time.Now().UTC(), user.ID,
+	)
+}
+
+// SetLastSeen records when the user was last active
+func (r *SQLiteUserRepository) SetLastSeen(ctx context.Context, id uuid.UUID, seenAt time.Time) error {
+	query := `UPDATE users SET last_seen_at = ? WHERE id = ?`
+	return r.update(ctx, query, seenAt.UTC(), id)
+}
+
+func (r *SQLiteUserRepository) update(ctx context.Context, query string, args ...interface{}) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("user not found")
+	}
+
+	return nil
+}
+
+// Delete deletes a user and, through the foreign key, their bots. Rooms they
+// created are kept without a creator.
+func (r *SQLiteUserRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return withinTx(ctx, r.db, func(ctx context.Context) error {
+		// rooms.created_by has no ON DELETE action, so release it first
+		if _, err := conn(ctx, r.db).ExecContext(ctx, `UPDATE rooms SET created_by = NULL WHERE created_by = ?`, id); err != nil {
+			return err
+		}
+		return r.update(ctx, `DELETE FROM users WHERE id = ?`, id)
+	})
+}
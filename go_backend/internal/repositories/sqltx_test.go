@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/GavinHemsada/go-backend/internal/database"
+	"github.com/jmoiron/sqlx"
+)
+
+// newTestSQLite returns a migrated SQLite database in a temporary file
+func newTestSQLite(t *testing.T) *sqlx.DB {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "chat.db")
+	database.RunSQLiteMigrations(path)
+
+	db, err := database.ConnectSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestWithinTxCommits(t *testing.T) {
+	ctx := context.Background()
+	db := newTestSQLite(t)
+	users := NewSQLiteUserRepository(db)
+
+	var id string
+	err := withinTx(ctx, db, func(ctx context.Context) error {
+		user, err := users.Register(ctx, "alice", "alice@example.com", "hash")
+		if err != nil {
+			return err
+		}
+		id = user.ID.String()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := users.GetByIdentifier(ctx, "alice"); err != nil {
+		t.Fatalf("user %s was not committed: %v", id, err)
+	}
+}
+
+func TestWithinTxRollsBackOnError(t *testing.T) {
+	ctx := context.Background()
+	db := newTestSQLite(t)
+	users := NewSQLiteUserRepository(db)
+
+	failure := errors.New("failure after the insert")
+	err := withinTx(ctx, db, func(ctx context.Context) error {
+		if _, err := users.Register(ctx, "alice", "alice@example.com", "hash"); err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("got error %v, want %v", err, failure)
+	}
+
+	if _, err := users.GetByIdentifier(ctx, "alice"); err == nil {
+		t.Fatal("user was committed although the transaction failed")
+	}
+}
+
+func TestWithinTxJoinsOuterTx(t *testing.T) {
+	ctx := context.Background()
+	db := newTestSQLite(t)
+	users := NewSQLiteUserRepository(db)
+
+	failure := errors.New("outer failure")
+	err := withinTx(ctx, db, func(ctx context.Context) error {
+		outer, _ := TxFromContext(ctx)
+
+		err := withinTx(ctx, db, func(ctx context.Context) error {
+			if inner, _ := TxFromContext(ctx); inner != outer {
+				t.Error("inner call started its own transaction")
+			}
+			_, err := users.Register(ctx, "alice", "alice@example.com", "hash")
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		// The inner call must not have committed on its own
+		if _, err := users.GetByIdentifier(context.Background(), "alice"); err == nil {
+			t.Error("inner call committed before the outer transaction")
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("got error %v, want %v", err, failure)
+	}
+
+	if _, err := users.GetByIdentifier(ctx, "alice"); err == nil {
+		t.Fatal("inner insert survived the outer rollback")
+	}
+}
+
+func TestWithinTxInnerErrorRollsBackOuter(t *testing.T) {
+	ctx := context.Background()
+	db := newTestSQLite(t)
+	users := NewSQLiteUserRepository(db)
+
+	failure := errors.New("inner failure")
+	err := withinTx(ctx, db, func(ctx context.Context) error {
+		if _, err := users.Register(ctx, "alice", "alice@example.com", "hash"); err != nil {
+			return err
+		}
+		return withinTx(ctx, db, func(ctx context.Context) error {
+			return failure
+		})
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("got error %v, want %v", err, failure)
+	}
+
+	if _, err := users.GetByIdentifier(ctx, "alice"); err == nil {
+		t.Fatal("outer insert survived the inner failure")
+	}
+}
+
+func TestWithinTxRollsBackOnPanic(t *testing.T) {
+	ctx := context.Background()
+	db := newTestSQLite(t)
+	users := NewSQLiteUserRepository(db)
+
+	func() {
+		defer func() {
+			if p := recover(); p != "boom" {
+				t.Errorf("got panic %v, want boom", p)
+			}
+		}()
+
+		withinTx(ctx, db, func(ctx context.Context) error {
+			if _, err := users.Register(ctx, "alice", "alice@example.com", "hash"); err != nil {
+				return err
+			}
+			panic("boom")
+		})
+	}()
+
+	if _, err := users.GetByIdentifier(ctx, "alice"); err == nil {
+		t.Fatal("user was committed although the transaction panicked")
+	}
+
+	// The connection went back to the pool usable
+	if _, err := users.Register(ctx, "bob", "bob@example.com", "hash"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWithinTxRunsHooksOnceOver(t *testing.T) {
+	ctx := context.Background()
+	db := newTestSQLite(t)
+	users := NewSQLiteUserRepository(db)
+
+	var ran []string
+	for _, fail := range []bool{false, true} {
+		name := "alice"
+		if fail {
+			name = "bob"
+		}
+		before := len(ran)
+		withinTx(ctx, db, func(ctx context.Context) error {
+			if _, err := users.Register(ctx, name, name+"@example.com", "hash"); err != nil {
+				return err
+			}
+			// Queued by a nested call, run by the outer one
+			withinTx(ctx, db, func(ctx context.Context) error {
+				AfterCommit(ctx, func() {
+					// The hook sees the committed row from outside the transaction
+					if _, err := users.GetByIdentifier(context.Background(), name); err != nil {
+						t.Errorf("%s is not committed when the hook runs: %v", name, err)
+					}
+					ran = append(ran, "commit "+name)
+				})
+				return nil
+			})
+			AfterTx(ctx, func() {
+				if _, ok := TxFromContext(WithoutTx(ctx)); ok {
+					t.Error("WithoutTx kept the transaction")
+				}
+				ran = append(ran, "end "+name)
+			})
+			if len(ran) != before {
+				t.Error("a hook ran inside the transaction")
+			}
+			if fail {
+				return errors.New("failure")
+			}
+			return nil
+		})
+	}
+
+	want := []string{"commit alice", "end alice", "end bob"}
+	if len(ran) != len(want) {
+		t.Fatalf("ran %v, want %v", ran, want)
+	}
+	for i := range want {
+		if ran[i] != want[i] {
+			t.Fatalf("ran %v, want %v", ran, want)
+		}
+	}
+
+	// Outside a transaction hooks run right away
+	AfterCommit(ctx, func() { ran = append(ran, "now") })
+	if ran[len(ran)-1] != "now" {
+		t.Error("hook outside a transaction did not run")
+	}
+}
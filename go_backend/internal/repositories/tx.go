@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"sync"
+)
+
+type txHooksKey struct{}
+
+// txHooks holds the functions queued by AfterTx and AfterCommit for a transaction
+type txHooks struct {
+	mu    sync.Mutex
+	hooks []txHook
+}
+
+type txHook struct {
+	fn       func()
+	onCommit bool // run only if the transaction commits
+}
+
+// beginHooks returns a copy of ctx that collects the hooks of the
+// transaction about to start
+func beginHooks(ctx context.Context) (context.Context, *txHooks) {
+	hooks := &txHooks{}
+	return context.WithValue(ctx, txHooksKey{}, hooks), hooks
+}
+
+// run calls the queued hooks in order, once the transaction has ended
+func (h *txHooks) run(committed bool) {
+	h.mu.Lock()
+	hooks := h.hooks
+	h.hooks = nil
+	h.mu.Unlock()
+
+	for _, hook := range hooks {
+		if committed || !hook.onCommit {
+			hook.fn()
+		}
+	}
+}
+
+func addHook(ctx context.Context, hook txHook) {
+	hooks, ok := ctx.Value(txHooksKey{}).(*txHooks)
+	if !ok {
+		hook.fn()
+		return
+	}
+
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+	hooks.hooks = append(hooks.hooks, hook)
+}
+
+// AfterTx runs fn once the transaction carried by ctx has ended, committed or
+// not, or right away when ctx carries none. fn must not use ctx for
+// repository calls; see WithoutTx.
+func AfterTx(ctx context.Context, fn func()) {
+	addHook(ctx, txHook{fn: fn})
+}
+
+// AfterCommit runs fn once the transaction carried by ctx has committed, or
+// right away when ctx carries none. Nothing is run if the transaction rolls back.
+func AfterCommit(ctx context.Context, fn func()) {
+	addHook(ctx, txHook{fn: fn, onCommit: true})
+}
+
+// noTxContext hides the transaction of its parent
+type noTxContext struct {
+	context.Context
+}
+
+func (c noTxContext) Value(key any) any {
+	switch key.(type) {
+	case txKey, memTxKey, txHooksKey:
+		return nil
+	}
+	return c.Context.Value(key)
+}
+
+// WithoutTx returns a copy of ctx without the transaction it carries, so
+// repository calls made with it run on their own
+func WithoutTx(ctx context.Context) context.Context {
+	return noTxContext{ctx}
+}
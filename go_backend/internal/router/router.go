@@ -5,11 +5,13 @@ import (
 
 	"github.com/GavinHemsada/go-backend/internal/handlers"
 	"github.com/GavinHemsada/go-backend/internal/middleware"
+	"github.com/GavinHemsada/go-backend/internal/models"
 	"github.com/GavinHemsada/go-backend/internal/websocket"
+	"github.com/GavinHemsada/go-backend/pkg/utils"
 	"github.com/gorilla/mux"
 )
 
-func NewRouter(userHandler *handlers.UserHandler, roomHandler *handlers.RoomHandler, messageHandler *handlers.MessageHandler, wsHandler *websocket.Handler, jwtSecret string) *mux.Router {
+func NewRouter(userHandler *handlers.UserHandler, roomHandler *handlers.RoomHandler, messageHandler *handlers.MessageHandler, moderationHandler *handlers.ModerationHandler, reportHandler *handlers.ReportHandler, filterHandler *handlers.FilterHandler, adminHandler *handlers.AdminHandler, twoFactorHandler *handlers.TwoFactorHandler, passkeyHandler *handlers.PasskeyHandler, oidcHandler *handlers.OIDCHandler, samlHandler *handlers.SAMLHandler, accessTokenHandler *handlers.AccessTokenHandler, botHandler *handlers.BotHandler, oauthHandler *handlers.OAuthHandler, emailHandler *handlers.EmailHandler, magicLinkHandler *handlers.MagicLinkHandler, profileHandler *handlers.ProfileHandler, wsHandler *websocket.Handler, jwtSecret string) *mux.Router {
 	r := mux.NewRouter()
 
 	// Health check
@@ -20,38 +22,157 @@ func NewRouter(userHandler *handlers.UserHandler, roomHandler *handlers.RoomHand
 
 	// API routes
 	api := r.PathPrefix("/api/v1").Subrouter()
+	api.Use(middleware.ClientInfo)
 	
 	// Public routes (no authentication required)
 	api.HandleFunc("/users/register", userHandler.Register).Methods("POST")
 	api.HandleFunc("/users/login", userHandler.Login).Methods("POST")
+	api.HandleFunc("/users/login/2fa", twoFactorHandler.LoginVerify).Methods("POST")
+	api.HandleFunc("/users/login/2fa/setup", twoFactorHandler.LoginSetup).Methods("POST")
+	api.HandleFunc("/users/login/2fa/enable", twoFactorHandler.LoginEnable).Methods("POST")
+	api.HandleFunc("/users/login/passkey/begin", passkeyHandler.BeginLogin).Methods("POST")
+	api.HandleFunc("/users/login/passkey/finish", passkeyHandler.FinishLogin).Methods("POST")
+	api.HandleFunc("/users/login/magic-link", magicLinkHandler.Request).Methods("POST")
+	api.HandleFunc("/users/login/magic-link/verify", magicLinkHandler.Login).Methods("POST")
+	api.HandleFunc("/users/verify-email", emailHandler.VerifyEmail).Methods("POST")
+	api.HandleFunc("/users/password/forgot", emailHandler.ForgotPassword).Methods("POST")
+	api.HandleFunc("/users/password/reset", emailHandler.ResetPassword).Methods("POST")
+
+	// Avatars are public so that image tags can load them
+	api.HandleFunc("/avatars/{id}", profileHandler.GetAvatar).Methods("GET")
+
+	// Single sign-on routes, when an identity provider is configured
+	if oidcHandler != nil {
+		api.HandleFunc("/users/login/oidc", oidcHandler.BeginLogin).Methods("GET")
+		api.HandleFunc("/users/login/oidc/callback", oidcHandler.Callback).Methods("GET")
+	}
+	if samlHandler != nil {
+		api.HandleFunc("/users/login/saml", samlHandler.BeginLogin).Methods("GET")
+		api.HandleFunc("/users/login/saml/metadata", samlHandler.Metadata).Methods("GET")
+		api.HandleFunc("/users/login/saml/acs", samlHandler.ACS).Methods("POST")
+	}
 	
-	// Protected routes (require JWT authentication)
+	// OAuth2 endpoints for apps, which authenticate with their client credentials
+	api.HandleFunc("/oauth/token", oauthHandler.Token).Methods("POST")
+	api.HandleFunc("/oauth/introspect", oauthHandler.Introspect).Methods("POST")
+	api.HandleFunc("/oauth/revoke", oauthHandler.Revoke).Methods("POST")
+	
+	// Protected routes (require JWT authentication). Personal and OAuth2
+	// access tokens only reach the routes wrapped with middleware.Scoped.
 	protected := api.PathPrefix("").Subrouter()
-	protected.Use(middleware.JWTMiddleware(jwtSecret))
+	protected.Use(middleware.JWTMiddleware(jwtSecret, map[string]middleware.TokenChecker{
+		utils.AccessTokenPrefix:      accessTokenHandler.CheckToken,
+		utils.OAuthAccessTokenPrefix: oauthHandler.CheckToken,
+	}, userHandler.CheckAccount))
+	
+	// OAuth2 consent screen
+	protected.HandleFunc("/oauth/authorize", oauthHandler.GetAuthorization).Methods("GET")
+	protected.HandleFunc("/oauth/authorize", oauthHandler.Authorize).Methods("POST")
 	
 	// User routes
 	users := protected.PathPrefix("/users").Subrouter()
+	users.HandleFunc("/me", profileHandler.GetProfile).Methods("GET")
+	users.HandleFunc("/me", profileHandler.UpdateProfile).Methods("PATCH")
+	users.HandleFunc("/me/avatar", profileHandler.UploadAvatar).Methods("POST")
+	users.HandleFunc("/me/avatar", profileHandler.DeleteAvatar).Methods("DELETE")
+	users.HandleFunc("/me/privacy", userHandler.GetPrivacy).Methods("GET")
+	users.HandleFunc("/me/privacy", userHandler.UpdatePrivacy).Methods("PATCH")
+	users.HandleFunc("/me/deactivate", userHandler.Deactivate).Methods("POST")
+	users.HandleFunc("/me/password", userHandler.ChangePassword).Methods("PUT")
+	users.HandleFunc("/me/email/verify", emailHandler.SendVerification).Methods("POST")
+	users.HandleFunc("/me/2fa", twoFactorHandler.GetStatus).Methods("GET")
+	users.HandleFunc("/me/2fa/totp", twoFactorHandler.Start).Methods("POST")
+	users.HandleFunc("/me/2fa/totp", twoFactorHandler.Disable).Methods("DELETE")
+	users.HandleFunc("/me/2fa/totp/enable", twoFactorHandler.Enable).Methods("POST")
+	users.HandleFunc("/me/2fa/recovery-codes", twoFactorHandler.RegenerateRecoveryCodes).Methods("POST")
+	users.HandleFunc("/me/passkeys", passkeyHandler.GetPasskeys).Methods("GET")
+	users.HandleFunc("/me/passkeys/begin", passkeyHandler.BeginRegistration).Methods("POST")
+	users.HandleFunc("/me/passkeys/finish", passkeyHandler.FinishRegistration).Methods("POST")
+	users.HandleFunc("/me/passkeys/{id}", passkeyHandler.DeletePasskey).Methods("DELETE")
+	users.HandleFunc("/me/tokens", accessTokenHandler.GetTokens).Methods("GET")
+	users.HandleFunc("/me/tokens", accessTokenHandler.CreateToken).Methods("POST")
+	users.HandleFunc("/me/tokens/{id}", accessTokenHandler.RevokeToken).Methods("DELETE")
+	users.HandleFunc("/me/bots", botHandler.GetBots).Methods("GET")
+	users.HandleFunc("/me/bots", botHandler.CreateBot).Methods("POST")
+	users.HandleFunc("/me/bots/{id}", botHandler.DeleteBot).Methods("DELETE")
+	users.HandleFunc("/me/bots/{id}/tokens", botHandler.GetTokens).Methods("GET")
+	users.HandleFunc("/me/bots/{id}/tokens", botHandler.CreateToken).Methods("POST")
+	users.HandleFunc("/me/bots/{id}/tokens/{token_id}", botHandler.RevokeToken).Methods("DELETE")
+	users.HandleFunc("/me/apps", oauthHandler.GetApps).Methods("GET")
+	users.HandleFunc("/me/apps", oauthHandler.CreateApp).Methods("POST")
+	users.HandleFunc("/me/apps/{id}", oauthHandler.DeleteApp).Methods("DELETE")
+	users.HandleFunc("/me/authorizations", oauthHandler.GetAuthorizations).Methods("GET")
+	users.HandleFunc("/me/authorizations/{id}", oauthHandler.RevokeAuthorization).Methods("DELETE")
 	users.HandleFunc("/{id}", userHandler.GetUserByID).Methods("GET")
 	users.HandleFunc("", userHandler.GetAllUsers).Methods("GET")
+	users.HandleFunc("/{id}/reports", reportHandler.ReportUser).Methods("POST")
 	
 	// Room routes
 	rooms := protected.PathPrefix("/rooms").Subrouter()
-	rooms.HandleFunc("", roomHandler.CreateRoom).Methods("POST")
-	rooms.HandleFunc("", roomHandler.GetAllRooms).Methods("GET")
-	rooms.HandleFunc("/user", roomHandler.GetUserRooms).Methods("GET")
-	rooms.HandleFunc("/{id}", roomHandler.GetRoomByID).Methods("GET")
-	rooms.HandleFunc("/{id}", roomHandler.DeleteRoom).Methods("DELETE")
-	rooms.HandleFunc("/{id}/join", roomHandler.JoinRoom).Methods("POST")
-	rooms.HandleFunc("/{id}/leave", roomHandler.LeaveRoom).Methods("POST")
-	rooms.HandleFunc("/{id}/members", roomHandler.GetRoomMembers).Methods("GET")
+	rooms.Handle("", middleware.Scoped(roomHandler.CreateRoom, models.ScopeRoomsManage)).Methods("POST")
+	rooms.Handle("", middleware.Scoped(roomHandler.GetAllRooms, models.ScopeMessagesRead)).Methods("GET")
+	rooms.Handle("/user", middleware.Scoped(roomHandler.GetUserRooms, models.ScopeMessagesRead)).Methods("GET")
+	rooms.Handle("/{id}", middleware.Scoped(roomHandler.GetRoomByID, models.ScopeMessagesRead)).Methods("GET")
+	rooms.Handle("/{id}", middleware.Scoped(roomHandler.DeleteRoom, models.ScopeRoomsManage)).Methods("DELETE")
+	rooms.Handle("/{id}/join", middleware.Scoped(roomHandler.JoinRoom, models.ScopeRoomsManage)).Methods("POST")
+	rooms.Handle("/{id}/leave", middleware.Scoped(roomHandler.LeaveRoom, models.ScopeRoomsManage)).Methods("POST")
+	rooms.Handle("/{id}/members", middleware.Scoped(roomHandler.GetRoomMembers, models.ScopeMessagesRead)).Methods("GET")
+
+	// Moderation routes (room creator only)
+	rooms.HandleFunc("/{id}/kick", moderationHandler.KickUser).Methods("POST")
+	rooms.HandleFunc("/{id}/bans", moderationHandler.BanUser).Methods("POST")
+	rooms.HandleFunc("/{id}/bans", moderationHandler.GetBans).Methods("GET")
+	rooms.HandleFunc("/{id}/bans/{user_id}", moderationHandler.UnbanUser).Methods("DELETE")
+	rooms.HandleFunc("/{id}/mutes", moderationHandler.MuteUser).Methods("POST")
+	rooms.HandleFunc("/{id}/mutes", moderationHandler.GetMutes).Methods("GET")
+	rooms.HandleFunc("/{id}/mutes/{user_id}", moderationHandler.UnmuteUser).Methods("DELETE")
+	rooms.HandleFunc("/{id}/bots", botHandler.AddToRoom).Methods("POST")
+	rooms.HandleFunc("/{id}/bots/{bot_id}", botHandler.RemoveFromRoom).Methods("DELETE")
+
+	// Moderation queue routes (room creator only)
+	rooms.HandleFunc("/{id}/reports", reportHandler.GetReports).Methods("GET")
+	rooms.HandleFunc("/{id}/reports/{report_id}", reportHandler.GetReport).Methods("GET")
+	rooms.HandleFunc("/{id}/reports/{report_id}/claim", reportHandler.ClaimReport).Methods("POST")
+	rooms.HandleFunc("/{id}/reports/{report_id}/resolve", reportHandler.ResolveReport).Methods("POST")
+	rooms.HandleFunc("/{id}/reports/{report_id}/dismiss", reportHandler.DismissReport).Methods("POST")
+
+	// Content filter routes (room creator only)
+	rooms.HandleFunc("/{id}/filters/words", filterHandler.GetWords).Methods("GET")
+	rooms.HandleFunc("/{id}/filters/words", filterHandler.AddWord).Methods("POST")
+	rooms.HandleFunc("/{id}/filters/words/{word}", filterHandler.RemoveWord).Methods("DELETE")
 	
 	// Message routes
 	messages := protected.PathPrefix("/rooms/{room_id}/messages").Subrouter()
-	messages.HandleFunc("", messageHandler.CreateMessage).Methods("POST")
-	messages.HandleFunc("", messageHandler.GetMessagesByRoom).Methods("GET")
+	messages.Handle("", middleware.Scoped(messageHandler.CreateMessage, models.ScopeMessagesWrite)).Methods("POST")
+	messages.Handle("", middleware.Scoped(messageHandler.GetMessagesByRoom, models.ScopeMessagesRead)).Methods("GET")
+	messages.HandleFunc("/{message_id}/reports", reportHandler.ReportMessage).Methods("POST")
 	
+	// Admin routes (global admins only)
+	admin := protected.PathPrefix("/admin").Subrouter()
+	admin.Use(middleware.RequireAdmin(adminHandler.IsAdmin))
+	admin.HandleFunc("/users", adminHandler.GetUsers).Methods("GET")
+	admin.HandleFunc("/users/{id}", adminHandler.DeleteUser).Methods("DELETE")
+	admin.HandleFunc("/users/{id}/suspend", adminHandler.SuspendUser).Methods("POST")
+	admin.HandleFunc("/users/{id}/deactivate", adminHandler.DeactivateUser).Methods("POST")
+	admin.HandleFunc("/users/{id}/reactivate", adminHandler.ReactivateUser).Methods("POST")
+	admin.HandleFunc("/users/{id}/role", adminHandler.SetRole).Methods("PUT")
+	admin.HandleFunc("/users/{id}/unlock", adminHandler.UnlockUser).Methods("POST")
+	admin.HandleFunc("/users/{id}/2fa", adminHandler.ResetTwoFactor).Methods("DELETE")
+	admin.HandleFunc("/ips/{ip}/unlock", adminHandler.UnlockIP).Methods("POST")
+	admin.HandleFunc("/settings/2fa", adminHandler.GetTwoFactorPolicy).Methods("GET")
+	admin.HandleFunc("/settings/2fa", adminHandler.SetTwoFactorPolicy).Methods("PUT")
+	admin.HandleFunc("/settings/email-verification", adminHandler.GetEmailVerificationPolicy).Methods("GET")
+	admin.HandleFunc("/settings/email-verification", adminHandler.SetEmailVerificationPolicy).Methods("PUT")
+	admin.HandleFunc("/rooms/{id}", adminHandler.DeleteRoom).Methods("DELETE")
+	admin.HandleFunc("/reports", reportHandler.GetAllReports).Methods("GET")
+	admin.HandleFunc("/stats", adminHandler.GetStats).Methods("GET")
+	admin.HandleFunc("/clients", adminHandler.GetClients).Methods("GET")
+	admin.HandleFunc("/audit", adminHandler.GetAuditLog).Methods("GET")
+	admin.HandleFunc("/audit/export", adminHandler.ExportAuditLog).Methods("GET")
+	admin.HandleFunc("/audit/verify", adminHandler.VerifyAuditLog).Methods("GET")
+
 	// WebSocket route for live chat (protected with JWT)
-	protected.HandleFunc("/ws/rooms/{room_id}", wsHandler.ServeWS)
+	protected.Handle("/ws/rooms/{room_id}", middleware.Scoped(wsHandler.ServeWS, models.ScopeMessagesRead, models.ScopeMessagesWrite))
 
 	return r
 }
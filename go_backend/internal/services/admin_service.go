@@ -0,0 +1,278 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+
+	"github.com/GavinHemsada/go-backend/internal/audit"
+	"github.com/GavinHemsada/go-backend/internal/loginguard"
+	"github.com/GavinHemsada/go-backend/internal/models"
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+	"github.com/google/uuid"
+)
+
+// AdminHub gives administrators access to the live WebSocket connections
+type AdminHub interface {
+	ConnectionCloser
+	// CloseRoom closes every connection to a room on every server instance
+	CloseRoom(roomID uuid.UUID, reason string)
+	// Clients lists the connections held by this server instance
+	Clients() models.HubClients
+	// Stats counts the rooms and connections held by this server instance
+	Stats() models.HubStats
+}
+
+type AdminService struct {
+	userRepo  repository.UserRepository
+	roomRepo  repository.RoomRepository
+	statsRepo repository.StatsRepository
+	hub       AdminHub
+	guard     *loginguard.Guard
+	twoFactor *TwoFactorService
+	email     *EmailService
+	auditLog  *audit.Logger
+}
+
+func NewAdminService(userRepo repository.UserRepository, roomRepo repository.RoomRepository, statsRepo repository.StatsRepository, hub AdminHub, guard *loginguard.Guard, twoFactor *TwoFactorService, email *EmailService, auditLog *audit.Logger) *AdminService {
+	return &AdminService{
+		userRepo:  userRepo,
+		roomRepo:  roomRepo,
+		statsRepo: statsRepo,
+		hub:       hub,
+		guard:     guard,
+		twoFactor: twoFactor,
+		email:     email,
+		auditLog:  auditLog,
+	}
+}
+
+// IsAdmin reports whether the user exists and holds the admin role
+func (s *AdminService) IsAdmin(ctx context.Context, userID uuid.UUID) bool {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	return err == nil && user.Role == models.RoleAdmin
+}
+
+// GetUsers lists every user, newest first
+func (s *AdminService) GetUsers(ctx context.Context) ([]models.User, error) {
+	return s.userRepo.GetAll(ctx)
+}
+
+// SuspendUser locks a user out until they are reactivated
+func (s *AdminService) SuspendUser(ctx context.Context, actorID, targetID uuid.UUID) (*models.User, error) {
+	return s.lockOut(ctx, actorID, targetID, models.UserStatusSuspended, models.AuditAdminSuspend, "account suspended")
+}
+
+// DeactivateUser closes a user's account. Their messages stay, shown under models.DeactivatedUsername.
+func (s *AdminService) DeactivateUser(ctx context.Context, actorID, targetID uuid.UUID) (*models.User, error) {
+	return s.lockOut(ctx, actorID, targetID, models.UserStatusDeactivated, models.AuditAdminDeactivate, "account deactivated")
+}
+
+// ReactivateUser lets a suspended or deactivated user log in again
+func (s *AdminService) ReactivateUser(ctx context.Context, actorID, targetID uuid.UUID) (*models.User, error) {
+	err := s.userRepo.SetStatus(ctx, targetID, models.UserStatusActive)
+	s.recordUser(ctx, models.AuditAdminReactivate, actorID, targetID, "", err)
+	if err != nil {
+		return nil, err
+	}
+	return s.userRepo.GetByID(ctx, targetID)
+}
+
+// lockOut moves a user to status, which stops their login and their tokens,
+// and closes their live connections
+func (s *AdminService) lockOut(ctx context.Context, actorID, targetID uuid.UUID, status, action, reason string) (*models.User, error) {
+	err := s.authorize(ctx, actorID, targetID)
+	if err == nil {
+		err = s.userRepo.SetStatus(ctx, targetID, status)
+	}
+	s.recordUser(ctx, action, actorID, targetID, "", err)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.hub != nil {
+		s.hub.DisconnectAll(targetID, reason)
+	}
+	return s.userRepo.GetByID(ctx, targetID)
+}
+
+// DeleteUser deletes a user account. Rooms they created are kept without a
+// creator, but their messages drop out of listings; DeactivateUser keeps them.
+func (s *AdminService) DeleteUser(ctx context.Context, actorID, targetID uuid.UUID) error {
+	err := s.authorize(ctx, actorID, targetID)
+	if err == nil {
+		err = s.userRepo.Delete(ctx, targetID)
+	}
+	s.recordUser(ctx, models.AuditAdminDeleteUser, actorID, targetID, "", err)
+	if err != nil {
+		return err
+	}
+
+	if s.hub != nil {
+		s.hub.DisconnectAll(targetID, "account deleted")
+	}
+	return nil
+}
+
+// SetRole grants or revokes the admin role
+func (s *AdminService) SetRole(ctx context.Context, actorID, targetID uuid.UUID, role string) (*models.User, error) {
+	if role != models.RoleUser && role != models.RoleAdmin {
+		return nil, errors.New("role must be user or admin")
+	}
+
+	// Keeps the last admin from locking everyone out
+	if actorID == targetID {
+		return nil, errors.New("you cannot change your own role")
+	}
+
+	err := s.userRepo.SetRole(ctx, targetID, role)
+	s.recordUser(ctx, models.AuditAdminSetRole, actorID, targetID, "role "+role, err)
+	if err != nil {
+		return nil, err
+	}
+	return s.userRepo.GetByID(ctx, targetID)
+}
+
+// UnlockUser lifts a lock placed on a user's account by failed logins and
+// clears its failures
+func (s *AdminService) UnlockUser(ctx context.Context, actorID, targetID uuid.UUID) error {
+	_, err := s.userRepo.GetByID(ctx, targetID)
+	if err == nil {
+		err = s.guard.UnlockAccount(ctx, loginguard.UserKey(targetID))
+	}
+	s.recordUser(ctx, models.AuditAdminUnlockUser, actorID, targetID, "", err)
+	return err
+}
+
+// UnlockIP lifts a lock placed on a client IP by failed logins and clears its failures
+func (s *AdminService) UnlockIP(ctx context.Context, actorID uuid.UUID, ip string) error {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return errors.New("invalid IP address")
+	}
+
+	err := s.guard.UnlockIP(ctx, parsed.String())
+	s.auditLog.Record(ctx, audit.Outcome(models.AuditEvent{
+		ActorID:    &actorID,
+		Action:     models.AuditAdminUnlockIP,
+		TargetType: models.AuditTargetIP,
+		TargetID:   parsed.String(),
+	}, err))
+	return err
+}
+
+// ResetTwoFactor removes a user's second factor so they can log in with
+// their password alone, or enroll again if the policy requires it
+func (s *AdminService) ResetTwoFactor(ctx context.Context, actorID, targetID uuid.UUID) error {
+	err := s.authorize(ctx, actorID, targetID)
+	if err == nil {
+		err = s.twoFactor.Reset(ctx, targetID)
+	}
+	s.recordUser(ctx, models.AuditAdminReset2FA, actorID, targetID, "", err)
+	return err
+}
+
+// GetTwoFactorPolicy returns who must use two-factor authentication
+func (s *AdminService) GetTwoFactorPolicy(ctx context.Context) (string, error) {
+	return s.twoFactor.Policy(ctx)
+}
+
+// SetTwoFactorPolicy changes who must use two-factor authentication
+func (s *AdminService) SetTwoFactorPolicy(ctx context.Context, actorID uuid.UUID, requiredFor string) error {
+	err := s.twoFactor.SetPolicy(ctx, requiredFor)
+	s.auditLog.Record(ctx, audit.Outcome(models.AuditEvent{
+		ActorID:    &actorID,
+		Action:     models.AuditAdminSetting,
+		TargetType: models.AuditTargetSetting,
+		TargetID:   models.SettingTwoFactorRequired,
+		Details:    requiredFor,
+	}, err))
+	return err
+}
+
+// GetEmailVerificationPolicy reports whether users must verify their email before joining rooms
+func (s *AdminService) GetEmailVerificationPolicy(ctx context.Context) (bool, error) {
+	return s.email.Policy(ctx)
+}
+
+// SetEmailVerificationPolicy changes whether users must verify their email before joining rooms
+func (s *AdminService) SetEmailVerificationPolicy(ctx context.Context, actorID uuid.UUID, required bool) error {
+	err := s.email.SetPolicy(ctx, required)
+	s.auditLog.Record(ctx, audit.Outcome(models.AuditEvent{
+		ActorID:    &actorID,
+		Action:     models.AuditAdminSetting,
+		TargetType: models.AuditTargetSetting,
+		TargetID:   models.SettingEmailVerificationRequired,
+		Details:    strconv.FormatBool(required),
+	}, err))
+	return err
+}
+
+// DeleteRoom deletes any room with its members and messages and closes its live connections
+func (s *AdminService) DeleteRoom(ctx context.Context, actorID, roomID uuid.UUID) error {
+	err := s.roomRepo.ForceDelete(ctx, roomID)
+	s.auditLog.Record(ctx, audit.Outcome(models.AuditEvent{
+		ActorID:    &actorID,
+		Action:     models.AuditAdminDeleteRoom,
+		TargetType: models.AuditTargetRoom,
+		TargetID:   roomID.String(),
+	}, err))
+	if err != nil {
+		return err
+	}
+
+	if s.hub != nil {
+		s.hub.CloseRoom(roomID, "room deleted by an administrator")
+	}
+	return nil
+}
+
+// GetStats returns storage counters and this server instance's connection counts
+func (s *AdminService) GetStats(ctx context.Context) (*models.ServerStats, error) {
+	stats, err := s.statsRepo.GetStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.hub != nil {
+		stats.Hub = s.hub.Stats()
+	}
+	return stats, nil
+}
+
+// GetClients lists the live connections held by this server instance
+func (s *AdminService) GetClients() models.HubClients {
+	if s.hub == nil {
+		return models.HubClients{Clients: []models.ConnectedClient{}}
+	}
+	return s.hub.Clients()
+}
+
+// authorize checks that an admin may act on the target account. Other
+// admins must be demoted first.
+func (s *AdminService) authorize(ctx context.Context, actorID, targetID uuid.UUID) error {
+	if actorID == targetID {
+		return errors.New("you cannot do this to your own account")
+	}
+
+	target, err := s.userRepo.GetByID(ctx, targetID)
+	if err != nil {
+		return err
+	}
+	if target.Role == models.RoleAdmin {
+		return errors.New("cannot act on another admin, revoke their role first")
+	}
+	return nil
+}
+
+// recordUser audits an admin action on a user account, successful unless err is set
+func (s *AdminService) recordUser(ctx context.Context, action string, actorID, targetID uuid.UUID, details string, err error) {
+	s.auditLog.Record(ctx, audit.Outcome(models.AuditEvent{
+		ActorID:    &actorID,
+		Action:     action,
+		TargetType: models.AuditTargetUser,
+		TargetID:   targetID.String(),
+		Details:    details,
+	}, err))
+}
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GavinHemsada/go-backend/internal/audit"
+	"github.com/GavinHemsada/go-backend/internal/loginguard"
+	"github.com/GavinHemsada/go-backend/internal/models"
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+	"github.com/google/uuid"
+)
+
+// hubRecorder records the users whose connections it closes
+type hubRecorder struct {
+	users []uuid.UUID
+}
+
+func (h *hubRecorder) DisconnectAll(userID uuid.UUID, reason string) {
+	h.users = append(h.users, userID)
+}
+
+func (h *hubRecorder) CloseRoom(roomID uuid.UUID, reason string) {}
+
+func (h *hubRecorder) Clients() models.HubClients {
+	return models.HubClients{}
+}
+
+func (h *hubRecorder) Stats() models.HubStats {
+	return models.HubStats{}
+}
+
+type adminTest struct {
+	service *AdminService
+	users   *UserService
+	hub     *hubRecorder
+	admin   *models.User
+	member  *models.User
+}
+
+// newAdminTest returns an admin service, an admin and a member with a password
+func newAdminTest(t *testing.T, repos *repository.Repositories) *adminTest {
+	t.Helper()
+	hasher := newTestHasher(t)
+	admin := registerWithPassword(t, repos, hasher, "admin", "correct horse")
+	if err := repos.Users.SetRole(context.Background(), admin.ID, models.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+
+	hub := &hubRecorder{}
+	guard := loginguard.NewGuard(loginguard.Config{}, loginguard.NewMemoryStore())
+	return &adminTest{
+		service: NewAdminService(repos.Users, repos.Rooms, repos.Stats, hub, guard, newTwoFactorService(t, repos), nil, audit.NewLogger(repos.Audit)),
+		users:   newUserService(t, repos),
+		hub:     hub,
+		admin:   admin,
+		member:  registerWithPassword(t, repos, hasher, "member", "correct horse"),
+	}
+}
+
+// lockOutTest checks that users locked out by lockOut are refused from their
+// next request and cannot log in, until they are reactivated
+func lockOutTest(t *testing.T, status string, lockOut func(*AdminService, context.Context, uuid.UUID, uuid.UUID) (*models.User, error)) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		at := newAdminTest(t, repos)
+		claims := sessionClaims(t, at.member)
+
+		user, err := lockOut(at.service, ctx, at.admin.ID, at.member.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.Status != status {
+			t.Errorf("got status %s, want %s", user.Status, status)
+		}
+		checkRefused(t, at.users.CheckAccount(ctx, claims))
+		if len(at.hub.users) != 1 || at.hub.users[0] != at.member.ID {
+			t.Errorf("disconnected %v, want the member once", at.hub.users)
+		}
+		if _, err := at.users.authenticator.Authenticate(ctx, "member", "correct horse"); err == nil {
+			t.Errorf("a %s user logged in", status)
+		}
+
+		user, err = at.service.ReactivateUser(ctx, at.admin.ID, at.member.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.Status != models.UserStatusActive {
+			t.Errorf("got status %s after reactivation, want %s", user.Status, models.UserStatusActive)
+		}
+		if err := at.users.CheckAccount(ctx, claims); err != nil {
+			t.Errorf("the session does not work after reactivation: %v", err)
+		}
+		if _, err := at.users.authenticator.Authenticate(ctx, "member", "correct horse"); err != nil {
+			t.Errorf("the user cannot log in after reactivation: %v", err)
+		}
+	})
+}
+
+func TestSuspendTakesEffectAtOnce(t *testing.T) {
+	lockOutTest(t, models.UserStatusSuspended, (*AdminService).SuspendUser)
+}
+
+func TestDeactivateUserTakesEffectAtOnce(t *testing.T) {
+	lockOutTest(t, models.UserStatusDeactivated, (*AdminService).DeactivateUser)
+}
+
+// Bots stop working while their owner is suspended
+func TestLockOutStopsBots(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		at := newAdminTest(t, repos)
+		bot, err := repos.Users.CreateBot(ctx, at.member.ID, "helper", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims := sessionClaims(t, bot)
+		if err := at.users.CheckAccount(ctx, claims); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := at.service.SuspendUser(ctx, at.admin.ID, at.member.ID); err != nil {
+			t.Fatal(err)
+		}
+		checkRefused(t, at.users.CheckAccount(ctx, claims))
+
+		if _, err := at.service.ReactivateUser(ctx, at.admin.ID, at.member.ID); err != nil {
+			t.Fatal(err)
+		}
+		if err := at.users.CheckAccount(ctx, claims); err != nil {
+			t.Errorf("the bot does not work after its owner's reactivation: %v", err)
+		}
+	})
+}
+
+// Admins cannot act on their own account or on another admin's, who must be
+// demoted first
+func TestAdminGuard(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		at := newAdminTest(t, repos)
+		other := registerWithPassword(t, repos, newTestHasher(t), "other", "correct horse")
+		if _, err := at.service.SetRole(ctx, at.admin.ID, other.ID, models.RoleAdmin); err != nil {
+			t.Fatal(err)
+		}
+
+		actions := map[string]func(targetID uuid.UUID) error{
+			"suspend": func(targetID uuid.UUID) error {
+				_, err := at.service.SuspendUser(ctx, at.admin.ID, targetID)
+				return err
+			},
+			"deactivate": func(targetID uuid.UUID) error {
+				_, err := at.service.DeactivateUser(ctx, at.admin.ID, targetID)
+				return err
+			},
+			"delete": func(targetID uuid.UUID) error {
+				return at.service.DeleteUser(ctx, at.admin.ID, targetID)
+			},
+			"reset 2fa": func(targetID uuid.UUID) error {
+				return at.service.ResetTwoFactor(ctx, at.admin.ID, targetID)
+			},
+		}
+		for name, action := range actions {
+			for _, target := range []*models.User{at.admin, other} {
+				if err := action(target.ID); err == nil {
+					t.Errorf("%s: an admin acted on %s", name, target.Username)
+				}
+				user, err := repos.Users.GetByID(ctx, target.ID)
+				if err != nil {
+					t.Fatalf("%s: %s is gone: %v", name, target.Username, err)
+				}
+				if user.Status != models.UserStatusActive {
+					t.Errorf("%s: %s is %s", name, target.Username, user.Status)
+				}
+			}
+		}
+		if len(at.hub.users) != 0 {
+			t.Errorf("disconnected %v, want nobody", at.hub.users)
+		}
+		if _, err := at.service.SetRole(ctx, at.admin.ID, at.admin.ID, models.RoleUser); err == nil {
+			t.Error("an admin changed their own role")
+		}
+
+		// Once demoted, the other admin can be suspended
+		if _, err := at.service.SetRole(ctx, at.admin.ID, other.ID, models.RoleUser); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := at.service.SuspendUser(ctx, at.admin.ID, other.ID); err != nil {
+			t.Errorf("a demoted admin could not be suspended: %v", err)
+		}
+
+		// Every refused action is audited
+		var refused int
+		for _, event := range auditEvents(t, repos) {
+			if event.Result == models.AuditFailure {
+				refused++
+			}
+		}
+		if want := 2 * len(actions); refused != want {
+			t.Errorf("got %d failures audited, want %d", refused, want)
+		}
+	})
+}
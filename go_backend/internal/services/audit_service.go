@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+)
+
+// Events read per query when walking the whole audit log
+const auditPageSize = 500
+
+type AuditService struct {
+	auditRepo repository.AuditRepository
+}
+
+func NewAuditService(auditRepo repository.AuditRepository) *AuditService {
+	return &AuditService{
+		auditRepo: auditRepo,
+	}
+}
+
+// GetEvents returns the audit events matching filter, newest first
+func (s *AuditService) GetEvents(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error) {
+	switch filter.Result {
+	case "", models.AuditSuccess, models.AuditFailure:
+	default:
+		return nil, errors.New("result must be success or failure")
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = 50 // Default limit
+	}
+	if filter.Limit > 500 {
+		filter.Limit = 500 // Max limit
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	return s.auditRepo.List(ctx, filter)
+}
+
+// Export calls fn with every event after afterSeq, oldest first
+func (s *AuditService) Export(ctx context.Context, afterSeq int64, fn func(models.AuditEvent) error) error {
+	for {
+		events, err := s.auditRepo.Range(ctx, afterSeq, auditPageSize)
+		if err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			if err := fn(event); err != nil {
+				return err
+			}
+			afterSeq = event.Seq
+		}
+
+		if len(events) < auditPageSize {
+			return nil
+		}
+	}
+}
+
+// Verify walks the whole audit log and checks that every event is sealed by
+// its hash and chained to the one before it
+func (s *AuditService) Verify(ctx context.Context) (*models.AuditVerification, error) {
+	result := &models.AuditVerification{Valid: true}
+	var prev models.AuditEvent
+
+	err := s.Export(ctx, 0, func(event models.AuditEvent) error {
+		var problem string
+		switch {
+		case event.Seq != prev.Seq+1:
+			problem = fmt.Sprintf("expected seq %d", prev.Seq+1)
+		case event.PrevHash != prev.Hash:
+			problem = "prev_hash does not match the previous event"
+		case event.Hash != event.ComputeHash():
+			problem = "hash does not match the event's contents"
+		}
+
+		if problem != "" {
+			result.Valid = false
+			result.BrokenSeq = event.Seq
+			result.Error = problem
+			return errStopVerify
+		}
+
+		result.Checked++
+		prev = event
+		return nil
+	})
+	if err != nil && err != errStopVerify {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+var errStopVerify = errors.New("stop verifying")
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/GavinHemsada/go-backend/internal/audit"
+	"github.com/GavinHemsada/go-backend/internal/models"
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+)
+
+// tamperedAudit returns the log with the event at seq changed by tamper, as
+// if someone had edited the row
+type tamperedAudit struct {
+	repository.AuditRepository
+	seq    int64
+	tamper func(event *models.AuditEvent)
+}
+
+func (r tamperedAudit) Range(ctx context.Context, afterSeq int64, limit int) ([]models.AuditEvent, error) {
+	events, err := r.AuditRepository.Range(ctx, afterSeq, limit)
+	for i := range events {
+		if events[i].Seq == r.seq {
+			r.tamper(&events[i])
+		}
+	}
+	return events, err
+}
+
+// recordEvents appends n events to the audit log
+func recordEvents(t *testing.T, repos *repository.Repositories, n int) {
+	t.Helper()
+	logger := audit.NewLogger(repos.Audit)
+	for i := 0; i < n; i++ {
+		err := logger.Write(context.Background(), models.AuditEvent{Action: models.AuditUserLogin, Details: fmt.Sprint("event ", i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAuditVerify(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		recordEvents(t, repos, 5)
+
+		result, err := NewAuditService(repos.Audit).Verify(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Valid || result.Checked != 5 {
+			t.Fatalf("got %+v, want 5 valid events", result)
+		}
+
+		tampering := map[string]func(event *models.AuditEvent){
+			"changed details": func(event *models.AuditEvent) { event.Details = "nothing happened" },
+			"resealed":        func(event *models.AuditEvent) { event.Result = models.AuditFailure; event.Hash = event.ComputeHash() },
+			"removed":         func(event *models.AuditEvent) { *event = models.AuditEvent{Seq: 4} },
+		}
+		for name, tamper := range tampering {
+			result, err := NewAuditService(tamperedAudit{repos.Audit, 3, tamper}).Verify(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Valid || result.Checked > 3 {
+				t.Errorf("%s: got %+v, want the chain broken at event 3 or 4", name, result)
+			}
+		}
+	})
+}
+
+func TestAuditExport(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		total := auditPageSize + 10
+		recordEvents(t, repos, total)
+
+		var seqs []int64
+		err := NewAuditService(repos.Audit).Export(ctx, 5, func(event models.AuditEvent) error {
+			seqs = append(seqs, event.Seq)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(seqs) != total-5 {
+			t.Fatalf("exported %d events, want %d", len(seqs), total-5)
+		}
+		for i, seq := range seqs {
+			if seq != int64(i+6) {
+				t.Fatalf("event %d has seq %d, want %d", i, seq, i+6)
+			}
+		}
+
+		// An error from fn stops the export
+		stop := errors.New("stop")
+		calls := 0
+		err = NewAuditService(repos.Audit).Export(ctx, 0, func(models.AuditEvent) error {
+			calls++
+			return stop
+		})
+		if !errors.Is(err, stop) || calls != 1 {
+			t.Errorf("got %v after %d calls, want the error after one", err, calls)
+		}
+	})
+}
+
+// Events recorded inside a transaction are written once it is over, so a
+// failure is on record even though the action rolled back
+func TestAuditRecordOutlivesRollback(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		logger := audit.NewLogger(repos.Audit)
+		failure := errors.New("failure")
+
+		err := repos.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+			if err := logger.Write(ctx, models.AuditEvent{Action: models.AuditModerationBan}); err != nil {
+				return err
+			}
+			logger.Record(ctx, audit.Outcome(models.AuditEvent{Action: models.AuditModerationKick}, failure))
+			return failure
+		})
+		if !errors.Is(err, failure) {
+			t.Fatalf("got %v, want %v", err, failure)
+		}
+
+		events := auditEvents(t, repos)
+		if len(events) != 1 || events[0].Action != models.AuditModerationKick || events[0].Result != models.AuditFailure {
+			t.Fatalf("got %+v, want only the recorded failure", events)
+		}
+		if result, err := NewAuditService(repos.Audit).Verify(ctx); err != nil || !result.Valid {
+			t.Errorf("got %+v, %v, want a valid chain", result, err)
+		}
+	})
+}
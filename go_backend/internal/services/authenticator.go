@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/GavinHemsada/go-backend/internal/password"
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+)
+
+// ErrUnknownAccount is returned by an Authenticator that has no account for
+// the identifier, so that the next one in the chain is asked
+var ErrUnknownAccount = errors.New("invalid credentials")
+
+// Authenticator checks a username or email and password
+type Authenticator interface {
+	// Authenticate returns the user the credentials belong to. Suspended and
+	// deactivated accounts are refused once the password checks out.
+	Authenticate(ctx context.Context, identifier, password string) (*models.User, error)
+}
+
+// AuthChain asks its authenticators in order. The first one that knows the
+// identifier decides; when none does, the login fails.
+type AuthChain struct {
+	authenticators []Authenticator
+}
+
+func NewAuthChain(authenticators ...Authenticator) *AuthChain {
+	return &AuthChain{authenticators: authenticators}
+}
+
+// Authenticate returns the user from the first authenticator that knows the identifier
+func (c *AuthChain) Authenticate(ctx context.Context, identifier, password string) (*models.User, error) {
+	for _, a := range c.authenticators {
+		user, err := a.Authenticate(ctx, identifier, password)
+		if errors.Is(err, ErrUnknownAccount) {
+			continue
+		}
+		return user, err
+	}
+	return nil, ErrUnknownAccount
+}
+
+// LocalAuthenticator checks passwords against the hashes in the users table.
+// Hashes made by an older algorithm or cost are replaced once the password
+// checks out. Accounts without a password, and linked accounts whose local
+// password does not match, are left to the next authenticator in the chain.
+type LocalAuthenticator struct {
+	userRepo     repository.UserRepository
+	identityRepo repository.IdentityRepository
+	hasher       *password.Hasher
+}
+
+func NewLocalAuthenticator(userRepo repository.UserRepository, identityRepo repository.IdentityRepository, hasher *password.Hasher) *LocalAuthenticator {
+	return &LocalAuthenticator{userRepo: userRepo, identityRepo: identityRepo, hasher: hasher}
+}
+
+// Authenticate checks the password of a local account
+func (a *LocalAuthenticator) Authenticate(ctx context.Context, identifier, password string) (*models.User, error) {
+	user, err := a.userRepo.GetByIdentifier(ctx, identifier)
+	if err != nil {
+		// Unknown identifiers take as long as wrong passwords
+		a.hasher.VerifyDummy(password)
+		return nil, ErrUnknownAccount
+	}
+	// Accounts provisioned by an identity provider have no password to check
+	if user.PasswordHash == "" {
+		a.hasher.VerifyDummy(password)
+		return nil, ErrUnknownAccount
+	}
+	if !a.hasher.Verify(user.PasswordHash, password) {
+		// The password may be the one the directory the account is linked to knows
+		identities, err := a.identityRepo.GetByUser(ctx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		if len(identities) > 0 {
+			return nil, ErrUnknownAccount
+		}
+		return nil, errors.New("invalid credentials")
+	}
+	if user.Status != models.UserStatusActive {
+		return nil, errors.New("account is " + user.Status)
+	}
+
+	if a.hasher.NeedsRehash(user.PasswordHash) {
+		hash, err := a.hasher.Hash(password)
+		if err == nil {
+			err = a.userRepo.SetPasswordHash(ctx, user.ID, hash)
+		}
+		if err != nil {
+			// The old hash still works, so the login goes ahead
+			log.Printf("Failed to upgrade password hash of user %s: %v", user.ID, err)
+		} else {
+			user.PasswordHash = hash
+		}
+	}
+	return user, nil
+}
+
+// checkPassword confirms a logged-in user's password the same way logging in does
+func checkPassword(ctx context.Context, authenticator Authenticator, user *models.User, password string) error {
+	checked, err := authenticator.Authenticate(ctx, user.Username, password)
+	if err != nil {
+		return err
+	}
+	if checked.ID != user.ID {
+		return errors.New("invalid credentials")
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newAuthChain returns local logins followed by directory logins, as
+// AUTH_BACKENDS=local,ldap sets them up
+func newAuthChain(t *testing.T, repos *repository.Repositories, dir *fakeDirectory) *AuthChain {
+	local := NewLocalAuthenticator(repos.Users, repos.Identities, newTestHasher(t))
+	return NewAuthChain(local, newLDAPAuthenticator(repos, dir, LDAPConfig{}, nil))
+}
+
+// Directory users get a local account without a password on their first
+// login, which must not stop the chain on their next one
+func TestAuthChainDirectoryUserLogsInAgain(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		dir := newFakeDirectory()
+		dir.add("carol", "directory pass", "carol@corp.example")
+		chain := newAuthChain(t, repos, dir)
+
+		first, err := chain.Authenticate(ctx, "carol", "directory pass")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 2; i++ {
+			again, err := chain.Authenticate(ctx, "carol", "directory pass")
+			if err != nil {
+				t.Fatalf("login %d: %v", i+2, err)
+			}
+			if again.ID != first.ID {
+				t.Fatalf("login %d: got account %s, want %s", i+2, again.ID, first.ID)
+			}
+		}
+		if _, err := chain.Authenticate(ctx, "carol", "wrong"); err == nil {
+			t.Error("logged in with a wrong password")
+		}
+
+		// No password matches an account without one
+		local := NewLocalAuthenticator(repos.Users, repos.Identities, newTestHasher(t))
+		if _, err := local.Authenticate(ctx, "carol", ""); !errors.Is(err, ErrUnknownAccount) {
+			t.Errorf("got %v, want %v", err, ErrUnknownAccount)
+		}
+	})
+}
+
+// A local account linked to a directory entry accepts both passwords
+func TestAuthChainLinkedAccount(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		alice := registerWithPassword(t, repos, newTestHasher(t), "alice", "local pass")
+		dir := newFakeDirectory()
+		entry := dir.add("alice", "directory pass", alice.Email)
+		chain := newAuthChain(t, repos, dir)
+
+		// Linked by an earlier login, such as one made while the directory came first
+		err := repos.Identities.Create(ctx, &models.Identity{UserID: alice.ID, Issuer: dir.Issuer(), Subject: entry.ID, Email: alice.Email})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, pass := range []string{"directory pass", "directory pass", "local pass"} {
+			user, err := chain.Authenticate(ctx, "alice", pass)
+			if err != nil {
+				t.Fatalf("%s: %v", pass, err)
+			}
+			if user.ID != alice.ID {
+				t.Fatalf("%s: got account %s, want alice's", pass, user.ID)
+			}
+		}
+		if _, err := chain.Authenticate(ctx, "alice", "wrong"); err == nil {
+			t.Error("logged in with a wrong password")
+		}
+	})
+}
+
+// The local account decides for users the directory has not vouched for
+func TestAuthChainLocalUser(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		bob := registerWithPassword(t, repos, newTestHasher(t), "bob", "local pass")
+		dir := newFakeDirectory()
+		dir.add("bob", "directory pass", "bob@elsewhere.example")
+		chain := newAuthChain(t, repos, dir)
+
+		user, err := chain.Authenticate(ctx, "bob", "local pass")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.ID != bob.ID {
+			t.Fatalf("got account %s, want bob's", user.ID)
+		}
+		if _, err := chain.Authenticate(ctx, "bob", "directory pass"); err == nil || errors.Is(err, ErrUnknownAccount) {
+			t.Errorf("got %v, want the local account to refuse the password", err)
+		}
+		if dir.binds != 0 {
+			t.Errorf("the directory was asked %d times about a local user", dir.binds)
+		}
+
+		if _, err := chain.Authenticate(ctx, "nobody", "pass"); !errors.Is(err, ErrUnknownAccount) {
+			t.Errorf("got %v for an unknown user, want %v", err, ErrUnknownAccount)
+		}
+	})
+}
+
+// A legacy hash is replaced by an Argon2id one when the user logs in with it,
+// and left alone when the login fails
+func TestLocalAuthenticatorUpgradesLegacyHash(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		legacy, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+		if err != nil {
+			t.Fatal(err)
+		}
+		alice, err := repos.Users.Register(ctx, "alice", "alice@example.com", string(legacy))
+		if err != nil {
+			t.Fatal(err)
+		}
+		hasher := newTestHasher(t)
+		local := NewLocalAuthenticator(repos.Users, repos.Identities, hasher)
+
+		storedHash := func() string {
+			t.Helper()
+			user, err := repos.Users.GetByID(ctx, alice.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return user.PasswordHash
+		}
+
+		if _, err := local.Authenticate(ctx, "alice", "wrong"); err == nil {
+			t.Fatal("logged in with a wrong password")
+		}
+		if storedHash() != string(legacy) {
+			t.Error("a failed login changed the stored hash")
+		}
+
+		if _, err := local.Authenticate(ctx, "alice", "correct horse"); err != nil {
+			t.Fatal(err)
+		}
+		upgraded := storedHash()
+		if !strings.HasPrefix(upgraded, "$argon2id$") || hasher.NeedsRehash(upgraded) {
+			t.Fatalf("got the hash %q, want a current Argon2id one", upgraded)
+		}
+		if !hasher.Verify(upgraded, "correct horse") {
+			t.Error("the password does not match the upgraded hash")
+		}
+
+		if _, err := local.Authenticate(ctx, "alice", "correct horse"); err != nil {
+			t.Fatal(err)
+		}
+		if storedHash() != upgraded {
+			t.Error("a current hash was replaced again")
+		}
+	})
+}
@@ -0,0 +1,245 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/audit"
+	"github.com/GavinHemsada/go-backend/internal/dtos"
+	"github.com/GavinHemsada/go-backend/internal/models"
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+	"github.com/google/uuid"
+)
+
+const (
+	// Most bots a user may own.
+	maxBotsPerUser = 20
+
+	// Longest username, as stored in the users table.
+	maxUsernameLength = 50
+)
+
+// botTokenScopes are the scopes a bot token may carry. Bots are added to
+// rooms by room moderators, so they never manage rooms themselves.
+var botTokenScopes = []string{models.ScopeMessagesRead, models.ScopeMessagesWrite}
+
+// BotService handles bot accounts. A bot is owned by a human user, who
+// issues its tokens; room moderators add it to their rooms, where it posts
+// over REST or the WebSocket like any member.
+type BotService struct {
+	userRepo       repository.UserRepository
+	roomRepo       repository.RoomRepository
+	moderationRepo repository.ModerationRepository
+	txManager      repository.TxManager
+	moderation     *ModerationService
+	tokens         *AccessTokenService
+	hub            ConnectionCloser
+	auditLog       *audit.Logger
+}
+
+func NewBotService(userRepo repository.UserRepository, roomRepo repository.RoomRepository, moderationRepo repository.ModerationRepository, txManager repository.TxManager, moderation *ModerationService, tokens *AccessTokenService, hub ConnectionCloser, auditLog *audit.Logger) *BotService {
+	return &BotService{
+		userRepo:       userRepo,
+		roomRepo:       roomRepo,
+		moderationRepo: moderationRepo,
+		txManager:      txManager,
+		moderation:     moderation,
+		tokens:         tokens,
+		hub:            hub,
+		auditLog:       auditLog,
+	}
+}
+
+// CreateBot creates a bot owned by ownerID
+func (s *BotService) CreateBot(ctx context.Context, ownerID uuid.UUID, username, displayName string) (*models.User, error) {
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return nil, errors.New("username is required")
+	}
+	if len(username) > maxUsernameLength {
+		return nil, errors.New("username must be at most 50 characters")
+	}
+	if strings.Contains(username, "@") {
+		return nil, errors.New("username cannot contain @")
+	}
+	displayName = strings.TrimSpace(displayName)
+	if len([]rune(displayName)) > maxDisplayNameLength {
+		return nil, errors.New("display name must be at most 100 characters")
+	}
+
+	owner, err := s.userRepo.GetByID(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if owner.IsBot {
+		return nil, errors.New("bots cannot own bots")
+	}
+
+	bots, err := s.userRepo.GetByOwner(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if len(bots) >= maxBotsPerUser {
+		return nil, errors.New("too many bots, delete one first")
+	}
+
+	if _, err := s.userRepo.GetByIdentifier(ctx, username); err == nil {
+		return nil, errors.New("username already exists")
+	}
+
+	bot, err := s.userRepo.CreateBot(ctx, ownerID, username, displayName)
+	event := models.AuditEvent{
+		ActorID:    &ownerID,
+		Action:     models.AuditBotCreate,
+		TargetType: models.AuditTargetUser,
+		TargetID:   username,
+	}
+	if err == nil {
+		event.TargetID = bot.ID.String()
+	}
+	s.auditLog.Record(ctx, audit.Outcome(event, err))
+	if err != nil {
+		return nil, err
+	}
+	return bot, nil
+}
+
+// GetBots lists the bots owned by ownerID
+func (s *BotService) GetBots(ctx context.Context, ownerID uuid.UUID) ([]models.User, error) {
+	return s.userRepo.GetByOwner(ctx, ownerID)
+}
+
+// DeleteBot deletes one of the owner's bots with its tokens and memberships,
+// and closes its connections. Its messages stay without an author.
+func (s *BotService) DeleteBot(ctx context.Context, ownerID, botID uuid.UUID) error {
+	if _, err := s.ownedBot(ctx, ownerID, botID); err != nil {
+		return err
+	}
+
+	err := s.userRepo.Delete(ctx, botID)
+	s.auditLog.Record(ctx, audit.Outcome(models.AuditEvent{
+		ActorID:    &ownerID,
+		Action:     models.AuditBotDelete,
+		TargetType: models.AuditTargetUser,
+		TargetID:   botID.String(),
+	}, err))
+	if err != nil {
+		return err
+	}
+
+	if s.hub != nil {
+		s.hub.DisconnectAll(botID, "bot deleted")
+	}
+	return nil
+}
+
+// CreateToken issues a token for one of the owner's bots
+func (s *BotService) CreateToken(ctx context.Context, ownerID, botID uuid.UUID, name string, scopes []string, expiresAt *time.Time) (*dtos.AccessTokenResponse, error) {
+	if _, err := s.ownedBot(ctx, ownerID, botID); err != nil {
+		return nil, err
+	}
+	for _, scope := range scopes {
+		if containsString(models.AccessTokenScopes, scope) && !containsString(botTokenScopes, scope) {
+			return nil, errors.New("bot tokens cannot have the " + scope + " scope")
+		}
+	}
+	return s.tokens.create(ctx, ownerID, botID, name, scopes, expiresAt)
+}
+
+// GetTokens lists the tokens of one of the owner's bots
+func (s *BotService) GetTokens(ctx context.Context, ownerID, botID uuid.UUID) ([]models.AccessToken, error) {
+	if _, err := s.ownedBot(ctx, ownerID, botID); err != nil {
+		return nil, err
+	}
+	return s.tokens.GetTokens(ctx, botID)
+}
+
+// RevokeToken revokes a token of one of the owner's bots
+func (s *BotService) RevokeToken(ctx context.Context, ownerID, botID, tokenID uuid.UUID) error {
+	if _, err := s.ownedBot(ctx, ownerID, botID); err != nil {
+		return err
+	}
+	return s.tokens.revoke(ctx, ownerID, botID, tokenID)
+}
+
+// AddToRoom makes a bot a member of the room. Only the room's moderators may
+// add bots, and bots banned from the room cannot be added.
+func (s *BotService) AddToRoom(ctx context.Context, roomID, actorID, botID uuid.UUID) error {
+	err := s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.moderation.requireModerator(ctx, roomID, actorID); err != nil {
+			return err
+		}
+
+		bot, err := s.bot(ctx, botID)
+		if err != nil {
+			return err
+		}
+		if bot.Status != models.UserStatusActive {
+			return errors.New("bot is " + bot.Status)
+		}
+
+		ban, err := s.moderationRepo.GetBan(ctx, roomID, botID)
+		if err != nil {
+			return err
+		}
+		if ban != nil {
+			return errors.New("bot is banned from this room")
+		}
+
+		return s.roomRepo.AddMember(ctx, roomID, botID)
+	})
+	s.recordRoom(ctx, models.AuditRoomBotAdd, roomID, actorID, botID, err)
+	return err
+}
+
+// RemoveFromRoom takes a bot out of the room and closes its connections to it
+func (s *BotService) RemoveFromRoom(ctx context.Context, roomID, actorID, botID uuid.UUID) error {
+	err := s.moderation.requireModerator(ctx, roomID, actorID)
+	if err == nil {
+		_, err = s.bot(ctx, botID)
+	}
+	if err == nil {
+		err = s.roomRepo.RemoveMember(ctx, roomID, botID)
+	}
+	s.recordRoom(ctx, models.AuditRoomBotRemove, roomID, actorID, botID, err)
+	if err != nil {
+		return err
+	}
+
+	s.moderation.disconnect(ctx, roomID, botID, "removed from room")
+	return nil
+}
+
+// bot returns the bot with the given ID, failing for human users
+func (s *BotService) bot(ctx context.Context, botID uuid.UUID) (*models.User, error) {
+	bot, err := s.userRepo.GetByID(ctx, botID)
+	if err != nil || !bot.IsBot {
+		return nil, errors.New("bot not found")
+	}
+	return bot, nil
+}
+
+// ownedBot returns the bot with the given ID if ownerID owns it
+func (s *BotService) ownedBot(ctx context.Context, ownerID, botID uuid.UUID) (*models.User, error) {
+	bot, err := s.bot(ctx, botID)
+	if err != nil {
+		return nil, err
+	}
+	if bot.OwnerID == nil || *bot.OwnerID != ownerID {
+		return nil, errors.New("bot not found")
+	}
+	return bot, nil
+}
+
+// recordRoom audits a moderator adding a bot to a room or removing it
+func (s *BotService) recordRoom(ctx context.Context, action string, roomID, actorID, botID uuid.UUID, err error) {
+	s.auditLog.Record(ctx, audit.Outcome(models.AuditEvent{
+		ActorID:    &actorID,
+		Action:     action,
+		TargetType: models.AuditTargetRoom,
+		TargetID:   roomID.String(),
+		Details:    botID.String(),
+	}, err))
+}
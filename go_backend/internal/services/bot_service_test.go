@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/audit"
+	"github.com/GavinHemsada/go-backend/internal/models"
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+)
+
+type botTest struct {
+	service   *BotService
+	tokens    *AccessTokenService
+	messages  *MessageService
+	room      *models.Room
+	moderator *models.User
+	owner     *models.User
+	bot       *models.User
+}
+
+// newBotTest returns a bot service and a bot owned by a member of a room the
+// bot has not been added to. The moderation service disconnects through enforcer.
+func newBotTest(t *testing.T, repos *repository.Repositories, enforcer ModerationEnforcer) *botTest {
+	t.Helper()
+	moderation, room, moderator, owner := newModerationTest(t, repos, repos.Audit)
+	moderation.enforcer = enforcer
+	auditLog := audit.NewLogger(repos.Audit)
+
+	tokens := NewAccessTokenService(repos.Users, repos.AccessTokens, auditLog)
+	service := NewBotService(repos.Users, repos.Rooms, repos.Moderation, repos.TxManager, moderation, tokens, nil, auditLog)
+	bot, err := service.CreateBot(context.Background(), owner.ID, "helper", "Helper")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &botTest{
+		service:   service,
+		tokens:    tokens,
+		messages:  NewMessageService(repos.Messages, repos.Rooms, repos.Users, repos.Moderation, repos.Reports, repos.TxManager, nil, auditLog),
+		room:      room,
+		moderator: moderator,
+		owner:     owner,
+		bot:       bot,
+	}
+}
+
+// Bot tokens can read and write messages, and nothing else
+func TestBotTokenScopes(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		bt := newBotTest(t, repos, nil)
+
+		for _, scopes := range [][]string{
+			{models.ScopeRoomsManage},
+			{models.ScopeMessagesRead, models.ScopeRoomsManage},
+			{"admin"},
+		} {
+			if _, err := bt.service.CreateToken(ctx, bt.owner.ID, bt.bot.ID, "script", scopes, nil); err == nil {
+				t.Errorf("a bot token was issued with scopes %v", scopes)
+			}
+		}
+
+		resp, err := bt.service.CreateToken(ctx, bt.owner.ID, bt.bot.ID, "script", botTokenScopes, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims, err := bt.tokens.Authenticate(ctx, resp.Token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if claims.UserID != bt.bot.ID || !claims.Scopes.Has(models.ScopeMessagesRead) ||
+			!claims.Scopes.Has(models.ScopeMessagesWrite) || claims.Scopes.Has(models.ScopeRoomsManage) {
+			t.Errorf("got %+v, want the bot with the message scopes", claims)
+		}
+
+		// Only the owner manages the bot's tokens
+		if _, err := bt.service.CreateToken(ctx, bt.moderator.ID, bt.bot.ID, "script", botTokenScopes, nil); err == nil {
+			t.Error("a user issued a token for a bot they do not own")
+		}
+		if err := bt.service.RevokeToken(ctx, bt.moderator.ID, bt.bot.ID, resp.AccessToken.ID); err == nil {
+			t.Error("a user revoked a token of a bot they do not own")
+		}
+
+		if err := bt.service.RevokeToken(ctx, bt.owner.ID, bt.bot.ID, resp.AccessToken.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := bt.tokens.Authenticate(ctx, resp.Token); err == nil {
+			t.Error("a revoked bot token still works")
+		}
+	})
+}
+
+// Bots only post in rooms a moderator added them to
+func TestBotRoomMembership(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		enforcer := &disconnectRecorder{}
+		bt := newBotTest(t, repos, enforcer)
+
+		if _, err := bt.messages.CreateMessage(ctx, bt.room.ID, bt.bot.ID, "hello", ""); err == nil {
+			t.Error("a bot posted in a room it was not added to")
+		}
+		if canPost, err := bt.messages.CanPost(ctx, bt.room.ID, bt.bot.ID); err != nil || canPost {
+			t.Errorf("got %v, %v, want the bot kept out of the room", canPost, err)
+		}
+		if err := bt.service.AddToRoom(ctx, bt.room.ID, bt.owner.ID, bt.bot.ID); err == nil {
+			t.Error("a member who does not moderate the room added a bot to it")
+		}
+		if err := bt.service.AddToRoom(ctx, bt.room.ID, bt.moderator.ID, bt.owner.ID); err == nil {
+			t.Error("a human user was added as a bot")
+		}
+
+		if err := bt.service.AddToRoom(ctx, bt.room.ID, bt.moderator.ID, bt.bot.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := bt.messages.CreateMessage(ctx, bt.room.ID, bt.bot.ID, "hello", ""); err != nil {
+			t.Fatalf("the bot cannot post after being added: %v", err)
+		}
+
+		if err := bt.service.RemoveFromRoom(ctx, bt.room.ID, bt.moderator.ID, bt.bot.ID); err != nil {
+			t.Fatal(err)
+		}
+		if len(enforcer.users) != 1 || enforcer.users[0] != bt.bot.ID {
+			t.Errorf("disconnected %v, want the bot once", enforcer.users)
+		}
+		if _, err := bt.messages.CreateMessage(ctx, bt.room.ID, bt.bot.ID, "still here", ""); err == nil {
+			t.Error("a bot posted after being removed from the room")
+		}
+	})
+}
+
+func TestBannedBotCannotBeAdded(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		bt := newBotTest(t, repos, nil)
+		moderation := bt.service.moderation
+
+		if err := bt.service.AddToRoom(ctx, bt.room.ID, bt.moderator.ID, bt.bot.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := moderation.Ban(ctx, bt.room.ID, bt.moderator.ID, bt.bot.ID, time.Hour, "spam"); err != nil {
+			t.Fatal(err)
+		}
+		if err := bt.service.AddToRoom(ctx, bt.room.ID, bt.moderator.ID, bt.bot.ID); err == nil {
+			t.Error("a banned bot was added back to the room")
+		}
+		if isMember, err := repos.Rooms.IsMember(ctx, bt.room.ID, bt.bot.ID); err != nil || isMember {
+			t.Errorf("got %v, %v, want the bot out of the room", isMember, err)
+		}
+	})
+}
+
+// Messages are stored with whether their author is a bot
+func TestBotMessagesAreMarked(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		bt := newBotTest(t, repos, nil)
+		if err := bt.service.AddToRoom(ctx, bt.room.ID, bt.moderator.ID, bt.bot.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		fromBot, err := bt.messages.CreateMessage(ctx, bt.room.ID, bt.bot.ID, "beep", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fromOwner, err := bt.messages.CreateMessage(ctx, bt.room.ID, bt.owner.ID, "hi", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		batch, errs := bt.messages.CreateMessages(ctx, []NewMessage{
+			{RoomID: bt.room.ID, UserID: bt.bot.ID, Content: "boop"},
+			{RoomID: bt.room.ID, UserID: bt.owner.ID, Content: "ok"},
+		})
+		if errs[0] != nil || errs[1] != nil {
+			t.Fatalf("the batch failed: %v", errs)
+		}
+
+		for _, want := range []struct {
+			message *models.Message
+			isBot   bool
+		}{
+			{fromBot, true},
+			{fromOwner, false},
+			{batch[0], true},
+			{batch[1], false},
+		} {
+			if want.message.IsBot != want.isBot {
+				t.Errorf("%q: got is_bot %v, want %v", want.message.Content, want.message.IsBot, want.isBot)
+			}
+			stored, err := repos.Messages.GetByID(ctx, want.message.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.IsBot != want.isBot {
+				t.Errorf("%q: stored with is_bot %v, want %v", stored.Content, stored.IsBot, want.isBot)
+			}
+		}
+
+		listed, err := bt.messages.GetMessagesByRoom(ctx, bt.room.ID, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, message := range listed {
+			if message.IsBot != (message.UserID == bt.bot.ID) {
+				t.Errorf("%q: listed with is_bot %v", message.Content, message.IsBot)
+			}
+		}
+	})
+}
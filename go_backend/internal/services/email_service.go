@@ -0,0 +1,320 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/audit"
+	"github.com/GavinHemsada/go-backend/internal/loginguard"
+	"github.com/GavinHemsada/go-backend/internal/mailer"
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/GavinHemsada/go-backend/internal/password"
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+	"github.com/GavinHemsada/go-backend/pkg/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	// Shortest wait between two emails of the same kind to one account, so
+	// the endpoints cannot be used to flood an inbox. Each request made
+	// during the wait starts it over.
+	emailCooldown = time.Minute
+
+	// Time allowed for sending an email in the background.
+	emailSendTimeout = time.Minute
+)
+
+// EmailService verifies users' email addresses and resets forgotten
+// passwords through signed links sent by email
+type EmailService struct {
+	userRepo     repository.UserRepository
+	settingsRepo repository.SettingsRepository
+	mailer       mailer.Mailer
+	guard        *loginguard.Guard
+	hasher       *password.Hasher
+	policy       *password.Policy
+	jwtSecret    string
+	appURL       string
+	verifyTTL    time.Duration
+	resetTTL     time.Duration
+	auditLog     *audit.Logger
+	cooldowns    loginguard.Store // emails sent per purpose and user, shared by every instance using the store
+}
+
+// NewEmailService creates an EmailService. cooldowns keeps track of the
+// emails sent to each account; share it between server instances so the
+// cooldown holds across them.
+func NewEmailService(userRepo repository.UserRepository, settingsRepo repository.SettingsRepository, mail mailer.Mailer, guard *loginguard.Guard, hasher *password.Hasher, policy *password.Policy, jwtSecret, appURL string, verifyTTL, resetTTL time.Duration, auditLog *audit.Logger, cooldowns loginguard.Store) *EmailService {
+	return &EmailService{
+		userRepo:     userRepo,
+		settingsRepo: settingsRepo,
+		mailer:       mail,
+		guard:        guard,
+		hasher:       hasher,
+		policy:       policy,
+		jwtSecret:    jwtSecret,
+		appURL:       appURL,
+		verifyTTL:    verifyTTL,
+		resetTTL:     resetTTL,
+		auditLog:     auditLog,
+		cooldowns:    cooldowns,
+	}
+}
+
+// Policy reports whether users must verify their email before joining rooms
+func (s *EmailService) Policy(ctx context.Context) (bool, error) {
+	value, err := s.settingsRepo.Get(ctx, models.SettingEmailVerificationRequired)
+	if err != nil {
+		return false, err
+	}
+	return value == "true", nil
+}
+
+// SetPolicy changes whether users must verify their email before joining
+// rooms. Rooms they are already in are kept.
+func (s *EmailService) SetPolicy(ctx context.Context, required bool) error {
+	return s.settingsRepo.Set(ctx, models.SettingEmailVerificationRequired, strconv.FormatBool(required))
+}
+
+// RequireVerified returns an error if the policy requires a verified email
+// and the user has none. Bots have no email and are exempt.
+func (s *EmailService) RequireVerified(ctx context.Context, userID uuid.UUID) error {
+	required, err := s.Policy(ctx)
+	if err != nil || !required {
+		return err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.EmailVerified && !user.IsBot {
+		return errors.New("verify your email address first")
+	}
+	return nil
+}
+
+// SendVerification emails the user a link that verifies their address
+func (s *EmailService) SendVerification(ctx context.Context, userID uuid.UUID) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.IsBot {
+		return errors.New("bots have no email address")
+	}
+	if user.EmailVerified {
+		return errors.New("email is already verified")
+	}
+	if !s.mayEmail(ctx, utils.PurposeEmailVerify, user.ID) {
+		return errors.New("a verification email was just sent, try again in a minute")
+	}
+
+	return s.sendLink(ctx, user, utils.PurposeEmailVerify, mailer.TemplateVerifyEmail, "/verify-email", stamp(user.Email), s.verifyTTL)
+}
+
+// SendVerificationLater emails a new user the verification link without
+// holding up their registration. Failures are only logged.
+func (s *EmailService) SendVerificationLater(ctx context.Context, user *models.User) {
+	if !s.mayEmail(ctx, utils.PurposeEmailVerify, user.ID) {
+		return
+	}
+	later(ctx, func(ctx context.Context) error {
+		return s.sendLink(ctx, user, utils.PurposeEmailVerify, mailer.TemplateVerifyEmail, "/verify-email", stamp(user.Email), s.verifyTTL)
+	})
+}
+
+// VerifyEmail marks the address a verification link was sent to as
+// verified. Links sent to an address the account no longer has do not work.
+func (s *EmailService) VerifyEmail(ctx context.Context, token string) (*models.User, error) {
+	user, err := s.linkUser(ctx, token, utils.PurposeEmailVerify, func(user *models.User) string { return stamp(user.Email) })
+	if err == nil && !user.EmailVerified {
+		err = s.userRepo.SetEmailVerified(ctx, user.ID, true)
+	}
+
+	event := models.AuditEvent{Action: models.AuditEmailVerify, TargetType: models.AuditTargetUser}
+	if user != nil {
+		event.ActorID, event.TargetID = &user.ID, user.ID.String()
+	}
+	s.auditLog.Record(ctx, audit.Outcome(event, err))
+	if err != nil {
+		return nil, err
+	}
+
+	user.EmailVerified = true
+	return user, nil
+}
+
+// errNoPassword refuses password resets of accounts that sign in through
+// single sign-on or a directory, whose password is not kept here
+var errNoPassword = errors.New("account has no password to reset")
+
+// RequestPasswordReset emails a password reset link to the account with the
+// given email. It succeeds whether or not there is one, and sends in the
+// background, so that callers cannot tell which addresses have accounts.
+// Accounts without a password get no link.
+func (s *EmailService) RequestPasswordReset(ctx context.Context, email string) error {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return errors.New("email is required")
+	}
+
+	user, err := s.userRepo.GetByIdentifier(ctx, email)
+	if err == nil && (user.Email != email || user.IsBot) {
+		err = errors.New("user not found")
+	}
+	if err == nil && user.PasswordHash == "" {
+		err = errNoPassword
+	}
+
+	// Only the outcome is kept; unknown addresses are not recorded
+	event := models.AuditEvent{Action: models.AuditPasswordResetRequest, TargetType: models.AuditTargetUser}
+	if err == nil {
+		event.ActorID, event.TargetID = &user.ID, user.ID.String()
+	}
+	s.auditLog.Record(ctx, audit.Outcome(event, err))
+	if err != nil || !s.mayEmail(ctx, utils.PurposePasswordReset, user.ID) {
+		return nil
+	}
+
+	later(ctx, func(ctx context.Context) error {
+		return s.sendLink(ctx, user, utils.PurposePasswordReset, mailer.TemplatePasswordReset, "/reset-password", stamp(user.PasswordHash), s.resetTTL)
+	})
+	return nil
+}
+
+// ResetPassword sets a new password through a reset link and logs the user
+// out everywhere, in case the password was reset because someone else knew
+// it. The link stops working once the password changes, so each works once.
+// As the link proved the user reads the account's email, the address counts
+// as verified, and failed login locks are lifted.
+func (s *EmailService) ResetPassword(ctx context.Context, token, password string) error {
+	if password == "" {
+		return errors.New("password is required")
+	}
+
+	user, err := s.linkUser(ctx, token, utils.PurposePasswordReset, func(user *models.User) string { return stamp(user.PasswordHash) })
+	if err == nil && user.PasswordHash == "" {
+		err = errNoPassword
+	}
+	if err == nil {
+		err = s.policy.Check(password, user.Username, user.Email)
+	}
+	var hash string
+	if err == nil {
+		hash, err = s.hasher.Hash(password)
+	}
+	if err == nil {
+		err = s.userRepo.SetPasswordHash(ctx, user.ID, hash)
+	}
+	if err == nil {
+		err = revokeSessions(ctx, s.userRepo, user.ID)
+	}
+	if err == nil && !user.EmailVerified {
+		err = s.userRepo.SetEmailVerified(ctx, user.ID, true)
+	}
+	if err == nil {
+		err = s.guard.UnlockAccount(ctx, loginguard.UserKey(user.ID))
+	}
+
+	event := models.AuditEvent{Action: models.AuditPasswordReset, TargetType: models.AuditTargetUser}
+	if user != nil {
+		event.ActorID, event.TargetID = &user.ID, user.ID.String()
+	}
+	s.auditLog.Record(ctx, audit.Outcome(event, err))
+	return err
+}
+
+// linkUser returns the user a link token was issued to, as long as their
+// account still matches the token's stamp
+func (s *EmailService) linkUser(ctx context.Context, token, purpose string, stampOf func(*models.User) string) (*models.User, error) {
+	claims, err := utils.ParseChallengeToken(token, s.jwtSecret, purpose)
+	if err != nil {
+		return nil, errors.New("invalid or expired link")
+	}
+
+	user, err := s.userRepo.GetByID(ctx, claims.UserID)
+	if err != nil || claims.Stamp != stampOf(user) {
+		return nil, errors.New("invalid or expired link")
+	}
+	return user, nil
+}
+
+// sendLink emails the user a link to path on the frontend carrying a token for purpose
+func (s *EmailService) sendLink(ctx context.Context, user *models.User, purpose, template, path, linkStamp string, ttl time.Duration) error {
+	token, _, err := utils.GenerateLinkToken(user, s.jwtSecret, purpose, linkStamp, ttl)
+	if err != nil {
+		return err
+	}
+
+	msg, err := mailer.Render(template, map[string]string{
+		"Username":  user.Username,
+		"Link":      s.appURL + path + "?token=" + url.QueryEscape(token),
+		"ExpiresIn": formatTTL(ttl),
+	})
+	if err != nil {
+		return err
+	}
+	msg.To = user.Email
+	return s.mailer.Send(ctx, msg)
+}
+
+// later runs send in the background, detached from the request
+func later(ctx context.Context, send func(ctx context.Context) error) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), emailSendTimeout)
+	go func() {
+		defer cancel()
+		if err := send(ctx); err != nil {
+			log.Printf("Failed to send email: %v", err)
+		}
+	}()
+}
+
+// mayEmail reports whether the cooldown allows another email for purpose to
+// the user, and starts a new one. Should the store fail, the email is allowed.
+func (s *EmailService) mayEmail(ctx context.Context, purpose string, userID uuid.UUID) bool {
+	record, err := s.cooldowns.Fail(ctx, "email:"+purpose+":"+userID.String(), time.Now(), emailCooldown)
+	if err != nil {
+		log.Printf("Error reading email cooldown: %v", err)
+		return true
+	}
+	return record.Failures == 1
+}
+
+// revokeSessions stops every session token issued to the user so far from working
+func revokeSessions(ctx context.Context, userRepo repository.UserRepository, id uuid.UUID) error {
+	sessionStamp, err := randomToken()
+	if err != nil {
+		return err
+	}
+	return userRepo.SetSessionStamp(ctx, id, sessionStamp)
+}
+
+// stamp fingerprints the account state a link depends on
+func stamp(value string) string {
+	return hashAccessToken(value)[:16]
+}
+
+// formatTTL writes a link lifetime the way the emails show it, like "2 days"
+func formatTTL(ttl time.Duration) string {
+	units := []struct {
+		size time.Duration
+		name string
+	}{{24 * time.Hour, "day"}, {time.Hour, "hour"}, {time.Minute, "minute"}}
+
+	for _, unit := range units {
+		if ttl < unit.size || ttl%unit.size != 0 {
+			continue
+		}
+		if n := int(ttl / unit.size); n != 1 {
+			return strconv.Itoa(n) + " " + unit.name + "s"
+		}
+		return "1 " + unit.name
+	}
+	return ttl.String()
+}
@@ -0,0 +1,299 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/quotedprintable"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/audit"
+	"github.com/GavinHemsada/go-backend/internal/loginguard"
+	"github.com/GavinHemsada/go-backend/internal/mailer"
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/GavinHemsada/go-backend/internal/password"
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+	"github.com/GavinHemsada/go-backend/pkg/utils"
+)
+
+// mailbox is a directory the file mailer writes to
+type mailbox string
+
+func newMailbox(t *testing.T) (mailbox, *mailer.FileMailer) {
+	t.Helper()
+	dir := t.TempDir()
+	mail, err := mailer.NewFileMailer("chat@example.com", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mailbox(dir), mail
+}
+
+// messages returns the emails delivered so far, decoded
+func (m mailbox) messages(t *testing.T) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(string(m), "*.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var messages []string
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := io.ReadAll(quotedprintable.NewReader(bytes.NewReader(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		messages = append(messages, string(decoded))
+	}
+	return messages
+}
+
+// wait returns the emails once there are n of them, failing the test after a while
+func (m mailbox) wait(t *testing.T, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		messages := m.messages(t)
+		if len(messages) >= n {
+			return messages
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d emails, want %d", len(messages), n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+var linkToken = regexp.MustCompile(`\?token=([A-Za-z0-9._-]+)`)
+
+// token returns the token of the link in an email
+func token(t *testing.T, message string) string {
+	t.Helper()
+	match := linkToken.FindStringSubmatch(message)
+	if match == nil {
+		t.Fatalf("no link in the email:\n%s", message)
+	}
+	return match[1]
+}
+
+// newEmailTest returns an email service delivering to a mailbox, with its
+// cooldowns kept in cooldowns
+func newEmailTest(t *testing.T, repos *repository.Repositories, cooldowns loginguard.Store) (*EmailService, mailbox) {
+	t.Helper()
+	box, mail := newMailbox(t)
+	policy, err := password.NewPolicy(8, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	guard := loginguard.NewGuard(loginguard.Config{}, loginguard.NewMemoryStore())
+	service := NewEmailService(
+		repos.Users, repos.Settings, mail, guard, newTestHasher(t), policy,
+		testJWTSecret, "https://chat.example.com", time.Hour, time.Hour, audit.NewLogger(repos.Audit), cooldowns,
+	)
+	return service, box
+}
+
+func TestEmailVerification(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		service, box := newEmailTest(t, repos, loginguard.NewMemoryStore())
+		alice := registerWithPassword(t, repos, newTestHasher(t), "alice", "correct horse")
+
+		if err := service.SendVerification(ctx, alice.ID); err != nil {
+			t.Fatal(err)
+		}
+		messages := box.wait(t, 1)
+		link := token(t, messages[0])
+
+		// The cooldown holds back a second email
+		if err := service.SendVerification(ctx, alice.ID); err == nil {
+			t.Error("a second verification email was sent right away")
+		}
+
+		user, err := service.VerifyEmail(ctx, link)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !user.EmailVerified {
+			t.Error("the email is not verified")
+		}
+		if err := service.SendVerification(ctx, alice.ID); err == nil {
+			t.Error("a verification email was sent for a verified address")
+		}
+	})
+}
+
+// Verification links only work for the address they were sent to
+func TestEmailVerificationFollowsAddress(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		service, _ := newEmailTest(t, repos, loginguard.NewMemoryStore())
+		alice := registerWithPassword(t, repos, newTestHasher(t), "alice", "correct horse")
+
+		link, _, err := utils.GenerateLinkToken(alice, testJWTSecret, utils.PurposeEmailVerify, stamp("old@example.com"), time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := service.VerifyEmail(ctx, link); err == nil {
+			t.Error("a link sent to another address verified the account")
+		}
+
+		expired, _, err := utils.GenerateLinkToken(alice, testJWTSecret, utils.PurposeEmailVerify, stamp(alice.Email), -time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := service.VerifyEmail(ctx, expired); err == nil {
+			t.Error("an expired link verified the account")
+		}
+	})
+}
+
+func TestPasswordReset(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		service, box := newEmailTest(t, repos, loginguard.NewMemoryStore())
+		hasher := newTestHasher(t)
+		alice := registerWithPassword(t, repos, hasher, "alice", "correct horse")
+		users := &UserService{userRepo: repos.Users}
+
+		session, err := utils.GenerateToken(alice, testJWTSecret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims, err := utils.ParseChallengeToken(session, testJWTSecret, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := users.CheckAccount(ctx, claims); err != nil {
+			t.Fatalf("the session does not work before the reset: %v", err)
+		}
+
+		// Unknown addresses get the same answer and no email
+		if err := service.RequestPasswordReset(ctx, "nobody@example.com"); err != nil {
+			t.Fatal(err)
+		}
+		if err := service.RequestPasswordReset(ctx, alice.Email); err != nil {
+			t.Fatal(err)
+		}
+		messages := box.wait(t, 1)
+		link := token(t, messages[0])
+
+		if err := service.ResetPassword(ctx, link, "alice1234"); err == nil {
+			t.Error("a password the policy refuses was set")
+		}
+		if err := service.ResetPassword(ctx, link, "new battery staple"); err != nil {
+			t.Fatal(err)
+		}
+
+		user, err := repos.Users.GetByID(ctx, alice.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !hasher.Verify(user.PasswordHash, "new battery staple") || hasher.Verify(user.PasswordHash, "correct horse") {
+			t.Error("the password was not replaced")
+		}
+		if !user.EmailVerified {
+			t.Error("the reset did not verify the address")
+		}
+
+		if err := service.ResetPassword(ctx, link, "another good password"); err == nil {
+			t.Error("the link worked twice")
+		}
+
+		// Sessions from before the reset are over; new ones work
+		if err := users.CheckAccount(ctx, claims); err == nil {
+			t.Error("a session from before the reset still works")
+		}
+		session, err = utils.GenerateToken(user, testJWTSecret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if claims, err = utils.ParseChallengeToken(session, testJWTSecret, ""); err != nil {
+			t.Fatal(err)
+		}
+		if err := users.CheckAccount(ctx, claims); err != nil {
+			t.Errorf("a session from after the reset does not work: %v", err)
+		}
+
+		if n := len(box.messages(t)); n != 1 {
+			t.Errorf("got %d emails, want only alice's", n)
+		}
+	})
+}
+
+// Accounts without a password, made by single sign-on, cannot get one through a reset
+func TestPasswordResetRefusesAccountsWithoutPassword(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		service, box := newEmailTest(t, repos, loginguard.NewMemoryStore())
+		carol, err := repos.Users.Register(ctx, "carol", "carol@corp.example", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := service.RequestPasswordReset(ctx, carol.Email); err != nil {
+			t.Fatal(err)
+		}
+
+		link, _, err := utils.GenerateLinkToken(carol, testJWTSecret, utils.PurposePasswordReset, stamp(""), time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := service.ResetPassword(ctx, link, "correct horse battery"); err == nil {
+			t.Error("a password was set on an account without one")
+		}
+
+		user, err := repos.Users.GetByID(ctx, carol.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.PasswordHash != "" {
+			t.Error("the account has a password now")
+		}
+		if n := len(box.messages(t)); n != 0 {
+			t.Errorf("got %d emails, want none", n)
+		}
+
+		events := auditEvents(t, repos)
+		if len(events) != 2 || events[0].Result != models.AuditFailure || events[1].Result != models.AuditFailure {
+			t.Errorf("got %+v, want the request and the reset audited as failures", events)
+		}
+	})
+}
+
+// Instances sharing a cooldown store send one email between them
+func TestEmailCooldownIsShared(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		cooldowns := loginguard.NewMemoryStore()
+		first, box := newEmailTest(t, repos, cooldowns)
+		second, _ := newEmailTest(t, repos, cooldowns)
+		second.mailer = first.mailer
+		alice := registerWithPassword(t, repos, newTestHasher(t), "alice", "correct horse")
+
+		for _, service := range []*EmailService{first, second, first} {
+			if err := service.RequestPasswordReset(ctx, alice.Email); err != nil {
+				t.Fatal(err)
+			}
+		}
+		box.wait(t, 1)
+
+		if err := second.SendVerification(ctx, alice.ID); err != nil {
+			t.Errorf("the reset emails held back a verification email: %v", err)
+		}
+		box.wait(t, 2)
+
+		// Give any email sent by mistake time to arrive
+		time.Sleep(50 * time.Millisecond)
+		if n := len(box.messages(t)); n != 2 {
+			t.Errorf("got %d emails, want one of each kind", n)
+		}
+	})
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/GavinHemsada/go-backend/internal/audit"
+	"github.com/GavinHemsada/go-backend/internal/models"
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+)
+
+// Longest username given to a provisioned account, before a numeric suffix.
+const maxProvisionedUsernameLength = 40
+
+// externalIdentity is a user as an external identity provider vouches for them
+type externalIdentity struct {
+	Protocol string // "oidc", "saml" or "ldap"
+	Issuer   string
+	Subject  string // the provider's stable ID for the user
+	Email    string // verified by the provider
+	Username string // suggested for a new account, may be empty
+}
+
+// externalAccounts finds the local accounts of users logging in through an
+// external identity provider, and creates them on first login
+type externalAccounts struct {
+	userRepo     repository.UserRepository
+	identityRepo repository.IdentityRepository
+	txManager    repository.TxManager
+	auditLog     *audit.Logger
+}
+
+// account returns the user linked to the identity. An identity seen for the
+// first time is linked to the account with the same email, or to a new account.
+func (a *externalAccounts) account(ctx context.Context, id externalIdentity) (*models.User, error) {
+	identity, err := a.identityRepo.GetBySubject(ctx, id.Issuer, id.Subject)
+	if err == nil {
+		if err := a.identityRepo.RecordLogin(ctx, identity.ID, id.Email); err != nil {
+			return nil, err
+		}
+		return a.userRepo.GetByID(ctx, identity.UserID)
+	}
+
+	var user *models.User
+	provisioned := false
+	err = a.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		existing, err := a.userRepo.GetByIdentifier(ctx, id.Email)
+		if err == nil && strings.EqualFold(existing.Email, id.Email) {
+			user = existing
+		} else {
+			user, err = a.provision(ctx, id)
+			if err != nil {
+				return err
+			}
+			provisioned = true
+		}
+
+		// The provider vouches for the email
+		if !user.EmailVerified {
+			if err := a.userRepo.SetEmailVerified(ctx, user.ID, true); err != nil {
+				return err
+			}
+			user.EmailVerified = true
+		}
+
+		identity := &models.Identity{
+			UserID:  user.ID,
+			Issuer:  id.Issuer,
+			Subject: id.Subject,
+			Email:   id.Email,
+		}
+		if err := a.identityRepo.Create(ctx, identity); err != nil {
+			return err
+		}
+		return a.identityRepo.RecordLogin(ctx, identity.ID, id.Email)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	action := models.AuditIdentityLink
+	if provisioned {
+		action = models.AuditUserRegister
+	}
+	a.auditLog.Record(ctx, models.AuditEvent{
+		ActorID:    &user.ID,
+		Action:     action,
+		TargetType: models.AuditTargetUser,
+		TargetID:   user.ID.String(),
+		Details:    id.Protocol + " " + id.Issuer + " " + id.Subject,
+	})
+	return user, nil
+}
+
+// provision creates an account for a new identity. It gets no password, so
+// it can only log in through the provider.
+func (a *externalAccounts) provision(ctx context.Context, id externalIdentity) (*models.User, error) {
+	base := id.Username
+	if base == "" || strings.Contains(base, "@") {
+		base = strings.SplitN(id.Email, "@", 2)[0]
+	}
+	base = usernameFrom(base)
+
+	// Take the first free username among base, base2, base3...
+	username := base
+	for n := 2; ; n++ {
+		if _, err := a.userRepo.GetByIdentifier(ctx, username); err != nil {
+			break
+		}
+		if n > 100 {
+			return nil, errors.New("no free username for the account")
+		}
+		username = base + strconv.Itoa(n)
+	}
+
+	// An empty hash matches no password
+	return a.userRepo.Register(ctx, username, id.Email, "")
+}
+
+// syncRole gives the user the role their provider groups map to. Without
+// admin groups, roles are left to the admins.
+func (a *externalAccounts) syncRole(ctx context.Context, user *models.User, groups, adminGroups []string) error {
+	if len(adminGroups) == 0 {
+		return nil
+	}
+
+	role := models.RoleUser
+	for _, group := range groups {
+		if containsString(adminGroups, group) {
+			role = models.RoleAdmin
+			break
+		}
+	}
+	if user.Role == role {
+		return nil
+	}
+
+	err := a.userRepo.SetRole(ctx, user.ID, role)
+	a.auditLog.Record(ctx, audit.Outcome(models.AuditEvent{
+		Action:     models.AuditAdminSetRole,
+		TargetType: models.AuditTargetUser,
+		TargetID:   user.ID.String(),
+		Details:    "role " + role + ", from identity provider groups",
+	}, err))
+	if err != nil {
+		return err
+	}
+	user.Role = role
+	return nil
+}
+
+// usernameFrom keeps the letters, digits, dots, dashes and underscores of name
+func usernameFrom(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '.' || r == '-' || r == '_' {
+			b.WriteRune(r)
+		}
+	}
+
+	username := b.String()
+	if len(username) > maxProvisionedUsernameLength {
+		username = username[:maxProvisionedUsernameLength]
+	}
+	if username == "" {
+		username = "user"
+	}
+	return username
+}
+
+// randomToken returns 32 random bytes, URL-safe encoded
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
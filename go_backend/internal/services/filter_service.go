@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/GavinHemsada/go-backend/internal/filter"
+	"github.com/GavinHemsada/go-backend/internal/models"
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+	"github.com/google/uuid"
+)
+
+// FilterService manages the per-room word lists of the content filter
+type FilterService struct {
+	filterRepo repository.FilterRepository
+	moderation *ModerationService
+	words      *filter.WordFilter
+}
+
+func NewFilterService(filterRepo repository.FilterRepository, moderation *ModerationService, words *filter.WordFilter) *FilterService {
+	return &FilterService{
+		filterRepo: filterRepo,
+		moderation: moderation,
+		words:      words,
+	}
+}
+
+// AddWord blocks, masks or flags a word or phrase in a room
+func (s *FilterService) AddWord(ctx context.Context, roomID, actorID uuid.UUID, word, action string) (*models.WordFilter, error) {
+	word = strings.ToLower(strings.TrimSpace(word))
+	if word == "" {
+		return nil, errors.New("word is required")
+	}
+
+	if len(word) > 100 {
+		return nil, errors.New("word must be at most 100 characters")
+	}
+
+	switch action {
+	case models.WordActionBlock, models.WordActionMask, models.WordActionFlag:
+	default:
+		return nil, errors.New("action must be block, mask or flag")
+	}
+
+	if err := s.moderation.requireModerator(ctx, roomID, actorID); err != nil {
+		return nil, err
+	}
+
+	wordFilter := &models.WordFilter{
+		RoomID:    roomID,
+		Word:      word,
+		Action:    action,
+		CreatedBy: actorID,
+	}
+	if err := s.filterRepo.AddWord(ctx, wordFilter); err != nil {
+		return nil, err
+	}
+
+	s.invalidate(roomID)
+	return wordFilter, nil
+}
+
+// RemoveWord stops filtering a word in a room
+func (s *FilterService) RemoveWord(ctx context.Context, roomID, actorID uuid.UUID, word string) error {
+	if err := s.moderation.requireModerator(ctx, roomID, actorID); err != nil {
+		return err
+	}
+
+	if err := s.filterRepo.RemoveWord(ctx, roomID, strings.ToLower(strings.TrimSpace(word))); err != nil {
+		return err
+	}
+
+	s.invalidate(roomID)
+	return nil
+}
+
+// GetWords lists a room's word list. Server-wide words are not included.
+func (s *FilterService) GetWords(ctx context.Context, roomID, actorID uuid.UUID) ([]models.WordFilter, error) {
+	if err := s.moderation.requireModerator(ctx, roomID, actorID); err != nil {
+		return nil, err
+	}
+	return s.filterRepo.GetWords(ctx, roomID)
+}
+
+func (s *FilterService) invalidate(roomID uuid.UUID) {
+	if s.words != nil {
+		s.words.Invalidate(roomID)
+	}
+}
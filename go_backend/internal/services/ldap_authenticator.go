@@ -0,0 +1,212 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/audit"
+	"github.com/GavinHemsada/go-backend/internal/directory"
+	"github.com/GavinHemsada/go-backend/internal/models"
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+)
+
+// Longest display name kept from the directory, in characters
+const maxDisplayNameLength = 100
+
+// UserDirectory is the directory LDAPAuthenticator checks users against,
+// in production a *directory.Directory
+type UserDirectory interface {
+	// Issuer names the directory in linked identities
+	Issuer() string
+	// Authenticate returns the user's entry, or directory.ErrUnknownUser
+	Authenticate(ctx context.Context, username, password string) (*directory.Entry, error)
+	// Users returns every entry, with their groups
+	Users(ctx context.Context) ([]directory.Entry, error)
+}
+
+// LDAPConfig holds the group rules of LDAP logins
+type LDAPConfig struct {
+	RequiredGroups []string // when set, only members may log in
+	AdminGroups    []string // when set, members get the admin role and everyone else loses it
+}
+
+// LDAPAuthenticator logs users in with their directory credentials. Each
+// directory entry is linked to a local account, created on first login.
+type LDAPAuthenticator struct {
+	directory    UserDirectory
+	userRepo     repository.UserRepository
+	identityRepo repository.IdentityRepository
+	accounts     *externalAccounts
+	cfg          LDAPConfig
+	hub          ConnectionCloser
+	auditLog     *audit.Logger
+}
+
+func NewLDAPAuthenticator(dir UserDirectory, userRepo repository.UserRepository, identityRepo repository.IdentityRepository, txManager repository.TxManager, cfg LDAPConfig, hub ConnectionCloser, auditLog *audit.Logger) *LDAPAuthenticator {
+	return &LDAPAuthenticator{
+		directory:    dir,
+		userRepo:     userRepo,
+		identityRepo: identityRepo,
+		accounts:     &externalAccounts{userRepo: userRepo, identityRepo: identityRepo, txManager: txManager, auditLog: auditLog},
+		cfg:          cfg,
+		hub:          hub,
+		auditLog:     auditLog,
+	}
+}
+
+// Authenticate binds to the directory as the user, then brings their local
+// account's display name and role in line with the entry
+func (a *LDAPAuthenticator) Authenticate(ctx context.Context, identifier, password string) (*models.User, error) {
+	entry, err := a.directory.Authenticate(ctx, identifier, password)
+	if errors.Is(err, directory.ErrUnknownUser) {
+		return nil, ErrUnknownAccount
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if entry.Disabled {
+		return nil, errors.New("account is deactivated")
+	}
+	if !a.allowed(entry) {
+		return nil, errors.New("account is not allowed to log in")
+	}
+	if entry.Email == "" {
+		return nil, errors.New("directory entry has no email")
+	}
+
+	user, err := a.accounts.account(ctx, externalIdentity{
+		Protocol: "ldap",
+		Issuer:   a.directory.Issuer(),
+		Subject:  entry.ID,
+		Email:    entry.Email,
+		Username: entry.Username,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := a.update(ctx, user, entry); err != nil {
+		return nil, err
+	}
+
+	if user.Status != models.UserStatusActive {
+		return nil, errors.New("account is " + user.Status)
+	}
+	return user, nil
+}
+
+// Sync brings every linked account in line with the directory. Display names
+// and roles follow the entries; accounts whose entry is gone, disabled or out
+// of the required groups are deactivated. Deactivated accounts stay so until
+// an admin reactivates them.
+func (a *LDAPAuthenticator) Sync(ctx context.Context) error {
+	entries, err := a.directory.Users(ctx)
+	if err != nil {
+		return err
+	}
+	// An empty listing more likely means a broken filter than an empty directory
+	if len(entries) == 0 {
+		return errors.New("directory returned no users, skipping sync")
+	}
+	byID := make(map[string]*directory.Entry, len(entries))
+	for i := range entries {
+		byID[entries[i].ID] = &entries[i]
+	}
+
+	identities, err := a.identityRepo.GetByIssuer(ctx, a.directory.Issuer())
+	if err != nil {
+		return err
+	}
+	for _, identity := range identities {
+		user, err := a.userRepo.GetByID(ctx, identity.UserID)
+		if err != nil {
+			return err
+		}
+
+		entry, ok := byID[identity.Subject]
+		switch {
+		case !ok:
+			err = a.deactivate(ctx, user, "no longer in the directory")
+		case entry.Disabled:
+			err = a.deactivate(ctx, user, "disabled in the directory")
+		case !a.allowed(entry):
+			err = a.deactivate(ctx, user, "no longer in a required directory group")
+		default:
+			err = a.update(ctx, user, entry)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunSync calls Sync every interval until ctx is done
+func (a *LDAPAuthenticator) RunSync(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.Sync(ctx); err != nil {
+				log.Printf("Directory sync failed: %v", err)
+			}
+		}
+	}
+}
+
+// allowed tells whether the entry is in one of the required groups, if any
+func (a *LDAPAuthenticator) allowed(entry *directory.Entry) bool {
+	if len(a.cfg.RequiredGroups) == 0 {
+		return true
+	}
+	for _, group := range entry.Groups {
+		if containsString(a.cfg.RequiredGroups, group) {
+			return true
+		}
+	}
+	return false
+}
+
+// update copies the entry's display name to the account and syncs its role
+func (a *LDAPAuthenticator) update(ctx context.Context, user *models.User, entry *directory.Entry) error {
+	displayName := entry.DisplayName
+	if runes := []rune(displayName); len(runes) > maxDisplayNameLength {
+		displayName = string(runes[:maxDisplayNameLength])
+	}
+	if user.DisplayName != displayName {
+		if err := a.userRepo.SetDisplayName(ctx, user.ID, displayName); err != nil {
+			return err
+		}
+		user.DisplayName = displayName
+	}
+	return a.accounts.syncRole(ctx, user, entry.Groups, a.cfg.AdminGroups)
+}
+
+// deactivate closes an active account the directory no longer vouches for
+func (a *LDAPAuthenticator) deactivate(ctx context.Context, user *models.User, reason string) error {
+	if user.Status != models.UserStatusActive {
+		return nil
+	}
+
+	err := a.userRepo.SetStatus(ctx, user.ID, models.UserStatusDeactivated)
+	a.auditLog.Record(ctx, audit.Outcome(models.AuditEvent{
+		Action:     models.AuditAdminDeactivate,
+		TargetType: models.AuditTargetUser,
+		TargetID:   user.ID.String(),
+		Details:    reason,
+	}, err))
+	if err != nil {
+		return err
+	}
+
+	if a.hub != nil {
+		a.hub.DisconnectAll(user.ID, "account deactivated")
+	}
+	return nil
+}
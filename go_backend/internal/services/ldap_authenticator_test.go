@@ -0,0 +1,205 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/GavinHemsada/go-backend/internal/audit"
+	"github.com/GavinHemsada/go-backend/internal/directory"
+	"github.com/GavinHemsada/go-backend/internal/models"
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+	"github.com/google/uuid"
+)
+
+// fakeDirectory stands in for an LDAP directory, answering the way
+// directory.Directory does
+type fakeDirectory struct {
+	users map[string]*directoryUser
+	binds int // password checks made
+}
+
+type directoryUser struct {
+	password string
+	entry    directory.Entry
+}
+
+func newFakeDirectory() *fakeDirectory {
+	return &fakeDirectory{users: make(map[string]*directoryUser)}
+}
+
+// add creates an entry for username, in groups
+func (d *fakeDirectory) add(username, password, email string, groups ...string) *directory.Entry {
+	user := &directoryUser{
+		password: password,
+		entry: directory.Entry{
+			DN:          "uid=" + username + ",ou=people,dc=example,dc=com",
+			ID:          uuid.NewString(),
+			Username:    username,
+			Email:       email,
+			DisplayName: username + " from the directory",
+			Groups:      groups,
+		},
+	}
+	d.users[username] = user
+	return &user.entry
+}
+
+func (d *fakeDirectory) Issuer() string {
+	return "ldap:dc=example,dc=com"
+}
+
+func (d *fakeDirectory) Authenticate(ctx context.Context, username, password string) (*directory.Entry, error) {
+	if username == "" || password == "" {
+		return nil, errors.New("invalid credentials")
+	}
+	user, ok := d.users[username]
+	if !ok {
+		return nil, directory.ErrUnknownUser
+	}
+	d.binds++
+	if user.password != password {
+		return nil, errors.New("invalid credentials")
+	}
+	entry := user.entry
+	return &entry, nil
+}
+
+func (d *fakeDirectory) Users(ctx context.Context) ([]directory.Entry, error) {
+	entries := make([]directory.Entry, 0, len(d.users))
+	for _, user := range d.users {
+		entries = append(entries, user.entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Username < entries[j].Username })
+	return entries, nil
+}
+
+// recordingCloser remembers whose connections were closed
+type recordingCloser struct {
+	closed []uuid.UUID
+}
+
+func (c *recordingCloser) DisconnectAll(userID uuid.UUID, reason string) {
+	c.closed = append(c.closed, userID)
+}
+
+func newLDAPAuthenticator(repos *repository.Repositories, dir *fakeDirectory, cfg LDAPConfig, hub ConnectionCloser) *LDAPAuthenticator {
+	return NewLDAPAuthenticator(dir, repos.Users, repos.Identities, repos.TxManager, cfg, hub, audit.NewLogger(repos.Audit))
+}
+
+func TestLDAPLoginProvisionsAccount(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		dir := newFakeDirectory()
+		entry := dir.add("henry", "directory pass", "henry@corp.example", "staff", "chat-admins")
+		ldap := newLDAPAuthenticator(repos, dir, LDAPConfig{AdminGroups: []string{"chat-admins"}}, nil)
+
+		henry, err := ldap.Authenticate(ctx, "henry", "directory pass")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if henry.Username != "henry" || henry.DisplayName != entry.DisplayName || !henry.EmailVerified || henry.Role != models.RoleAdmin {
+			t.Fatalf("got %+v, want a verified admin account with the entry's display name", henry)
+		}
+		if henry.PasswordHash != "" {
+			t.Error("the directory password was stored locally")
+		}
+
+		// Later logins find the linked account and follow the entry
+		entry.DisplayName = "Henry H."
+		entry.Groups = []string{"staff"}
+		again, err := ldap.Authenticate(ctx, "henry", "directory pass")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again.ID != henry.ID || again.DisplayName != "Henry H." || again.Role != models.RoleUser {
+			t.Errorf("got %+v, want henry renamed and demoted", again)
+		}
+	})
+}
+
+func TestLDAPLoginRefusals(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		dir := newFakeDirectory()
+		dir.add("ivy", "directory pass", "ivy@corp.example", "chat-users")
+		dir.add("jack", "directory pass", "jack@corp.example", "sales")
+		dir.add("kim", "directory pass", "", "chat-users")
+		dir.add("lee", "directory pass", "lee@corp.example", "chat-users").Disabled = true
+		ldap := newLDAPAuthenticator(repos, dir, LDAPConfig{RequiredGroups: []string{"chat-users"}}, nil)
+
+		if _, err := ldap.Authenticate(ctx, "nobody", "directory pass"); !errors.Is(err, ErrUnknownAccount) {
+			t.Errorf("got %v for a user the directory does not know, want %v", err, ErrUnknownAccount)
+		}
+		if _, err := ldap.Authenticate(ctx, "ivy", "wrong"); err == nil || errors.Is(err, ErrUnknownAccount) {
+			t.Errorf("got %v for a wrong password, want a refusal", err)
+		}
+		for _, username := range []string{"jack", "kim", "lee"} {
+			if _, err := ldap.Authenticate(ctx, username, "directory pass"); err == nil {
+				t.Errorf("%s logged in", username)
+			}
+		}
+		if _, err := ldap.Authenticate(ctx, "ivy", "directory pass"); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestLDAPSync(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		dir := newFakeDirectory()
+		hub := &recordingCloser{}
+		ldap := newLDAPAuthenticator(repos, dir, LDAPConfig{RequiredGroups: []string{"chat-users"}}, hub)
+
+		users := make(map[string]*models.User)
+		for _, username := range []string{"mia", "ned", "ola", "pat"} {
+			dir.add(username, "directory pass", username+"@corp.example", "chat-users")
+			user, err := ldap.Authenticate(ctx, username, "directory pass")
+			if err != nil {
+				t.Fatal(err)
+			}
+			users[username] = user
+		}
+
+		dir.users["mia"].entry.DisplayName = "Mia M."
+		delete(dir.users, "ned")
+		dir.users["ola"].entry.Disabled = true
+		dir.users["pat"].entry.Groups = nil
+		if err := ldap.Sync(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		want := map[string]string{
+			"mia": models.UserStatusActive,
+			"ned": models.UserStatusDeactivated,
+			"ola": models.UserStatusDeactivated,
+			"pat": models.UserStatusDeactivated,
+		}
+		for username, status := range want {
+			user, err := repos.Users.GetByID(ctx, users[username].ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if user.Status != status {
+				t.Errorf("%s is %s, want %s", username, user.Status, status)
+			}
+		}
+		if mia, err := repos.Users.GetByID(ctx, users["mia"].ID); err != nil || mia.DisplayName != "Mia M." {
+			t.Errorf("got %+v, %v, want mia renamed", mia, err)
+		}
+		if len(hub.closed) != 3 {
+			t.Errorf("closed the connections of %d users, want 3", len(hub.closed))
+		}
+
+		// A directory that lists nobody is more likely misconfigured than empty
+		dir.users = make(map[string]*directoryUser)
+		if err := ldap.Sync(ctx); err == nil {
+			t.Error("synced against an empty directory")
+		}
+		if mia, err := repos.Users.GetByID(ctx, users["mia"].ID); err != nil || mia.Status != models.UserStatusActive {
+			t.Errorf("got %+v, %v, want mia still active", mia, err)
+		}
+	})
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/audit"
+	"github.com/GavinHemsada/go-backend/internal/dtos"
+	"github.com/GavinHemsada/go-backend/internal/mailer"
+	"github.com/GavinHemsada/go-backend/internal/models"
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+	"github.com/GavinHemsada/go-backend/pkg/utils"
+)
+
+// Login links sent to one account within magicLinkWindow, used or not, are
+// capped at magicLinkLimit, so the endpoint cannot be used to flood an inbox.
+const (
+	magicLinkLimit  = 5
+	magicLinkWindow = time.Hour
+)
+
+// MagicLinkService logs users in through one-time links sent to their email.
+// A link only works together with the device token returned to whoever asked
+// for it, so a link forwarded or intercepted on its way is useless elsewhere.
+type MagicLinkService struct {
+	userRepo  repository.UserRepository
+	linkRepo  repository.LoginLinkRepository
+	twoFactor *TwoFactorService
+	mailer    mailer.Mailer
+	jwtSecret string
+	appURL    string
+	ttl       time.Duration
+	auditLog  *audit.Logger
+}
+
+func NewMagicLinkService(userRepo repository.UserRepository, linkRepo repository.LoginLinkRepository, twoFactor *TwoFactorService, mail mailer.Mailer, jwtSecret, appURL string, ttl time.Duration, auditLog *audit.Logger) *MagicLinkService {
+	return &MagicLinkService{
+		userRepo:  userRepo,
+		linkRepo:  linkRepo,
+		twoFactor: twoFactor,
+		mailer:    mail,
+		jwtSecret: jwtSecret,
+		appURL:    appURL,
+		ttl:       ttl,
+		auditLog:  auditLog,
+	}
+}
+
+// Request emails a login link to the account with the given email. The
+// response is the same whether or not there is one, and the email is sent in
+// the background, so that callers cannot tell which addresses have accounts.
+func (s *MagicLinkService) Request(ctx context.Context, email string) (*dtos.MagicLinkResponse, error) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return nil, errors.New("email is required")
+	}
+
+	deviceToken, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	resp := &dtos.MagicLinkResponse{DeviceToken: deviceToken, ExpiresAt: time.Now().UTC().Add(s.ttl)}
+
+	user, err := s.userRepo.GetByIdentifier(ctx, email)
+	if err != nil || user.Email != email || user.IsBot {
+		user, err = nil, errors.New("user not found")
+	}
+	if err == nil && user.Status != models.UserStatusActive {
+		err = errors.New("account is " + user.Status)
+	}
+	if err == nil {
+		var count int
+		count, err = s.linkRepo.CountSince(ctx, user.ID, time.Now().Add(-magicLinkWindow))
+		if err == nil && count >= magicLinkLimit {
+			err = errors.New("too many login links requested, try again later")
+		}
+	}
+
+	var token string
+	if err == nil {
+		token, err = randomToken()
+	}
+	if err == nil {
+		err = s.linkRepo.Create(ctx, &models.LoginLink{
+			TokenHash:  hashAccessToken(token),
+			UserID:     user.ID,
+			DeviceHash: hashAccessToken(deviceToken),
+			ExpiresAt:  resp.ExpiresAt,
+		})
+	}
+
+	// Only the outcome is kept; unknown addresses are not recorded
+	event := models.AuditEvent{Action: models.AuditMagicLinkRequest, TargetType: models.AuditTargetUser}
+	if user != nil {
+		event.ActorID, event.TargetID = &user.ID, user.ID.String()
+	}
+	s.auditLog.Record(ctx, audit.Outcome(event, err))
+	if err != nil {
+		return resp, nil
+	}
+
+	later(ctx, func(ctx context.Context) error {
+		return s.send(ctx, user, token)
+	})
+	return resp, nil
+}
+
+// Login logs in with a link's token and the device token returned when the
+// link was asked for. Like a password login, accounts with a second factor
+// get a challenge instead of a session token. A link is spent by its first
+// use, even from the wrong device.
+func (s *MagicLinkService) Login(ctx context.Context, token, deviceToken string) (*dtos.AuthResponse, error) {
+	if token == "" || deviceToken == "" {
+		return nil, errors.New("token and device token are required")
+	}
+
+	user, err := s.login(ctx, token, deviceToken)
+
+	event := models.AuditEvent{
+		Action:     models.AuditUserLogin,
+		TargetType: models.AuditTargetUser,
+		Details:    "magic link",
+	}
+	if user != nil {
+		event.ActorID, event.TargetID = &user.ID, user.ID.String()
+	}
+	if err != nil {
+		s.auditLog.Record(ctx, audit.Outcome(event, err))
+		return nil, err
+	}
+
+	challenge, err := s.twoFactor.Challenge(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if challenge != nil {
+		event.Details = "magic link accepted, second factor required"
+	}
+	s.auditLog.Record(ctx, event)
+
+	if challenge != nil {
+		return &dtos.AuthResponse{TwoFactor: challenge}, nil
+	}
+
+	jwt, err := utils.GenerateToken(user, s.jwtSecret)
+	if err != nil {
+		return nil, err
+	}
+	return &dtos.AuthResponse{User: user, Token: jwt}, nil
+}
+
+// login spends the link and returns the user it logs in. On failure the
+// user is returned too once the link's owner is known.
+func (s *MagicLinkService) login(ctx context.Context, token, deviceToken string) (*models.User, error) {
+	link, err := s.linkRepo.Use(ctx, hashAccessToken(token))
+	if err != nil {
+		return nil, errors.New("invalid or expired link")
+	}
+
+	user, err := s.userRepo.GetByID(ctx, link.UserID)
+	if err != nil {
+		return nil, errors.New("invalid or expired link")
+	}
+	if subtle.ConstantTimeCompare([]byte(hashAccessToken(deviceToken)), []byte(link.DeviceHash)) != 1 {
+		return user, errors.New("open the link on the device that asked for it")
+	}
+	if user.Status != models.UserStatusActive {
+		return user, errors.New("account is " + user.Status)
+	}
+
+	// The link proved the user reads the account's email
+	if !user.EmailVerified {
+		if err := s.userRepo.SetEmailVerified(ctx, user.ID, true); err != nil {
+			return user, err
+		}
+		user.EmailVerified = true
+	}
+	return user, nil
+}
+
+// send emails the user a link to the frontend's /magic-link page carrying token
+func (s *MagicLinkService) send(ctx context.Context, user *models.User, token string) error {
+	msg, err := mailer.Render(mailer.TemplateMagicLink, map[string]string{
+		"Username":  user.Username,
+		"Link":      s.appURL + "/magic-link?token=" + url.QueryEscape(token),
+		"ExpiresIn": formatTTL(s.ttl),
+	})
+	if err != nil {
+		return err
+	}
+	msg.To = user.Email
+	return s.mailer.Send(ctx, msg)
+}
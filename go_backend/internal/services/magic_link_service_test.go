@@ -0,0 +1,191 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/audit"
+	"github.com/GavinHemsada/go-backend/internal/mailer"
+	"github.com/GavinHemsada/go-backend/internal/models"
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+)
+
+// logBuffer collects the log output, which the log mailer writes emails to
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// links returns the tokens of the links logged so far
+func (b *logBuffer) links() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var tokens []string
+	for _, match := range linkToken.FindAllStringSubmatch(b.buf.String(), -1) {
+		tokens = append(tokens, match[1])
+	}
+	return tokens
+}
+
+// wait returns the link tokens once there are n of them, failing the test after a while
+func (b *logBuffer) wait(t *testing.T, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		tokens := b.links()
+		if len(tokens) >= n {
+			return tokens
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d links, want %d", len(tokens), n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// newMagicLinkTest returns a magic link service with links valid for ttl,
+// mailing them to the log it returns
+func newMagicLinkTest(t *testing.T, repos *repository.Repositories, ttl time.Duration) (*MagicLinkService, *logBuffer) {
+	t.Helper()
+	logs := &logBuffer{}
+	log.SetOutput(logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	service := NewMagicLinkService(
+		repos.Users, repos.LoginLinks, newTwoFactorService(t, repos), mailer.NewLogMailer("chat@example.com"),
+		testJWTSecret, "https://chat.example.com", ttl, audit.NewLogger(repos.Audit),
+	)
+	return service, logs
+}
+
+func TestMagicLinkLogin(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		service, logs := newMagicLinkTest(t, repos, 15*time.Minute)
+		alice := registerWithPassword(t, repos, newTestHasher(t), "alice", "correct horse")
+
+		resp, err := service.Request(ctx, alice.Email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.DeviceToken == "" || time.Until(resp.ExpiresAt) <= 14*time.Minute {
+			t.Fatalf("got %+v, want a device token and the link's expiry", resp)
+		}
+		link := logs.wait(t, 1)[0]
+
+		auth, err := service.Login(ctx, link, resp.DeviceToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if auth.Token == "" || auth.User.ID != alice.ID {
+			t.Fatalf("got %+v, want a session for alice", auth)
+		}
+		if !auth.User.EmailVerified {
+			t.Error("the link did not verify the address")
+		}
+
+		if _, err := service.Login(ctx, link, resp.DeviceToken); err == nil {
+			t.Error("the link worked twice")
+		}
+	})
+}
+
+// A link opened on another device fails, and is spent all the same
+func TestMagicLinkDeviceBinding(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		service, logs := newMagicLinkTest(t, repos, 15*time.Minute)
+		alice := registerWithPassword(t, repos, newTestHasher(t), "alice", "correct horse")
+
+		resp, err := service.Request(ctx, alice.Email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		link := logs.wait(t, 1)[0]
+
+		other, err := service.Request(ctx, "nobody@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := service.Login(ctx, link, other.DeviceToken); err == nil {
+			t.Fatal("the link worked on another device")
+		}
+		if _, err := service.Login(ctx, link, resp.DeviceToken); err == nil {
+			t.Error("the link still worked after being opened elsewhere")
+		}
+
+		events := auditEvents(t, repos)
+		last := events[len(events)-1]
+		if last.Action != models.AuditUserLogin || last.Result != models.AuditFailure || last.ActorID != nil {
+			t.Errorf("got %+v, want the spent link's login audited as an anonymous failure", last)
+		}
+	})
+}
+
+func TestMagicLinkExpires(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		service, logs := newMagicLinkTest(t, repos, -time.Minute)
+		alice := registerWithPassword(t, repos, newTestHasher(t), "alice", "correct horse")
+
+		resp, err := service.Request(ctx, alice.Email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		link := logs.wait(t, 1)[0]
+
+		if _, err := service.Login(ctx, link, resp.DeviceToken); err == nil {
+			t.Error("an expired link worked")
+		}
+	})
+}
+
+// Whether an email has an account, and whether a link was sent, cannot be
+// told from the response
+func TestMagicLinkRequestDoesNotRevealAccounts(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		service, logs := newMagicLinkTest(t, repos, 15*time.Minute)
+		alice := registerWithPassword(t, repos, newTestHasher(t), "alice", "correct horse")
+		bob := registerWithPassword(t, repos, newTestHasher(t), "bob", "correct horse")
+		if err := repos.Users.SetStatus(ctx, bob.ID, models.UserStatusSuspended); err != nil {
+			t.Fatal(err)
+		}
+
+		// Alice's requests beyond the limit send nothing
+		for i := 0; i < magicLinkLimit+2; i++ {
+			if _, err := service.Request(ctx, alice.Email); err != nil {
+				t.Fatal(err)
+			}
+		}
+		logs.wait(t, magicLinkLimit)
+
+		for _, email := range []string{alice.Email, "nobody@example.com", strings.ToUpper(alice.Email), bob.Email, "alice"} {
+			resp, err := service.Request(ctx, email)
+			if err != nil {
+				t.Fatalf("%s: %v", email, err)
+			}
+			if resp.DeviceToken == "" || resp.ExpiresAt.IsZero() {
+				t.Errorf("%s: got %+v, want the usual response", email, resp)
+			}
+		}
+
+		// Give any email sent by mistake time to arrive
+		time.Sleep(50 * time.Millisecond)
+		if n := len(logs.links()); n != magicLinkLimit {
+			t.Errorf("got %d links, want %d", n, magicLinkLimit)
+		}
+	})
+}
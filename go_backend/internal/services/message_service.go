@@ -3,21 +3,46 @@ package services
 import (
 	"context"
 	"errors"
+	"strings"
+	"time"
 
+	"github.com/GavinHemsada/go-backend/internal/audit"
+	"github.com/GavinHemsada/go-backend/internal/filter"
 	"github.com/GavinHemsada/go-backend/internal/models"
 	repository "github.com/GavinHemsada/go-backend/internal/repositories"
 	"github.com/google/uuid"
 )
 
+// RoomNotifier delivers events to the live WebSocket connections of a room
+// on every server instance
+type RoomNotifier interface {
+	// NotifyRoom sends event to every connection to the room
+	NotifyRoom(roomID uuid.UUID, event models.WSMessageResponse)
+}
+
 type MessageService struct {
-	messageRepo *repository.MessageRepository
-	roomRepo    *repository.RoomRepository
+	messageRepo    repository.MessageRepository
+	roomRepo       repository.RoomRepository
+	userRepo       repository.UserRepository
+	moderationRepo repository.ModerationRepository
+	reportRepo     repository.ReportRepository
+	txManager      repository.TxManager
+	filters        *filter.Chain
+	auditLog       *audit.Logger
 }
 
-func NewMessageService(messageRepo *repository.MessageRepository, roomRepo *repository.RoomRepository) *MessageService {
+// NewMessageService creates a MessageService. Messages pass through filters
+// before they are stored; a nil chain stores them as sent.
+func NewMessageService(messageRepo repository.MessageRepository, roomRepo repository.RoomRepository, userRepo repository.UserRepository, moderationRepo repository.ModerationRepository, reportRepo repository.ReportRepository, txManager repository.TxManager, filters *filter.Chain, auditLog *audit.Logger) *MessageService {
 	return &MessageService{
-		messageRepo: messageRepo,
-		roomRepo:    roomRepo,
+		messageRepo:    messageRepo,
+		roomRepo:       roomRepo,
+		userRepo:       userRepo,
+		moderationRepo: moderationRepo,
+		reportRepo:     reportRepo,
+		txManager:      txManager,
+		filters:        filters,
+		auditLog:       auditLog,
 	}
 }
 
@@ -27,16 +52,6 @@ func (s *MessageService) CreateMessage(ctx context.Context, roomID, userID uuid.
 		return nil, errors.New("message content is required")
 	}
 
-	// Check if user is a member of the room
-	isMember, err := s.roomRepo.IsMember(ctx, roomID, userID)
-	if err != nil {
-		return nil, err
-	}
-
-	if !isMember {
-		return nil, errors.New("user is not a member of this room")
-	}
-
 	if messageType == "" {
 		messageType = "text" // Default message type
 	}
@@ -48,14 +63,294 @@ func (s *MessageService) CreateMessage(ctx context.Context, roomID, userID uuid.
 		MessageType: messageType,
 	}
 
-	err = s.messageRepo.Create(ctx, message)
+	// Membership check and insert run together, and the check holds the
+	// membership until the insert commits, so a user removed concurrently
+	// cannot slip a message in
+	var outcome *filter.Outcome
+	err := s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.checkCanPost(ctx, roomID, userID); err != nil {
+			return err
+		}
+
+		var err error
+		if message.IsBot, err = s.isBot(ctx, userID); err != nil {
+			return err
+		}
+
+		outcome, err = s.filter(ctx, message)
+		if err != nil {
+			return err
+		}
+
+		if err := s.messageRepo.Create(ctx, message); err != nil {
+			return err
+		}
+
+		return s.reportFlagged(ctx, message, outcome.Flags)
+	})
 	if err != nil {
+		outcome.Undo()
+		s.recordDenied(ctx, roomID, userID, err)
 		return nil, err
 	}
 
+	s.recordFlagged(ctx, message, outcome.Flags)
 	return message, nil
 }
 
+// NewMessage is one entry of a CreateMessages batch
+type NewMessage struct {
+	RoomID      uuid.UUID
+	UserID      uuid.UUID
+	Content     string
+	MessageType string
+}
+
+// CreateMessages validates and stores a batch of messages in one transaction with multi-row inserts.
+// It returns one message and one error per input, in input order. Inputs that fail
+// validation, the membership check or the content filter get an error and are skipped;
+// the rest are stored. Should the batch fail for another reason, its messages are
+// stored one at a time instead, so the failure only affects the messages it concerns.
+func (s *MessageService) CreateMessages(ctx context.Context, inputs []NewMessage) ([]*models.Message, []error) {
+	messages := make([]*models.Message, len(inputs))
+	errs := make([]error, len(inputs))
+	outcomes := make(map[*models.Message]*filter.Outcome)
+
+	err := s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		type poster struct{ roomID, userID uuid.UUID }
+		checked := make(map[poster]error) // checkCanPost result per room and user
+		bots := make(map[uuid.UUID]bool)  // whether each poster is a bot
+
+		var batch []*models.Message
+		for i, in := range inputs {
+			if in.Content == "" {
+				errs[i] = errors.New("message content is required")
+				continue
+			}
+
+			key := poster{in.RoomID, in.UserID}
+			postErr, ok := checked[key]
+			if !ok {
+				postErr = s.checkCanPost(ctx, in.RoomID, in.UserID)
+				var denied *postDeniedError
+				if postErr != nil && !errors.As(postErr, &denied) {
+					return postErr
+				}
+				checked[key] = postErr
+			}
+
+			if postErr != nil {
+				errs[i] = postErr
+				continue
+			}
+
+			isBot, ok := bots[in.UserID]
+			if !ok {
+				var err error
+				if isBot, err = s.isBot(ctx, in.UserID); err != nil {
+					return err
+				}
+				bots[in.UserID] = isBot
+			}
+
+			messageType := in.MessageType
+			if messageType == "" {
+				messageType = "text" // Default message type
+			}
+
+			message := &models.Message{
+				RoomID:      in.RoomID,
+				UserID:      in.UserID,
+				Content:     in.Content,
+				MessageType: messageType,
+				IsBot:       isBot,
+			}
+
+			outcome, err := s.filter(ctx, message)
+			if err != nil {
+				var denied *postDeniedError
+				if !errors.As(err, &denied) {
+					return err
+				}
+				errs[i] = err
+				continue
+			}
+
+			outcomes[message] = outcome
+			messages[i] = message
+			batch = append(batch, message)
+		}
+
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := s.messageRepo.CreateBatch(ctx, batch); err != nil {
+			return err
+		}
+
+		for _, message := range batch {
+			if err := s.reportFlagged(ctx, message, outcomes[message].Flags); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		// The whole batch was rolled back; retry each message on its own
+		for _, outcome := range outcomes {
+			outcome.Undo()
+		}
+		for i, in := range inputs {
+			messages[i], errs[i] = s.CreateMessage(ctx, in.RoomID, in.UserID, in.Content, in.MessageType)
+		}
+		return messages, errs
+	}
+
+	for i, in := range inputs {
+		if errs[i] != nil {
+			s.recordDenied(ctx, in.RoomID, in.UserID, errs[i])
+		} else {
+			s.recordFlagged(ctx, messages[i], outcomes[messages[i]].Flags)
+		}
+	}
+	return messages, errs
+}
+
+// postDeniedError is a reason a user may not post, as opposed to a lookup failure
+type postDeniedError struct {
+	reason string
+}
+
+func (e *postDeniedError) Error() string {
+	return e.reason
+}
+
+// checkCanPost checks that the user is a member of the room and is not muted there
+func (s *MessageService) checkCanPost(ctx context.Context, roomID, userID uuid.UUID) error {
+	isMember, err := s.roomRepo.IsMember(ctx, roomID, userID)
+	if err != nil {
+		return err
+	}
+
+	if !isMember {
+		return &postDeniedError{"user is not a member of this room"}
+	}
+
+	mute, err := s.moderationRepo.GetMute(ctx, roomID, userID)
+	if err != nil {
+		return err
+	}
+
+	if mute != nil {
+		return &postDeniedError{"you are muted in this room until " + mute.ExpiresAt.Format(time.RFC3339)}
+	}
+
+	return nil
+}
+
+// CanPost tells whether the user is a member of the room and is not muted
+// there, for events such as typing notices that are relayed but not stored
+func (s *MessageService) CanPost(ctx context.Context, roomID, userID uuid.UUID) (bool, error) {
+	err := s.checkCanPost(ctx, roomID, userID)
+	var denied *postDeniedError
+	if errors.As(err, &denied) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// isBot tells whether the user is a bot account, for the message's is_bot flag
+func (s *MessageService) isBot(ctx context.Context, userID uuid.UUID) (bool, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return user.IsBot, nil
+}
+
+// filter runs a message through the content filter chain, applying any
+// changes to its content. Rejections are returned as a postDeniedError.
+// Undo the outcome if the message is not stored after all.
+func (s *MessageService) filter(ctx context.Context, message *models.Message) (*filter.Outcome, error) {
+	outcome, err := s.filters.Run(ctx, filter.Message{
+		RoomID:  message.RoomID,
+		UserID:  message.UserID,
+		Content: message.Content,
+	})
+	if err != nil {
+		var rejected *filter.RejectedError
+		if errors.As(err, &rejected) {
+			return nil, &postDeniedError{rejected.Error()}
+		}
+		return nil, err
+	}
+
+	message.Content = outcome.Content
+	return outcome, nil
+}
+
+// reportFlagged files a report on a stored message the content filter flagged,
+// putting it in the room's moderation queue
+func (s *MessageService) reportFlagged(ctx context.Context, message *models.Message, flags []string) error {
+	if len(flags) == 0 || s.reportRepo == nil {
+		return nil
+	}
+
+	details := "flagged by content filter: " + strings.Join(flags, ", ")
+	report := &models.Report{
+		RoomID:         message.RoomID,
+		TargetType:     models.ReportTargetMessage,
+		MessageID:      &message.ID,
+		TargetUserID:   message.UserID,
+		MessageContent: message.Content,
+		Category:       "other",
+		Details:        details,
+	}
+	if err := s.reportRepo.Create(ctx, report); err != nil {
+		return err
+	}
+
+	return s.reportRepo.AddAction(ctx, &models.ReportAction{
+		ReportID: report.ID,
+		Action:   "created",
+		Note:     details,
+	})
+}
+
+// recordDenied audits a message the user was not allowed to post. Other
+// errors are failures to store it and are not audited.
+func (s *MessageService) recordDenied(ctx context.Context, roomID, userID uuid.UUID, err error) {
+	var denied *postDeniedError
+	if !errors.As(err, &denied) {
+		return
+	}
+
+	s.auditLog.Record(ctx, models.AuditEvent{
+		ActorID:    &userID,
+		Action:     models.AuditMessageDenied,
+		TargetType: models.AuditTargetRoom,
+		TargetID:   roomID.String(),
+		Result:     models.AuditFailure,
+		Details:    denied.Error(),
+	})
+}
+
+// recordFlagged audits a stored message the content filter flagged
+func (s *MessageService) recordFlagged(ctx context.Context, message *models.Message, flags []string) {
+	if len(flags) == 0 {
+		return
+	}
+
+	s.auditLog.Record(ctx, models.AuditEvent{
+		ActorID:    &message.UserID,
+		Action:     models.AuditMessageFlagged,
+		TargetType: models.AuditTargetMessage,
+		TargetID:   message.ID.String(),
+		Details:    strings.Join(flags, ", "),
+	})
+}
+
 // GetMessagesByRoom retrieves messages from a room with pagination
 func (s *MessageService) GetMessagesByRoom(ctx context.Context, roomID uuid.UUID, limit, offset int) ([]models.Message, error) {
 	if limit <= 0 {
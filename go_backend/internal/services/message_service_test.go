@@ -0,0 +1,207 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/audit"
+	"github.com/GavinHemsada/go-backend/internal/filter"
+	"github.com/GavinHemsada/go-backend/internal/models"
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+	"github.com/google/uuid"
+)
+
+var errAddAction = errors.New("failed to add report action")
+
+// failingReports stores reports but fails to add their first action, so a
+// flagged message fails after its message and report rows were written
+type failingReports struct {
+	repository.ReportRepository
+}
+
+func (failingReports) AddAction(ctx context.Context, action *models.ReportAction) error {
+	return errAddAction
+}
+
+// flagFilter flags messages containing "flag"
+type flagFilter struct{}
+
+func (flagFilter) Check(ctx context.Context, msg filter.Message) (filter.Result, error) {
+	if strings.Contains(msg.Content, "flag") {
+		return filter.Result{Action: filter.Allow, Flags: []string{"test"}}, nil
+	}
+	return filter.Result{Action: filter.Allow}, nil
+}
+
+// newFailingMessageService returns a MessageService whose flagged messages
+// fail partway through, and a room its user can post in
+func newFailingMessageService(t *testing.T, repos *repository.Repositories) (*MessageService, uuid.UUID, uuid.UUID) {
+	t.Helper()
+	ctx := context.Background()
+
+	user, err := repos.Users.Register(ctx, "alice", "alice@example.com", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	room := &models.Room{Name: "general", CreatedBy: user.ID}
+	if err := repos.Rooms.Create(ctx, room); err != nil {
+		t.Fatal(err)
+	}
+
+	service := NewMessageService(
+		repos.Messages, repos.Rooms, repos.Users, repos.Moderation,
+		failingReports{repos.Reports}, repos.TxManager,
+		filter.NewChain(flagFilter{}), audit.NewLogger(repos.Audit),
+	)
+	return service, room.ID, user.ID
+}
+
+// checkNothingStored fails the test if the room has any messages or reports
+func checkNothingStored(t *testing.T, repos *repository.Repositories, roomID uuid.UUID) {
+	t.Helper()
+	ctx := context.Background()
+
+	messages, err := repos.Messages.GetByRoom(ctx, roomID, 100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 0 {
+		t.Errorf("%d messages were stored, want none", len(messages))
+	}
+
+	reports, err := repos.Reports.GetByRoom(ctx, roomID, "", 100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 0 {
+		t.Errorf("%d reports were stored, want none", len(reports))
+	}
+}
+
+func TestCreateMessageRollsBack(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		service, roomID, userID := newFailingMessageService(t, repos)
+
+		_, err := service.CreateMessage(context.Background(), roomID, userID, "please flag this", "")
+		if !errors.Is(err, errAddAction) {
+			t.Fatalf("got error %v, want %v", err, errAddAction)
+		}
+		checkNothingStored(t, repos, roomID)
+	})
+}
+
+// A failure in one message of a batch leaves the others to be stored
+func TestCreateMessagesIsolatesFailure(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		service, roomID, userID := newFailingMessageService(t, repos)
+
+		messages, errs := service.CreateMessages(ctx, []NewMessage{
+			{RoomID: roomID, UserID: userID, Content: "hello"},
+			{RoomID: roomID, UserID: userID, Content: "please flag this"},
+			{RoomID: roomID, UserID: userID, Content: "goodbye"},
+		})
+		if errs[0] != nil || errs[2] != nil || messages[0] == nil || messages[2] == nil {
+			t.Fatalf("got errors %v, want the messages around the failing one stored", errs)
+		}
+		if messages[1] != nil || !errors.Is(errs[1], errAddAction) {
+			t.Errorf("got %+v, %v for the flagged message, want error %v", messages[1], errs[1], errAddAction)
+		}
+
+		stored, err := repos.Messages.GetByRoom(ctx, roomID, 100, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(stored) != 2 || stored[0].Content != "goodbye" || stored[1].Content != "hello" {
+			t.Fatalf("got messages %+v, want goodbye then hello", stored)
+		}
+		reports, err := repos.Reports.GetByRoom(ctx, roomID, "", 100, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(reports) != 0 {
+			t.Errorf("%d reports were stored, want none", len(reports))
+		}
+	})
+}
+
+// gateFilter rejects every message while closed
+type gateFilter struct {
+	closed bool
+}
+
+func (f *gateFilter) Check(ctx context.Context, msg filter.Message) (filter.Result, error) {
+	if f.closed {
+		return filter.Result{Action: filter.Reject, Reason: "closed"}, nil
+	}
+	return filter.Result{Action: filter.Allow}, nil
+}
+
+// Messages that are not stored do not count towards the flood limit
+func TestFloodLimitCountsStoredMessages(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		_, roomID, userID := newFailingMessageService(t, repos)
+		flood := filter.NewFloodFilter(1, time.Hour)
+		gate := &gateFilter{closed: true}
+		newService := func(reports repository.ReportRepository) *MessageService {
+			return NewMessageService(
+				repos.Messages, repos.Rooms, repos.Users, repos.Moderation, reports, repos.TxManager,
+				filter.NewChain(flood, flagFilter{}, gate), audit.NewLogger(repos.Audit),
+			)
+		}
+		service, failing := newService(repos.Reports), newService(failingReports{repos.Reports})
+
+		// Rejected by a filter after the flood limit
+		if _, err := service.CreateMessage(ctx, roomID, userID, "hello", ""); err == nil {
+			t.Fatal("the closed gate let a message through")
+		}
+		gate.closed = false
+
+		// Rolled back after the filters
+		if _, err := failing.CreateMessage(ctx, roomID, userID, "please flag this", ""); !errors.Is(err, errAddAction) {
+			t.Fatalf("got error %v, want %v", err, errAddAction)
+		}
+		if _, errs := failing.CreateMessages(ctx, []NewMessage{{RoomID: roomID, UserID: userID, Content: "please flag this"}}); !errors.Is(errs[0], errAddAction) {
+			t.Fatalf("got error %v, want %v", errs[0], errAddAction)
+		}
+
+		for _, content := range []string{"hello", "please flag this"} {
+			if _, err := service.CreateMessage(ctx, roomID, userID, content, ""); err != nil {
+				t.Fatalf("%s: %v", content, err)
+			}
+			if _, err := service.CreateMessage(ctx, roomID, userID, content, ""); err == nil {
+				t.Errorf("%s: a repeat got past the flood limit", content)
+			}
+		}
+	})
+}
+
+func TestCreateMessagesStoresBatch(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		service, roomID, userID := newFailingMessageService(t, repos)
+
+		_, errs := service.CreateMessages(context.Background(), []NewMessage{
+			{RoomID: roomID, UserID: userID, Content: "hello"},
+			{RoomID: roomID, UserID: userID, Content: ""},
+			{RoomID: roomID, UserID: userID, Content: "goodbye"},
+		})
+		if errs[0] != nil || errs[2] != nil {
+			t.Fatalf("valid messages failed: %v, %v", errs[0], errs[2])
+		}
+		if errs[1] == nil {
+			t.Error("empty message was accepted")
+		}
+
+		messages, err := repos.Messages.GetByRoom(context.Background(), roomID, 100, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(messages) != 2 || messages[0].Content != "goodbye" || messages[1].Content != "hello" {
+			t.Fatalf("got messages %+v, want goodbye then hello", messages)
+		}
+	})
+}
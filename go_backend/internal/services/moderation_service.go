@@ -0,0 +1,255 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/audit"
+	"github.com/GavinHemsada/go-backend/internal/models"
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+	"github.com/google/uuid"
+)
+
+// ModerationEnforcer applies moderation actions to live WebSocket connections
+// on every server instance
+type ModerationEnforcer interface {
+	// DisconnectUser closes the user's connections to a room
+	DisconnectUser(roomID, userID uuid.UUID, reason string)
+	// NotifyUser sends an event to the user's connections to a room
+	NotifyUser(roomID, userID uuid.UUID, event models.WSMessageResponse)
+}
+
+type ModerationService struct {
+	roomRepo       repository.RoomRepository
+	moderationRepo repository.ModerationRepository
+	userRepo       repository.UserRepository
+	txManager      repository.TxManager
+	enforcer       ModerationEnforcer
+	auditLog       *audit.Logger
+}
+
+func NewModerationService(roomRepo repository.RoomRepository, moderationRepo repository.ModerationRepository, userRepo repository.UserRepository, txManager repository.TxManager, enforcer ModerationEnforcer, auditLog *audit.Logger) *ModerationService {
+	return &ModerationService{
+		roomRepo:       roomRepo,
+		moderationRepo: moderationRepo,
+		userRepo:       userRepo,
+		txManager:      txManager,
+		enforcer:       enforcer,
+		auditLog:       auditLog,
+	}
+}
+
+// Kick removes a user from a room and closes their connections to it. The user may rejoin.
+func (s *ModerationService) Kick(ctx context.Context, roomID, actorID, targetID uuid.UUID) error {
+	err := s.act(ctx, models.AuditModerationKick, roomID, actorID, targetID, moderationDetails(roomID, "", ""), func(ctx context.Context) error {
+		return s.roomRepo.RemoveMember(ctx, roomID, targetID)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.disconnect(ctx, roomID, targetID, "kicked from room")
+	return nil
+}
+
+// Ban removes a user from a room and keeps them from rejoining for duration,
+// or permanently when duration is 0
+func (s *ModerationService) Ban(ctx context.Context, roomID, actorID, targetID uuid.UUID, duration time.Duration, reason string) (*models.RoomBan, error) {
+	if duration < 0 {
+		return nil, errors.New("ban duration cannot be negative")
+	}
+
+	ban := &models.RoomBan{
+		RoomID:   roomID,
+		UserID:   targetID,
+		BannedBy: actorID,
+		Reason:   reason,
+	}
+
+	length := "permanent"
+	if duration > 0 {
+		length = duration.String()
+	}
+	err := s.act(ctx, models.AuditModerationBan, roomID, actorID, targetID, moderationDetails(roomID, length, reason), func(ctx context.Context) error {
+		if err := s.moderationRepo.Ban(ctx, ban, duration); err != nil {
+			return err
+		}
+
+		// Users who are not members can still be banned pre-emptively
+		isMember, err := s.roomRepo.IsMember(ctx, roomID, targetID)
+		if err != nil || !isMember {
+			return err
+		}
+		return s.roomRepo.RemoveMember(ctx, roomID, targetID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.disconnect(ctx, roomID, targetID, "banned from room")
+	return ban, nil
+}
+
+// Unban lifts a user's ban so they can join the room again
+func (s *ModerationService) Unban(ctx context.Context, roomID, actorID, targetID uuid.UUID) error {
+	return s.act(ctx, models.AuditModerationUnban, roomID, actorID, targetID, moderationDetails(roomID, "", ""), func(ctx context.Context) error {
+		return s.moderationRepo.Unban(ctx, roomID, targetID)
+	})
+}
+
+// Mute stops a user from posting in a room for duration
+func (s *ModerationService) Mute(ctx context.Context, roomID, actorID, targetID uuid.UUID, duration time.Duration, reason string) (*models.RoomMute, error) {
+	if duration <= 0 {
+		return nil, errors.New("mute duration must be positive")
+	}
+
+	mute := &models.RoomMute{
+		RoomID:  roomID,
+		UserID:  targetID,
+		MutedBy: actorID,
+		Reason:  reason,
+	}
+
+	err := s.act(ctx, models.AuditModerationMute, roomID, actorID, targetID, moderationDetails(roomID, duration.String(), reason), func(ctx context.Context) error {
+		return s.moderationRepo.Mute(ctx, mute, duration)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := mute.ExpiresAt
+	s.notify(ctx, roomID, targetID, models.WSMessageResponse{
+		Type:      "muted",
+		RoomID:    roomID.String(),
+		UserID:    targetID.String(),
+		Reason:    reason,
+		ExpiresAt: &expiresAt,
+	})
+	return mute, nil
+}
+
+// Unmute lets a muted user post again
+func (s *ModerationService) Unmute(ctx context.Context, roomID, actorID, targetID uuid.UUID) error {
+	err := s.act(ctx, models.AuditModerationUnmute, roomID, actorID, targetID, moderationDetails(roomID, "", ""), func(ctx context.Context) error {
+		return s.moderationRepo.Unmute(ctx, roomID, targetID)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.notify(ctx, roomID, targetID, models.WSMessageResponse{
+		Type:   "unmuted",
+		RoomID: roomID.String(),
+		UserID: targetID.String(),
+	})
+	return nil
+}
+
+// GetBans lists the active bans of a room
+func (s *ModerationService) GetBans(ctx context.Context, roomID, actorID uuid.UUID) ([]models.RoomBan, error) {
+	if err := s.requireModerator(ctx, roomID, actorID); err != nil {
+		return nil, err
+	}
+	return s.moderationRepo.GetBans(ctx, roomID)
+}
+
+// GetMutes lists the active mutes of a room
+func (s *ModerationService) GetMutes(ctx context.Context, roomID, actorID uuid.UUID) ([]models.RoomMute, error) {
+	if err := s.requireModerator(ctx, roomID, actorID); err != nil {
+		return nil, err
+	}
+	return s.moderationRepo.GetMutes(ctx, roomID)
+}
+
+// act checks that actorID may moderate targetID in the room, then runs
+// action and audits it in one transaction. Refused or failed attempts are
+// audited as failures.
+func (s *ModerationService) act(ctx context.Context, auditAction string, roomID, actorID, targetID uuid.UUID, details string, action func(ctx context.Context) error) error {
+	event := models.AuditEvent{
+		ActorID:    &actorID,
+		Action:     auditAction,
+		TargetType: models.AuditTargetUser,
+		TargetID:   targetID.String(),
+		Details:    details,
+	}
+
+	err := s.authorize(ctx, roomID, actorID, targetID)
+	if err == nil {
+		err = s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+			if err := action(ctx); err != nil {
+				return err
+			}
+			return s.auditLog.Write(ctx, event)
+		})
+	}
+	if err != nil {
+		s.auditLog.Record(ctx, audit.Outcome(event, err))
+	}
+	return err
+}
+
+// moderationDetails describes the room, duration and reason of a moderation
+// action for its audit event, leaving out what does not apply
+func moderationDetails(roomID uuid.UUID, duration, reason string) string {
+	details := []string{"room=" + roomID.String()}
+	if duration != "" {
+		details = append(details, "duration="+duration)
+	}
+	if reason != "" {
+		details = append(details, "reason="+strconv.Quote(reason))
+	}
+	return strings.Join(details, " ")
+}
+
+// authorize checks that actorID may moderate targetID in the room
+func (s *ModerationService) authorize(ctx context.Context, roomID, actorID, targetID uuid.UUID) error {
+	if actorID == targetID {
+		return errors.New("you cannot moderate yourself")
+	}
+	return s.requireModerator(ctx, roomID, actorID)
+}
+
+// requireModerator checks that userID may moderate the room (the room creator or a global admin)
+func (s *ModerationService) requireModerator(ctx context.Context, roomID, userID uuid.UUID) error {
+	room, err := s.roomRepo.GetByID(ctx, roomID)
+	if err != nil {
+		return err
+	}
+
+	if room.CreatedBy == userID {
+		return nil
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.Role != models.RoleAdmin {
+		return errors.New("only room creator or an admin can moderate the room")
+	}
+	return nil
+}
+
+// disconnect closes the user's connections to the room. When ctx carries a
+// transaction, as when a report is resolved, it waits for the commit so
+// nothing is enforced that is then rolled back.
+func (s *ModerationService) disconnect(ctx context.Context, roomID, userID uuid.UUID, reason string) {
+	if s.enforcer != nil {
+		repository.AfterCommit(ctx, func() {
+			s.enforcer.DisconnectUser(roomID, userID, reason)
+		})
+	}
+}
+
+// notify sends an event to the user's connections to the room, after the
+// commit of any transaction ctx carries
+func (s *ModerationService) notify(ctx context.Context, roomID, userID uuid.UUID, event models.WSMessageResponse) {
+	if s.enforcer != nil {
+		repository.AfterCommit(ctx, func() {
+			s.enforcer.NotifyUser(roomID, userID, event)
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/audit"
+	"github.com/GavinHemsada/go-backend/internal/models"
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+)
+
+var errAuditDown = errors.New("audit log unavailable")
+
+// failingAudit refuses to append successful events, so only failures reach the log
+type failingAudit struct {
+	repository.AuditRepository
+}
+
+func (f failingAudit) Append(ctx context.Context, event *models.AuditEvent) error {
+	if event.Result == models.AuditSuccess {
+		return errAuditDown
+	}
+	return f.AuditRepository.Append(ctx, event)
+}
+
+// newModerationTest returns a moderation service auditing to auditRepo, a
+// room owned by the moderator it returns, and a member of that room
+func newModerationTest(t *testing.T, repos *repository.Repositories, auditRepo repository.AuditRepository) (*ModerationService, *models.Room, *models.User, *models.User) {
+	t.Helper()
+	ctx := context.Background()
+
+	moderator, err := repos.Users.Register(ctx, "mod", "mod@example.com", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	member, err := repos.Users.Register(ctx, "member", "member@example.com", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	room := &models.Room{Name: "general", CreatedBy: moderator.ID}
+	if err := repos.Rooms.Create(ctx, room); err != nil {
+		t.Fatal(err)
+	}
+	if err := repos.Rooms.AddMember(ctx, room.ID, member.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	service := NewModerationService(repos.Rooms, repos.Moderation, repos.Users, repos.TxManager, nil, audit.NewLogger(auditRepo))
+	return service, room, moderator, member
+}
+
+// auditEvents returns the audit log, oldest first
+func auditEvents(t *testing.T, repos *repository.Repositories) []models.AuditEvent {
+	t.Helper()
+	events, err := repos.Audit.Range(context.Background(), 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+func TestModerationActionsAreAudited(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		service, room, moderator, member := newModerationTest(t, repos, repos.Audit)
+		roomDetails := "room=" + room.ID.String()
+
+		steps := []struct {
+			run     func() error
+			action  string
+			details string
+		}{
+			{func() error {
+				_, err := service.Mute(ctx, room.ID, moderator.ID, member.ID, time.Hour, "spam")
+				return err
+			}, models.AuditModerationMute, roomDetails + ` duration=1h0m0s reason="spam"`},
+			{func() error {
+				return service.Unmute(ctx, room.ID, moderator.ID, member.ID)
+			}, models.AuditModerationUnmute, roomDetails},
+			{func() error {
+				return service.Kick(ctx, room.ID, moderator.ID, member.ID)
+			}, models.AuditModerationKick, roomDetails},
+			{func() error {
+				_, err := service.Ban(ctx, room.ID, moderator.ID, member.ID, 0, "")
+				return err
+			}, models.AuditModerationBan, roomDetails + " duration=permanent"},
+			{func() error {
+				return service.Unban(ctx, room.ID, moderator.ID, member.ID)
+			}, models.AuditModerationUnban, roomDetails},
+		}
+		for _, step := range steps {
+			if err := step.run(); err != nil {
+				t.Fatalf("%s: %v", step.action, err)
+			}
+		}
+
+		events := auditEvents(t, repos)
+		if len(events) != len(steps) {
+			t.Fatalf("got %d audit events, want %d", len(events), len(steps))
+		}
+		for i, step := range steps {
+			e := events[i]
+			if e.Action != step.action || e.Result != models.AuditSuccess || e.ActorID == nil || *e.ActorID != moderator.ID ||
+				e.TargetType != models.AuditTargetUser || e.TargetID != member.ID.String() || e.Details != step.details {
+				t.Errorf("event %d = %+v, want a successful %s of the member by the moderator with details %q", i, e, step.action, step.details)
+			}
+		}
+	})
+}
+
+func TestModerationFailuresAreAudited(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		service, room, moderator, member := newModerationTest(t, repos, repos.Audit)
+
+		// The member is not banned, and may not moderate the moderator
+		if err := service.Unban(ctx, room.ID, moderator.ID, member.ID); err == nil {
+			t.Fatal("unbanned a user who is not banned")
+		}
+		if err := service.Kick(ctx, room.ID, member.ID, moderator.ID); err == nil {
+			t.Fatal("a member kicked the room creator")
+		}
+
+		events := auditEvents(t, repos)
+		if len(events) != 2 {
+			t.Fatalf("got %d audit events, want 2", len(events))
+		}
+		for _, e := range events {
+			if e.Result != models.AuditFailure || e.Details == "" {
+				t.Errorf("event %+v, want a failure with the error in its details", e)
+			}
+		}
+		if events[1].Action != models.AuditModerationKick || *events[1].ActorID != member.ID {
+			t.Errorf("got %+v, want the member's kick attempt", events[1])
+		}
+	})
+}
+
+// The action and its audit event commit together, so an action that cannot
+// be audited does not happen
+func TestModerationRollsBackWithoutAudit(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		service, room, moderator, member := newModerationTest(t, repos, failingAudit{repos.Audit})
+
+		if _, err := service.Ban(ctx, room.ID, moderator.ID, member.ID, time.Hour, "spam"); !errors.Is(err, errAuditDown) {
+			t.Fatalf("got error %v, want %v", err, errAuditDown)
+		}
+
+		ban, err := repos.Moderation.GetBan(ctx, room.ID, member.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ban != nil {
+			t.Error("the ban was stored without its audit event")
+		}
+		if isMember, err := repos.Rooms.IsMember(ctx, room.ID, member.ID); err != nil || !isMember {
+			t.Errorf("the member was removed without an audit event (err %v)", err)
+		}
+		if events := auditEvents(t, repos); len(events) != 1 || events[0].Result != models.AuditFailure {
+			t.Errorf("got events %+v, want only the failed ban", events)
+		}
+	})
+}
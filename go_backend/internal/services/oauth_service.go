@@ -0,0 +1,652 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/audit"
+	"github.com/GavinHemsada/go-backend/internal/dtos"
+	"github.com/GavinHemsada/go-backend/internal/models"
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+	"github.com/GavinHemsada/go-backend/pkg/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	// How long an authorization code waits to be exchanged.
+	oauthCodeLifetime = 10 * time.Minute
+
+	// How long access and refresh tokens work. Every refresh issues new ones.
+	oauthAccessTokenLifetime  = time.Hour
+	oauthRefreshTokenLifetime = 30 * 24 * time.Hour
+
+	// Most apps a user may register, and redirect URIs per app.
+	maxOAuthAppsPerUser = 20
+	maxRedirectURIs     = 10
+
+	// Longest app name.
+	maxOAuthAppNameLength = 100
+
+	// Prefixes of refresh tokens and client secrets.
+	oauthRefreshTokenPrefix = "chat_ort_"
+	oauthClientSecretPrefix = "chat_ocs_"
+)
+
+// OAuth2 error codes (RFC 6749 section 5.2)
+const (
+	OAuthInvalidRequest   = "invalid_request"
+	OAuthInvalidClient    = "invalid_client"
+	OAuthInvalidGrant     = "invalid_grant"
+	OAuthInvalidScope     = "invalid_scope"
+	OAuthUnsupportedGrant = "unsupported_grant_type"
+	OAuthAccessDenied     = "access_denied"
+)
+
+const (
+	oauthResponseTypeCode  = "code"
+	oauthTokenTypeBearer   = "Bearer"
+	oauthCodeChallengeS256 = "S256"
+
+	// Length of an S256 code challenge: a base64url SHA-256 without padding.
+	oauthCodeChallengeLength = 43
+)
+
+// OAuthError is an error the token, introspection and revocation endpoints
+// report with an RFC 6749 error code
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+func (e *OAuthError) Error() string {
+	return e.Description
+}
+
+// OAuthService is the OAuth2 authorization server. Users register apps;
+// apps send users to the consent screen with an authorization code request
+// using PKCE, then exchange the code for an access token and a refresh
+// token. Access tokens carry the scopes the user granted and are checked by
+// the same middleware as personal access tokens.
+type OAuthService struct {
+	userRepo  repository.UserRepository
+	oauthRepo repository.OAuthRepository
+	txManager repository.TxManager
+	auditLog  *audit.Logger
+}
+
+func NewOAuthService(userRepo repository.UserRepository, oauthRepo repository.OAuthRepository, txManager repository.TxManager, auditLog *audit.Logger) *OAuthService {
+	return &OAuthService{
+		userRepo:  userRepo,
+		oauthRepo: oauthRepo,
+		txManager: txManager,
+		auditLog:  auditLog,
+	}
+}
+
+// CreateApp registers an app owned by ownerID. The client secret of a
+// confidential app is only returned here.
+func (s *OAuthService) CreateApp(ctx context.Context, ownerID uuid.UUID, name string, redirectURIs []string, confidential bool) (*dtos.OAuthAppResponse, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+	if len([]rune(name)) > maxOAuthAppNameLength {
+		return nil, errors.New("name must be at most 100 characters")
+	}
+
+	var uris models.URIs
+	for _, uri := range redirectURIs {
+		if err := checkRedirectURI(uri); err != nil {
+			return nil, err
+		}
+		if !uris.Has(uri) {
+			uris = append(uris, uri)
+		}
+	}
+	if len(uris) == 0 {
+		return nil, errors.New("at least one redirect URI is required")
+	}
+	if len(uris) > maxRedirectURIs {
+		return nil, errors.New("at most 10 redirect URIs are allowed")
+	}
+
+	owner, err := s.userRepo.GetByID(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if owner.IsBot {
+		return nil, errors.New("bots cannot register apps")
+	}
+
+	apps, err := s.oauthRepo.GetAppsByOwner(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if len(apps) >= maxOAuthAppsPerUser {
+		return nil, errors.New("too many apps, delete one first")
+	}
+
+	app := &models.OAuthApp{
+		OwnerID:      ownerID,
+		Name:         name,
+		RedirectURIs: uris,
+	}
+	var secret string
+	if confidential {
+		random, err := randomToken()
+		if err != nil {
+			return nil, err
+		}
+		secret = oauthClientSecretPrefix + random
+		hash := hashAccessToken(secret)
+		app.SecretHash = &hash
+	}
+
+	err = s.oauthRepo.CreateApp(ctx, app)
+	event := models.AuditEvent{
+		ActorID:    &ownerID,
+		Action:     models.AuditOAuthAppCreate,
+		TargetType: models.AuditTargetApp,
+		TargetID:   name,
+	}
+	if err == nil {
+		event.TargetID = app.ID.String()
+	}
+	s.auditLog.Record(ctx, audit.Outcome(event, err))
+	if err != nil {
+		return nil, err
+	}
+
+	return &dtos.OAuthAppResponse{OAuthApp: app, Confidential: confidential, ClientSecret: secret}, nil
+}
+
+// GetApps lists the apps ownerID registered
+func (s *OAuthService) GetApps(ctx context.Context, ownerID uuid.UUID) ([]dtos.OAuthAppResponse, error) {
+	apps, err := s.oauthRepo.GetAppsByOwner(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]dtos.OAuthAppResponse, len(apps))
+	for i := range apps {
+		responses[i] = dtos.OAuthAppResponse{OAuthApp: &apps[i], Confidential: apps[i].Confidential()}
+	}
+	return responses, nil
+}
+
+// DeleteApp deletes one of the owner's apps. Its tokens stop working right away.
+func (s *OAuthService) DeleteApp(ctx context.Context, ownerID, appID uuid.UUID) error {
+	err := s.oauthRepo.DeleteApp(ctx, ownerID, appID)
+	s.auditLog.Record(ctx, audit.Outcome(models.AuditEvent{
+		ActorID:    &ownerID,
+		Action:     models.AuditOAuthAppDelete,
+		TargetType: models.AuditTargetApp,
+		TargetID:   appID.String(),
+	}, err))
+	return err
+}
+
+// GetAuthorization checks an authorization request and describes it for the
+// consent screen
+func (s *OAuthService) GetAuthorization(ctx context.Context, userID uuid.UUID, req dtos.OAuthAuthorizeDto) (*dtos.OAuthConsentResponse, error) {
+	app, scopes, err := s.checkAuthorization(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	consent, err := s.oauthRepo.GetConsent(ctx, userID, app.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dtos.OAuthConsentResponse{
+		ClientID:    app.ID,
+		Name:        app.Name,
+		RedirectURI: req.RedirectURI,
+		Scopes:      scopes,
+		Granted:     consent != nil && consent.Scopes.Has(scopes...),
+	}, nil
+}
+
+// Authorize records the user's decision on an authorization request and
+// returns the redirect back to the app: with a code if the user approved,
+// with an access_denied error otherwise.
+func (s *OAuthService) Authorize(ctx context.Context, userID uuid.UUID, req dtos.OAuthAuthorizeDto) (*dtos.OAuthRedirectResponse, error) {
+	app, scopes, err := s.checkAuthorization(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	event := models.AuditEvent{
+		ActorID:    &userID,
+		Action:     models.AuditOAuthConsent,
+		TargetType: models.AuditTargetApp,
+		TargetID:   app.ID.String(),
+		Details:    strings.Join(scopes, ","),
+	}
+
+	if !req.Approve {
+		s.auditLog.Record(ctx, audit.Outcome(event, errors.New("denied")))
+		return &dtos.OAuthRedirectResponse{RedirectTo: redirectWith(req.RedirectURI, url.Values{
+			"error": {OAuthAccessDenied},
+			"state": {req.State},
+		})}, nil
+	}
+
+	random, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	err = s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		// Consent grows with each approval, so the app keeps what it had
+		granted := scopes
+		consent, err := s.oauthRepo.GetConsent(ctx, userID, app.ID)
+		if err != nil {
+			return err
+		}
+		if consent != nil {
+			granted = consent.Scopes
+			for _, scope := range scopes {
+				if !granted.Has(scope) {
+					granted = append(granted, scope)
+				}
+			}
+		}
+		if err := s.oauthRepo.SaveConsent(ctx, &models.OAuthConsent{UserID: userID, AppID: app.ID, Scopes: granted}); err != nil {
+			return err
+		}
+
+		return s.oauthRepo.CreateCode(ctx, &models.OAuthCode{
+			CodeHash:      hashAccessToken(random),
+			AppID:         app.ID,
+			UserID:        userID,
+			RedirectURI:   req.RedirectURI,
+			Scopes:        scopes,
+			CodeChallenge: req.CodeChallenge,
+			ExpiresAt:     time.Now().Add(oauthCodeLifetime),
+		})
+	})
+	s.auditLog.Record(ctx, audit.Outcome(event, err))
+	if err != nil {
+		return nil, err
+	}
+
+	return &dtos.OAuthRedirectResponse{RedirectTo: redirectWith(req.RedirectURI, url.Values{
+		"code":  {random},
+		"state": {req.State},
+	})}, nil
+}
+
+// checkAuthorization validates an authorization request and returns its
+// app and requested scopes
+func (s *OAuthService) checkAuthorization(ctx context.Context, req dtos.OAuthAuthorizeDto) (*models.OAuthApp, models.Scopes, error) {
+	clientID, err := uuid.Parse(req.ClientID)
+	if err != nil {
+		return nil, nil, errors.New("unknown client")
+	}
+	app, err := s.oauthRepo.GetApp(ctx, clientID)
+	if err != nil {
+		return nil, nil, errors.New("unknown client")
+	}
+	if !app.RedirectURIs.Has(req.RedirectURI) {
+		return nil, nil, errors.New("redirect_uri is not registered for this app")
+	}
+
+	if req.ResponseType != oauthResponseTypeCode {
+		return nil, nil, errors.New("response_type must be code")
+	}
+	if req.CodeChallengeMethod != oauthCodeChallengeS256 {
+		return nil, nil, errors.New("code_challenge_method must be S256")
+	}
+	if len(req.CodeChallenge) != oauthCodeChallengeLength {
+		return nil, nil, errors.New("code_challenge must be a base64url SHA-256 hash")
+	}
+
+	scopes, err := parseScopes(req.Scope)
+	if err != nil {
+		return nil, nil, err
+	}
+	return app, scopes, nil
+}
+
+// ExchangeCode is the authorization_code grant: it trades a code for tokens
+// once the client and the PKCE code verifier check out
+func (s *OAuthService) ExchangeCode(ctx context.Context, clientID, clientSecret, code, redirectURI, codeVerifier string) (*dtos.OAuthTokenResponse, error) {
+	app, err := s.authenticateClient(ctx, clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+	if code == "" || codeVerifier == "" {
+		return nil, &OAuthError{Code: OAuthInvalidRequest, Description: "code and code_verifier are required"}
+	}
+
+	grant, err := s.oauthRepo.TakeCode(ctx, hashAccessToken(code))
+	if err != nil || grant.AppID != app.ID {
+		return nil, &OAuthError{Code: OAuthInvalidGrant, Description: "invalid or expired authorization code"}
+	}
+	if grant.RedirectURI != redirectURI {
+		return nil, &OAuthError{Code: OAuthInvalidGrant, Description: "redirect_uri does not match the authorization request"}
+	}
+	if !pkceMatches(codeVerifier, grant.CodeChallenge) {
+		return nil, &OAuthError{Code: OAuthInvalidGrant, Description: "code_verifier does not match the code challenge"}
+	}
+
+	if err := s.checkUser(ctx, grant.UserID); err != nil {
+		return nil, err
+	}
+	return s.issue(ctx, app.ID, grant.UserID, grant.Scopes)
+}
+
+// Refresh is the refresh_token grant: it replaces a token with a new access
+// and refresh token, optionally with fewer scopes. The old refresh token
+// stops working.
+func (s *OAuthService) Refresh(ctx context.Context, clientID, clientSecret, refreshToken, scope string) (*dtos.OAuthTokenResponse, error) {
+	app, err := s.authenticateClient(ctx, clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+	if refreshToken == "" {
+		return nil, &OAuthError{Code: OAuthInvalidRequest, Description: "refresh_token is required"}
+	}
+
+	invalid := &OAuthError{Code: OAuthInvalidGrant, Description: "invalid or expired refresh token"}
+	token, err := s.oauthRepo.GetTokenByRefreshHash(ctx, hashAccessToken(refreshToken))
+	if err != nil || token.AppID != app.ID || !token.RefreshExpiresAt.After(time.Now()) {
+		return nil, invalid
+	}
+
+	scopes := token.Scopes
+	if scope != "" {
+		if scopes, err = parseScopes(scope); err != nil {
+			return nil, &OAuthError{Code: OAuthInvalidScope, Description: err.Error()}
+		}
+		if !token.Scopes.Has(scopes...) {
+			return nil, &OAuthError{Code: OAuthInvalidScope, Description: "scope exceeds the scopes originally granted"}
+		}
+	}
+
+	if err := s.checkUser(ctx, token.UserID); err != nil {
+		return nil, err
+	}
+
+	var response *dtos.OAuthTokenResponse
+	err = s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		// Deleting first means two requests racing with one refresh token cannot both win
+		if err := s.oauthRepo.DeleteToken(ctx, token.ID); err != nil {
+			return invalid
+		}
+		var err error
+		response, err = s.issue(ctx, app.ID, token.UserID, scopes)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// Introspect describes a token issued to the calling app (RFC 7662). Tokens
+// that are unknown, expired, or issued to other apps are reported inactive.
+func (s *OAuthService) Introspect(ctx context.Context, clientID, clientSecret, tokenString string) (*dtos.OAuthIntrospectionResponse, error) {
+	app, err := s.authenticateClient(ctx, clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	inactive := &dtos.OAuthIntrospectionResponse{}
+	token, isAccess := s.findToken(ctx, tokenString)
+	if token == nil || token.AppID != app.ID {
+		return inactive, nil
+	}
+
+	expiresAt, tokenType := token.RefreshExpiresAt, ""
+	if isAccess {
+		expiresAt, tokenType = token.AccessExpiresAt, oauthTokenTypeBearer
+	}
+	if !expiresAt.After(time.Now()) {
+		return inactive, nil
+	}
+
+	user, err := s.userRepo.GetByID(ctx, token.UserID)
+	if err != nil || user.Status != models.UserStatusActive {
+		return inactive, nil
+	}
+
+	return &dtos.OAuthIntrospectionResponse{
+		Active:    true,
+		Scope:     strings.Join(token.Scopes, " "),
+		ClientID:  app.ID.String(),
+		Username:  user.Username,
+		TokenType: tokenType,
+		Exp:       expiresAt.Unix(),
+		Iat:       token.CreatedAt.Unix(),
+		Sub:       user.ID.String(),
+	}, nil
+}
+
+// Revoke revokes a token issued to the calling app, with the access or
+// refresh token paired with it (RFC 7009). Unknown tokens are ignored.
+func (s *OAuthService) Revoke(ctx context.Context, clientID, clientSecret, tokenString string) error {
+	app, err := s.authenticateClient(ctx, clientID, clientSecret)
+	if err != nil {
+		return err
+	}
+
+	token, _ := s.findToken(ctx, tokenString)
+	if token == nil || token.AppID != app.ID {
+		return nil
+	}
+	if err := s.oauthRepo.DeleteToken(ctx, token.ID); err != nil {
+		// Revoked by a concurrent request in the meantime
+		if _, lookupErr := s.oauthRepo.GetTokenByAccessHash(ctx, token.AccessHash); lookupErr != nil {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// Authenticate returns the claims of a request made with an access token.
+// Unknown, revoked and expired tokens fail.
+func (s *OAuthService) Authenticate(ctx context.Context, tokenString string) (*utils.Claims, error) {
+	token, err := s.oauthRepo.GetTokenByAccessHash(ctx, hashAccessToken(tokenString))
+	if err != nil {
+		return nil, errors.New("invalid token")
+	}
+	if !token.AccessExpiresAt.After(time.Now()) {
+		return nil, errors.New("token has expired")
+	}
+
+	user, err := s.userRepo.GetByID(ctx, token.UserID)
+	if err != nil {
+		return nil, errors.New("invalid token")
+	}
+
+	return &utils.Claims{
+		UserID:   user.ID,
+		Username: user.Username,
+		Email:    user.Email,
+		TokenID:  &token.ID,
+		Scopes:   token.Scopes,
+	}, nil
+}
+
+// GetAuthorizations lists the apps the user authorized
+func (s *OAuthService) GetAuthorizations(ctx context.Context, userID uuid.UUID) ([]models.OAuthConsent, error) {
+	return s.oauthRepo.GetConsents(ctx, userID)
+}
+
+// RevokeAuthorization withdraws the user's consent to an app and revokes
+// the app's tokens and unused authorization codes for the user
+func (s *OAuthService) RevokeAuthorization(ctx context.Context, userID, appID uuid.UUID) error {
+	err := s.oauthRepo.DeleteConsent(ctx, userID, appID)
+	s.auditLog.Record(ctx, audit.Outcome(models.AuditEvent{
+		ActorID:    &userID,
+		Action:     models.AuditOAuthConsentRevoke,
+		TargetType: models.AuditTargetApp,
+		TargetID:   appID.String(),
+	}, err))
+	return err
+}
+
+// authenticateClient returns the app with the given client ID. Confidential
+// apps must send their secret; public apps must not send one.
+func (s *OAuthService) authenticateClient(ctx context.Context, clientID, clientSecret string) (*models.OAuthApp, error) {
+	invalid := &OAuthError{Code: OAuthInvalidClient, Description: "invalid client credentials"}
+
+	id, err := uuid.Parse(clientID)
+	if err != nil {
+		return nil, invalid
+	}
+	app, err := s.oauthRepo.GetApp(ctx, id)
+	if err != nil {
+		return nil, invalid
+	}
+
+	if !app.Confidential() {
+		if clientSecret != "" {
+			return nil, invalid
+		}
+		return app, nil
+	}
+	if subtle.ConstantTimeCompare([]byte(hashAccessToken(clientSecret)), []byte(*app.SecretHash)) != 1 {
+		return nil, invalid
+	}
+	return app, nil
+}
+
+// checkUser fails the grant when the user can no longer sign in
+func (s *OAuthService) checkUser(ctx context.Context, userID uuid.UUID) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return &OAuthError{Code: OAuthInvalidGrant, Description: "user not found"}
+	}
+	if user.Status != models.UserStatusActive {
+		return &OAuthError{Code: OAuthInvalidGrant, Description: "account is " + user.Status}
+	}
+	return nil
+}
+
+// issue creates an access and refresh token pair
+func (s *OAuthService) issue(ctx context.Context, appID, userID uuid.UUID, scopes models.Scopes) (*dtos.OAuthTokenResponse, error) {
+	access, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	accessToken := utils.OAuthAccessTokenPrefix + access
+	refreshToken := oauthRefreshTokenPrefix + refresh
+
+	now := time.Now()
+	token := &models.OAuthToken{
+		AppID:            appID,
+		UserID:           userID,
+		AccessHash:       hashAccessToken(accessToken),
+		RefreshHash:      hashAccessToken(refreshToken),
+		Scopes:           scopes,
+		AccessExpiresAt:  now.Add(oauthAccessTokenLifetime),
+		RefreshExpiresAt: now.Add(oauthRefreshTokenLifetime),
+	}
+	if err := s.oauthRepo.CreateToken(ctx, token); err != nil {
+		return nil, err
+	}
+
+	return &dtos.OAuthTokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    oauthTokenTypeBearer,
+		ExpiresIn:    int(oauthAccessTokenLifetime.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        strings.Join(scopes, " "),
+	}, nil
+}
+
+// findToken looks a token up by its prefix and tells whether it is an access token
+func (s *OAuthService) findToken(ctx context.Context, tokenString string) (*models.OAuthToken, bool) {
+	var token *models.OAuthToken
+	var err error
+	isAccess := strings.HasPrefix(tokenString, utils.OAuthAccessTokenPrefix)
+	switch {
+	case isAccess:
+		token, err = s.oauthRepo.GetTokenByAccessHash(ctx, hashAccessToken(tokenString))
+	case strings.HasPrefix(tokenString, oauthRefreshTokenPrefix):
+		token, err = s.oauthRepo.GetTokenByRefreshHash(ctx, hashAccessToken(tokenString))
+	default:
+		return nil, false
+	}
+	if err != nil {
+		return nil, false
+	}
+	return token, isAccess
+}
+
+// parseScopes parses a space-separated scope parameter
+func parseScopes(scope string) (models.Scopes, error) {
+	var scopes models.Scopes
+	for _, s := range strings.Fields(scope) {
+		if !containsString(models.AccessTokenScopes, s) {
+			return nil, errors.New("unknown scope: " + s)
+		}
+		if !scopes.Has(s) {
+			scopes = append(scopes, s)
+		}
+	}
+	if len(scopes) == 0 {
+		return nil, errors.New("at least one scope is required")
+	}
+	return scopes, nil
+}
+
+// checkRedirectURI accepts absolute https URLs without a fragment, and
+// http ones on the loopback interface for native apps
+func checkRedirectURI(uri string) error {
+	u, err := url.Parse(uri)
+	if err != nil || !u.IsAbs() || u.Host == "" {
+		return errors.New("redirect URI must be an absolute URL: " + uri)
+	}
+	if u.Fragment != "" || strings.Contains(uri, "#") {
+		return errors.New("redirect URI cannot have a fragment: " + uri)
+	}
+	switch u.Scheme {
+	case "https":
+		return nil
+	case "http":
+		host := u.Hostname()
+		if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+			return nil
+		}
+	}
+	return errors.New("redirect URI must use https, or http on localhost: " + uri)
+}
+
+// redirectWith adds params to a registered redirect URI, dropping empty ones
+func redirectWith(uri string, params url.Values) string {
+	u, _ := url.Parse(uri)
+	query := u.Query()
+	for key, values := range params {
+		if len(values) > 0 && values[0] != "" {
+			query.Set(key, values[0])
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// pkceMatches tells whether the code verifier hashes to the S256 challenge
+func pkceMatches(verifier, challenge string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
@@ -0,0 +1,260 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/GavinHemsada/go-backend/internal/audit"
+	"github.com/GavinHemsada/go-backend/internal/dtos"
+	"github.com/GavinHemsada/go-backend/internal/models"
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+)
+
+const (
+	testRedirectURI  = "https://app.example.com/callback"
+	testCodeVerifier = "a-code-verifier-of-at-least-forty-three-characters"
+)
+
+// oauthTest is a confidential app registered by its developer, and a user
+// who signs in to it
+type oauthTest struct {
+	service *OAuthService
+	app     *dtos.OAuthAppResponse
+	user    *models.User
+}
+
+func newOAuthTest(t *testing.T, repos *repository.Repositories) *oauthTest {
+	t.Helper()
+
+	service := NewOAuthService(repos.Users, repos.OAuth, repos.TxManager, audit.NewLogger(repos.Audit))
+	developer := registerWithPassword(t, repos, newTestHasher(t), "developer", "correct horse")
+	app, err := service.CreateApp(context.Background(), developer.ID, "Example", []string{testRedirectURI}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &oauthTest{
+		service: service,
+		app:     app,
+		user:    registerWithPassword(t, repos, newTestHasher(t), "alice", "correct horse"),
+	}
+}
+
+func (o *oauthTest) clientID() string {
+	return o.app.ID.String()
+}
+
+// authorize approves a request for scope with the S256 challenge of
+// testCodeVerifier and returns the code from the redirect
+func (o *oauthTest) authorize(t *testing.T, scope string) string {
+	t.Helper()
+
+	sum := sha256.Sum256([]byte(testCodeVerifier))
+	redirect, err := o.service.Authorize(context.Background(), o.user.ID, dtos.OAuthAuthorizeDto{
+		ResponseType:        "code",
+		ClientID:            o.clientID(),
+		RedirectURI:         testRedirectURI,
+		Scope:               scope,
+		State:               "xyz",
+		CodeChallenge:       base64.RawURLEncoding.EncodeToString(sum[:]),
+		CodeChallengeMethod: "S256",
+		Approve:             true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(redirect.RedirectTo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Query().Get("state") != "xyz" || u.Query().Get("code") == "" {
+		t.Fatalf("got redirect %s, want a code and the state", redirect.RedirectTo)
+	}
+	return u.Query().Get("code")
+}
+
+// tokens runs the whole authorization code flow for scope
+func (o *oauthTest) tokens(t *testing.T, scope string) *dtos.OAuthTokenResponse {
+	t.Helper()
+
+	tokens, err := o.service.ExchangeCode(context.Background(), o.clientID(), o.app.ClientSecret, o.authorize(t, scope), testRedirectURI, testCodeVerifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tokens
+}
+
+// checkGrantError fails the test unless err is an OAuth invalid_grant error
+func checkGrantError(t *testing.T, what string, err error) {
+	t.Helper()
+
+	var oauthErr *OAuthError
+	if !errors.As(err, &oauthErr) || oauthErr.Code != OAuthInvalidGrant {
+		t.Errorf("%s: got %v, want %s", what, err, OAuthInvalidGrant)
+	}
+}
+
+// active introspects token as the app
+func (o *oauthTest) active(t *testing.T, token string) bool {
+	t.Helper()
+
+	result, err := o.service.Introspect(context.Background(), o.clientID(), o.app.ClientSecret, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result.Active
+}
+
+func TestOAuthCodeExchange(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		o := newOAuthTest(t, repos)
+
+		code := o.authorize(t, models.ScopeMessagesRead)
+		tokens, err := o.service.ExchangeCode(ctx, o.clientID(), o.app.ClientSecret, code, testRedirectURI, testCodeVerifier)
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims, err := o.service.Authenticate(ctx, tokens.AccessToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if claims.UserID != o.user.ID || len(claims.Scopes) != 1 || claims.Scopes[0] != models.ScopeMessagesRead {
+			t.Errorf("got claims %+v, want alice with messages:read", claims)
+		}
+
+		_, err = o.service.ExchangeCode(ctx, o.clientID(), o.app.ClientSecret, code, testRedirectURI, testCodeVerifier)
+		checkGrantError(t, "code reused", err)
+	})
+}
+
+func TestOAuthCodeExchangeRejections(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		o := newOAuthTest(t, repos)
+
+		_, err := o.service.ExchangeCode(ctx, o.clientID(), o.app.ClientSecret, o.authorize(t, models.ScopeMessagesRead), testRedirectURI, strings.Repeat("x", 43))
+		checkGrantError(t, "PKCE verifier mismatch", err)
+
+		_, err = o.service.ExchangeCode(ctx, o.clientID(), o.app.ClientSecret, o.authorize(t, models.ScopeMessagesRead), "https://app.example.com/other", testCodeVerifier)
+		checkGrantError(t, "redirect URI mismatch", err)
+
+		_, err = o.service.ExchangeCode(ctx, o.clientID(), "chat_ocs_wrong", o.authorize(t, models.ScopeMessagesRead), testRedirectURI, testCodeVerifier)
+		var oauthErr *OAuthError
+		if !errors.As(err, &oauthErr) || oauthErr.Code != OAuthInvalidClient {
+			t.Errorf("wrong client secret: got %v, want %s", err, OAuthInvalidClient)
+		}
+
+		// Requests naming an unregistered redirect URI get no code at all
+		_, err = o.service.Authorize(ctx, o.user.ID, dtos.OAuthAuthorizeDto{
+			ResponseType:        "code",
+			ClientID:            o.clientID(),
+			RedirectURI:         "https://evil.example.com/callback",
+			Scope:               models.ScopeMessagesRead,
+			CodeChallenge:       strings.Repeat("x", 43),
+			CodeChallengeMethod: "S256",
+			Approve:             true,
+		})
+		if err == nil {
+			t.Error("authorized a request for an unregistered redirect URI")
+		}
+	})
+}
+
+func TestOAuthRefreshRotation(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		o := newOAuthTest(t, repos)
+		first := o.tokens(t, models.ScopeMessagesRead+" "+models.ScopeMessagesWrite)
+
+		second, err := o.service.Refresh(ctx, o.clientID(), o.app.ClientSecret, first.RefreshToken, models.ScopeMessagesRead)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if second.Scope != models.ScopeMessagesRead {
+			t.Errorf("got scope %q, want the narrowed scope", second.Scope)
+		}
+
+		_, err = o.service.Refresh(ctx, o.clientID(), o.app.ClientSecret, first.RefreshToken, "")
+		checkGrantError(t, "old refresh token", err)
+		if _, err := o.service.Authenticate(ctx, first.AccessToken); err == nil {
+			t.Error("the replaced access token still works")
+		}
+
+		// Scopes can only shrink
+		_, err = o.service.Refresh(ctx, o.clientID(), o.app.ClientSecret, second.RefreshToken, models.ScopeMessagesWrite)
+		var oauthErr *OAuthError
+		if !errors.As(err, &oauthErr) || oauthErr.Code != OAuthInvalidScope {
+			t.Errorf("widened scope: got %v, want %s", err, OAuthInvalidScope)
+		}
+		if _, err := o.service.Refresh(ctx, o.clientID(), o.app.ClientSecret, second.RefreshToken, ""); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestOAuthIntrospectionAndRevocation(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		o := newOAuthTest(t, repos)
+		tokens := o.tokens(t, models.ScopeMessagesRead)
+
+		result, err := o.service.Introspect(ctx, o.clientID(), o.app.ClientSecret, tokens.AccessToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Active || result.Sub != o.user.ID.String() || result.Scope != models.ScopeMessagesRead {
+			t.Fatalf("got %+v, want an active token of alice's", result)
+		}
+
+		// Revoking the refresh token revokes its access token with it
+		if err := o.service.Revoke(ctx, o.clientID(), o.app.ClientSecret, tokens.RefreshToken); err != nil {
+			t.Fatal(err)
+		}
+		for _, token := range []string{tokens.AccessToken, tokens.RefreshToken} {
+			if o.active(t, token) {
+				t.Errorf("revoked token %s is still active", token[:9])
+			}
+		}
+		if _, err := o.service.Authenticate(ctx, tokens.AccessToken); err == nil {
+			t.Error("a revoked access token still works")
+		}
+
+		// Revoking again, or an unknown token, is not an error
+		for _, token := range []string{tokens.AccessToken, "chat_oat_unknown"} {
+			if err := o.service.Revoke(ctx, o.clientID(), o.app.ClientSecret, token); err != nil {
+				t.Errorf("revoking %s: %v", token, err)
+			}
+		}
+	})
+}
+
+// Withdrawing consent revokes the app's tokens and the codes it has yet to exchange
+func TestOAuthRevokeAuthorization(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		o := newOAuthTest(t, repos)
+		tokens := o.tokens(t, models.ScopeMessagesRead)
+		code := o.authorize(t, models.ScopeMessagesRead)
+
+		if err := o.service.RevokeAuthorization(ctx, o.user.ID, o.app.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		if o.active(t, tokens.AccessToken) || o.active(t, tokens.RefreshToken) {
+			t.Error("tokens are still active after the authorization was revoked")
+		}
+		_, err := o.service.ExchangeCode(ctx, o.clientID(), o.app.ClientSecret, code, testRedirectURI, testCodeVerifier)
+		checkGrantError(t, "code issued before the revocation", err)
+		if consents, err := o.service.GetAuthorizations(ctx, o.user.ID); err != nil || len(consents) != 0 {
+			t.Errorf("got authorizations %+v, %v, want none", consents, err)
+		}
+		if err := o.service.RevokeAuthorization(ctx, o.user.ID, o.app.ID); err == nil {
+			t.Error("revoked an authorization that no longer exists")
+		}
+	})
+}
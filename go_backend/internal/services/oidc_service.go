@@ -0,0 +1,285 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/audit"
+	"github.com/GavinHemsada/go-backend/internal/dtos"
+	"github.com/GavinHemsada/go-backend/internal/models"
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+	"github.com/GavinHemsada/go-backend/pkg/utils"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// How long the user may take at the provider before coming back.
+	oidcLoginTimeout = 10 * time.Minute
+
+	// Timeout of each request to the provider.
+	oidcRequestTimeout = 10 * time.Second
+)
+
+// OIDCConfig sets up an OIDCService
+type OIDCConfig struct {
+	Issuer         string
+	ClientID       string
+	ClientSecret   string
+	RedirectURL    string
+	Scopes         []string
+	AllowedDomains []string // empty allows every domain
+	GroupsClaim    string
+	AdminGroups    []string // empty leaves roles alone
+}
+
+// OIDCService logs users in through an OpenID Connect provider with the
+// authorization code flow and PKCE. The provider is the source of truth: a
+// login links the identity to the account with the same verified email, or
+// creates an account, and the provider's groups decide the global role.
+// The provider's own second factor applies instead of the local one.
+type OIDCService struct {
+	userRepo     repository.UserRepository
+	identityRepo repository.IdentityRepository
+	accounts     *externalAccounts
+	cfg          OIDCConfig
+	jwtSecret    string
+	auditLog     *audit.Logger
+	client       *http.Client
+
+	// The provider's discovery document is fetched on first use, so the
+	// server starts while the provider is unreachable
+	mu       sync.Mutex
+	provider *oidc.Provider
+}
+
+func NewOIDCService(userRepo repository.UserRepository, identityRepo repository.IdentityRepository, txManager repository.TxManager, cfg OIDCConfig, jwtSecret string, auditLog *audit.Logger) *OIDCService {
+	return &OIDCService{
+		userRepo:     userRepo,
+		identityRepo: identityRepo,
+		accounts:     &externalAccounts{userRepo: userRepo, identityRepo: identityRepo, txManager: txManager, auditLog: auditLog},
+		cfg:          cfg,
+		jwtSecret:    jwtSecret,
+		auditLog:     auditLog,
+		client:       &http.Client{Timeout: oidcRequestTimeout},
+	}
+}
+
+// oidcClaims are the ID token claims used to find or provision the account
+type oidcClaims struct {
+	Subject           string `json:"sub"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	PreferredUsername string `json:"preferred_username"`
+	groups            []string
+}
+
+// BeginLogin starts a login and returns the provider URL to send the user to
+func (s *OIDCService) BeginLogin(ctx context.Context) (string, error) {
+	oauthConfig, _, err := s.oauthConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	login := &models.OIDCLogin{
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: oauth2.GenerateVerifier(),
+		ExpiresAt:    time.Now().UTC().Add(oidcLoginTimeout),
+	}
+	if err := s.identityRepo.CreateLogin(ctx, login); err != nil {
+		return "", err
+	}
+
+	return oauthConfig.AuthCodeURL(login.State, oidc.Nonce(login.Nonce), oauth2.S256ChallengeOption(login.CodeVerifier)), nil
+}
+
+// FinishLogin exchanges the code the provider sent back for an ID token and
+// returns a session token for the matching account
+func (s *OIDCService) FinishLogin(ctx context.Context, state, code, providerError string) (*dtos.AuthResponse, error) {
+	user, err := s.finishLogin(ctx, state, code, providerError)
+
+	event := models.AuditEvent{
+		Action:     models.AuditUserLogin,
+		TargetType: models.AuditTargetUser,
+		Details:    "oidc",
+	}
+	if user != nil {
+		event.ActorID = &user.ID
+		event.TargetID = user.ID.String()
+	}
+	s.auditLog.Record(ctx, audit.Outcome(event, err))
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := utils.GenerateToken(user, s.jwtSecret)
+	if err != nil {
+		return nil, err
+	}
+	return &dtos.AuthResponse{User: user, Token: token}, nil
+}
+
+// finishLogin returns the user who logged in. On failure the user is
+// returned too once the account is known.
+func (s *OIDCService) finishLogin(ctx context.Context, state, code, providerError string) (*models.User, error) {
+	if providerError != "" {
+		return nil, errors.New("identity provider refused the login: " + providerError)
+	}
+	if state == "" || code == "" {
+		return nil, errors.New("state and code are required")
+	}
+
+	// The state is single-use, so a response cannot be replayed
+	login, err := s.identityRepo.TakeLogin(ctx, state)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := s.exchange(ctx, login, code)
+	if err != nil {
+		return nil, err
+	}
+
+	if !claims.EmailVerified || claims.Email == "" {
+		return nil, errors.New("identity provider did not report a verified email")
+	}
+	if !s.domainAllowed(claims.Email) {
+		return nil, errors.New("email domain is not allowed")
+	}
+
+	user, err := s.accounts.account(ctx, externalIdentity{
+		Protocol: "oidc",
+		Issuer:   s.cfg.Issuer,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+		Username: claims.PreferredUsername,
+	})
+	if err != nil {
+		return user, err
+	}
+	if err := s.accounts.syncRole(ctx, user, claims.groups, s.cfg.AdminGroups); err != nil {
+		return user, err
+	}
+
+	if user.Status != models.UserStatusActive {
+		return user, errors.New("account is " + user.Status)
+	}
+	return user, nil
+}
+
+// exchange redeems the code with the PKCE verifier and checks the ID token
+func (s *OIDCService) exchange(ctx context.Context, login *models.OIDCLogin, code string) (*oidcClaims, error) {
+	oauthConfig, provider, err := s.oauthConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = context.WithValue(oidc.ClientContext(ctx, s.client), oauth2.HTTPClient, s.client)
+	token, err := oauthConfig.Exchange(ctx, code, oauth2.VerifierOption(login.CodeVerifier))
+	if err != nil {
+		return nil, errors.New("authorization code could not be redeemed")
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("identity provider did not return an ID token")
+	}
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: s.cfg.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, errors.New("ID token could not be verified")
+	}
+	if idToken.Nonce != login.Nonce {
+		return nil, errors.New("ID token could not be verified")
+	}
+
+	var claims oidcClaims
+	var all map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+	if err := idToken.Claims(&all); err != nil {
+		return nil, err
+	}
+	claims.groups = claimStrings(all[s.cfg.GroupsClaim])
+	return &claims, nil
+}
+
+func (s *OIDCService) domainAllowed(email string) bool {
+	if len(s.cfg.AllowedDomains) == 0 {
+		return true
+	}
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := email[at+1:]
+	for _, allowed := range s.cfg.AllowedDomains {
+		if strings.EqualFold(domain, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// oauthConfig returns the client configuration, discovering the provider's
+// endpoints and keys on first use
+func (s *OIDCService) oauthConfig(ctx context.Context) (*oauth2.Config, *oidc.Provider, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.provider == nil {
+		provider, err := oidc.NewProvider(oidc.ClientContext(ctx, s.client), s.cfg.Issuer)
+		if err != nil {
+			return nil, nil, errors.New("identity provider is unavailable")
+		}
+		s.provider = provider
+	}
+
+	return &oauth2.Config{
+		ClientID:     s.cfg.ClientID,
+		ClientSecret: s.cfg.ClientSecret,
+		RedirectURL:  s.cfg.RedirectURL,
+		Endpoint:     s.provider.Endpoint(),
+		Scopes:       s.cfg.Scopes,
+	}, s.provider, nil
+}
+
+// claimStrings reads a claim holding a list of strings or a single string
+func claimStrings(claim interface{}) []string {
+	switch v := claim.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var list []string
+		for _, item := range v {
+			if str, ok := item.(string); ok {
+				list = append(list, str)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,343 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/audit"
+	"github.com/GavinHemsada/go-backend/internal/dtos"
+	"github.com/GavinHemsada/go-backend/internal/models"
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientID     = "chat"
+	testClientSecret = "client-secret"
+	testRedirectURL  = "https://chat.example.com/api/auth/oidc/callback"
+)
+
+// oidcUser is who signs in at the stand-in provider
+type oidcUser struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Username      string
+	Groups        []string
+}
+
+// oidcGrant is an authorization code the provider handed out
+type oidcGrant struct {
+	user          oidcUser
+	nonce         string
+	codeChallenge string
+}
+
+// fakeOIDCProvider is a stand-in OpenID Connect provider serving discovery,
+// keys, the authorization endpoint and the token endpoint of the code flow
+// with PKCE. Whoever signs in is set with signIn.
+type fakeOIDCProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu       sync.Mutex
+	user     oidcUser
+	badNonce bool
+	grants   map[string]oidcGrant
+}
+
+func newFakeOIDCProvider(t *testing.T) *fakeOIDCProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &fakeOIDCProvider{key: key, grants: make(map[string]oidcGrant)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("GET /keys", p.keys)
+	mux.HandleFunc("GET /authorize", p.authorize)
+	mux.HandleFunc("POST /token", p.token)
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+func (p *fakeOIDCProvider) signIn(user oidcUser) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.user = user
+}
+
+// issueWrongNonce makes the provider put another login's nonce in ID tokens
+func (p *fakeOIDCProvider) issueWrongNonce(wrong bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.badNonce = wrong
+}
+
+func (p *fakeOIDCProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	issuer := p.server.URL
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"issuer":                                issuer,
+		"authorization_endpoint":                issuer + "/authorize",
+		"token_endpoint":                        issuer + "/token",
+		"jwks_uri":                              issuer + "/keys",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (p *fakeOIDCProvider) keys(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"kid": "test",
+			"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+		}},
+	})
+}
+
+// authorize signs the current user in and redirects back with a code
+func (p *fakeOIDCProvider) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("response_type") != "code" || q.Get("client_id") != testClientID || q.Get("redirect_uri") != testRedirectURL ||
+		q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" || q.Get("nonce") == "" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+
+	code, err := randomToken()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	p.mu.Lock()
+	p.grants[code] = oidcGrant{user: p.user, nonce: q.Get("nonce"), codeChallenge: q.Get("code_challenge")}
+	p.mu.Unlock()
+
+	back := url.Values{"state": {q.Get("state")}, "code": {code}}
+	http.Redirect(w, r, testRedirectURL+"?"+back.Encode(), http.StatusFound)
+}
+
+// token redeems a code once, for the client that holds the PKCE verifier
+func (p *fakeOIDCProvider) token(w http.ResponseWriter, r *http.Request) {
+	clientID, secret, ok := r.BasicAuth()
+	if !ok {
+		clientID, secret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+	}
+	if clientID != testClientID || secret != testClientSecret {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+
+	p.mu.Lock()
+	grant, ok := p.grants[r.PostFormValue("code")]
+	delete(p.grants, r.PostFormValue("code"))
+	badNonce := p.badNonce
+	p.mu.Unlock()
+
+	verifier := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !ok || r.PostFormValue("grant_type") != "authorization_code" || r.PostFormValue("redirect_uri") != testRedirectURL ||
+		base64.RawURLEncoding.EncodeToString(verifier[:]) != grant.codeChallenge {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_grant"}`))
+		return
+	}
+
+	nonce := grant.nonce
+	if badNonce {
+		nonce = "another login"
+	}
+	now := time.Now()
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":                p.server.URL,
+		"aud":                testClientID,
+		"sub":                grant.user.Subject,
+		"iat":                now.Unix(),
+		"exp":                now.Add(time.Minute).Unix(),
+		"nonce":              nonce,
+		"email":              grant.user.Email,
+		"email_verified":     grant.user.EmailVerified,
+		"preferred_username": grant.user.Username,
+		"groups":             grant.user.Groups,
+	})
+	idToken.Header["kid"] = "test"
+	signed, err := idToken.SignedString(p.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   60,
+		"id_token":     signed,
+	})
+}
+
+func newOIDCService(repos *repository.Repositories, provider *fakeOIDCProvider, allowedDomains ...string) *OIDCService {
+	return NewOIDCService(repos.Users, repos.Identities, repos.TxManager, OIDCConfig{
+		Issuer:         provider.server.URL,
+		ClientID:       testClientID,
+		ClientSecret:   testClientSecret,
+		RedirectURL:    testRedirectURL,
+		Scopes:         []string{"openid", "email", "profile"},
+		AllowedDomains: allowedDomains,
+		GroupsClaim:    "groups",
+		AdminGroups:    []string{"chat-admins"},
+	}, testJWTSecret, audit.NewLogger(repos.Audit))
+}
+
+// oidcRedirect follows a login through the provider as a browser would and
+// returns the state and code sent back to the redirect URL
+func oidcRedirect(t *testing.T, service *OIDCService) (string, string) {
+	t.Helper()
+
+	authURL, err := service.BeginLogin(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	browser := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := browser.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("provider answered the authorization request with %s", resp.Status)
+	}
+
+	back, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return back.Query().Get("state"), back.Query().Get("code")
+}
+
+func oidcLogin(t *testing.T, service *OIDCService) (*dtos.AuthResponse, error) {
+	t.Helper()
+
+	state, code := oidcRedirect(t, service)
+	return service.FinishLogin(context.Background(), state, code, "")
+}
+
+func TestOIDCLoginProvisionsAccount(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		provider := newFakeOIDCProvider(t)
+		service := newOIDCService(repos, provider)
+
+		provider.signIn(oidcUser{Subject: "u-1", Email: "carol@corp.example", EmailVerified: true, Username: "Carol", Groups: []string{"chat-admins"}})
+		auth, err := oidcLogin(t, service)
+		if err != nil {
+			t.Fatal(err)
+		}
+		carol := auth.User
+		if auth.Token == "" || carol.Username != "carol" || carol.Email != "carol@corp.example" || !carol.EmailVerified || carol.Role != models.RoleAdmin {
+			t.Fatalf("got %+v, want a verified admin account named carol", carol)
+		}
+
+		// The identity is linked, and groups are synced on every login
+		provider.signIn(oidcUser{Subject: "u-1", Email: "carol@corp.example", EmailVerified: true, Username: "Carol"})
+		auth, err = oidcLogin(t, service)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if auth.User.ID != carol.ID || auth.User.Role != models.RoleUser {
+			t.Errorf("got %+v, want carol demoted to user", auth.User)
+		}
+	})
+}
+
+func TestOIDCLoginLinksExistingAccount(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		provider := newFakeOIDCProvider(t)
+		service := newOIDCService(repos, provider)
+		alice := registerWithPassword(t, repos, newTestHasher(t), "alice", "correct horse")
+
+		provider.signIn(oidcUser{Subject: "u-2", Email: alice.Email, EmailVerified: true, Username: "someone-else"})
+		auth, err := oidcLogin(t, service)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if auth.User.ID != alice.ID {
+			t.Errorf("got %+v, want alice's account", auth.User)
+		}
+		if _, err := repos.Identities.GetBySubject(context.Background(), provider.server.URL, "u-2"); err != nil {
+			t.Errorf("identity was not linked: %v", err)
+		}
+	})
+}
+
+func TestOIDCLoginRejections(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		provider := newFakeOIDCProvider(t)
+		service := newOIDCService(repos, provider, "corp.example")
+		verified := oidcUser{Subject: "u-3", Email: "dave@corp.example", EmailVerified: true}
+
+		provider.signIn(oidcUser{Subject: "u-3", Email: "dave@corp.example"})
+		if _, err := oidcLogin(t, service); err == nil {
+			t.Error("logged in without a verified email")
+		}
+		provider.signIn(oidcUser{Subject: "u-4", Email: "eve@elsewhere.example", EmailVerified: true})
+		if _, err := oidcLogin(t, service); err == nil {
+			t.Error("logged in from a domain that is not allowed")
+		}
+
+		provider.signIn(verified)
+		provider.issueWrongNonce(true)
+		if _, err := oidcLogin(t, service); err == nil {
+			t.Error("accepted an ID token issued for another login")
+		}
+		provider.issueWrongNonce(false)
+
+		// The state is single-use, and a code is bound to its login's verifier
+		state, code := oidcRedirect(t, service)
+		if _, err := service.FinishLogin(ctx, state, code, ""); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := service.FinishLogin(ctx, state, code, ""); err == nil {
+			t.Error("a login response was accepted twice")
+		}
+		_, code = oidcRedirect(t, service)
+		otherState, _ := oidcRedirect(t, service)
+		if _, err := service.FinishLogin(ctx, otherState, code, ""); err == nil {
+			t.Error("redeemed a code with another login's verifier")
+		}
+
+		if _, err := service.FinishLogin(ctx, "", "", "access_denied"); err == nil {
+			t.Error("accepted a refused login")
+		}
+	})
+}
+
+// The provider is discovered on first use, so it may be down at startup
+func TestOIDCProviderUnavailable(t *testing.T) {
+	repos := repository.NewMemoryRepositories(repository.NewMemoryStore())
+	provider := newFakeOIDCProvider(t)
+	service := newOIDCService(repos, provider)
+	provider.server.Close()
+
+	if _, err := service.BeginLogin(context.Background()); err == nil {
+		t.Fatal("began a login while the provider is down")
+	}
+}
@@ -0,0 +1,370 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/audit"
+	"github.com/GavinHemsada/go-backend/internal/dtos"
+	"github.com/GavinHemsada/go-backend/internal/models"
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+	"github.com/GavinHemsada/go-backend/pkg/utils"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+)
+
+const (
+	// How long a passkey ceremony may take, from begin to finish.
+	passkeyCeremonyTimeout = 5 * time.Minute
+
+	// Longest passkey name.
+	maxPasskeyNameLength = 100
+)
+
+// PasskeyService handles passkey registration and passwordless login. Passkeys
+// are discoverable credentials that verify the user, with a PIN or biometric,
+// so a passkey login needs no password and no second factor.
+type PasskeyService struct {
+	userRepo    repository.UserRepository
+	passkeyRepo repository.PasskeyRepository
+	webAuthn    *webauthn.WebAuthn
+	jwtSecret   string
+	auditLog    *audit.Logger
+}
+
+// NewPasskeyService sets up the relying party identified by rpID, accepting
+// ceremonies from origins
+func NewPasskeyService(userRepo repository.UserRepository, passkeyRepo repository.PasskeyRepository, rpID, rpDisplayName string, origins []string, jwtSecret string, auditLog *audit.Logger) (*PasskeyService, error) {
+	timeout := webauthn.TimeoutConfig{Enforce: true, Timeout: passkeyCeremonyTimeout, TimeoutUVD: passkeyCeremonyTimeout}
+	webAuthn, err := webauthn.New(&webauthn.Config{
+		RPID:          rpID,
+		RPDisplayName: rpDisplayName,
+		RPOrigins:     origins,
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementRequired,
+			UserVerification: protocol.VerificationRequired,
+		},
+		Timeouts: webauthn.TimeoutsConfig{Login: timeout, Registration: timeout},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &PasskeyService{
+		userRepo:    userRepo,
+		passkeyRepo: passkeyRepo,
+		webAuthn:    webAuthn,
+		jwtSecret:   jwtSecret,
+		auditLog:    auditLog,
+	}, nil
+}
+
+// BeginRegistration starts registering a new passkey for the user
+func (s *PasskeyService) BeginRegistration(ctx context.Context, userID uuid.UUID) (*dtos.PasskeyCeremonyResponse, error) {
+	account, err := s.account(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The user's other passkeys are excluded, so one authenticator is not registered twice
+	creation, session, err := s.webAuthn.BeginRegistration(
+		account,
+		webauthn.WithExclusions(webauthn.Credentials(account.WebAuthnCredentials()).CredentialDescriptors()),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionID, err := s.saveSession(ctx, models.PasskeyCeremonyRegistration, &userID, session)
+	if err != nil {
+		return nil, err
+	}
+	return &dtos.PasskeyCeremonyResponse{SessionID: sessionID, Options: creation}, nil
+}
+
+// FinishRegistration verifies the authenticator's response and stores the new passkey
+func (s *PasskeyService) FinishRegistration(ctx context.Context, userID, sessionID uuid.UUID, name string, credential json.RawMessage) (*models.Passkey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "Passkey"
+	}
+	if len(name) > maxPasskeyNameLength {
+		return nil, errors.New("name must be at most 100 characters")
+	}
+
+	passkey, err := s.finishRegistration(ctx, userID, sessionID, name, credential)
+	s.auditLog.Record(ctx, audit.Outcome(models.AuditEvent{
+		ActorID:    &userID,
+		Action:     models.AuditPasskeyRegister,
+		TargetType: models.AuditTargetUser,
+		TargetID:   userID.String(),
+		Details:    name,
+	}, err))
+	return passkey, err
+}
+
+func (s *PasskeyService) finishRegistration(ctx context.Context, userID, sessionID uuid.UUID, name string, credential json.RawMessage) (*models.Passkey, error) {
+	session, err := s.takeSession(ctx, sessionID, models.PasskeyCeremonyRegistration)
+	if err != nil {
+		return nil, err
+	}
+	if session.UserID == nil || *session.UserID != userID {
+		return nil, errors.New("passkey session not found or expired")
+	}
+
+	account, err := s.account(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(credential)
+	if err != nil {
+		return nil, errors.New("invalid passkey response")
+	}
+	created, err := s.webAuthn.CreateCredential(account, *session.data, parsed)
+	if err != nil {
+		return nil, errors.New("passkey could not be verified")
+	}
+
+	passkey := toPasskey(created)
+	passkey.UserID = userID
+	passkey.Name = name
+	if err := s.passkeyRepo.Create(ctx, passkey); err != nil {
+		return nil, err
+	}
+	return passkey, nil
+}
+
+// GetPasskeys lists the user's passkeys
+func (s *PasskeyService) GetPasskeys(ctx context.Context, userID uuid.UUID) ([]models.Passkey, error) {
+	return s.passkeyRepo.GetByUser(ctx, userID)
+}
+
+// DeletePasskey removes one of the user's passkeys
+func (s *PasskeyService) DeletePasskey(ctx context.Context, userID, passkeyID uuid.UUID) error {
+	err := s.passkeyRepo.Delete(ctx, userID, passkeyID)
+	s.auditLog.Record(ctx, audit.Outcome(models.AuditEvent{
+		ActorID:    &userID,
+		Action:     models.AuditPasskeyDelete,
+		TargetType: models.AuditTargetUser,
+		TargetID:   userID.String(),
+		Details:    passkeyID.String(),
+	}, err))
+	return err
+}
+
+// BeginLogin starts a passwordless login. The authenticator offers the
+// passkeys it holds for this site, so no username is needed.
+func (s *PasskeyService) BeginLogin(ctx context.Context) (*dtos.PasskeyCeremonyResponse, error) {
+	assertion, session, err := s.webAuthn.BeginDiscoverableLogin()
+	if err != nil {
+		return nil, err
+	}
+
+	sessionID, err := s.saveSession(ctx, models.PasskeyCeremonyLogin, nil, session)
+	if err != nil {
+		return nil, err
+	}
+	return &dtos.PasskeyCeremonyResponse{SessionID: sessionID, Options: assertion}, nil
+}
+
+// FinishLogin verifies the authenticator's response and returns a session token
+func (s *PasskeyService) FinishLogin(ctx context.Context, sessionID uuid.UUID, credential json.RawMessage) (*dtos.AuthResponse, error) {
+	user, err := s.finishLogin(ctx, sessionID, credential)
+
+	event := models.AuditEvent{
+		Action:     models.AuditUserLogin,
+		TargetType: models.AuditTargetUser,
+		Details:    "passkey",
+	}
+	if user != nil {
+		event.ActorID = &user.ID
+		event.TargetID = user.ID.String()
+	}
+	s.auditLog.Record(ctx, audit.Outcome(event, err))
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := utils.GenerateToken(user, s.jwtSecret)
+	if err != nil {
+		return nil, err
+	}
+	return &dtos.AuthResponse{User: user, Token: token}, nil
+}
+
+// finishLogin returns the user who logged in. On failure the user is
+// returned too once the passkey's owner is known.
+func (s *PasskeyService) finishLogin(ctx context.Context, sessionID uuid.UUID, credential json.RawMessage) (*models.User, error) {
+	session, err := s.takeSession(ctx, sessionID, models.PasskeyCeremonyLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(credential)
+	if err != nil {
+		return nil, errors.New("invalid passkey response")
+	}
+
+	// The user handle the authenticator returns is the ID the passkey was registered with
+	var account *passkeyAccount
+	_, used, err := s.webAuthn.ValidatePasskeyLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+		userID, err := uuid.FromBytes(userHandle)
+		if err != nil {
+			return nil, err
+		}
+		account, err = s.account(ctx, userID)
+		return account, err
+	}, *session.data, parsed)
+	if account == nil {
+		return nil, errors.New("passkey is not registered")
+	}
+	if err != nil {
+		return account.user, errors.New("passkey could not be verified")
+	}
+
+	passkey := account.passkey(used.ID)
+	passkey.SignCount = used.Authenticator.SignCount
+	passkey.CloneWarning = used.Authenticator.CloneWarning
+	passkey.Flags = used.Flags.MsgpByte()
+	if err := s.passkeyRepo.RecordUse(ctx, passkey); err != nil {
+		return account.user, err
+	}
+
+	// A signature counter that goes backwards means the key was copied off the authenticator
+	if passkey.CloneWarning {
+		return account.user, errors.New("passkey may have been cloned, remove it and register a new one")
+	}
+	if account.user.Status != models.UserStatusActive {
+		return account.user, errors.New("account is " + account.user.Status)
+	}
+	return account.user, nil
+}
+
+// passkeySession is a stored ceremony with its relying party state decoded
+type passkeySession struct {
+	*models.PasskeySession
+	data *webauthn.SessionData
+}
+
+func (s *PasskeyService) saveSession(ctx context.Context, ceremony string, userID *uuid.UUID, data *webauthn.SessionData) (uuid.UUID, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	session := &models.PasskeySession{
+		Ceremony:  ceremony,
+		UserID:    userID,
+		Data:      string(encoded),
+		ExpiresAt: data.Expires,
+	}
+	if err := s.passkeyRepo.CreateSession(ctx, session); err != nil {
+		return uuid.Nil, err
+	}
+	return session.ID, nil
+}
+
+func (s *PasskeyService) takeSession(ctx context.Context, id uuid.UUID, ceremony string) (*passkeySession, error) {
+	session, err := s.passkeyRepo.TakeSession(ctx, id, ceremony)
+	if err != nil {
+		return nil, err
+	}
+
+	var data webauthn.SessionData
+	if err := json.Unmarshal([]byte(session.Data), &data); err != nil {
+		return nil, err
+	}
+	return &passkeySession{PasskeySession: session, data: &data}, nil
+}
+
+// passkeyAccount presents a user and their passkeys to the WebAuthn library
+type passkeyAccount struct {
+	user     *models.User
+	passkeys []models.Passkey
+}
+
+func (s *PasskeyService) account(ctx context.Context, userID uuid.UUID) (*passkeyAccount, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	passkeys, err := s.passkeyRepo.GetByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &passkeyAccount{user: user, passkeys: passkeys}, nil
+}
+
+// WebAuthnID is the user handle stored on the authenticator: the user's ID
+func (a *passkeyAccount) WebAuthnID() []byte {
+	return a.user.ID[:]
+}
+
+func (a *passkeyAccount) WebAuthnName() string {
+	return a.user.Username
+}
+
+func (a *passkeyAccount) WebAuthnDisplayName() string {
+	return a.user.Username
+}
+
+func (a *passkeyAccount) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, len(a.passkeys))
+	for i, p := range a.passkeys {
+		var transports []protocol.AuthenticatorTransport
+		for _, t := range strings.Split(p.Transports, ",") {
+			if t != "" {
+				transports = append(transports, protocol.AuthenticatorTransport(t))
+			}
+		}
+
+		credentials[i] = webauthn.Credential{
+			ID:              p.CredentialID,
+			PublicKey:       p.PublicKey,
+			AttestationType: p.AttestationType,
+			Transport:       transports,
+			Flags:           webauthn.CredentialFlagsFromMsgpByte(p.Flags),
+			Authenticator: webauthn.Authenticator{
+				AAGUID:       p.AAGUID,
+				SignCount:    p.SignCount,
+				CloneWarning: p.CloneWarning,
+			},
+		}
+	}
+	return credentials
+}
+
+// passkey returns the account's passkey with the credential ID
+func (a *passkeyAccount) passkey(credentialID []byte) *models.Passkey {
+	for i := range a.passkeys {
+		if bytes.Equal(a.passkeys[i].CredentialID, credentialID) {
+			return &a.passkeys[i]
+		}
+	}
+	return nil
+}
+
+// toPasskey converts a newly registered credential for storage
+func toPasskey(c *webauthn.Credential) *models.Passkey {
+	transports := make([]string, len(c.Transport))
+	for i, t := range c.Transport {
+		transports[i] = string(t)
+	}
+
+	return &models.Passkey{
+		CredentialID:    c.ID,
+		PublicKey:       c.PublicKey,
+		AttestationType: c.AttestationType,
+		Transports:      strings.Join(transports, ","),
+		AAGUID:          c.Authenticator.AAGUID,
+		Flags:           c.Flags.MsgpByte(),
+		SignCount:       c.Authenticator.SignCount,
+		CloneWarning:    c.Authenticator.CloneWarning,
+	}
+}
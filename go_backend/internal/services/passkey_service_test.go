@@ -0,0 +1,348 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"github.com/GavinHemsada/go-backend/internal/audit"
+	"github.com/GavinHemsada/go-backend/internal/dtos"
+	"github.com/GavinHemsada/go-backend/internal/models"
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+)
+
+const (
+	testRPID   = "chat.example.com"
+	testOrigin = "https://chat.example.com"
+)
+
+// Authenticator data flags
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttestedData = 0x40
+)
+
+var b64 = base64.RawURLEncoding
+
+// softAuthenticator is a software passkey: one P-256 key with a signature
+// counter, answering ceremonies the way a platform authenticator would
+type softAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32
+	origin       string
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentialID := make([]byte, 16)
+	if _, err := rand.Read(credentialID); err != nil {
+		t.Fatal(err)
+	}
+	return &softAuthenticator{key: key, credentialID: credentialID, origin: testOrigin}
+}
+
+// ceremonyOptions is the part of the options sent to the browser the authenticator reads
+type ceremonyOptions struct {
+	PublicKey struct {
+		Challenge string `json:"challenge"`
+		User      struct {
+			ID string `json:"id"`
+		} `json:"user"`
+	} `json:"publicKey"`
+}
+
+func readOptions(t *testing.T, ceremony *dtos.PasskeyCeremonyResponse) ceremonyOptions {
+	t.Helper()
+
+	b, err := json.Marshal(ceremony.Options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var options ceremonyOptions
+	if err := json.Unmarshal(b, &options); err != nil {
+		t.Fatal(err)
+	}
+	return options
+}
+
+func (a *softAuthenticator) clientData(t *testing.T, ceremonyType, challenge string) []byte {
+	t.Helper()
+
+	b, err := json.Marshal(map[string]string{"type": ceremonyType, "challenge": challenge, "origin": a.origin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// authData builds authenticator data for the relying party, with the
+// credential's public key appended when attested is set
+func (a *softAuthenticator) authData(t *testing.T, attested bool) []byte {
+	t.Helper()
+
+	rpIDHash := sha256.Sum256([]byte(testRPID))
+	data := append([]byte{}, rpIDHash[:]...)
+	flags := byte(flagUserPresent | flagUserVerified)
+	if attested {
+		flags |= flagAttestedData
+	}
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	if !attested {
+		return data
+	}
+
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  int64(webauthncose.P256),
+		XCoord: a.key.PublicKey.X.FillBytes(make([]byte, 32)),
+		YCoord: a.key.PublicKey.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = append(data, make([]byte, 16)...) // AAGUID
+	data = binary.BigEndian.AppendUint16(data, uint16(len(a.credentialID)))
+	data = append(data, a.credentialID...)
+	return append(data, publicKey...)
+}
+
+// create answers a registration ceremony with a "none" attestation
+func (a *softAuthenticator) create(t *testing.T, ceremony *dtos.PasskeyCeremonyResponse) json.RawMessage {
+	t.Helper()
+
+	options := readOptions(t, ceremony)
+	userHandle, err := b64.DecodeString(options.PublicKey.User.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.userHandle = userHandle
+
+	attestation, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": a.authData(t, true),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a.credential(t, map[string]string{
+		"clientDataJSON":    b64.EncodeToString(a.clientData(t, "webauthn.create", options.PublicKey.Challenge)),
+		"attestationObject": b64.EncodeToString(attestation),
+	})
+}
+
+// get answers a login ceremony, counting the signature
+func (a *softAuthenticator) get(t *testing.T, ceremony *dtos.PasskeyCeremonyResponse) json.RawMessage {
+	t.Helper()
+
+	a.signCount++
+	return a.sign(t, a.key, readOptions(t, ceremony).PublicKey.Challenge)
+}
+
+// sign makes an assertion over the challenge with key
+func (a *softAuthenticator) sign(t *testing.T, key *ecdsa.PrivateKey, challenge string) json.RawMessage {
+	t.Helper()
+
+	authData := a.authData(t, false)
+	clientData := a.clientData(t, "webauthn.get", challenge)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a.credential(t, map[string]string{
+		"clientDataJSON":    b64.EncodeToString(clientData),
+		"authenticatorData": b64.EncodeToString(authData),
+		"signature":         b64.EncodeToString(signature),
+		"userHandle":        b64.EncodeToString(a.userHandle),
+	})
+}
+
+func (a *softAuthenticator) credential(t *testing.T, response map[string]string) json.RawMessage {
+	t.Helper()
+
+	id := b64.EncodeToString(a.credentialID)
+	b, err := json.Marshal(map[string]any{"id": id, "rawId": id, "type": "public-key", "response": response})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func newPasskeyService(t *testing.T, repos *repository.Repositories) *PasskeyService {
+	t.Helper()
+
+	service, err := NewPasskeyService(repos.Users, repos.Passkeys, testRPID, "Chat", []string{testOrigin}, testJWTSecret, audit.NewLogger(repos.Audit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return service
+}
+
+// registerPasskey registers a new software passkey for user
+func registerPasskey(t *testing.T, service *PasskeyService, user *models.User) *softAuthenticator {
+	t.Helper()
+	ctx := context.Background()
+
+	ceremony, err := service.BeginRegistration(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticator := newSoftAuthenticator(t)
+	if _, err := service.FinishRegistration(ctx, user.ID, ceremony.SessionID, "Laptop", authenticator.create(t, ceremony)); err != nil {
+		t.Fatal(err)
+	}
+	return authenticator
+}
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		service := newPasskeyService(t, repos)
+		alice := registerWithPassword(t, repos, newTestHasher(t), "alice", "correct horse")
+
+		authenticator := registerPasskey(t, service, alice)
+		passkeys, err := service.GetPasskeys(ctx, alice.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(passkeys) != 1 || passkeys[0].Name != "Laptop" {
+			t.Fatalf("got passkeys %+v, want the one named Laptop", passkeys)
+		}
+
+		ceremony, err := service.BeginLogin(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertion := authenticator.get(t, ceremony)
+		auth, err := service.FinishLogin(ctx, ceremony.SessionID, assertion)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if auth.Token == "" || auth.User.ID != alice.ID {
+			t.Fatalf("got %+v, want a session for alice", auth)
+		}
+
+		// The ceremony is used up, and its challenge does not carry over to another one
+		if _, err := service.FinishLogin(ctx, ceremony.SessionID, assertion); err == nil {
+			t.Fatal("an assertion was accepted twice")
+		}
+		next, err := service.BeginLogin(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := service.FinishLogin(ctx, next.SessionID, assertion); err == nil {
+			t.Fatal("an assertion was accepted for another challenge")
+		}
+	})
+}
+
+func TestPasskeyRegistrationRejectsOtherOrigin(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		service := newPasskeyService(t, repos)
+		alice := registerWithPassword(t, repos, newTestHasher(t), "alice", "correct horse")
+
+		ceremony, err := service.BeginRegistration(ctx, alice.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		authenticator := newSoftAuthenticator(t)
+		authenticator.origin = "https://phish.example.net"
+		if _, err := service.FinishRegistration(ctx, alice.ID, ceremony.SessionID, "", authenticator.create(t, ceremony)); err == nil {
+			t.Fatal("registered a passkey created for another origin")
+		}
+		if passkeys, err := service.GetPasskeys(ctx, alice.ID); err != nil || len(passkeys) != 0 {
+			t.Errorf("got passkeys %+v, %v, want none", passkeys, err)
+		}
+	})
+}
+
+func TestPasskeyLoginRejectsWrongKey(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		service := newPasskeyService(t, repos)
+		alice := registerWithPassword(t, repos, newTestHasher(t), "alice", "correct horse")
+		authenticator := registerPasskey(t, service, alice)
+
+		ceremony, err := service.BeginLogin(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		impostor := newSoftAuthenticator(t)
+		authenticator.signCount++
+		if _, err := service.FinishLogin(ctx, ceremony.SessionID, authenticator.sign(t, impostor.key, readOptions(t, ceremony).PublicKey.Challenge)); err == nil {
+			t.Fatal("logged in with a signature from another key")
+		}
+
+		events := auditEvents(t, repos)
+		last := events[len(events)-1]
+		if last.Action != models.AuditUserLogin || last.Result != models.AuditFailure || last.TargetID != alice.ID.String() {
+			t.Errorf("got %+v, want a failed login for alice", last)
+		}
+	})
+}
+
+// A counter that goes backwards means two copies of the key are in use
+func TestPasskeyCloneWarning(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		service := newPasskeyService(t, repos)
+		alice := registerWithPassword(t, repos, newTestHasher(t), "alice", "correct horse")
+		authenticator := registerPasskey(t, service, alice)
+
+		login := func() error {
+			ceremony, err := service.BeginLogin(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = service.FinishLogin(ctx, ceremony.SessionID, authenticator.get(t, ceremony))
+			return err
+		}
+		authenticator.signCount = 10
+		if err := login(); err != nil {
+			t.Fatal(err)
+		}
+
+		// The clone's counter lags behind the original's
+		authenticator.signCount = 3
+		if err := login(); err == nil {
+			t.Fatal("logged in with a cloned passkey")
+		}
+		passkeys, err := service.GetPasskeys(ctx, alice.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(passkeys) != 1 || !passkeys[0].CloneWarning {
+			t.Fatalf("got passkeys %+v, want the passkey flagged as cloned", passkeys)
+		}
+
+		// The flag sticks even once the counter moves on
+		authenticator.signCount = 20
+		if err := login(); err == nil {
+			t.Fatal("logged in with a passkey flagged as cloned")
+		}
+	})
+}
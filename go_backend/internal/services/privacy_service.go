@@ -0,0 +1,249 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/audit"
+	"github.com/GavinHemsada/go-backend/internal/dtos"
+	"github.com/GavinHemsada/go-backend/internal/models"
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+	"github.com/google/uuid"
+)
+
+// Users active this recently show as online. Open WebSocket connections
+// keep their user active, see UserService.Seen.
+const presenceWindow = 3 * time.Minute
+
+// PrivacyService decides what users see of each other. The full record,
+// email included, is for the user themselves and admins; everyone else gets
+// a models.PublicUser within the user's privacy settings.
+type PrivacyService struct {
+	userRepo    repository.UserRepository
+	roomRepo    repository.RoomRepository
+	privacyRepo repository.PrivacyRepository
+	auditLog    *audit.Logger
+}
+
+func NewPrivacyService(userRepo repository.UserRepository, roomRepo repository.RoomRepository, privacyRepo repository.PrivacyRepository, auditLog *audit.Logger) *PrivacyService {
+	return &PrivacyService{
+		userRepo:    userRepo,
+		roomRepo:    roomRepo,
+		privacyRepo: privacyRepo,
+		auditLog:    auditLog,
+	}
+}
+
+// GetSettings returns the user's privacy settings
+func (s *PrivacyService) GetSettings(ctx context.Context, userID uuid.UUID) (*models.PrivacySettings, error) {
+	return s.privacyRepo.Get(ctx, userID)
+}
+
+// UpdateSettings changes the settings set in req and returns them all
+func (s *PrivacyService) UpdateSettings(ctx context.Context, userID uuid.UUID, req dtos.UpdatePrivacyDto) (*models.PrivacySettings, error) {
+	settings, err := s.privacyRepo.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	fields := []struct {
+		name  string
+		value *string
+		field *string
+	}{
+		{"email", req.Email, &settings.Email},
+		{"presence", req.Presence, &settings.Presence},
+		{"last_seen", req.LastSeen, &settings.LastSeen},
+		{"direct_messages", req.DirectMessages, &settings.DirectMessages},
+	}
+	for _, f := range fields {
+		if f.value == nil {
+			continue
+		}
+		switch *f.value {
+		case models.VisibilityEveryone, models.VisibilityRoomMembers, models.VisibilityNobody:
+		default:
+			return nil, errors.New(f.name + " must be everyone, room_members or nobody")
+		}
+		if *f.value != *f.field {
+			*f.field = *f.value
+			changed = append(changed, f.name+"="+*f.value)
+		}
+	}
+	if len(changed) == 0 {
+		return settings, nil
+	}
+
+	err = s.privacyRepo.Set(ctx, settings)
+	s.auditLog.Record(ctx, audit.Outcome(models.AuditEvent{
+		ActorID:    &userID,
+		Action:     models.AuditPrivacyUpdate,
+		TargetType: models.AuditTargetUser,
+		TargetID:   userID.String(),
+		Details:    strings.Join(changed, ", "),
+	}, err))
+	if err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// FullAccess reports whether the viewer may see the user's full record,
+// which only the user themselves and admins may
+func (s *PrivacyService) FullAccess(ctx context.Context, viewerID, userID uuid.UUID) (bool, error) {
+	if viewerID == userID {
+		return true, nil
+	}
+	viewer, err := s.userRepo.GetByID(ctx, viewerID)
+	if err != nil {
+		return false, err
+	}
+	return viewer.Role == models.RoleAdmin, nil
+}
+
+// GetUser returns what the viewer may see of a user. Deactivated accounts
+// are not shown.
+func (s *PrivacyService) GetUser(ctx context.Context, viewerID, userID uuid.UUID) (*models.PublicUser, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Status == models.UserStatusDeactivated {
+		return nil, errors.New("user not found")
+	}
+
+	users, err := s.PublicUsers(ctx, viewerID, []models.User{*user})
+	if err != nil {
+		return nil, err
+	}
+	return &users[0], nil
+}
+
+// Directory returns a page of the users whose username or display name
+// contains query, or whose email is query and shown to everyone, as the
+// viewer may see them
+func (s *PrivacyService) Directory(ctx context.Context, viewerID uuid.UUID, query string, limit, offset int) (*dtos.UserDirectoryResponse, error) {
+	if limit <= 0 {
+		limit = 50 // Default limit
+	}
+	if limit > 100 {
+		limit = 100 // Max limit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	users, total, err := s.userRepo.Search(ctx, strings.TrimSpace(query), limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	public, err := s.PublicUsers(ctx, viewerID, users)
+	if err != nil {
+		return nil, err
+	}
+	return &dtos.UserDirectoryResponse{Users: public, Total: total, Limit: limit, Offset: offset}, nil
+}
+
+// PublicUsers returns what the viewer may see of each of the users. Admins
+// see every detail, but may only message users who allow them to.
+func (s *PrivacyService) PublicUsers(ctx context.Context, viewerID uuid.UUID, users []models.User) ([]models.PublicUser, error) {
+	viewer, err := s.userRepo.GetByID(ctx, viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uuid.UUID, len(users))
+	for i, user := range users {
+		ids[i] = user.ID
+	}
+	settings, err := s.privacyRepo.GetMany(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	roommates, err := s.roommates(ctx, viewerID, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	public := make([]models.PublicUser, len(users))
+	for i, user := range users {
+		p := settings[user.ID]
+		full := user.ID == viewerID || viewer.Role == models.RoleAdmin
+		roommate := roommates[user.ID]
+
+		public[i] = models.PublicUser{Profile: user.Profile()}
+		// Bots only have placeholder emails
+		if !user.IsBot && (full || visibleTo(p.Email, roommate)) {
+			public[i].Email = user.Email
+		}
+		if full || visibleTo(p.Presence, roommate) {
+			public[i].Presence = presence(&user)
+		}
+		if full || visibleTo(p.LastSeen, roommate) {
+			public[i].LastSeenAt = user.LastSeenAt
+		}
+		public[i].CanMessage = user.ID != viewerID && user.Status == models.UserStatusActive && visibleTo(p.DirectMessages, roommate)
+	}
+	return public, nil
+}
+
+// CanDirectMessage reports whether the sender may send the recipient direct
+// messages under the recipient's settings
+func (s *PrivacyService) CanDirectMessage(ctx context.Context, senderID, recipientID uuid.UUID) (bool, error) {
+	if senderID == recipientID {
+		return false, nil
+	}
+	recipient, err := s.userRepo.GetByID(ctx, recipientID)
+	if err != nil {
+		return false, err
+	}
+	if recipient.Status != models.UserStatusActive {
+		return false, nil
+	}
+
+	settings, err := s.privacyRepo.Get(ctx, recipientID)
+	if err != nil {
+		return false, err
+	}
+	roommates, err := s.roommates(ctx, senderID, []uuid.UUID{recipientID})
+	if err != nil {
+		return false, err
+	}
+	return visibleTo(settings.DirectMessages, roommates[recipientID]), nil
+}
+
+// roommates returns which of the users share a room with the viewer
+func (s *PrivacyService) roommates(ctx context.Context, viewerID uuid.UUID, userIDs []uuid.UUID) (map[uuid.UUID]bool, error) {
+	ids, err := s.roomRepo.GetRoommates(ctx, viewerID, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		result[id] = true
+	}
+	return result, nil
+}
+
+// visibleTo reports whether a setting's audience includes the viewer
+func visibleTo(audience string, roommate bool) bool {
+	switch audience {
+	case models.VisibilityEveryone:
+		return true
+	case models.VisibilityRoomMembers:
+		return roommate
+	}
+	return false
+}
+
+// presence tells whether the user was active within presenceWindow
+func presence(user *models.User) string {
+	if user.Status == models.UserStatusActive && user.LastSeenAt != nil && time.Since(*user.LastSeenAt) < presenceWindow {
+		return models.PresenceOnline
+	}
+	return models.PresenceOffline
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/audit"
+	"github.com/GavinHemsada/go-backend/internal/dtos"
+	"github.com/GavinHemsada/go-backend/internal/models"
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+)
+
+type privacyTest struct {
+	service  *PrivacyService
+	viewer   *models.User
+	roommate *models.User
+	stranger *models.User
+	admin    *models.User
+}
+
+// newPrivacyTest returns a privacy service and a viewer who shares a room
+// with roommate but not with stranger. Everyone was seen a minute ago.
+func newPrivacyTest(t *testing.T, repos *repository.Repositories) *privacyTest {
+	t.Helper()
+	ctx := context.Background()
+	users := make(map[string]*models.User)
+	for _, name := range []string{"viewer", "roommate", "stranger", "admin"} {
+		user, err := repos.Users.Register(ctx, name, name+"@example.com", "hash")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := repos.Users.SetLastSeen(ctx, user.ID, time.Now().Add(-time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+		users[name] = user
+	}
+	if err := repos.Users.SetRole(ctx, users["admin"].ID, models.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+
+	room := &models.Room{Name: "general", CreatedBy: users["viewer"].ID}
+	if err := repos.Rooms.Create(ctx, room); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"viewer", "roommate"} {
+		if err := repos.Rooms.AddMember(ctx, room.ID, users[name].ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return &privacyTest{
+		service:  NewPrivacyService(repos.Users, repos.Rooms, repos.Privacy, audit.NewLogger(repos.Audit)),
+		viewer:   users["viewer"],
+		roommate: users["roommate"],
+		stranger: users["stranger"],
+		admin:    users["admin"],
+	}
+}
+
+// update changes a user's privacy settings
+func (pt *privacyTest) update(t *testing.T, user *models.User, req dtos.UpdatePrivacyDto) {
+	t.Helper()
+	if _, err := pt.service.UpdateSettings(context.Background(), user.ID, req); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// seen returns what viewer sees of user in a profile and in the directory,
+// searching by username and by email
+func (pt *privacyTest) seen(t *testing.T, viewer, user *models.User) []models.PublicUser {
+	t.Helper()
+	ctx := context.Background()
+	profile, err := pt.service.GetUser(ctx, viewer.ID, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := []models.PublicUser{*profile}
+
+	for _, query := range []string{"", user.Username, user.Email} {
+		page, err := pt.service.Directory(ctx, viewer.ID, query, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, listed := range page.Users {
+			if listed.ID == user.ID {
+				seen = append(seen, listed)
+			}
+		}
+	}
+	return seen
+}
+
+// checkShown fails unless every view of the user shows the email and last seen
+// time exactly when wanted
+func checkShown(t *testing.T, seen []models.PublicUser, wantEmail, wantLastSeen bool) {
+	t.Helper()
+	for i, user := range seen {
+		if (user.Email != "") != wantEmail {
+			t.Errorf("view %d of %s: got email %q, want shown %v", i, user.Username, user.Email, wantEmail)
+		}
+		if (user.LastSeenAt != nil) != wantLastSeen {
+			t.Errorf("view %d of %s: got last seen %v, want shown %v", i, user.Username, user.LastSeenAt, wantLastSeen)
+		}
+	}
+}
+
+// By default emails are shown to nobody and last seen times to room members
+func TestPrivacyDefaults(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		pt := newPrivacyTest(t, repos)
+
+		roommate := pt.seen(t, pt.viewer, pt.roommate)
+		if len(roommate) != 3 {
+			t.Fatalf("got %d views of the roommate, want the profile and two directory pages", len(roommate))
+		}
+		checkShown(t, roommate, false, true)
+
+		// Emails hidden from everyone are not searchable either
+		stranger := pt.seen(t, pt.viewer, pt.stranger)
+		if len(stranger) != 3 {
+			t.Fatalf("got %d views of the stranger, want the profile and two directory pages", len(stranger))
+		}
+		checkShown(t, stranger, false, false)
+	})
+}
+
+func TestPrivacySettingsApply(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		everyone, roomMembers, nobody := models.VisibilityEveryone, models.VisibilityRoomMembers, models.VisibilityNobody
+
+		pt := newPrivacyTest(t, repos)
+		pt.update(t, pt.roommate, dtos.UpdatePrivacyDto{Email: &roomMembers, LastSeen: &nobody})
+		pt.update(t, pt.stranger, dtos.UpdatePrivacyDto{Email: &everyone, LastSeen: &everyone})
+
+		checkShown(t, pt.seen(t, pt.viewer, pt.roommate), true, false)
+		checkShown(t, pt.seen(t, pt.stranger, pt.roommate), false, false)
+
+		// An email shown to everyone can be searched for
+		stranger := pt.seen(t, pt.viewer, pt.stranger)
+		if len(stranger) != 4 {
+			t.Fatalf("got %d views of the stranger, want the profile and three directory pages", len(stranger))
+		}
+		checkShown(t, stranger, true, true)
+
+		pt.update(t, pt.stranger, dtos.UpdatePrivacyDto{Email: &roomMembers})
+		checkShown(t, pt.seen(t, pt.viewer, pt.stranger), false, true)
+	})
+}
+
+// Users see all of their own details and admins see everyone's
+func TestPrivacyFullAccess(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		nobody := models.VisibilityNobody
+		pt := newPrivacyTest(t, repos)
+		pt.update(t, pt.stranger, dtos.UpdatePrivacyDto{Email: &nobody, LastSeen: &nobody})
+
+		checkShown(t, pt.seen(t, pt.stranger, pt.stranger), true, true)
+		checkShown(t, pt.seen(t, pt.admin, pt.stranger), true, true)
+		checkShown(t, pt.seen(t, pt.viewer, pt.stranger), false, false)
+
+		for _, c := range []struct {
+			viewer *models.User
+			want   bool
+		}{
+			{pt.stranger, true},
+			{pt.admin, true},
+			{pt.viewer, false},
+			{pt.roommate, false},
+		} {
+			full, err := pt.service.FullAccess(ctx, c.viewer.ID, pt.stranger.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if full != c.want {
+				t.Errorf("%s: got full access %v, want %v", c.viewer.Username, full, c.want)
+			}
+		}
+	})
+}
+
+func TestPrivacyRejectsUnknownAudience(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		pt := newPrivacyTest(t, repos)
+		friends := "friends"
+		if _, err := pt.service.UpdateSettings(context.Background(), pt.viewer.ID, dtos.UpdatePrivacyDto{Email: &friends}); err == nil {
+			t.Error("an unknown audience was accepted")
+		}
+	})
+}
@@ -0,0 +1,296 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // time zones are checked the same on every host
+	"unicode"
+
+	"github.com/GavinHemsada/go-backend/internal/audit"
+	"github.com/GavinHemsada/go-backend/internal/dtos"
+	"github.com/GavinHemsada/go-backend/internal/models"
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+	"github.com/google/uuid"
+)
+
+const (
+	// Longest profile fields, in characters.
+	maxBioLength          = 500
+	maxPronounsLength     = 40
+	maxStatusTextLength   = 100
+	maxStatusEmojiLength  = 32
+	maxTimeZoneNameLength = 64
+
+	// Largest avatar accepted, in bytes and pixels per side.
+	MaxAvatarSize      = 1 << 20
+	maxAvatarDimension = 4096
+)
+
+// ProfileService lets users edit what others see of them. Changes are pushed
+// to the rooms they are in as "profile_updated" events.
+type ProfileService struct {
+	userRepo   repository.UserRepository
+	roomRepo   repository.RoomRepository
+	avatarRepo repository.AvatarRepository
+	notifier   RoomNotifier
+	auditLog   *audit.Logger
+}
+
+func NewProfileService(userRepo repository.UserRepository, roomRepo repository.RoomRepository, avatarRepo repository.AvatarRepository, notifier RoomNotifier, auditLog *audit.Logger) *ProfileService {
+	return &ProfileService{
+		userRepo:   userRepo,
+		roomRepo:   roomRepo,
+		avatarRepo: avatarRepo,
+		notifier:   notifier,
+		auditLog:   auditLog,
+	}
+}
+
+// UpdateProfile changes the fields set in req and returns the updated user
+func (s *ProfileService) UpdateProfile(ctx context.Context, userID uuid.UUID, req dtos.UpdateProfileDto) (*models.User, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	changed, err := s.apply(ctx, user, req)
+	if err != nil || len(changed) == 0 {
+		return user, err
+	}
+
+	err = s.userRepo.UpdateProfile(ctx, user)
+	s.auditLog.Record(ctx, audit.Outcome(models.AuditEvent{
+		ActorID:    &userID,
+		Action:     models.AuditProfileUpdate,
+		TargetType: models.AuditTargetUser,
+		TargetID:   userID.String(),
+		Details:    strings.Join(changed, ", "),
+	}, err))
+	if err != nil {
+		return nil, err
+	}
+
+	s.publish(ctx, user)
+	return user, nil
+}
+
+// apply validates the fields set in req and copies them to user, returning
+// the names of those that changed
+func (s *ProfileService) apply(ctx context.Context, user *models.User, req dtos.UpdateProfileDto) ([]string, error) {
+	var changed []string
+
+	if req.Username != nil {
+		username := strings.TrimSpace(*req.Username)
+		if username == "" {
+			return nil, errors.New("username is required")
+		}
+		if len(username) > maxUsernameLength {
+			return nil, errors.New("username must be at most 50 characters")
+		}
+		if strings.Contains(username, "@") {
+			return nil, errors.New("username cannot contain @")
+		}
+		if username != user.Username {
+			if _, err := s.userRepo.GetByIdentifier(ctx, username); err == nil {
+				return nil, errors.New("username already exists")
+			}
+			user.Username = username
+			changed = append(changed, "username")
+		}
+	}
+
+	fields := []struct {
+		name  string
+		value *string
+		max   int
+		field *string
+	}{
+		{"display name", req.DisplayName, maxDisplayNameLength, &user.DisplayName},
+		{"bio", req.Bio, maxBioLength, &user.Bio},
+		{"pronouns", req.Pronouns, maxPronounsLength, &user.Pronouns},
+	}
+	for _, f := range fields {
+		if f.value == nil {
+			continue
+		}
+		value := strings.TrimSpace(*f.value)
+		if len([]rune(value)) > f.max {
+			return nil, errors.New(f.name + " must be at most " + strconv.Itoa(f.max) + " characters")
+		}
+		if value != *f.field {
+			*f.field = value
+			changed = append(changed, f.name)
+		}
+	}
+
+	if req.TimeZone != nil {
+		timeZone := strings.TrimSpace(*req.TimeZone)
+		if err := checkTimeZone(timeZone); err != nil {
+			return nil, err
+		}
+		if timeZone != user.TimeZone {
+			user.TimeZone = timeZone
+			changed = append(changed, "time zone")
+		}
+	}
+
+	if req.Status != nil {
+		text := strings.TrimSpace(req.Status.Text)
+		emoji := strings.TrimSpace(req.Status.Emoji)
+		expires := req.Status.ExpiresAt
+		if len([]rune(text)) > maxStatusTextLength {
+			return nil, errors.New("status text must be at most 100 characters")
+		}
+		if err := checkEmoji(emoji); err != nil {
+			return nil, err
+		}
+		if text == "" && emoji == "" {
+			expires = nil
+		} else if expires != nil {
+			if !expires.After(time.Now()) {
+				return nil, errors.New("status expiry must be in the future")
+			}
+			utc := expires.UTC()
+			expires = &utc
+		}
+
+		// An expired status counts as none
+		current := user.CustomStatus()
+		if current == nil {
+			current = &models.CustomStatus{}
+		}
+		if text != current.Text || emoji != current.Emoji || !sameTime(expires, current.ExpiresAt) {
+			user.StatusText, user.StatusEmoji, user.StatusExpires = text, emoji, expires
+			changed = append(changed, "custom status")
+		}
+	}
+
+	return changed, nil
+}
+
+// SetAvatar replaces the user's avatar with a PNG, JPEG or GIF image
+func (s *ProfileService) SetAvatar(ctx context.Context, userID uuid.UUID, data []byte) (*models.User, error) {
+	if len(data) > MaxAvatarSize {
+		return nil, errors.New("avatar must be at most 1 MiB")
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.New("avatar must be a PNG, JPEG or GIF image")
+	}
+	if config.Width < 1 || config.Height < 1 || config.Width > maxAvatarDimension || config.Height > maxAvatarDimension {
+		return nil, errors.New("avatar must be at most 4096 pixels wide and high")
+	}
+
+	avatar := &models.Avatar{
+		ID:          uuid.New(),
+		UserID:      userID,
+		ContentType: "image/" + format,
+		Data:        data,
+	}
+	return s.changeAvatar(ctx, userID, "avatar", func() error {
+		return s.avatarRepo.Set(ctx, avatar)
+	})
+}
+
+// DeleteAvatar removes the user's avatar
+func (s *ProfileService) DeleteAvatar(ctx context.Context, userID uuid.UUID) (*models.User, error) {
+	return s.changeAvatar(ctx, userID, "avatar removed", func() error {
+		return s.avatarRepo.Delete(ctx, userID)
+	})
+}
+
+// GetAvatar returns an uploaded avatar by its ID
+func (s *ProfileService) GetAvatar(ctx context.Context, id uuid.UUID) (*models.Avatar, error) {
+	return s.avatarRepo.Get(ctx, id)
+}
+
+// changeAvatar runs change, audits it and publishes the user's new profile
+func (s *ProfileService) changeAvatar(ctx context.Context, userID uuid.UUID, details string, change func() error) (*models.User, error) {
+	err := change()
+	s.auditLog.Record(ctx, audit.Outcome(models.AuditEvent{
+		ActorID:    &userID,
+		Action:     models.AuditProfileUpdate,
+		TargetType: models.AuditTargetUser,
+		TargetID:   userID.String(),
+		Details:    details,
+	}, err))
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	s.publish(ctx, user)
+	return user, nil
+}
+
+// publish sends the user's new profile to every room they are in
+func (s *ProfileService) publish(ctx context.Context, user *models.User) {
+	if s.notifier == nil {
+		return
+	}
+
+	rooms, err := s.roomRepo.GetUserRooms(ctx, user.ID)
+	if err != nil {
+		log.Printf("Failed to list rooms of user %s for a profile update: %v", user.ID, err)
+		return
+	}
+
+	profile := user.Profile()
+	for _, room := range rooms {
+		s.notifier.NotifyRoom(room.ID, models.WSMessageResponse{
+			Type:      "profile_updated",
+			UserID:    user.ID.String(),
+			Username:  user.Username,
+			RoomID:    room.ID.String(),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+			Profile:   &profile,
+		})
+	}
+}
+
+// checkTimeZone accepts IANA time zone names and "" for none
+func checkTimeZone(name string) error {
+	if name == "" {
+		return nil
+	}
+	if len(name) > maxTimeZoneNameLength || name == "Local" {
+		return errors.New("unknown time zone")
+	}
+	if _, err := time.LoadLocation(name); err != nil {
+		return errors.New("unknown time zone")
+	}
+	return nil
+}
+
+// checkEmoji accepts a short run of symbols without letters or spaces, such
+// as an emoji with its modifiers
+func checkEmoji(emoji string) error {
+	if len([]rune(emoji)) > maxStatusEmojiLength {
+		return errors.New("status emoji must be at most 32 characters")
+	}
+	for _, r := range emoji {
+		if unicode.IsLetter(r) || unicode.IsSpace(r) || unicode.IsControl(r) {
+			return errors.New("status emoji must be an emoji")
+		}
+	}
+	return nil
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
@@ -0,0 +1,377 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/dtos"
+	"github.com/GavinHemsada/go-backend/internal/models"
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+	"github.com/google/uuid"
+)
+
+// Reasons a message or user can be reported for
+var reportCategories = map[string]bool{
+	"spam":          true,
+	"harassment":    true,
+	"hate_speech":   true,
+	"sexual":        true,
+	"violence":      true,
+	"impersonation": true,
+	"other":         true,
+}
+
+// Actions a moderator can take when resolving a report
+const (
+	ReportActionNone          = "none"
+	ReportActionDeleteMessage = "delete_message"
+	ReportActionKick          = "kick"
+	ReportActionBan           = "ban"
+	ReportActionMute          = "mute"
+)
+
+type ReportService struct {
+	reportRepo  repository.ReportRepository
+	messageRepo repository.MessageRepository
+	roomRepo    repository.RoomRepository
+	moderation  *ModerationService
+	txManager   repository.TxManager
+}
+
+func NewReportService(reportRepo repository.ReportRepository, messageRepo repository.MessageRepository, roomRepo repository.RoomRepository, moderation *ModerationService, txManager repository.TxManager) *ReportService {
+	return &ReportService{
+		reportRepo:  reportRepo,
+		messageRepo: messageRepo,
+		roomRepo:    roomRepo,
+		moderation:  moderation,
+		txManager:   txManager,
+	}
+}
+
+// ReportMessage files a report on a message for the moderators of its room.
+// Deleted messages cannot be reported.
+func (s *ReportService) ReportMessage(ctx context.Context, roomID, messageID, reporterID uuid.UUID, category, details string) (*models.Report, error) {
+	if !reportCategories[category] {
+		return nil, errors.New("invalid report category")
+	}
+
+	var report *models.Report
+	err := s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		// GetByID treats deleted messages as missing
+		message, err := s.messageRepo.GetByID(ctx, messageID)
+		if err != nil {
+			return err
+		}
+
+		if message.RoomID != roomID {
+			return errors.New("message not found")
+		}
+
+		if message.UserID == reporterID {
+			return errors.New("you cannot report yourself")
+		}
+
+		report = &models.Report{
+			RoomID:         roomID,
+			ReporterID:     &reporterID,
+			TargetType:     models.ReportTargetMessage,
+			MessageID:      &message.ID,
+			TargetUserID:   message.UserID,
+			MessageContent: message.Content,
+			Category:       category,
+			Details:        details,
+		}
+		return s.file(ctx, report)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// ReportUser files a report on a member of a room for the room's moderators.
+// Every report belongs to a room's queue: there are no reports on a user
+// outside of a room they share with the reporter.
+func (s *ReportService) ReportUser(ctx context.Context, roomID, targetID, reporterID uuid.UUID, category, details string) (*models.Report, error) {
+	if !reportCategories[category] {
+		return nil, errors.New("invalid report category")
+	}
+
+	if targetID == reporterID {
+		return nil, errors.New("you cannot report yourself")
+	}
+
+	report := &models.Report{
+		RoomID:       roomID,
+		ReporterID:   &reporterID,
+		TargetType:   models.ReportTargetUser,
+		TargetUserID: targetID,
+		Category:     category,
+		Details:      details,
+	}
+
+	err := s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		isMember, err := s.roomRepo.IsMember(ctx, roomID, targetID)
+		if err != nil {
+			return err
+		}
+
+		if !isMember {
+			return errors.New("reported user is not a member of this room")
+		}
+
+		return s.file(ctx, report)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// file stores a report from a room member, once per reporter and target while it is pending
+func (s *ReportService) file(ctx context.Context, report *models.Report) error {
+	isMember, err := s.roomRepo.IsMember(ctx, report.RoomID, *report.ReporterID)
+	if err != nil {
+		return err
+	}
+
+	if !isMember {
+		return errors.New("user is not a member of this room")
+	}
+
+	pending, err := s.reportRepo.HasPending(ctx, report)
+	if err != nil {
+		return err
+	}
+
+	if pending {
+		return errors.New("you have already reported this")
+	}
+
+	if err := s.reportRepo.Create(ctx, report); err != nil {
+		return err
+	}
+
+	return s.record(ctx, report.ID, *report.ReporterID, "created", report.Details)
+}
+
+// GetQueue lists a room's reports for its moderators, oldest first. An empty status lists every report.
+func (s *ReportService) GetQueue(ctx context.Context, roomID, actorID uuid.UUID, status string, limit, offset int) ([]models.Report, error) {
+	limit, offset, err := queuePage(status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.moderation.requireModerator(ctx, roomID, actorID); err != nil {
+		return nil, err
+	}
+
+	return s.reportRepo.GetByRoom(ctx, roomID, status, limit, offset)
+}
+
+// GetAllQueues lists the reports of every room, oldest first, for global
+// admins. An empty status lists every report.
+func (s *ReportService) GetAllQueues(ctx context.Context, status string, limit, offset int) ([]models.Report, error) {
+	limit, offset, err := queuePage(status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.reportRepo.List(ctx, status, limit, offset)
+}
+
+// queuePage checks a queue's status filter and clamps its page
+func queuePage(status string, limit, offset int) (int, int, error) {
+	switch status {
+	case "", models.ReportStatusOpen, models.ReportStatusClaimed, models.ReportStatusResolved, models.ReportStatusDismissed:
+	default:
+		return 0, 0, errors.New("invalid report status")
+	}
+
+	if limit <= 0 {
+		limit = 50 // Default limit
+	}
+	if limit > 100 {
+		limit = 100 // Max limit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return limit, offset, nil
+}
+
+// GetReport returns a report of the room with its audit trail
+func (s *ReportService) GetReport(ctx context.Context, roomID, reportID, actorID uuid.UUID) (*dtos.ReportDetails, error) {
+	if err := s.moderation.requireModerator(ctx, roomID, actorID); err != nil {
+		return nil, err
+	}
+
+	report, err := s.getRoomReport(ctx, roomID, reportID)
+	if err != nil {
+		return nil, err
+	}
+
+	actions, err := s.reportRepo.GetActions(ctx, reportID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dtos.ReportDetails{Report: *report, Actions: actions}, nil
+}
+
+// Claim assigns an open report to the acting moderator so others leave it alone
+func (s *ReportService) Claim(ctx context.Context, roomID, reportID, actorID uuid.UUID) (*models.Report, error) {
+	if err := s.moderation.requireModerator(ctx, roomID, actorID); err != nil {
+		return nil, err
+	}
+
+	var report *models.Report
+	err := s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		if _, err := s.getRoomReport(ctx, roomID, reportID); err != nil {
+			return err
+		}
+
+		if err := s.reportRepo.Claim(ctx, reportID, actorID); err != nil {
+			return err
+		}
+
+		if err := s.record(ctx, reportID, actorID, "claimed", ""); err != nil {
+			return err
+		}
+
+		var err error
+		report, err = s.reportRepo.GetByID(ctx, reportID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// Resolve takes action on a report and closes it. Ban and mute use duration
+// (a zero ban duration bans permanently); the note becomes their reason.
+func (s *ReportService) Resolve(ctx context.Context, roomID, reportID, actorID uuid.UUID, action string, duration time.Duration, note string) (*models.Report, error) {
+	switch action {
+	case ReportActionNone, ReportActionDeleteMessage, ReportActionKick, ReportActionBan, ReportActionMute:
+	default:
+		return nil, errors.New("invalid report action")
+	}
+
+	return s.close(ctx, roomID, reportID, actorID, models.ReportStatusResolved, action, note, func(ctx context.Context, report *models.Report) error {
+		return s.apply(ctx, report, actorID, action, duration, note)
+	})
+}
+
+// Dismiss closes a report without taking action
+func (s *ReportService) Dismiss(ctx context.Context, roomID, reportID, actorID uuid.UUID, note string) (*models.Report, error) {
+	return s.close(ctx, roomID, reportID, actorID, models.ReportStatusDismissed, ReportActionNone, note, nil)
+}
+
+// close runs apply and moves the report to status in one transaction, recording who did it
+func (s *ReportService) close(ctx context.Context, roomID, reportID, actorID uuid.UUID, status, resolution, note string, apply func(ctx context.Context, report *models.Report) error) (*models.Report, error) {
+	if err := s.moderation.requireModerator(ctx, roomID, actorID); err != nil {
+		return nil, err
+	}
+
+	var report *models.Report
+	err := s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		report, err = s.getRoomReport(ctx, roomID, reportID)
+		if err != nil {
+			return err
+		}
+
+		// Checked up front as well as by Close so no action is taken on a report that cannot be closed
+		switch {
+		case report.Status == models.ReportStatusResolved || report.Status == models.ReportStatusDismissed:
+			return errors.New("report is already closed")
+		case report.Status == models.ReportStatusClaimed && (report.ClaimedBy == nil || *report.ClaimedBy != actorID):
+			return errors.New("report is claimed by another moderator")
+		}
+
+		if apply != nil {
+			if err := apply(ctx, report); err != nil {
+				return err
+			}
+		}
+
+		if err := s.reportRepo.Close(ctx, reportID, actorID, status, resolution, note); err != nil {
+			return err
+		}
+
+		if err := s.record(ctx, reportID, actorID, status, note); err != nil {
+			return err
+		}
+
+		report, err = s.reportRepo.GetByID(ctx, reportID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// apply carries out a resolution action through the message and moderation operations
+func (s *ReportService) apply(ctx context.Context, report *models.Report, actorID uuid.UUID, action string, duration time.Duration, note string) error {
+	if action == ReportActionNone {
+		return nil
+	}
+
+	if action == ReportActionDeleteMessage {
+		if report.MessageID == nil {
+			return errors.New("report has no message to delete")
+		}
+		return s.messageRepo.Delete(ctx, *report.MessageID)
+	}
+
+	if report.TargetUserID == uuid.Nil {
+		return errors.New("reported user no longer exists")
+	}
+
+	reason := note
+	if reason == "" {
+		reason = report.Category
+	}
+
+	var err error
+	switch action {
+	case ReportActionKick:
+		err = s.moderation.Kick(ctx, report.RoomID, actorID, report.TargetUserID)
+	case ReportActionBan:
+		_, err = s.moderation.Ban(ctx, report.RoomID, actorID, report.TargetUserID, duration, reason)
+	case ReportActionMute:
+		_, err = s.moderation.Mute(ctx, report.RoomID, actorID, report.TargetUserID, duration, reason)
+	}
+	return err
+}
+
+// getRoomReport loads a report, treating reports of other rooms as missing
+func (s *ReportService) getRoomReport(ctx context.Context, roomID, reportID uuid.UUID) (*models.Report, error) {
+	report, err := s.reportRepo.GetByID(ctx, reportID)
+	if err != nil {
+		return nil, err
+	}
+
+	if report.RoomID != roomID {
+		return nil, errors.New("report not found")
+	}
+
+	return report, nil
+}
+
+// record appends an entry to the report's audit trail
+func (s *ReportService) record(ctx context.Context, reportID, actorID uuid.UUID, action, note string) error {
+	return s.reportRepo.AddAction(ctx, &models.ReportAction{
+		ReportID: reportID,
+		ActorID:  &actorID,
+		Action:   action,
+		Note:     note,
+	})
+}
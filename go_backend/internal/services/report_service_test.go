@@ -0,0 +1,281 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+	"github.com/google/uuid"
+)
+
+// disconnectRecorder records the users it disconnects
+type disconnectRecorder struct {
+	users []uuid.UUID
+}
+
+func (d *disconnectRecorder) DisconnectUser(roomID, userID uuid.UUID, reason string) {
+	d.users = append(d.users, userID)
+}
+
+func (d *disconnectRecorder) NotifyUser(roomID, userID uuid.UUID, event models.WSMessageResponse) {}
+
+var errCloseFailed = errors.New("close failed")
+
+// failingClose fails to close reports, after the resolution action has run
+type failingClose struct {
+	repository.ReportRepository
+}
+
+func (f failingClose) Close(ctx context.Context, id, actorID uuid.UUID, status, resolution, note string) error {
+	return errCloseFailed
+}
+
+type reportTest struct {
+	service   *ReportService
+	room      *models.Room
+	moderator *models.User
+	member    *models.User
+	reporter  *models.User
+	message   *models.Message
+}
+
+// newReportTest returns a report service on reportRepo and a room with a
+// message from member that reporter can report
+func newReportTest(t *testing.T, repos *repository.Repositories, reportRepo repository.ReportRepository, enforcer ModerationEnforcer) *reportTest {
+	t.Helper()
+	ctx := context.Background()
+	moderation, room, moderator, member := newModerationTest(t, repos, repos.Audit)
+	moderation.enforcer = enforcer
+
+	reporter, err := repos.Users.Register(ctx, "reporter", "reporter@example.com", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repos.Rooms.AddMember(ctx, room.ID, reporter.ID); err != nil {
+		t.Fatal(err)
+	}
+	message := &models.Message{RoomID: room.ID, UserID: member.ID, Content: "buy now", MessageType: "text"}
+	if err := repos.Messages.Create(ctx, message); err != nil {
+		t.Fatal(err)
+	}
+
+	return &reportTest{
+		service:   NewReportService(reportRepo, repos.Messages, repos.Rooms, moderation, repos.TxManager),
+		room:      room,
+		moderator: moderator,
+		member:    member,
+		reporter:  reporter,
+		message:   message,
+	}
+}
+
+func TestReportMessage(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		rt := newReportTest(t, repos, repos.Reports, nil)
+
+		report, err := rt.service.ReportMessage(ctx, rt.room.ID, rt.message.ID, rt.reporter.ID, "spam", "ad")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Status != models.ReportStatusOpen || report.TargetUserID != rt.member.ID || report.MessageContent != "buy now" {
+			t.Errorf("got %+v, want an open report on the member's message", report)
+		}
+
+		if _, err := rt.service.ReportMessage(ctx, rt.room.ID, rt.message.ID, rt.reporter.ID, "spam", "again"); err == nil {
+			t.Error("the same message was reported twice while the first report was pending")
+		}
+		if _, err := rt.service.ReportMessage(ctx, rt.room.ID, rt.message.ID, rt.member.ID, "spam", ""); err == nil {
+			t.Error("a user reported their own message")
+		}
+		if _, err := rt.service.ReportMessage(ctx, uuid.New(), rt.message.ID, rt.moderator.ID, "spam", ""); err == nil {
+			t.Error("a message was reported in a room it was not posted in")
+		}
+		if _, err := rt.service.ReportMessage(ctx, rt.room.ID, rt.message.ID, rt.moderator.ID, "boring", ""); err == nil {
+			t.Error("a report was filed with an unknown category")
+		}
+
+		if err := repos.Messages.Delete(ctx, rt.message.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := rt.service.ReportMessage(ctx, rt.room.ID, rt.message.ID, rt.moderator.ID, "spam", ""); err == nil {
+			t.Error("a deleted message was reported")
+		}
+	})
+}
+
+func TestReportUser(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		rt := newReportTest(t, repos, repos.Reports, nil)
+
+		report, err := rt.service.ReportUser(ctx, rt.room.ID, rt.member.ID, rt.reporter.ID, "harassment", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.TargetType != models.ReportTargetUser || report.MessageID != nil {
+			t.Errorf("got %+v, want a report on the user", report)
+		}
+
+		outsider, err := repos.Users.Register(ctx, "outsider", "outsider@example.com", "hash")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := rt.service.ReportUser(ctx, rt.room.ID, outsider.ID, rt.reporter.ID, "spam", ""); err == nil {
+			t.Error("a user was reported in a room they are not a member of")
+		}
+		if _, err := rt.service.ReportUser(ctx, rt.room.ID, rt.member.ID, outsider.ID, "spam", ""); err == nil {
+			t.Error("a user outside the room filed a report in it")
+		}
+	})
+}
+
+func TestReportQueues(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		rt := newReportTest(t, repos, repos.Reports, nil)
+
+		other := &models.Room{Name: "other", CreatedBy: rt.reporter.ID}
+		if err := repos.Rooms.Create(ctx, other); err != nil {
+			t.Fatal(err)
+		}
+		if err := repos.Rooms.AddMember(ctx, other.ID, rt.member.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		first, err := rt.service.ReportMessage(ctx, rt.room.ID, rt.message.ID, rt.reporter.ID, "spam", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := rt.service.ReportUser(ctx, other.ID, rt.member.ID, rt.reporter.ID, "spam", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := rt.service.Dismiss(ctx, other.ID, second.ID, rt.reporter.ID, ""); err != nil {
+			t.Fatal(err)
+		}
+
+		queue, err := rt.service.GetQueue(ctx, rt.room.ID, rt.moderator.ID, "", 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(queue) != 1 || queue[0].ID != first.ID {
+			t.Errorf("got %+v, want the room's report only", queue)
+		}
+		if _, err := rt.service.GetQueue(ctx, rt.room.ID, rt.reporter.ID, "", 0, 0); err == nil {
+			t.Error("a member who does not moderate the room read its queue")
+		}
+		if _, err := rt.service.GetQueue(ctx, rt.room.ID, rt.moderator.ID, "closed", 0, 0); err == nil {
+			t.Error("the queue was listed with an unknown status")
+		}
+
+		all, err := rt.service.GetAllQueues(ctx, "", 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(all) != 2 || all[0].ID != first.ID || all[1].ID != second.ID {
+			t.Errorf("got %+v, want the reports of both rooms, oldest first", all)
+		}
+
+		open, err := rt.service.GetAllQueues(ctx, models.ReportStatusOpen, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(open) != 1 || open[0].ID != first.ID {
+			t.Errorf("got %+v, want only the open report", open)
+		}
+
+		page, err := rt.service.GetAllQueues(ctx, "", 1, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) != 1 || page[0].ID != second.ID {
+			t.Errorf("got %+v, want the second report", page)
+		}
+	})
+}
+
+func TestResolveReport(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		enforcer := &disconnectRecorder{}
+		rt := newReportTest(t, repos, repos.Reports, enforcer)
+
+		report, err := rt.service.ReportMessage(ctx, rt.room.ID, rt.message.ID, rt.reporter.ID, "spam", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		claimed, err := rt.service.Claim(ctx, rt.room.ID, report.ID, rt.moderator.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if claimed.Status != models.ReportStatusClaimed || claimed.ClaimedBy == nil || *claimed.ClaimedBy != rt.moderator.ID {
+			t.Errorf("got %+v, want the report claimed by the moderator", claimed)
+		}
+
+		resolved, err := rt.service.Resolve(ctx, rt.room.ID, report.ID, rt.moderator.ID, ReportActionBan, time.Hour, "ads")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resolved.Status != models.ReportStatusResolved || resolved.Resolution != ReportActionBan {
+			t.Errorf("got %+v, want the report resolved with a ban", resolved)
+		}
+		if ban, err := repos.Moderation.GetBan(ctx, rt.room.ID, rt.member.ID); err != nil || ban == nil {
+			t.Errorf("got %+v, %v, want the member banned", ban, err)
+		}
+		if len(enforcer.users) != 1 || enforcer.users[0] != rt.member.ID {
+			t.Errorf("disconnected %v, want the member once", enforcer.users)
+		}
+
+		if _, err := rt.service.Dismiss(ctx, rt.room.ID, report.ID, rt.moderator.ID, ""); err == nil {
+			t.Error("a closed report was dismissed")
+		}
+
+		details, err := rt.service.GetReport(ctx, rt.room.ID, report.ID, rt.moderator.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var trail []string
+		for _, action := range details.Actions {
+			trail = append(trail, action.Action)
+		}
+		if len(trail) != 3 || trail[0] != "created" || trail[1] != "claimed" || trail[2] != models.ReportStatusResolved {
+			t.Errorf("got the trail %v, want created, claimed, resolved", trail)
+		}
+	})
+}
+
+// A resolution that fails to commit takes no action, live connections included
+func TestResolveReportRollsBack(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		enforcer := &disconnectRecorder{}
+		rt := newReportTest(t, repos, failingClose{repos.Reports}, enforcer)
+
+		report, err := rt.service.ReportUser(ctx, rt.room.ID, rt.member.ID, rt.reporter.ID, "spam", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, action := range []string{ReportActionKick, ReportActionBan} {
+			_, err := rt.service.Resolve(ctx, rt.room.ID, report.ID, rt.moderator.ID, action, 0, "")
+			if !errors.Is(err, errCloseFailed) {
+				t.Fatalf("%s: got %v, want %v", action, err, errCloseFailed)
+			}
+		}
+
+		if len(enforcer.users) != 0 {
+			t.Errorf("disconnected %v, want nobody", enforcer.users)
+		}
+		if isMember, err := repos.Rooms.IsMember(ctx, rt.room.ID, rt.member.ID); err != nil || !isMember {
+			t.Errorf("got %v, %v, want the member still in the room", isMember, err)
+		}
+		if ban, err := repos.Moderation.GetBan(ctx, rt.room.ID, rt.member.ID); err != nil || ban != nil {
+			t.Errorf("got %+v, %v, want no ban", ban, err)
+		}
+	})
+}
@@ -4,27 +4,41 @@ import (
 	"context"
 	"errors"
 
+	"github.com/GavinHemsada/go-backend/internal/audit"
 	"github.com/GavinHemsada/go-backend/internal/models"
 	repository "github.com/GavinHemsada/go-backend/internal/repositories"
 	"github.com/google/uuid"
 )
 
 type RoomService struct {
-	roomRepo *repository.RoomRepository
+	roomRepo       repository.RoomRepository
+	moderationRepo repository.ModerationRepository
+	txManager      repository.TxManager
+	email          *EmailService
+	auditLog       *audit.Logger
 }
 
-func NewRoomService(roomRepo *repository.RoomRepository) *RoomService {
+func NewRoomService(roomRepo repository.RoomRepository, moderationRepo repository.ModerationRepository, txManager repository.TxManager, email *EmailService, auditLog *audit.Logger) *RoomService {
 	return &RoomService{
-		roomRepo: roomRepo,
+		roomRepo:       roomRepo,
+		moderationRepo: moderationRepo,
+		txManager:      txManager,
+		email:          email,
+		auditLog:       auditLog,
 	}
 }
 
-// CreateRoom creates a new room
+// CreateRoom creates a new room. Creators become members, so the email
+// verification policy applies as it does to joining.
 func (s *RoomService) CreateRoom(ctx context.Context, name, roomType string, createdBy uuid.UUID) (*models.Room, error) {
 	if name == "" {
 		return nil, errors.New("room name is required")
 	}
 
+	if err := s.email.RequireVerified(ctx, createdBy); err != nil {
+		return nil, err
+	}
+
 	if roomType == "" {
 		roomType = "public" // Default room type
 	}
@@ -40,6 +54,13 @@ func (s *RoomService) CreateRoom(ctx context.Context, name, roomType string, cre
 		return nil, err
 	}
 
+	s.auditLog.Record(ctx, models.AuditEvent{
+		ActorID:    &createdBy,
+		Action:     models.AuditRoomCreate,
+		TargetType: models.AuditTargetRoom,
+		TargetID:   room.ID.String(),
+		Details:    room.Name,
+	})
 	return room, nil
 }
 
@@ -60,26 +81,57 @@ func (s *RoomService) GetUserRooms(ctx context.Context, userID uuid.UUID) ([]mod
 
 // DeleteRoom deletes a room (only creator can delete)
 func (s *RoomService) DeleteRoom(ctx context.Context, roomID, userID uuid.UUID) error {
-	return s.roomRepo.Delete(ctx, roomID, userID)
+	err := s.roomRepo.Delete(ctx, roomID, userID)
+	s.record(ctx, models.AuditRoomDelete, roomID, userID, err)
+	return err
 }
 
-// JoinRoom adds a user to a room
+// JoinRoom adds a user to a room. The policy may require a verified email.
 func (s *RoomService) JoinRoom(ctx context.Context, roomID, userID uuid.UUID) error {
-	// Check if room exists
-	_, err := s.roomRepo.GetByID(ctx, roomID)
-	if err != nil {
-		return err
-	}
-
-	return s.roomRepo.AddMember(ctx, roomID, userID)
+	err := s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.email.RequireVerified(ctx, userID); err != nil {
+			return err
+		}
+
+		// Check if room exists
+		_, err := s.roomRepo.GetByID(ctx, roomID)
+		if err != nil {
+			return err
+		}
+
+		ban, err := s.moderationRepo.GetBan(ctx, roomID, userID)
+		if err != nil {
+			return err
+		}
+
+		if ban != nil {
+			return errors.New("you are banned from this room")
+		}
+
+		return s.roomRepo.AddMember(ctx, roomID, userID)
+	})
+	s.record(ctx, models.AuditRoomJoin, roomID, userID, err)
+	return err
 }
 
 // LeaveRoom removes a user from a room
 func (s *RoomService) LeaveRoom(ctx context.Context, roomID, userID uuid.UUID) error {
-	return s.roomRepo.RemoveMember(ctx, roomID, userID)
+	err := s.roomRepo.RemoveMember(ctx, roomID, userID)
+	s.record(ctx, models.AuditRoomLeave, roomID, userID, err)
+	return err
 }
 
 // GetRoomMembers retrieves all members of a room
 func (s *RoomService) GetRoomMembers(ctx context.Context, roomID uuid.UUID) ([]models.RoomMember, error) {
 	return s.roomRepo.GetMembers(ctx, roomID)
 }
+
+// record audits an action userID took on a room, successful unless err is set
+func (s *RoomService) record(ctx context.Context, action string, roomID, userID uuid.UUID, err error) {
+	s.auditLog.Record(ctx, audit.Outcome(models.AuditEvent{
+		ActorID:    &userID,
+		Action:     action,
+		TargetType: models.AuditTargetRoom,
+		TargetID:   roomID.String(),
+	}, err))
+}
@@ -0,0 +1,314 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/audit"
+	"github.com/GavinHemsada/go-backend/internal/dtos"
+	"github.com/GavinHemsada/go-backend/internal/models"
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+	"github.com/GavinHemsada/go-backend/pkg/utils"
+	"github.com/crewjam/saml"
+	"github.com/crewjam/saml/samlsp"
+	dsig "github.com/russellhaering/goxmldsig"
+)
+
+const (
+	// How long the user may take at the identity provider before coming back.
+	samlLoginTimeout = 10 * time.Minute
+
+	// Timeout of the request for the identity provider's metadata.
+	samlMetadataTimeout = 10 * time.Second
+)
+
+// SAMLConfig sets up a SAMLService
+type SAMLConfig struct {
+	IDPMetadata       string // URL or file path
+	EntityID          string // defaults to MetadataURL
+	MetadataURL       string
+	ACSURL            string
+	CertFile          string
+	KeyFile           string
+	UsernameAttribute string
+	EmailAttribute    string
+}
+
+// SAMLService logs users in as a SAML 2.0 service provider. Requests are
+// signed and sent with the HTTP-Redirect binding; responses come back with
+// HTTP-POST. A response is accepted once, only in reply to a request of ours,
+// and only with a valid signature, audience and time window. The identity is
+// linked to the account with the same email, or to a new account.
+type SAMLService struct {
+	identityRepo repository.IdentityRepository
+	accounts     *externalAccounts
+	cfg          SAMLConfig
+	jwtSecret    string
+	auditLog     *audit.Logger
+
+	// The provider's metadata is loaded on first use, so the server starts
+	// while the provider is unreachable
+	mu sync.Mutex
+	sp saml.ServiceProvider
+}
+
+// NewSAMLService loads the service provider's signing key and certificate
+func NewSAMLService(userRepo repository.UserRepository, identityRepo repository.IdentityRepository, txManager repository.TxManager, cfg SAMLConfig, jwtSecret string, auditLog *audit.Logger) (*SAMLService, error) {
+	keyPair, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(keyPair.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	key, ok := keyPair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("SAML key cannot sign requests")
+	}
+
+	metadataURL, err := url.Parse(cfg.MetadataURL)
+	if err != nil {
+		return nil, err
+	}
+	acsURL, err := url.Parse(cfg.ACSURL)
+	if err != nil {
+		return nil, err
+	}
+
+	signatureMethod := dsig.RSASHA256SignatureMethod
+	if _, ok := key.(*ecdsa.PrivateKey); ok {
+		signatureMethod = dsig.ECDSASHA256SignatureMethod
+	}
+
+	return &SAMLService{
+		identityRepo: identityRepo,
+		accounts:     &externalAccounts{userRepo: userRepo, identityRepo: identityRepo, txManager: txManager, auditLog: auditLog},
+		cfg:          cfg,
+		jwtSecret:    jwtSecret,
+		auditLog:     auditLog,
+		sp: saml.ServiceProvider{
+			EntityID:          cfg.EntityID,
+			Key:               key,
+			Certificate:       cert,
+			MetadataURL:       *metadataURL,
+			AcsURL:            *acsURL,
+			SignatureMethod:   signatureMethod,
+			AuthnNameIDFormat: saml.PersistentNameIDFormat,
+		},
+	}, nil
+}
+
+// Metadata returns the service provider metadata to register with the identity provider
+func (s *SAMLService) Metadata() ([]byte, error) {
+	s.mu.Lock()
+	metadata := s.sp.Metadata()
+	s.mu.Unlock()
+
+	// Responses are only accepted posted to the ACS, not as artifacts
+	for i := range metadata.SPSSODescriptors {
+		descriptor := &metadata.SPSSODescriptors[i]
+		var acs []saml.IndexedEndpoint
+		for _, endpoint := range descriptor.AssertionConsumerServices {
+			if endpoint.Binding == saml.HTTPPostBinding {
+				acs = append(acs, endpoint)
+			}
+		}
+		descriptor.AssertionConsumerServices = acs
+	}
+
+	encoded, err := xml.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), encoded...), nil
+}
+
+// BeginLogin starts a login and returns the provider URL to send the user to
+func (s *SAMLService) BeginLogin(ctx context.Context) (string, error) {
+	sp, err := s.serviceProvider(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	location := sp.GetSSOBindingLocation(saml.HTTPRedirectBinding)
+	if location == "" {
+		return "", errors.New("identity provider does not accept redirect requests")
+	}
+	request, err := sp.MakeAuthenticationRequest(location, saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	if err != nil {
+		return "", err
+	}
+
+	relayState, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	err = s.identityRepo.CreateSAMLRequest(ctx, &models.SAMLRequest{
+		RelayState: relayState,
+		RequestID:  request.ID,
+		ExpiresAt:  time.Now().UTC().Add(samlLoginTimeout),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	redirect, err := request.Redirect(relayState, sp)
+	if err != nil {
+		return "", err
+	}
+	return redirect.String(), nil
+}
+
+// FinishLogin validates the provider's response and returns a session token
+// for the matching account
+func (s *SAMLService) FinishLogin(ctx context.Context, samlResponse, relayState string) (*dtos.AuthResponse, error) {
+	user, err := s.finishLogin(ctx, samlResponse, relayState)
+
+	event := models.AuditEvent{
+		Action:     models.AuditUserLogin,
+		TargetType: models.AuditTargetUser,
+		Details:    "saml",
+	}
+	if user != nil {
+		event.ActorID = &user.ID
+		event.TargetID = user.ID.String()
+	}
+
+	// The reason a response was rejected is kept out of the reply, but audited
+	var invalid *saml.InvalidResponseError
+	if errors.As(err, &invalid) {
+		s.auditLog.Record(ctx, audit.Outcome(event, invalid.PrivateErr))
+		return nil, errors.New("SAML response could not be verified")
+	}
+	s.auditLog.Record(ctx, audit.Outcome(event, err))
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := utils.GenerateToken(user, s.jwtSecret)
+	if err != nil {
+		return nil, err
+	}
+	return &dtos.AuthResponse{User: user, Token: token}, nil
+}
+
+// finishLogin returns the user who logged in. On failure the user is
+// returned too once the account is known.
+func (s *SAMLService) finishLogin(ctx context.Context, samlResponse, relayState string) (*models.User, error) {
+	if samlResponse == "" || relayState == "" {
+		return nil, errors.New("SAMLResponse and RelayState are required")
+	}
+
+	sp, err := s.serviceProvider(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The request is single-use, so a response cannot be replayed
+	request, err := s.identityRepo.TakeSAMLRequest(ctx, relayState)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(samlResponse)
+	if err != nil {
+		return nil, errors.New("invalid SAML response")
+	}
+	assertion, err := sp.ParseXMLResponse(decoded, []string{request.RequestID}, sp.AcsURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// A transient NameID changes on every login, so it cannot identify the account
+	if assertion.Subject == nil || assertion.Subject.NameID == nil || assertion.Subject.NameID.Value == "" {
+		return nil, errors.New("SAML response has no NameID")
+	}
+	nameID := assertion.Subject.NameID
+	if nameID.Format == string(saml.TransientNameIDFormat) {
+		return nil, errors.New("identity provider must send a persistent NameID")
+	}
+
+	email := samlAttribute(assertion, s.cfg.EmailAttribute)
+	if email == "" && nameID.Format == string(saml.EmailAddressNameIDFormat) {
+		email = nameID.Value
+	}
+	if !strings.Contains(email, "@") {
+		return nil, errors.New("identity provider did not send an email")
+	}
+
+	user, err := s.accounts.account(ctx, externalIdentity{
+		Protocol: "saml",
+		Issuer:   sp.IDPMetadata.EntityID,
+		Subject:  nameID.Value,
+		Email:    email,
+		Username: samlAttribute(assertion, s.cfg.UsernameAttribute),
+	})
+	if err != nil {
+		return user, err
+	}
+
+	if user.Status != models.UserStatusActive {
+		return user, errors.New("account is " + user.Status)
+	}
+	return user, nil
+}
+
+// serviceProvider returns the service provider, loading the identity
+// provider's metadata on first use
+func (s *SAMLService) serviceProvider(ctx context.Context) (*saml.ServiceProvider, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sp.IDPMetadata == nil {
+		metadata, err := s.loadMetadata(ctx)
+		if err != nil {
+			return nil, errors.New("identity provider is unavailable")
+		}
+		s.sp.IDPMetadata = metadata
+	}
+
+	sp := s.sp
+	return &sp, nil
+}
+
+func (s *SAMLService) loadMetadata(ctx context.Context) (*saml.EntityDescriptor, error) {
+	source := s.cfg.IDPMetadata
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		metadataURL, err := url.Parse(source)
+		if err != nil {
+			return nil, err
+		}
+		return samlsp.FetchMetadata(ctx, &http.Client{Timeout: samlMetadataTimeout}, *metadataURL)
+	}
+
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return nil, err
+	}
+	return samlsp.ParseMetadata(data)
+}
+
+// samlAttribute returns the first value of the attribute with the name or friendly name
+func samlAttribute(assertion *saml.Assertion, name string) string {
+	for _, statement := range assertion.AttributeStatements {
+		for _, attr := range statement.Attributes {
+			if (attr.Name == name || attr.FriendlyName == name) && len(attr.Values) > 0 {
+				return strings.TrimSpace(attr.Values[0].Value)
+			}
+		}
+	}
+	return ""
+}
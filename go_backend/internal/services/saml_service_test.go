@@ -0,0 +1,310 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"html"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/audit"
+	"github.com/GavinHemsada/go-backend/internal/models"
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+	"github.com/crewjam/saml"
+	"github.com/crewjam/saml/samlsp"
+)
+
+const (
+	testSAMLMetadataURL = "https://chat.example.com/api/auth/saml/metadata"
+	testSAMLACSURL      = "https://chat.example.com/api/auth/saml/acs"
+)
+
+// newTestCertificate returns a key and a self-signed certificate for it
+func newTestCertificate(t *testing.T, name string) (*rsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, cert
+}
+
+// fakeIdentityProvider is an in-process SAML identity provider. It signs in
+// whoever signIn names without asking, and knows only the service under test.
+type fakeIdentityProvider struct {
+	server *httptest.Server
+
+	mu      sync.Mutex
+	idp     saml.IdentityProvider
+	session saml.Session
+	sp      *saml.EntityDescriptor
+}
+
+func newFakeIdentityProvider(t *testing.T) *fakeIdentityProvider {
+	t.Helper()
+
+	key, cert := newTestCertificate(t, "idp")
+	p := &fakeIdentityProvider{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metadata", func(w http.ResponseWriter, r *http.Request) {
+		idp := p.snapshot()
+		idp.ServeMetadata(w, r)
+	})
+	mux.HandleFunc("GET /sso", func(w http.ResponseWriter, r *http.Request) {
+		idp := p.snapshot()
+		idp.ServeSSO(w, r)
+	})
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+
+	base, err := url.Parse(p.server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.idp = saml.IdentityProvider{
+		Key:                     key,
+		Certificate:             cert,
+		Logger:                  log.New(io.Discard, "", 0),
+		MetadataURL:             *base.JoinPath("metadata"),
+		SSOURL:                  *base.JoinPath("sso"),
+		ServiceProviderProvider: p,
+		SessionProvider:         p,
+	}
+	return p
+}
+
+// snapshot copies the provider's settings, so tests may change them between requests
+func (p *fakeIdentityProvider) snapshot() *saml.IdentityProvider {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	idp := p.idp
+	return &idp
+}
+
+// trust registers the service provider's metadata with the identity provider
+func (p *fakeIdentityProvider) trust(t *testing.T, service *SAMLService) {
+	t.Helper()
+
+	metadata, err := service.Metadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sp, err := samlsp.ParseMetadata(metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sp = sp
+}
+
+func (p *fakeIdentityProvider) signIn(session saml.Session) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	session.CreateTime = time.Now()
+	session.ExpireTime = time.Now().Add(time.Hour)
+	p.session = session
+}
+
+// signWith makes the provider sign with a new key, one the metadata the
+// service has already loaded does not list
+func (p *fakeIdentityProvider) signWith(t *testing.T) {
+	t.Helper()
+
+	key, cert := newTestCertificate(t, "rogue")
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.idp.Key, p.idp.Certificate = key, cert
+}
+
+func (p *fakeIdentityProvider) GetSession(w http.ResponseWriter, r *http.Request, req *saml.IdpAuthnRequest) *saml.Session {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	session := p.session
+	return &session
+}
+
+func (p *fakeIdentityProvider) GetServiceProvider(r *http.Request, serviceProviderID string) (*saml.EntityDescriptor, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.sp == nil || p.sp.EntityID != serviceProviderID {
+		return nil, os.ErrNotExist
+	}
+	return p.sp, nil
+}
+
+func newSAMLService(t *testing.T, repos *repository.Repositories, provider *fakeIdentityProvider) *SAMLService {
+	t.Helper()
+
+	key, cert := newTestCertificate(t, "chat")
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "sp.crt"), filepath.Join(dir, "sp.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	service, err := NewSAMLService(repos.Users, repos.Identities, repos.TxManager, SAMLConfig{
+		IDPMetadata:       provider.server.URL + "/metadata",
+		MetadataURL:       testSAMLMetadataURL,
+		ACSURL:            testSAMLACSURL,
+		CertFile:          certFile,
+		KeyFile:           keyFile,
+		UsernameAttribute: "uid",
+		EmailAttribute:    "mail",
+	}, testJWTSecret, audit.NewLogger(repos.Audit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider.trust(t, service)
+	return service
+}
+
+var samlFormField = regexp.MustCompile(`name="(SAMLResponse|RelayState)" value="([^"]*)"`)
+
+// samlPost follows a login to the identity provider as a browser would and
+// returns the response and relay state it would post back to the ACS
+func samlPost(t *testing.T, service *SAMLService) (string, string) {
+	t.Helper()
+
+	redirect, err := service.BeginLogin(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Get(redirect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	page, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("identity provider answered the request with %s", resp.Status)
+	}
+
+	fields := make(map[string]string)
+	for _, m := range samlFormField.FindAllSubmatch(page, -1) {
+		fields[string(m[1])] = html.UnescapeString(string(m[2]))
+	}
+	if fields["SAMLResponse"] == "" || fields["RelayState"] == "" {
+		t.Fatalf("no response form in %s", page)
+	}
+	return fields["SAMLResponse"], fields["RelayState"]
+}
+
+func samlLogin(t *testing.T, service *SAMLService) (*models.User, error) {
+	t.Helper()
+
+	response, relayState := samlPost(t, service)
+	auth, err := service.FinishLogin(context.Background(), response, relayState)
+	if err != nil {
+		return nil, err
+	}
+	return auth.User, nil
+}
+
+func TestSAMLLogin(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		provider := newFakeIdentityProvider(t)
+		service := newSAMLService(t, repos, provider)
+
+		provider.signIn(saml.Session{NameID: "u-1", NameIDFormat: string(saml.PersistentNameIDFormat), UserName: "Frank", UserEmail: "frank@corp.example"})
+		frank, err := samlLogin(t, service)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if frank.Username != "frank" || frank.Email != "frank@corp.example" || !frank.EmailVerified {
+			t.Fatalf("got %+v, want a verified account named frank", frank)
+		}
+		if _, err := repos.Identities.GetBySubject(context.Background(), provider.server.URL+"/metadata", "u-1"); err != nil {
+			t.Errorf("identity was not linked to the provider's entity ID: %v", err)
+		}
+
+		// The NameID identifies the account, even once the email changes
+		provider.signIn(saml.Session{NameID: "u-1", NameIDFormat: string(saml.PersistentNameIDFormat), UserEmail: "frank@new.example"})
+		again, err := samlLogin(t, service)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again.ID != frank.ID {
+			t.Errorf("got %+v, want frank's account", again)
+		}
+	})
+}
+
+func TestSAMLLoginRejections(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		provider := newFakeIdentityProvider(t)
+		service := newSAMLService(t, repos, provider)
+		persistent := saml.Session{NameID: "u-2", NameIDFormat: string(saml.PersistentNameIDFormat), UserEmail: "grace@corp.example"}
+
+		provider.signIn(saml.Session{NameID: "u-2", NameIDFormat: string(saml.TransientNameIDFormat), UserEmail: "grace@corp.example"})
+		if _, err := samlLogin(t, service); err == nil {
+			t.Error("logged in with a transient NameID")
+		}
+		provider.signIn(saml.Session{NameID: "u-2", NameIDFormat: string(saml.PersistentNameIDFormat)})
+		if _, err := samlLogin(t, service); err == nil {
+			t.Error("logged in without an email")
+		}
+
+		// A response is accepted once, and only for the request it answers
+		provider.signIn(persistent)
+		response, relayState := samlPost(t, service)
+		if _, err := service.FinishLogin(ctx, response, relayState); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := service.FinishLogin(ctx, response, relayState); err == nil {
+			t.Error("a response was accepted twice")
+		}
+		response, _ = samlPost(t, service)
+		_, otherRelayState := samlPost(t, service)
+		if _, err := service.FinishLogin(ctx, response, otherRelayState); err == nil {
+			t.Error("a response was accepted for another request")
+		}
+
+		provider.signWith(t)
+		if _, err := samlLogin(t, service); err == nil {
+			t.Error("accepted a response signed with a key the metadata does not list")
+		}
+
+		events := auditEvents(t, repos)
+		last := events[len(events)-1]
+		if last.Action != models.AuditUserLogin || last.Result != models.AuditFailure || last.Details == "saml" {
+			t.Errorf("got %+v, want a failed login with the reason", last)
+		}
+	})
+}
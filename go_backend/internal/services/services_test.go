@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/GavinHemsada/go-backend/internal/database"
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/GavinHemsada/go-backend/internal/password"
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+)
+
+const testJWTSecret = "test-secret"
+
+// forEachBackend runs test against fresh in-memory and SQLite repositories
+func forEachBackend(t *testing.T, test func(t *testing.T, repos *repository.Repositories)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, repository.NewMemoryRepositories(repository.NewMemoryStore()))
+	})
+	t.Run("sqlite", func(t *testing.T) {
+		test(t, newSQLiteRepositories(t))
+	})
+}
+
+// newSQLiteRepositories returns repositories on a migrated SQLite database in a temporary file
+func newSQLiteRepositories(t *testing.T) *repository.Repositories {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "chat.db")
+	database.RunSQLiteMigrations(path)
+
+	db, err := database.ConnectSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return repository.NewSQLiteRepositories(db)
+}
+
+// newTestHasher returns a hasher with the cheapest parameters Argon2id allows
+func newTestHasher(t *testing.T) *password.Hasher {
+	t.Helper()
+
+	hasher, err := password.NewHasher(password.Params{Memory: 8, Iterations: 1, Parallelism: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hasher
+}
+
+// registerWithPassword registers a local user whose password is pass
+func registerWithPassword(t *testing.T, repos *repository.Repositories, hasher *password.Hasher, username, pass string) *models.User {
+	t.Helper()
+
+	hash, err := hasher.Hash(pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := repos.Users.Register(context.Background(), username, username+"@example.com", hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
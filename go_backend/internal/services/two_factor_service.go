@@ -0,0 +1,417 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/audit"
+	"github.com/GavinHemsada/go-backend/internal/dtos"
+	"github.com/GavinHemsada/go-backend/internal/loginguard"
+	"github.com/GavinHemsada/go-backend/internal/models"
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+	"github.com/GavinHemsada/go-backend/internal/totp"
+	"github.com/GavinHemsada/go-backend/pkg/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	// How long a login challenge token stays valid.
+	challengeTokenTTL = 5 * time.Minute
+
+	// Recovery codes issued at a time.
+	recoveryCodeCount = 10
+
+	// Characters of a recovery code, without look-alikes. 32 of them, so
+	// every random byte maps onto one evenly.
+	recoveryCodeAlphabet = "abcdefghijkmnpqrstuvwxyz23456789"
+
+	// Length of a recovery code, in groups of four. 16 characters give 80
+	// bits, enough for a plain SHA-256 hash to be safe to store.
+	recoveryCodeLength = 16
+)
+
+// TwoFactorService handles TOTP enrollment, recovery codes and the second login step
+type TwoFactorService struct {
+	userRepo      repository.UserRepository
+	authenticator Authenticator
+	twoFactorRepo repository.TwoFactorRepository
+	settingsRepo  repository.SettingsRepository
+	hub           AccountHub
+	guard         *loginguard.Guard
+	jwtSecret     string
+	issuer        string
+	auditLog      *audit.Logger
+}
+
+func NewTwoFactorService(userRepo repository.UserRepository, authenticator Authenticator, twoFactorRepo repository.TwoFactorRepository, settingsRepo repository.SettingsRepository, hub AccountHub, guard *loginguard.Guard, jwtSecret, issuer string, auditLog *audit.Logger) *TwoFactorService {
+	return &TwoFactorService{
+		userRepo:      userRepo,
+		authenticator: authenticator,
+		twoFactorRepo: twoFactorRepo,
+		settingsRepo:  settingsRepo,
+		hub:           hub,
+		guard:         guard,
+		jwtSecret:     jwtSecret,
+		issuer:        issuer,
+		auditLog:      auditLog,
+	}
+}
+
+// Policy returns who must use two-factor authentication: none, admins or everyone
+func (s *TwoFactorService) Policy(ctx context.Context) (string, error) {
+	policy, err := s.settingsRepo.Get(ctx, models.SettingTwoFactorRequired)
+	if err != nil {
+		return "", err
+	}
+	if policy == "" {
+		policy = models.TwoFactorRequiredNone
+	}
+	return policy, nil
+}
+
+// SetPolicy changes who must use two-factor authentication. It applies from
+// their next login; sessions already open are kept.
+func (s *TwoFactorService) SetPolicy(ctx context.Context, requiredFor string) error {
+	switch requiredFor {
+	case models.TwoFactorRequiredNone, models.TwoFactorRequiredAdmins, models.TwoFactorRequiredEveryone:
+	default:
+		return errors.New("required_for must be none, admins or everyone")
+	}
+	return s.settingsRepo.Set(ctx, models.SettingTwoFactorRequired, requiredFor)
+}
+
+// required reports whether the policy requires a second factor from user
+func (s *TwoFactorService) required(ctx context.Context, user *models.User) (bool, error) {
+	policy, err := s.Policy(ctx)
+	if err != nil {
+		return false, err
+	}
+	switch policy {
+	case models.TwoFactorRequiredEveryone:
+		return true, nil
+	case models.TwoFactorRequiredAdmins:
+		return user.Role == models.RoleAdmin, nil
+	}
+	return false, nil
+}
+
+// Challenge returns the second login step for user once their password
+// checked out, or nil when they can log in right away. Users the policy
+// requires a second factor from must enroll one before logging in.
+func (s *TwoFactorService) Challenge(ctx context.Context, user *models.User) (*dtos.TwoFactorChallenge, error) {
+	enrollment, err := s.twoFactorRepo.GetTOTP(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	method, purpose := "totp", utils.PurposeTwoFactor
+	if enrollment == nil || !enrollment.Enabled {
+		required, err := s.required(ctx, user)
+		if err != nil || !required {
+			return nil, err
+		}
+		method, purpose = "totp_setup", utils.PurposeTwoFactorSetup
+	}
+
+	token, expiresAt, err := utils.GenerateChallengeToken(user, s.jwtSecret, purpose, challengeTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &dtos.TwoFactorChallenge{Method: method, ChallengeToken: token, ExpiresAt: expiresAt}, nil
+}
+
+// CompleteLogin checks the second factor of a "totp" challenge and returns the session token
+func (s *TwoFactorService) CompleteLogin(ctx context.Context, challengeToken, code, recoveryCode string) (*dtos.AuthResponse, error) {
+	user, err := s.challengeUser(ctx, challengeToken, utils.PurposeTwoFactor)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.checkSecondFactor(ctx, user, code, recoveryCode)
+	event := models.AuditEvent{
+		ActorID:    &user.ID,
+		Action:     models.AuditTwoFactorVerify,
+		TargetType: models.AuditTargetUser,
+		TargetID:   user.ID.String(),
+	}
+	if recoveryCode != "" {
+		event.Details = "recovery code"
+	}
+	s.auditLog.Record(ctx, audit.Outcome(event, err))
+	if err != nil {
+		return nil, err
+	}
+
+	return s.session(user, nil)
+}
+
+// StartLoginSetup starts enrolling an authenticator app for a "totp_setup" challenge
+func (s *TwoFactorService) StartLoginSetup(ctx context.Context, challengeToken string) (*models.TOTPSetup, error) {
+	user, err := s.challengeUser(ctx, challengeToken, utils.PurposeTwoFactorSetup)
+	if err != nil {
+		return nil, err
+	}
+	return s.start(ctx, user)
+}
+
+// CompleteLoginSetup confirms the enrollment of a "totp_setup" challenge and
+// returns the session token with the new recovery codes
+func (s *TwoFactorService) CompleteLoginSetup(ctx context.Context, challengeToken, code string) (*dtos.AuthResponse, error) {
+	user, err := s.challengeUser(ctx, challengeToken, utils.PurposeTwoFactorSetup)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, err := s.enable(ctx, user, code)
+	if err != nil {
+		return nil, err
+	}
+	return s.session(user, codes)
+}
+
+// GetStatus describes the user's two-factor authentication
+func (s *TwoFactorService) GetStatus(ctx context.Context, userID uuid.UUID) (*models.TwoFactorStatus, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &models.TwoFactorStatus{}
+	if status.Required, err = s.required(ctx, user); err != nil {
+		return nil, err
+	}
+
+	enrollment, err := s.twoFactorRepo.GetTOTP(ctx, userID)
+	if err != nil || enrollment == nil || !enrollment.Enabled {
+		return status, err
+	}
+	status.Enabled = true
+	status.EnabledAt = enrollment.EnabledAt
+	status.RecoveryCodes, err = s.twoFactorRepo.CountRecoveryCodes(ctx, userID)
+	return status, err
+}
+
+// Start generates a new secret for the user to add to their authenticator app.
+// It takes effect once Enable confirms a code from the app.
+func (s *TwoFactorService) Start(ctx context.Context, userID uuid.UUID) (*models.TOTPSetup, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.start(ctx, user)
+}
+
+// Enable confirms the enrollment started by Start with a code from the app
+// and returns the recovery codes, which are only shown this once
+func (s *TwoFactorService) Enable(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.enable(ctx, user, code)
+}
+
+// Disable turns two-factor authentication off after checking the password
+// and the second factor. Users the policy requires it from cannot.
+func (s *TwoFactorService) Disable(ctx context.Context, userID uuid.UUID, password, code, recoveryCode string) error {
+	if password == "" {
+		return errors.New("password is required")
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	required, err := s.required(ctx, user)
+	if err != nil {
+		return err
+	}
+	if required {
+		return errors.New("two-factor authentication is required for your account")
+	}
+
+	err = checkPassword(ctx, s.authenticator, user, password)
+	if err == nil {
+		err = s.checkSecondFactor(ctx, user, code, recoveryCode)
+	}
+	if err == nil {
+		err = s.twoFactorRepo.DeleteTOTP(ctx, userID)
+	}
+	s.recordUser(ctx, models.AuditTwoFactorDisable, user.ID, err)
+	return err
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes after checking
+// the second factor, and returns the new ones
+func (s *TwoFactorService) RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, code, recoveryCode string) ([]string, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var codes []string
+	err = s.checkSecondFactor(ctx, user, code, recoveryCode)
+	if err == nil {
+		var hashes []string
+		codes, hashes, err = newRecoveryCodes()
+		if err == nil {
+			err = s.twoFactorRepo.ReplaceRecoveryCodes(ctx, userID, hashes)
+		}
+	}
+	s.recordUser(ctx, models.AuditTwoFactorRecoveryCodes, user.ID, err)
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Reset removes a user's second factor, for users who lost both their
+// authenticator and their recovery codes. Admins only; see AdminService.
+func (s *TwoFactorService) Reset(ctx context.Context, userID uuid.UUID) error {
+	return s.twoFactorRepo.DeleteTOTP(ctx, userID)
+}
+
+func (s *TwoFactorService) start(ctx context.Context, user *models.User) (*models.TOTPSetup, error) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.twoFactorRepo.StartTOTP(ctx, user.ID, secret); err != nil {
+		return nil, err
+	}
+	return &models.TOTPSetup{Secret: secret, URI: totp.URI(s.issuer, user.Username, secret)}, nil
+}
+
+func (s *TwoFactorService) enable(ctx context.Context, user *models.User, code string) ([]string, error) {
+	enrollment, err := s.twoFactorRepo.GetTOTP(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if enrollment == nil || enrollment.Enabled {
+		return nil, errors.New("no two-factor setup in progress")
+	}
+
+	step, ok := totp.Validate(enrollment.Secret, code, time.Now())
+	if !ok {
+		return nil, errors.New("invalid code")
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err == nil {
+		err = s.twoFactorRepo.EnableTOTP(ctx, user.ID, step, hashes)
+	}
+	s.recordUser(ctx, models.AuditTwoFactorEnable, user.ID, err)
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// checkSecondFactor checks a TOTP code or, when given, a recovery code.
+// Failures are limited like password failures, apart from them.
+func (s *TwoFactorService) checkSecondFactor(ctx context.Context, user *models.User, code, recoveryCode string) error {
+	attempt, err := s.guard.Begin(ctx, loginguard.SecondFactorKey(user.ID), audit.ClientFromContext(ctx).IP)
+	if err != nil {
+		return err
+	}
+
+	if err := s.verifySecondFactor(ctx, user.ID, code, recoveryCode); err != nil {
+		reportLockouts(ctx, s.auditLog, s.hub, attempt.Failed(ctx), user.Username, user)
+		return err
+	}
+	attempt.Succeeded(ctx)
+	return nil
+}
+
+func (s *TwoFactorService) verifySecondFactor(ctx context.Context, userID uuid.UUID, code, recoveryCode string) error {
+	if recoveryCode != "" {
+		return s.twoFactorRepo.UseRecoveryCode(ctx, userID, hashRecoveryCode(recoveryCode))
+	}
+	if code == "" {
+		return errors.New("code or recovery_code is required")
+	}
+
+	enrollment, err := s.twoFactorRepo.GetTOTP(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if enrollment == nil || !enrollment.Enabled {
+		return errors.New("two-factor authentication is not enabled")
+	}
+
+	step, ok := totp.Validate(enrollment.Secret, code, time.Now())
+	if !ok {
+		return errors.New("invalid code")
+	}
+	return s.twoFactorRepo.UseTOTPStep(ctx, userID, step)
+}
+
+// challengeUser returns the active user a challenge token was issued to
+func (s *TwoFactorService) challengeUser(ctx context.Context, challengeToken, purpose string) (*models.User, error) {
+	claims, err := utils.ParseChallengeToken(challengeToken, s.jwtSecret, purpose)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, claims.UserID)
+	if err != nil {
+		return nil, errors.New("invalid or expired challenge token")
+	}
+	if user.Status != models.UserStatusActive {
+		return nil, errors.New("account is " + user.Status)
+	}
+	return user, nil
+}
+
+// session issues the session token that ends a login
+func (s *TwoFactorService) session(user *models.User, recoveryCodes []string) (*dtos.AuthResponse, error) {
+	token, err := utils.GenerateToken(user, s.jwtSecret)
+	if err != nil {
+		return nil, err
+	}
+	return &dtos.AuthResponse{User: user, Token: token, RecoveryCodes: recoveryCodes}, nil
+}
+
+// recordUser audits a user's change to their own two-factor authentication
+func (s *TwoFactorService) recordUser(ctx context.Context, action string, userID uuid.UUID, err error) {
+	s.auditLog.Record(ctx, audit.Outcome(models.AuditEvent{
+		ActorID:    &userID,
+		Action:     action,
+		TargetType: models.AuditTargetUser,
+		TargetID:   userID.String(),
+	}, err))
+}
+
+// newRecoveryCodes returns a fresh set of recovery codes and their hashes
+func newRecoveryCodes() (codes, hashes []string, err error) {
+	random := make([]byte, recoveryCodeCount*recoveryCodeLength)
+	if _, err := rand.Read(random); err != nil {
+		return nil, nil, err
+	}
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		var code strings.Builder
+		for j, b := range random[i*recoveryCodeLength : (i+1)*recoveryCodeLength] {
+			if j > 0 && j%4 == 0 {
+				code.WriteByte('-')
+			}
+			code.WriteByte(recoveryCodeAlphabet[int(b)%len(recoveryCodeAlphabet)])
+		}
+		codes = append(codes, code.String())
+		hashes = append(hashes, hashRecoveryCode(code.String()))
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode hashes a recovery code, ignoring case, dashes and spaces
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/audit"
+	"github.com/GavinHemsada/go-backend/internal/loginguard"
+	"github.com/GavinHemsada/go-backend/internal/models"
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+	"github.com/GavinHemsada/go-backend/internal/totp"
+)
+
+// authenticatorApp is a software stand-in for an authenticator app: it reads
+// the provisioning URI and shows the code of any moment
+type authenticatorApp struct {
+	secret string
+}
+
+func scanTOTP(t *testing.T, uri string) *authenticatorApp {
+	t.Helper()
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := u.Query()
+	if u.Scheme != "otpauth" || u.Host != "totp" || query.Get("algorithm") != "SHA1" || query.Get("digits") != "6" || query.Get("period") != "30" {
+		t.Fatalf("authenticator cannot use %s", uri)
+	}
+	return &authenticatorApp{secret: query.Get("secret")}
+}
+
+// code returns the code shown at t
+func (a *authenticatorApp) code(t *testing.T, at time.Time) string {
+	t.Helper()
+
+	code, err := totp.Code(a.secret, totp.Step(at))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func newTwoFactorService(t *testing.T, repos *repository.Repositories) *TwoFactorService {
+	authenticator := NewLocalAuthenticator(repos.Users, repos.Identities, newTestHasher(t))
+	guard := loginguard.NewGuard(loginguard.Config{}, loginguard.NewMemoryStore())
+	return NewTwoFactorService(repos.Users, authenticator, repos.TwoFactor, repos.Settings, nil, guard, testJWTSecret, "Chat", audit.NewLogger(repos.Audit))
+}
+
+// enroll turns two-factor authentication on for user and returns their app,
+// the time of the code that enabled it and the recovery codes
+func enroll(t *testing.T, service *TwoFactorService, user *models.User) (*authenticatorApp, time.Time, []string) {
+	t.Helper()
+	ctx := context.Background()
+
+	setup, err := service.Start(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(setup.URI, "otpauth://totp/Chat:"+user.Username+"?") {
+		t.Errorf("got URI %s, want one labelled with the issuer and username", setup.URI)
+	}
+	app := scanTOTP(t, setup.URI)
+
+	if _, err := service.Enable(ctx, user.ID, app.code(t, time.Now().Add(time.Hour))); err == nil {
+		t.Fatal("enabled with a code that is not due yet")
+	}
+	enabledAt := time.Now()
+	codes, err := service.Enable(ctx, user.ID, app.code(t, enabledAt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("got %d recovery codes, want %d", len(codes), recoveryCodeCount)
+	}
+	return app, enabledAt, codes
+}
+
+func TestTOTPLogin(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		service := newTwoFactorService(t, repos)
+		alice := registerWithPassword(t, repos, newTestHasher(t), "alice", "correct horse")
+
+		if challenge, err := service.Challenge(ctx, alice); err != nil || challenge != nil {
+			t.Fatalf("got challenge %+v, %v before enrolling, want none", challenge, err)
+		}
+		app, enabledAt, _ := enroll(t, service, alice)
+
+		challenge, err := service.Challenge(ctx, alice)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if challenge == nil || challenge.Method != "totp" {
+			t.Fatalf("got challenge %+v, want a totp challenge", challenge)
+		}
+
+		// The code that enabled the app was used up by enabling it
+		if _, err := service.CompleteLogin(ctx, challenge.ChallengeToken, app.code(t, enabledAt), ""); err == nil {
+			t.Fatal("the enrollment code was accepted again")
+		}
+
+		// The next code is within the allowed clock skew, and works once
+		next := app.code(t, enabledAt.Add(totp.Period))
+		auth, err := service.CompleteLogin(ctx, challenge.ChallengeToken, next, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if auth.Token == "" || auth.User.ID != alice.ID {
+			t.Fatalf("got %+v, want a session for alice", auth)
+		}
+		if _, err := service.CompleteLogin(ctx, challenge.ChallengeToken, next, ""); err == nil {
+			t.Fatal("a code was accepted twice")
+		}
+
+		if _, err := service.CompleteLogin(ctx, "not a token", next, ""); err == nil {
+			t.Fatal("logged in without a challenge token")
+		}
+	})
+}
+
+func TestTOTPRecoveryCodes(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		service := newTwoFactorService(t, repos)
+		alice := registerWithPassword(t, repos, newTestHasher(t), "alice", "correct horse")
+		_, _, codes := enroll(t, service, alice)
+
+		challenge, err := service.Challenge(ctx, alice)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Codes are accepted whatever their case and grouping, once each
+		typed := strings.ToUpper(strings.ReplaceAll(codes[0], "-", " "))
+		if _, err := service.CompleteLogin(ctx, challenge.ChallengeToken, "", typed); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := service.CompleteLogin(ctx, challenge.ChallengeToken, "", codes[0]); err == nil {
+			t.Fatal("a recovery code was accepted twice")
+		}
+
+		status, err := service.GetStatus(ctx, alice.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !status.Enabled || status.RecoveryCodes != recoveryCodeCount-1 {
+			t.Errorf("got status %+v, want enabled with %d recovery codes left", status, recoveryCodeCount-1)
+		}
+	})
+}
+
+func TestTOTPDisable(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		service := newTwoFactorService(t, repos)
+		alice := registerWithPassword(t, repos, newTestHasher(t), "alice", "correct horse")
+		app, enabledAt, _ := enroll(t, service, alice)
+		next := app.code(t, enabledAt.Add(totp.Period))
+
+		if err := service.Disable(ctx, alice.ID, "wrong password", next, ""); err == nil {
+			t.Fatal("disabled with a wrong password")
+		}
+		if err := service.Disable(ctx, alice.ID, "correct horse", "", ""); err == nil {
+			t.Fatal("disabled without a second factor")
+		}
+		if err := service.Disable(ctx, alice.ID, "correct horse", next, ""); err != nil {
+			t.Fatal(err)
+		}
+
+		if challenge, err := service.Challenge(ctx, alice); err != nil || challenge != nil {
+			t.Errorf("got challenge %+v, %v after disabling, want none", challenge, err)
+		}
+	})
+}
+
+// Users the policy covers must enroll during login before they get a session
+func TestTOTPPolicyRequiresSetup(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		service := newTwoFactorService(t, repos)
+		alice := registerWithPassword(t, repos, newTestHasher(t), "alice", "correct horse")
+		if err := service.SetPolicy(ctx, models.TwoFactorRequiredEveryone); err != nil {
+			t.Fatal(err)
+		}
+
+		challenge, err := service.Challenge(ctx, alice)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if challenge == nil || challenge.Method != "totp_setup" {
+			t.Fatalf("got challenge %+v, want totp_setup", challenge)
+		}
+		if _, err := service.CompleteLogin(ctx, challenge.ChallengeToken, "123456", ""); err == nil {
+			t.Fatal("a setup challenge was accepted as a login challenge")
+		}
+
+		setup, err := service.StartLoginSetup(ctx, challenge.ChallengeToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		app := scanTOTP(t, setup.URI)
+		auth, err := service.CompleteLoginSetup(ctx, challenge.ChallengeToken, app.code(t, time.Now()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if auth.Token == "" || len(auth.RecoveryCodes) != recoveryCodeCount {
+			t.Fatalf("got %+v, want a session with recovery codes", auth)
+		}
+
+		if err := service.Disable(ctx, alice.ID, "correct horse", app.code(t, time.Now().Add(totp.Period)), ""); err == nil {
+			t.Fatal("disabled two-factor authentication the policy requires")
+		}
+	})
+}
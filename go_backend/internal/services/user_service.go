@@ -3,44 +3,110 @@ package services
 import (
 	"context"
 	"errors"
+	"log"
+	"sync"
+	"time"
 
+	"github.com/GavinHemsada/go-backend/internal/audit"
+	"github.com/GavinHemsada/go-backend/internal/loginguard"
 	"github.com/GavinHemsada/go-backend/internal/models"
 	"github.com/GavinHemsada/go-backend/internal/dtos"
+	"github.com/GavinHemsada/go-backend/internal/password"
 	repository "github.com/GavinHemsada/go-backend/internal/repositories"
 	"github.com/GavinHemsada/go-backend/pkg/utils"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
+// ConnectionCloser closes live WebSocket connections on every server instance
+type ConnectionCloser interface {
+	// DisconnectAll closes every connection of the user, in every room
+	DisconnectAll(userID uuid.UUID, reason string)
+}
+
+// AccountHub reaches a user's live WebSocket connections on every server instance
+type AccountHub interface {
+	ConnectionCloser
+	// NotifyAll sends event to every connection of the user, in every room
+	NotifyAll(userID uuid.UUID, event models.WSMessageResponse)
+}
+
+// Shortest time between two writes of a user's last seen time
+const seenInterval = time.Minute
+
+// How recently users without a password must have logged in to close their
+// account, which stands in for confirming the password
+const reauthWindow = 5 * time.Minute
+
 type UserService struct {
-	userRepo  *repository.UserRepository
-	jwtSecret string
+	userRepo      repository.UserRepository
+	authenticator Authenticator
+	hasher        *password.Hasher
+	policy        *password.Policy
+	jwtSecret     string
+	hub           AccountHub
+	guard         *loginguard.Guard
+	twoFactor     *TwoFactorService
+	email         *EmailService
+	auditLog      *audit.Logger
+
+	mu        sync.Mutex
+	lastSeen  map[uuid.UUID]time.Time // last seen times written by this instance
+	seenPrune time.Time               // when lastSeen was last pruned
 }
 
-func NewUserService(userRepo *repository.UserRepository, jwtSecret string) *UserService {
+func NewUserService(userRepo repository.UserRepository, authenticator Authenticator, hasher *password.Hasher, policy *password.Policy, jwtSecret string, hub AccountHub, guard *loginguard.Guard, twoFactor *TwoFactorService, email *EmailService, auditLog *audit.Logger) *UserService {
 	return &UserService{
-		userRepo:  userRepo,
-		jwtSecret: jwtSecret,
+		userRepo:      userRepo,
+		authenticator: authenticator,
+		hasher:        hasher,
+		policy:        policy,
+		jwtSecret:     jwtSecret,
+		hub:           hub,
+		guard:         guard,
+		twoFactor:     twoFactor,
+		email:         email,
+		auditLog:      auditLog,
+		lastSeen:      make(map[uuid.UUID]time.Time),
 	}
 }
 
-// Register creates a new user and returns the user with a JWT token
+// Register creates a new user and returns the user with a JWT token. A link
+// to verify their email is sent in the background.
 func (s *UserService) Register(ctx context.Context, username, email, password string) (*dtos.AuthResponse, error) {
 	// Validate input
 	if username == "" || email == "" || password == "" {
 		return nil, errors.New("username, email, and password are required")
 	}
 
-	if len(password) < 6 {
-		return nil, errors.New("password must be at least 6 characters")
+	if err := s.policy.Check(password, username, email); err != nil {
+		return nil, err
+	}
+
+	hash, err := s.hasher.Hash(password)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create user via repository
-	user, err := s.userRepo.Register(ctx, username, email, password)
+	user, err := s.userRepo.Register(ctx, username, email, hash)
 	if err != nil {
+		s.auditLog.Record(ctx, audit.Outcome(models.AuditEvent{
+			Action:     models.AuditUserRegister,
+			TargetType: models.AuditTargetUser,
+			TargetID:   username,
+		}, err))
 		return nil, err
 	}
 
+	s.auditLog.Record(ctx, models.AuditEvent{
+		ActorID:    &user.ID,
+		Action:     models.AuditUserRegister,
+		TargetType: models.AuditTargetUser,
+		TargetID:   user.ID.String(),
+	})
+	s.email.SendVerificationLater(ctx, user)
+
 	// Generate JWT token
 	token, err := utils.GenerateToken(user, s.jwtSecret)
 	if err != nil {
@@ -53,19 +119,70 @@ func (s *UserService) Register(ctx context.Context, username, email, password st
 	}, nil
 }
 
-// Login authenticates a user and returns the user with a JWT token
+// Login authenticates a user and returns the user with a JWT token, or a
+// challenge for the second factor when the account needs one. Repeated
+// failures are slowed down and locked out per account and per client IP;
+// unknown identifiers are treated like existing accounts.
 func (s *UserService) Login(ctx context.Context, identifier, password string) (*dtos.AuthResponse, error) {
 	// Validate input
 	if identifier == "" || password == "" {
 		return nil, errors.New("identifier and password are required")
 	}
 
-	// Authenticate user via repository
-	user, err := s.userRepo.Login(ctx, identifier, password)
+	// Failures count against the account whichever identifier names it
+	accountKey := loginguard.IdentifierKey(identifier)
+	account, err := s.userRepo.GetByIdentifier(ctx, identifier)
 	if err != nil {
+		account = nil
+	} else {
+		accountKey = loginguard.UserKey(account.ID)
+	}
+
+	var user *models.User
+	var lockouts []loginguard.Lockout
+	attempt, err := s.guard.Begin(ctx, accountKey, audit.ClientFromContext(ctx).IP)
+	if err == nil {
+		// Authenticate user via the authenticator chain
+		user, err = s.authenticator.Authenticate(ctx, identifier, password)
+		if err != nil {
+			lockouts = attempt.Failed(ctx)
+		} else {
+			attempt.Succeeded(ctx)
+		}
+	}
+
+	if err != nil {
+		// The identifier is kept as given; it may not name an existing account
+		s.auditLog.Record(ctx, audit.Outcome(models.AuditEvent{
+			Action:     models.AuditUserLogin,
+			TargetType: models.AuditTargetUser,
+			TargetID:   identifier,
+		}, err))
+		reportLockouts(ctx, s.auditLog, s.hub, lockouts, identifier, account)
 		return nil, err
 	}
 
+	// Accounts with a second factor get a challenge instead of a session token
+	challenge, err := s.twoFactor.Challenge(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	event := models.AuditEvent{
+		ActorID:    &user.ID,
+		Action:     models.AuditUserLogin,
+		TargetType: models.AuditTargetUser,
+		TargetID:   user.ID.String(),
+	}
+	if challenge != nil {
+		event.Details = "password accepted, second factor required"
+	}
+	s.auditLog.Record(ctx, event)
+
+	if challenge != nil {
+		return &dtos.AuthResponse{TwoFactor: challenge}, nil
+	}
+
 	// Generate JWT token
 	token, err := utils.GenerateToken(user, s.jwtSecret)
 	if err != nil {
@@ -78,14 +195,202 @@ func (s *UserService) Login(ctx context.Context, identifier, password string) (*
 	}, nil
 }
 
+// reportLockouts audits the locks placed by a failed login step and warns the
+// account's live connections. account is nil for unknown identifiers.
+func reportLockouts(ctx context.Context, auditLog *audit.Logger, hub AccountHub, lockouts []loginguard.Lockout, identifier string, account *models.User) {
+	for _, lockout := range lockouts {
+		details := "locked until " + lockout.Until.UTC().Format(time.RFC3339)
+
+		if lockout.Kind == loginguard.LockoutIP {
+			auditLog.Record(ctx, models.AuditEvent{
+				Action:     models.AuditIPLockout,
+				TargetType: models.AuditTargetIP,
+				TargetID:   audit.ClientFromContext(ctx).IP,
+				Details:    details,
+			})
+			continue
+		}
+
+		event := models.AuditEvent{
+			Action:     models.AuditUserLockout,
+			TargetType: models.AuditTargetUser,
+			TargetID:   identifier,
+			Details:    details,
+		}
+		if account != nil {
+			event.TargetID = account.ID.String()
+		}
+		auditLog.Record(ctx, event)
+
+		if account != nil && hub != nil {
+			hub.NotifyAll(account.ID, models.WSMessageResponse{
+				Type:      "account_locked",
+				Reason:    "too many failed login attempts",
+				ExpiresAt: &lockout.Until,
+			})
+		}
+	}
+}
+
 // GetByID retrieves a user by their ID
 func (s *UserService) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
 	return s.userRepo.GetByID(ctx, id)
 }
 
-// GetAll retrieves all users
-func (s *UserService) GetAll(ctx context.Context) ([]models.User, error) {
-	return s.userRepo.GetAll(ctx)
+// Seen records that the user is active now, writing it at most once per
+// seenInterval. Failures are only logged.
+func (s *UserService) Seen(ctx context.Context, id uuid.UUID) {
+	now := time.Now()
+
+	s.mu.Lock()
+	if now.Sub(s.lastSeen[id]) < seenInterval {
+		s.mu.Unlock()
+		return
+	}
+	if now.Sub(s.seenPrune) >= seenInterval {
+		for userID, seen := range s.lastSeen {
+			if now.Sub(seen) >= seenInterval {
+				delete(s.lastSeen, userID)
+			}
+		}
+		s.seenPrune = now
+	}
+	s.lastSeen[id] = now
+	s.mu.Unlock()
+
+	if err := s.userRepo.SetLastSeen(ctx, id, now); err != nil {
+		log.Printf("Failed to record user %s as seen: %v", id, err)
+	}
+}
+
+// CheckAccount returns a *utils.AccountError unless the user the claims were
+// issued to still exists and is active, and, for session tokens, has not had
+// their sessions revoked since. Bots also stop working while their owner's
+// account is not active. Failures to look the account up are returned as is.
+func (s *UserService) CheckAccount(ctx context.Context, claims *utils.Claims) error {
+	user, err := s.userRepo.GetByID(ctx, claims.UserID)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return &utils.AccountError{Reason: "account no longer exists"}
+	}
+	if err != nil {
+		return err
+	}
+
+	// Access tokens are revoked on their own
+	if claims.TokenID == nil && claims.Stamp != user.SessionStamp {
+		return &utils.AccountError{Reason: "session has been revoked, log in again"}
+	}
+
+	switch user.Status {
+	case models.UserStatusSuspended:
+		return &utils.AccountError{Reason: "account is suspended"}
+	case models.UserStatusDeactivated:
+		return &utils.AccountError{Reason: "account is deactivated"}
+	}
+
+	if user.IsBot && user.OwnerID != nil {
+		owner, err := s.userRepo.GetByID(ctx, *user.OwnerID)
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return &utils.AccountError{Reason: "bot owner's account no longer exists"}
+		}
+		if err != nil {
+			return err
+		}
+		if owner.Status != models.UserStatusActive {
+			return &utils.AccountError{Reason: "bot owner's account is " + owner.Status}
+		}
+	}
+	return nil
+}
+
+// Deactivate closes the user's own account after checking their password.
+// Users without a password, who log in with a passkey or single sign-on,
+// confirm by sending no password with a session from a login within
+// reauthWindow instead. Their messages stay, shown under models.DeactivatedUsername.
+func (s *UserService) Deactivate(ctx context.Context, claims *utils.Claims, password string) error {
+	id := claims.UserID
+	user, err := s.userRepo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case password != "":
+		err = checkPassword(ctx, s.authenticator, user, password)
+	case user.PasswordHash != "":
+		return errors.New("password is required")
+	case !recentLogin(claims):
+		err = errors.New("log in again, then close the account within 5 minutes")
+	}
+	if err == nil {
+		err = s.userRepo.SetStatus(ctx, id, models.UserStatusDeactivated)
+	}
+	s.auditLog.Record(ctx, audit.Outcome(models.AuditEvent{
+		ActorID:    &id,
+		Action:     models.AuditUserDeactivate,
+		TargetType: models.AuditTargetUser,
+		TargetID:   id.String(),
+	}, err))
+	if err != nil {
+		return err
+	}
+
+	if s.hub != nil {
+		s.hub.DisconnectAll(id, "account deactivated")
+	}
+	return nil
+}
+
+// recentLogin tells whether the claims are of a session from a login within reauthWindow
+func recentLogin(claims *utils.Claims) bool {
+	return claims.TokenID == nil && claims.IssuedAt != nil && time.Since(claims.IssuedAt.Time) < reauthWindow
+}
+
+// ChangePassword replaces the user's password after checking the current
+// one. Wrong guesses count as failed logins, so a stolen session cannot be
+// used to find out the password.
+func (s *UserService) ChangePassword(ctx context.Context, id uuid.UUID, current, newPassword string) error {
+	if current == "" || newPassword == "" {
+		return errors.New("current and new password are required")
+	}
+	if current == newPassword {
+		return errors.New("new password must differ from the current one")
+	}
+
+	user, err := s.userRepo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.policy.Check(newPassword, user.Username, user.Email); err != nil {
+		return err
+	}
+
+	var lockouts []loginguard.Lockout
+	attempt, err := s.guard.Begin(ctx, loginguard.UserKey(id), audit.ClientFromContext(ctx).IP)
+	if err == nil {
+		if s.hasher.Verify(user.PasswordHash, current) {
+			attempt.Succeeded(ctx)
+		} else {
+			lockouts = attempt.Failed(ctx)
+			err = errors.New("current password is incorrect")
+		}
+	}
+	var hash string
+	if err == nil {
+		hash, err = s.hasher.Hash(newPassword)
+	}
+	if err == nil {
+		err = s.userRepo.SetPasswordHash(ctx, id, hash)
+	}
+
+	s.auditLog.Record(ctx, audit.Outcome(models.AuditEvent{
+		ActorID:    &id,
+		Action:     models.AuditPasswordChange,
+		TargetType: models.AuditTargetUser,
+		TargetID:   id.String(),
+	}, err))
+	reportLockouts(ctx, s.auditLog, s.hub, lockouts, user.Username, user)
+	return err
 }
 
 // ValidateToken validates a JWT token and returns the claims
@@ -104,7 +409,7 @@ func (s *UserService) ValidateToken(tokenString string) (*utils.Claims, error) {
 		return nil, err
 	}
 
-	if !token.Valid {
+	if !token.Valid || claims.Purpose != "" {
 		return nil, errors.New("invalid token")
 	}
 
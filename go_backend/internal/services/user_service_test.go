@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/audit"
+	"github.com/GavinHemsada/go-backend/internal/models"
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+	"github.com/GavinHemsada/go-backend/pkg/utils"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+var errDatabaseDown = errors.New("database is down")
+
+// brokenUsers fails to look users up
+type brokenUsers struct {
+	repository.UserRepository
+}
+
+func (brokenUsers) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	return nil, errDatabaseDown
+}
+
+// sessionClaims returns the claims of a new session token for user
+func sessionClaims(t *testing.T, user *models.User) *utils.Claims {
+	t.Helper()
+	session, err := utils.GenerateToken(user, testJWTSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := utils.ParseChallengeToken(session, testJWTSecret, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return claims
+}
+
+// newUserService returns a user service checking passwords locally
+func newUserService(t *testing.T, repos *repository.Repositories) *UserService {
+	t.Helper()
+	authenticator := NewLocalAuthenticator(repos.Users, repos.Identities, newTestHasher(t))
+	return NewUserService(repos.Users, authenticator, newTestHasher(t), nil, testJWTSecret, nil, nil, nil, nil, audit.NewLogger(repos.Audit))
+}
+
+// checkRefused fails unless err refuses the account rather than failing to check it
+func checkRefused(t *testing.T, err error) {
+	t.Helper()
+	var refused *utils.AccountError
+	if !errors.As(err, &refused) {
+		t.Errorf("got %v, want the account refused", err)
+	}
+}
+
+func TestDeactivateChecksPassword(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		service := newUserService(t, repos)
+		alice := registerWithPassword(t, repos, newTestHasher(t), "alice", "correct horse")
+		claims := sessionClaims(t, alice)
+
+		// A fresh session does not stand in for a password the account has
+		if err := service.Deactivate(ctx, claims, ""); err == nil {
+			t.Error("the account was closed without its password")
+		}
+		if err := service.Deactivate(ctx, claims, "wrong horse"); err == nil {
+			t.Error("the account was closed with a wrong password")
+		}
+		if err := service.CheckAccount(ctx, claims); err != nil {
+			t.Fatalf("the account stopped working: %v", err)
+		}
+
+		if err := service.Deactivate(ctx, claims, "correct horse"); err != nil {
+			t.Fatal(err)
+		}
+		checkRefused(t, service.CheckAccount(ctx, claims))
+	})
+}
+
+// Accounts without a password confirm by having logged in just now
+func TestDeactivateWithoutPassword(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		service := newUserService(t, repos)
+		carol, err := repos.Users.Register(ctx, "carol", "carol@corp.example", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stale := sessionClaims(t, carol)
+		stale.IssuedAt = jwt.NewNumericDate(time.Now().Add(-reauthWindow - time.Minute))
+		if err := service.Deactivate(ctx, stale, ""); err == nil {
+			t.Error("the account was closed from an old session")
+		}
+
+		token := sessionClaims(t, carol)
+		tokenID := uuid.New()
+		token.TokenID, token.IssuedAt = &tokenID, nil
+		if err := service.Deactivate(ctx, token, ""); err == nil {
+			t.Error("the account was closed with an access token")
+		}
+
+		if err := service.Deactivate(ctx, sessionClaims(t, carol), ""); err != nil {
+			t.Fatalf("a fresh session did not close the account: %v", err)
+		}
+		user, err := repos.Users.GetByID(ctx, carol.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.Status != models.UserStatusDeactivated {
+			t.Errorf("got status %s, want %s", user.Status, models.UserStatusDeactivated)
+		}
+
+		events := auditEvents(t, repos)
+		if len(events) != 3 || events[0].Result != models.AuditFailure || events[1].Result != models.AuditFailure || events[2].Result != models.AuditSuccess {
+			t.Errorf("got %+v, want two failed attempts and a success audited", events)
+		}
+	})
+}
+
+// Accounts that may not use their tokens are refused, while failures to look
+// them up are not mistaken for that
+func TestCheckAccountErrors(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		service := newUserService(t, repos)
+		alice := registerWithPassword(t, repos, newTestHasher(t), "alice", "correct horse")
+		claims := sessionClaims(t, alice)
+
+		broken := &UserService{userRepo: brokenUsers{repos.Users}}
+		err := broken.CheckAccount(ctx, claims)
+		var refused *utils.AccountError
+		if !errors.Is(err, errDatabaseDown) || errors.As(err, &refused) {
+			t.Errorf("got %v, want %v as is", err, errDatabaseDown)
+		}
+
+		if err := repos.Users.Delete(ctx, alice.ID); err != nil {
+			t.Fatal(err)
+		}
+		checkRefused(t, service.CheckAccount(ctx, claims))
+	})
+}
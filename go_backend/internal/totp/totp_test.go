@@ -0,0 +1,80 @@
+package totp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+// The SHA-1 secret of RFC 6238 appendix B, "12345678901234567890", in base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCodeMatchesRFC6238(t *testing.T) {
+	// The RFC lists 8 digit codes; these are their last 6 digits
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		code, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != tt.code {
+			t.Errorf("code at %d = %s, want %s", tt.unix, code, tt.code)
+		}
+	}
+}
+
+func TestValidateAcceptsSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := Step(now)
+
+	for offset := int64(-2); offset <= 2; offset++ {
+		code, err := Code(rfcSecret, current+offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		step, ok := Validate(rfcSecret, code[:3]+" "+code[3:], now)
+		want := offset >= -Skew && offset <= Skew
+		if ok != want {
+			t.Errorf("step %+d: accepted = %v, want %v", offset, ok, want)
+		}
+		if ok && step != current+offset {
+			t.Errorf("step %+d: matched step %d, want %d", offset, step, current+offset)
+		}
+	}
+
+	if _, ok := Validate(rfcSecret, "12345", now); ok {
+		t.Error("accepted a code of the wrong length")
+	}
+}
+
+func TestURI(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := url.Parse(URI("Chat App", "alice", secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := u.Query()
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/Chat App:alice" {
+		t.Errorf("got %s, want an otpauth://totp/ URI labelled Chat App:alice", u)
+	}
+	if query.Get("secret") != secret || query.Get("issuer") != "Chat App" || query.Get("digits") != "6" || query.Get("period") != "30" {
+		t.Errorf("got query %v", query)
+	}
+	if _, err := Code(secret, 1); err != nil {
+		t.Errorf("generated secret does not decode: %v", err)
+	}
+}
@@ -28,12 +28,19 @@ type Client struct {
 	send   chan []byte
 	userID string
 	roomID string
+
+	// Peer address and connect time, reported to administrators.
+	remoteAddr  string
+	connectedAt time.Time
+
+	// Close frame sent when the room closes send; set by the room before closing.
+	closeMessage []byte
 }
 
 // ReadPump pumps messages from the websocket connection to the hub.
 func (c *Client) ReadPump() {
 	defer func() {
-		c.hub.unregister <- c
+		c.hub.Unregister(c)
 		c.conn.Close()
 	}()
 
@@ -41,6 +48,7 @@ func (c *Client) ReadPump() {
 	c.conn.SetReadLimit(maxMessageSize)
 	c.conn.SetPongHandler(func(string) error {
 		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+		c.hub.active(c.userID)
 		return nil
 	})
 
@@ -55,12 +63,13 @@ func (c *Client) ReadPump() {
 
 		// Send raw message to handler for processing
 		// The handler will save to DB and then broadcast
-		c.hub.broadcast <- &BroadcastMessage{
+		c.hub.Broadcast(&BroadcastMessage{
 			RoomID:    c.roomID,
 			Message:   message,
 			UserID:    c.userID, // Include userID for processing
 			FromRedis: false,    // This is a new message from client
-		}
+			sender:    c,
+		})
 	}
 }
 
@@ -78,7 +87,11 @@ func (c *Client) WritePump() {
 			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if !ok {
 				// The hub closed the channel.
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				closeMessage := c.closeMessage
+				if closeMessage == nil {
+					closeMessage = []byte{}
+				}
+				c.conn.WriteMessage(websocket.CloseMessage, closeMessage)
 				return
 			}
 
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/GavinHemsada/go-backend/internal/middleware"
 	"github.com/GavinHemsada/go-backend/internal/models"
@@ -34,73 +35,115 @@ func (h *Handler) GetHub() *Hub {
 }
 
 func NewHandler(messageService *services.MessageService, roomService *services.RoomService, redisClient *redis.Client) *Handler {
-	// Create message processor
-	processor := func(msg *BroadcastMessage) *BroadcastMessage {
+	// Create message processor. It runs on the hub's persistence pipeline and
+	// saves all chat messages of a batch with a single CreateMessages call.
+	processor := func(batch []*BroadcastMessage) []*BroadcastMessage {
 		ctx := context.Background()
-		
-		var wsMsg models.WSMessage
-		if err := json.Unmarshal(msg.Message, &wsMsg); err != nil {
-			log.Printf("Error unmarshaling message: %v", err)
-			return nil
-		}
 
-		if wsMsg.Type != "message" {
-			return msg // Forward non-message types as-is
-		}
+		results := make([]*BroadcastMessage, len(batch))
+		var inputs []services.NewMessage
+		var positions []int // index in batch of each input
 
-		// Parse user and room IDs
-		userID, err := uuid.Parse(msg.UserID)
-		if err != nil {
-			log.Printf("Invalid user ID: %v", err)
-			return nil
-		}
+		for i, msg := range batch {
+			var wsMsg models.WSMessage
+			if err := json.Unmarshal(msg.Message, &wsMsg); err != nil {
+				log.Printf("Error unmarshaling message: %v", err)
+				continue
+			}
 
-		roomID, err := uuid.Parse(wsMsg.RoomID)
-		if err != nil {
-			log.Printf("Invalid room ID: %v", err)
-			return nil
-		}
+			// Parse user and room IDs
+			userID, err := uuid.Parse(msg.UserID)
+			if err != nil {
+				log.Printf("Invalid user ID: %v", err)
+				continue
+			}
 
-		content := wsMsg.Content
-		if content == "" {
-			if payloadStr, ok := wsMsg.Payload.(string); ok {
-				content = payloadStr
+			// Checks and persistence use the room of the connection, so a
+			// frame naming another room must not reach that room's checks
+			if wsMsg.RoomID != "" && wsMsg.RoomID != msg.RoomID {
+				results[i] = rejection(msg, "message is for another room")
+				continue
+			}
+			roomID, err := uuid.Parse(msg.RoomID)
+			if err != nil {
+				log.Printf("Invalid room ID: %v", err)
+				continue
 			}
-		}
 
-		if content == "" {
-			log.Printf("Empty message content")
-			return nil
-		}
+			if wsMsg.Type == "typing" {
+				results[i] = typingNotice(ctx, messageService, msg, roomID, userID)
+				continue
+			}
+			if wsMsg.Type != "message" {
+				// Clients send only the types above. Anything else, including
+				// the events the server itself sends, is refused, not relayed.
+				results[i] = rejection(msg, "unsupported message type")
+				continue
+			}
 
-		// Save message to database
-		savedMsg, err := messageService.CreateMessage(ctx, roomID, userID, content, "text")
-		if err != nil {
-			log.Printf("Error saving message: %v", err)
-			return nil
-		}
+			content := wsMsg.Content
+			if content == "" {
+				if payloadStr, ok := wsMsg.Payload.(string); ok {
+					content = payloadStr
+				}
+			}
+
+			if content == "" {
+				log.Printf("Empty message content")
+				continue
+			}
 
-		// Create response with saved message
-		response := models.WSMessageResponse{
-			Type:    "message",
-			Message: savedMsg,
-			UserID:  userID.String(),
-			RoomID:  roomID.String(),
+			inputs = append(inputs, services.NewMessage{
+				RoomID:      roomID,
+				UserID:      userID,
+				Content:     content,
+				MessageType: "text",
+			})
+			positions = append(positions, i)
 		}
 
-		responseBytes, err := json.Marshal(response)
-		if err != nil {
-			log.Printf("Error marshaling response: %v", err)
-			return nil
+		// Save messages to database
+		if len(inputs) > 0 {
+			savedMsgs, errs := messageService.CreateMessages(ctx, inputs)
+			for j, savedMsg := range savedMsgs {
+				if errs[j] != nil {
+					log.Printf("Error saving message: %v", errs[j])
+					results[positions[j]] = rejection(batch[positions[j]], errs[j].Error())
+					continue
+				}
+
+				// Create response with saved message
+				response := models.WSMessageResponse{
+					Type:    "message",
+					Message: savedMsg,
+					UserID:  savedMsg.UserID.String(),
+					RoomID:  savedMsg.RoomID.String(),
+				}
+
+				responseBytes, err := json.Marshal(response)
+				if err != nil {
+					log.Printf("Error marshaling response: %v", err)
+					continue
+				}
+
+				// Processed message (without UserID so it won't be processed again)
+				results[positions[j]] = &BroadcastMessage{
+					RoomID:    batch[positions[j]].RoomID,
+					Message:   responseBytes,
+					UserID:    "",      // Clear UserID to indicate it's processed
+					FromRedis: false,   // This is a new message, not from Redis
+				}
+			}
 		}
 
-		// Return processed message (without UserID so it won't be processed again)
-		return &BroadcastMessage{
-			RoomID:    msg.RoomID,
-			Message:   responseBytes,
-			UserID:    "",      // Clear UserID to indicate it's processed
-			FromRedis: false,   // This is a new message, not from Redis
+		// Keep arrival order, dropping messages that failed
+		processed := results[:0]
+		for _, msg := range results {
+			if msg != nil {
+				processed = append(processed, msg)
+			}
 		}
+		return processed
 	}
 
 	hub := NewHub(redisClient, processor)
@@ -112,6 +155,55 @@ func NewHandler(messageService *services.MessageService, roomService *services.R
 	}
 }
 
+// typingNotice tells the room that the sender of msg is typing. The notice
+// names the user of the connection and carries nothing else from the frame;
+// users who may not post in the room get no notice.
+func typingNotice(ctx context.Context, messageService *services.MessageService, msg *BroadcastMessage, roomID, userID uuid.UUID) *BroadcastMessage {
+	canPost, err := messageService.CanPost(ctx, roomID, userID)
+	if err != nil {
+		log.Printf("Error checking typing notice: %v", err)
+		return nil
+	}
+	if !canPost {
+		return nil
+	}
+
+	response, err := json.Marshal(models.WSMessageResponse{
+		Type:   "typing",
+		UserID: userID.String(),
+		RoomID: msg.RoomID,
+	})
+	if err != nil {
+		log.Printf("Error marshaling typing notice: %v", err)
+		return nil
+	}
+
+	return &BroadcastMessage{
+		RoomID:  msg.RoomID,
+		Message: response,
+	}
+}
+
+// rejection builds an error reply that is delivered only to the sender of msg
+func rejection(msg *BroadcastMessage, reason string) *BroadcastMessage {
+	response, err := json.Marshal(models.WSMessageResponse{
+		Type:   "error",
+		RoomID: msg.RoomID,
+		Error:  reason,
+	})
+	if err != nil {
+		log.Printf("Error marshaling rejection: %v", err)
+		return nil
+	}
+
+	return &BroadcastMessage{
+		RoomID:  msg.RoomID,
+		Message: response,
+		sender:  msg.sender,
+		direct:  true,
+	}
+}
+
 func (h *Handler) ServeWS(w http.ResponseWriter, r *http.Request) {
 	// Get user from JWT token
 	claims, err := middleware.GetUserClaims(r)
@@ -170,19 +262,18 @@ func (h *Handler) ServeWS(w http.ResponseWriter, r *http.Request) {
 	}
 
 	client := &Client{
-		hub:    h.hub,
-		conn:   conn,
-		send:   make(chan []byte, 256),
-		userID: claims.UserID.String(),
-		roomID: roomID.String(),
+		hub:         h.hub,
+		conn:        conn,
+		send:        make(chan []byte, 256),
+		userID:      claims.UserID.String(),
+		roomID:      roomID.String(),
+		remoteAddr:  r.RemoteAddr,
+		connectedAt: time.Now().UTC(),
 	}
 
-	h.hub.register <- client
+	h.hub.Register(client)
 
 	// Start goroutines
 	go client.WritePump()
 	client.ReadPump()
 }
-
-// processIncomingMessages processes messages from the hub broadcast channel
-// This intercepts messages before they're sent to clients, saves them to DB, then rebroadcasts
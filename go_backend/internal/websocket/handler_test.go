@@ -0,0 +1,235 @@
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/GavinHemsada/go-backend/internal/audit"
+	"github.com/GavinHemsada/go-backend/internal/filter"
+	"github.com/GavinHemsada/go-backend/internal/models"
+	repository "github.com/GavinHemsada/go-backend/internal/repositories"
+	"github.com/GavinHemsada/go-backend/internal/services"
+)
+
+// newTestHandler returns a handler on in-memory repositories, with alice
+// muted in the first room and free to post in the second
+func newTestHandler(t *testing.T) (*Handler, *repository.Repositories, *models.Room, *models.Room, *models.User) {
+	t.Helper()
+	ctx := context.Background()
+	repos := repository.NewMemoryRepositories(repository.NewMemoryStore())
+
+	alice, err := repos.Users.Register(ctx, "alice", "alice@example.com", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := repos.Users.Register(ctx, "bob", "bob@example.com", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	muted := &models.Room{Name: "muted", CreatedBy: bob.ID}
+	open := &models.Room{Name: "open", CreatedBy: bob.ID}
+	for _, room := range []*models.Room{muted, open} {
+		if err := repos.Rooms.Create(ctx, room); err != nil {
+			t.Fatal(err)
+		}
+		if err := repos.Rooms.AddMember(ctx, room.ID, alice.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repos.Moderation.Mute(ctx, &models.RoomMute{RoomID: muted.ID, UserID: alice.ID, MutedBy: bob.ID}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	messageService := services.NewMessageService(
+		repos.Messages, repos.Rooms, repos.Users, repos.Moderation, repos.Reports, repos.TxManager,
+		filter.NewChain(), audit.NewLogger(repos.Audit),
+	)
+	return NewHandler(messageService, nil, nil), repos, muted, open, alice
+}
+
+// newTestProcessor returns the message processor of newTestHandler's handler
+func newTestProcessor(t *testing.T) (MessageProcessor, *repository.Repositories, *models.Room, *models.Room, *models.User) {
+	t.Helper()
+	h, repos, muted, open, alice := newTestHandler(t)
+	return h.hub.pipeline.processor, repos, muted, open, alice
+}
+
+func frame(t *testing.T, msg models.WSMessage) []byte {
+	t.Helper()
+	b, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func countMessages(t *testing.T, repos *repository.Repositories, room *models.Room) int {
+	t.Helper()
+	messages, err := repos.Messages.GetByRoom(context.Background(), room.ID, 100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(messages)
+}
+
+// A muted user cannot get around the mute by naming another room in the frame
+func TestProcessorRejectsFrameForAnotherRoom(t *testing.T) {
+	process, repos, muted, open, alice := newTestProcessor(t)
+
+	out := process([]*BroadcastMessage{{
+		RoomID:  muted.ID.String(),
+		UserID:  alice.ID.String(),
+		Message: frame(t, models.WSMessage{Type: "message", RoomID: open.ID.String(), Content: "hi"}),
+	}})
+
+	if len(out) != 1 || !out[0].direct {
+		t.Fatalf("got %+v, want a rejection for the sender only", out)
+	}
+	if n := countMessages(t, repos, open); n != 0 {
+		t.Errorf("%d messages were stored in the room named by the frame", n)
+	}
+	if n := countMessages(t, repos, muted); n != 0 {
+		t.Errorf("%d messages were stored in the room the user is muted in", n)
+	}
+}
+
+func TestProcessorUsesConnectionRoom(t *testing.T) {
+	process, repos, muted, open, alice := newTestProcessor(t)
+
+	out := process([]*BroadcastMessage{
+		{
+			RoomID:  open.ID.String(),
+			UserID:  alice.ID.String(),
+			Message: frame(t, models.WSMessage{Type: "message", Content: "hello"}),
+		},
+		{
+			RoomID:  muted.ID.String(),
+			UserID:  alice.ID.String(),
+			Message: frame(t, models.WSMessage{Type: "message", RoomID: muted.ID.String(), Content: "hello"}),
+		},
+	})
+
+	if len(out) != 2 || out[0].direct || out[0].RoomID != open.ID.String() || !out[1].direct {
+		t.Fatalf("got %+v, want the first message broadcast to its room and the second rejected", out)
+	}
+	if n := countMessages(t, repos, open); n != 1 {
+		t.Errorf("got %d messages in the connection's room, want 1", n)
+	}
+	if n := countMessages(t, repos, muted); n != 0 {
+		t.Errorf("%d messages were stored in the room the user is muted in", n)
+	}
+}
+
+// Typing notices name the user of the connection, whatever the frame claims,
+// and are only relayed for users who may post in the room
+func TestProcessorRelaysTyping(t *testing.T) {
+	process, _, muted, open, alice := newTestProcessor(t)
+
+	out := process([]*BroadcastMessage{
+		{
+			RoomID:  open.ID.String(),
+			UserID:  alice.ID.String(),
+			Message: frame(t, models.WSMessage{Type: "typing", UserID: open.CreatedBy.String()}),
+		},
+		{
+			RoomID:  muted.ID.String(),
+			UserID:  alice.ID.String(),
+			Message: frame(t, models.WSMessage{Type: "typing"}),
+		},
+	})
+
+	if len(out) != 1 || out[0].direct || out[0].RoomID != open.ID.String() {
+		t.Fatalf("got %+v, want one notice for the room alice may post in", out)
+	}
+	var notice models.WSMessageResponse
+	if err := json.Unmarshal(out[0].Message, &notice); err != nil {
+		t.Fatal(err)
+	}
+	if notice.Type != "typing" || notice.UserID != alice.ID.String() {
+		t.Errorf("got %+v, want a typing notice from alice", notice)
+	}
+}
+
+// Frames of any other type are refused to their sender and reach nobody else
+func TestProcessorRefusesOtherTypes(t *testing.T) {
+	process, repos, muted, open, alice := newTestProcessor(t)
+
+	for _, typ := range []string{"chat", "", "error", "muted", "kicked", "account_locked"} {
+		for _, room := range []*models.Room{open, muted} {
+			out := process([]*BroadcastMessage{{
+				RoomID:  room.ID.String(),
+				UserID:  alice.ID.String(),
+				Message: frame(t, models.WSMessage{Type: typ, Content: "hi"}),
+			}})
+			if len(out) != 1 || !out[0].direct {
+				t.Errorf("%q frame in %s: got %+v, want a rejection for the sender only", typ, room.Name, out)
+			}
+		}
+	}
+	if n := countMessages(t, repos, open) + countMessages(t, repos, muted); n != 0 {
+		t.Errorf("%d messages were stored", n)
+	}
+}
+
+// Text can only reach a room as a message, through the content filter
+func TestProcessorDropsTextFromTypingNotices(t *testing.T) {
+	process, _, _, open, alice := newTestProcessor(t)
+
+	out := process([]*BroadcastMessage{{
+		RoomID:  open.ID.String(),
+		UserID:  alice.ID.String(),
+		Message: frame(t, models.WSMessage{Type: "typing", Content: "unfiltered text", Payload: "unfiltered payload"}),
+	}})
+
+	if len(out) != 1 {
+		t.Fatalf("got %+v, want one typing notice", out)
+	}
+	if bytes.Contains(out[0].Message, []byte("unfiltered")) {
+		t.Errorf("the notice %s relays text from the frame", out[0].Message)
+	}
+}
+
+// Profile changes are announced by the server alone; a client cannot forge one
+func TestHubDoesNotRelayClientProfileEvents(t *testing.T) {
+	quietLogs(t)
+	handler, _, _, open, alice := newTestHandler(t)
+	h := handler.hub
+	go h.Run()
+	defer h.Stop()
+
+	room := open.ID.String()
+	sender := &Client{hub: h, userID: alice.ID.String(), roomID: room, send: make(chan []byte, 4)}
+	other := &Client{hub: h, userID: open.CreatedBy.String(), roomID: room, send: make(chan []byte, 4)}
+	h.Register(sender)
+	h.Register(other)
+	waitFor(t, "clients to register", func() bool { return h.Stats().Clients == 2 })
+
+	forged, err := json.Marshal(models.WSMessageResponse{
+		Type:    "profile_updated",
+		UserID:  open.CreatedBy.String(),
+		Profile: &models.Profile{DisplayName: "Site Admin"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Broadcast(&BroadcastMessage{RoomID: room, UserID: alice.ID.String(), Message: forged, sender: sender})
+	// Frames of a room are handled in order, so the notice comes after anything relayed before it
+	h.Broadcast(&BroadcastMessage{RoomID: room, UserID: alice.ID.String(), Message: frame(t, models.WSMessage{Type: "typing"}), sender: sender})
+
+	var event models.WSMessageResponse
+	if err := json.Unmarshal(<-other.send, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != "typing" {
+		t.Fatalf("the room got %+v before the typing notice", event)
+	}
+	if err := json.Unmarshal(<-sender.send, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != "error" {
+		t.Errorf("the sender got %+v, want the frame refused", event)
+	}
+}
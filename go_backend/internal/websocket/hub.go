@@ -3,140 +3,468 @@ package websocket
 import (
 	"context"
 	"encoding/json"
+	"hash/fnv"
 	"log"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
 	"github.com/redis/go-redis/v9"
 )
 
+const (
+	// Number of persistence workers; rooms are spread across them by hash.
+	persistWorkers = 8
+
+	// Messages each persistence worker can queue before senders are told to back off.
+	persistQueueSize = 256
+
+	// Maximum number of queued messages persisted in one batch.
+	persistMaxBatch = 100
+
+	// Number of shards the room index is split into.
+	hubShards = 64
+
+	// Redis channel carrying moderation actions between server instances.
+	controlChannel = "chat:control"
+
+	// How long Clients waits for busy rooms to report their connections.
+	snapshotTimeout = 2 * time.Second
+)
+
 type BroadcastMessage struct {
 	RoomID     string
 	Message    []byte
-	UserID     string // For processing incoming messages
-	FromRedis  bool   // Flag to prevent republishing messages from Redis
+	UserID     string  // For processing incoming messages
+	FromRedis  bool    // Flag to prevent republishing messages from Redis
+	sender     *Client // Client the raw message came from, for backpressure errors
+	direct     bool    // Deliver only to sender, e.g. a rejection notice
+}
+
+// controlMessage asks every server instance to act on one user's connections to a room
+type controlMessage struct {
+	Origin  string `json:"origin"` // ID of the publishing hub, which already applied it
+	Action  string `json:"action"` // "disconnect", "disconnect_all", "notify", "notify_all" or "close_room"
+	RoomID  string `json:"room_id,omitempty"`
+	UserID  string `json:"user_id,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+	Payload []byte `json:"payload,omitempty"`
 }
 
-type MessageProcessor func(*BroadcastMessage) *BroadcastMessage
+// MessageProcessor turns a batch of raw client messages into broadcast-ready
+// messages. Messages that should not be broadcast are left out of the result.
+type MessageProcessor func([]*BroadcastMessage) []*BroadcastMessage
 
+// Hub routes messages between clients, the persistence pipeline and Redis.
+//
+// Every room with local clients is run by its own goroutine (see room) that
+// owns the room's client set, so registrations and fan-out in one room never
+// wait on another room. The hub itself only keeps an index of the running
+// rooms, split into shards by room ID to keep lock contention low.
 type Hub struct {
-	clients         map[string]map[*Client]bool // roomID -> clients
-	broadcast       chan *BroadcastMessage
-	register        chan *Client
-	unregister      chan *Client
-	mu              sync.RWMutex
-	redisPub        *redis.Client
-	redisSub        *redis.PubSub
-	messageProcessor MessageProcessor
+	id       string
+	shards   [hubShards]hubShard
+	redisPub *redis.Client
+	redisSub *redis.PubSub
+	pipeline *Pipeline
+	ctx      context.Context
+	cancel   context.CancelFunc
+	onActive func(userID uuid.UUID) // called when a client answers a ping
+}
+
+type hubShard struct {
+	mu    sync.RWMutex
+	rooms map[string]*room
 }
 
 func NewHub(redisClient *redis.Client, processor MessageProcessor) *Hub {
-	return &Hub{
-		clients:          make(map[string]map[*Client]bool),
-		broadcast:        make(chan *BroadcastMessage, 256),
-		register:         make(chan *Client),
-		unregister:       make(chan *Client),
-		redisPub:         redisClient,
-		messageProcessor: processor,
+	ctx, cancel := context.WithCancel(context.Background())
+	h := &Hub{
+		id:       uuid.NewString(),
+		redisPub: redisClient,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	for i := range h.shards {
+		h.shards[i].rooms = make(map[string]*room)
+	}
+	if processor != nil {
+		h.pipeline = NewPipeline(persistWorkers, persistQueueSize, persistMaxBatch, processor, h.publish)
 	}
+	return h
 }
 
+// OnActive makes the hub call fn in the background whenever a client answers
+// a ping, so open connections keep their user active. Call it before clients connect.
+func (h *Hub) OnActive(fn func(userID uuid.UUID)) {
+	h.onActive = fn
+}
+
+// active reports a client's user as active
+func (h *Hub) active(userID string) {
+	if h.onActive == nil {
+		return
+	}
+	if id, err := uuid.Parse(userID); err == nil {
+		go h.onActive(id)
+	}
+}
+
+// Run starts the persistence pipeline and the Redis listener, then blocks until Stop is called.
 func (h *Hub) Run() {
-	ctx := context.Background()
-	
 	// Subscribe to Redis for messages from other server instances (if Redis is available)
 	if h.redisPub != nil {
 		// Subscribe to a pattern that matches all room channels
-		h.redisSub = h.redisPub.PSubscribe(ctx, "chat:room:*")
+		h.redisSub = h.redisPub.PSubscribe(h.ctx, "chat:room:*")
+		// And to moderation actions taken on other instances
+		if err := h.redisSub.Subscribe(h.ctx, controlChannel); err != nil {
+			log.Printf("Error subscribing to Redis control channel: %v", err)
+		}
 		// Start Redis listener in goroutine
-		go h.listenRedis(ctx)
+		go h.listenRedis(h.ctx)
 		log.Println("Redis pub/sub initialized for WebSocket")
 	}
-    
-    for {
-        select {
-        case client := <-h.register:
-            h.mu.Lock()
-            if h.clients[client.roomID] == nil {
-                h.clients[client.roomID] = make(map[*Client]bool)
-            }
-            h.clients[client.roomID][client] = true
-            h.mu.Unlock()
-            log.Printf("Client registered to room %s", client.roomID)
-            
-        case client := <-h.unregister:
-            h.mu.Lock()
-            if clients, ok := h.clients[client.roomID]; ok {
-                if _, ok := clients[client]; ok {
-                    delete(clients, client)
-                    close(client.send)
-                    if len(clients) == 0 {
-                        delete(h.clients, client.roomID)
-                    }
-                }
-            }
-            h.mu.Unlock()
-            log.Printf("Client unregistered from room %s", client.roomID)
-            
-        case message := <-h.broadcast:
-            // Process message if processor is available
-            if h.messageProcessor != nil && message.UserID != "" {
-                processedMsg := h.messageProcessor(message)
-                if processedMsg != nil {
-                    message = processedMsg
-                }
-            }
-            
-            // Send to local clients first
-            h.sendToLocalClients(message)
-            
-            // Publish to Redis for other server instances (if Redis is available)
-            // Only publish if:
-            // 1. Message is processed (UserID is empty means it's ready to broadcast)
-            // 2. Message didn't come from Redis (FromRedis is false)
-            if h.redisPub != nil && message.UserID == "" && !message.FromRedis {
-                // Serialize the full BroadcastMessage for Redis
-                messageBytes, err := json.Marshal(message)
-                if err != nil {
-                    log.Printf("Error marshaling message for Redis: %v", err)
-                } else {
-                    // Use room-specific channel to avoid cross-room message leakage
-                   	channel := "chat:room:" + message.RoomID
-                   	if err := h.redisPub.Publish(ctx, channel, messageBytes).Err(); err != nil {
-                   		log.Printf("Error publishing to Redis: %v", err)
-                   	} else {
-                   		log.Printf("Published message to Redis channel: %s", channel)
-                   	}
-                }
-            }
-        }
-    }
+
+	if h.pipeline != nil {
+		h.pipeline.Start()
+	}
+
+	<-h.ctx.Done()
+}
+
+// Stop flushes messages still waiting to be persisted and stops the Redis listener
+func (h *Hub) Stop() {
+	if h.pipeline != nil {
+		h.pipeline.Stop()
+	}
+	if h.redisSub != nil {
+		h.redisSub.Close()
+	}
+	h.cancel()
+}
+
+// Register adds a client to its room, starting the room if needed
+func (h *Hub) Register(client *Client) {
+	h.post(client.roomID, roomEvent{kind: eventRegister, client: client}, true)
+}
+
+// Unregister removes a client from its room and closes its send channel
+func (h *Hub) Unregister(client *Client) {
+	h.post(client.roomID, roomEvent{kind: eventUnregister, client: client}, false)
+}
+
+// Broadcast accepts a message read from a client. Raw client messages go to
+// the persistence pipeline and are broadcast once saved; processed messages
+// are broadcast right away.
+func (h *Hub) Broadcast(message *BroadcastMessage) {
+	if h.pipeline != nil && message.UserID != "" {
+		if !h.pipeline.Submit(message) {
+			h.rejectBusy(message)
+		}
+		return
+	}
+	h.publish(message)
+}
+
+// publish sends a processed message to local clients and, unless it came
+// from there, to the other server instances through Redis
+func (h *Hub) publish(message *BroadcastMessage) {
+	if message.direct {
+		if message.sender != nil {
+			h.post(message.sender.roomID, roomEvent{kind: eventDirect, client: message.sender, payload: message.Message}, false)
+		}
+		return
+	}
+
+	// Send to local clients first
+	h.sendToLocalClients(message)
+
+	// Publish to Redis for other server instances (if Redis is available)
+	// Only publish if:
+	// 1. Message is processed (UserID is empty means it's ready to broadcast)
+	// 2. Message didn't come from Redis (FromRedis is false)
+	if h.redisPub != nil && message.UserID == "" && !message.FromRedis {
+		// Serialize the full BroadcastMessage for Redis
+		messageBytes, err := json.Marshal(message)
+		if err != nil {
+			log.Printf("Error marshaling message for Redis: %v", err)
+			return
+		}
+
+		// Use room-specific channel to avoid cross-room message leakage
+		channel := "chat:room:" + message.RoomID
+		if err := h.redisPub.Publish(h.ctx, channel, messageBytes).Err(); err != nil {
+			log.Printf("Error publishing to Redis: %v", err)
+		} else {
+			log.Printf("Published message to Redis channel: %s", channel)
+		}
+	}
+}
+
+// rejectBusy tells the sender of a message that could not be queued to retry later
+func (h *Hub) rejectBusy(message *BroadcastMessage) {
+	client := message.sender
+	if client == nil {
+		return
+	}
+
+	response, err := json.Marshal(models.WSMessageResponse{
+		Type:   "error",
+		RoomID: message.RoomID,
+		Error:  "server busy, message was not delivered, please retry",
+	})
+	if err != nil {
+		log.Printf("Error marshaling busy response: %v", err)
+		return
+	}
+
+	h.post(client.roomID, roomEvent{kind: eventDirect, client: client, payload: response}, false)
+}
+
+// DisconnectUser closes the user's connections to a room on every server instance
+func (h *Hub) DisconnectUser(roomID, userID uuid.UUID, reason string) {
+	h.control(controlMessage{
+		Action: "disconnect",
+		RoomID: roomID.String(),
+		UserID: userID.String(),
+		Reason: reason,
+	})
+}
+
+// DisconnectAll closes every connection of the user, in every room, on every server instance
+func (h *Hub) DisconnectAll(userID uuid.UUID, reason string) {
+	h.control(controlMessage{
+		Action: "disconnect_all",
+		UserID: userID.String(),
+		Reason: reason,
+	})
+}
+
+// NotifyUser sends event to the user's connections to a room on every server instance
+func (h *Hub) NotifyUser(roomID, userID uuid.UUID, event models.WSMessageResponse) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Error marshaling notification: %v", err)
+		return
+	}
+
+	h.control(controlMessage{
+		Action:  "notify",
+		RoomID:  roomID.String(),
+		UserID:  userID.String(),
+		Payload: payload,
+	})
+}
+
+// NotifyAll sends event to every connection of the user, in every room, on every server instance
+func (h *Hub) NotifyAll(userID uuid.UUID, event models.WSMessageResponse) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Error marshaling notification: %v", err)
+		return
+	}
+
+	h.control(controlMessage{
+		Action:  "notify_all",
+		UserID:  userID.String(),
+		Payload: payload,
+	})
+}
+
+// NotifyRoom sends event to every connection to a room on every server instance
+func (h *Hub) NotifyRoom(roomID uuid.UUID, event models.WSMessageResponse) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Error marshaling notification: %v", err)
+		return
+	}
+
+	// Rooms already fan processed messages out through Redis
+	h.publish(&BroadcastMessage{RoomID: roomID.String(), Message: payload})
+}
+
+// CloseRoom closes every connection to a room on every server instance
+func (h *Hub) CloseRoom(roomID uuid.UUID, reason string) {
+	h.control(controlMessage{
+		Action: "close_room",
+		RoomID: roomID.String(),
+		Reason: reason,
+	})
+}
+
+// Clients lists the connections held by this server instance, oldest first.
+// Rooms that do not answer within snapshotTimeout are left out.
+func (h *Hub) Clients() models.HubClients {
+	result := models.HubClients{HubID: h.id, Clients: []models.ConnectedClient{}}
+	deadline := time.After(snapshotTimeout)
+
+	for i := range h.shards {
+		shard := &h.shards[i]
+
+		// Queue under the read lock so no room can stop before it answers
+		shard.mu.RLock()
+		reply := make(chan []models.ConnectedClient, len(shard.rooms))
+		for _, r := range shard.rooms {
+			r.enqueue(roomEvent{kind: eventSnapshot, reply: reply})
+		}
+		pending := len(shard.rooms)
+		shard.mu.RUnlock()
+
+		for ; pending > 0; pending-- {
+			select {
+			case clients := <-reply:
+				result.Clients = append(result.Clients, clients...)
+			case <-deadline:
+				log.Printf("Timed out listing hub clients")
+				return sortClients(result)
+			}
+		}
+	}
+
+	return sortClients(result)
+}
+
+// Stats counts the rooms and connections held by this server instance
+func (h *Hub) Stats() models.HubStats {
+	clients := h.Clients()
+	rooms := make(map[uuid.UUID]struct{})
+	for _, c := range clients.Clients {
+		rooms[c.RoomID] = struct{}{}
+	}
+	return models.HubStats{
+		HubID:       h.id,
+		ActiveRooms: len(rooms),
+		Clients:     len(clients.Clients),
+	}
+}
+
+func sortClients(c models.HubClients) models.HubClients {
+	sort.Slice(c.Clients, func(i, j int) bool {
+		return c.Clients[i].ConnectedAt.Before(c.Clients[j].ConnectedAt)
+	})
+	return c
+}
+
+// control applies msg locally and forwards it to the other instances
+func (h *Hub) control(msg controlMessage) {
+	h.applyControl(msg)
+
+	if h.redisPub == nil {
+		return
+	}
+
+	msg.Origin = h.id
+	messageBytes, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("Error marshaling control message: %v", err)
+		return
+	}
+	if err := h.redisPub.Publish(h.ctx, controlChannel, messageBytes).Err(); err != nil {
+		log.Printf("Error publishing control message to Redis: %v", err)
+	}
+}
+
+func (h *Hub) applyControl(msg controlMessage) {
+	switch msg.Action {
+	case "disconnect":
+		closeMessage := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, msg.Reason)
+		h.post(msg.RoomID, roomEvent{kind: eventDisconnectUser, userID: msg.UserID, payload: closeMessage}, false)
+	case "disconnect_all":
+		closeMessage := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, msg.Reason)
+		h.postAll(roomEvent{kind: eventDisconnectUser, userID: msg.UserID, payload: closeMessage})
+	case "notify":
+		h.post(msg.RoomID, roomEvent{kind: eventNotifyUser, userID: msg.UserID, payload: msg.Payload}, false)
+	case "notify_all":
+		h.postAll(roomEvent{kind: eventNotifyUser, userID: msg.UserID, payload: msg.Payload})
+	case "close_room":
+		closeMessage := websocket.FormatCloseMessage(websocket.CloseGoingAway, msg.Reason)
+		h.post(msg.RoomID, roomEvent{kind: eventCloseRoom, payload: closeMessage}, false)
+	default:
+		log.Printf("Unknown control action: %s", msg.Action)
+	}
 }
 
 func (h *Hub) sendToLocalClients(message *BroadcastMessage) {
-    h.mu.RLock()
-    defer h.mu.RUnlock()
-    
-    if clients, ok := h.clients[message.RoomID]; ok {
-        for client := range clients {
-            select {
-            case client.send <- message.Message:
-            default:
-                close(client.send)
-                delete(clients, client)
-            }
-        }
-    }
+	h.post(message.RoomID, roomEvent{kind: eventBroadcast, payload: message.Message}, false)
+}
+
+// post queues ev on the room's goroutine. Rooms without local clients are
+// only started when create is set; otherwise the event is dropped.
+func (h *Hub) post(roomID string, ev roomEvent, create bool) {
+	shard := h.shard(roomID)
+
+	shard.mu.RLock()
+	r := shard.rooms[roomID]
+	if r != nil {
+		r.enqueue(ev)
+		shard.mu.RUnlock()
+		return
+	}
+	shard.mu.RUnlock()
+
+	if !create {
+		return
+	}
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	r = shard.rooms[roomID]
+	if r == nil {
+		r = newRoom(roomID, shard)
+		shard.rooms[roomID] = r
+		go r.run()
+	}
+	r.enqueue(ev)
+}
+
+// postAll queues ev on every running room
+func (h *Hub) postAll(ev roomEvent) {
+	for i := range h.shards {
+		shard := &h.shards[i]
+		shard.mu.RLock()
+		for _, r := range shard.rooms {
+			r.enqueue(ev)
+		}
+		shard.mu.RUnlock()
+	}
+}
+
+func (h *Hub) shard(roomID string) *hubShard {
+	return &h.shards[shardIndex(roomID, hubShards)]
+}
+
+// shardIndex maps a room ID onto one of n shards
+func shardIndex(roomID string, n int) int {
+	hash := fnv.New32a()
+	hash.Write([]byte(roomID))
+	return int(hash.Sum32() % uint32(n))
 }
 
 func (h *Hub) listenRedis(ctx context.Context) {
 	if h.redisSub == nil {
 		return
 	}
-	
+
 	ch := h.redisSub.Channel()
-	
+
 	for msg := range ch {
+		if msg.Channel == controlChannel {
+			var controlMsg controlMessage
+			if err := json.Unmarshal([]byte(msg.Payload), &controlMsg); err != nil {
+				log.Printf("Error unmarshaling Redis control message: %v", err)
+				continue
+			}
+			// The publishing instance applied it already
+			if controlMsg.Origin != h.id {
+				h.applyControl(controlMsg)
+			}
+			continue
+		}
+
 		// Extract room ID from channel name (format: chat:room:{roomID})
 		// For PSubscribe, msg.Channel contains the actual channel name that matched the pattern
 		prefix := "chat:room:"
@@ -149,25 +477,25 @@ func (h *Hub) listenRedis(ctx context.Context) {
 			log.Printf("Empty room ID in channel: %s", msg.Channel)
 			continue
 		}
-		
+
 		var broadcastMsg BroadcastMessage
 		if err := json.Unmarshal([]byte(msg.Payload), &broadcastMsg); err != nil {
 			log.Printf("Error unmarshaling Redis message: %v", err)
 			continue
 		}
-		
+
 		// Ensure RoomID matches (safety check)
 		if broadcastMsg.RoomID != roomID {
 			broadcastMsg.RoomID = roomID
 		}
-		
+
 		// Mark as from Redis to prevent republishing
 		broadcastMsg.FromRedis = true
 		broadcastMsg.UserID = "" // Ensure it's not processed again
-		
+
 		// Don't process again, just broadcast to local clients
 		// This message came from another server instance and was already processed there
 		log.Printf("Received message from Redis for room: %s", roomID)
 		h.sendToLocalClients(&broadcastMsg)
 	}
-}
\ No newline at end of file
+}
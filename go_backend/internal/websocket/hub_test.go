@@ -0,0 +1,175 @@
+package websocket
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// quietLogs discards the hub's per-client log lines for the rest of the test
+func quietLogs(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+}
+
+// waitFor polls cond until it holds, failing the test after a while
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(30 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// runningRooms counts the room actors the hub still has
+func runningRooms(h *Hub) int {
+	n := 0
+	for i := range h.shards {
+		h.shards[i].mu.RLock()
+		n += len(h.shards[i].rooms)
+		h.shards[i].mu.RUnlock()
+	}
+	return n
+}
+
+// loadTest connects clientsPerRoom clients to each of rooms rooms, every
+// slowEvery-th of them a slow consumer that never reads, and broadcasts
+// broadcasts messages to every room from concurrent senders. Clients don't
+// run pumps: fast ones have room for every message, slow ones for a few.
+func loadTest(t *testing.T, rooms, clientsPerRoom, slowEvery, broadcasts int) {
+	quietLogs(t)
+	const slowBuffer = 8
+
+	h := NewHub(nil, nil)
+	defer h.Stop()
+
+	ids := roomIDs(rooms)
+	var fast, slow []*Client
+	for _, id := range ids {
+		for i := 0; i < clientsPerRoom; i++ {
+			c := &Client{hub: h, userID: fmt.Sprintf("user-%d", i), roomID: id, connectedAt: time.Now()}
+			if i%slowEvery == slowEvery-1 {
+				c.send = make(chan []byte, slowBuffer)
+				slow = append(slow, c)
+			} else {
+				c.send = make(chan []byte, broadcasts)
+				fast = append(fast, c)
+			}
+			h.Register(c)
+		}
+	}
+	waitFor(t, "clients to register", func() bool { return h.Stats().Clients == len(fast)+len(slow) })
+
+	const senders = 8
+	var wg sync.WaitGroup
+	for s := 0; s < senders; s++ {
+		wg.Add(1)
+		go func(s int) {
+			defer wg.Done()
+			for i := s; i < broadcasts; i += senders {
+				for _, id := range ids {
+					h.Broadcast(&BroadcastMessage{RoomID: id, Message: []byte(id + ":" + fmt.Sprint(i))})
+				}
+			}
+		}(s)
+	}
+	wg.Wait()
+
+	// Rooms handle events in order, so once every room answered the
+	// snapshot all broadcasts before it were delivered
+	stats := h.Stats()
+	if stats.ActiveRooms != rooms || stats.Clients != len(fast) {
+		t.Fatalf("got %d rooms with %d clients, want %d rooms with the %d fast clients",
+			stats.ActiveRooms, stats.Clients, rooms, len(fast))
+	}
+
+	for _, c := range fast {
+		if len(c.send) != broadcasts {
+			t.Fatalf("fast client in %s got %d messages, want %d", c.roomID, len(c.send), broadcasts)
+		}
+		for i := 0; i < broadcasts; i++ {
+			if msg := <-c.send; !bytes.HasPrefix(msg, []byte(c.roomID+":")) {
+				t.Fatalf("client in %s got %q", c.roomID, msg)
+			}
+		}
+	}
+
+	want := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "slow consumer")
+	for _, c := range slow {
+		received := 0
+		for range c.send {
+			received++
+		}
+		if received != slowBuffer {
+			t.Fatalf("slow client got %d messages before it was dropped, want %d", received, slowBuffer)
+		}
+		if !bytes.Equal(c.closeMessage, want) {
+			t.Fatalf("slow client got close frame %q, want %q", c.closeMessage, want)
+		}
+	}
+
+	for _, c := range fast {
+		h.Unregister(c)
+	}
+	for _, c := range slow {
+		h.Unregister(c) // already dropped, must be a no-op
+	}
+	waitFor(t, "rooms to stop", func() bool { return runningRooms(h) == 0 })
+}
+
+func TestHubLoadOneLargeRoom(t *testing.T) {
+	if testing.Short() {
+		t.Skip("load test")
+	}
+	loadTest(t, 1, 10000, 100, 50)
+}
+
+func TestHubLoadManyRooms(t *testing.T) {
+	if testing.Short() {
+		t.Skip("load test")
+	}
+	loadTest(t, 2000, 5, 5, 20)
+}
+
+func TestHubDropsSlowConsumer(t *testing.T) {
+	quietLogs(t)
+	h := NewHub(nil, nil)
+	defer h.Stop()
+
+	fast := &Client{hub: h, userID: "fast", roomID: "room", send: make(chan []byte, 4)}
+	slow := &Client{hub: h, userID: "slow", roomID: "room", send: make(chan []byte, 1)}
+	h.Register(fast)
+	h.Register(slow)
+
+	h.Broadcast(&BroadcastMessage{RoomID: "room", Message: []byte("one")})
+	h.Broadcast(&BroadcastMessage{RoomID: "room", Message: []byte("two")})
+
+	if got := h.Stats().Clients; got != 1 {
+		t.Fatalf("got %d clients, want only the fast one", got)
+	}
+	if msg := <-slow.send; string(msg) != "one" {
+		t.Fatalf("slow client got %q, want one", msg)
+	}
+	if _, ok := <-slow.send; ok {
+		t.Fatal("slow client's send channel is still open")
+	}
+	if len(fast.send) != 2 {
+		t.Fatalf("fast client got %d messages, want 2", len(fast.send))
+	}
+
+	// A later broadcast or unregister must not touch the closed channel
+	h.Broadcast(&BroadcastMessage{RoomID: "room", Message: []byte("three")})
+	h.Unregister(slow)
+	h.Unregister(fast)
+	waitFor(t, "the room to stop", func() bool { return runningRooms(h) == 0 })
+}
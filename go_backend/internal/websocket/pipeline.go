@@ -0,0 +1,119 @@
+package websocket
+
+import (
+	"sync"
+)
+
+// Pipeline persists incoming client messages off the hub loop.
+//
+// Messages are sharded by room onto a fixed set of workers, so messages of
+// one room are always handled by the same worker in arrival order while
+// different rooms proceed in parallel. Each shard has a bounded queue; when it
+// is full Submit refuses the message instead of blocking the hub. A worker
+// takes everything already waiting in its queue (up to maxBatch) and hands it
+// to the processor as one batch, so batches grow with load and stay at a
+// single message when the server is idle.
+type Pipeline struct {
+	shards    []chan *BroadcastMessage
+	maxBatch  int
+	processor MessageProcessor
+	deliver   func(*BroadcastMessage)
+	wg        sync.WaitGroup
+
+	// Held for reading while a message is queued, so Stop cannot close a
+	// queue under a Submit still running on a connection's read loop
+	mu      sync.RWMutex
+	stopped bool
+}
+
+// NewPipeline creates a pipeline with the given number of workers, each with
+// a queue of queueSize messages. Processed messages are passed to deliver.
+func NewPipeline(workers, queueSize, maxBatch int, processor MessageProcessor, deliver func(*BroadcastMessage)) *Pipeline {
+	if workers < 1 {
+		workers = 1
+	}
+	if maxBatch < 1 {
+		maxBatch = 1
+	}
+
+	p := &Pipeline{
+		shards:    make([]chan *BroadcastMessage, workers),
+		maxBatch:  maxBatch,
+		processor: processor,
+		deliver:   deliver,
+	}
+	for i := range p.shards {
+		p.shards[i] = make(chan *BroadcastMessage, queueSize)
+	}
+	return p
+}
+
+// Start launches the workers
+func (p *Pipeline) Start() {
+	for _, shard := range p.shards {
+		p.wg.Add(1)
+		go p.work(shard)
+	}
+}
+
+// Submit queues msg for processing. It never blocks and returns false when
+// the room's shard is full, so the caller can tell the sender to back off,
+// or once the pipeline is stopped.
+func (p *Pipeline) Submit(msg *BroadcastMessage) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.stopped {
+		return false
+	}
+
+	select {
+	case p.shard(msg.RoomID) <- msg:
+		return true
+	default:
+		return false
+	}
+}
+
+// Stop stops accepting work and waits for queued messages to be processed
+func (p *Pipeline) Stop() {
+	p.mu.Lock()
+	if !p.stopped {
+		p.stopped = true
+		for _, shard := range p.shards {
+			close(shard)
+		}
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+}
+
+func (p *Pipeline) shard(roomID string) chan *BroadcastMessage {
+	return p.shards[shardIndex(roomID, len(p.shards))]
+}
+
+func (p *Pipeline) work(queue chan *BroadcastMessage) {
+	defer p.wg.Done()
+
+	batch := make([]*BroadcastMessage, 0, p.maxBatch)
+	for msg := range queue {
+		batch = append(batch[:0], msg)
+
+		// Take whatever else is already waiting without blocking
+	drain:
+		for len(batch) < p.maxBatch {
+			select {
+			case next, ok := <-queue:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, next)
+			default:
+				break drain
+			}
+		}
+
+		for _, out := range p.processor(batch) {
+			p.deliver(out)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package websocket
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// roomIDs returns n distinct room IDs
+func roomIDs(n int) []string {
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = uuid.NewString()
+	}
+	return ids
+}
+
+// submitAll submits msg, retrying while its shard is full
+func submitAll(p *Pipeline, msg *BroadcastMessage) {
+	for !p.Submit(msg) {
+		runtime.Gosched()
+	}
+}
+
+func TestPipelineKeepsRoomOrder(t *testing.T) {
+	const rooms, perRoom = 20, 200
+
+	var mu sync.Mutex
+	got := make(map[string][]int)
+	var done sync.WaitGroup
+	done.Add(rooms * perRoom)
+
+	p := NewPipeline(4, 16, 8, func(batch []*BroadcastMessage) []*BroadcastMessage { return batch }, func(msg *BroadcastMessage) {
+		mu.Lock()
+		got[msg.RoomID] = append(got[msg.RoomID], int(msg.Message[0])<<8|int(msg.Message[1]))
+		mu.Unlock()
+		done.Done()
+	})
+	p.Start()
+
+	ids := roomIDs(rooms)
+	for i := 0; i < perRoom; i++ {
+		for _, id := range ids {
+			submitAll(p, &BroadcastMessage{RoomID: id, Message: []byte{byte(i >> 8), byte(i)}})
+		}
+	}
+	done.Wait()
+	p.Stop()
+
+	for _, id := range ids {
+		seq := got[id]
+		if len(seq) != perRoom {
+			t.Fatalf("room %s: got %d messages, want %d", id, len(seq), perRoom)
+		}
+		for i, n := range seq {
+			if n != i {
+				t.Fatalf("room %s: message %d arrived at position %d", id, n, i)
+			}
+		}
+	}
+}
+
+func TestPipelineRefusesWhenFull(t *testing.T) {
+	release := make(chan struct{})
+	p := NewPipeline(1, 2, 1, func(batch []*BroadcastMessage) []*BroadcastMessage {
+		<-release
+		return batch
+	}, func(*BroadcastMessage) {})
+	p.Start()
+	defer p.Stop()
+	defer close(release)
+
+	// One message is held by the worker, two fill the queue
+	accepted := 0
+	deadline := time.Now().Add(time.Second)
+	for accepted < 3 && time.Now().Before(deadline) {
+		if p.Submit(&BroadcastMessage{RoomID: "room"}) {
+			accepted++
+		}
+	}
+	if accepted != 3 {
+		t.Fatalf("accepted %d messages, want 3", accepted)
+	}
+	if p.Submit(&BroadcastMessage{RoomID: "room"}) {
+		t.Fatal("Submit accepted a message while the shard was full")
+	}
+}
+
+// Connections keep submitting while the server shuts down; Stop must neither
+// make them panic nor lose a message it accepted
+func TestPipelineSubmitDuringStop(t *testing.T) {
+	var delivered atomic.Int64
+	p := NewPipeline(4, 16, 8, func(batch []*BroadcastMessage) []*BroadcastMessage { return batch }, func(*BroadcastMessage) {
+		delivered.Add(1)
+	})
+	p.Start()
+
+	const senders = 16
+	var accepted atomic.Int64
+	var wg sync.WaitGroup
+	ids := roomIDs(senders)
+	for _, id := range ids {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			for i := 0; i < 10000; i++ {
+				if p.Submit(&BroadcastMessage{RoomID: id}) {
+					accepted.Add(1)
+				}
+			}
+		}(id)
+	}
+	time.Sleep(time.Millisecond)
+	p.Stop()
+	wg.Wait()
+
+	if delivered.Load() != accepted.Load() {
+		t.Errorf("delivered %d of %d accepted messages", delivered.Load(), accepted.Load())
+	}
+	if p.Submit(&BroadcastMessage{RoomID: ids[0]}) {
+		t.Error("Submit accepted a message after Stop")
+	}
+	p.Stop() // a second Stop must be harmless
+}
+
+// BenchmarkPipelineSubmit measures how fast concurrent senders spread over
+// many rooms can queue messages while the workers keep up
+func BenchmarkPipelineSubmit(b *testing.B) {
+	p := NewPipeline(persistWorkers, persistQueueSize, persistMaxBatch,
+		func(batch []*BroadcastMessage) []*BroadcastMessage { return batch },
+		func(*BroadcastMessage) {})
+	p.Start()
+	defer p.Stop()
+
+	ids := roomIDs(1000)
+	var next atomic.Int64
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		msg := &BroadcastMessage{RoomID: ids[int(next.Add(1))%len(ids)], Message: []byte("hello")}
+		for pb.Next() {
+			submitAll(p, msg)
+		}
+	})
+}
+
+// BenchmarkPipelineFlush measures end-to-end throughput from Submit to
+// deliver when each processor call costs a database round trip, showing how
+// batching amortizes that cost as the number of rooms grows
+func BenchmarkPipelineFlush(b *testing.B) {
+	const roundTrip = 100 * time.Microsecond
+
+	for _, maxBatch := range []int{1, persistMaxBatch} {
+		for _, rooms := range []int{1, 100, 10000} {
+			b.Run(fmt.Sprintf("batch=%d/rooms=%d", maxBatch, rooms), func(b *testing.B) {
+				var batches, delivered atomic.Int64
+				done := make(chan struct{})
+				total := int64(b.N)
+
+				p := NewPipeline(persistWorkers, persistQueueSize, maxBatch, func(batch []*BroadcastMessage) []*BroadcastMessage {
+					time.Sleep(roundTrip)
+					batches.Add(1)
+					return batch
+				}, func(*BroadcastMessage) {
+					if delivered.Add(1) == total {
+						close(done)
+					}
+				})
+				p.Start()
+
+				ids := roomIDs(rooms)
+				msgs := make([]*BroadcastMessage, rooms)
+				for i, id := range ids {
+					msgs[i] = &BroadcastMessage{RoomID: id, Message: []byte("hello")}
+				}
+
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					submitAll(p, msgs[i%rooms])
+				}
+				<-done
+				b.StopTimer()
+				p.Stop()
+
+				b.ReportMetric(float64(b.N)/float64(batches.Load()), "msgs/batch")
+			})
+		}
+	}
+}
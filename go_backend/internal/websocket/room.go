@@ -0,0 +1,204 @@
+package websocket
+
+import (
+	"log"
+	"sync"
+
+	"github.com/GavinHemsada/go-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// Broadcasts a room can have queued before new ones are dropped.
+const roomInboxLimit = 4096
+
+type roomEventKind int
+
+const (
+	eventRegister roomEventKind = iota
+	eventUnregister
+	eventBroadcast      // payload to every client
+	eventDirect         // payload to one client, if it is still connected
+	eventDisconnectUser // close every connection of userID, payload is the close frame
+	eventNotifyUser     // payload to every connection of userID
+	eventCloseRoom      // close every connection, payload is the close frame
+	eventSnapshot       // send the connected clients on reply
+)
+
+type roomEvent struct {
+	kind    roomEventKind
+	client  *Client
+	userID  string
+	payload []byte
+	reply   chan<- []models.ConnectedClient
+}
+
+// room is the actor for one room on this node. Its run goroutine is the only
+// code that touches the client set and the only code that closes a client's
+// send channel. Events are queued in an inbox guarded by mu so posting never
+// blocks on a busy room.
+//
+// A room stops once it has no clients and nothing queued. Stopping removes it
+// from its shard while holding the shard's write lock, and events are only
+// queued under the shard's read lock, so nothing can be queued on a stopped
+// room: the next event creates a fresh one.
+type room struct {
+	id      string
+	shard   *hubShard
+	mu      sync.Mutex
+	inbox   []roomEvent
+	wake    chan struct{}
+	clients map[*Client]struct{}
+}
+
+func newRoom(id string, shard *hubShard) *room {
+	return &room{
+		id:      id,
+		shard:   shard,
+		wake:    make(chan struct{}, 1),
+		clients: make(map[*Client]struct{}),
+	}
+}
+
+// enqueue queues ev. Callers must hold the shard lock (read or write).
+func (r *room) enqueue(ev roomEvent) {
+	r.mu.Lock()
+	if ev.kind == eventBroadcast && len(r.inbox) >= roomInboxLimit {
+		r.mu.Unlock()
+		log.Printf("Room %s inbox full, dropping broadcast", r.id)
+		return
+	}
+	r.inbox = append(r.inbox, ev)
+	r.mu.Unlock()
+
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (r *room) run() {
+	var events []roomEvent
+	for range r.wake {
+		for {
+			// Swap buffers so posters append to a fresh slice while this one is handled
+			r.mu.Lock()
+			events, r.inbox = r.inbox, events[:0]
+			r.mu.Unlock()
+
+			if len(events) == 0 {
+				break
+			}
+			for i := range events {
+				r.handle(events[i])
+				events[i] = roomEvent{} // don't keep payloads alive
+			}
+		}
+
+		if len(r.clients) == 0 && r.tryStop() {
+			return
+		}
+	}
+}
+
+// tryStop removes the room from its shard if nothing was queued meanwhile
+func (r *room) tryStop() bool {
+	r.shard.mu.Lock()
+	defer r.shard.mu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.inbox) > 0 {
+		return false
+	}
+	if r.shard.rooms[r.id] == r {
+		delete(r.shard.rooms, r.id)
+	}
+	return true
+}
+
+func (r *room) handle(ev roomEvent) {
+	switch ev.kind {
+	case eventRegister:
+		r.clients[ev.client] = struct{}{}
+		log.Printf("Client registered to room %s", r.id)
+
+	case eventUnregister:
+		if _, ok := r.clients[ev.client]; ok {
+			delete(r.clients, ev.client)
+			close(ev.client.send)
+			log.Printf("Client unregistered from room %s", r.id)
+		}
+
+	case eventBroadcast:
+		for client := range r.clients {
+			select {
+			case client.send <- ev.payload:
+			default:
+				r.dropSlow(client)
+			}
+		}
+
+	case eventDirect:
+		if _, ok := r.clients[ev.client]; ok {
+			select {
+			case ev.client.send <- ev.payload:
+			default:
+				r.dropSlow(ev.client)
+			}
+		}
+
+	case eventDisconnectUser:
+		for client := range r.clients {
+			if client.userID == ev.userID {
+				delete(r.clients, client)
+				client.closeMessage = ev.payload
+				close(client.send)
+				log.Printf("Disconnected user %s from room %s", ev.userID, r.id)
+			}
+		}
+
+	case eventCloseRoom:
+		for client := range r.clients {
+			delete(r.clients, client)
+			client.closeMessage = ev.payload
+			close(client.send)
+		}
+		log.Printf("Closed room %s", r.id)
+
+	case eventSnapshot:
+		clients := make([]models.ConnectedClient, 0, len(r.clients))
+		for client := range r.clients {
+			userID, _ := uuid.Parse(client.userID)
+			roomID, _ := uuid.Parse(client.roomID)
+			clients = append(clients, models.ConnectedClient{
+				UserID:      userID,
+				RoomID:      roomID,
+				RemoteAddr:  client.remoteAddr,
+				ConnectedAt: client.connectedAt,
+			})
+		}
+		ev.reply <- clients
+
+	case eventNotifyUser:
+		for client := range r.clients {
+			if client.userID == ev.userID {
+				select {
+				case client.send <- ev.payload:
+				default:
+					r.dropSlow(client)
+				}
+			}
+		}
+	}
+}
+
+// dropSlow disconnects a client whose send buffer is full. Closing the send
+// channel makes its WritePump send the close frame and shut the connection,
+// which in turn ends its ReadPump; the later unregister is then a no-op.
+func (r *room) dropSlow(client *Client) {
+	delete(r.clients, client)
+	client.closeMessage = websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "slow consumer")
+	close(client.send)
+	log.Printf("Disconnected slow client from room %s", r.id)
+}
@@ -0,0 +1,35 @@
+-- Reports (message_id is NULL for user reports)
+CREATE TABLE reports (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    room_id UUID NOT NULL REFERENCES rooms(id) ON DELETE CASCADE,
+    reporter_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    target_type VARCHAR(20) NOT NULL,
+    message_id UUID REFERENCES messages(id) ON DELETE SET NULL,
+    target_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    message_content TEXT NOT NULL DEFAULT '',
+    category VARCHAR(30) NOT NULL,
+    details TEXT NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL DEFAULT 'open',
+    claimed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    claimed_at TIMESTAMP,
+    resolved_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    resolved_at TIMESTAMP,
+    resolution VARCHAR(30) NOT NULL DEFAULT '',
+    resolution_note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Report audit trail
+CREATE TABLE report_actions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    report_id UUID NOT NULL REFERENCES reports(id) ON DELETE CASCADE,
+    actor_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    action VARCHAR(20) NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Indexes
+CREATE INDEX idx_reports_room_status ON reports(room_id, status, created_at);
+CREATE INDEX idx_reports_reporter ON reports(reporter_id);
+CREATE INDEX idx_report_actions_report ON report_actions(report_id, created_at);
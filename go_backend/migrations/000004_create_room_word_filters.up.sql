@@ -0,0 +1,9 @@
+-- Room Word Filters (word is stored lower-case)
+CREATE TABLE room_word_filters (
+    room_id UUID REFERENCES rooms(id) ON DELETE CASCADE,
+    word VARCHAR(100) NOT NULL,
+    action VARCHAR(10) NOT NULL,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (room_id, word)
+);
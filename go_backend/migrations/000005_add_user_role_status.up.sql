@@ -0,0 +1,3 @@
+-- Global role (user or admin) and account status (active or suspended)
+ALTER TABLE users ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'user';
+ALTER TABLE users ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'active';
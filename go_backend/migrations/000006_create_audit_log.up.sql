@@ -0,0 +1,34 @@
+-- Audit Log (append-only; every row carries a hash chained to the previous row)
+-- actor_id has no foreign key so events outlive the accounts they mention
+CREATE TABLE audit_log (
+    seq BIGINT PRIMARY KEY,
+    actor_id UUID,
+    action VARCHAR(50) NOT NULL,
+    target_type VARCHAR(20) NOT NULL DEFAULT '',
+    target_id VARCHAR(255) NOT NULL DEFAULT '',
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    result VARCHAR(10) NOT NULL,
+    details TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    prev_hash VARCHAR(64) NOT NULL,
+    hash VARCHAR(64) NOT NULL
+);
+
+CREATE INDEX idx_audit_log_actor ON audit_log(actor_id, seq DESC);
+CREATE INDEX idx_audit_log_action ON audit_log(action, seq DESC);
+CREATE INDEX idx_audit_log_target ON audit_log(target_id, seq DESC);
+
+CREATE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_no_change
+    BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+CREATE TRIGGER audit_log_no_truncate
+    BEFORE TRUNCATE ON audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
@@ -0,0 +1,6 @@
+-- SAML Authentication Requests awaiting a response (each usable once, until expires_at)
+CREATE TABLE saml_requests (
+    relay_state VARCHAR(64) PRIMARY KEY,
+    request_id VARCHAR(64) NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
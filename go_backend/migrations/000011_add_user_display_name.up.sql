@@ -0,0 +1,2 @@
+-- Display name (kept in sync with the directory for LDAP users)
+ALTER TABLE users ADD COLUMN display_name VARCHAR(100) NOT NULL DEFAULT '';
@@ -0,0 +1,5 @@
+-- Bot accounts (owned by a human user, deleted with them; bots have no password)
+ALTER TABLE users ADD COLUMN is_bot BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN owner_id UUID REFERENCES users(id) ON DELETE CASCADE;
+
+CREATE INDEX idx_users_owner_id ON users(owner_id);
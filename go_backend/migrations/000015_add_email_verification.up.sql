@@ -0,0 +1,2 @@
+-- Whether the user proved they own their email. Existing accounts start unverified.
+ALTER TABLE users ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,47 @@
+-- SQLite flavour of ../000001_create_users_rooms_messages.up.sql
+-- UUIDs are generated by the application and stored as text.
+-- Timestamps keep millisecond precision so newest-first ordering stays stable.
+
+-- Users
+CREATE TABLE users (
+    id TEXT PRIMARY KEY,
+    username VARCHAR(50) UNIQUE NOT NULL,
+    email VARCHAR(255) UNIQUE NOT NULL,
+    password_hash VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    updated_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+-- Rooms
+CREATE TABLE rooms (
+    id TEXT PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    room_type VARCHAR(20) NOT NULL,
+    created_by TEXT REFERENCES users(id),
+    created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+-- Room Members
+CREATE TABLE room_members (
+    room_id TEXT REFERENCES rooms(id) ON DELETE CASCADE,
+    user_id TEXT REFERENCES users(id) ON DELETE CASCADE,
+    joined_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    PRIMARY KEY (room_id, user_id)
+);
+
+-- Messages
+CREATE TABLE messages (
+    id TEXT PRIMARY KEY,
+    room_id TEXT REFERENCES rooms(id) ON DELETE CASCADE,
+    user_id TEXT REFERENCES users(id) ON DELETE SET NULL,
+    content TEXT NOT NULL,
+    message_type VARCHAR(20) DEFAULT 'text',
+    created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    updated_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    is_deleted BOOLEAN DEFAULT FALSE
+);
+
+-- Indexes
+CREATE INDEX idx_messages_room_created ON messages(room_id, created_at DESC);
+CREATE INDEX idx_messages_user ON messages(user_id);
+CREATE INDEX idx_room_members_user ON room_members(user_id);
@@ -0,0 +1,35 @@
+-- Reports (message_id is NULL for user reports)
+CREATE TABLE reports (
+    id TEXT PRIMARY KEY,
+    room_id TEXT NOT NULL REFERENCES rooms(id) ON DELETE CASCADE,
+    reporter_id TEXT REFERENCES users(id) ON DELETE SET NULL,
+    target_type VARCHAR(20) NOT NULL,
+    message_id TEXT REFERENCES messages(id) ON DELETE SET NULL,
+    target_user_id TEXT REFERENCES users(id) ON DELETE SET NULL,
+    message_content TEXT NOT NULL DEFAULT '',
+    category VARCHAR(30) NOT NULL,
+    details TEXT NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL DEFAULT 'open',
+    claimed_by TEXT REFERENCES users(id) ON DELETE SET NULL,
+    claimed_at TIMESTAMP,
+    resolved_by TEXT REFERENCES users(id) ON DELETE SET NULL,
+    resolved_at TIMESTAMP,
+    resolution VARCHAR(30) NOT NULL DEFAULT '',
+    resolution_note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+-- Report audit trail
+CREATE TABLE report_actions (
+    id TEXT PRIMARY KEY,
+    report_id TEXT NOT NULL REFERENCES reports(id) ON DELETE CASCADE,
+    actor_id TEXT REFERENCES users(id) ON DELETE SET NULL,
+    action VARCHAR(20) NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+-- Indexes
+CREATE INDEX idx_reports_room_status ON reports(room_id, status, created_at);
+CREATE INDEX idx_reports_reporter ON reports(reporter_id);
+CREATE INDEX idx_report_actions_report ON report_actions(report_id, created_at);
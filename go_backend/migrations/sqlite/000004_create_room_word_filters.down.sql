@@ -0,0 +1 @@
+DROP TABLE IF EXISTS room_word_filters;
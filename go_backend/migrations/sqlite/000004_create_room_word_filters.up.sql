@@ -0,0 +1,9 @@
+-- Room Word Filters (word is stored lower-case)
+CREATE TABLE room_word_filters (
+    room_id TEXT REFERENCES rooms(id) ON DELETE CASCADE,
+    word VARCHAR(100) NOT NULL,
+    action VARCHAR(10) NOT NULL,
+    created_by TEXT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    PRIMARY KEY (room_id, word)
+);
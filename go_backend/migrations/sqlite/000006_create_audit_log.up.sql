@@ -0,0 +1,30 @@
+-- Audit Log (append-only; every row carries a hash chained to the previous row)
+-- actor_id has no foreign key so events outlive the accounts they mention
+CREATE TABLE audit_log (
+    seq INTEGER PRIMARY KEY,
+    actor_id TEXT,
+    action VARCHAR(50) NOT NULL,
+    target_type VARCHAR(20) NOT NULL DEFAULT '',
+    target_id VARCHAR(255) NOT NULL DEFAULT '',
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    result VARCHAR(10) NOT NULL,
+    details TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    prev_hash VARCHAR(64) NOT NULL,
+    hash VARCHAR(64) NOT NULL
+);
+
+CREATE INDEX idx_audit_log_actor ON audit_log(actor_id, seq DESC);
+CREATE INDEX idx_audit_log_action ON audit_log(action, seq DESC);
+CREATE INDEX idx_audit_log_target ON audit_log(target_id, seq DESC);
+
+CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+
+CREATE TRIGGER audit_log_no_delete BEFORE DELETE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
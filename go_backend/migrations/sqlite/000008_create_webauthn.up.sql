@@ -0,0 +1,27 @@
+-- Passkeys (WebAuthn credentials; flags holds the backup and verification bits)
+CREATE TABLE webauthn_credentials (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    credential_id BLOB NOT NULL UNIQUE,
+    name VARCHAR(100) NOT NULL,
+    public_key BLOB NOT NULL,
+    attestation_type VARCHAR(30) NOT NULL DEFAULT '',
+    transports VARCHAR(255) NOT NULL DEFAULT '',
+    aaguid BLOB,
+    flags INTEGER NOT NULL DEFAULT 0,
+    sign_count INTEGER NOT NULL DEFAULT 0,
+    clone_warning BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    last_used_at TIMESTAMP
+);
+
+CREATE INDEX idx_webauthn_credentials_user_id ON webauthn_credentials(user_id);
+
+-- Passkey Ceremonies in progress (each usable once, until expires_at)
+CREATE TABLE webauthn_sessions (
+    id TEXT PRIMARY KEY,
+    ceremony VARCHAR(20) NOT NULL,
+    user_id TEXT REFERENCES users(id) ON DELETE CASCADE,
+    data TEXT NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,14 @@
+-- Personal Access Tokens (only the SHA-256 of each token is stored; scopes are comma-separated)
+CREATE TABLE access_tokens (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    prefix VARCHAR(20) NOT NULL,
+    scopes VARCHAR(255) NOT NULL,
+    expires_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    last_used_at TIMESTAMP
+);
+
+CREATE INDEX idx_access_tokens_user_id ON access_tokens(user_id);
@@ -0,0 +1,48 @@
+-- OAuth2 apps acting for users. Public apps have no secret and rely on PKCE;
+-- redirect URIs are newline-separated.
+CREATE TABLE oauth_apps (
+    id TEXT PRIMARY KEY,
+    owner_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    secret_hash VARCHAR(64),
+    redirect_uris TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE INDEX idx_oauth_apps_owner_id ON oauth_apps(owner_id);
+
+-- Authorization codes waiting to be exchanged (only their SHA-256 is stored)
+CREATE TABLE oauth_codes (
+    code_hash VARCHAR(64) PRIMARY KEY,
+    app_id TEXT NOT NULL REFERENCES oauth_apps(id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    redirect_uri TEXT NOT NULL,
+    scopes VARCHAR(255) NOT NULL,
+    code_challenge VARCHAR(128) NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+-- Scopes each user granted each app
+CREATE TABLE oauth_consents (
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    app_id TEXT NOT NULL REFERENCES oauth_apps(id) ON DELETE CASCADE,
+    scopes VARCHAR(255) NOT NULL,
+    granted_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    PRIMARY KEY (user_id, app_id)
+);
+
+-- Access tokens issued to apps with the refresh token that renews each
+CREATE TABLE oauth_tokens (
+    id TEXT PRIMARY KEY,
+    app_id TEXT NOT NULL REFERENCES oauth_apps(id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    access_hash VARCHAR(64) NOT NULL UNIQUE,
+    refresh_hash VARCHAR(64) NOT NULL UNIQUE,
+    scopes VARCHAR(255) NOT NULL,
+    access_expires_at TIMESTAMP NOT NULL,
+    refresh_expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE INDEX idx_oauth_tokens_app_id ON oauth_tokens(app_id);
+CREATE INDEX idx_oauth_tokens_user_id ON oauth_tokens(user_id);
@@ -0,0 +1,13 @@
+-- One-time login links emailed to users (only SHA-256 hashes are stored).
+-- A link only works on the device that asked for it, which holds the
+-- device token. Used links are kept a while for rate limiting.
+CREATE TABLE login_links (
+    token_hash VARCHAR(64) PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    device_hash VARCHAR(64) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE INDEX idx_login_links_user_id_created_at ON login_links(user_id, created_at);
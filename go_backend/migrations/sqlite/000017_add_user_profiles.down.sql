@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS avatars;
+ALTER TABLE users DROP COLUMN avatar_id;
+ALTER TABLE users DROP COLUMN status_expires_at;
+ALTER TABLE users DROP COLUMN status_emoji;
+ALTER TABLE users DROP COLUMN status_text;
+ALTER TABLE users DROP COLUMN pronouns;
+ALTER TABLE users DROP COLUMN time_zone;
+ALTER TABLE users DROP COLUMN bio;
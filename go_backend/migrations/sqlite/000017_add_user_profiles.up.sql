@@ -0,0 +1,19 @@
+-- Profile fields users edit themselves. The custom status is hidden once
+-- status_expires_at has passed.
+ALTER TABLE users ADD COLUMN bio TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN time_zone VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN pronouns VARCHAR(40) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN status_text VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN status_emoji VARCHAR(32) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN status_expires_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN avatar_id TEXT;
+
+-- Uploaded avatar images, one per user. A new upload gets a new ID, so
+-- avatar URLs can be cached for good.
+CREATE TABLE avatars (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+    content_type VARCHAR(32) NOT NULL,
+    data BLOB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS privacy_settings;
+ALTER TABLE users DROP COLUMN last_seen_at;
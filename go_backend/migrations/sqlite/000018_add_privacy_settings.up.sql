@@ -0,0 +1,13 @@
+-- When the user was last active, behind their presence and last seen time
+ALTER TABLE users ADD COLUMN last_seen_at TIMESTAMP;
+
+-- Who may see a user's email, presence and last seen time, and who may send
+-- them direct messages. Users without a row get the defaults.
+CREATE TABLE privacy_settings (
+    user_id TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    email_visibility VARCHAR(20) NOT NULL DEFAULT 'nobody',
+    presence_visibility VARCHAR(20) NOT NULL DEFAULT 'everyone',
+    last_seen_visibility VARCHAR(20) NOT NULL DEFAULT 'room_members',
+    direct_messages VARCHAR(20) NOT NULL DEFAULT 'everyone',
+    updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
@@ -0,0 +1,3 @@
+-- Changes when the user's sessions are revoked, such as on a password
+-- reset; session tokens carrying another stamp stop working
+ALTER TABLE users ADD COLUMN session_stamp VARCHAR(64) NOT NULL DEFAULT '';
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"errors"
 	"time"
 
 	"github.com/GavinHemsada/go-backend/internal/models"
@@ -8,15 +9,50 @@ import (
 	"github.com/google/uuid"
 )
 
-// Claims represents the JWT claims structure
+// Token purposes. Session tokens have none; the others only work for the
+// step they were issued for.
+const (
+	PurposeTwoFactor      = "2fa"            // log in with a second factor
+	PurposeTwoFactorSetup = "2fa_setup"      // enroll a second factor required by policy, then log in
+	PurposeEmailVerify    = "email_verify"   // confirm the account's email address
+	PurposePasswordReset  = "password_reset" // choose a new password
+)
+
+// AccessTokenPrefix starts every personal access token, which tells them
+// apart from JWTs in the Authorization header
+const AccessTokenPrefix = "chat_pat_"
+
+// OAuthAccessTokenPrefix starts every access token issued to an OAuth2 app
+const OAuthAccessTokenPrefix = "chat_oat_"
+
+// Claims represents the JWT claims structure. Requests made with a personal
+// access token or an OAuth2 access token get claims too, with the token's ID
+// and scopes.
 type Claims struct {
 	UserID   uuid.UUID `json:"user_id"`
 	Username string    `json:"username"`
 	Email    string    `json:"email"`
+	Purpose  string    `json:"purpose,omitempty"`
+	Stamp    string    `json:"stamp,omitempty"` // session stamp of a session token, or fingerprint of the account state a link token was issued for
 	jwt.RegisteredClaims
+
+	TokenID *uuid.UUID    `json:"-"` // nil for session tokens
+	Scopes  models.Scopes `json:"-"`
+}
+
+// AccountError tells why an account may no longer use a valid token: it is
+// suspended, deactivated or deleted, or its sessions were revoked. Account
+// checks that cannot be made return other errors.
+type AccountError struct {
+	Reason string
+}
+
+func (e *AccountError) Error() string {
+	return e.Reason
 }
 
-// GenerateToken creates a JWT token for a user
+// GenerateToken creates a JWT token for a user. It works until the user's
+// session stamp changes.
 func GenerateToken(user *models.User, jwtSecret string) (string, error) {
 	expirationTime := time.Now().Add(24 * time.Hour) // Token expires in 24 hours
 
@@ -24,6 +60,7 @@ func GenerateToken(user *models.User, jwtSecret string) (string, error) {
 		UserID:   user.ID,
 		Username: user.Username,
 		Email:    user.Email,
+		Stamp:    user.SessionStamp,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -40,4 +77,57 @@ func GenerateToken(user *models.User, jwtSecret string) (string, error) {
 	}
 
 	return tokenString, nil
-}
\ No newline at end of file
+}
+
+// GenerateChallengeToken creates a short-lived token showing that user passed
+// the password check, for the login step named by purpose
+func GenerateChallengeToken(user *models.User, jwtSecret, purpose string, ttl time.Duration) (string, time.Time, error) {
+	return GenerateLinkToken(user, jwtSecret, purpose, "", ttl)
+}
+
+// GenerateLinkToken creates a token for an emailed link, like
+// GenerateChallengeToken. The link should stop working once stamp no longer
+// matches the account.
+func GenerateLinkToken(user *models.User, jwtSecret, purpose, stamp string, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	expirationTime := now.Add(ttl)
+
+	claims := &Claims{
+		UserID:   user.ID,
+		Username: user.Username,
+		Email:    user.Email,
+		Purpose:  purpose,
+		Stamp:    stamp,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    "chat-app",
+			Subject:   user.ID.String(),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString([]byte(jwtSecret))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return tokenString, expirationTime, nil
+}
+
+// ParseChallengeToken validates a token from GenerateChallengeToken or
+// GenerateLinkToken issued for purpose
+func ParseChallengeToken(tokenString, jwtSecret, purpose string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("invalid signing method")
+		}
+		return []byte(jwtSecret), nil
+	})
+	if err != nil || !token.Valid || claims.Purpose != purpose {
+		return nil, errors.New("invalid or expired challenge token")
+	}
+	return claims, nil
+}